@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -12,13 +15,21 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/erazemk/skladisce/internal/alerts"
 	"github.com/erazemk/skladisce/internal/api"
+	"github.com/erazemk/skladisce/internal/backup"
+	"github.com/erazemk/skladisce/internal/config"
 	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/reprocess"
 	"github.com/erazemk/skladisce/internal/store"
 	"github.com/erazemk/skladisce/internal/web"
 )
@@ -54,6 +65,15 @@ func (lr *levelRouter) WithGroup(name string) slog.Handler {
 	}
 }
 
+// resolveDataPath returns path joined onto dataDir. Empty and absolute paths,
+// and any path when dataDir is empty, are returned unchanged.
+func resolveDataPath(dataDir, path string) string {
+	if dataDir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dataDir, path)
+}
+
 // setupLogger configures structured logging. INFO/WARN go to stdout, ERROR goes
 // to stderr. If logPath is non-empty, all levels are also written to that file.
 // Returns a cleanup function that closes the log file (if opened).
@@ -84,52 +104,170 @@ func setupLogger(logPath string) (func(), error) {
 }
 
 func main() {
-	fs := flag.NewFlagSet("skladisce", flag.ContinueOnError)
-
-	var dbPath string
-	fs.StringVar(&dbPath, "db", "skladisce.sqlite3", "")
-	fs.StringVar(&dbPath, "d", "skladisce.sqlite3", "")
-
-	var addr string
-	fs.StringVar(&addr, "addr", ":8080", "")
-	fs.StringVar(&addr, "a", ":8080", "")
-
-	var adminUser string
-	fs.StringVar(&adminUser, "user", "Admin", "")
-	fs.StringVar(&adminUser, "u", "Admin", "")
-
-	var logPath string
-	fs.StringVar(&logPath, "log", "", "")
-	fs.StringVar(&logPath, "l", "", "")
-
-	fs.Usage = func() {
-		fmt.Fprint(os.Stdout, `Usage: skladisce [flags]
-
-Flags:
+	usage := func() {
+		fmt.Fprint(os.Stdout, `Usage: skladisce [flags] [command]
+
+Commands:
+  optimize [-vacuum]      run PRAGMA optimize and truncate the WAL, then exit;
+                          -vacuum also rebuilds the file to reclaim free space
+                          (locks the database, stop the server first)
+  reprocess-images [-delay <duration>]
+                          re-run image processing over stored item images
+                          that exceed the current size limit or are not
+                          JPEG, pausing between images (default: 100ms),
+                          then exit; Ctrl-C stops after the current image
+
+Flags (each long flag can also be set with a SKLADISCE_* environment variable,
+e.g. SKLADISCE_DATA_DIR, or a JSON config file; flags win over the
+environment, which wins over the file):
+      -config <path>      JSON config file keyed by long flag name
+                          (default: $SKLADISCE_CONFIG, or none)
   -d, -db <path>          SQLite database path (default: skladisce.sqlite3)
   -a, -addr <host:port>   listen address (default: :8080)
   -u, -user <name>        admin username on first run (default: Admin)
+      -admin-password <pw>
+                          admin password on first run, or - to read it from
+                          the first line of stdin (default: generated and
+                          printed, must be changed on first login)
   -l, -log <path>         log file path (default: no file, stdout/stderr only)
+      -data-dir <dir>     resolve relative -db, -log and -backup-dir paths
+                          against dir, creating it if needed (default:
+                          working directory)
+      -max-requests <n>   maximum concurrent requests, 0 = unlimited (default: 64)
+      -max-body <bytes>   maximum request body size, 0 = unlimited (default: 8388608)
+      -readonly           start in read-only mode (reject mutating requests)
+      -base-path <path>   serve everything under a path prefix, e.g. /skladisce
+                          (default: none, served at /)
+      -csp <policy>       Content-Security-Policy header, "" = none (default: see SPEC.md)
+      -hsts               send Strict-Transport-Security (set when behind HTTPS;
+                          implied by -tls-cert)
+      -tls-cert <path>    serve HTTPS with this PEM certificate (chain); needs
+                          -tls-key (default: none, plain HTTP)
+      -tls-key <path>     PEM private key for -tls-cert
+      -trusted-proxies <list>
+                          comma-separated proxy CIDRs/IPs whose X-Forwarded-For
+                          and X-Real-IP headers are trusted (default: none)
+      -min-password <n>   minimum password length, 8-64 (default: 8)
+      -default-role <role>
+                          role for API-created users that omit one: user,
+                          manager or admin (default: none, role required)
+      -read-role <role>   minimum role to read items, owners, inventory,
+                          transfers, reports and search: user, manager or
+                          admin (default: user)
+      -restrict-transfers only let managers and admins move items between other
+                          owners; users may only transfer to or from the
+                          person owner linked to their account
+      -max-items <n>      maximum active items, 0 = unlimited (default: 0)
+      -max-owners <n>     maximum active owners, 0 = unlimited (default: 0)
+      -max-page-size <n>  largest page paginated endpoints return; bigger
+                          ?limit values are capped (default: 200)
+      -low-stock-interval <duration>
+                          how often to check items against their low-stock
+                          threshold, 0 = never (default: 5m)
+      -low-stock-webhook <url>
+                          POST low-stock alerts as JSON to url (default: none,
+                          alerts are only logged)
+      -backup-dir <dir>   write a backup of the database into dir every
+                          -backup-interval (default: none, no backups)
+      -backup-interval <duration>
+                          time between backups (default: 24h)
+      -backup-keep <n>    backups to keep in -backup-dir, 0 = all (default: 7)
   -h, -help               show this help and exit
 `)
 	}
 
-	if err := fs.Parse(os.Args[1:]); err != nil {
+	cfg, args, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err != nil {
 		if err == flag.ErrHelp {
+			usage()
 			os.Exit(0)
 		}
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		usage()
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] != "optimize" && args[0] != "reprocess-images" {
+		fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", args[0])
+		usage()
+		os.Exit(1)
+	}
+
+	if err := model.SetMinPasswordLength(cfg.MinPassword); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -min-password: %v\n", err)
+		os.Exit(1)
+	}
+
+	if cfg.DefaultRole != "" && !model.ValidRole(cfg.DefaultRole) {
+		fmt.Fprintf(os.Stderr, "invalid -default-role: %q (must be user, manager or admin)\n", cfg.DefaultRole)
+		os.Exit(1)
+	}
+
+	if cfg.ReadRole != "" && !model.ValidRole(cfg.ReadRole) {
+		fmt.Fprintf(os.Stderr, "invalid -read-role: %q (must be user, manager or admin)\n", cfg.ReadRole)
+		os.Exit(1)
+	}
+
+	basePath, err := normalizeBasePath(cfg.BasePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -base-path: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := store.SetQuotas(cfg.MaxItems, cfg.MaxOwners); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -max-items/-max-owners: %v\n", err)
+		os.Exit(1)
+	}
+
+	if cfg.MaxPageSize < 1 {
+		fmt.Fprintf(os.Stderr, "invalid -max-page-size: %d (must be at least 1)\n", cfg.MaxPageSize)
+		os.Exit(1)
+	}
+
+	if cfg.BackupDir != "" && cfg.BackupInterval <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid -backup-interval: %s (must be positive)\n", cfg.BackupInterval)
+		os.Exit(1)
+	}
+	if cfg.BackupKeep < 0 {
+		fmt.Fprintf(os.Stderr, "invalid -backup-keep: %d (must not be negative)\n", cfg.BackupKeep)
 		os.Exit(1)
 	}
 
-	if fs.NArg() > 0 {
-		fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", fs.Arg(0))
-		fs.Usage()
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		fmt.Fprintln(os.Stderr, "invalid -tls-cert/-tls-key: set both or neither")
 		os.Exit(1)
 	}
 
+	proxies, err := api.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -trusted-proxies: %v\n", err)
+		os.Exit(1)
+	}
+
+	if cfg.DataDir != "" {
+		if err := os.MkdirAll(cfg.DataDir, 0750); err != nil {
+			fmt.Fprintf(os.Stderr, "error: creating data directory: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.DB = resolveDataPath(cfg.DataDir, cfg.DB)
+		cfg.Log = resolveDataPath(cfg.DataDir, cfg.Log)
+		cfg.BackupDir = resolveDataPath(cfg.DataDir, cfg.BackupDir)
+	}
+
+	// Load the certificate before anything else starts, so a bad or missing
+	// file fails startup instead of the first handshake.
+	var tlsConfig *tls.Config
+	if cfg.TLSCert != "" {
+		tlsConfig, err = loadTLSConfig(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -tls-cert/-tls-key: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Set up structured logging: INFO/WARN → stdout, ERROR → stderr.
 	// Optionally also write to a log file.
-	closeLog, err := setupLogger(logPath)
+	closeLog, err := setupLogger(cfg.Log)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
@@ -138,21 +276,44 @@ Flags:
 		defer closeLog()
 	}
 
+	if len(args) > 0 && args[0] == "optimize" {
+		if err := runOptimize(cfg.DB, args[1:]); err != nil {
+			slog.Error("optimize failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "reprocess-images" {
+		if err := runReprocessImages(cfg.DB, args[1:]); err != nil {
+			slog.Error("image reprocessing failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Check if DB exists, auto-init if not.
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		database, password, err := initDatabase(dbPath, adminUser)
+	if _, err := os.Stat(cfg.DB); os.IsNotExist(err) {
+		adminPassword, err := readAdminPassword(cfg.AdminPassword, os.Stdin)
+		if err != nil {
+			slog.Error("failed to read -admin-password", "error", err)
+			os.Exit(1)
+		}
+		database, password, err := initDatabase(cfg.DB, cfg.AdminUser, adminPassword)
 		if err != nil {
 			slog.Error("failed to initialize database", "error", err)
 			os.Exit(1)
 		}
 		database.Close()
 
-		printInitResult(dbPath, adminUser, password)
+		printInitResult(cfg.DB, cfg.AdminUser, password)
 		fmt.Println()
+	} else if cfg.AdminPassword != "" {
+		slog.Warn("ignoring -admin-password, the database already exists")
 	}
 
 	// Open database.
-	database, err := db.Open(dbPath)
+	database, err := db.Open(cfg.DB)
 	if err != nil {
 		slog.Error("failed to open database", "error", err)
 		os.Exit(1)
@@ -165,7 +326,7 @@ Flags:
 		os.Exit(1)
 	}
 
-	slog.Info("database ready", "path", dbPath)
+	slog.Info("database ready", "path", cfg.DB)
 
 	// Load JWT secret from database (auto-generated on first run).
 	jwtSecret, err := store.GetJWTSecret(context.Background(), database)
@@ -174,12 +335,56 @@ Flags:
 		os.Exit(1)
 	}
 
-	// Set up routers.
-	apiRouter := api.NewRouter(database, jwtSecret)
-	webRouter, err := web.NewRouter(database, jwtSecret)
+	// Background jobs run until shutdown. The low-stock scanner checks for
+	// items dropping below their threshold; inventory snapshots feed the
+	// trend report; the backup scheduler copies the database into
+	// -backup-dir, and is waited for before the database is closed.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go snapshotInventory(bgCtx, database)
+	if cfg.LowStockInterval > 0 {
+		notifiers := []alerts.Notifier{alerts.LogNotifier{}}
+		if cfg.LowStockWebhook != "" {
+			notifiers = append(notifiers, alerts.NewWebhookNotifier(cfg.LowStockWebhook))
+		}
+		scanner := &alerts.Scanner{DB: database, Interval: cfg.LowStockInterval, Notifiers: notifiers}
+		go scanner.Run(bgCtx)
+	}
+	backupDone := make(chan struct{})
+	if cfg.BackupDir != "" {
+		if err := os.MkdirAll(cfg.BackupDir, 0750); err != nil {
+			slog.Error("failed to create backup directory", "error", err)
+			os.Exit(1)
+		}
+		scheduler := &backup.Scheduler{DB: database, Dir: cfg.BackupDir, Interval: cfg.BackupInterval, Keep: cfg.BackupKeep}
+		go func() {
+			defer close(backupDone)
+			scheduler.Run(bgCtx)
+		}()
+	} else {
+		close(backupDone)
+	}
+
+	// Set up routers. A broken web UI must not take the API down with it, so
+	// a web router failure is logged and replaced with a stub handler.
+	readOnlyMode := api.NewReadOnlyMode(cfg.ReadOnly)
+	apiRouter := api.NewRouter(database, jwtSecret, api.Options{
+		ReadOnly:    readOnlyMode,
+		DefaultRole: cfg.DefaultRole,
+		MaxPageSize: cfg.MaxPageSize,
+		Config:      cfg.Effective(),
+		ReadRole:    cfg.ReadRole,
+
+		RestrictTransfers: cfg.RestrictTransfers,
+		ImageJob:          &reprocess.Job{DB: database, Delay: reprocess.DefaultDelay, Context: bgCtx},
+	})
+	webRouter, err := web.NewRouter(database, jwtSecret, basePath, web.Options{
+		RestrictTransfers: cfg.RestrictTransfers,
+		ReadRole:          cfg.ReadRole,
+	})
 	if err != nil {
-		slog.Error("failed to set up web router", "error", err)
-		os.Exit(1)
+		slog.Error("failed to set up web router, serving API only", "error", err)
+		webRouter = http.HandlerFunc(uiUnavailable)
 	}
 
 	// Combine: API routes take priority, web routes handle the rest.
@@ -187,15 +392,22 @@ Flags:
 	mux.Handle("/api/", apiRouter)
 	mux.Handle("/", webRouter)
 
-	handler := api.LoggingMiddleware(mux)
+	handler := api.TrustedProxies(proxies)(
+		api.LoggingMiddleware(
+			api.RecoverPanics(
+				api.SecurityHeaders(cfg.CSP, cfg.HSTS)(
+					api.MaxConcurrentRequests(cfg.MaxRequests)(
+						api.MaxBodySize(cfg.MaxBody)(
+							mountAt(basePath, readOnlyMode.Middleware(mux))))))))
 
 	server := &http.Server{
-		Addr:              addr,
+		Addr:              cfg.Addr,
 		Handler:           handler,
 		ReadHeaderTimeout: 10 * time.Second,
 		ReadTimeout:       30 * time.Second,
 		WriteTimeout:      60 * time.Second,
 		IdleTimeout:       120 * time.Second,
+		TLSConfig:         tlsConfig,
 	}
 
 	// Graceful shutdown on SIGINT/SIGTERM.
@@ -205,6 +417,7 @@ Flags:
 	go func() {
 		sig := <-quit
 		slog.Info("shutdown signal received", "signal", sig.String())
+		stopBackground()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -214,17 +427,197 @@ Flags:
 		}
 	}()
 
-	slog.Info("server started", "addr", addr)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if cfg.ReadOnly {
+		slog.Warn("starting in read-only mode")
+	}
+	slog.Info("server started", "addr", cfg.Addr, "tls", tlsConfig != nil)
+	if tlsConfig != nil {
+		// The certificate is already in TLSConfig.
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		slog.Error("server error", "error", err)
 		os.Exit(1)
 	}
 
+	// A backup still running is aborted; wait for it to clean up.
+	stopBackground()
+	<-backupDone
+
 	slog.Info("server stopped, closing database")
 }
 
-// initDatabase creates a new database, ensures the schema, and creates the admin user.
-func initDatabase(path, adminUsername string) (*sql.DB, string, error) {
+// runOptimize implements the optimize command against an existing database.
+func runOptimize(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("optimize", flag.ContinueOnError)
+	vacuum := fs.Bool("vacuum", false, "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	before, err := store.GetDBStats(ctx, database)
+	if err != nil {
+		return err
+	}
+	if *vacuum {
+		slog.Warn("VACUUM locks the database until it finishes; other connections will block")
+	}
+	if err := store.Optimize(ctx, database, *vacuum); err != nil {
+		return err
+	}
+	after, err := store.GetDBStats(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Pages: %d -> %d (free: %d -> %d)\n", before.PageCount, after.PageCount, before.FreelistCount, after.FreelistCount)
+	fmt.Printf("File size: %d -> %d bytes\n", before.FileSize+before.WALSize, after.FileSize+after.WALSize)
+	return nil
+}
+
+// runReprocessImages implements the reprocess-images command against an
+// existing database. It can run alongside the server, which keeps serving
+// the old images until each is replaced.
+func runReprocessImages(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("reprocess-images", flag.ContinueOnError)
+	delay := fs.Duration("delay", reprocess.DefaultDelay, "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *delay < 0 {
+		return fmt.Errorf("invalid -delay: %s", *delay)
+	}
+
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	status, _ := (&reprocess.Job{DB: database, Delay: *delay}).Run(ctx)
+	if status.Error != "" {
+		return errors.New(status.Error)
+	}
+
+	fmt.Printf("Images: %d updated, %d already conforming, %d failed (of %d)\n",
+		status.Updated, status.Skipped, status.Failed, status.Total)
+	if status.Canceled {
+		fmt.Printf("Stopped early after %d of %d images.\n", status.Processed, status.Total)
+	}
+	return nil
+}
+
+// snapshotInterval is how often the inventory snapshot of the current day is
+// refreshed, so the last one of each day is close to its end.
+const snapshotInterval = time.Hour
+
+// snapshotInventory records the day's inventory snapshot now and then every
+// snapshotInterval until ctx is canceled.
+func snapshotInventory(ctx context.Context, database *sql.DB) {
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+	for {
+		if err := store.RecordInventorySnapshot(ctx, database, time.Now()); err != nil && ctx.Err() == nil {
+			slog.Error("failed to record inventory snapshot", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// normalizeBasePath validates a -base-path value and returns it without a
+// trailing slash. "" and "/" mean the root and yield "".
+func normalizeBasePath(p string) (string, error) {
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	if p[0] != '/' {
+		return "", fmt.Errorf("%q must start with /", p)
+	}
+	if path.Clean(p) != p {
+		return "", fmt.Errorf("%q is not a clean path", p)
+	}
+	for _, c := range p {
+		if !(c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return "", fmt.Errorf("%q contains %q; use letters, digits and -._~", p, c)
+		}
+	}
+	return p, nil
+}
+
+// loadTLSConfig loads the PEM certificate and key for serving HTTPS
+// directly. It allows TLS 1.2 and later, with only forward-secret AEAD cipher
+// suites for 1.2 (those of 1.3 are not configurable).
+func loadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}, nil
+}
+
+// mountAt serves h under basePath, stripping the prefix before h sees the
+// request. Requests outside basePath get 404, and basePath itself redirects
+// to basePath + "/". An empty basePath returns h unchanged.
+func mountAt(basePath string, h http.Handler) http.Handler {
+	if basePath == "" {
+		return h
+	}
+	mux := http.NewServeMux()
+	mux.Handle(basePath+"/", http.StripPrefix(basePath, h))
+	return mux
+}
+
+// uiUnavailable responds to web UI requests when the web router failed to load.
+func uiUnavailable(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "UI unavailable", http.StatusServiceUnavailable)
+}
+
+// initDatabase creates a new database, ensures the schema, and creates the
+// admin user with adminPassword. If adminPassword is empty, a password is
+// generated, returned and must be changed on first login; otherwise the
+// returned password is empty.
+func initDatabase(path, adminUsername, adminPassword string) (*sql.DB, string, error) {
+	if adminPassword != "" {
+		if err := model.ValidatePassword(adminPassword); err != nil {
+			return nil, "", fmt.Errorf("invalid admin password: %w", err)
+		}
+	}
+
 	database, err := db.Open(path)
 	if err != nil {
 		return nil, "", fmt.Errorf("opening database: %w", err)
@@ -236,11 +629,14 @@ func initDatabase(path, adminUsername string) (*sql.DB, string, error) {
 		return nil, "", fmt.Errorf("ensuring schema: %w", err)
 	}
 
-	password, err := generatePassword(16)
-	if err != nil {
-		database.Close()
-		os.Remove(path)
-		return nil, "", fmt.Errorf("generating password: %w", err)
+	password := adminPassword
+	if password == "" {
+		password, err = generatePassword(16)
+		if err != nil {
+			database.Close()
+			os.Remove(path)
+			return nil, "", fmt.Errorf("generating password: %w", err)
+		}
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -251,27 +647,61 @@ func initDatabase(path, adminUsername string) (*sql.DB, string, error) {
 	}
 
 	ctx := context.Background()
-	_, err = store.CreateUser(ctx, database, adminUsername, string(hash), "admin")
+	admin, err := store.CreateUser(ctx, database, adminUsername, string(hash), "admin")
 	if err != nil {
 		database.Close()
 		os.Remove(path)
 		return nil, "", fmt.Errorf("creating admin user: %w", err)
 	}
 
+	if adminPassword != "" {
+		return database, "", nil
+	}
+
+	// The generated password is printed to stdout, so it must be replaced
+	// on first login.
+	if err := store.SetMustChangePassword(ctx, database, admin.ID, true); err != nil {
+		database.Close()
+		os.Remove(path)
+		return nil, "", fmt.Errorf("flagging admin password: %w", err)
+	}
+
 	return database, password, nil
 }
 
-// printInitResult prints the database initialization result to stdout.
+// readAdminPassword returns the -admin-password value, reading the first
+// line of stdin instead if it is "-".
+func readAdminPassword(value string, stdin io.Reader) (string, error) {
+	if value != "-" {
+		return value, nil
+	}
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading stdin: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("no password on stdin")
+	}
+	return line, nil
+}
+
+// printInitResult prints the database initialization result to stdout. An
+// empty password means the admin password was given with -admin-password.
 func printInitResult(dbPath, username, password string) {
 	fmt.Printf("Database created: %s\n", dbPath)
 	fmt.Println("Schema initialized.")
 	fmt.Println()
 	fmt.Println("Admin account created:")
 	fmt.Printf("  Username: %s\n", username)
+	if password == "" {
+		fmt.Println("  Password: as given with -admin-password")
+		return
+	}
 	fmt.Printf("  Password: %s\n", password)
 	fmt.Println()
 	fmt.Println("Save this password — it cannot be recovered.")
-	fmt.Println("The admin can change it after logging in.")
+	fmt.Println("It must be changed on first login.")
 }
 
 // generatePassword creates a random password of the given length.
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+	"github.com/erazemk/skladisce/internal/web"
+)
+
+func TestResolveDataPath(t *testing.T) {
+	abs := filepath.Join(t.TempDir(), "abs.sqlite3")
+	tests := []struct {
+		dataDir, path, want string
+	}{
+		{"", "skladisce.sqlite3", "skladisce.sqlite3"},
+		{"/var/lib/skladisce", "skladisce.sqlite3", filepath.Join("/var/lib/skladisce", "skladisce.sqlite3")},
+		{"/var/lib/skladisce", "logs/app.log", filepath.Join("/var/lib/skladisce", "logs", "app.log")},
+		{"data", "skladisce.sqlite3", filepath.Join("data", "skladisce.sqlite3")},
+		{"/var/lib/skladisce", abs, abs},
+		{"/var/lib/skladisce", "", ""},
+	}
+
+	for _, tt := range tests {
+		got := resolveDataPath(tt.dataDir, tt.path)
+		if got != tt.want {
+			t.Errorf("resolveDataPath(%q, %q) = %q, want %q", tt.dataDir, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"", "", false},
+		{"/", "", false},
+		{"/skladisce", "/skladisce", false},
+		{"/skladisce/", "/skladisce", false},
+		{"/tools/skladisce", "/tools/skladisce", false},
+		{"skladisce", "", true},
+		{"/a/../b", "", true},
+		{"/a//b", "", true},
+		{"/{id}", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeBasePath(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeBasePath(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeBasePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBasePathRedirects(t *testing.T) {
+	database := db.NewTestDB(t)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	store.CreateUser(context.Background(), database, "admin", string(hash), model.RoleAdmin)
+
+	webRouter, err := web.NewRouter(database, "test-secret", "/skladisce", web.Options{})
+	if err != nil {
+		t.Fatalf("web.NewRouter: %v", err)
+	}
+	server := httptest.NewServer(mountAt("/skladisce", webRouter))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	get := func(path string) *http.Response {
+		t.Helper()
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get("/skladisce/items"); resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/skladisce/login" {
+		t.Errorf("expected 303 to /skladisce/login, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp := get("/skladisce"); resp.StatusCode/100 != 3 || resp.Header.Get("Location") != "/skladisce/" {
+		t.Errorf("expected redirect to /skladisce/, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp := get("/items"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 outside the base path, got %d", resp.StatusCode)
+	}
+
+	resp, err := client.PostForm(server.URL+"/skladisce/login", url.Values{"username": {"admin"}, "password": {"password"}})
+	if err != nil {
+		t.Fatalf("POST login: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/skladisce/" {
+		t.Errorf("expected 303 to /skladisce/ after login, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 1 || cookies[0].Path != "/skladisce" {
+		t.Fatalf("expected session cookie scoped to /skladisce, got %v", cookies)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/skladisce/items", nil)
+	req.AddCookie(cookies[0])
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("GET items: %v", err)
+	}
+	defer resp.Body.Close()
+	var body strings.Builder
+	if _, err := io.Copy(&body, resp.Body); err != nil {
+		t.Fatalf("reading items page: %v", err)
+	}
+	for _, want := range []string{`href="/skladisce/static/style.css"`, `action="/skladisce/items"`, `href="/skladisce/owners"`} {
+		if !strings.Contains(body.String(), want) {
+			t.Errorf("expected items page to contain %s", want)
+		}
+	}
+}
+
+func TestInitDatabaseForcesPasswordChange(t *testing.T) {
+	database, _, err := initDatabase(filepath.Join(t.TempDir(), "init.sqlite3"), "Admin", "")
+	if err != nil {
+		t.Fatalf("initDatabase: %v", err)
+	}
+	defer database.Close()
+
+	admin, err := store.GetUserByUsername(context.Background(), database, "Admin")
+	if err != nil || admin == nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if !admin.MustChangePassword {
+		t.Error("expected the generated admin to be flagged for a password change")
+	}
+}
+
+func TestInitDatabaseAdminPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "init.sqlite3")
+	if _, _, err := initDatabase(path, "Admin", "short"); err == nil {
+		t.Fatal("expected a too-short admin password to be rejected")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected no database after a rejected password, got %v", err)
+	}
+
+	database, password, err := initDatabase(path, "Admin", "correct horse battery")
+	if err != nil {
+		t.Fatalf("initDatabase: %v", err)
+	}
+	defer database.Close()
+	if password != "" {
+		t.Errorf("expected no generated password, got %q", password)
+	}
+
+	admin, err := store.GetUserByUsername(context.Background(), database, "Admin")
+	if err != nil || admin == nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte("correct horse battery")) != nil {
+		t.Error("expected the admin to have the given password")
+	}
+	if admin.MustChangePassword {
+		t.Error("expected a given admin password not to need changing")
+	}
+}
+
+func TestReadAdminPassword(t *testing.T) {
+	tests := []struct {
+		value, stdin, want string
+		wantErr            bool
+	}{
+		{"", "ignored\n", "", false},
+		{"secret-pass", "ignored\n", "secret-pass", false},
+		{"-", "from stdin\r\nsecond line\n", "from stdin", false},
+		{"-", "no newline", "no newline", false},
+		{"-", "", "", true},
+		{"-", "\n", "", true},
+	}
+	for _, tt := range tests {
+		got, err := readAdminPassword(tt.value, strings.NewReader(tt.stdin))
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("readAdminPassword(%q, %q) = %q, %v", tt.value, tt.stdin, got, err)
+		}
+	}
+}
+
+func TestUILocale(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user, _ := store.CreateUser(ctx, database, "ana", string(hash), model.RoleUser)
+	item, _ := store.CreateItem(ctx, database, "Tent", "", "")
+	store.UpdateItem(ctx, database, item.ID, "Tent", "", "", model.ItemStatusDamaged, "", nil)
+
+	webRouter, err := web.NewRouter(database, "test-secret", "", web.Options{})
+	if err != nil {
+		t.Fatalf("web.NewRouter: %v", err)
+	}
+	server := httptest.NewServer(webRouter)
+	t.Cleanup(server.Close)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.PostForm(server.URL+"/login", url.Values{"username": {"ana"}, "password": {"password"}})
+	if err != nil {
+		t.Fatalf("POST login: %v", err)
+	}
+	resp.Body.Close()
+	cookies := resp.Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected a session cookie, got %v", cookies)
+	}
+
+	itemsPage := func() string {
+		t.Helper()
+		req, _ := http.NewRequest("GET", server.URL+"/items", nil)
+		req.AddCookie(cookies[0])
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET items: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	if page := itemsPage(); !strings.Contains(page, "Poškodovan") || !strings.Contains(page, `lang="sl"`) {
+		t.Error("expected Slovenian status name by default")
+	}
+
+	if err := store.SetUserLocale(ctx, database, user.ID, model.LocaleEnglish); err != nil {
+		t.Fatalf("SetUserLocale: %v", err)
+	}
+	page := itemsPage()
+	if !strings.Contains(page, "Damaged") || strings.Contains(page, "Poškodovan") {
+		t.Error("expected English status name after switching locale")
+	}
+	if !strings.Contains(page, `lang="en"`) || !strings.Contains(page, ">Items<") {
+		t.Error("expected English page strings after switching locale")
+	}
+
+	// The settings form switches back.
+	req, _ := http.NewRequest("POST", server.URL+"/settings/language", strings.NewReader(url.Values{"locale": {"sl"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookies[0])
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("POST language: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "Jezik spremenjen.") {
+		t.Errorf("expected the confirmation in the new language, got status %d", resp.StatusCode)
+	}
+	if got, _ := store.GetUserLocale(ctx, database, user.ID); got != model.LocaleSlovenian {
+		t.Errorf("expected locale %q, got %q", model.LocaleSlovenian, got)
+	}
+}
+
+func TestForcedPasswordChangeAllowsSettings(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user, _ := store.CreateUser(ctx, database, "ana", string(hash), model.RoleUser)
+	store.SetMustChangePassword(ctx, database, user.ID, true)
+
+	webRouter, err := web.NewRouter(database, "test-secret", "", web.Options{})
+	if err != nil {
+		t.Fatalf("web.NewRouter: %v", err)
+	}
+	server := httptest.NewServer(webRouter)
+	t.Cleanup(server.Close)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.PostForm(server.URL+"/login", url.Values{"username": {"ana"}, "password": {"password"}})
+	if err != nil {
+		t.Fatalf("POST login: %v", err)
+	}
+	resp.Body.Close()
+	cookies := resp.Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected a session cookie, got %v", cookies)
+	}
+
+	do := func(method, path string, form url.Values) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookies[0])
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := do("GET", "/items", nil); resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/settings" {
+		t.Errorf("expected items to redirect to settings, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp := do("GET", "/settings", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the settings page, got %d", resp.StatusCode)
+	}
+	if resp := do("POST", "/settings/language", url.Values{"locale": {"en"}}); resp.StatusCode == http.StatusSeeOther {
+		t.Errorf("expected the language form to be usable, got a redirect to %s", resp.Header.Get("Location"))
+	}
+	if got, _ := store.GetUserLocale(ctx, database, user.ID); got != model.LocaleEnglish {
+		t.Errorf("expected locale %q, got %q", model.LocaleEnglish, got)
+	}
+}
+
+func TestWebFlash(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	store.CreateUser(ctx, database, "bor", string(hash), model.RoleManager)
+
+	webRouter, err := web.NewRouter(database, "test-secret", "", web.Options{})
+	if err != nil {
+		t.Fatalf("web.NewRouter: %v", err)
+	}
+	server := httptest.NewServer(webRouter)
+	t.Cleanup(server.Close)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.PostForm(server.URL+"/login", url.Values{"username": {"bor"}, "password": {"password"}})
+	if err != nil {
+		t.Fatalf("POST login: %v", err)
+	}
+	resp.Body.Close()
+	session := resp.Cookies()[0]
+
+	do := func(method, path string, form url.Values, cookies ...*http.Cookie) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range append(cookies, session) {
+			req.AddCookie(c)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+	flashCookie := func(resp *http.Response) *http.Cookie {
+		for _, c := range resp.Cookies() {
+			if c.Name == "flash" {
+				return c
+			}
+		}
+		return nil
+	}
+
+	resp, _ = do("POST", "/items", url.Values{"name": {"Drill"}})
+	flash := flashCookie(resp)
+	if resp.StatusCode != http.StatusSeeOther || flash == nil || flash.MaxAge <= 0 {
+		t.Fatalf("expected a redirect setting a flash, got %d %v", resp.StatusCode, resp.Cookies())
+	}
+
+	resp, page := do("GET", "/items", nil, flash)
+	if !strings.Contains(page, "Predmet dodan.") {
+		t.Error("expected the flash on the page after the redirect")
+	}
+	if c := flashCookie(resp); c == nil || c.MaxAge >= 0 {
+		t.Errorf("expected the shown flash to be cleared, got %v", c)
+	}
+
+	if _, page := do("GET", "/items", nil); strings.Contains(page, "Predmet dodan.") {
+		t.Error("expected the flash to be shown only once")
+	}
+	forged := &http.Cookie{Name: "flash", Value: "items.title"}
+	if _, page := do("GET", "/transfers", nil, forged); strings.Contains(page, "alert-success") {
+		t.Error("expected a flash that is not a flash message to be ignored")
+	}
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	cfg, err := loadTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("loading TLS config: %v", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 || len(cfg.Certificates) != 1 {
+		t.Errorf("expected TLS 1.2+ with one certificate, got min %x and %d", cfg.MinVersion, len(cfg.Certificates))
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = cfg
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // rejected handshakes
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(cfg.Certificates[0].Leaf)
+	for _, tc := range []struct {
+		max    uint16
+		wantOK bool
+	}{
+		{tls.VersionTLS13, true},
+		{tls.VersionTLS12, true},
+		{tls.VersionTLS11, false},
+	} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost", MaxVersion: tc.max}}}
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != tc.wantOK {
+			t.Errorf("max version %x: expected ok=%v, got %v", tc.max, tc.wantOK, err)
+		}
+	}
+
+	if _, err := loadTLSConfig(certFile, filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("expected an error for a missing key file")
+	}
+	if _, err := loadTLSConfig(keyFile, keyFile); err == nil {
+		t.Error("expected an error for a key given as the certificate")
+	}
+}
@@ -0,0 +1,161 @@
+// Package alerts runs the background low-stock scanner and delivers its
+// alerts.
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// EventLowStock is the event name sent for an item dropping below its
+// low-stock threshold.
+const EventLowStock = "low_stock"
+
+// Notifier delivers a low-stock alert.
+type Notifier interface {
+	NotifyLowStock(ctx context.Context, level model.StockLevel) error
+}
+
+// LogNotifier reports low-stock alerts in the server log.
+type LogNotifier struct{}
+
+// NotifyLowStock logs the alert as a warning.
+func (LogNotifier) NotifyLowStock(_ context.Context, level model.StockLevel) error {
+	slog.Warn("low stock", "item", level.ItemName, "item_id", level.ItemID,
+		"quantity", model.FormatQuantity(level.Quantity, level.Divisible),
+		"min_quantity", model.FormatQuantity(level.MinQuantity, level.Divisible))
+	return nil
+}
+
+// WebhookNotifier POSTs each alert as JSON to URL:
+// {"event": "low_stock", "item_id": 1, "item_name": "...", "quantity": 2, "min_quantity": 5}.
+// Quantities of divisible items are decimals, flagged with "divisible": true.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier returns a WebhookNotifier with a 10 second timeout.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// NotifyLowStock posts the alert. Any non-2xx response is an error.
+func (n *WebhookNotifier) NotifyLowStock(ctx context.Context, level model.StockLevel) error {
+	body, err := json.Marshal(struct {
+		Event       string      `json:"event"`
+		ItemID      int64       `json:"item_id"`
+		ItemName    string      `json:"item_name"`
+		Quantity    json.Number `json:"quantity"`
+		MinQuantity json.Number `json:"min_quantity"`
+		Divisible   bool        `json:"divisible,omitempty"`
+	}{
+		Event:       EventLowStock,
+		ItemID:      level.ItemID,
+		ItemName:    level.ItemName,
+		Quantity:    model.QuantityNumber(level.Quantity, level.Divisible),
+		MinQuantity: model.QuantityNumber(level.MinQuantity, level.Divisible),
+		Divisible:   level.Divisible,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Transitions compares current stock levels with their alert state. It
+// returns the levels that newly dropped below their threshold and the IDs of
+// alerted items that are no longer low (restocked, or threshold lowered or
+// removed). Items that stay low after being alerted are in neither list, so
+// they alert once per spell rather than on every scan.
+func Transitions(levels []model.StockLevel) (alert []model.StockLevel, recovered []int64) {
+	for _, l := range levels {
+		switch {
+		case l.Low() && !l.Alerted:
+			alert = append(alert, l)
+		case !l.Low() && l.Alerted:
+			recovered = append(recovered, l.ItemID)
+		}
+	}
+	return alert, recovered
+}
+
+// Scanner periodically checks stock levels and alerts on items that drop
+// below their low-stock threshold.
+type Scanner struct {
+	DB        *sql.DB
+	Interval  time.Duration
+	Notifiers []Notifier
+}
+
+// Run scans once immediately and then every Interval until ctx is done.
+func (s *Scanner) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Scan(ctx); err != nil {
+			slog.Error("low-stock scan failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan runs one pass. An alert is recorded as sent only when every notifier
+// delivered it, so a failed delivery is retried on the next scan.
+func (s *Scanner) Scan(ctx context.Context) error {
+	levels, err := store.ListStockLevels(ctx, s.DB)
+	if err != nil {
+		return err
+	}
+	alert, recovered := Transitions(levels)
+
+	for _, l := range alert {
+		delivered := true
+		for _, n := range s.Notifiers {
+			if err := n.NotifyLowStock(ctx, l); err != nil {
+				slog.Error("failed to deliver low-stock alert", "item", l.ItemName, "error", err)
+				delivered = false
+			}
+		}
+		if !delivered {
+			continue
+		}
+		if err := store.SetLowStockAlerted(ctx, s.DB, l.ItemID, true); err != nil {
+			return err
+		}
+	}
+	for _, id := range recovered {
+		if err := store.SetLowStockAlerted(ctx, s.DB, id, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
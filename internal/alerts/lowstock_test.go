@@ -0,0 +1,130 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+func TestTransitions(t *testing.T) {
+	levels := []model.StockLevel{
+		{ItemID: 1, Quantity: 2, MinQuantity: 5},                // newly low
+		{ItemID: 2, Quantity: 2, MinQuantity: 5, Alerted: true}, // still low, already alerted
+		{ItemID: 3, Quantity: 5, MinQuantity: 5, Alerted: true}, // restocked to the threshold
+		{ItemID: 4, Quantity: 9, MinQuantity: 5},                // fine
+		{ItemID: 5, Quantity: 0, MinQuantity: 0, Alerted: true}, // threshold removed
+		{ItemID: 6, Quantity: 0, MinQuantity: 1},                // newly empty
+	}
+
+	alert, recovered := Transitions(levels)
+	var alerted []int64
+	for _, l := range alert {
+		alerted = append(alerted, l.ItemID)
+	}
+	if !slices.Equal(alerted, []int64{1, 6}) {
+		t.Errorf("expected alerts for items 1 and 6, got %v", alerted)
+	}
+	if !slices.Equal(recovered, []int64{3, 5}) {
+		t.Errorf("expected items 3 and 5 recovered, got %v", recovered)
+	}
+}
+
+// recorder is a Notifier that records the alerted item IDs and fails while
+// err is set.
+type recorder struct {
+	ids []int64
+	err error
+}
+
+func (r *recorder) NotifyLowStock(_ context.Context, level model.StockLevel) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.ids = append(r.ids, level.ItemID)
+	return nil
+}
+
+func TestScanAlertsOncePerSpell(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := store.CreateItem(ctx, database, "Batteries", "", "")
+	shelf, _ := store.CreateOwner(ctx, database, "Shelf", model.OwnerTypeLocation)
+	alice, _ := store.CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	store.AddStock(ctx, database, item.ID, shelf.ID, 10, false, nil)
+	threshold := 5
+	store.SetItemMinQuantity(ctx, database, item.ID, &threshold)
+
+	rec := &recorder{}
+	scanner := &Scanner{DB: database, Notifiers: []Notifier{rec}}
+	scan := func() {
+		t.Helper()
+		if err := scanner.Scan(ctx); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+	}
+
+	scan()
+	if len(rec.ids) != 0 {
+		t.Fatalf("expected no alert above the threshold, got %v", rec.ids)
+	}
+
+	// Quantity held by people still counts towards the total.
+	store.CreateTransfer(ctx, database, item.ID, shelf.ID, alice.ID, 4, false, "", nil)
+	scan()
+	if len(rec.ids) != 0 {
+		t.Fatalf("expected no alert while the total is 10, got %v", rec.ids)
+	}
+
+	store.AdjustInventory(ctx, database, item.ID, shelf.ID, -6, false, "", nil)
+	rec.err = errors.New("unreachable")
+	scan()
+	rec.err = nil
+	scan()
+	scan()
+	if !slices.Equal(rec.ids, []int64{item.ID}) {
+		t.Fatalf("expected one alert after a failed delivery, got %v", rec.ids)
+	}
+
+	store.AddStock(ctx, database, item.ID, shelf.ID, 5, false, nil)
+	scan()
+	store.AdjustInventory(ctx, database, item.ID, shelf.ID, -5, false, "", nil)
+	scan()
+	if !slices.Equal(rec.ids, []int64{item.ID, item.ID}) {
+		t.Errorf("expected a second alert after restocking and dropping again, got %v", rec.ids)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	level := model.StockLevel{ItemID: 7, ItemName: "Tape", Quantity: 1, MinQuantity: 3}
+	if err := NewWebhookNotifier(server.URL).NotifyLowStock(context.Background(), level); err != nil {
+		t.Fatalf("NotifyLowStock: %v", err)
+	}
+	if got["event"] != EventLowStock || got["item_id"] != float64(7) || got["item_name"] != "Tape" ||
+		got["quantity"] != float64(1) || got["min_quantity"] != float64(3) {
+		t.Errorf("unexpected payload: %v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+	if err := NewWebhookNotifier(failing.URL).NotifyLowStock(context.Background(), level); err == nil {
+		t.Error("expected error for a 500 response")
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// ActivityHandler handles the global recent-activity feed.
+type ActivityHandler struct {
+	Store store.Store
+
+	// MaxPageSize caps ?limit.
+	MaxPageSize int
+}
+
+// List handles GET /api/activity?limit=&before=.
+// Pages by timestamp: pass next_before from one response as before in the
+// next request. next_before is null once the feed is exhausted.
+func (h *ActivityHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit, _, err := parsePagination(w, r, 50, h.MaxPageSize)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	before := time.Now().UTC().Add(time.Second)
+	if v := r.URL.Query().Get("before"); v != "" {
+		before, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid before: expected RFC 3339")
+			return
+		}
+	}
+
+	events, err := h.Store.ListActivity(r.Context(), before, limit)
+	if err != nil {
+		slog.Error("failed to list activity", "error", err)
+		storeError(w, err, "failed to list activity")
+		return
+	}
+	if events == nil {
+		events = []model.ActivityEvent{}
+	}
+
+	var nextBefore *time.Time
+	if len(events) >= limit {
+		nextBefore = &events[len(events)-1].At
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{
+		"events":      events,
+		"limit":       limit,
+		"next_before": nextBefore,
+	})
+}
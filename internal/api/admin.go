@@ -0,0 +1,257 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/auth"
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/reprocess"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// AdminHandler handles server administration endpoints (admin only).
+type AdminHandler struct {
+	Store     store.Store
+	JWTSecret string
+	ReadOnly  *ReadOnlyMode
+	Config    map[string]string
+	ImageJob  *reprocess.Job
+}
+
+type readOnlyRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type readOnlyResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+type optimizeRequest struct {
+	Vacuum bool `json:"vacuum"`
+}
+
+type optimizeResponse struct {
+	Before *model.DBStats `json:"before"`
+	After  *model.DBStats `json:"after"`
+}
+
+type impersonateResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// GetReadOnly handles GET /api/admin/readonly.
+func (h *AdminHandler) GetReadOnly(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, readOnlyResponse{Enabled: h.ReadOnly.Enabled()})
+}
+
+// GetConfig handles GET /api/admin/config. It returns the settings the
+// server was started with, secrets redacted; read-only mode may have been
+// toggled since, see GetReadOnly.
+func (h *AdminHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	config := h.Config
+	if config == nil {
+		config = map[string]string{}
+	}
+	jsonResponse(w, http.StatusOK, config)
+}
+
+// SetReadOnly handles POST /api/admin/readonly.
+func (h *AdminHandler) SetReadOnly(w http.ResponseWriter, r *http.Request) {
+	var req readOnlyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	h.ReadOnly.Set(req.Enabled)
+
+	claims := GetClaims(r.Context())
+	slog.Info("read-only mode changed", "user", claims.Username, "enabled", req.Enabled)
+	jsonResponse(w, http.StatusOK, readOnlyResponse{Enabled: req.Enabled})
+}
+
+// Impersonate handles POST /api/admin/impersonate/{id}.
+// Issues a short-lived token for the target user, marked with the admin's
+// username so every action taken with it can be traced back.
+func (h *AdminHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	if claims.ImpersonatedBy != "" {
+		jsonError(w, http.StatusForbidden, "cannot impersonate while impersonating")
+		return
+	}
+	if claims.UserID == id {
+		jsonError(w, http.StatusBadRequest, "cannot impersonate yourself")
+		return
+	}
+
+	target, err := h.Store.GetUser(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get user", "error", err)
+		storeError(w, err, "failed to get user")
+		return
+	}
+	if target == nil || target.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if target.Role == model.RoleAdmin {
+		jsonError(w, http.StatusForbidden, "cannot impersonate another admin")
+		return
+	}
+
+	token, err := auth.GenerateImpersonationToken(h.JWTSecret, target.ID, target.Username, target.Role, claims.Username)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+
+	slog.Warn("impersonation started", "user", claims.Username, "target_user", target.Username, "expires_in", auth.ImpersonationExpiry.String())
+	jsonResponse(w, http.StatusOK, impersonateResponse{
+		Token:     token,
+		ExpiresAt: time.Now().Add(auth.ImpersonationExpiry).UTC().Truncate(time.Second),
+	})
+}
+
+// DBStats handles GET /api/admin/db-stats.
+func (h *AdminHandler) DBStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.Store.GetDBStats(r.Context())
+	if err != nil {
+		slog.Error("failed to get database stats", "error", err)
+		storeError(w, err, "failed to get database stats")
+		return
+	}
+	jsonResponse(w, http.StatusOK, stats)
+}
+
+// Migrations handles GET /api/admin/migrations. It reports the schema
+// version, pending migrations, and which applied migrations were edited since.
+func (h *AdminHandler) Migrations(w http.ResponseWriter, r *http.Request) {
+	status, err := h.Store.GetMigrationStatus(r.Context())
+	if err != nil {
+		slog.Error("failed to get migration status", "error", err)
+		storeError(w, err, "failed to get migration status")
+		return
+	}
+	jsonResponse(w, http.StatusOK, status)
+}
+
+// Logins handles GET /api/admin/logins?user_id=&from=&to=&limit=. It returns
+// recent login successes and failures, newest first, and the addresses with
+// the most failed logins in the range. from and to take the same forms as in
+// /api/stats.
+func (h *AdminHandler) Logins(w http.ResponseWriter, r *http.Request) {
+	limit, _, err := parsePagination(w, r, 100, 0)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	var filter model.LoginFilter
+	if filter.From, err = parseStatsTime(q.Get("from"), false); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	if filter.To, err = parseStatsTime(q.Get("to"), true); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	if v := q.Get("user_id"); v != "" {
+		filter.UserID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || filter.UserID <= 0 {
+			jsonError(w, http.StatusBadRequest, "invalid user_id")
+			return
+		}
+	}
+
+	activity, err := h.Store.GetLoginActivity(r.Context(), filter, limit)
+	if err != nil {
+		slog.Error("failed to get login activity", "error", err)
+		storeError(w, err, "failed to get login activity")
+		return
+	}
+	jsonResponse(w, http.StatusOK, activity)
+}
+
+// Optimize handles POST /api/admin/optimize.
+// With vacuum, the database is locked until VACUUM finishes, so every other
+// request blocks (and may time out) meanwhile.
+func (h *AdminHandler) Optimize(w http.ResponseWriter, r *http.Request) {
+	var req optimizeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	before, err := h.Store.GetDBStats(r.Context())
+	if err != nil {
+		slog.Error("failed to get database stats", "error", err)
+		storeError(w, err, "failed to get database stats")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	if req.Vacuum {
+		slog.Warn("running VACUUM, database is locked until it finishes", "user", claims.Username)
+	}
+	if err := h.Store.Optimize(r.Context(), req.Vacuum); err != nil {
+		slog.Error("failed to optimize database", "error", err)
+		storeError(w, err, "failed to optimize database")
+		return
+	}
+
+	after, err := h.Store.GetDBStats(r.Context())
+	if err != nil {
+		slog.Error("failed to get database stats", "error", err)
+		storeError(w, err, "failed to get database stats")
+		return
+	}
+
+	slog.Info("database optimized", "user", claims.Username, "vacuum", req.Vacuum,
+		"pages_before", before.PageCount, "pages_after", after.PageCount)
+	jsonResponse(w, http.StatusOK, optimizeResponse{Before: before, After: after})
+}
+
+// ReprocessImagesStatus handles GET /api/admin/reprocess-images. It reports
+// the progress of the running image reprocessing job, or the result of the
+// last one.
+func (h *AdminHandler) ReprocessImagesStatus(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, h.ImageJob.Status())
+}
+
+// StartReprocessImages handles POST /api/admin/reprocess-images. The job runs
+// in the background, since it can take much longer than a request may; poll
+// ReprocessImagesStatus for progress.
+func (h *AdminHandler) StartReprocessImages(w http.ResponseWriter, r *http.Request) {
+	if !h.ImageJob.Start() {
+		jsonError(w, http.StatusConflict, "image reprocessing is already running")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("image reprocessing requested", "user", claims.Username)
+	jsonResponse(w, http.StatusAccepted, h.ImageJob.Status())
+}
+
+// CancelReprocessImages handles DELETE /api/admin/reprocess-images. Images
+// already reprocessed keep their new version.
+func (h *AdminHandler) CancelReprocessImages(w http.ResponseWriter, r *http.Request) {
+	if !h.ImageJob.Cancel() {
+		jsonError(w, http.StatusConflict, "image reprocessing is not running")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("image reprocessing canceled", "user", claims.Username)
+	jsonResponse(w, http.StatusOK, h.ImageJob.Status())
+}
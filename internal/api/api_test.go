@@ -2,17 +2,33 @@ package api
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"maps"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/erazemk/skladisce/internal/auth"
 	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/imaging"
 	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/reprocess"
 	"github.com/erazemk/skladisce/internal/store"
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,13 +37,13 @@ const testJWTSecret = "test-secret"
 func setupTestServer(t *testing.T) (*httptest.Server, string) {
 	t.Helper()
 	database := db.NewTestDB(t)
-	router := NewRouter(database, testJWTSecret)
+	router := NewRouter(database, testJWTSecret, Options{})
 	server := httptest.NewServer(router)
 	t.Cleanup(server.Close)
 
 	// Create admin user.
 	ctx := context.Background()
-	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
 	store.CreateUser(ctx, database, "admin", string(hash), model.RoleAdmin)
 
 	// Get token.
@@ -135,7 +151,7 @@ func TestItemsAPIFlow(t *testing.T) {
 
 func TestUnauthenticatedAccess(t *testing.T) {
 	database := db.NewTestDB(t)
-	router := NewRouter(database, testJWTSecret)
+	router := NewRouter(database, testJWTSecret, Options{})
 	server := httptest.NewServer(router)
 	t.Cleanup(server.Close)
 
@@ -148,13 +164,13 @@ func TestUnauthenticatedAccess(t *testing.T) {
 
 func TestRoleBasedAccess(t *testing.T) {
 	database := db.NewTestDB(t)
-	router := NewRouter(database, testJWTSecret)
+	router := NewRouter(database, testJWTSecret, Options{})
 	server := httptest.NewServer(router)
 	t.Cleanup(server.Close)
 
 	// Create a regular user.
 	ctx := context.Background()
-	hash, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.DefaultCost)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.MinCost)
 	store.CreateUser(ctx, database, "user1", string(hash), model.RoleUser)
 
 	userToken, _ := auth.GenerateToken(testJWTSecret, 1, "user1", model.RoleUser)
@@ -269,6 +285,85 @@ func TestLogoutRevokesToken(t *testing.T) {
 	resp.Body.Close()
 }
 
+func TestIntrospectToken(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	introspect := func(tok string) map[string]any {
+		t.Helper()
+		req, _ := authRequest("POST", server.URL+"/api/auth/introspect", token, map[string]string{"token": tok})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("introspect request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 for introspect, got %d", resp.StatusCode)
+		}
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body
+	}
+
+	// Active token.
+	other, _ := auth.GenerateToken(testJWTSecret, 7, "alice", model.RoleUser)
+	body := introspect(other)
+	if body["active"] != true || body["username"] != "alice" || body["role"] != model.RoleUser || body["user_id"] != float64(7) {
+		t.Errorf("expected active token for alice, got %v", body)
+	}
+	if exp, _ := body["exp"].(float64); exp <= float64(time.Now().Unix()) {
+		t.Errorf("expected exp in the future, got %v", body["exp"])
+	}
+
+	// Expired token.
+	past := time.Now().Add(-time.Hour)
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		UserID:   7,
+		Username: "alice",
+		Role:     model.RoleUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "expired",
+			IssuedAt:  jwt.NewNumericDate(past.Add(-time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(past),
+		},
+	}).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("signing expired token: %v", err)
+	}
+	body = introspect(expired)
+	if body["active"] != false || len(body) != 1 {
+		t.Errorf("expected only active=false for expired token, got %v", body)
+	}
+
+	// Revoked token.
+	req, _ := authRequest("POST", server.URL+"/api/auth/logout", other, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	body = introspect(other)
+	if body["active"] != false || len(body) != 1 {
+		t.Errorf("expected only active=false for revoked token, got %v", body)
+	}
+
+	// Garbage is inactive, a missing token is 400.
+	if body = introspect("not-a-token"); body["active"] != false {
+		t.Errorf("expected inactive for garbage token, got %v", body)
+	}
+	req, _ = authRequest("POST", server.URL+"/api/auth/introspect", token, map[string]string{})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without token, got %d", resp.StatusCode)
+	}
+
+	// Only admins may introspect.
+	userToken, _ := auth.GenerateToken(testJWTSecret, 8, "bob", model.RoleManager)
+	req, _ = authRequest("POST", server.URL+"/api/auth/introspect", userToken, map[string]string{"token": token})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for manager, got %d", resp.StatusCode)
+	}
+}
+
 func TestPasswordMinLength(t *testing.T) {
 	server, token := setupTestServer(t)
 
@@ -296,3 +391,4408 @@ func TestPasswordMinLength(t *testing.T) {
 	}
 	resp.Body.Close()
 }
+
+func TestTransferInsufficientQuantityResponse(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Widget"})
+	resp, _ := http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+
+	var owners [2]model.Owner
+	for i, name := range []string{"Storage", "Alice"} {
+		req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": name, "type": model.OwnerTypeLocation})
+		resp, _ = http.DefaultClient.Do(req)
+		json.NewDecoder(resp.Body).Decode(&owners[i])
+		resp.Body.Close()
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/inventory/stock", token, map[string]any{
+		"item_id": item.ID, "owner_id": owners[0].ID, "quantity": 7,
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	req, _ = authRequest("POST", server.URL+"/api/transfers", token, map[string]any{
+		"item_id": item.ID, "from_owner_id": owners[0].ID, "to_owner_id": owners[1].ID, "quantity": 10,
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+
+	if body["code"] != "insufficient_quantity" {
+		t.Errorf("expected code 'insufficient_quantity', got %v", body["code"])
+	}
+	if body["available"] != float64(7) || body["requested"] != float64(10) {
+		t.Errorf("expected available 7 and requested 10, got %v and %v", body["available"], body["requested"])
+	}
+}
+
+func TestTransferWarnings(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Projector"})
+	resp, _ := http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+
+	var storage, alice model.Owner
+	for _, o := range []struct {
+		owner      *model.Owner
+		name, kind string
+	}{{&storage, "Storage", model.OwnerTypeLocation}, {&alice, "Alice", model.OwnerTypePerson}} {
+		req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": o.name, "type": o.kind})
+		resp, _ = http.DefaultClient.Do(req)
+		json.NewDecoder(resp.Body).Decode(o.owner)
+		resp.Body.Close()
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/inventory/stock", token, map[string]any{
+		"item_id": item.ID, "owner_id": storage.ID, "quantity": 2,
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	transfer := func(from, to int64) []string {
+		t.Helper()
+		req, _ := authRequest("POST", server.URL+"/api/transfers", token, map[string]any{
+			"item_id": item.ID, "from_owner_id": from, "to_owner_id": to, "quantity": 1,
+		})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", resp.StatusCode)
+		}
+		var body struct {
+			Warnings []string `json:"warnings"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body.Warnings
+	}
+
+	if warnings := transfer(storage.ID, alice.ID); len(warnings) != 0 {
+		t.Errorf("expected no warnings while storage still has one, got %v", warnings)
+	}
+	if warnings := transfer(storage.ID, alice.ID); !slices.Equal(warnings, []string{warnNoLocationStock}) {
+		t.Errorf("expected a warning when the last unit leaves all locations, got %v", warnings)
+	}
+	if warnings := transfer(alice.ID, storage.ID); len(warnings) != 0 {
+		t.Errorf("expected no warnings for a return, got %v", warnings)
+	}
+}
+
+func TestOwnersSearchAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	for _, o := range []map[string]string{
+		{"name": "Storage Room", "type": model.OwnerTypeLocation},
+		{"name": "Roman", "type": model.OwnerTypePerson},
+		{"name": "Alice", "type": model.OwnerTypePerson},
+	} {
+		req, _ := authRequest("POST", server.URL+"/api/owners", token, o)
+		resp, _ := http.DefaultClient.Do(req)
+		resp.Body.Close()
+	}
+
+	req, _ := authRequest("GET", server.URL+"/api/owners?q=ro&type=person", token, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var owners []model.Owner
+	json.NewDecoder(resp.Body).Decode(&owners)
+	resp.Body.Close()
+	if len(owners) != 1 || owners[0].Name != "Roman" {
+		t.Errorf("expected only 'Roman', got %v", owners)
+	}
+}
+
+func TestDecodeJSONRejectsEmptyBodyAndWrongContentType(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	// Empty body with a JSON content type.
+	req, _ := authRequest("POST", server.URL+"/api/items", token, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for empty body, got %d", resp.StatusCode)
+	}
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if body["error"] != "empty request body" {
+		t.Errorf("expected 'empty request body' error, got %q", body["error"])
+	}
+
+	// Valid JSON sent with a non-JSON content type.
+	req, _ = authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Laptop"})
+	req.Header.Set("Content-Type", "text/plain")
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for text/plain, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	// Content type parameters are accepted.
+	req, _ = authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Laptop"})
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201 with charset parameter, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+// statsStore is a Store whose GetStats is canned; any other method panics.
+type statsStore struct {
+	store.Store
+	stats    *model.Stats
+	err      error
+	from, to time.Time
+}
+
+func (s *statsStore) GetStats(ctx context.Context, from, to time.Time) (*model.Stats, error) {
+	s.from, s.to = from, to
+	return s.stats, s.err
+}
+
+func TestStatsWithFakeStore(t *testing.T) {
+	fake := &statsStore{stats: &model.Stats{Items: 3, People: 2, Locations: 1, TotalQuantity: 12}}
+	h := &StatsHandler{Store: fake}
+
+	rec := httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest("GET", "/api/stats?from=2024-03-01&to=2024-03-31", nil))
+	var stats model.Stats
+	json.NewDecoder(rec.Body).Decode(&stats)
+	if rec.Code != http.StatusOK || stats.Items != 3 || stats.TotalQuantity != 12 {
+		t.Fatalf("expected the store's stats, got %d %+v", rec.Code, stats)
+	}
+	wantFrom := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	wantTo := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	if !fake.from.Equal(wantFrom) || !fake.to.Equal(wantTo) {
+		t.Errorf("expected range [%v, %v), got [%v, %v)", wantFrom, wantTo, fake.from, fake.to)
+	}
+
+	fake.err = fmt.Errorf("getting stats: %w", store.ErrBusy)
+	rec = httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest("GET", "/api/stats", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After for a busy store, got %d", rec.Code)
+	}
+}
+
+// statusStore is a Store whose CreateStatus fails with err.
+type statusStore struct {
+	store.Store
+	err error
+}
+
+func (s *statusStore) CreateStatus(ctx context.Context, name string) (*model.ItemStatus, error) {
+	return nil, s.err
+}
+
+func TestStoreValidationErrors(t *testing.T) {
+	fake := &statusStore{}
+	h := &StatusesHandler{Store: fake}
+	for _, tc := range []struct {
+		err  error
+		code int
+	}{
+		{&store.ValidationError{Err: errors.New("status already exists")}, http.StatusConflict},
+		{fmt.Errorf("creating status: %w", &store.ValidationError{Err: errors.New("status already exists")}), http.StatusConflict},
+		// A bare driver error is not the caller's fault, wrapped or not.
+		{errors.New("disk I/O error"), http.StatusInternalServerError},
+		{fmt.Errorf("creating status: %w", errors.New("disk I/O error")), http.StatusInternalServerError},
+	} {
+		fake.err = tc.err
+		rec := httptest.NewRecorder()
+		h.Create(rec, httptest.NewRequest("POST", "/api/statuses", strings.NewReader(`{"name": "lent"}`)))
+		if rec.Code != tc.code {
+			t.Errorf("%v: expected %d, got %d", tc.err, tc.code, rec.Code)
+		}
+	}
+}
+
+func TestStoreErrorBusy(t *testing.T) {
+	rec := httptest.NewRecorder()
+	storeError(rec, fmt.Errorf("creating owner: %w", store.ErrBusy), "failed to create owner")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected 503 with Retry-After for a busy database, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	rec = httptest.NewRecorder()
+	storeError(rec, fmt.Errorf("creating owner: disk I/O error"), "failed to create owner")
+	var body map[string]string
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusInternalServerError || body["error"] != "failed to create owner" || rec.Header().Get("Retry-After") != "" {
+		t.Errorf("expected a plain 500 for other errors, got %d %v", rec.Code, body)
+	}
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	const limit = 2
+	started := make(chan struct{}, limit)
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(MaxConcurrentRequests(limit)(blocking))
+	t.Cleanup(server.Close)
+
+	// Occupy every slot.
+	var wg sync.WaitGroup
+	for range limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Errorf("request: %v", err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("expected 200 for request within limit, got %d", resp.StatusCode)
+			}
+		}()
+	}
+	for range limit {
+		<-started
+	}
+
+	// The next request exceeds the limit.
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 beyond the limit, got %d", resp.StatusCode)
+	}
+
+	close(release)
+	wg.Wait()
+
+	// Slots are freed once in-flight requests finish.
+	go func() { <-started }()
+	resp, err = http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 after slots were released, got %d", resp.StatusCode)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		var item *model.Item
+		_ = item.Name // nil pointer dereference
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(RecoverPanics(mux))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/panic")
+	if err != nil {
+		t.Fatalf("GET /panic: %v", err)
+	}
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || body["error"] != "internal server error" {
+		t.Errorf("expected 500 internal server error, got %d %v", resp.StatusCode, body)
+	}
+
+	resp, err = http.Get(server.URL + "/ok")
+	if err != nil {
+		t.Fatalf("GET /ok after panic: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the server to keep serving, got %d", resp.StatusCode)
+	}
+}
+
+func TestReportLimiter(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+	ana, _ := store.CreateUser(ctx, database, "ana", "hash", model.RoleAdmin)
+	bor, _ := store.CreateUser(ctx, database, "bor", "hash", model.RoleAdmin)
+	anaToken, _ := auth.GenerateToken(testJWTSecret, ana.ID, ana.Username, ana.Role)
+	borToken, _ := auth.GenerateToken(testJWTSecret, bor.ID, bor.Username, bor.Role)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	report := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("block") != "" {
+			started <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	limiter := NewReportLimiter(time.Hour)
+	server := httptest.NewServer(AuthMiddleware(testJWTSecret, store.New(database))(limiter.Middleware(report)))
+	t.Cleanup(server.Close)
+
+	get := func(token, query string) *http.Response {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"?"+query, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	done := make(chan int)
+	go func() {
+		req, _ := authRequest("GET", server.URL+"?block=1", anaToken, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("request: %v", err)
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	<-started
+
+	// A second report for the same user is rejected while the first runs.
+	if resp := get(anaToken, ""); resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After for a concurrent report, got %d", resp.StatusCode)
+	}
+	// Other users are not affected.
+	if resp := get(borToken, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for another user, got %d", resp.StatusCode)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected the first report to succeed, got %d", code)
+	}
+	// The finished report starts the cooldown.
+	if resp := get(anaToken, ""); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429 during the cooldown, got %d", resp.StatusCode)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(MaxBodySize(64)(NewRouter(database, testJWTSecret, Options{})))
+	t.Cleanup(server.Close)
+
+	big := `{"username": "` + strings.Repeat("a", 100) + `", "password": "x"}`
+
+	// Declared Content-Length over the limit is rejected up front.
+	resp, err := http.Post(server.URL+"/api/auth/login", "application/json", strings.NewReader(big))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for oversized body, got %d", resp.StatusCode)
+	}
+
+	// Streamed bodies without a Content-Length are cut off while decoding.
+	req, _ := http.NewRequest("POST", server.URL+"/api/auth/login", io.NopCloser(strings.NewReader(big)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for oversized streamed body, got %d", resp.StatusCode)
+	}
+}
+
+func TestItemStatusHistoryAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Drill"})
+	resp, _ := http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+
+	req, _ = authRequest("PUT", fmt.Sprintf("%s/api/items/%d", server.URL, item.ID), token, map[string]string{
+		"name": "Drill", "status": model.ItemStatusDamaged, "reason": "dropped",
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 updating item, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/items/%d/status-history", server.URL, item.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var history []model.StatusChange
+	json.NewDecoder(resp.Body).Decode(&history)
+	resp.Body.Close()
+
+	if len(history) != 1 {
+		t.Fatalf("expected 1 status change, got %d", len(history))
+	}
+	if history[0].ToStatus != model.ItemStatusDamaged || history[0].Reason != "dropped" || history[0].Username != "admin" {
+		t.Errorf("unexpected status change: %+v", history[0])
+	}
+
+	// Updating a missing item returns 404.
+	req, _ = authRequest("PUT", server.URL+"/api/items/999", token, map[string]string{"name": "Ghost"})
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 updating missing item, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	database := db.NewTestDB(t)
+	mode := NewReadOnlyMode(true)
+	server := httptest.NewServer(mode.Middleware(NewRouter(database, testJWTSecret, Options{ReadOnly: mode})))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	admin, _ := store.CreateUser(ctx, database, "admin", string(hash), model.RoleAdmin)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+
+	// Writes are rejected.
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Laptop"})
+	resp, _ := http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for POST in read-only mode, got %d", resp.StatusCode)
+	}
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if body["error"] != "service in read-only mode" {
+		t.Errorf("expected read-only error message, got %q", body["error"])
+	}
+
+	// Reads still work.
+	req, _ = authRequest("GET", server.URL+"/api/items", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for GET in read-only mode, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	// Login still works.
+	loginBody, _ := json.Marshal(map[string]string{"username": "admin", "password": "password"})
+	resp, _ = http.Post(server.URL+"/api/auth/login", "application/json", bytes.NewReader(loginBody))
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for login in read-only mode, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	// So do the POST checks that never write.
+	for path, body := range map[string]map[string]string{
+		"/api/auth/verify-password": {"password": "password"},
+		"/api/auth/introspect":      {"token": token},
+	} {
+		req, _ = authRequest("POST", server.URL+path, token, body)
+		resp, _ = http.DefaultClient.Do(req)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected 200 for %s in read-only mode, got %d", path, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+
+	// Admin can turn it off, after which writes succeed.
+	req, _ = authRequest("POST", server.URL+"/api/admin/readonly", token, map[string]bool{"enabled": false})
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 disabling read-only mode, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	req, _ = authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Laptop"})
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201 after disabling read-only mode, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestInventoryChangesAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	since := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Laptop"})
+	resp, _ := http.DefaultClient.Do(req)
+	var item map[string]any
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+	itemID := int64(item["id"].(float64))
+
+	req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": "Storage", "type": "location"})
+	resp, _ = http.DefaultClient.Do(req)
+	var owner map[string]any
+	json.NewDecoder(resp.Body).Decode(&owner)
+	resp.Body.Close()
+	ownerID := int64(owner["id"].(float64))
+
+	req, _ = authRequest("POST", server.URL+"/api/inventory/stock", token, map[string]any{"item_id": itemID, "owner_id": ownerID, "quantity": 3})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	req, _ = authRequest("GET", server.URL+"/api/inventory/changes?since="+since, token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		AsOf    time.Time `json:"as_of"`
+		Changes []struct {
+			ItemID   int64 `json:"item_id"`
+			OwnerID  int64 `json:"owner_id"`
+			Quantity int   `json:"quantity"`
+			Deleted  bool  `json:"deleted"`
+		} `json:"changes"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if body.AsOf.IsZero() {
+		t.Error("expected as_of to be set")
+	}
+	if len(body.Changes) != 1 || body.Changes[0].Quantity != 3 || body.Changes[0].Deleted {
+		t.Errorf("expected one change with quantity 3, got %+v", body.Changes)
+	}
+
+	for _, bad := range []string{"", "?since=yesterday"} {
+		req, _ = authRequest("GET", server.URL+"/api/inventory/changes"+bad, token, nil)
+		resp, _ = http.DefaultClient.Do(req)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %q, got %d", bad, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestAdminImpersonation(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/users", token, map[string]string{
+		"username": "alice", "password": "password123", "role": model.RoleUser,
+	})
+	resp, _ := http.DefaultClient.Do(req)
+	var alice model.User
+	json.NewDecoder(resp.Body).Decode(&alice)
+	resp.Body.Close()
+
+	req, _ = authRequest("POST", fmt.Sprintf("%s/api/admin/impersonate/%d", server.URL, alice.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var imp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	json.NewDecoder(resp.Body).Decode(&imp)
+	resp.Body.Close()
+
+	claims, err := auth.ValidateToken(testJWTSecret, imp.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.UserID != alice.ID || claims.Role != model.RoleUser {
+		t.Errorf("expected claims for alice, got %+v", claims)
+	}
+	if claims.ImpersonatedBy != "admin" {
+		t.Errorf("expected impersonated_by 'admin', got %q", claims.ImpersonatedBy)
+	}
+	if claims.ExpiresAt.Time.After(time.Now().Add(auth.ImpersonationExpiry + time.Minute)) {
+		t.Errorf("expected short-lived token, expires at %v", claims.ExpiresAt.Time)
+	}
+
+	// Actions taken with the token are attributed to the target user.
+	req, _ = authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Widget"})
+	resp, _ = http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+
+	var owners [2]model.Owner
+	for i, name := range []string{"Storage", "Office"} {
+		req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": name, "type": model.OwnerTypeLocation})
+		resp, _ = http.DefaultClient.Do(req)
+		json.NewDecoder(resp.Body).Decode(&owners[i])
+		resp.Body.Close()
+	}
+	req, _ = authRequest("POST", server.URL+"/api/inventory/stock", token, map[string]any{
+		"item_id": item.ID, "owner_id": owners[0].ID, "quantity": 2,
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	req, _ = authRequest("POST", server.URL+"/api/transfers", imp.Token, map[string]any{
+		"item_id": item.ID, "from_owner_id": owners[0].ID, "to_owner_id": owners[1].ID, "quantity": 1,
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 for impersonated transfer, got %d", resp.StatusCode)
+	}
+	var transfer model.Transfer
+	json.NewDecoder(resp.Body).Decode(&transfer)
+	resp.Body.Close()
+	if transfer.TransferredBy == nil || *transfer.TransferredBy != alice.ID {
+		t.Errorf("expected transfer attributed to alice (%d), got %v", alice.ID, transfer.TransferredBy)
+	}
+
+	// The impersonation token cannot change the password.
+	req, _ = authRequest("PUT", server.URL+"/api/auth/password", imp.Token, map[string]string{
+		"current_password": "password123", "new_password": "newpassword123",
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 changing password while impersonating, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	// Admins cannot impersonate themselves; non-admins cannot impersonate.
+	req, _ = authRequest("POST", server.URL+"/api/admin/impersonate/1", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 impersonating self, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	req, _ = authRequest("POST", server.URL+"/api/admin/impersonate/1", imp.Token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for non-admin, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestDeletedItemImageNotFound(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	adminToken, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+	user, _ := store.CreateUser(ctx, database, "user1", "hash", model.RoleUser)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+
+	item, _ := store.CreateItem(ctx, database, "Photo Item", "", "")
+	store.SetItemImage(ctx, database, item.ID, []byte("fake image data"), "image/png")
+	imageURL := fmt.Sprintf("%s/api/items/%d/image", server.URL, item.ID)
+
+	req, _ := authRequest("GET", imageURL, userToken, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 before delete, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	store.DeleteItem(ctx, database, item.ID, nil, "")
+
+	req, _ = authRequest("GET", imageURL, userToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for deleted item image, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	req, _ = authRequest("GET", imageURL+"?include_deleted=true", userToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for include_deleted as user, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	req, _ = authRequest("GET", imageURL+"?include_deleted=true", adminToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for include_deleted as admin, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestItemChangelogAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Laptop"})
+	resp, _ := http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+
+	for _, status := range []string{model.ItemStatusDamaged, model.ItemStatusActive} {
+		req, _ = authRequest("PUT", fmt.Sprintf("%s/api/items/%d", server.URL, item.ID), token, map[string]string{
+			"name": "Laptop", "status": status,
+		})
+		resp, _ = http.DefaultClient.Do(req)
+		resp.Body.Close()
+	}
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/items/%d/changelog?limit=2", server.URL, item.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var page struct {
+		Entries []model.ItemChangelogEntry `json:"entries"`
+		Limit   int                        `json:"limit"`
+		HasMore bool                       `json:"has_more"`
+	}
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if len(page.Entries) != 2 || !page.HasMore || page.Limit != 2 {
+		t.Errorf("expected 2 entries with more to come, got %d (has_more=%v)", len(page.Entries), page.HasMore)
+	}
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/items/%d/changelog?offset=2", server.URL, item.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if len(page.Entries) != 1 || page.HasMore || page.Entries[0].Type != model.ChangelogCreated {
+		t.Errorf("expected only the creation entry on the last page, got %+v", page.Entries)
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/items/9999/changelog", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for missing item, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/items/%d/changelog?limit=0", server.URL, item.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for limit=0, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestAddStockBatchAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	var items [2]model.Item
+	for i, name := range []string{"Widget", "Gadget"} {
+		req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": name})
+		resp, _ := http.DefaultClient.Do(req)
+		json.NewDecoder(resp.Body).Decode(&items[i])
+		resp.Body.Close()
+	}
+	req, _ := authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": "Warehouse", "type": model.OwnerTypeLocation})
+	resp, _ := http.DefaultClient.Do(req)
+	var owner model.Owner
+	json.NewDecoder(resp.Body).Decode(&owner)
+	resp.Body.Close()
+
+	req, _ = authRequest("POST", server.URL+"/api/inventory/stock/batch", token, map[string]any{
+		"owner_id": owner.ID,
+		"lines": []map[string]any{
+			{"item_id": items[0].ID, "quantity": 4},
+			{"item_id": items[1].ID, "quantity": -1},
+		},
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid line, got %d", resp.StatusCode)
+	}
+	var failure addStockBatchFailure
+	json.NewDecoder(resp.Body).Decode(&failure)
+	resp.Body.Close()
+	if failure.Line != 1 || failure.ItemID != items[1].ID {
+		t.Errorf("expected failing line 1, got %+v", failure)
+	}
+	// Nothing is applied, so both lines count as failed.
+	if failure.Requested != 2 || failure.Succeeded != 0 || failure.Failed != 2 ||
+		len(failure.Errors) != 1 || failure.Errors[0].Index != 1 || failure.Errors[0].Message != "quantity must be positive" {
+		t.Errorf("unexpected failure summary: %+v", failure.BulkResult)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/inventory/stock/batch", token, map[string]any{
+		"owner_id": owner.ID,
+		"lines": []map[string]any{
+			{"item_id": items[0].ID, "quantity": 4},
+			{"item_id": items[1].ID, "quantity": 6},
+		},
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result addStockBatchResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	// The failed batch must not have applied its first line.
+	if len(result.Lines) != 2 || result.Lines[0].Quantity != 4 || result.Lines[1].Quantity != 6 {
+		t.Errorf("unexpected batch result: %+v", result.Lines)
+	}
+	if result.Requested != 2 || result.Succeeded != 2 || result.Failed != 0 || result.Errors == nil || len(result.Errors) != 0 {
+		t.Errorf("unexpected success summary: %+v", result.BulkResult)
+	}
+}
+
+// batchStore is a Store whose AddStockBatch fails with err.
+type batchStore struct {
+	store.Store
+	err error
+}
+
+func (s *batchStore) AddStockBatch(ctx context.Context, ownerID int64, lines []model.StockLine, userID *int64) ([]model.StockLineResult, error) {
+	return nil, s.err
+}
+
+func TestAddStockBatchStoreErrors(t *testing.T) {
+	database := db.NewTestDB(t)
+	item, _ := store.CreateItem(context.Background(), database, "Widget", "", "")
+	fake := &batchStore{Store: store.New(database)}
+	h := &InventoryHandler{Store: fake}
+	post := func() *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"owner_id": 99, "lines": [{"item_id": %d, "quantity": 1}]}`, item.ID)
+		req := httptest.NewRequest("POST", "/api/inventory/stock/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.AddStockBatch(rec, req)
+		return rec
+	}
+
+	fake.err = &store.ValidationError{Err: errors.New("owner not found")}
+	if rec := post(); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "owner not found") {
+		t.Errorf("expected 400 owner not found, got %d %s", rec.Code, rec.Body)
+	}
+	fake.err = fmt.Errorf("committing batch stock addition: %w", store.ErrBusy)
+	if rec := post(); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After for a busy database, got %d", rec.Code)
+	}
+	fake.err = errors.New("disk I/O error")
+	if rec := post(); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 for a database failure, got %d", rec.Code)
+	}
+}
+
+// transferStore is a Store whose CreateTransfer fails with err.
+type transferStore struct {
+	store.Store
+	err error
+}
+
+func (s *transferStore) CreateTransfer(ctx context.Context, itemID, fromOwnerID, toOwnerID int64, quantity int, divisible bool, notes string, transferredBy *int64) (*model.Transfer, error) {
+	return nil, s.err
+}
+
+func TestCreateTransferStoreErrors(t *testing.T) {
+	database := db.NewTestDB(t)
+	item, _ := store.CreateItem(context.Background(), database, "Widget", "", "")
+	fake := &transferStore{Store: store.New(database)}
+	h := &TransfersHandler{Store: fake}
+	body := fmt.Sprintf(`{"item_id": %d, "from_owner_id": 1, "to_owner_id": 2, "quantity": 1}`, item.ID)
+	post := func(handler http.HandlerFunc, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/transfers", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	fake.err = &store.ValidationError{Err: errors.New("source owner not found")}
+	if rec := post(h.Create, "application/json", body); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "source owner not found") {
+		t.Errorf("expected 400 with the store's message, got %d %s", rec.Code, rec.Body)
+	}
+	fake.err = fmt.Errorf("committing transfer: %w", store.ErrBusy)
+	if rec := post(h.Create, "application/json", body); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After for a busy database, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := post(h.Ingest, "application/x-ndjson", body+"\n"+body+"\n"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for an ingest failing on its first line, got %d %s", rec.Code, rec.Body)
+	}
+	fake.err = errors.New("disk I/O error")
+	if rec := post(h.Create, "application/json", body); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 for a database failure, got %d", rec.Code)
+	}
+}
+
+func TestStatsAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Widget"})
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	req, _ = authRequest("GET", server.URL+"/api/stats", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var stats model.Stats
+	json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if stats.Items != 1 {
+		t.Errorf("expected 1 item, got %d", stats.Items)
+	}
+	if d := stats.Activity.To.Sub(stats.Activity.From); d != 30*24*time.Hour {
+		t.Errorf("expected default 30-day range, got %v", d)
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/stats?from=2024-01-01&to=2024-03-31", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	wantTo := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	if !stats.Activity.To.Equal(wantTo) {
+		t.Errorf("expected to date to include the whole day (%v), got %v", wantTo, stats.Activity.To)
+	}
+	if stats.Items != 1 {
+		t.Errorf("expected current-state counts regardless of range, got %d items", stats.Items)
+	}
+
+	for _, q := range []string{"?from=2024-05-01&to=2024-04-01", "?from=yesterday"} {
+		req, _ = authRequest("GET", server.URL+"/api/stats"+q, token, nil)
+		resp, _ = http.DefaultClient.Do(req)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %q, got %d", q, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestInventoryTrendAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{ReportCooldown: -1}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user1", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	item, _ := store.CreateItem(ctx, database, "Rope", "", "")
+	storage, _ := store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	store.AddStock(ctx, database, item.ID, storage.ID, 6, false, nil)
+	store.RecordInventorySnapshot(ctx, database, time.Now().AddDate(0, 0, -1))
+	store.SetStock(ctx, database, item.ID, storage.ID, 4, false, nil)
+
+	get := func(query string) (int, []model.InventoryTrendPoint) {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"/api/reports/inventory-trend"+query, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET trend: %v", err)
+		}
+		defer resp.Body.Close()
+		var points []model.InventoryTrendPoint
+		json.NewDecoder(resp.Body).Decode(&points)
+		return resp.StatusCode, points
+	}
+
+	code, points := get("?days=3&by_status=true")
+	if code != http.StatusOK || len(points) != 3 {
+		t.Fatalf("expected 200 with 3 points, got %d %v", code, points)
+	}
+	if points[0].Units != nil || points[0].Source != model.TrendSourceMissing {
+		t.Errorf("expected the day before the first snapshot to be missing, got %+v", points[0])
+	}
+	for i, want := range []int{6, 4} {
+		p := points[i+1]
+		if p.Units == nil || *p.Units != want || p.ByStatus[model.ItemStatusActive] != want {
+			t.Errorf("point %d: expected %d units, got %+v", i+1, want, p)
+		}
+	}
+	if today := time.Now().UTC().Format(time.DateOnly); points[2].Date != today || points[2].Source != model.TrendSourceLive {
+		t.Errorf("expected the last point to be today's live total, got %+v", points[2])
+	}
+
+	if _, points := get(""); len(points) != 30 || points[0].ByStatus != nil {
+		t.Errorf("expected 30 days without per-status figures by default, got %d points", len(points))
+	}
+	for _, q := range []string{"?days=0", "?days=367", "?days=week"} {
+		if code, _ := get(q); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %q, got %d", q, code)
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := SecurityHeaders(model.DefaultCSP, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	want := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "same-origin",
+		"Content-Security-Policy": model.DefaultCSP,
+	}
+	for name, value := range want {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("%s: expected %q, got %q", name, value, got)
+		}
+	}
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("expected no HSTS over plain HTTP")
+	}
+
+	handler = SecurityHeaders("", true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Header().Get("Strict-Transport-Security") == "" {
+		t.Error("expected HSTS when enabled")
+	}
+	if rec.Header().Get("Content-Security-Policy") != "" {
+		t.Error("expected no CSP when disabled")
+	}
+}
+
+func TestAdminDBStats(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("GET", server.URL+"/api/admin/db-stats", token, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var stats model.DBStats
+	json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if stats.PageCount <= 0 || stats.PageSize <= 0 || stats.JournalMode == "" {
+		t.Errorf("expected populated stats, got %+v", stats)
+	}
+}
+
+func TestAdminMigrations(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("GET", server.URL+"/api/admin/migrations", token, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var status model.MigrationStatus
+	json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if status.Version == 0 || status.Version != status.Latest || len(status.Pending) != 0 || len(status.Migrations) != status.Latest {
+		t.Errorf("expected an up-to-date schema, got %+v", status)
+	}
+}
+
+func TestAdminLogins(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	// setupTestServer logged in once; add a failed attempt with a user agent.
+	body, _ := json.Marshal(map[string]string{"username": "admin", "password": "wrong"})
+	req, _ := http.NewRequest("POST", server.URL+"/api/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "test-agent")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for bad password, got %d", resp.StatusCode)
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/admin/logins?user_id=1", token, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var activity model.LoginActivity
+	json.NewDecoder(resp.Body).Decode(&activity)
+	resp.Body.Close()
+
+	if len(activity.Events) != 2 {
+		t.Fatalf("expected 2 login events, got %+v", activity.Events)
+	}
+	failed, succeeded := activity.Events[0], activity.Events[1]
+	if failed.Success || failed.Username != "admin" || failed.UserAgent != "test-agent" || failed.IP == "" {
+		t.Errorf("unexpected failed login event: %+v", failed)
+	}
+	if !succeeded.Success || succeeded.UserID == nil || *succeeded.UserID != 1 {
+		t.Errorf("unexpected successful login event: %+v", succeeded)
+	}
+	if len(activity.FailedHotspots) != 1 || activity.FailedHotspots[0].Failures != 1 || activity.FailedHotspots[0].IP != failed.IP {
+		t.Errorf("expected one hotspot with one failure, got %+v", activity.FailedHotspots)
+	}
+
+	// Another user's filter sees nothing; a range in the past neither.
+	for _, query := range []string{"?user_id=2", "?to=2000-01-01"} {
+		req, _ = authRequest("GET", server.URL+"/api/admin/logins"+query, token, nil)
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		activity = model.LoginActivity{}
+		json.NewDecoder(resp.Body).Decode(&activity)
+		resp.Body.Close()
+		if len(activity.Events) != 0 {
+			t.Errorf("%s: expected no events, got %+v", query, activity.Events)
+		}
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/admin/logins?from=yesterday", token, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid from, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminOptimize(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/admin/optimize", token, map[string]bool{"vacuum": true})
+	resp, _ := http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Before *model.DBStats `json:"before"`
+		After  *model.DBStats `json:"after"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if body.Before == nil || body.After == nil || body.After.PageCount <= 0 {
+		t.Errorf("expected before/after stats, got %+v", body)
+	}
+}
+
+func TestAdminReprocessImages(t *testing.T) {
+	database := db.NewTestDB(t)
+	job := &reprocess.Job{DB: database}
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{ImageJob: job}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "x", model.RoleAdmin)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+
+	item, _ := store.CreateItem(ctx, database, "Poster", "", "")
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1200, 300)), nil)
+	store.SetItemImage(ctx, database, item.ID, buf.Bytes(), "image/jpeg")
+
+	do := func(method string) (int, model.ReprocessStatus) {
+		req, _ := authRequest(method, server.URL+"/api/admin/reprocess-images", token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s request: %v", method, err)
+		}
+		defer resp.Body.Close()
+		var status model.ReprocessStatus
+		json.NewDecoder(resp.Body).Decode(&status)
+		return resp.StatusCode, status
+	}
+
+	if code, _ := do("DELETE"); code != http.StatusConflict {
+		t.Errorf("expected 409 canceling with no run, got %d", code)
+	}
+	if code, status := do("POST"); code != http.StatusAccepted || status.StartedAt == nil {
+		t.Fatalf("expected 202 with a started run, got %d %+v", code, status)
+	}
+	job.Wait()
+
+	code, status := do("GET")
+	if code != http.StatusOK || status.Running || status.Total != 1 || status.Updated != 1 {
+		t.Errorf("expected one updated image, got %d %+v", code, status)
+	}
+	data, _, _ := store.GetItemImage(ctx, database, item.ID, false)
+	if cfg, _, _ := image.DecodeConfig(bytes.NewReader(data)); cfg.Width != imaging.MaxDimension || cfg.Height != 256 {
+		t.Errorf("expected %dx256, got %dx%d", imaging.MaxDimension, cfg.Width, cfg.Height)
+	}
+}
+
+func TestActivityAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Widget"})
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": "Storage", "type": "location"})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	req, _ = authRequest("GET", server.URL+"/api/activity", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var feed struct {
+		Events     []model.ActivityEvent `json:"events"`
+		NextBefore *time.Time            `json:"next_before"`
+	}
+	json.NewDecoder(resp.Body).Decode(&feed)
+	resp.Body.Close()
+	types := map[string]bool{}
+	for _, e := range feed.Events {
+		types[e.Type] = true
+	}
+	if len(feed.Events) != 2 || !types[model.ActivityItemCreated] || !types[model.ActivityOwnerCreated] {
+		t.Errorf("expected item and owner creation, got %+v", feed.Events)
+	}
+	if feed.NextBefore != nil {
+		t.Errorf("expected no next page, got %v", feed.NextBefore)
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/activity?limit=1", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	feed.NextBefore = nil
+	json.NewDecoder(resp.Body).Decode(&feed)
+	resp.Body.Close()
+	if feed.NextBefore == nil {
+		t.Error("expected next_before when the page is full")
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/activity?before=yesterday", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid before, got %d", resp.StatusCode)
+	}
+}
+
+func TestOwnerHandoverCSV(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	create := func(path string, body map[string]string) int64 {
+		req, _ := authRequest("POST", server.URL+path, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var v struct {
+			ID int64 `json:"id"`
+		}
+		json.NewDecoder(resp.Body).Decode(&v)
+		return v.ID
+	}
+	laptop := create("/api/items", map[string]string{"name": "Laptop"})
+	cable := create("/api/items", map[string]string{"name": "Cable"})
+	storage := create("/api/owners", map[string]string{"name": "Storage", "type": "location"})
+	ana := create("/api/owners", map[string]string{"name": "Ana", "type": "person"})
+
+	for _, item := range []int64{laptop, cable} {
+		req, _ := authRequest("POST", server.URL+"/api/inventory/stock", token, map[string]any{"item_id": item, "owner_id": storage, "quantity": 2})
+		resp, _ := http.DefaultClient.Do(req)
+		resp.Body.Close()
+	}
+	// Ana borrows a laptop and a cable, then returns the cable.
+	for _, tr := range []map[string]any{
+		{"item_id": laptop, "from_owner_id": storage, "to_owner_id": ana, "quantity": 1, "notes": "=HYPERLINK(\"http://evil.example\")"},
+		{"item_id": cable, "from_owner_id": storage, "to_owner_id": ana, "quantity": 1},
+		{"item_id": cable, "from_owner_id": ana, "to_owner_id": storage, "quantity": 1},
+	} {
+		req, _ := authRequest("POST", server.URL+"/api/transfers", token, tr)
+		resp, _ := http.DefaultClient.Do(req)
+		resp.Body.Close()
+	}
+
+	// Checked first: a generated report starts the per-user cooldown.
+	req, _ := authRequest("GET", fmt.Sprintf("%s/api/owners/%d/handover?format=pdf", server.URL, ana), token, nil)
+	resp2, _ := http.DefaultClient.Do(req)
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for pdf, got %d", resp2.StatusCode)
+	}
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/owners/%d/handover?format=csv", server.URL, ana), token, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("handover request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv, got %q", ct)
+	}
+	r := csv.NewReader(resp.Body)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("parsing CSV: %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("expected owner, date, header, 1 holding and 1 transfer row, got %v", records)
+	}
+	if records[0][1] != "Ana" || records[1][0] != "generated_at" {
+		t.Errorf("unexpected preamble: %v", records[:2])
+	}
+	if got := records[3]; got[0] != "holding" || got[2] != "Laptop" || got[3] != "1" {
+		t.Errorf("unexpected holding row: %v", got)
+	}
+	if got := records[4]; got[0] != "received" || got[2] != "Laptop" || got[5] != "Storage" || got[6] != `'=HYPERLINK("http://evil.example")` {
+		t.Errorf("unexpected transfer row: %v", got)
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	for in, want := range map[string]string{
+		"":           "",
+		"Drill":      "Drill",
+		"=1+1":       "'=1+1",
+		"+386 1 234": "'+386 1 234",
+		"-2":         "'-2",
+		"@SUM(A1)":   "'@SUM(A1)",
+		"\tcmd":      "'\tcmd",
+		"\r=1":       "'\r=1",
+		"a=b":        "a=b",
+		"'quoted":    "'quoted",
+	} {
+		if got := csvCell(in); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFavoritesAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	var ids []int64
+	for _, name := range []string{"Cable", "Drill", "Laptop"} {
+		req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": name})
+		resp, _ := http.DefaultClient.Do(req)
+		var item model.Item
+		json.NewDecoder(resp.Body).Decode(&item)
+		resp.Body.Close()
+		ids = append(ids, item.ID)
+	}
+
+	for _, id := range []int64{ids[2], ids[1]} {
+		req, _ := authRequest("POST", fmt.Sprintf("%s/api/items/%d/favorite", server.URL, id), token, nil)
+		resp, _ := http.DefaultClient.Do(req)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("pin: expected 200, got %d", resp.StatusCode)
+		}
+	}
+	req, _ := authRequest("DELETE", fmt.Sprintf("%s/api/items/%d/favorite", server.URL, ids[1]), token, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	req, _ = authRequest("GET", server.URL+"/api/favorites", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	var favs []model.Item
+	json.NewDecoder(resp.Body).Decode(&favs)
+	resp.Body.Close()
+	if len(favs) != 1 || favs[0].Name != "Laptop" {
+		t.Errorf("expected only Laptop pinned, got %+v", favs)
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/items?favorites_first=true", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	var items []model.Item
+	json.NewDecoder(resp.Body).Decode(&items)
+	resp.Body.Close()
+	if len(items) != 3 || items[0].Name != "Laptop" || items[1].Name != "Cable" {
+		t.Errorf("expected Laptop first then the rest by name, got %+v", items)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/items/999/favorite", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for missing item, got %d", resp.StatusCode)
+	}
+}
+
+func TestNameNormalization(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "  Laptop \t Pro "})
+	resp, _ := http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || item.Name != "Laptop Pro" {
+		t.Errorf("expected normalized name %q, got %d %q", "Laptop Pro", resp.StatusCode, item.Name)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": strings.Repeat("x", model.MaxNameLength+1)})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for over-long item name, got %d", resp.StatusCode)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": " Ana  Novak ", "type": "person"})
+	resp, _ = http.DefaultClient.Do(req)
+	var owner model.Owner
+	json.NewDecoder(resp.Body).Decode(&owner)
+	resp.Body.Close()
+	if owner.Name != "Ana Novak" {
+		t.Errorf("expected normalized owner name, got %q", owner.Name)
+	}
+
+	req, _ = authRequest("PUT", fmt.Sprintf("%s/api/owners/%d", server.URL, owner.ID), token, map[string]string{"name": "   "})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for blank owner name, got %d", resp.StatusCode)
+	}
+}
+
+func TestCloneItemAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Drill", "description": "Cordless"})
+	resp, _ := http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+
+	req, _ = authRequest("POST", fmt.Sprintf("%s/api/items/%d/clone", server.URL, item.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	var clone model.Item
+	json.NewDecoder(resp.Body).Decode(&clone)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if clone.Name != "Drill (copy)" || clone.Description != "Cordless" {
+		t.Errorf("unexpected clone: %+v", clone)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/items/999/clone", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for missing item, got %d", resp.StatusCode)
+	}
+}
+
+func TestItemAvailableAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Widget"})
+	resp, _ := http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+	var owners []model.Owner
+	for _, name := range []string{"Storage", "Office"} {
+		req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": name, "type": "location"})
+		resp, _ = http.DefaultClient.Do(req)
+		var o model.Owner
+		json.NewDecoder(resp.Body).Decode(&o)
+		resp.Body.Close()
+		owners = append(owners, o)
+	}
+	req, _ = authRequest("POST", server.URL+"/api/inventory/stock", token, map[string]any{"item_id": item.ID, "owner_id": owners[0].ID, "quantity": 5})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	for _, tc := range []struct {
+		ownerID int64
+		want    int
+	}{{owners[0].ID, 5}, {owners[1].ID, 0}} {
+		req, _ = authRequest("GET", fmt.Sprintf("%s/api/items/%d/available?owner_id=%d", server.URL, item.ID, tc.ownerID), token, nil)
+		resp, _ = http.DefaultClient.Do(req)
+		var body struct {
+			Quantity int `json:"quantity"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || body.Quantity != tc.want {
+			t.Errorf("owner %d: expected 200 with %d, got %d with %d", tc.ownerID, tc.want, resp.StatusCode, body.Quantity)
+		}
+	}
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/items/%d/available", server.URL, item.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without owner_id, got %d", resp.StatusCode)
+	}
+}
+
+func TestDivisibleQuantitiesAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	do := func(method, url string, body any) (int, map[string]any) {
+		req, _ := authRequest(method, server.URL+url, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, url, err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	_, flour := do("POST", "/api/items", map[string]any{"name": "Flour", "divisible": true})
+	_, nails := do("POST", "/api/items", map[string]any{"name": "Nails"})
+	_, storage := do("POST", "/api/owners", map[string]string{"name": "Storage", "type": "location"})
+	_, kitchen := do("POST", "/api/owners", map[string]string{"name": "Kitchen", "type": "location"})
+	if flour["divisible"] != true || nails["divisible"] != false {
+		t.Fatalf("expected only Flour divisible, got %v and %v", flour, nails)
+	}
+	flourID, nailsID := flour["id"], nails["id"]
+	storageID, kitchenID := storage["id"], kitchen["id"]
+
+	if code, _ := do("POST", "/api/inventory/stock", map[string]any{"item_id": flourID, "owner_id": storageID, "quantity": 2.5}); code != http.StatusOK {
+		t.Fatalf("expected 200 adding 2.5 kg, got %d", code)
+	}
+	if code, body := do("POST", "/api/inventory/stock", map[string]any{"item_id": nailsID, "owner_id": storageID, "quantity": 1.5}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a fractional quantity of a whole item, got %d %v", code, body)
+	}
+
+	code, transfer := do("POST", "/api/transfers", map[string]any{"item_id": flourID, "from_owner_id": storageID, "to_owner_id": kitchenID, "quantity": 1.25})
+	if code != http.StatusCreated || transfer["quantity"] != 1.25 || transfer["divisible"] != true {
+		t.Fatalf("expected a 1.25 transfer, got %d %v", code, transfer)
+	}
+	code, body := do("POST", "/api/transfers", map[string]any{"item_id": flourID, "from_owner_id": storageID, "to_owner_id": kitchenID, "quantity": 1.5})
+	if code != http.StatusBadRequest || body["available"] != 1.25 || body["requested"] != 1.5 {
+		t.Errorf("expected insufficient quantity with 1.25 available, got %d %v", code, body)
+	}
+	if code, body := do("POST", "/api/transfers/validate", map[string]any{"item_id": flourID, "from_owner_id": storageID, "to_owner_id": kitchenID, "quantity": 0.25}); body["valid"] != true || body["from_quantity"] != float64(1) || body["to_quantity"] != 1.5 {
+		t.Errorf("expected a valid preview leaving 1 and 1.5, got %d %v", code, body)
+	}
+
+	_, available := do("GET", fmt.Sprintf("/api/items/%v/available?owner_id=%v", flourID, kitchenID), nil)
+	if available["quantity"] != 1.25 {
+		t.Errorf("expected 1.25 available, got %v", available)
+	}
+
+	// Fractional stock keeps the item divisible.
+	if code, _ := do("PUT", fmt.Sprintf("/api/items/%v/divisible", flourID), map[string]any{"divisible": false}); code != http.StatusConflict {
+		t.Errorf("expected 409 switching off with fractional stock, got %d", code)
+	}
+}
+
+func TestDeleteWithReasonAndRestoreAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Drill"})
+	resp, _ := http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+
+	req, _ = authRequest("DELETE", fmt.Sprintf("%s/api/items/%d", server.URL, item.ID), token, map[string]string{"reason": "broken"})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", resp.StatusCode)
+	}
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/items/%d?include_deleted=true", server.URL, item.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	var detail struct {
+		Item model.Item `json:"item"`
+	}
+	json.NewDecoder(resp.Body).Decode(&detail)
+	resp.Body.Close()
+	if detail.Item.DeletedBy == nil || *detail.Item.DeletedBy != 1 || detail.Item.DeleteReason != "broken" {
+		t.Errorf("expected deleted_by=1 and reason, got %+v", detail.Item)
+	}
+
+	req, _ = authRequest("POST", fmt.Sprintf("%s/api/items/%d/restore", server.URL, item.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	var restored model.Item
+	json.NewDecoder(resp.Body).Decode(&restored)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || restored.DeletedAt != nil || restored.DeletedBy != nil || restored.DeleteReason != "" {
+		t.Errorf("expected restored item with cleared fields, got %d %+v", resp.StatusCode, restored)
+	}
+
+	// Deleting an owner without a body, the way the web UI does.
+	req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": "Ana", "type": "person"})
+	resp, _ = http.DefaultClient.Do(req)
+	var owner model.Owner
+	json.NewDecoder(resp.Body).Decode(&owner)
+	resp.Body.Close()
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("%s/api/owners/%d", server.URL, owner.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("bodyless owner delete: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	if _, err := ParseTrustedProxies("not-a-cidr"); err == nil {
+		t.Error("expected error for invalid proxy")
+	}
+
+	var got string
+	handler := TrustedProxies(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ClientIP(r)
+	}))
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"no proxy headers", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer spoofing XFF", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.7"},
+		{"untrusted peer spoofing X-Real-IP", "203.0.113.7:5000", map[string]string{"X-Real-IP": "1.2.3.4"}, "203.0.113.7"},
+		{"trusted peer with XFF", "10.1.2.3:5000", map[string]string{"X-Forwarded-For": "198.51.100.9"}, "198.51.100.9"},
+		{"trusted chain skips inner proxies", "10.1.2.3:5000", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.9, 10.9.9.9"}, "198.51.100.9"},
+		{"trusted bare IP with X-Real-IP", "192.168.1.1:5000", map[string]string{"X-Real-IP": "198.51.100.9"}, "198.51.100.9"},
+		{"trusted peer without headers", "10.1.2.3:5000", nil, "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("%s: ClientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOwnerSummaryAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": "Room", "type": "location"})
+	resp, _ := http.DefaultClient.Do(req)
+	var owner model.Owner
+	json.NewDecoder(resp.Body).Decode(&owner)
+	resp.Body.Close()
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/owners/%d/summary", server.URL, owner.ID), token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	var summary model.OwnerSummary
+	json.NewDecoder(resp.Body).Decode(&summary)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || summary.OwnerID != owner.ID || summary.UnitsByStatus == nil {
+		t.Errorf("expected empty summary for owner, got %d %+v", resp.StatusCode, summary)
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/owners/999/summary", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for missing owner, got %d", resp.StatusCode)
+	}
+}
+
+func TestWrongTypedFieldNamesField(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	tests := []struct {
+		path string
+		body map[string]any
+		want string
+	}{
+		{"/api/transfers", map[string]any{"item_id": 1, "from_owner_id": 1, "to_owner_id": 2, "quantity": "five"}, "field 'quantity' must be a number"},
+		{"/api/inventory/stock", map[string]any{"item_id": 1, "owner_id": 1, "quantity": "five"}, "field 'quantity' must be a number"},
+		{"/api/inventory/stock", map[string]any{"item_id": "one", "owner_id": 1, "quantity": 5}, "field 'item_id' must be a number"},
+		{"/api/transfers", map[string]any{"item_id": 1, "from_owner_id": 1, "to_owner_id": 2, "quantity": 1, "notes": 7}, "field 'notes' must be a string"},
+	}
+	for _, tt := range tests {
+		req, _ := authRequest("POST", server.URL+tt.path, token, tt.body)
+		resp, _ := http.DefaultClient.Do(req)
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || body["error"] != tt.want {
+			t.Errorf("%s %v: expected 400 %q, got %d %q", tt.path, tt.body, tt.want, resp.StatusCode, body["error"])
+		}
+	}
+}
+
+func TestTransformItemImageAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+
+	item, _ := store.CreateItem(ctx, database, "Crooked Photo", "", "")
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil)
+	store.SetItemImage(ctx, database, item.ID, buf.Bytes(), "image/jpeg")
+	transformURL := fmt.Sprintf("%s/api/items/%d/image/transform", server.URL, item.ID)
+
+	req, _ := authRequest("POST", transformURL, token, map[string]any{"rotate": 90})
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	data, _, _ := store.GetItemImage(ctx, database, item.ID, false)
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding stored image: %v", err)
+	}
+	if cfg.Width != 20 || cfg.Height != 40 {
+		t.Errorf("expected stored image 20x40 after rotation, got %dx%d", cfg.Width, cfg.Height)
+	}
+
+	for _, body := range []map[string]any{{}, {"rotate": 45}, {"flip": "diagonal"}} {
+		req, _ = authRequest("POST", transformURL, token, body)
+		resp, _ = http.DefaultClient.Do(req)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d", body, resp.StatusCode)
+		}
+	}
+
+	bare, _ := store.CreateItem(ctx, database, "No Photo", "", "")
+	req, _ = authRequest("POST", fmt.Sprintf("%s/api/items/%d/image/transform", server.URL, bare.ID), token, map[string]any{"flip": "h"})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for item without image, got %d", resp.StatusCode)
+	}
+}
+
+func TestCreateUserDefaultRole(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{DefaultRole: model.RoleUser}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+
+	req, _ := authRequest("POST", server.URL+"/api/users", token, map[string]string{"username": "ana", "password": "password123"})
+	resp, _ := http.DefaultClient.Do(req)
+	var user model.User
+	json.NewDecoder(resp.Body).Decode(&user)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || user.Role != model.RoleUser {
+		t.Errorf("expected 201 with default role 'user', got %d %q", resp.StatusCode, user.Role)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/users", token, map[string]string{"username": "bor", "password": "password123", "role": "superuser"})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid role, got %d", resp.StatusCode)
+	}
+
+	// Without a configured default the role is still required.
+	strict, strictToken := setupTestServer(t)
+	req, _ = authRequest("POST", strict.URL+"/api/users", strictToken, map[string]string{"username": "ana", "password": "password123"})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for omitted role without default, got %d", resp.StatusCode)
+	}
+}
+
+func TestListHoldingOwnersAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user1", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+
+	room, _ := store.CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	closet, _ := store.CreateOwner(ctx, database, "Closet", model.OwnerTypeLocation)
+	store.CreateOwner(ctx, database, "Empty", model.OwnerTypeLocation)
+	chair, _ := store.CreateItem(ctx, database, "Chair", "", "")
+	lamp, _ := store.CreateItem(ctx, database, "Lamp", "", "")
+	store.AddStock(ctx, database, chair.ID, room.ID, 5, false, nil)
+	store.AddStock(ctx, database, lamp.ID, closet.ID, 1, false, nil)
+
+	list := func(query string) (int, []model.Owner) {
+		req, _ := authRequest("GET", server.URL+"/api/owners?"+query, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", query, err)
+		}
+		defer resp.Body.Close()
+		var owners []model.Owner
+		json.NewDecoder(resp.Body).Decode(&owners)
+		return resp.StatusCode, owners
+	}
+
+	if code, owners := list("holding=true"); code != http.StatusOK || len(owners) != 2 {
+		t.Errorf("expected 2 holding owners, got %d %v", code, owners)
+	}
+	if code, owners := list(fmt.Sprintf("holding=true&item_id=%d", chair.ID)); code != http.StatusOK || len(owners) != 1 || owners[0].ID != room.ID {
+		t.Errorf("expected only Room holding chairs, got %d %v", code, owners)
+	}
+	if code, owners := list("holding=true&item_id=999"); code != http.StatusOK || len(owners) != 0 {
+		t.Errorf("expected empty list for unknown item, got %d %v", code, owners)
+	}
+	for _, query := range []string{fmt.Sprintf("item_id=%d", chair.ID), "holding=true&item_id=abc", "holding=true&q=room"} {
+		if code, _ := list(query); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, code)
+		}
+	}
+}
+
+func TestListOwnersExclude(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "ana", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	ana, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	bor, _ := store.CreateOwner(ctx, database, "Bor", model.OwnerTypePerson)
+	shed, _ := store.CreateOwner(ctx, database, "Shed", model.OwnerTypeLocation)
+
+	list := func(query string) (int, []int64) {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"/api/owners?"+query, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", query, err)
+		}
+		defer resp.Body.Close()
+		var owners []model.Owner
+		json.NewDecoder(resp.Body).Decode(&owners)
+		var ids []int64
+		for _, o := range owners {
+			ids = append(ids, o.ID)
+		}
+		return resp.StatusCode, ids
+	}
+
+	if code, ids := list(fmt.Sprintf("exclude=%d", bor.ID)); code != http.StatusOK || slices.Contains(ids, bor.ID) || len(ids) != 2 {
+		t.Errorf("expected Bor to be excluded, got %d %v", code, ids)
+	}
+	if code, ids := list(fmt.Sprintf("exclude=%d&exclude=%d&type=person", bor.ID, shed.ID)); code != http.StatusOK || !slices.Equal(ids, []int64{ana.ID}) {
+		t.Errorf("expected only Ana, got %d %v", code, ids)
+	}
+
+	// Without a linked owner there is nothing to exclude.
+	if code, ids := list("exclude_self=true"); code != http.StatusOK || len(ids) != 3 {
+		t.Errorf("expected all owners for an unlinked user, got %d %v", code, ids)
+	}
+	store.LinkOwnerUser(ctx, database, ana.ID, &user.ID)
+	if code, ids := list("exclude_self=true"); code != http.StatusOK || !slices.Equal(ids, []int64{bor.ID, shed.ID}) {
+		t.Errorf("expected the linked owner to be excluded, got %d %v", code, ids)
+	}
+
+	if code, _ := list("exclude=abc"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid exclude, got %d", code)
+	}
+}
+
+func TestTransfersPaginationLinks(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user1", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	item, _ := store.CreateItem(ctx, database, "Widget", "", "")
+	from, _ := store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := store.CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	store.AddStock(ctx, database, item.ID, from.ID, 10, false, nil)
+	for i := 0; i < 5; i++ {
+		store.CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 1, false, "", nil)
+	}
+
+	tests := []struct {
+		offset     int
+		count      int
+		prev, next string
+	}{
+		{0, 2, "", `</api/transfers?limit=2&offset=2>; rel="next"`},
+		{2, 2, `</api/transfers?limit=2&offset=0>; rel="prev"`, `</api/transfers?limit=2&offset=4>; rel="next"`},
+		{4, 1, `</api/transfers?limit=2&offset=2>; rel="prev"`, ""},
+	}
+	for _, tt := range tests {
+		req, _ := authRequest("GET", fmt.Sprintf("%s/api/transfers?limit=2&offset=%d", server.URL, tt.offset), token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("listing transfers: %v", err)
+		}
+		var transfers []model.Transfer
+		json.NewDecoder(resp.Body).Decode(&transfers)
+		resp.Body.Close()
+
+		link := resp.Header.Get("Link")
+		if len(transfers) != tt.count || resp.Header.Get("X-Total-Count") != "5" {
+			t.Errorf("offset %d: expected %d of 5 transfers, got %d of %s", tt.offset, tt.count, len(transfers), resp.Header.Get("X-Total-Count"))
+		}
+		if !strings.Contains(link, `</api/transfers?limit=2&offset=4>; rel="last"`) {
+			t.Errorf("offset %d: missing last link in %q", tt.offset, link)
+		}
+		if (tt.prev == "") == strings.Contains(link, `rel="prev"`) || (tt.prev != "" && !strings.Contains(link, tt.prev)) {
+			t.Errorf("offset %d: expected prev %q in %q", tt.offset, tt.prev, link)
+		}
+		if (tt.next == "") == strings.Contains(link, `rel="next"`) || (tt.next != "" && !strings.Contains(link, tt.next)) {
+			t.Errorf("offset %d: expected next %q in %q", tt.offset, tt.next, link)
+		}
+	}
+
+	// Without limit/offset the list is unpaginated and has no Link header.
+	req, _ := authRequest("GET", server.URL+"/api/transfers", token, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.Header.Get("Link") != "" {
+		t.Errorf("expected no Link header without pagination, got %q", resp.Header.Get("Link"))
+	}
+}
+
+func TestSetStockAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	token, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+	item, _ := store.CreateItem(ctx, database, "Widget", "", "")
+	room, _ := store.CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	store.AddStock(ctx, database, item.ID, room.ID, 5, false, nil)
+
+	set := func(body map[string]any) (int, setStockResponse) {
+		req, _ := authRequest("PUT", server.URL+"/api/inventory/stock", token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT /api/inventory/stock: %v", err)
+		}
+		defer resp.Body.Close()
+		var res setStockResponse
+		json.NewDecoder(resp.Body).Decode(&res)
+		return resp.StatusCode, res
+	}
+
+	if code, res := set(map[string]any{"item_id": item.ID, "owner_id": room.ID, "quantity": 12}); code != http.StatusOK || res.Quantity != "12" || res.Delta != "7" {
+		t.Errorf("expected 200 with quantity 12, delta 7, got %d %+v", code, res)
+	}
+	if code, res := set(map[string]any{"item_id": item.ID, "owner_id": room.ID, "quantity": 12}); code != http.StatusOK || res.Delta != "0" {
+		t.Errorf("expected repeated set to be a no-op, got %d %+v", code, res)
+	}
+	if code, res := set(map[string]any{"item_id": item.ID, "owner_id": room.ID, "quantity": 0}); code != http.StatusOK || res.Delta != "-12" {
+		t.Errorf("expected set to zero with delta -12, got %d %+v", code, res)
+	}
+	if got, _ := store.GetHeldQuantity(ctx, database, item.ID, room.ID); got != 0 {
+		t.Errorf("expected nothing held after set to zero, got %d", got)
+	}
+
+	for _, body := range []map[string]any{
+		{"item_id": item.ID, "owner_id": room.ID},
+		{"item_id": item.ID, "owner_id": room.ID, "quantity": -1},
+		{"item_id": item.ID, "owner_id": 999, "quantity": 1},
+	} {
+		if code, _ := set(body); code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d", body, code)
+		}
+	}
+}
+
+func TestGetImageContentLength(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user1", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	item, _ := store.CreateItem(ctx, database, "Photo Item", "", "")
+	data := bytes.Repeat([]byte{0xff, 0xd8, 0x42}, 40000)
+	store.SetItemImage(ctx, database, item.ID, data, "image/jpeg")
+
+	req, _ := authRequest("GET", fmt.Sprintf("%s/api/items/%d/image", server.URL, item.ID), token, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET image: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if got := resp.Header.Get("Content-Length"); got != fmt.Sprint(len(data)) {
+		t.Errorf("expected Content-Length %d, got %q", len(data), got)
+	}
+	if !bytes.Equal(body, data) {
+		t.Errorf("expected %d image bytes, got %d", len(data), len(body))
+	}
+}
+
+func TestItemQuotaAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+	store.SetQuotas(1, 0)
+	t.Cleanup(func() { store.SetQuotas(0, 0) })
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Chair"})
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 within quota, got %d", resp.StatusCode)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Desk"})
+	resp, _ = http.DefaultClient.Do(req)
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || body["error"] != "items quota exceeded (max 1)" {
+		t.Errorf("expected 403 quota exceeded, got %d %q", resp.StatusCode, body["error"])
+	}
+
+	// Owners are unlimited.
+	req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": "Room", "type": "location"})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201 for owner without quota, got %d", resp.StatusCode)
+	}
+}
+
+func TestOwnerCardAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user1", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	room, _ := store.CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	ana, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	chair, _ := store.CreateItem(ctx, database, "Chair", "", "")
+	store.AddStock(ctx, database, chair.ID, room.ID, 10, false, nil)
+	for i := 0; i < 3; i++ {
+		store.CreateTransfer(ctx, database, chair.ID, room.ID, ana.ID, 1, false, "", nil)
+	}
+
+	req, _ := authRequest("GET", fmt.Sprintf("%s/api/owners/%d/card?limit=2", server.URL, ana.ID), token, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET card: %v", err)
+	}
+	var card ownerCardResponse
+	json.NewDecoder(resp.Body).Decode(&card)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || card.Owner == nil || card.Owner.ID != ana.ID {
+		t.Fatalf("expected 200 with owner Ana, got %d %+v", resp.StatusCode, card.Owner)
+	}
+	if len(card.Inventory) != 1 || card.Inventory[0].Quantity != 3 {
+		t.Errorf("expected Ana to hold 3 chairs, got %+v", card.Inventory)
+	}
+	if len(card.RecentTransfers) != 2 || card.TransferCount != 3 {
+		t.Errorf("expected 2 of 3 recent transfers, got %d of %d", len(card.RecentTransfers), card.TransferCount)
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/owners/999/card", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for missing owner, got %d", resp.StatusCode)
+	}
+}
+
+func TestIngestTransfersNDJSON(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "scanner", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	room, _ := store.CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	ana, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	chair, _ := store.CreateItem(ctx, database, "Chair", "", "")
+	store.AddStock(ctx, database, chair.ID, room.ID, 3, false, nil)
+
+	line := func(from, to int64, qty int) string {
+		return fmt.Sprintf(`{"item_id": %d, "from_owner_id": %d, "to_owner_id": %d, "quantity": %d}`, chair.ID, from, to, qty)
+	}
+	body := strings.Join([]string{
+		line(room.ID, ana.ID, 2),
+		line(room.ID, ana.ID, 5), // only 1 left
+		"",
+		fmt.Sprintf(`{"item_id": %d, "quantity": "one"}`, chair.ID),
+		line(ana.ID, room.ID, 1),
+	}, "\n") + "\n"
+
+	req, _ := http.NewRequest("POST", server.URL+"/api/transfers/ingest", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST ingest: %v", err)
+	}
+	var res ingestResponse
+	json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || res.Created != 2 || res.Failed != 2 || len(res.Results) != 4 {
+		t.Fatalf("expected 200 with 2 created, 2 failed, got %d %+v", resp.StatusCode, res)
+	}
+	want := []struct {
+		line int
+		err  string
+	}{
+		{1, ""},
+		{2, "insufficient quantity"},
+		{4, "field 'quantity' must be a number"},
+		{5, ""},
+	}
+	for i, w := range want {
+		got := res.Results[i]
+		if got.Line != w.line || got.Error != w.err || (w.err == "") != (got.Transfer != nil) {
+			t.Errorf("result %d: expected line %d error %q, got %+v", i, w.line, w.err, got)
+		}
+	}
+	if res.Requested != 4 || res.Succeeded != 2 {
+		t.Errorf("expected 4 requested, 2 succeeded, got %+v", res.BulkResult)
+	}
+	wantErrors := []model.BulkError{{Index: 1, Message: "insufficient quantity"}, {Index: 3, Message: "field 'quantity' must be a number"}}
+	if !slices.Equal(res.Errors, wantErrors) {
+		t.Errorf("expected errors %+v, got %+v", wantErrors, res.Errors)
+	}
+	if r := res.Results[1]; r.Code != "insufficient_quantity" || r.Available != "1" {
+		t.Errorf("expected insufficient_quantity with available 1, got %+v", r)
+	}
+	if got, _ := store.GetHeldQuantity(ctx, database, chair.ID, ana.ID); got != 1 {
+		t.Errorf("expected Ana to hold 1 chair after ingest, got %d", got)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/transfers/ingest", token, map[string]any{"item_id": chair.ID})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for application/json body, got %d", resp.StatusCode)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"password", http.StatusOK},
+		{"wrong", http.StatusUnauthorized},
+		{"", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, _ := authRequest("POST", server.URL+"/api/auth/verify-password", token, map[string]string{"password": tt.password})
+		resp, _ := http.DefaultClient.Do(req)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("password %q: expected %d, got %d", tt.password, tt.want, resp.StatusCode)
+		}
+	}
+
+	// The token stays valid after a failed verification.
+	req, _ := authRequest("GET", server.URL+"/api/items", token, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected token to remain valid, got %d", resp.StatusCode)
+	}
+}
+
+func TestPasswordChangeRejectsOldTokens(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user, _ := store.CreateUser(ctx, database, "alice", string(hash), model.RoleUser)
+	oldToken, _ := auth.GenerateToken(testJWTSecret, user.ID, "alice", model.RoleUser)
+
+	req, _ := authRequest("PUT", server.URL+"/api/auth/password", oldToken, map[string]string{
+		"current_password": "password",
+		"new_password":     "newpassword",
+	})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for password change, got %d", resp.StatusCode)
+	}
+	var changeResp map[string]string
+	json.NewDecoder(resp.Body).Decode(&changeResp)
+	resp.Body.Close()
+	if changeResp["token"] == "" {
+		t.Fatal("expected a fresh token in the password change response")
+	}
+
+	// Tokens have second precision, so move the change past the old token's
+	// iat instead of sleeping.
+	database.Exec(`UPDATE users SET password_changed_at = datetime('now', '+1 minute') WHERE id = ?`, user.ID)
+
+	req, _ = authRequest("GET", server.URL+"/api/items", oldToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for token issued before the change, got %d", resp.StatusCode)
+	}
+
+	newToken, _ := auth.GenerateToken(testJWTSecret, user.ID, "alice", model.RoleUser)
+	database.Exec(`UPDATE users SET password_changed_at = datetime('now', '-1 minute') WHERE id = ?`, user.ID)
+	req, _ = authRequest("GET", server.URL+"/api/items", newToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for token issued after the change, got %d", resp.StatusCode)
+	}
+}
+
+func TestFreeTextLengthLimits(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, "admin", model.RoleAdmin)
+	item, _ := store.CreateItem(ctx, database, "Cable", "", "")
+	from, _ := store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := store.CreateOwner(ctx, database, "Bob", model.OwnerTypePerson)
+	store.AddStock(ctx, database, item.ID, from.ID, 10, false, nil)
+
+	do := func(method, path string, body any) int {
+		t.Helper()
+		req, _ := authRequest(method, server.URL+path, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	transfer := func(notes string) int {
+		return do("POST", "/api/transfers", map[string]any{
+			"item_id": item.ID, "from_owner_id": from.ID, "to_owner_id": to.ID, "quantity": 1, "notes": notes,
+		})
+	}
+	update := func(description string) int {
+		return do("PUT", fmt.Sprintf("/api/items/%d", item.ID), map[string]string{"name": "Cable", "description": description})
+	}
+
+	if code := transfer(strings.Repeat("n", model.MaxNotesLength)); code != http.StatusCreated {
+		t.Errorf("expected 201 for notes at the limit, got %d", code)
+	}
+	if code := transfer(strings.Repeat("n", model.MaxNotesLength+1)); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for notes over the limit, got %d", code)
+	}
+	if code := update(strings.Repeat("d", model.MaxDescriptionLength)); code != http.StatusOK {
+		t.Errorf("expected 200 for description at the limit, got %d", code)
+	}
+	if code := update(strings.Repeat("d", model.MaxDescriptionLength+1)); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for description over the limit, got %d", code)
+	}
+}
+
+func TestValidateTransfer(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "alice", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, "alice", model.RoleUser)
+	item, _ := store.CreateItem(ctx, database, "Cable", "", "")
+	from, _ := store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := store.CreateOwner(ctx, database, "Bob", model.OwnerTypePerson)
+	store.AddStock(ctx, database, item.ID, from.ID, 5, false, nil)
+
+	validate := func(quantity int) map[string]any {
+		t.Helper()
+		req, _ := authRequest("POST", server.URL+"/api/transfers/validate", token, map[string]any{
+			"item_id": item.ID, "from_owner_id": from.ID, "to_owner_id": to.ID, "quantity": quantity,
+		})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("validate transfer: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var result map[string]any
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result
+	}
+
+	ok := validate(3)
+	if ok["valid"] != true || ok["from_quantity"] != float64(2) || ok["to_quantity"] != float64(3) {
+		t.Errorf("expected valid transfer leaving 2/3, got %v", ok)
+	}
+
+	short := validate(6)
+	if short["valid"] != false || short["code"] != "insufficient_quantity" ||
+		short["available"] != float64(5) || short["requested"] != float64(6) {
+		t.Errorf("expected insufficient quantity failure, got %v", short)
+	}
+
+	inv, _ := store.GetOwnerInventory(ctx, database, from.ID)
+	if len(inv) != 1 || inv[0].Quantity != 5 {
+		t.Errorf("expected dry run to leave stock untouched, got %v", inv)
+	}
+}
+
+func TestPaginationLinksKeepStrippedPrefix(t *testing.T) {
+	database := db.NewTestDB(t)
+	mux := http.NewServeMux()
+	mux.Handle("/skladisce/", http.StripPrefix("/skladisce", NewRouter(database, testJWTSecret, Options{})))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	user, _ := store.CreateUser(context.Background(), database, "alice", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, "alice", model.RoleUser)
+
+	req, _ := authRequest("GET", server.URL+"/skladisce/api/transfers?limit=10", token, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("list transfers: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if link := resp.Header.Get("Link"); !strings.Contains(link, "</skladisce/api/transfers?limit=10&offset=0>") {
+		t.Errorf("expected links under /skladisce, got %q", link)
+	}
+}
+
+func TestForcedPasswordChangeGate(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user, _ := store.CreateUser(ctx, database, "admin", string(hash), model.RoleAdmin)
+	store.SetMustChangePassword(ctx, database, user.ID, true)
+
+	login := func(password string) map[string]any {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"username": "admin", "password": password})
+		resp, err := http.Post(server.URL+"/api/auth/login", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 for login, got %d", resp.StatusCode)
+		}
+		var result map[string]any
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result
+	}
+	do := func(method, path, token string, body any) (int, map[string]any) {
+		t.Helper()
+		req, _ := authRequest(method, server.URL+path, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var result map[string]any
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	first := login("password")
+	if first["must_change_password"] != true {
+		t.Fatalf("expected login to report must_change_password, got %v", first)
+	}
+	token := first["token"].(string)
+
+	code, body := do("GET", "/api/items", token, nil)
+	if code != http.StatusForbidden || body["code"] != "password_change_required" {
+		t.Errorf("expected 403 password_change_required, got %d %v", code, body)
+	}
+	if code, _ := do("POST", "/api/users", token, map[string]string{"username": "x", "password": "password1", "role": "user"}); code != http.StatusForbidden {
+		t.Errorf("expected 403 for admin action before the change, got %d", code)
+	}
+
+	code, body = do("PUT", "/api/auth/password", token, map[string]string{
+		"current_password": "password",
+		"new_password":     "newpassword",
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 for password change, got %d %v", code, body)
+	}
+	if code, _ := do("GET", "/api/items", body["token"].(string), nil); code != http.StatusOK {
+		t.Errorf("expected 200 with the new token, got %d", code)
+	}
+
+	if again := login("newpassword"); again["must_change_password"] != nil {
+		t.Errorf("expected flag to be cleared after the change, got %v", again)
+	}
+}
+
+func TestReadRole(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "clerk", "hash", model.RoleUser)
+	manager, _ := store.CreateUser(ctx, database, "boss", "hash", model.RoleManager)
+	item, _ := store.CreateItem(ctx, database, "Ladder", "", "")
+	owner, _ := store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	store.AddStock(ctx, database, item.ID, owner.ID, 1, false, nil)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, "clerk", model.RoleUser)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, "boss", model.RoleManager)
+
+	paths := []string{
+		"/api/items",
+		fmt.Sprintf("/api/items/%d", item.ID),
+		"/api/owners",
+		fmt.Sprintf("/api/owners/%d/inventory", owner.ID),
+		"/api/inventory",
+		"/api/transfers",
+		"/api/search?q=ladder",
+	}
+	get := func(server *httptest.Server, path, token string) int {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+path, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	open := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(open.Close)
+	gated := httptest.NewServer(NewRouter(database, testJWTSecret, Options{ReadRole: model.RoleManager}))
+	t.Cleanup(gated.Close)
+
+	for _, path := range paths {
+		if code := get(open, path, userToken); code != http.StatusOK {
+			t.Errorf("default: expected 200 for user on %s, got %d", path, code)
+		}
+		if code := get(gated, path, userToken); code != http.StatusForbidden {
+			t.Errorf("read-role manager: expected 403 for user on %s, got %d", path, code)
+		}
+		if code := get(gated, path, managerToken); code != http.StatusOK {
+			t.Errorf("read-role manager: expected 200 for manager on %s, got %d", path, code)
+		}
+	}
+
+	// Validating a transfer reveals both balances, so it is gated too.
+	req, _ := authRequest("POST", gated.URL+"/api/transfers/validate", userToken, map[string]any{
+		"item_id": item.ID, "from_owner_id": owner.ID, "to_owner_id": owner.ID, "quantity": 1,
+	})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("read-role manager: expected 403 for user on transfer validation, got %d", resp.StatusCode)
+	}
+
+	// Reads outside the inventory stay open.
+	if code := get(gated, "/api/statuses", userToken); code != http.StatusOK {
+		t.Errorf("expected 200 for user on statuses, got %d", code)
+	}
+}
+
+func TestItemHistoryExportCSV(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{ReportCooldown: -1}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	clerk, _ := store.CreateUser(ctx, database, "clerk", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, "admin", model.RoleAdmin)
+	item, _ := store.CreateItem(ctx, database, "Drill", "", "")
+	unused, _ := store.CreateItem(ctx, database, "Saw", "", "")
+	storage, _ := store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	van, _ := store.CreateOwner(ctx, database, "Van", model.OwnerTypeLocation)
+	store.AddStock(ctx, database, item.ID, storage.ID, 4, false, nil)
+	store.CreateTransfer(ctx, database, item.ID, storage.ID, van.ID, 3, false, "site, north", &clerk.ID)
+	store.CreateTransfer(ctx, database, item.ID, van.ID, storage.ID, 1, false, "", &admin.ID)
+	database.Exec(`INSERT INTO inventory_adjustments (item_id, owner_id, delta, kind, reason, user_id, created_at)
+		VALUES (?, ?, -2, 'decommissioned', 'worn out', ?, datetime('now', '+1 minute'))`, item.ID, van.ID, admin.ID)
+
+	export := func(id int64) [][]string {
+		t.Helper()
+		req, _ := authRequest("GET", fmt.Sprintf("%s/api/items/%d/history/export?format=csv", server.URL, id), token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Errorf("expected text/csv, got %q", ct)
+		}
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("parsing CSV: %v", err)
+		}
+		return records
+	}
+
+	// Oldest first; both transfers share a timestamp, so order by ID.
+	transfers, _ := store.GetItemHistory(ctx, database, item.ID)
+	slices.SortFunc(transfers, func(a, b model.Transfer) int { return cmp.Compare(a.ID, b.ID) })
+	records := export(item.ID)
+	if len(records) != len(transfers)+2 {
+		t.Fatalf("expected header, %d transfers and 1 adjustment, got %v", len(transfers), records)
+	}
+	if !slices.Equal(records[0], []string{"date", "from", "to", "quantity", "notes", "user"}) {
+		t.Errorf("unexpected header: %v", records[0])
+	}
+	users := map[int64]string{admin.ID: "admin", clerk.ID: "clerk"}
+	for i, tr := range transfers {
+		want := []string{tr.TransferredAt.UTC().Format(time.RFC3339), tr.FromOwnerName, tr.ToOwnerName,
+			strconv.Itoa(tr.Quantity), tr.Notes, users[*tr.TransferredBy]}
+		if got := records[i+1]; !slices.Equal(got, want) {
+			t.Errorf("row %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+	if got := records[len(records)-1]; got[1] != "Van" || got[2] != "decommissioned" || got[3] != "2" || got[4] != "worn out" || got[5] != "admin" {
+		t.Errorf("unexpected adjustment row: %v", got)
+	}
+
+	// An item without history is just the header.
+	if records := export(unused.ID); len(records) != 1 {
+		t.Errorf("expected only the header, got %v", records)
+	}
+
+	req, _ := authRequest("GET", fmt.Sprintf("%s/api/items/%d/history/export?format=pdf", server.URL, item.ID), token, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for pdf, got %d", resp.StatusCode)
+	}
+}
+
+func TestAuditExportCSV(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{ReportCooldown: -1}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	clerk, _ := store.CreateUser(ctx, database, "clerk", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, "admin", model.RoleAdmin)
+	item, _ := store.CreateItem(ctx, database, "Ladder", "", "")
+	from, _ := store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := store.CreateOwner(ctx, database, "Van", model.OwnerTypeLocation)
+	store.AddStock(ctx, database, item.ID, from.ID, 4, false, nil)
+	store.CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 1, false, `said "ok", then left`, &clerk.ID)
+	store.CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 2, false, "", &admin.ID)
+
+	export := func(query string) [][]string {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"/api/audit/export?"+query, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Errorf("expected text/csv, got %q", ct)
+		}
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("parsing CSV: %v", err)
+		}
+		return records
+	}
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	rows := export(fmt.Sprintf("format=csv&from=%s&to=%s&user_id=%d", today, today, clerk.ID))
+	if len(rows) != 2 {
+		t.Fatalf("expected header and one row, got %v", rows)
+	}
+	if rows[0][0] != "at" || rows[0][9] != "reason" {
+		t.Errorf("unexpected header: %v", rows[0])
+	}
+	row := rows[1]
+	if row[1] != "transfer" || row[4] != "clerk" || row[7] != "Ladder" ||
+		row[8] != "1 from Storage to Van" || row[9] != `said "ok", then left` {
+		t.Errorf("unexpected row: %v", row)
+	}
+
+	if rows := export("from=" + today); len(rows) != 3 {
+		t.Errorf("expected both transfers for today, got %d rows", len(rows)-1)
+	}
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+	if rows := export("to=" + yesterday); len(rows) != 1 {
+		t.Errorf("expected header only before today, got %v", rows)
+	}
+
+	req, _ := authRequest("GET", server.URL+"/api/audit/export?format=json", token, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for format=json, got %d", resp.StatusCode)
+	}
+}
+
+func TestItemConditionPersistsAndIsSearchable(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	admin, _ := store.CreateUser(context.Background(), database, "admin", "hash", model.RoleAdmin)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, "admin", model.RoleAdmin)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{
+		"name": "Cooler", "description": "Blue 20 l cooler", "condition": "scratched lid, works fine",
+	})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var created model.Item
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.Condition != "scratched lid, works fine" {
+		t.Fatalf("expected 201 with condition, got %d %q", resp.StatusCode, created.Condition)
+	}
+
+	req, _ = authRequest("PUT", fmt.Sprintf("%s/api/items/%d", server.URL, created.ID), token, map[string]string{
+		"name": "Cooler", "description": "Blue 20 l cooler", "condition": "handle missing",
+	})
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from update, got %d", resp.StatusCode)
+	}
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/items/%d", server.URL, created.ID), token, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	var got struct {
+		Item model.Item `json:"item"`
+	}
+	json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	if got.Item.Condition != "handle missing" {
+		t.Errorf("expected updated condition from GET, got %q", got.Item.Condition)
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/items?q=HANDLE", token, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	var found []model.Item
+	json.NewDecoder(resp.Body).Decode(&found)
+	resp.Body.Close()
+	if len(found) != 1 || found[0].ID != created.ID {
+		t.Errorf("expected search by condition to find the item, got %v", found)
+	}
+
+	req, _ = authRequest("PUT", fmt.Sprintf("%s/api/items/%d", server.URL, created.ID), token, map[string]string{
+		"name": "Cooler", "condition": strings.Repeat("c", model.MaxConditionLength+1),
+	})
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for condition over the limit, got %d", resp.StatusCode)
+	}
+}
+
+func TestListItemsHasImage(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, "user", model.RoleUser)
+
+	lamp, _ := store.CreateItem(ctx, database, "Lamp", "", "")
+	store.CreateItem(ctx, database, "Rope", "", "")
+	tent, _ := store.CreateItem(ctx, database, "Tent", "", "")
+	store.SetItemImage(ctx, database, lamp.ID, []byte("fake image data"), "image/png")
+	store.SetItemImage(ctx, database, tent.ID, []byte("fake image data"), "image/png")
+	store.UpdateItem(ctx, database, tent.ID, "Tent", "", "", model.ItemStatusDamaged, "", nil)
+
+	list := func(query string) (int, []string) {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"/api/items?"+query, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", query, err)
+		}
+		defer resp.Body.Close()
+		var items []model.Item
+		json.NewDecoder(resp.Body).Decode(&items)
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Name)
+		}
+		return resp.StatusCode, names
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"has_image=false", []string{"Rope"}},
+		{"has_image=true", []string{"Lamp", "Tent"}},
+		{"has_image=true&status=" + model.ItemStatusActive, []string{"Lamp"}},
+		{"has_image=true&q=ten", []string{"Tent"}},
+		{"has_image=false&q=ten", nil},
+	} {
+		if code, names := list(tc.query); code != http.StatusOK || !slices.Equal(names, tc.want) {
+			t.Errorf("%s: expected 200 %v, got %d %v", tc.query, tc.want, code, names)
+		}
+	}
+	if code, _ := list("has_image=maybe"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid has_image, got %d", code)
+	}
+}
+
+func TestBatchGetItems(t *testing.T) {
+	database := db.NewTestDB(t)
+	readOnly := NewReadOnlyMode(true)
+	server := httptest.NewServer(readOnly.Middleware(NewRouter(database, testJWTSecret, Options{ReadOnly: readOnly})))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, "user", model.RoleUser)
+
+	tent, _ := store.CreateItem(ctx, database, "Tent", "", "")
+	stove, _ := store.CreateItem(ctx, database, "Stove", "", "")
+	gone, _ := store.CreateItem(ctx, database, "Lantern", "", "")
+	store.SetItemImage(ctx, database, tent.ID, []byte("fake image data"), "image/png")
+	store.DeleteItem(ctx, database, gone.ID, nil, "")
+
+	batchGet := func(body any) (int, map[string]any) {
+		t.Helper()
+		req, _ := authRequest("POST", server.URL+"/api/items/batch-get", token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST batch-get: %v", err)
+		}
+		defer resp.Body.Close()
+		var got map[string]any
+		json.NewDecoder(resp.Body).Decode(&got)
+		return resp.StatusCode, got
+	}
+
+	// Works in read-only mode, keeps request order and drops duplicates.
+	code, got := batchGet(map[string]any{"ids": []int64{stove.ID, 9999, tent.ID, gone.ID, stove.ID}})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %v", code, got)
+	}
+	items, _ := got["items"].([]any)
+	var names []string
+	for _, item := range items {
+		item := item.(map[string]any)
+		names = append(names, item["name"].(string))
+		if _, ok := item["image"]; ok {
+			t.Errorf("expected no image data in %v", item)
+		}
+	}
+	if !slices.Equal(names, []string{"Stove", "Tent"}) {
+		t.Errorf("expected Stove and Tent in request order, got %v", names)
+	}
+	if notFound, _ := got["not_found"].([]any); !slices.Equal(notFound, []any{float64(9999), float64(gone.ID)}) {
+		t.Errorf("expected 9999 and the deleted item not found, got %v", got["not_found"])
+	}
+
+	if code, got := batchGet(map[string]any{"ids": []int64{9999}}); code != http.StatusOK || len(got["items"].([]any)) != 0 {
+		t.Errorf("expected 200 with no items, got %d %v", code, got)
+	}
+	for _, body := range []any{
+		map[string]any{},
+		map[string]any{"ids": []int64{}},
+		map[string]any{"skus": []string{"TENT-1"}},
+		map[string]any{"ids": make([]int64, maxBatchGetItems+1)},
+	} {
+		if code, got := batchGet(body); code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d %v", body, code, got)
+		}
+	}
+}
+
+func TestSuggestItems(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, "user", model.RoleUser)
+
+	drill, _ := store.CreateItem(ctx, database, "Drill", "a long description", "")
+	hammer, _ := store.CreateItem(ctx, database, "Hammer drill", "", "")
+	store.CreateItem(ctx, database, "Saw", "drill-free", "")
+	gone, _ := store.CreateItem(ctx, database, "Drill bits", "", "")
+	store.SetItemImage(ctx, database, drill.ID, []byte("fake image data"), "image/png")
+	store.DeleteItem(ctx, database, gone.ID, nil, "")
+
+	suggest := func(query string) (int, []map[string]any) {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"/api/items/suggest?"+query, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET suggest: %v", err)
+		}
+		defer resp.Body.Close()
+		var got []map[string]any
+		json.NewDecoder(resp.Body).Decode(&got)
+		return resp.StatusCode, got
+	}
+
+	code, got := suggest("q=DRI")
+	want := []map[string]any{
+		{"id": float64(drill.ID), "name": "Drill", "has_image": true},
+		{"id": float64(hammer.ID), "name": "Hammer drill", "has_image": false},
+	}
+	if code != http.StatusOK || !slices.EqualFunc(got, want, maps.Equal) {
+		t.Errorf("expected 200 %v, got %d %v", want, code, got)
+	}
+	if _, got := suggest("q=dri&limit=1"); len(got) != 1 || got[0]["name"] != "Drill" {
+		t.Errorf("expected only Drill with limit=1, got %v", got)
+	}
+	for _, query := range []string{"", "q=%20", "q=dri&limit=0"} {
+		if code, _ := suggest(query); code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, code)
+		}
+	}
+}
+
+func TestFulfillTransfer(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, "user", model.RoleUser)
+	item, _ := store.CreateItem(ctx, database, "Chair", "", "")
+	hall, _ := store.CreateOwner(ctx, database, "Hall", model.OwnerTypeLocation)
+	attic, _ := store.CreateOwner(ctx, database, "Attic", model.OwnerTypeLocation)
+	alice, _ := store.CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	store.AddStock(ctx, database, item.ID, hall.ID, 5, false, nil)
+	store.AddStock(ctx, database, item.ID, attic.ID, 3, false, nil)
+
+	fulfill := func(quantity int) *http.Response {
+		t.Helper()
+		req, _ := authRequest("POST", server.URL+"/api/transfers/fulfill", token, map[string]any{
+			"item_id": item.ID, "to_owner_id": alice.ID, "quantity": quantity,
+		})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("fulfill: %v", err)
+		}
+		return resp
+	}
+
+	resp := fulfill(9)
+	var insufficient map[string]any
+	json.NewDecoder(resp.Body).Decode(&insufficient)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || insufficient["code"] != "insufficient_quantity" || insufficient["available"] != float64(8) {
+		t.Errorf("expected 400 insufficient_quantity with available=8, got %d %v", resp.StatusCode, insufficient)
+	}
+
+	resp = fulfill(7)
+	var transfers []model.Transfer
+	json.NewDecoder(resp.Body).Decode(&transfers)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if len(transfers) != 2 || transfers[0].FromOwnerName != "Hall" || transfers[0].Quantity != 5 ||
+		transfers[1].FromOwnerName != "Attic" || transfers[1].Quantity != 2 {
+		t.Errorf("expected 5 from Hall and 2 from Attic, got %+v", transfers)
+	}
+	if transfers[0].TransferredBy == nil || *transfers[0].TransferredBy != user.ID {
+		t.Errorf("expected transfers attributed to the caller, got %v", transfers[0].TransferredBy)
+	}
+}
+
+func TestTransferTemplates(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, "manager", model.RoleManager)
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, "user", model.RoleUser)
+	item, _ := store.CreateItem(ctx, database, "Towel", "", "")
+	desk, _ := store.CreateOwner(ctx, database, "Front desk", model.OwnerTypeLocation)
+	storage, _ := store.CreateOwner(ctx, database, "Main storage", model.OwnerTypeLocation)
+	store.AddStock(ctx, database, item.ID, desk.ID, 5, false, nil)
+
+	do := func(method, path, token string, body any, into any) int {
+		t.Helper()
+		req, _ := authRequest(method, server.URL+path, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		if into != nil {
+			json.NewDecoder(resp.Body).Decode(into)
+		}
+		return resp.StatusCode
+	}
+
+	returns := map[string]any{"label": "  Weekly   returns ", "from_owner_id": desk.ID, "to_owner_id": storage.ID}
+	if status := do("POST", "/api/transfer-templates", userToken, returns, nil); status != http.StatusForbidden {
+		t.Errorf("expected 403 for a user creating a template, got %d", status)
+	}
+	same := map[string]any{"label": "Loop", "from_owner_id": desk.ID, "to_owner_id": desk.ID}
+	if status := do("POST", "/api/transfer-templates", managerToken, same, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for a template with the same owners, got %d", status)
+	}
+	var template model.TransferTemplate
+	if status := do("POST", "/api/transfer-templates", managerToken, returns, &template); status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", status)
+	}
+	if template.Label != "Weekly returns" || template.FromOwnerName != "Front desk" || template.ToOwnerName != "Main storage" {
+		t.Errorf("unexpected template: %+v", template)
+	}
+
+	apply := fmt.Sprintf("/api/transfer-templates/%d/apply", template.ID)
+	var transfer model.Transfer
+	if status := do("POST", apply, userToken, map[string]any{"item_id": item.ID, "quantity": 3}, &transfer); status != http.StatusCreated {
+		t.Fatalf("expected 201 applying the template, got %d", status)
+	}
+	if transfer.FromOwnerID != desk.ID || transfer.ToOwnerID != storage.ID || transfer.Quantity != 3 ||
+		transfer.Notes != "Weekly returns" || transfer.TransferredBy == nil || *transfer.TransferredBy != user.ID {
+		t.Errorf("unexpected transfer: %+v", transfer)
+	}
+	if q, _ := store.GetItemDistribution(ctx, database, item.ID); len(q) != 2 {
+		t.Errorf("expected stock at both owners, got %+v", q)
+	}
+
+	var insufficient map[string]any
+	if status := do("POST", apply, userToken, map[string]any{"item_id": item.ID, "quantity": 3, "notes": "More"}, &insufficient); status != http.StatusBadRequest ||
+		insufficient["code"] != "insufficient_quantity" {
+		t.Errorf("expected 400 insufficient_quantity, got %d %v", status, insufficient)
+	}
+
+	var templates []model.TransferTemplate
+	if status := do("GET", "/api/transfer-templates", userToken, nil, &templates); status != http.StatusOK || len(templates) != 1 {
+		t.Errorf("expected one template, got %d %+v", status, templates)
+	}
+	if status := do("DELETE", fmt.Sprintf("/api/transfer-templates/%d", template.ID), managerToken, nil, nil); status != http.StatusOK {
+		t.Errorf("expected 200 deleting the template, got %d", status)
+	}
+	if status := do("POST", apply, userToken, map[string]any{"item_id": item.ID, "quantity": 1}, nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 applying a deleted template, got %d", status)
+	}
+}
+
+func TestUploadImageTooLarge(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+	item, _ := store.CreateItem(ctx, database, "Poster", "", "")
+	imageURL := fmt.Sprintf("%s/api/items/%d/image", server.URL, item.ID)
+
+	upload := func(data []byte) (int, string) {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("image", "poster.jpg")
+		part.Write(data)
+		mw.Close()
+
+		req, _ := http.NewRequest("PUT", imageURL, &body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+		defer resp.Body.Close()
+		var errResp map[string]string
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return resp.StatusCode, errResp["error"]
+	}
+
+	status, msg := upload(make([]byte, imaging.MaxUploadSize+1))
+	if status != http.StatusRequestEntityTooLarge || msg != "image exceeds 5 MB" {
+		t.Errorf("expected 413 'image exceeds 5 MB', got %d %q", status, msg)
+	}
+
+	status, _ = upload([]byte("not an image"))
+	if status != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid image, got %d", status)
+	}
+}
+
+func TestUploadSameImageUnchanged(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+	item, _ := store.CreateItem(ctx, database, "Poster", "", "")
+
+	upload := func(id int64, width int) (int, string) {
+		t.Helper()
+		var img bytes.Buffer
+		jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, width, 100)), nil)
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("image", "poster.jpg")
+		part.Write(img.Bytes())
+		mw.Close()
+
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/items/%d/image", server.URL, id), &body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+		defer resp.Body.Close()
+		var result map[string]string
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result["message"]
+	}
+
+	if status, msg := upload(item.ID, 200); status != http.StatusOK || msg != "image uploaded" {
+		t.Fatalf("expected 200 'image uploaded', got %d %q", status, msg)
+	}
+	// Backdate the item so a second write would show in updated_at.
+	database.Exec(`UPDATE items SET updated_at = '2000-01-01 00:00:00' WHERE id = ?`, item.ID)
+
+	if status, msg := upload(item.ID, 200); status != http.StatusOK || msg != "image unchanged" {
+		t.Fatalf("expected 200 'image unchanged', got %d %q", status, msg)
+	}
+	got, _ := store.GetItem(ctx, database, item.ID)
+	if got.UpdatedAt.Year() != 2000 {
+		t.Errorf("expected no write for the same image, updated_at is %v", got.UpdatedAt)
+	}
+
+	if status, msg := upload(item.ID, 300); status != http.StatusOK || msg != "image uploaded" {
+		t.Errorf("expected a different image to be written, got %d %q", status, msg)
+	}
+	if status, _ := upload(9999, 200); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing item, got %d", status)
+	}
+}
+
+func TestSetItemMinQuantity(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	item, _ := store.CreateItem(ctx, database, "Batteries", "", "")
+	url := fmt.Sprintf("%s/api/items/%d/min-quantity", server.URL, item.ID)
+
+	set := func(token string, body map[string]any) (int, model.Item) {
+		t.Helper()
+		req, _ := authRequest("PUT", url, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT min-quantity: %v", err)
+		}
+		defer resp.Body.Close()
+		var got model.Item
+		json.NewDecoder(resp.Body).Decode(&got)
+		return resp.StatusCode, got
+	}
+
+	status, got := set(managerToken, map[string]any{"min_quantity": 5})
+	if status != http.StatusOK || got.MinQuantity == nil || *got.MinQuantity != 5 {
+		t.Fatalf("expected 200 with min_quantity 5, got %d %+v", status, got)
+	}
+
+	if status, _ := set(managerToken, map[string]any{"min_quantity": 0}); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for min_quantity 0, got %d", status)
+	}
+	if status, _ := set(userToken, map[string]any{"min_quantity": 3}); status != http.StatusForbidden {
+		t.Errorf("expected 403 for user role, got %d", status)
+	}
+
+	status, got = set(managerToken, map[string]any{"min_quantity": nil})
+	if status != http.StatusOK || got.MinQuantity != nil {
+		t.Errorf("expected threshold removed, got %d %+v", status, got)
+	}
+}
+
+func TestReorderReport(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	token, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+	storage, _ := store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	gloves, _ := store.CreateItem(ctx, database, "Gloves", "", "")
+	oil, _ := store.CreateItem(ctx, database, "Oil", "", "")
+	store.SetItemDivisible(ctx, database, oil.ID, true)
+	store.AddStock(ctx, database, gloves.ID, storage.ID, 2, false, nil)
+	store.AddStock(ctx, database, oil.ID, storage.ID, 1500, true, nil)
+
+	put := func(id int64, path string, body map[string]any) (int, model.Item) {
+		t.Helper()
+		req, _ := authRequest("PUT", fmt.Sprintf("%s/api/items/%d/%s", server.URL, id, path), token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var item model.Item
+		json.NewDecoder(resp.Body).Decode(&item)
+		return resp.StatusCode, item
+	}
+	put(gloves.ID, "min-quantity", map[string]any{"min_quantity": 5})
+	status, item := put(gloves.ID, "reorder-target", map[string]any{"reorder_target": 20})
+	if status != http.StatusOK || item.ReorderTarget == nil || *item.ReorderTarget != 20 {
+		t.Fatalf("expected 200 with reorder_target 20, got %d %+v", status, item)
+	}
+	put(oil.ID, "min-quantity", map[string]any{"min_quantity": 2})
+	put(oil.ID, "reorder-target", map[string]any{"reorder_target": 4.25})
+	if status, _ := put(gloves.ID, "reorder-target", map[string]any{"reorder_target": 0}); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for reorder_target 0, got %d", status)
+	}
+
+	req, _ := authRequest("GET", server.URL+"/api/reports/reorder", token, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET reorder: %v", err)
+	}
+	defer resp.Body.Close()
+	var lines []map[string]any
+	json.NewDecoder(resp.Body).Decode(&lines)
+	if resp.StatusCode != http.StatusOK || len(lines) != 2 {
+		t.Fatalf("expected 2 reorder lines, got %d %v", resp.StatusCode, lines)
+	}
+	if l := lines[0]; l["item_name"] != "Gloves" || l["quantity"] != float64(2) || l["suggested"] != float64(18) {
+		t.Errorf("expected 18 gloves to order, got %v", l)
+	}
+	if l := lines[1]; l["item_name"] != "Oil" || l["quantity"] != 1.5 || l["suggested"] != 2.75 {
+		t.Errorf("expected 2.75 oil to order, got %v", l)
+	}
+
+	// The report is rate limited like the other reports.
+	req, _ = authRequest("GET", server.URL+"/api/reports/reorder", token, nil)
+	again, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET reorder: %v", err)
+	}
+	again.Body.Close()
+	if again.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429 within the cooldown, got %d", again.StatusCode)
+	}
+
+	status, item = put(gloves.ID, "reorder-target", map[string]any{"reorder_target": nil})
+	if status != http.StatusOK || item.ReorderTarget != nil {
+		t.Errorf("expected target removed, got %d %+v", status, item)
+	}
+}
+
+func TestReassignUserRecords(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	leaver, _ := store.CreateUser(ctx, database, "leaver", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+
+	item, _ := store.CreateItem(ctx, database, "Ladder", "", "")
+	shed, _ := store.CreateOwner(ctx, database, "Shed", model.OwnerTypeLocation)
+	carol, _ := store.CreateOwner(ctx, database, "Carol", model.OwnerTypePerson)
+	store.AddStock(ctx, database, item.ID, shed.ID, 2, false, nil)
+	store.CreateTransfer(ctx, database, item.ID, shed.ID, carol.ID, 1, false, "", &leaver.ID)
+
+	reassign := func(id int64, body map[string]any) *http.Response {
+		t.Helper()
+		req, _ := authRequest("POST", fmt.Sprintf("%s/api/users/%d/reassign", server.URL, id), token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("reassign: %v", err)
+		}
+		return resp
+	}
+
+	resp := reassign(leaver.ID, map[string]any{"to": admin.ID, "delete": true})
+	var result model.ReassignResult
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || result.Transfers != 1 || !result.Deleted {
+		t.Fatalf("expected 200 with 1 transfer and deleted, got %d %+v", resp.StatusCode, result)
+	}
+
+	for _, tt := range []struct {
+		id   int64
+		body map[string]any
+		want int
+	}{
+		{leaver.ID, map[string]any{}, http.StatusBadRequest},
+		{admin.ID, map[string]any{"to": admin.ID}, http.StatusBadRequest},
+		{admin.ID, map[string]any{"to": leaver.ID}, http.StatusNotFound},
+		{9999, map[string]any{"to": admin.ID}, http.StatusNotFound},
+	} {
+		resp := reassign(tt.id, tt.body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("reassign %d %v: expected %d, got %d", tt.id, tt.body, tt.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestCanEndpoint(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	tokens := map[string]string{}
+	for _, role := range []string{model.RoleUser, model.RoleManager, model.RoleAdmin} {
+		u, _ := store.CreateUser(ctx, database, role, "hash", role)
+		tokens[role], _ = auth.GenerateToken(testJWTSecret, u.ID, u.Username, u.Role)
+	}
+
+	can := func(role, query string) (int, map[string]any) {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"/api/auth/can?"+query, tokens[role], nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("can: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	tests := []struct {
+		role, action string
+		want         bool
+	}{
+		{model.RoleUser, model.ActionCreateTransfer, true},
+		{model.RoleUser, model.ActionCreateItem, false},
+		{model.RoleManager, model.ActionCreateItem, true},
+		{model.RoleManager, model.ActionManageUsers, false},
+		{model.RoleAdmin, model.ActionManageUsers, true},
+	}
+	for _, tt := range tests {
+		status, body := can(tt.role, "action="+tt.action)
+		if status != http.StatusOK || body["allowed"] != tt.want {
+			t.Errorf("%s %s: expected allowed=%v, got %d %v", tt.role, tt.action, tt.want, status, body)
+		}
+	}
+
+	// The answer matches what the route enforces.
+	req, _ := authRequest("POST", server.URL+"/api/items", tokens[model.RoleUser], map[string]any{"name": "Nope"})
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 creating an item as user, got %d", resp.StatusCode)
+	}
+
+	status, body := can(model.RoleManager, "action=create_item&action=manage_users")
+	actions, _ := body["actions"].(map[string]any)
+	if status != http.StatusOK || body["allowed"] != false || actions["create_item"] != true || actions["manage_users"] != false {
+		t.Errorf("unexpected batch answer: %d %v", status, body)
+	}
+
+	if status, _ := can(model.RoleAdmin, "action=launch_rocket"); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown action, got %d", status)
+	}
+	if status, _ := can(model.RoleAdmin, ""); status != http.StatusBadRequest {
+		t.Errorf("expected 400 without action, got %d", status)
+	}
+}
+
+func TestSerialTransfers(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	token, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+	room, _ := store.CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	ana, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	laptop, _ := store.CreateItem(ctx, database, "Laptop", "", "")
+
+	do := func(method, url string, body any, out any) int {
+		t.Helper()
+		req, _ := authRequest(method, server.URL+url, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, url, err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+	itemURL := fmt.Sprintf("/api/items/%d", laptop.ID)
+
+	if status := do("POST", itemURL+"/serials", map[string]any{"owner_id": room.ID, "serials": []string{"SN1"}}, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 assigning serials to a quantity item, got %d", status)
+	}
+	var item model.Item
+	if status := do("PUT", itemURL+"/serialized", map[string]any{"serialized": true}, &item); status != http.StatusOK || !item.Serialized {
+		t.Fatalf("expected item to become serialized, got %d %+v", status, item)
+	}
+
+	var assigned []model.Serial
+	status := do("POST", itemURL+"/serials", map[string]any{"owner_id": room.ID, "serials": []string{" SN1 ", "SN2"}}, &assigned)
+	if status != http.StatusCreated || len(assigned) != 2 || assigned[0].Serial != "SN1" {
+		t.Fatalf("expected 2 serials assigned, got %d %+v", status, assigned)
+	}
+
+	var conflict map[string]any
+	status = do("POST", itemURL+"/serials", map[string]any{"owner_id": ana.ID, "serials": []string{"SN2"}}, &conflict)
+	if status != http.StatusConflict || conflict["code"] != "serial_assigned" || conflict["owner_id"] != float64(room.ID) {
+		t.Errorf("expected 409 serial_assigned for SN2, got %d %v", status, conflict)
+	}
+	if status := do("POST", itemURL+"/serials", map[string]any{"owner_id": room.ID, "serials": []string{"SN3", "SN3"}}, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for a repeated serial, got %d", status)
+	}
+
+	// Quantity transfers of a serialized item are refused.
+	var quantityErr map[string]any
+	status = do("POST", "/api/transfers", map[string]any{"item_id": laptop.ID, "from_owner_id": room.ID, "to_owner_id": ana.ID, "quantity": 1}, &quantityErr)
+	if status != http.StatusBadRequest || quantityErr["error"] != store.ErrSerializedItem.Error() {
+		t.Errorf("expected 400 for a quantity transfer, got %d %v", status, quantityErr)
+	}
+
+	var transfer model.Transfer
+	status = do("POST", "/api/transfers/serials", map[string]any{"item_id": laptop.ID, "from_owner_id": room.ID, "to_owner_id": ana.ID, "serials": []string{"SN1"}}, &transfer)
+	if status != http.StatusCreated || transfer.Quantity != 1 || len(transfer.Serials) != 1 || transfer.Serials[0] != "SN1" {
+		t.Fatalf("expected 201 moving SN1, got %d %+v", status, transfer)
+	}
+
+	var notHeld map[string]any
+	status = do("POST", "/api/transfers/serials", map[string]any{"item_id": laptop.ID, "from_owner_id": room.ID, "to_owner_id": ana.ID, "serials": []string{"SN1"}}, &notHeld)
+	if status != http.StatusBadRequest || notHeld["code"] != "serial_not_held" || notHeld["serial"] != "SN1" {
+		t.Errorf("expected 400 serial_not_held for SN1, got %d %v", status, notHeld)
+	}
+
+	var held []model.Serial
+	if status := do("GET", fmt.Sprintf("%s/serials?owner_id=%d", itemURL, ana.ID), nil, &held); status != http.StatusOK || len(held) != 1 || held[0].OwnerName != "Ana" {
+		t.Errorf("expected Ana to hold SN1, got %d %+v", status, held)
+	}
+	if got, _ := store.GetHeldQuantity(ctx, database, laptop.ID, ana.ID); got != 1 {
+		t.Errorf("expected Ana's quantity 1, got %d", got)
+	}
+
+	if status := do("PUT", itemURL+"/serialized", map[string]any{"serialized": false}, nil); status != http.StatusConflict {
+		t.Errorf("expected 409 switching off an item with serials, got %d", status)
+	}
+	if status := do("DELETE", itemURL+"/serials/SN2", nil, nil); status != http.StatusOK {
+		t.Errorf("expected 200 removing SN2, got %d", status)
+	}
+	if status := do("DELETE", itemURL+"/serials/SN2", nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 removing SN2 again, got %d", status)
+	}
+}
+
+func TestMaxPageSizeClampsLimit(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{MaxPageSize: 3}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	room, _ := store.CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	ana, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	item, _ := store.CreateItem(ctx, database, "Chair", "", "")
+	store.AddStock(ctx, database, item.ID, room.ID, 5, false, nil)
+	for range 5 {
+		store.CreateTransfer(ctx, database, item.ID, room.ID, ana.ID, 1, false, "", nil)
+	}
+
+	get := func(path string, out any) *http.Response {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+path, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(out)
+		return resp
+	}
+
+	var transfers []model.Transfer
+	resp := get("/api/transfers?limit=1000000", &transfers)
+	if resp.StatusCode != http.StatusOK || len(transfers) != 3 {
+		t.Fatalf("expected 200 with 3 transfers, got %d with %d", resp.StatusCode, len(transfers))
+	}
+	if got := resp.Header.Get("X-Page-Limit"); got != "3" {
+		t.Errorf("expected X-Page-Limit 3, got %q", got)
+	}
+	if link := resp.Header.Get("Link"); !strings.Contains(link, `limit=3&offset=3>; rel="next"`) {
+		t.Errorf("expected next link with the clamped limit, got %q", link)
+	}
+
+	var changelog changelogResponse
+	resp = get(fmt.Sprintf("/api/items/%d/changelog?limit=500", item.ID), &changelog)
+	if changelog.Limit != 3 || len(changelog.Entries) != 3 || !changelog.HasMore || resp.Header.Get("X-Page-Limit") != "3" {
+		t.Errorf("expected changelog clamped to 3, got limit %d, %d entries, header %q", changelog.Limit, len(changelog.Entries), resp.Header.Get("X-Page-Limit"))
+	}
+
+	var activity struct {
+		Events []model.ActivityEvent `json:"events"`
+		Limit  int                   `json:"limit"`
+	}
+	resp = get("/api/activity?limit=99", &activity)
+	if activity.Limit != 3 || resp.Header.Get("X-Page-Limit") != "3" {
+		t.Errorf("expected activity limit 3, got %d, header %q", activity.Limit, resp.Header.Get("X-Page-Limit"))
+	}
+}
+
+func TestSearchAcrossEntities(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	lab, _ := store.CreateOwner(ctx, database, "Robotics lab", model.OwnerTypeLocation)
+	ana, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	arm, _ := store.CreateItem(ctx, database, "Arm", "Spare robotics arm", "")
+	store.CreateItem(ctx, database, "Chair", "", "")
+	store.AddStock(ctx, database, arm.ID, lab.ID, 2, false, nil)
+	store.CreateTransfer(ctx, database, arm.ID, lab.ID, ana.ID, 1, false, "for the ROBOTICS workshop", nil)
+	store.CreateTransfer(ctx, database, arm.ID, ana.ID, lab.ID, 1, false, "returned", nil)
+
+	req, _ := authRequest("GET", server.URL+"/api/search?q=robotics", token, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/search: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var result searchResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result.Items.Type != "item" || len(result.Items.Results) != 1 || result.Items.Results[0].ID != arm.ID {
+		t.Errorf("expected the arm in the item group, got %+v", result.Items)
+	}
+	if result.Owners.Type != "owner" || len(result.Owners.Results) != 1 || result.Owners.Results[0].ID != lab.ID {
+		t.Errorf("expected the lab in the owner group, got %+v", result.Owners)
+	}
+	if result.Transfers.Type != "transfer" || len(result.Transfers.Results) != 1 || result.Transfers.Results[0].Notes != "for the ROBOTICS workshop" {
+		t.Errorf("expected one transfer matched by its notes, got %+v", result.Transfers)
+	}
+	if result.Items.Truncated || result.Owners.Truncated || result.Transfers.Truncated {
+		t.Error("expected no group to be truncated")
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/search?q=+", token, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/search: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a blank query, got %d", resp.StatusCode)
+	}
+}
+
+func TestItemDocumentsAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	item, _ := store.CreateItem(ctx, database, "Drill", "", "")
+	docsURL := fmt.Sprintf("%s/api/items/%d/documents", server.URL, item.ID)
+
+	upload := func(token, filename string, data []byte) (int, map[string]any) {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("document", filename)
+		part.Write(data)
+		mw.Close()
+
+		req, _ := http.NewRequest("POST", docsURL, &body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n%%EOF\n")
+	status, created := upload(managerToken, `C:\scans\receipt 03.pdf`, pdf)
+	if status != http.StatusCreated || created["filename"] != "receipt 03.pdf" || created["mime"] != "application/pdf" || created["size"] != float64(len(pdf)) {
+		t.Fatalf("expected 201 with the PDF's metadata, got %d %v", status, created)
+	}
+	docID := int64(created["id"].(float64))
+
+	if status, _ := upload(userToken, "receipt.pdf", pdf); status != http.StatusForbidden {
+		t.Errorf("expected 403 for a user upload, got %d", status)
+	}
+	for name, data := range map[string][]byte{
+		"page.html":   []byte("<!DOCTYPE html><html><script>alert(1)</script></html>"),
+		"tool.exe":    []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00"),
+		"archive.zip": []byte("PK\x03\x04\x14\x00\x00\x00\x08\x00"),
+		"fake.pdf":    []byte("<html>not a pdf</html>"),
+	} {
+		if status, out := upload(managerToken, name, data); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d %v", name, status, out)
+		}
+	}
+	if status, out := upload(managerToken, "big.pdf", append(pdf, make([]byte, model.MaxDocumentSize)...)); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for an oversized document, got %d %v", status, out)
+	}
+
+	req, _ := authRequest("GET", docsURL, userToken, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var docs []model.Document
+	json.NewDecoder(resp.Body).Decode(&docs)
+	resp.Body.Close()
+	if len(docs) != 1 || docs[0].ID != docID || docs[0].UploadedBy == nil || *docs[0].UploadedBy != manager.ID {
+		t.Fatalf("expected the one uploaded document, got %+v", docs)
+	}
+
+	req, _ = authRequest("GET", fmt.Sprintf("%s/%d", docsURL, docID), userToken, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(data, pdf) {
+		t.Fatalf("expected the PDF back unchanged, got %d with %d bytes", resp.StatusCode, len(data))
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("expected Content-Type application/pdf, got %q", got)
+	}
+	if got := resp.Header.Get("Content-Disposition"); !strings.HasPrefix(got, "attachment;") || !strings.Contains(got, "receipt 03.pdf") {
+		t.Errorf("expected an attachment named receipt 03.pdf, got %q", got)
+	}
+
+	other, _ := store.CreateItem(ctx, database, "Saw", "", "")
+	req, _ = authRequest("GET", fmt.Sprintf("%s/api/items/%d/documents/%d", server.URL, other.ID, docID), userToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for another item's document, got %d", resp.StatusCode)
+	}
+
+	req, _ = authRequest("DELETE", fmt.Sprintf("%s/%d", docsURL, docID), managerToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 on delete, got %d", resp.StatusCode)
+	}
+	req, _ = authRequest("DELETE", fmt.Sprintf("%s/%d", docsURL, docID), managerToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 deleting a missing document, got %d", resp.StatusCode)
+	}
+}
+
+func TestDecommissionItemAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	item, _ := store.CreateItem(ctx, database, "Projector", "", "")
+	room, _ := store.CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	ana, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	store.AddStock(ctx, database, item.ID, room.ID, 4, false, nil)
+	store.CreateTransfer(ctx, database, item.ID, room.ID, ana.ID, 1, false, "", nil)
+	url := fmt.Sprintf("%s/api/items/%d/decommission", server.URL, item.ID)
+
+	req, _ := authRequest("POST", url, userToken, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a user, got %d", resp.StatusCode)
+	}
+	if dist, _ := store.GetItemDistribution(ctx, database, item.ID); len(dist) != 2 {
+		t.Fatalf("expected stock untouched after a refused call, got %+v", dist)
+	}
+
+	req, _ = authRequest("POST", url, managerToken, map[string]string{"reason": "bulb burnt out"})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("decommission: %v", err)
+	}
+	var result model.DecommissionResult
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if result.Item == nil || result.Item.Status != model.ItemStatusRemoved || len(result.Adjustments) != 2 {
+		t.Fatalf("expected a removed item and 2 adjustments, got %+v", result)
+	}
+	total := 0
+	for _, a := range result.Adjustments {
+		total += a.Delta
+		if a.Kind != "decommissioned" || a.Reason != "bulb burnt out" || a.UserID == nil || *a.UserID != manager.ID {
+			t.Errorf("unexpected adjustment: %+v", a)
+		}
+	}
+	if total != -4 {
+		t.Errorf("expected 4 units removed, got %d", -total)
+	}
+
+	got, _ := store.GetItem(ctx, database, item.ID)
+	dist, _ := store.GetItemDistribution(ctx, database, item.ID)
+	if got.Status != model.ItemStatusRemoved || len(dist) != 0 {
+		t.Errorf("expected status removed and no stock, got %q with %+v", got.Status, dist)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/items/999/decommission", managerToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing item, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminConfigAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	config := map[string]string{"db": "/data/inv.sqlite3", "low-stock-webhook": "[redacted]"}
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{Config: config}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	adminToken, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+
+	req, _ := authRequest("GET", server.URL+"/api/admin/config", managerToken, nil)
+	resp, _ := http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a manager, got %d", resp.StatusCode)
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/admin/config", adminToken, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/admin/config: %v", err)
+	}
+	defer resp.Body.Close()
+	var got map[string]string
+	json.NewDecoder(resp.Body).Decode(&got)
+	if resp.StatusCode != http.StatusOK || !maps.Equal(got, config) {
+		t.Errorf("expected 200 with the configuration, got %d %v", resp.StatusCode, got)
+	}
+}
+
+func TestInventoryListSearchAndPagination(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	user, _ := store.CreateUser(ctx, database, "user1", "hash", model.RoleUser)
+	token, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	widget, _ := store.CreateItem(ctx, database, "Widget", "", "")
+	gadget, _ := store.CreateItem(ctx, database, "Gadget", "", "")
+	storage, _ := store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	alice, _ := store.CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	for _, item := range []int64{widget.ID, gadget.ID} {
+		store.AddStock(ctx, database, item, storage.ID, 3, false, nil)
+		store.AddStock(ctx, database, item, alice.ID, 1, false, nil)
+	}
+
+	list := func(query string) (*http.Response, []model.Inventory) {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"/api/inventory"+query, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("listing inventory: %v", err)
+		}
+		defer resp.Body.Close()
+		var inventory []model.Inventory
+		json.NewDecoder(resp.Body).Decode(&inventory)
+		return resp, inventory
+	}
+
+	resp, inventory := list("?q=widg&limit=1")
+	if len(inventory) != 1 || resp.Header.Get("X-Total-Count") != "2" {
+		t.Errorf("expected 1 of 2 Widget rows, got %d of %s", len(inventory), resp.Header.Get("X-Total-Count"))
+	}
+	if link := resp.Header.Get("Link"); !strings.Contains(link, `</api/inventory?limit=1&offset=1&q=widg>; rel="next"`) {
+		t.Errorf("expected a next link keeping q, got %q", link)
+	}
+
+	resp, inventory = list("?q=ali&owner_type=person&offset=0")
+	if len(inventory) != 2 || resp.Header.Get("X-Total-Count") != "2" || inventory[0].OwnerID != alice.ID {
+		t.Errorf("expected both of Alice's rows, got %+v (total %s)", inventory, resp.Header.Get("X-Total-Count"))
+	}
+
+	// Without limit/offset the filters still apply and nothing is paginated.
+	resp, inventory = list(fmt.Sprintf("?q=stor&item_id=%d", gadget.ID))
+	if len(inventory) != 1 || inventory[0].ItemID != gadget.ID || resp.Header.Get("Link") != "" {
+		t.Errorf("expected Gadget in Storage without a Link header, got %+v", inventory)
+	}
+
+	for _, query := range []string{"?owner_type=robot", "?item_id=x", "?limit=0"} {
+		if resp, _ := list(query); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestCustomStatusAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	adminToken, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+	item, _ := store.CreateItem(ctx, database, "Drill", "", "")
+
+	do := func(method, path, token string, body any) *http.Response {
+		t.Helper()
+		req, _ := authRequest(method, server.URL+path, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	itemPath := fmt.Sprintf("/api/items/%d", item.ID)
+	inRepair := map[string]string{"name": "Drill", "status": "in_repair", "reason": "sent to service"}
+
+	if resp := do("PUT", itemPath, managerToken, inRepair); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown status, got %d", resp.StatusCode)
+	}
+	if resp := do("POST", "/api/statuses", managerToken, map[string]string{"name": "in_repair"}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a manager adding a status, got %d", resp.StatusCode)
+	}
+	if resp := do("POST", "/api/statuses", adminToken, map[string]string{"name": "in repair"}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid name, got %d", resp.StatusCode)
+	}
+
+	resp := do("POST", "/api/statuses", adminToken, map[string]string{"name": " In_Repair "})
+	var created model.ItemStatus
+	json.NewDecoder(resp.Body).Decode(&created)
+	if resp.StatusCode != http.StatusCreated || created.Name != "in_repair" || created.Builtin {
+		t.Fatalf("expected 201 with in_repair, got %d %+v", resp.StatusCode, created)
+	}
+	if resp := do("POST", "/api/statuses", adminToken, map[string]string{"name": "in_repair"}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 for a duplicate status, got %d", resp.StatusCode)
+	}
+
+	resp = do("GET", "/api/statuses", managerToken, nil)
+	var statuses []model.ItemStatus
+	json.NewDecoder(resp.Body).Decode(&statuses)
+	if len(statuses) != 5 || statuses[4].Name != "in_repair" {
+		t.Errorf("expected the built-ins and in_repair, got %+v", statuses)
+	}
+
+	resp = do("PUT", itemPath, managerToken, inRepair)
+	var updated model.Item
+	json.NewDecoder(resp.Body).Decode(&updated)
+	if resp.StatusCode != http.StatusOK || updated.Status != "in_repair" {
+		t.Errorf("expected 200 with status in_repair, got %d %+v", resp.StatusCode, updated)
+	}
+	if history, _ := store.GetItemStatusHistory(ctx, database, item.ID); len(history) != 1 || history[0].ToStatus != "in_repair" {
+		t.Errorf("expected the change to be recorded, got %+v", history)
+	}
+}
+
+func TestRestrictTransfers(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{RestrictTransfers: true}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	adminToken, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+	user, _ := store.CreateUser(ctx, database, "ana", "hash", model.RoleUser)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+
+	item, _ := store.CreateItem(ctx, database, "Drill", "", "")
+	ana, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	bor, _ := store.CreateOwner(ctx, database, "Bor", model.OwnerTypePerson)
+	cene, _ := store.CreateOwner(ctx, database, "Cene", model.OwnerTypePerson)
+	store.AddStock(ctx, database, item.ID, bor.ID, 5, false, nil)
+
+	do := func(method, path, token string, body any) *http.Response {
+		t.Helper()
+		req, _ := authRequest(method, server.URL+path, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	transfer := func(from, to int64) map[string]any {
+		return map[string]any{"item_id": item.ID, "from_owner_id": from, "to_owner_id": to, "quantity": 1}
+	}
+
+	// Without a linked owner a user may make no transfers at all.
+	if resp := do("POST", "/api/transfers", userToken, transfer(bor.ID, ana.ID)); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 without a linked owner, got %d", resp.StatusCode)
+	}
+
+	linkPath := fmt.Sprintf("/api/owners/%d/user", ana.ID)
+	if resp := do("PUT", linkPath, managerToken, map[string]any{"user_id": user.ID}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a manager linking users, got %d", resp.StatusCode)
+	}
+	resp := do("PUT", linkPath, adminToken, map[string]any{"user_id": user.ID})
+	var linked model.Owner
+	json.NewDecoder(resp.Body).Decode(&linked)
+	if resp.StatusCode != http.StatusOK || linked.UserID == nil || *linked.UserID != user.ID {
+		t.Fatalf("expected 200 linking Ana, got %d %+v", resp.StatusCode, linked)
+	}
+
+	if resp := do("POST", "/api/transfers", userToken, transfer(bor.ID, cene.ID)); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 moving items between two other people, got %d", resp.StatusCode)
+	}
+	if resp := do("POST", "/api/transfers", userToken, transfer(bor.ID, ana.ID)); resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201 for a transfer to the user, got %d", resp.StatusCode)
+	}
+	if resp := do("POST", "/api/transfers", userToken, transfer(ana.ID, cene.ID)); resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201 for a transfer from the user, got %d", resp.StatusCode)
+	}
+	if resp := do("POST", "/api/transfers", managerToken, transfer(bor.ID, cene.ID)); resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201 for a manager, got %d", resp.StatusCode)
+	}
+
+	fulfill := map[string]any{"item_id": item.ID, "to_owner_id": cene.ID, "quantity": 1}
+	if resp := do("POST", "/api/transfers/fulfill", userToken, fulfill); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 fulfilling to another person, got %d", resp.StatusCode)
+	}
+
+	resp = do("POST", "/api/transfers/validate", userToken, transfer(bor.ID, cene.ID))
+	var check validateTransferResponse
+	json.NewDecoder(resp.Body).Decode(&check)
+	if check.Valid || check.Code != "forbidden" {
+		t.Errorf("expected validation to report the transfer as forbidden, got %+v", check)
+	}
+
+	req, _ := authRequest("POST", server.URL+"/api/transfers/ingest", userToken, nil)
+	req.Body = io.NopCloser(strings.NewReader(fmt.Sprintf(`{"item_id":%d,"from_owner_id":%d,"to_owner_id":%d,"quantity":1}`, item.ID, bor.ID, cene.ID)))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, _ = http.DefaultClient.Do(req)
+	var ingested ingestResponse
+	json.NewDecoder(resp.Body).Decode(&ingested)
+	resp.Body.Close()
+	if ingested.Created != 0 || len(ingested.Results) != 1 || ingested.Results[0].Code != "forbidden" {
+		t.Errorf("expected the ingested line to be forbidden, got %+v", ingested)
+	}
+
+	if got, _ := store.GetHeldQuantity(ctx, database, item.ID, cene.ID); got != 2 {
+		t.Errorf("expected Cene to hold 2, got %d", got)
+	}
+}
+
+func TestMyHoldings(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	adminToken, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+	user, _ := store.CreateUser(ctx, database, "ana", "hash", model.RoleUser)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+
+	drill, _ := store.CreateItem(ctx, database, "Drill", "", "")
+	saw, _ := store.CreateItem(ctx, database, "Saw", "", "")
+	ana, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	room, _ := store.CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	store.AddStock(ctx, database, drill.ID, ana.ID, 2, false, nil)
+	store.AddStock(ctx, database, saw.ID, room.ID, 1, false, nil)
+
+	do := func(method, path, token string, body any) *http.Response {
+		t.Helper()
+		req, _ := authRequest(method, server.URL+path, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := do("GET", "/api/auth/me/holdings", userToken, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 without a linked owner, got %d", resp.StatusCode)
+	}
+
+	linkPath := fmt.Sprintf("/api/owners/%d/user", ana.ID)
+	if resp := do("PUT", linkPath, adminToken, map[string]any{"user_id": user.ID}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 linking Ana, got %d", resp.StatusCode)
+	}
+
+	resp := do("GET", "/api/owners?type=person", userToken, nil)
+	var owners []model.Owner
+	json.NewDecoder(resp.Body).Decode(&owners)
+	if len(owners) != 1 || owners[0].UserID == nil || *owners[0].UserID != user.ID {
+		t.Errorf("expected the owner list to show the link, got %+v", owners)
+	}
+
+	resp = do("GET", "/api/auth/me/holdings", userToken, nil)
+	var holdings holdingsResponse
+	json.NewDecoder(resp.Body).Decode(&holdings)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if holdings.Owner == nil || holdings.Owner.ID != ana.ID {
+		t.Errorf("expected Ana as the owner, got %+v", holdings.Owner)
+	}
+	if len(holdings.Inventory) != 1 || holdings.Inventory[0].ItemID != drill.ID || holdings.Inventory[0].Quantity != 2 {
+		t.Errorf("expected 2 drills, got %+v", holdings.Inventory)
+	}
+
+	if resp := do("DELETE", linkPath, userToken, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a user unlinking, got %d", resp.StatusCode)
+	}
+	resp = do("DELETE", linkPath, adminToken, nil)
+	var unlinked model.Owner
+	json.NewDecoder(resp.Body).Decode(&unlinked)
+	if resp.StatusCode != http.StatusOK || unlinked.UserID != nil {
+		t.Errorf("expected 200 and no user after unlinking, got %d %+v", resp.StatusCode, unlinked)
+	}
+	if resp := do("GET", "/api/auth/me/holdings", userToken, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 after unlinking, got %d", resp.StatusCode)
+	}
+}
+
+func TestMyActivity(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	ana, _ := store.CreateUser(ctx, database, "ana", "hash", model.RoleManager)
+	anaToken, _ := auth.GenerateToken(testJWTSecret, ana.ID, ana.Username, ana.Role)
+	bor, _ := store.CreateUser(ctx, database, "bor", "hash", model.RoleUser)
+
+	drill, _ := store.CreateItem(ctx, database, "Drill", "", "")
+	saw, _ := store.CreateItem(ctx, database, "Saw", "", "")
+	room, _ := store.CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	van, _ := store.CreateOwner(ctx, database, "Van", model.OwnerTypeLocation)
+	store.AddStock(ctx, database, drill.ID, room.ID, 2, false, nil)
+	store.AddStock(ctx, database, saw.ID, room.ID, 1, false, nil)
+
+	store.CreateTransfer(ctx, database, drill.ID, room.ID, van.ID, 1, false, "", &ana.ID)
+	store.CreateTransfer(ctx, database, saw.ID, room.ID, van.ID, 1, false, "", &bor.ID)
+	store.UpdateItem(ctx, database, saw.ID, "Saw", "", "", model.ItemStatusDamaged, "blunt", &ana.ID)
+	store.CreateTransfer(ctx, database, drill.ID, room.ID, van.ID, 1, false, "", nil)
+
+	get := func(query string) []model.AuditEntry {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"/api/auth/me/activity"+query, anaToken, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET activity: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var entries []model.AuditEntry
+		json.NewDecoder(resp.Body).Decode(&entries)
+		return entries
+	}
+
+	entries := get("")
+	var got []string
+	for _, e := range entries {
+		if e.UserID == nil || *e.UserID != ana.ID {
+			t.Errorf("expected only ana's actions, got %+v", e)
+		}
+		got = append(got, e.Action+" "+e.SubjectName)
+	}
+	slices.Sort(got) // both may share a timestamp
+	if want := []string{"status_changed Saw", "transfer Drill"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if page := get("?limit=1&offset=1"); len(page) != 1 || len(entries) != 2 || page[0].Action != entries[1].Action {
+		t.Errorf("expected the second entry as the second page, got %+v", page)
+	}
+}
+
+func TestMoveAllInventoryAPI(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+
+	rope, _ := store.CreateItem(ctx, database, "Rope", "", "")
+	tape, _ := store.CreateItem(ctx, database, "Tape", "", "")
+	roomA, _ := store.CreateOwner(ctx, database, "Room A", model.OwnerTypeLocation)
+	roomB, _ := store.CreateOwner(ctx, database, "Room B", model.OwnerTypeLocation)
+	store.AddStock(ctx, database, rope.ID, roomA.ID, 4, false, nil)
+	store.AddStock(ctx, database, tape.ID, roomA.ID, 1, false, nil)
+
+	move := func(ownerID int64, token string, body any) (int, []model.Transfer) {
+		t.Helper()
+		req, _ := authRequest("POST", fmt.Sprintf("%s/api/owners/%d/move-all", server.URL, ownerID), token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("move-all: %v", err)
+		}
+		defer resp.Body.Close()
+		var transfers []model.Transfer
+		json.NewDecoder(resp.Body).Decode(&transfers)
+		return resp.StatusCode, transfers
+	}
+
+	if code, _ := move(roomA.ID, userToken, map[string]any{"to": roomB.ID}); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a user, got %d", code)
+	}
+	if code, _ := move(roomA.ID, managerToken, map[string]any{}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 without to, got %d", code)
+	}
+	if code, _ := move(roomA.ID, managerToken, map[string]any{"to": roomA.ID}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 moving to the same owner, got %d", code)
+	}
+	if code, _ := move(9999, managerToken, map[string]any{"to": roomB.ID}); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown owner, got %d", code)
+	}
+
+	code, transfers := move(roomA.ID, managerToken, map[string]any{"to": roomB.ID, "notes": "renovation"})
+	if code != http.StatusOK || len(transfers) != 2 {
+		t.Fatalf("expected 200 with 2 transfers, got %d %+v", code, transfers)
+	}
+	for _, tr := range transfers {
+		if tr.FromOwnerID != roomA.ID || tr.ToOwnerID != roomB.ID || tr.TransferredBy == nil || *tr.TransferredBy != manager.ID {
+			t.Errorf("expected a transfer from Room A to Room B by the manager, got %+v", tr)
+		}
+	}
+	if held, _ := store.GetHeldQuantity(ctx, database, rope.ID, roomB.ID); held != 4 {
+		t.Errorf("expected Room B to hold 4 rope, got %d", held)
+	}
+}
+
+func TestStockSnapshotsAPI(t *testing.T) {
+	server, token := setupTestServer(t)
+
+	req, _ := authRequest("POST", server.URL+"/api/items", token, map[string]string{"name": "Drill"})
+	resp, _ := http.DefaultClient.Do(req)
+	var item model.Item
+	json.NewDecoder(resp.Body).Decode(&item)
+	resp.Body.Close()
+
+	var owners [2]model.Owner
+	for i, name := range []string{"Workshop", "Bor"} {
+		req, _ = authRequest("POST", server.URL+"/api/owners", token, map[string]string{"name": name, "type": []string{model.OwnerTypeLocation, model.OwnerTypePerson}[i]})
+		resp, _ = http.DefaultClient.Do(req)
+		json.NewDecoder(resp.Body).Decode(&owners[i])
+		resp.Body.Close()
+	}
+	req, _ = authRequest("POST", server.URL+"/api/inventory/stock", token, map[string]any{
+		"item_id": item.ID, "owner_id": owners[0].ID, "quantity": 4,
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	req, _ = authRequest("POST", server.URL+"/api/inventory/snapshots", token, map[string]string{"notes": "audit"})
+	resp, _ = http.DefaultClient.Do(req)
+	var snapshot model.StockSnapshot
+	json.NewDecoder(resp.Body).Decode(&snapshot)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || snapshot.ID == 0 || snapshot.Lines != 1 || snapshot.Notes != "audit" {
+		t.Fatalf("expected 201 with the snapshot, got %d %+v", resp.StatusCode, snapshot)
+	}
+
+	req, _ = authRequest("POST", server.URL+"/api/transfers", token, map[string]any{
+		"item_id": item.ID, "from_owner_id": owners[0].ID, "to_owner_id": owners[1].ID, "quantity": 1,
+	})
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	get := func(query string) (int, map[string]any) {
+		t.Helper()
+		req, _ := authRequest("GET", server.URL+"/api/inventory/snapshots/diff?"+query, token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	status, body := get("from=" + strconv.FormatInt(snapshot.ID, 10) + "&to=current")
+	if status != http.StatusOK || body["to"] != nil {
+		t.Fatalf("expected 200 against the current inventory, got %d %v", status, body)
+	}
+	changes, _ := body["changes"].([]any)
+	if len(changes) != 2 {
+		t.Fatalf("expected two changes, got %v", body["changes"])
+	}
+	for i, want := range []map[string]any{
+		{"owner_name": "Bor", "change": model.SnapshotAdded, "from_quantity": float64(0), "to_quantity": float64(1), "delta": float64(1)},
+		{"owner_name": "Workshop", "change": model.SnapshotChanged, "from_quantity": float64(4), "to_quantity": float64(3), "delta": float64(-1)},
+	} {
+		got := changes[i].(map[string]any)
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("change %d: expected %s %v, got %v", i, k, v, got[k])
+			}
+		}
+	}
+
+	for query, want := range map[string]int{
+		"":                http.StatusBadRequest,
+		"from=abc":        http.StatusBadRequest,
+		"from=1&to=later": http.StatusBadRequest,
+		"from=9999":       http.StatusNotFound,
+		"from=1&to=9999":  http.StatusNotFound,
+	} {
+		if status, _ := get(query); status != want {
+			t.Errorf("%q: expected %d, got %d", query, want, status)
+		}
+	}
+
+	req, _ = authRequest("GET", server.URL+"/api/inventory/snapshots", token, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	var snapshots []model.StockSnapshot
+	json.NewDecoder(resp.Body).Decode(&snapshots)
+	resp.Body.Close()
+	if len(snapshots) != 1 || snapshots[0].ID != snapshot.ID {
+		t.Errorf("expected the snapshot listed, got %+v", snapshots)
+	}
+}
+
+func TestImportStockCSV(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	manager, _ := store.CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	managerToken, _ := auth.GenerateToken(testJWTSecret, manager.ID, manager.Username, manager.Role)
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+
+	widget, _ := store.CreateItem(ctx, database, "Widget", "", "")
+	gadget, _ := store.CreateItem(ctx, database, "Gadget", "", "")
+	warehouse, _ := store.CreateOwner(ctx, database, "Warehouse", model.OwnerTypeLocation)
+
+	post := func(query, token, contentType, body string) (*http.Response, stockImportResponse) {
+		t.Helper()
+		req, _ := http.NewRequest("POST", server.URL+"/api/inventory/import"+query, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		defer resp.Body.Close()
+		var result stockImportResponse
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	valid := fmt.Sprintf("item,owner,quantity\nWidget,Warehouse,5\n%d,%d,2\n", gadget.ID, warehouse.ID)
+	if resp, _ := post("", userToken, "text/csv", valid); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a user, got %d", resp.StatusCode)
+	}
+	if resp, _ := post("", managerToken, "application/json", valid); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for a non-CSV body, got %d", resp.StatusCode)
+	}
+	if resp, _ := post("", managerToken, "text/csv", "item,quantity\nWidget,1\n"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without an owner column, got %d", resp.StatusCode)
+	}
+
+	resp, result := post("", managerToken, "text/csv", "item,owner,quantity\nWidget,Warehouse,5\nSprocket,Warehouse,1\n")
+	if resp.StatusCode != http.StatusBadRequest || result.Applied {
+		t.Errorf("expected 400 and nothing applied for an unresolved row, got %d %+v", resp.StatusCode, result)
+	}
+	if len(result.Rows) != 2 || result.Rows[0].Error != "" || result.Rows[1].Line != 3 || !strings.Contains(result.Rows[1].Error, "item not found") {
+		t.Errorf("expected line 3 unresolved, got %+v", result.Rows)
+	}
+	if result.Failed != 2 || len(result.Errors) != 1 || result.Errors[0].Index != 1 {
+		t.Errorf("unexpected failure summary: %+v", result.BulkResult)
+	}
+
+	resp, result = post("?dry_run=true", managerToken, "text/csv", valid)
+	if resp.StatusCode != http.StatusOK || !result.DryRun || result.Applied || result.Succeeded != 2 {
+		t.Errorf("expected a clean dry run, got %d %+v", resp.StatusCode, result)
+	}
+	if got, _ := store.GetHeldQuantity(ctx, database, widget.ID, warehouse.ID); got != 0 {
+		t.Errorf("expected nothing applied by the dry run, got %d", got)
+	}
+
+	resp, result = post("", managerToken, "text/csv", valid)
+	if resp.StatusCode != http.StatusOK || !result.Applied {
+		t.Fatalf("expected the import to apply, got %d %+v", resp.StatusCode, result)
+	}
+	if result.Rows[0].ItemID != widget.ID || result.Rows[0].OwnerID != warehouse.ID || result.Rows[0].Quantity != 5 {
+		t.Errorf("unexpected first row: %+v", result.Rows[0])
+	}
+	if got, _ := store.GetHeldQuantity(ctx, database, gadget.ID, warehouse.ID); got != 2 {
+		t.Errorf("expected 2 gadgets, got %d", got)
+	}
+}
+
+func TestMissingAndDeletedLookIdentical(t *testing.T) {
+	database := db.NewTestDB(t)
+	server := httptest.NewServer(NewRouter(database, testJWTSecret, Options{}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	admin, _ := store.CreateUser(ctx, database, "admin", "hash", model.RoleAdmin)
+	adminToken, _ := auth.GenerateToken(testJWTSecret, admin.ID, admin.Username, admin.Role)
+	user, _ := store.CreateUser(ctx, database, "user", "hash", model.RoleUser)
+	userToken, _ := auth.GenerateToken(testJWTSecret, user.ID, user.Username, user.Role)
+	gone, _ := store.CreateUser(ctx, database, "gone", "hash", model.RoleUser)
+	store.DeleteUser(ctx, database, gone.ID)
+
+	item, _ := store.CreateItem(ctx, database, "Drill", "", "")
+	store.DeleteItem(ctx, database, item.ID, nil, "")
+	owner, _ := store.CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	store.DeleteOwner(ctx, database, owner.ID, nil, "")
+	const missing = 999
+
+	do := func(method, path, token string, body any) (int, string) {
+		t.Helper()
+		req, _ := authRequest(method, server.URL+path, token, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	checks := []struct {
+		method, path, token string
+		body                any
+		status              int
+	}{
+		{"GET", "/api/items/%d", userToken, nil, http.StatusNotFound},
+		{"GET", "/api/items/%d/history", userToken, nil, http.StatusNotFound},
+		{"GET", "/api/items/%d/status-history", userToken, nil, http.StatusNotFound},
+		{"GET", "/api/items/%d/changelog", userToken, nil, http.StatusNotFound},
+		{"GET", "/api/items/%d?include_deleted=true", userToken, nil, http.StatusForbidden},
+		{"PUT", "/api/items/%d", adminToken, map[string]string{"name": "Saw"}, http.StatusNotFound},
+		{"DELETE", "/api/items/%d", adminToken, nil, http.StatusNotFound},
+		{"GET", "/api/owners/%d", userToken, nil, http.StatusNotFound},
+		{"GET", "/api/owners/%d/card", userToken, nil, http.StatusNotFound},
+		{"PUT", "/api/owners/%d", adminToken, map[string]string{"name": "Bor"}, http.StatusNotFound},
+		{"DELETE", "/api/owners/%d", adminToken, nil, http.StatusNotFound},
+		{"GET", "/api/users/%d", userToken, nil, http.StatusForbidden},
+		{"PUT", "/api/users/%d", adminToken, map[string]string{"role": model.RoleManager}, http.StatusNotFound},
+		{"DELETE", "/api/users/%d", adminToken, nil, http.StatusNotFound},
+	}
+	for _, c := range checks {
+		deleted := item.ID
+		switch {
+		case strings.HasPrefix(c.path, "/api/owners"):
+			deleted = owner.ID
+		case strings.HasPrefix(c.path, "/api/users"):
+			deleted = gone.ID
+		}
+		deletedStatus, deletedBody := do(c.method, fmt.Sprintf(c.path, deleted), c.token, c.body)
+		missingStatus, missingBody := do(c.method, fmt.Sprintf(c.path, missing), c.token, c.body)
+		if deletedStatus != c.status || missingStatus != c.status || deletedBody != missingBody {
+			t.Errorf("%s %s: deleted %d %s, missing %d %s; want both %d and equal",
+				c.method, c.path, deletedStatus, deletedBody, missingStatus, missingBody, c.status)
+		}
+	}
+
+	// Admins can still look at deleted items on request.
+	if status, _ := do("GET", fmt.Sprintf("/api/items/%d?include_deleted=true", item.ID), adminToken, nil); status != http.StatusOK {
+		t.Errorf("expected 200 for an admin asking for a deleted item, got %d", status)
+	}
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"database/sql"
 	"log/slog"
 	"net/http"
 
@@ -14,8 +13,11 @@ import (
 
 // AuthHandler handles authentication endpoints.
 type AuthHandler struct {
-	DB        *sql.DB
+	Store     store.Store
 	JWTSecret string
+
+	// MaxPageSize caps ?limit.
+	MaxPageSize int
 }
 
 type loginRequest struct {
@@ -25,6 +27,8 @@ type loginRequest struct {
 
 type loginResponse struct {
 	Token string `json:"token"`
+	// MustChangePassword is set when the token only allows a password change.
+	MustChangePassword bool `json:"must_change_password,omitempty"`
 }
 
 type changePasswordRequest struct {
@@ -32,11 +36,15 @@ type changePasswordRequest struct {
 	NewPassword     string `json:"new_password"`
 }
 
+type verifyPasswordRequest struct {
+	Password string `json:"password"`
+}
+
 // Login handles POST /api/auth/login.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req loginRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
@@ -45,30 +53,91 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := store.GetUserByUsername(r.Context(), h.DB, req.Username)
+	user, err := h.Store.GetUserByUsername(r.Context(), req.Username)
 	if err != nil {
-		jsonError(w, http.StatusInternalServerError, "internal error")
+		storeError(w, err, "internal error")
 		return
 	}
 	if user == nil || user.DeletedAt != nil {
+		RecordLogin(r, h.Store, user, req.Username, false)
 		jsonError(w, http.StatusUnauthorized, "invalid credentials")
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		slog.Warn("login failed", "username", req.Username, "remote", r.RemoteAddr)
+		slog.Warn("login failed", "username", req.Username, "remote", ClientIP(r))
+		RecordLogin(r, h.Store, user, req.Username, false)
 		jsonError(w, http.StatusUnauthorized, "invalid credentials")
 		return
 	}
 
-	token, err := auth.GenerateToken(h.JWTSecret, user.ID, user.Username, user.Role)
+	generate := auth.GenerateToken
+	if user.MustChangePassword {
+		generate = auth.GeneratePasswordChangeToken
+	}
+	token, err := generate(h.JWTSecret, user.ID, user.Username, user.Role)
 	if err != nil {
 		jsonError(w, http.StatusInternalServerError, "failed to generate token")
 		return
 	}
 
-	slog.Info("user logged in", "user", user.Username, "role", user.Role)
-	jsonResponse(w, http.StatusOK, loginResponse{Token: token})
+	RecordLogin(r, h.Store, user, req.Username, true)
+	slog.Info("user logged in", "user", user.Username, "role", user.Role, "must_change_password", user.MustChangePassword)
+	jsonResponse(w, http.StatusOK, loginResponse{Token: token, MustChangePassword: user.MustChangePassword})
+}
+
+// RecordLogin stores a login attempt made with username through r for
+// GET /api/admin/logins. user is the account the username matched, if any.
+// Failing to record is logged but does not fail the login.
+func RecordLogin(r *http.Request, st store.Store, user *model.User, username string, success bool) {
+	var userID int64
+	if user != nil {
+		userID = user.ID
+	}
+	if err := st.RecordLogin(r.Context(), userID, username, success, ClientIP(r), r.UserAgent()); err != nil {
+		slog.Error("failed to record login", "error", err)
+	}
+}
+
+// VerifyPassword handles POST /api/auth/verify-password. It checks the
+// current user's password for "confirm to continue" prompts without issuing
+// a new token.
+func (h *AuthHandler) VerifyPassword(w http.ResponseWriter, r *http.Request) {
+	claims := GetClaims(r.Context())
+	if claims == nil {
+		jsonError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	if claims.ImpersonatedBy != "" {
+		jsonError(w, http.StatusForbidden, "cannot verify password while impersonating")
+		return
+	}
+
+	var req verifyPasswordRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	if req.Password == "" {
+		jsonError(w, http.StatusBadRequest, "password required")
+		return
+	}
+
+	user, err := h.Store.GetUser(r.Context(), claims.UserID)
+	if err != nil || user == nil {
+		jsonError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		slog.Warn("password verification failed", "user", claims.Username, "ip", ClientIP(r))
+		jsonError(w, http.StatusUnauthorized, "password is incorrect")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "password verified"})
 }
 
 // ChangePassword handles PUT /api/auth/password.
@@ -79,9 +148,14 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if claims.ImpersonatedBy != "" {
+		jsonError(w, http.StatusForbidden, "cannot change password while impersonating")
+		return
+	}
+
 	var req changePasswordRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
@@ -95,7 +169,7 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := store.GetUser(r.Context(), h.DB, claims.UserID)
+	user, err := h.Store.GetUser(r.Context(), claims.UserID)
 	if err != nil || user == nil {
 		jsonError(w, http.StatusInternalServerError, "internal error")
 		return
@@ -112,13 +186,21 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := store.UpdateUserPassword(r.Context(), h.DB, claims.UserID, string(hash)); err != nil {
-		jsonError(w, http.StatusInternalServerError, "failed to update password")
+	if err := h.Store.UpdateUserPassword(r.Context(), claims.UserID, string(hash), false); err != nil {
+		storeError(w, err, "failed to update password")
+		return
+	}
+
+	// The change invalidates every existing token, including this one, so the
+	// caller gets a fresh one to stay logged in.
+	token, err := auth.GenerateToken(h.JWTSecret, claims.UserID, claims.Username, claims.Role)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, "failed to generate token")
 		return
 	}
 
 	slog.Info("user changed own password", "user", claims.Username)
-	jsonResponse(w, http.StatusOK, map[string]string{"message": "password updated"})
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "password updated", "token": token})
 }
 
 // Logout handles POST /api/auth/logout.
@@ -131,9 +213,9 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if claims.ID != "" && claims.ExpiresAt != nil {
-		if err := store.RevokeToken(r.Context(), h.DB, claims.ID, claims.ExpiresAt.Time); err != nil {
+		if err := h.Store.RevokeToken(r.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
 			slog.Error("failed to revoke token", "error", err)
-			jsonError(w, http.StatusInternalServerError, "failed to revoke token")
+			storeError(w, err, "failed to revoke token")
 			return
 		}
 	}
@@ -141,3 +223,157 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	slog.Info("user logged out (API)", "user", claims.Username)
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "logged out"})
 }
+
+// canResponse is the body of GET /api/auth/can.
+type canResponse struct {
+	// Allowed is true if every requested action is allowed.
+	Allowed bool            `json:"allowed"`
+	Actions map[string]bool `json:"actions"`
+}
+
+// Can handles GET /api/auth/can?action=a&action=b. It reports whether the
+// caller's role may perform each action, using the same mapping the router
+// enforces.
+func (h *AuthHandler) Can(w http.ResponseWriter, r *http.Request) {
+	actions := r.URL.Query()["action"]
+	if len(actions) == 0 {
+		jsonError(w, http.StatusBadRequest, "action required")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	resp := canResponse{Allowed: true, Actions: make(map[string]bool, len(actions))}
+	for _, action := range actions {
+		if _, ok := model.ActionRole(action); !ok {
+			jsonError(w, http.StatusBadRequest, "unknown action: "+action)
+			return
+		}
+		allowed := model.Can(claims.Role, action)
+		resp.Actions[action] = allowed
+		resp.Allowed = resp.Allowed && allowed
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+type introspectRequest struct {
+	Token string `json:"token"`
+}
+
+// introspectResponse is the body of POST /api/auth/introspect. Only Active is
+// set for tokens that are not active.
+type introspectResponse struct {
+	Active   bool   `json:"active"`
+	UserID   int64  `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Role     string `json:"role,omitempty"`
+	// Exp is the expiry as a Unix timestamp.
+	Exp int64 `json:"exp,omitempty"`
+}
+
+// Introspect handles POST /api/auth/introspect. It tells a companion service
+// whether a token is active, loosely following RFC 7662: the token must have a
+// valid signature, must not be expired and must not be revoked.
+func (h *AuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	var req introspectRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	if req.Token == "" {
+		jsonError(w, http.StatusBadRequest, "token required")
+		return
+	}
+
+	claims, err := auth.ValidateToken(h.JWTSecret, req.Token)
+	if err != nil {
+		jsonResponse(w, http.StatusOK, introspectResponse{})
+		return
+	}
+	revoked, err := tokenRevoked(r.Context(), h.Store, claims)
+	if err != nil {
+		slog.Error("failed to check token revocation", "error", err)
+		storeError(w, err, "internal error")
+		return
+	}
+	if revoked {
+		jsonResponse(w, http.StatusOK, introspectResponse{})
+		return
+	}
+
+	resp := introspectResponse{
+		Active:   true,
+		UserID:   claims.UserID,
+		Username: claims.Username,
+		Role:     claims.Role,
+	}
+	if claims.ExpiresAt != nil {
+		resp.Exp = claims.ExpiresAt.Unix()
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// holdingsResponse is the body of GET /api/auth/me/holdings.
+type holdingsResponse struct {
+	Owner     *model.Owner      `json:"owner"`
+	Inventory []model.Inventory `json:"inventory"`
+}
+
+// Holdings handles GET /api/auth/me/holdings. It returns what the person
+// owner linked to the caller's account currently holds, or 404 if the
+// account is not linked to an owner.
+func (h *AuthHandler) Holdings(w http.ResponseWriter, r *http.Request) {
+	claims := GetClaims(r.Context())
+	if claims == nil {
+		jsonError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	owner, err := h.Store.GetUserOwner(r.Context(), claims.UserID)
+	if err != nil {
+		slog.Error("failed to get user's owner", "error", err)
+		storeError(w, err, "failed to get holdings")
+		return
+	}
+	if owner == nil {
+		jsonError(w, http.StatusNotFound, "no owner is linked to this account")
+		return
+	}
+
+	inventory, err := h.Store.GetOwnerInventory(r.Context(), owner.ID)
+	if err != nil {
+		slog.Error("failed to get owner inventory", "error", err)
+		storeError(w, err, "failed to get holdings")
+		return
+	}
+	if inventory == nil {
+		inventory = []model.Inventory{}
+	}
+	jsonResponse(w, http.StatusOK, holdingsResponse{Owner: owner, Inventory: inventory})
+}
+
+// Activity handles GET /api/auth/me/activity?limit=&offset=. It returns the
+// caller's own recent actions (transfers, status changes, stock removals and
+// deletions), newest first.
+func (h *AuthHandler) Activity(w http.ResponseWriter, r *http.Request) {
+	claims := GetClaims(r.Context())
+	if claims == nil {
+		jsonError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	limit, offset, err := parsePagination(w, r, 50, h.MaxPageSize)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := h.Store.ListUserActions(r.Context(), claims.UserID, limit, offset)
+	if err != nil {
+		slog.Error("failed to list user actions", "error", err)
+		storeError(w, err, "failed to list activity")
+		return
+	}
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+	jsonResponse(w, http.StatusOK, entries)
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// DocumentsHandler handles documents attached to items.
+type DocumentsHandler struct {
+	Store store.Store
+}
+
+// documentTooLargeMessage is the error shown when an upload exceeds
+// model.MaxDocumentSize.
+var documentTooLargeMessage = fmt.Sprintf("document exceeds %d MB", model.MaxDocumentSize>>20)
+
+// getItem parses the {id} path value and loads the item, writing the error
+// response and returning nil if it is invalid or missing.
+func (h *DocumentsHandler) getItem(w http.ResponseWriter, r *http.Request) *model.Item {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return nil
+	}
+	item, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to get item")
+		return nil
+	}
+	if item == nil || item.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "item not found")
+		return nil
+	}
+	return item
+}
+
+// Upload handles POST /api/items/{id}/documents. The multipart "document"
+// file is stored as is once its sniffed type passes the allowlist.
+func (h *DocumentsHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	item := h.getItem(w, r)
+	if item == nil {
+		return
+	}
+
+	// Leave room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, model.MaxDocumentSize+64<<10)
+	if err := r.ParseMultipartForm(model.MaxDocumentSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			jsonError(w, http.StatusRequestEntityTooLarge, documentTooLargeMessage)
+			return
+		}
+		jsonError(w, http.StatusBadRequest, "invalid multipart form")
+		return
+	}
+
+	file, header, err := r.FormFile("document")
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "document file required")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > model.MaxDocumentSize {
+		jsonError(w, http.StatusRequestEntityTooLarge, documentTooLargeMessage)
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		slog.Error("failed to read document", "error", err)
+		storeError(w, err, "failed to read document")
+		return
+	}
+	if len(data) == 0 {
+		jsonError(w, http.StatusBadRequest, "document is empty")
+		return
+	}
+
+	filename := model.NormalizeFilename(header.Filename)
+	mimeType, err := model.DetectDocumentMIME(filename, data)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	var userID *int64
+	if claims != nil {
+		userID = &claims.UserID
+	}
+
+	doc, err := h.Store.AddDocument(r.Context(), item.ID, filename, mimeType, data, userID)
+	if err != nil {
+		if invalid(err) {
+			jsonError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		slog.Error("failed to save document", "error", err)
+		storeError(w, err, "failed to save document")
+		return
+	}
+
+	slog.Info("item document uploaded", "user", claims.Username, "item", item.Name, "filename", doc.Filename, "size", doc.Size)
+	jsonResponse(w, http.StatusCreated, doc)
+}
+
+// List handles GET /api/items/{id}/documents. It returns metadata only.
+func (h *DocumentsHandler) List(w http.ResponseWriter, r *http.Request) {
+	item := h.getItem(w, r)
+	if item == nil {
+		return
+	}
+
+	docs, err := h.Store.ListDocuments(r.Context(), item.ID)
+	if err != nil {
+		slog.Error("failed to list documents", "error", err)
+		storeError(w, err, "failed to list documents")
+		return
+	}
+	if docs == nil {
+		docs = []model.Document{}
+	}
+	jsonResponse(w, http.StatusOK, docs)
+}
+
+// Download handles GET /api/items/{id}/documents/{docID}. Documents are
+// always served as attachments so a browser never renders them in the
+// application's origin.
+func (h *DocumentsHandler) Download(w http.ResponseWriter, r *http.Request) {
+	item := h.getItem(w, r)
+	if item == nil {
+		return
+	}
+	docID, err := strconv.ParseInt(r.PathValue("docID"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+
+	doc, err := h.Store.GetDocument(r.Context(), item.ID, docID, true)
+	if err != nil {
+		slog.Error("failed to get document", "error", err)
+		storeError(w, err, "failed to get document")
+		return
+	}
+	if doc == nil {
+		jsonError(w, http.StatusNotFound, "document not found")
+		return
+	}
+	ServeDocument(w, doc)
+}
+
+// ServeDocument writes a document's data as a download. The web UI's
+// cookie-authenticated route uses it too.
+func ServeDocument(w http.ResponseWriter, doc *model.Document) {
+	w.Header().Set("Content-Type", doc.MIME)
+	w.Header().Set("Content-Length", strconv.Itoa(len(doc.Data)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if _, err := w.Write(doc.Data); err != nil {
+		slog.Error("failed to write document response", "error", err)
+	}
+}
+
+// Delete handles DELETE /api/items/{id}/documents/{docID}.
+func (h *DocumentsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	item := h.getItem(w, r)
+	if item == nil {
+		return
+	}
+	docID, err := strconv.ParseInt(r.PathValue("docID"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+
+	if err := h.Store.DeleteDocument(r.Context(), item.ID, docID); err != nil {
+		if invalid(err) {
+			jsonError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		slog.Error("failed to delete document", "error", err)
+		storeError(w, err, "failed to delete document")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("item document deleted", "user", claims.Username, "item", item.Name, "document_id", docID)
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "document deleted"})
+}
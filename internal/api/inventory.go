@@ -1,10 +1,17 @@
 package api
 
 import (
-	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/erazemk/skladisce/internal/model"
 	"github.com/erazemk/skladisce/internal/store"
@@ -12,28 +19,148 @@ import (
 
 // InventoryHandler handles inventory endpoints.
 type InventoryHandler struct {
-	DB *sql.DB
+	Store       store.Store
+	MaxPageSize int
 }
 
 type addStockRequest struct {
-	ItemID   int64 `json:"item_id"`
-	OwnerID  int64 `json:"owner_id"`
-	Quantity int   `json:"quantity"`
+	ItemID   int64   `json:"item_id"`
+	OwnerID  int64   `json:"owner_id"`
+	Quantity float64 `json:"quantity"`
+}
+
+type addStockBatchRequest struct {
+	OwnerID int64              `json:"owner_id"`
+	Lines   []stockLineRequest `json:"lines"`
+}
+
+type stockLineRequest struct {
+	ItemID   int64   `json:"item_id"`
+	Quantity float64 `json:"quantity"`
+}
+
+type addStockBatchResponse struct {
+	model.BulkResult
+	OwnerID int64                   `json:"owner_id"`
+	Lines   []model.StockLineResult `json:"lines"`
+}
+
+// addStockBatchFailure is the 400 body of a rejected batch. The batch is
+// all-or-nothing, so every line counts as failed and Errors holds the line
+// that stopped it.
+type addStockBatchFailure struct {
+	model.BulkResult
+	Error  string `json:"error"`
+	Line   int    `json:"line"`
+	ItemID int64  `json:"item_id"`
+}
+
+// maxStockBatchLines caps the number of lines in one batch stock request.
+const maxStockBatchLines = 500
+
+type setStockRequest struct {
+	ItemID   int64    `json:"item_id"`
+	OwnerID  int64    `json:"owner_id"`
+	Quantity *float64 `json:"quantity"`
+}
+
+type setStockResponse struct {
+	ItemID    int64       `json:"item_id"`
+	OwnerID   int64       `json:"owner_id"`
+	Quantity  json.Number `json:"quantity"`
+	Delta     json.Number `json:"delta"`
+	Divisible bool        `json:"divisible,omitempty"`
 }
 
 type adjustRequest struct {
-	ItemID  int64  `json:"item_id"`
-	OwnerID int64  `json:"owner_id"`
-	Delta   int    `json:"delta"`
-	Notes   string `json:"notes"`
+	ItemID  int64   `json:"item_id"`
+	OwnerID int64   `json:"owner_id"`
+	Delta   float64 `json:"delta"`
+	Notes   string  `json:"notes"`
+}
+
+// itemQuantity converts a quantity from a request body to the stored units
+// of an item (see model.ParseQuantity) and reports whether the item is
+// divisible. The flag goes to the store with the quantity, which re-checks
+// it inside its transaction (see store.ErrDivisibleChanged). Request
+// quantities are float64 so divisible items can take decimals; the shortest
+// decimal form of the number is what gets parsed. Errors for the quantity
+// itself are store.ValidationErrors; a missing item converts as a whole
+// number and is left to the store's checks.
+func itemQuantity(r *http.Request, st store.Store, itemID int64, quantity float64) (int, bool, error) {
+	divisible, err := st.ItemDivisible(r.Context(), itemID)
+	if err != nil {
+		return 0, false, err
+	}
+	q, err := model.ParseQuantity(strconv.FormatFloat(quantity, 'f', -1, 64), divisible)
+	if err != nil {
+		return 0, divisible, &store.ValidationError{Err: err}
+	}
+	return q, divisible, nil
+}
+
+// quantityError responds to an itemQuantity error.
+func quantityError(w http.ResponseWriter, err error) {
+	if invalid(err) {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	slog.Error("failed to check item", "error", err)
+	storeError(w, err, "failed to check item")
 }
 
+// inventoryPageDefaultLimit is the inventory list page size when ?offset is
+// given without ?limit.
+const inventoryPageDefaultLimit = 50
+
 // List handles GET /api/inventory.
 func (h *InventoryHandler) List(w http.ResponseWriter, r *http.Request) {
-	inventory, err := store.ListInventory(r.Context(), h.DB)
+	q := r.URL.Query()
+
+	var itemID int64
+	if v := q.Get("item_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid item_id")
+			return
+		}
+		itemID = id
+	}
+
+	ownerType := q.Get("owner_type")
+	if ownerType != "" && ownerType != model.OwnerTypePerson && ownerType != model.OwnerTypeLocation {
+		jsonError(w, http.StatusBadRequest, "owner_type must be 'person' or 'location'")
+		return
+	}
+
+	query := strings.TrimSpace(q.Get("q"))
+
+	// Pagination is opt-in; without limit/offset the first 1000 rows are
+	// returned.
+	if q.Has("limit") || q.Has("offset") {
+		limit, offset, err := parsePagination(w, r, inventoryPageDefaultLimit, h.MaxPageSize)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		inventory, total, err := h.Store.ListInventoryPage(r.Context(), itemID, ownerType, query, limit, offset)
+		if err != nil {
+			slog.Error("failed to list inventory", "error", err)
+			storeError(w, err, "failed to list inventory")
+			return
+		}
+		if inventory == nil {
+			inventory = []model.Inventory{}
+		}
+		setPaginationHeaders(w, r, limit, offset, total)
+		jsonResponse(w, http.StatusOK, inventory)
+		return
+	}
+
+	inventory, err := h.Store.ListInventory(r.Context(), itemID, ownerType, query)
 	if err != nil {
 		slog.Error("failed to list inventory", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to list inventory")
+		storeError(w, err, "failed to list inventory")
 		return
 	}
 	if inventory == nil {
@@ -42,15 +169,55 @@ func (h *InventoryHandler) List(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, inventory)
 }
 
+// inventoryChangesResponse is returned by GET /api/inventory/changes. Clients
+// pass AsOf as the next since value.
+type inventoryChangesResponse struct {
+	AsOf    time.Time               `json:"as_of"`
+	Changes []model.InventoryChange `json:"changes"`
+}
+
+// Changes handles GET /api/inventory/changes?since=<RFC3339>.
+func (h *InventoryHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	sinceStr := r.URL.Query().Get("since")
+	if sinceStr == "" {
+		jsonError(w, http.StatusBadRequest, "since is required")
+		return
+	}
+	since, err := time.Parse(time.RFC3339, sinceStr)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+		return
+	}
+
+	// Timestamps have second precision, so as_of is truncated to keep rows
+	// written later in the same second in the next response.
+	asOf := time.Now().UTC().Truncate(time.Second)
+	changes, err := h.Store.ListInventoryChanges(r.Context(), since)
+	if err != nil {
+		slog.Error("failed to list inventory changes", "error", err)
+		storeError(w, err, "failed to list inventory changes")
+		return
+	}
+	if changes == nil {
+		changes = []model.InventoryChange{}
+	}
+	jsonResponse(w, http.StatusOK, inventoryChangesResponse{AsOf: asOf, Changes: changes})
+}
+
 // AddStock handles POST /api/inventory/stock.
 func (h *InventoryHandler) AddStock(w http.ResponseWriter, r *http.Request) {
 	var req addStockRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
-	if req.ItemID <= 0 || req.OwnerID <= 0 || req.Quantity <= 0 {
+	quantity, divisible, err := itemQuantity(r, h.Store, req.ItemID, req.Quantity)
+	if err != nil {
+		quantityError(w, err)
+		return
+	}
+	if req.ItemID <= 0 || req.OwnerID <= 0 || quantity <= 0 {
 		jsonError(w, http.StatusBadRequest, "item_id, owner_id, and quantity are required and must be positive")
 		return
 	}
@@ -61,14 +228,19 @@ func (h *InventoryHandler) AddStock(w http.ResponseWriter, r *http.Request) {
 		userID = &claims.UserID
 	}
 
-	if err := store.AddStock(r.Context(), h.DB, req.ItemID, req.OwnerID, req.Quantity, userID); err != nil {
-		slog.Warn("failed to add stock", "error", err)
-		jsonError(w, http.StatusBadRequest, "failed to add stock: owner not found or invalid parameters")
+	if err := h.Store.AddStock(r.Context(), req.ItemID, req.OwnerID, quantity, divisible, userID); err != nil {
+		if invalid(err) {
+			slog.Warn("failed to add stock", "error", err)
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to add stock", "error", err)
+		storeError(w, err, "failed to add stock")
 		return
 	}
 
-	item, _ := store.GetItem(r.Context(), h.DB, req.ItemID)
-	owner, _ := store.GetOwner(r.Context(), h.DB, req.OwnerID)
+	item, _ := h.Store.GetItem(r.Context(), req.ItemID)
+	owner, _ := h.Store.GetOwner(r.Context(), req.OwnerID)
 	itemName := fmt.Sprintf("id:%d", req.ItemID)
 	ownerName := fmt.Sprintf("id:%d", req.OwnerID)
 	if item != nil {
@@ -81,33 +253,343 @@ func (h *InventoryHandler) AddStock(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "stock added"})
 }
 
+// SetStock handles PUT /api/inventory/stock. It sets the held quantity to an
+// exact value; repeating the same request changes nothing.
+func (h *InventoryHandler) SetStock(w http.ResponseWriter, r *http.Request) {
+	var req setStockRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	if req.Quantity == nil {
+		jsonError(w, http.StatusBadRequest, "item_id, owner_id, and non-negative quantity required")
+		return
+	}
+	quantity, divisible, err := itemQuantity(r, h.Store, req.ItemID, *req.Quantity)
+	if err != nil {
+		quantityError(w, err)
+		return
+	}
+	if req.ItemID <= 0 || req.OwnerID <= 0 || quantity < 0 {
+		jsonError(w, http.StatusBadRequest, "item_id, owner_id, and non-negative quantity required")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	var userID *int64
+	if claims != nil {
+		userID = &claims.UserID
+	}
+
+	previous, err := h.Store.SetStock(r.Context(), req.ItemID, req.OwnerID, quantity, divisible, userID)
+	if err != nil {
+		if invalid(err) {
+			slog.Warn("failed to set stock", "error", err)
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to set stock", "error", err)
+		storeError(w, err, "failed to set stock")
+		return
+	}
+
+	delta := quantity - previous
+	if delta != 0 {
+		item, _ := h.Store.GetItem(r.Context(), req.ItemID)
+		owner, _ := h.Store.GetOwner(r.Context(), req.OwnerID)
+		itemName := fmt.Sprintf("id:%d", req.ItemID)
+		ownerName := fmt.Sprintf("id:%d", req.OwnerID)
+		if item != nil {
+			itemName = item.Name
+		}
+		if owner != nil {
+			ownerName = owner.Name
+		}
+		slog.Info("stock set", "user", claims.Username, "item", itemName, "owner", ownerName, "quantity", model.FormatQuantity(quantity, divisible), "delta", model.FormatQuantity(delta, divisible))
+	}
+	jsonResponse(w, http.StatusOK, setStockResponse{
+		ItemID:    req.ItemID,
+		OwnerID:   req.OwnerID,
+		Quantity:  model.QuantityNumber(quantity, divisible),
+		Delta:     model.QuantityNumber(delta, divisible),
+		Divisible: divisible,
+	})
+}
+
+// AddStockBatch handles POST /api/inventory/stock/batch.
+// All lines are applied in one transaction; if any line fails, nothing is
+// applied and the failing line is reported. Both outcomes carry a
+// model.BulkResult summary.
+func (h *InventoryHandler) AddStockBatch(w http.ResponseWriter, r *http.Request) {
+	var req addStockBatchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	if req.OwnerID <= 0 || len(req.Lines) == 0 {
+		jsonError(w, http.StatusBadRequest, "owner_id and at least one line are required")
+		return
+	}
+	if len(req.Lines) > maxStockBatchLines {
+		jsonError(w, http.StatusBadRequest, fmt.Sprintf("at most %d lines per batch", maxStockBatchLines))
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	var userID *int64
+	if claims != nil {
+		userID = &claims.UserID
+	}
+
+	lines := make([]model.StockLine, len(req.Lines))
+	for i, line := range req.Lines {
+		quantity, divisible, err := itemQuantity(r, h.Store, line.ItemID, line.Quantity)
+		if err != nil && !invalid(err) {
+			quantityError(w, err)
+			return
+		}
+		if err != nil {
+			stockBatchFailure(w, len(req.Lines), &store.StockLineError{Line: i, ItemID: line.ItemID, Err: err})
+			return
+		}
+		lines[i] = model.StockLine{ItemID: line.ItemID, Quantity: quantity, Divisible: divisible}
+	}
+
+	results, err := h.Store.AddStockBatch(r.Context(), req.OwnerID, lines, userID)
+	if err != nil {
+		var lineErr *store.StockLineError
+		if errors.As(err, &lineErr) {
+			stockBatchFailure(w, len(req.Lines), lineErr)
+			return
+		}
+		if invalid(err) {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to add stock batch", "error", err)
+		storeError(w, err, "failed to add stock")
+		return
+	}
+
+	ownerName := fmt.Sprintf("id:%d", req.OwnerID)
+	if owner, _ := h.Store.GetOwner(r.Context(), req.OwnerID); owner != nil {
+		ownerName = owner.Name
+	}
+	summary := model.NewBulkResult()
+	for _, res := range results {
+		summary.Succeed()
+		itemName := fmt.Sprintf("id:%d", res.ItemID)
+		if item, _ := h.Store.GetItem(r.Context(), res.ItemID); item != nil {
+			itemName = item.Name
+		}
+		slog.Info("stock added", "user", claims.Username, "item", itemName, "owner", ownerName, "quantity", model.FormatQuantity(res.Added, res.Divisible), "batch", true)
+	}
+	jsonResponse(w, http.StatusOK, addStockBatchResponse{BulkResult: summary, OwnerID: req.OwnerID, Lines: results})
+}
+
+// stockBatchFailure responds to a batch of requested lines rejected because
+// of one of them.
+func stockBatchFailure(w http.ResponseWriter, requested int, lineErr *store.StockLineError) {
+	slog.Warn("batch stock addition failed", "error", lineErr)
+	jsonResponse(w, http.StatusBadRequest, addStockBatchFailure{
+		BulkResult: model.BulkResult{
+			Requested: requested,
+			Failed:    requested,
+			Errors:    []model.BulkError{{Index: lineErr.Line, Message: lineErr.Err.Error()}},
+		},
+		Error:  lineErr.Err.Error(),
+		Line:   lineErr.Line,
+		ItemID: lineErr.ItemID,
+	})
+}
+
+// maxStockImportRows caps the number of data rows in one CSV stock import.
+const maxStockImportRows = 5000
+
+// stockImportResponse embeds the model.BulkResult summary, whose error
+// indexes are zero-based data rows. Applied is false for a dry run and for an
+// import with any failed row.
+type stockImportResponse struct {
+	model.BulkResult
+	DryRun  bool                      `json:"dry_run"`
+	Applied bool                      `json:"applied"`
+	Rows    []model.StockImportResult `json:"rows"`
+}
+
+// stockImportColumns maps the accepted CSV header names to the column they
+// fill. Items have no SKU, so items and owners are referenced by id or name
+// under either header.
+var stockImportColumns = map[string]string{
+	"item":     "item",
+	"item_id":  "item",
+	"owner":    "owner",
+	"owner_id": "owner",
+	"quantity": "quantity",
+}
+
+// Import handles POST /api/inventory/import[?dry_run=true]. The body is CSV
+// with a header row naming the item, owner and quantity columns. Every row is
+// resolved and reported; the rows are added in one transaction, and only if
+// all of them are valid and this is not a dry run.
+func (h *InventoryHandler) Import(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/csv" {
+		jsonError(w, http.StatusUnsupportedMediaType, "content type must be text/csv")
+		return
+	}
+	defer r.Body.Close()
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	rows, msg, err := readStockImport(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			jsonError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		jsonError(w, http.StatusBadRequest, "invalid CSV: "+err.Error())
+		return
+	}
+	if msg != "" {
+		jsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	results, applied, err := h.Store.ImportStock(r.Context(), rows, dryRun)
+	if err != nil {
+		slog.Error("failed to import stock", "error", err)
+		storeError(w, err, "failed to import stock")
+		return
+	}
+
+	// The import is all-or-nothing, so one failed row fails every row; a dry
+	// run of valid rows counts them as succeeded.
+	resp := stockImportResponse{BulkResult: model.NewBulkResult(), DryRun: dryRun, Applied: applied, Rows: results}
+	for i, res := range results {
+		if res.Error != "" {
+			resp.Errors = append(resp.Errors, model.BulkError{Index: i, Message: fmt.Sprintf("line %d: %s", res.Line, res.Error)})
+		}
+	}
+	resp.Requested = len(results)
+	if len(resp.Errors) == 0 {
+		resp.Succeeded = len(results)
+	} else {
+		resp.Failed = len(results)
+	}
+
+	claims := GetClaims(r.Context())
+	if applied {
+		slog.Info("stock imported", "user", claims.Username, "rows", len(results))
+	}
+	status := http.StatusOK
+	if !applied && !dryRun {
+		status = http.StatusBadRequest
+	}
+	jsonResponse(w, status, resp)
+}
+
+// readStockImport parses a CSV stock import. It returns a message instead of
+// rows if the header or the number of rows is not acceptable. Quantities are
+// left as written for ImportStock to parse, since only the resolved item
+// tells whether decimals are allowed.
+func readStockImport(body io.Reader) ([]model.StockImportRow, string, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errEmptyBody.Error(), nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		column, ok := stockImportColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Sprintf("unknown column: %q", name), nil
+		}
+		if _, dup := index[column]; dup {
+			return nil, fmt.Sprintf("duplicate %s column", column), nil
+		}
+		index[column] = i
+	}
+	for _, column := range []string{"item", "owner", "quantity"} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Sprintf("missing %s column", column), nil
+		}
+	}
+
+	var rows []model.StockImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if len(rows) == maxStockImportRows {
+			return nil, fmt.Sprintf("at most %d rows per import", maxStockImportRows), nil
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, model.StockImportRow{
+			Line:     line,
+			Item:     strings.TrimSpace(record[index["item"]]),
+			Owner:    strings.TrimSpace(record[index["owner"]]),
+			Quantity: strings.TrimSpace(record[index["quantity"]]),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, "no rows to import", nil
+	}
+	return rows, "", nil
+}
+
 // Adjust handles POST /api/inventory/adjust.
 func (h *InventoryHandler) Adjust(w http.ResponseWriter, r *http.Request) {
 	var req adjustRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
-	if req.ItemID <= 0 || req.OwnerID <= 0 || req.Delta == 0 {
+	delta, divisible, err := itemQuantity(r, h.Store, req.ItemID, req.Delta)
+	if err != nil {
+		quantityError(w, err)
+		return
+	}
+	if req.ItemID <= 0 || req.OwnerID <= 0 || delta == 0 {
 		jsonError(w, http.StatusBadRequest, "item_id, owner_id, and non-zero delta required")
 		return
 	}
 
+	if err := model.ValidateNotes(req.Notes); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	claims := GetClaims(r.Context())
 	var userID *int64
 	if claims != nil {
 		userID = &claims.UserID
 	}
 
-	if err := store.AdjustInventory(r.Context(), h.DB, req.ItemID, req.OwnerID, req.Delta, req.Notes, userID); err != nil {
-		slog.Warn("failed to adjust inventory", "error", err)
-		jsonError(w, http.StatusBadRequest, "adjustment failed: would result in negative quantity or invalid parameters")
+	if err := h.Store.AdjustInventory(r.Context(), req.ItemID, req.OwnerID, delta, divisible, req.Notes, userID); err != nil {
+		if invalid(err) {
+			slog.Warn("failed to adjust inventory", "error", err)
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to adjust inventory", "error", err)
+		storeError(w, err, "failed to adjust inventory")
 		return
 	}
 
-	item, _ := store.GetItem(r.Context(), h.DB, req.ItemID)
-	owner, _ := store.GetOwner(r.Context(), h.DB, req.OwnerID)
+	item, _ := h.Store.GetItem(r.Context(), req.ItemID)
+	owner, _ := h.Store.GetOwner(r.Context(), req.OwnerID)
 	itemName := fmt.Sprintf("id:%d", req.ItemID)
 	ownerName := fmt.Sprintf("id:%d", req.OwnerID)
 	if item != nil {
@@ -119,3 +601,83 @@ func (h *InventoryHandler) Adjust(w http.ResponseWriter, r *http.Request) {
 	slog.Info("inventory adjusted", "user", claims.Username, "item", itemName, "owner", ownerName, "delta", req.Delta)
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "inventory adjusted"})
 }
+
+type createSnapshotRequest struct {
+	Notes string `json:"notes"`
+}
+
+// CreateSnapshot handles POST /api/inventory/snapshots. It records every
+// current balance so a later diff can compare against it.
+func (h *InventoryHandler) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	var req createSnapshotRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			decodeError(w, err)
+			return
+		}
+	}
+	if err := model.ValidateNotes(req.Notes); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	var userID *int64
+	if claims != nil {
+		userID = &claims.UserID
+	}
+
+	snapshot, err := h.Store.CreateStockSnapshot(r.Context(), req.Notes, userID)
+	if err != nil {
+		slog.Error("failed to create stock snapshot", "error", err)
+		storeError(w, err, "failed to create stock snapshot")
+		return
+	}
+	slog.Info("stock snapshot taken", "user", claims.Username, "id", snapshot.ID, "lines", snapshot.Lines)
+	jsonResponse(w, http.StatusCreated, snapshot)
+}
+
+// ListSnapshots handles GET /api/inventory/snapshots.
+func (h *InventoryHandler) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.Store.ListStockSnapshots(r.Context())
+	if err != nil {
+		slog.Error("failed to list stock snapshots", "error", err)
+		storeError(w, err, "failed to list stock snapshots")
+		return
+	}
+	if snapshots == nil {
+		snapshots = []model.StockSnapshot{}
+	}
+	jsonResponse(w, http.StatusOK, snapshots)
+}
+
+// DiffSnapshots handles GET /api/inventory/snapshots/diff?from=<id>&to=<id|current>.
+// to defaults to current, the live inventory.
+func (h *InventoryHandler) DiffSnapshots(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	fromID, err := strconv.ParseInt(q.Get("from"), 10, 64)
+	if err != nil || fromID <= 0 {
+		jsonError(w, http.StatusBadRequest, "invalid from")
+		return
+	}
+	var toID int64
+	if to := q.Get("to"); to != "" && to != "current" {
+		toID, err = strconv.ParseInt(to, 10, 64)
+		if err != nil || toID <= 0 {
+			jsonError(w, http.StatusBadRequest, "invalid to: must be a snapshot ID or current")
+			return
+		}
+	}
+
+	diff, err := h.Store.DiffStockSnapshots(r.Context(), fromID, toID)
+	if err != nil {
+		slog.Error("failed to diff stock snapshots", "error", err)
+		storeError(w, err, "failed to diff stock snapshots")
+		return
+	}
+	if diff == nil {
+		jsonError(w, http.StatusNotFound, "snapshot not found")
+		return
+	}
+	jsonResponse(w, http.StatusOK, diff)
+}
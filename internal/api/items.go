@@ -1,11 +1,15 @@
 package api
 
 import (
-	"database/sql"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/erazemk/skladisce/internal/imaging"
 	"github.com/erazemk/skladisce/internal/model"
@@ -14,72 +18,316 @@ import (
 
 // ItemsHandler handles item CRUD endpoints.
 type ItemsHandler struct {
-	DB *sql.DB
+	Store store.Store
+
+	// MaxPageSize caps ?limit on paginated listings.
+	MaxPageSize int
 }
 
 type createItemRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Condition   string `json:"condition"`
+	Divisible   bool   `json:"divisible"`
+}
+
+// deleteRequest is the optional body of item and owner deletes and of item
+// decommissioning.
+type deleteRequest struct {
+	Reason string `json:"reason"`
 }
 
 type updateItemRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Condition   string `json:"condition"`
 	Status      string `json:"status"`
+	Reason      string `json:"reason"`
+}
+
+// minQuantityRequest is the body of PUT /api/items/{id}/min-quantity. A null
+// or missing min_quantity removes the threshold. It may have decimals if the
+// item is divisible (see itemQuantity).
+type minQuantityRequest struct {
+	MinQuantity *float64 `json:"min_quantity"`
+}
+
+// reorderTargetRequest is the body of PUT /api/items/{id}/reorder-target. A
+// null or missing reorder_target removes the target. Like min_quantity it may
+// have decimals if the item is divisible.
+type reorderTargetRequest struct {
+	ReorderTarget *float64 `json:"reorder_target"`
+}
+
+// serializedRequest is the body of PUT /api/items/{id}/serialized.
+type serializedRequest struct {
+	Serialized *bool `json:"serialized"`
+}
+
+// divisibleRequest is the body of PUT /api/items/{id}/divisible.
+type divisibleRequest struct {
+	Divisible *bool `json:"divisible"`
+}
+
+// itemSearchLimit caps the number of results returned by an item search.
+const itemSearchLimit = 50
+
+// includeDeleted reports whether the request asks for a soft-deleted record
+// with ?include_deleted=true. Only admins may ask; anyone else gets 403
+// before any lookup, so the answer is the same whether the record exists or
+// not, and ok is false.
+func includeDeleted(w http.ResponseWriter, r *http.Request) (include, ok bool) {
+	if r.URL.Query().Get("include_deleted") != "true" {
+		return false, true
+	}
+	claims := GetClaims(r.Context())
+	if claims == nil || !model.RoleAtLeast(claims.Role, model.RoleAdmin) {
+		jsonError(w, http.StatusForbidden, "insufficient permissions")
+		return false, false
+	}
+	return true, true
+}
+
+// visibleItem loads item id for a read, writing the error response and
+// returning nil if it is missing or soft-deleted. Both answer the same 404,
+// unless an admin passes ?include_deleted=true to see deleted items.
+func (h *ItemsHandler) visibleItem(w http.ResponseWriter, r *http.Request, id int64) *model.Item {
+	include, ok := includeDeleted(w, r)
+	if !ok {
+		return nil
+	}
+	item, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to get item")
+		return nil
+	}
+	if item == nil || (item.DeletedAt != nil && !include) {
+		jsonError(w, http.StatusNotFound, "item not found")
+		return nil
+	}
+	return item
 }
 
-// List handles GET /api/items.
+// List handles GET /api/items. With ?q= it searches names, descriptions and
+// conditions instead of listing everything; ?status= and ?has_image= narrow
+// either.
 func (h *ItemsHandler) List(w http.ResponseWriter, r *http.Request) {
-	status := r.URL.Query().Get("status")
-	items, err := store.ListItems(r.Context(), h.DB, status)
+	filter := model.ItemFilter{Status: r.URL.Query().Get("status")}
+	if v := r.URL.Query().Get("has_image"); v != "" {
+		hasImage, err := strconv.ParseBool(v)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid has_image")
+			return
+		}
+		filter.HasImage = &hasImage
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var items []model.Item
+	var err error
+	if query != "" {
+		items, err = h.Store.SearchItems(r.Context(), query, filter, itemSearchLimit)
+	} else {
+		items, err = h.Store.ListItems(r.Context(), filter)
+	}
 	if err != nil {
 		slog.Error("failed to list items", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to list items")
+		storeError(w, err, "failed to list items")
 		return
 	}
 	if items == nil {
 		items = []model.Item{}
 	}
+
+	if r.URL.Query().Get("favorites_first") == "true" {
+		favorites, err := h.Store.ListFavorites(r.Context(), GetClaims(r.Context()).UserID)
+		if err != nil {
+			slog.Error("failed to list favorites", "error", err)
+			storeError(w, err, "failed to list items")
+			return
+		}
+		pinned := make(map[int64]bool, len(favorites))
+		for _, f := range favorites {
+			pinned[f.ID] = true
+		}
+		slices.SortStableFunc(items, func(a, b model.Item) int {
+			switch {
+			case pinned[a.ID] && !pinned[b.ID]:
+				return -1
+			case !pinned[a.ID] && pinned[b.ID]:
+				return 1
+			}
+			return 0
+		})
+	}
 	jsonResponse(w, http.StatusOK, items)
 }
 
+// suggestDefaultLimit is how many suggestions GET /api/items/suggest returns
+// without ?limit.
+const suggestDefaultLimit = 10
+
+type batchGetItemsRequest struct {
+	IDs  []int64  `json:"ids"`
+	SKUs []string `json:"skus"`
+}
+
+type batchGetItemsResponse struct {
+	Items    []model.Item `json:"items"`
+	NotFound []int64      `json:"not_found"`
+}
+
+// maxBatchGetItems caps the number of IDs in one batch-get request.
+const maxBatchGetItems = 500
+
+// BatchGet handles POST /api/items/batch-get. It resolves many item IDs in
+// one query and returns the items in request order, duplicates dropped,
+// with the IDs that match no (non-deleted) item in not_found.
+func (h *ItemsHandler) BatchGet(w http.ResponseWriter, r *http.Request) {
+	var req batchGetItemsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	if req.SKUs != nil {
+		jsonError(w, http.StatusBadRequest, "items have no SKU; look them up by ids")
+		return
+	}
+	if len(req.IDs) > maxBatchGetItems {
+		jsonError(w, http.StatusBadRequest, fmt.Sprintf("at most %d ids per request", maxBatchGetItems))
+		return
+	}
+	var ids []int64
+	seen := make(map[int64]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		jsonError(w, http.StatusBadRequest, "ids required")
+		return
+	}
+
+	items, err := h.Store.GetItems(r.Context(), ids)
+	if err != nil {
+		slog.Error("failed to get items", "error", err)
+		storeError(w, err, "failed to get items")
+		return
+	}
+	byID := make(map[int64]model.Item, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	resp := batchGetItemsResponse{Items: []model.Item{}, NotFound: []int64{}}
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			resp.Items = append(resp.Items, item)
+		} else {
+			resp.NotFound = append(resp.NotFound, id)
+		}
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// Suggest handles GET /api/items/suggest?q=&limit=. It returns items whose
+// name contains q as {id, name, has_image}, ordered by name, for typeahead
+// pickers. limit defaults to 10 and is capped at itemSearchLimit.
+func (h *ItemsHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		jsonError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := suggestDefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			jsonError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, itemSearchLimit)
+	}
+
+	suggestions, err := h.Store.SuggestItems(r.Context(), query, limit)
+	if err != nil {
+		slog.Error("failed to suggest items", "error", err)
+		storeError(w, err, "failed to suggest items")
+		return
+	}
+	if suggestions == nil {
+		suggestions = []model.ItemSuggestion{}
+	}
+	jsonResponse(w, http.StatusOK, suggestions)
+}
+
 // Create handles POST /api/items.
 func (h *ItemsHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req createItemRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
+		return
+	}
+
+	var err error
+	if req.Name, err = model.NormalizeName(req.Name); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if req.Name == "" {
-		jsonError(w, http.StatusBadRequest, "name required")
+	if err := model.ValidateDescription(req.Description); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := model.ValidateCondition(req.Condition); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	item, err := store.CreateItem(r.Context(), h.DB, req.Name, req.Description)
+	item, err := h.Store.CreateItem(r.Context(), req.Name, req.Description, req.Condition)
 	if err != nil {
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			jsonError(w, http.StatusForbidden, err.Error())
+			return
+		}
 		slog.Error("failed to create item", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to create item")
+		storeError(w, err, "failed to create item")
 		return
 	}
+	if req.Divisible {
+		if err := h.Store.SetItemDivisible(r.Context(), item.ID, true); err != nil {
+			slog.Error("failed to set item divisible", "error", err)
+			storeError(w, err, "failed to create item")
+			return
+		}
+		item.Divisible = true
+	}
 
 	claims := GetClaims(r.Context())
 	slog.Info("item created", "user", claims.Username, "item", req.Name)
 	jsonResponse(w, http.StatusCreated, item)
 }
 
-// Get handles GET /api/items/{id}.
-func (h *ItemsHandler) Get(w http.ResponseWriter, r *http.Request) {
+// Clone handles POST /api/items/{id}/clone?with_image=true.
+func (h *ItemsHandler) Clone(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
 		jsonError(w, http.StatusBadRequest, "invalid item id")
 		return
 	}
+	withImage := r.URL.Query().Get("with_image") == "true"
 
-	item, err := store.GetItem(r.Context(), h.DB, id)
+	item, err := h.Store.CloneItem(r.Context(), id, withImage)
 	if err != nil {
-		slog.Error("failed to get item", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to get item")
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			jsonError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		slog.Error("failed to clone item", "error", err)
+		storeError(w, err, "failed to clone item")
 		return
 	}
 	if item == nil {
@@ -87,11 +335,29 @@ func (h *ItemsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	claims := GetClaims(r.Context())
+	slog.Info("item cloned", "user", claims.Username, "source_id", id, "item", item.Name)
+	jsonResponse(w, http.StatusCreated, item)
+}
+
+// Get handles GET /api/items/{id}.
+func (h *ItemsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	item := h.visibleItem(w, r, id)
+	if item == nil {
+		return
+	}
+
 	// Get distribution as well.
-	dist, err := store.GetItemDistribution(r.Context(), h.DB, id)
+	dist, err := h.Store.GetItemDistribution(r.Context(), id)
 	if err != nil {
 		slog.Error("failed to get item distribution", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to get item distribution")
+		storeError(w, err, "failed to get item distribution")
 		return
 	}
 	if dist == nil {
@@ -114,32 +380,53 @@ func (h *ItemsHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	var req updateItemRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
-	if req.Name == "" {
-		jsonError(w, http.StatusBadRequest, "name required")
+	if req.Name, err = model.NormalizeName(req.Name); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := model.ValidateDescription(req.Description); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := model.ValidateCondition(req.Condition); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	if req.Status == "" {
 		req.Status = model.ItemStatusActive
 	}
-	if req.Status != model.ItemStatusActive && req.Status != model.ItemStatusDamaged && req.Status != model.ItemStatusLost && req.Status != model.ItemStatusRemoved {
-		jsonError(w, http.StatusBadRequest, "invalid status")
+
+	existing, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to update item")
+		return
+	}
+	if existing == nil || existing.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "item not found")
 		return
 	}
 
-	if err := store.UpdateItem(r.Context(), h.DB, id, req.Name, req.Description, req.Status); err != nil {
+	claims := GetClaims(r.Context())
+	if err := h.Store.UpdateItem(r.Context(), id, req.Name, req.Description, req.Condition, req.Status, req.Reason, &claims.UserID); err != nil {
+		// Unknown statuses are rejected by the store.
+		if invalid(err) {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		slog.Error("failed to update item", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to update item")
+		storeError(w, err, "failed to update item")
 		return
 	}
 
-	claims := GetClaims(r.Context())
 	slog.Info("item updated", "user", claims.Username, "item", req.Name, "status", req.Status)
-	item, _ := store.GetItem(r.Context(), h.DB, id)
+	item, _ := h.Store.GetItem(r.Context(), id)
 	jsonResponse(w, http.StatusOK, item)
 }
 
@@ -151,23 +438,307 @@ func (h *ItemsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	item, _ := store.GetItem(r.Context(), h.DB, id)
+	// The body is optional; htmx deletes send none (but a form Content-Type).
+	var req deleteRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil && !errors.Is(err, errEmptyBody) {
+			decodeError(w, err)
+			return
+		}
+	}
+
+	item, _ := h.Store.GetItem(r.Context(), id)
 	itemName := fmt.Sprintf("id:%d", id)
 	if item != nil {
 		itemName = item.Name
 	}
 
-	if err := store.DeleteItem(r.Context(), h.DB, id); err != nil {
+	claims := GetClaims(r.Context())
+	if err := h.Store.DeleteItem(r.Context(), id, &claims.UserID, req.Reason); err != nil {
 		slog.Error("failed to delete item", "error", err)
 		jsonError(w, http.StatusNotFound, "item not found")
 		return
 	}
 
-	claims := GetClaims(r.Context())
-	slog.Info("item deleted", "user", claims.Username, "item", itemName)
+	slog.Info("item deleted", "user", claims.Username, "item", itemName, "reason", req.Reason)
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "item deleted"})
 }
 
+// Decommission handles POST /api/items/{id}/decommission. It removes all of
+// the item's stock as decommissioned adjustments and sets its status to
+// removed, in one transaction.
+func (h *ItemsHandler) Decommission(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req deleteRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil && !errors.Is(err, errEmptyBody) {
+			decodeError(w, err)
+			return
+		}
+	}
+
+	claims := GetClaims(r.Context())
+	result, err := h.Store.DecommissionItem(r.Context(), id, req.Reason, &claims.UserID)
+	if err != nil {
+		if invalid(err) {
+			jsonError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		slog.Error("failed to decommission item", "error", err)
+		storeError(w, err, "failed to decommission item")
+		return
+	}
+
+	removed := 0
+	for _, a := range result.Adjustments {
+		removed -= a.Delta
+	}
+	slog.Info("item decommissioned", "user", claims.Username, "item", result.Item.Name, "removed", model.FormatQuantity(removed, result.Item.Divisible), "reason", req.Reason)
+	jsonResponse(w, http.StatusOK, result)
+}
+
+// Restore handles POST /api/items/{id}/restore.
+func (h *ItemsHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	if err := h.Store.RestoreItem(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			jsonError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		slog.Warn("failed to restore item", "id", id, "error", err)
+		jsonError(w, http.StatusNotFound, "deleted item not found")
+		return
+	}
+
+	item, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to get item")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("item restored", "user", claims.Username, "item", item.Name)
+	jsonResponse(w, http.StatusOK, item)
+}
+
+// SetMinQuantity handles PUT /api/items/{id}/min-quantity. It sets the
+// low-stock threshold the background scanner alerts on.
+func (h *ItemsHandler) SetMinQuantity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req minQuantityRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	existing, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to update item")
+		return
+	}
+	if existing == nil || existing.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "item not found")
+		return
+	}
+
+	var minQuantity *int
+	if req.MinQuantity != nil {
+		q, err := model.ParseQuantity(strconv.FormatFloat(*req.MinQuantity, 'f', -1, 64), existing.Divisible)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if q < 1 {
+			jsonError(w, http.StatusBadRequest, "min_quantity must be positive or null")
+			return
+		}
+		minQuantity = &q
+	}
+
+	if err := h.Store.SetItemMinQuantity(r.Context(), id, minQuantity); err != nil {
+		slog.Error("failed to set item min quantity", "error", err)
+		storeError(w, err, "failed to update item")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	threshold := "none"
+	if req.MinQuantity != nil {
+		threshold = model.FormatQuantity(*minQuantity, existing.Divisible)
+	}
+	slog.Info("item min quantity set", "user", claims.Username, "item", existing.Name, "min_quantity", threshold)
+	item, _ := h.Store.GetItem(r.Context(), id)
+	jsonResponse(w, http.StatusOK, item)
+}
+
+// SetReorderTarget handles PUT /api/items/{id}/reorder-target. It sets the
+// total the reorder report suggests restocking the item up to.
+func (h *ItemsHandler) SetReorderTarget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req reorderTargetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	existing, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to update item")
+		return
+	}
+	if existing == nil || existing.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "item not found")
+		return
+	}
+
+	var target *int
+	if req.ReorderTarget != nil {
+		q, err := model.ParseQuantity(strconv.FormatFloat(*req.ReorderTarget, 'f', -1, 64), existing.Divisible)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if q < 1 {
+			jsonError(w, http.StatusBadRequest, "reorder_target must be positive or null")
+			return
+		}
+		target = &q
+	}
+
+	if err := h.Store.SetItemReorderTarget(r.Context(), id, target); err != nil {
+		slog.Error("failed to set item reorder target", "error", err)
+		storeError(w, err, "failed to update item")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	logged := "none"
+	if target != nil {
+		logged = model.FormatQuantity(*target, existing.Divisible)
+	}
+	slog.Info("item reorder target set", "user", claims.Username, "item", existing.Name, "reorder_target", logged)
+	item, _ := h.Store.GetItem(r.Context(), id)
+	jsonResponse(w, http.StatusOK, item)
+}
+
+// SetSerialized handles PUT /api/items/{id}/serialized. It switches the
+// item between quantity tracking and per-unit serial tracking; the switch is
+// refused while the item has stock (or serials) of the other kind.
+func (h *ItemsHandler) SetSerialized(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req serializedRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	if req.Serialized == nil {
+		jsonError(w, http.StatusBadRequest, "serialized is required")
+		return
+	}
+
+	existing, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to update item")
+		return
+	}
+	if existing == nil || existing.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "item not found")
+		return
+	}
+
+	if err := h.Store.SetItemSerialized(r.Context(), id, *req.Serialized); err != nil {
+		if invalid(err) {
+			jsonError(w, http.StatusConflict, err.Error())
+			return
+		}
+		slog.Error("failed to set item serialized", "error", err)
+		storeError(w, err, "failed to update item")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("item serialized set", "user", claims.Username, "item", existing.Name, "serialized", *req.Serialized)
+	item, _ := h.Store.GetItem(r.Context(), id)
+	jsonResponse(w, http.StatusOK, item)
+}
+
+// SetDivisible handles PUT /api/items/{id}/divisible. It switches the item
+// between whole and decimal quantities, converting its stock, history and
+// low-stock threshold; switching back is refused while any of them is
+// fractional.
+func (h *ItemsHandler) SetDivisible(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req divisibleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	if req.Divisible == nil {
+		jsonError(w, http.StatusBadRequest, "divisible is required")
+		return
+	}
+
+	existing, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to update item")
+		return
+	}
+	if existing == nil || existing.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "item not found")
+		return
+	}
+
+	if err := h.Store.SetItemDivisible(r.Context(), id, *req.Divisible); err != nil {
+		if invalid(err) {
+			jsonError(w, http.StatusConflict, err.Error())
+			return
+		}
+		slog.Error("failed to set item divisible", "error", err)
+		storeError(w, err, "failed to update item")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("item divisible set", "user", claims.Username, "item", existing.Name, "divisible", *req.Divisible)
+	item, _ := h.Store.GetItem(r.Context(), id)
+	jsonResponse(w, http.StatusOK, item)
+}
+
 // UploadImage handles PUT /api/items/{id}/image.
 func (h *ItemsHandler) UploadImage(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
@@ -176,11 +747,14 @@ func (h *ItemsHandler) UploadImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Limit to 5 MB.
-	r.Body = http.MaxBytesReader(w, r.Body, 5<<20)
+	r.Body = http.MaxBytesReader(w, r.Body, imaging.MaxUploadSize)
 
-	if err := r.ParseMultipartForm(5 << 20); err != nil {
-		jsonError(w, http.StatusBadRequest, "file too large or invalid multipart form")
+	if err := r.ParseMultipartForm(imaging.MaxUploadSize); err != nil {
+		if imaging.TooLarge(err) {
+			jsonError(w, http.StatusRequestEntityTooLarge, imaging.TooLargeMessage)
+			return
+		}
+		jsonError(w, http.StatusBadRequest, "invalid multipart form")
 		return
 	}
 
@@ -191,27 +765,104 @@ func (h *ItemsHandler) UploadImage(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	quality, err := imaging.ParseQuality(r.FormValue("quality"))
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Process the image: validate format by sniffing bytes, downscale, compress.
-	result, err := imaging.Process(file)
+	result, err := imaging.ProcessQuality(file, quality)
 	if err != nil {
+		if imaging.TooLarge(err) {
+			jsonError(w, http.StatusRequestEntityTooLarge, imaging.TooLargeMessage)
+			return
+		}
 		jsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err := store.SetItemImage(r.Context(), h.DB, id, result.Data, result.MIME); err != nil {
+	changed, err := h.Store.SetItemImage(r.Context(), id, result.Data, result.MIME)
+	if err != nil {
 		slog.Error("failed to save image", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to save image")
+		storeError(w, err, "failed to save image")
+		return
+	}
+
+	item, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to get item")
+		return
+	}
+	if item == nil {
+		jsonError(w, http.StatusNotFound, "item not found")
+		return
+	}
+	if !changed {
+		// The item already has exactly this image; nothing was written.
+		jsonResponse(w, http.StatusOK, map[string]string{"message": "image unchanged"})
 		return
 	}
 
 	claims := GetClaims(r.Context())
-	item, _ := store.GetItem(r.Context(), h.DB, id)
+	slog.Info("item image uploaded", "user", claims.Username, "item", item.Name)
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "image uploaded"})
+}
+
+// TransformImage handles POST /api/items/{id}/image/transform. It rotates
+// and/or flips the stored image in place.
+func (h *ItemsHandler) TransformImage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req struct {
+		Rotate int    `json:"rotate"`
+		Flip   string `json:"flip"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	if req.Rotate == 0 && req.Flip == "" {
+		jsonError(w, http.StatusBadRequest, "rotate or flip required")
+		return
+	}
+
+	data, _, err := h.Store.GetItemImage(r.Context(), id, false)
+	if err != nil {
+		slog.Error("failed to get image", "error", err)
+		storeError(w, err, "failed to get image")
+		return
+	}
+	if data == nil {
+		jsonError(w, http.StatusNotFound, "no image")
+		return
+	}
+
+	result, err := imaging.Transform(data, req.Rotate, req.Flip)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.Store.SetItemImage(r.Context(), id, result.Data, result.MIME); err != nil {
+		slog.Error("failed to save image", "error", err)
+		storeError(w, err, "failed to save image")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	item, _ := h.Store.GetItem(r.Context(), id)
 	itemName := fmt.Sprintf("id:%d", id)
 	if item != nil {
 		itemName = item.Name
 	}
-	slog.Info("item image uploaded", "user", claims.Username, "item", itemName)
-	jsonResponse(w, http.StatusOK, map[string]string{"message": "image uploaded"})
+	slog.Info("item image transformed", "user", claims.Username, "item", itemName, "rotate", req.Rotate, "flip", req.Flip)
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "image transformed"})
 }
 
 // GetImage handles GET /api/items/{id}/image.
@@ -222,10 +873,16 @@ func (h *ItemsHandler) GetImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, mime, err := store.GetItemImage(r.Context(), h.DB, id)
+	// Admins may fetch images of deleted items for history views.
+	include, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	data, mime, err := h.Store.GetItemImage(r.Context(), id, include)
 	if err != nil {
 		slog.Error("failed to get image", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to get image")
+		storeError(w, err, "failed to get image")
 		return
 	}
 	if data == nil {
@@ -234,6 +891,7 @@ func (h *ItemsHandler) GetImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", mime)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 	w.Header().Set("Content-Disposition", "inline")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("Cache-Control", "public, max-age=3600")
@@ -250,10 +908,14 @@ func (h *ItemsHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	history, err := store.GetItemHistory(r.Context(), h.DB, id)
+	if h.visibleItem(w, r, id) == nil {
+		return
+	}
+
+	history, err := h.Store.GetItemHistory(r.Context(), id)
 	if err != nil {
 		slog.Error("failed to get item history", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to get item history")
+		storeError(w, err, "failed to get item history")
 		return
 	}
 	if history == nil {
@@ -261,3 +923,219 @@ func (h *ItemsHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 	}
 	jsonResponse(w, http.StatusOK, history)
 }
+
+// ExportHistory handles GET /api/items/{id}/history/export?format=csv. It
+// lists the item's transfers and ledger adjustments oldest first, for printing
+// with warranty claims. Only CSV is supported.
+func (h *ItemsHandler) ExportHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "csv" {
+		jsonError(w, http.StatusBadRequest, "unsupported format: only csv is available")
+		return
+	}
+
+	item := h.visibleItem(w, r, id)
+	if item == nil {
+		return
+	}
+
+	entries, err := h.Store.GetItemChangelog(r.Context(), id, -1, 0)
+	if err != nil {
+		slog.Error("failed to get item history", "error", err)
+		storeError(w, err, "failed to get item history")
+		return
+	}
+	slices.Reverse(entries)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="item-%d-history.csv"`, id))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "from", "to", "quantity", "notes", "user"})
+	for _, e := range entries {
+		date := e.At.UTC().Format(time.RFC3339)
+		switch {
+		case e.Transfer != nil:
+			t := e.Transfer
+			cw.Write([]string{date, csvCell(t.FromOwnerName), csvCell(t.ToOwnerName),
+				model.FormatQuantity(t.Quantity, item.Divisible), csvCell(t.Notes), csvCell(e.Username)})
+		case e.Adjustment != nil:
+			// Ledger deltas are negative; the sheet shows what left the
+			// owner, with the adjustment kind in place of a recipient.
+			a := e.Adjustment
+			cw.Write([]string{date, csvCell(a.OwnerName), a.Kind,
+				model.FormatQuantity(-a.Delta, item.Divisible), csvCell(a.Reason), csvCell(e.Username)})
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.Error("failed to write item history", "error", err)
+	}
+}
+
+// GetStatusHistory handles GET /api/items/{id}/status-history.
+func (h *ItemsHandler) GetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	if h.visibleItem(w, r, id) == nil {
+		return
+	}
+
+	history, err := h.Store.GetItemStatusHistory(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item status history", "error", err)
+		storeError(w, err, "failed to get item status history")
+		return
+	}
+	if history == nil {
+		history = []model.StatusChange{}
+	}
+	jsonResponse(w, http.StatusOK, history)
+}
+
+// changelogDefaultLimit is the changelog page size when ?limit is not given.
+const changelogDefaultLimit = 50
+
+type changelogResponse struct {
+	Entries []model.ItemChangelogEntry `json:"entries"`
+	Limit   int                        `json:"limit"`
+	Offset  int                        `json:"offset"`
+	HasMore bool                       `json:"has_more"`
+}
+
+// GetChangelog handles GET /api/items/{id}/changelog.
+func (h *ItemsHandler) GetChangelog(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	limit, offset, err := parsePagination(w, r, changelogDefaultLimit, h.MaxPageSize)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if h.visibleItem(w, r, id) == nil {
+		return
+	}
+
+	// Fetch one extra entry to tell whether another page exists.
+	entries, err := h.Store.GetItemChangelog(r.Context(), id, limit+1, offset)
+	if err != nil {
+		slog.Error("failed to get item changelog", "error", err)
+		storeError(w, err, "failed to get item changelog")
+		return
+	}
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+	if entries == nil {
+		entries = []model.ItemChangelogEntry{}
+	}
+	jsonResponse(w, http.StatusOK, changelogResponse{Entries: entries, Limit: limit, Offset: offset, HasMore: hasMore})
+}
+
+// AddFavorite handles POST /api/items/{id}/favorite.
+func (h *ItemsHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	item, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to get item")
+		return
+	}
+	if item == nil || item.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "item not found")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	if err := h.Store.AddFavorite(r.Context(), claims.UserID, id); err != nil {
+		slog.Error("failed to add favorite", "error", err)
+		storeError(w, err, "failed to add favorite")
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "item pinned"})
+}
+
+// RemoveFavorite handles DELETE /api/items/{id}/favorite.
+func (h *ItemsHandler) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	if err := h.Store.RemoveFavorite(r.Context(), claims.UserID, id); err != nil {
+		slog.Error("failed to remove favorite", "error", err)
+		storeError(w, err, "failed to remove favorite")
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "item unpinned"})
+}
+
+// ListFavorites handles GET /api/favorites.
+func (h *ItemsHandler) ListFavorites(w http.ResponseWriter, r *http.Request) {
+	claims := GetClaims(r.Context())
+	items, err := h.Store.ListFavorites(r.Context(), claims.UserID)
+	if err != nil {
+		slog.Error("failed to list favorites", "error", err)
+		storeError(w, err, "failed to list favorites")
+		return
+	}
+	if items == nil {
+		items = []model.Item{}
+	}
+	jsonResponse(w, http.StatusOK, items)
+}
+
+// GetAvailable handles GET /api/items/{id}/available?owner_id=.
+// It reports how many of the item the owner holds, i.e. the most that can be
+// transferred from them.
+func (h *ItemsHandler) GetAvailable(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+	ownerID, err := strconv.ParseInt(r.URL.Query().Get("owner_id"), 10, 64)
+	if err != nil || ownerID <= 0 {
+		jsonError(w, http.StatusBadRequest, "owner_id required")
+		return
+	}
+
+	quantity, err := h.Store.GetHeldQuantity(r.Context(), id, ownerID)
+	if err != nil {
+		slog.Error("failed to get available quantity", "error", err)
+		storeError(w, err, "failed to get available quantity")
+		return
+	}
+	divisible, err := h.Store.ItemDivisible(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get available quantity", "error", err)
+		storeError(w, err, "failed to get available quantity")
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{
+		"item_id":  id,
+		"owner_id": ownerID,
+		"quantity": model.QuantityNumber(quantity, divisible),
+	})
+}
@@ -2,10 +2,16 @@ package api
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/erazemk/skladisce/internal/auth"
@@ -17,10 +23,19 @@ type contextKey string
 
 const claimsKey contextKey = "claims"
 const tokenKey contextKey = "rawtoken"
+const clientIPKey contextKey = "clientip"
+
+// passwordChangeAllowed lists the routes a token with MustChangePassword may
+// use: everything else is rejected until the password is changed.
+var passwordChangeAllowed = map[string]bool{
+	"PUT /api/auth/password": true,
+	"POST /api/auth/logout":  true,
+}
 
 // AuthMiddleware validates JWT from Authorization header, checks token
-// revocation, and adds claims + raw token to context.
-func AuthMiddleware(secret string, db *sql.DB) func(http.Handler) http.Handler {
+// revocation, and adds claims + raw token to context. Tokens carrying
+// MustChangePassword are limited to passwordChangeAllowed.
+func AuthMiddleware(secret string, st store.Store) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			header := r.Header.Get("Authorization")
@@ -36,18 +51,32 @@ func AuthMiddleware(secret string, db *sql.DB) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Check if the token has been revoked.
-			if claims.ID != "" {
-				revoked, err := store.IsTokenRevoked(r.Context(), db, claims.ID)
-				if err != nil {
-					slog.Error("failed to check token revocation", "error", err)
-					jsonError(w, http.StatusInternalServerError, "internal error")
-					return
-				}
-				if revoked {
-					jsonError(w, http.StatusUnauthorized, "token has been revoked")
-					return
-				}
+			revoked, err := tokenRevoked(r.Context(), st, claims)
+			if err != nil {
+				slog.Error("failed to check token revocation", "error", err)
+				storeError(w, err, "internal error")
+				return
+			}
+			if revoked {
+				jsonError(w, http.StatusUnauthorized, "token has been revoked")
+				return
+			}
+
+			if claims.MustChangePassword && !passwordChangeAllowed[r.Method+" "+r.URL.Path] {
+				jsonResponse(w, http.StatusForbidden, map[string]string{
+					"error": "password change required",
+					"code":  "password_change_required",
+				})
+				return
+			}
+
+			if claims.ImpersonatedBy != "" {
+				slog.Info("impersonated request",
+					"user", claims.Username,
+					"impersonated_by", claims.ImpersonatedBy,
+					"method", r.Method,
+					"path", r.URL.RequestURI(),
+				)
 			}
 
 			ctx := context.WithValue(r.Context(), claimsKey, claims)
@@ -57,6 +86,30 @@ func AuthMiddleware(secret string, db *sql.DB) func(http.Handler) http.Handler {
 	}
 }
 
+// tokenRevoked reports whether a validated token has been revoked, either
+// explicitly on logout or by a later password change of its user.
+func tokenRevoked(ctx context.Context, st store.Store, claims *auth.Claims) (bool, error) {
+	if claims.ID != "" {
+		revoked, err := st.IsTokenRevoked(ctx, claims.ID)
+		if err != nil {
+			return false, err
+		}
+		if revoked {
+			return true, nil
+		}
+	}
+	if claims.IssuedAt != nil {
+		stale, err := st.IssuedBeforePasswordChange(ctx, claims.UserID, claims.IssuedAt.Time)
+		if err != nil {
+			return false, err
+		}
+		if stale {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // RequireRole returns middleware that checks if the user has at least the given role.
 func RequireRole(minimum string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -75,6 +128,25 @@ func RequireRole(minimum string) func(http.Handler) http.Handler {
 	}
 }
 
+// RequireAction returns middleware that checks if the user's role may perform
+// action (see model.Can). Unknown actions are always refused.
+func RequireAction(action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := GetClaims(r.Context())
+			if claims == nil {
+				jsonError(w, http.StatusUnauthorized, "not authenticated")
+				return
+			}
+			if !model.Can(claims.Role, action) {
+				jsonError(w, http.StatusForbidden, "insufficient permissions")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetClaims retrieves the JWT claims from the context.
 func GetClaims(ctx context.Context) *auth.Claims {
 	claims, _ := ctx.Value(claimsKey).(*auth.Claims)
@@ -87,6 +159,293 @@ func GetRawToken(ctx context.Context) string {
 	return token
 }
 
+// MaxConcurrentRequests returns middleware that allows at most limit requests
+// to be served at once. Requests beyond the limit are rejected with 503 rather
+// than queued. A limit of 0 disables the check.
+func MaxConcurrentRequests(limit int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		sem := make(chan struct{}, limit)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				jsonError(w, http.StatusServiceUnavailable, "server busy, try again later")
+			}
+		})
+	}
+}
+
+// DefaultReportCooldown is how long a user must wait after a report finishes
+// before starting the next one, unless Options.ReportCooldown says otherwise.
+const DefaultReportCooldown = 2 * time.Second
+
+// ReportLimiter allows each user one report request at a time, followed by a
+// cooldown, so CSV exports and similar heavy endpoints cannot be spammed.
+// Requests over the limit get 429 with Retry-After. It is safe for concurrent use.
+type ReportLimiter struct {
+	cooldown time.Duration
+
+	mu    sync.Mutex
+	users map[int64]reportState
+}
+
+type reportState struct {
+	running bool
+	until   time.Time // end of the cooldown once the report is done
+}
+
+// NewReportLimiter returns a ReportLimiter with the given cooldown. A cooldown
+// of 0 only limits users to one report at a time.
+func NewReportLimiter(cooldown time.Duration) *ReportLimiter {
+	return &ReportLimiter{cooldown: cooldown, users: make(map[int64]reportState)}
+}
+
+// acquire marks a report as running for userID. If one is already running or
+// the cooldown has not passed, it returns false and how long to wait.
+func (l *ReportLimiter) acquire(userID int64, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state := l.users[userID]
+	if state.running {
+		return false, l.cooldown
+	}
+	if now.Before(state.until) {
+		return false, state.until.Sub(now)
+	}
+	// Forget users whose cooldown is over while we hold the lock anyway.
+	for id, s := range l.users {
+		if !s.running && !now.Before(s.until) {
+			delete(l.users, id)
+		}
+	}
+	l.users[userID] = reportState{running: true}
+	return true, 0
+}
+
+// release ends the running report of userID, starting the cooldown if the
+// report was generated.
+func (l *ReportLimiter) release(userID int64, generated bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !generated {
+		delete(l.users, userID)
+		return
+	}
+	l.users[userID] = reportState{until: time.Now().Add(l.cooldown)}
+}
+
+// Middleware applies the limit to an authenticated route.
+func (l *ReportLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r.Context())
+		if claims == nil {
+			jsonError(w, http.StatusUnauthorized, "not authenticated")
+			return
+		}
+		ok, wait := l.acquire(claims.UserID, time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(max(wait.Round(time.Second), time.Second)/time.Second)))
+			jsonError(w, http.StatusTooManyRequests, "a report is already running or was just generated, try again shortly")
+			return
+		}
+		// Rejected requests (bad parameters, unknown owner) did no real work
+		// and do not start the cooldown.
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() { l.release(claims.UserID, rec.status < 400) }()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// MaxBodySize returns middleware that limits every request body to maxBytes.
+// Requests declaring a larger Content-Length are rejected with 413 up front;
+// streamed bodies are cut off by http.MaxBytesReader. A limit of 0 disables
+// the check.
+func MaxBodySize(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				jsonError(w, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReadOnlyMode is a server-wide toggle that rejects mutating requests while
+// enabled (e.g. during backups or migrations). It is safe for concurrent use.
+type ReadOnlyMode struct {
+	enabled atomic.Bool
+}
+
+// NewReadOnlyMode returns a ReadOnlyMode with the given initial state.
+func NewReadOnlyMode(enabled bool) *ReadOnlyMode {
+	m := &ReadOnlyMode{}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether read-only mode is on.
+func (m *ReadOnlyMode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set turns read-only mode on or off.
+func (m *ReadOnlyMode) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// readOnlyExempt lists POST routes that stay available in read-only mode: the
+// toggle itself, so an admin can turn it off, session login/logout, and the
+// password check, token introspection, transfer dry run and item batch-get,
+// which never write.
+var readOnlyExempt = map[string]bool{
+	"/api/admin/readonly":       true,
+	"/api/auth/introspect":      true,
+	"/api/auth/login":           true,
+	"/api/auth/logout":          true,
+	"/api/auth/verify-password": true,
+	"/api/items/batch-get":      true,
+	"/api/transfers/validate":   true,
+	"/login":                    true,
+	"/logout":                   true,
+}
+
+// Middleware rejects non-GET/HEAD/OPTIONS requests with 503 while read-only
+// mode is enabled. API paths get a JSON error, web paths plain text.
+func (m *ReadOnlyMode) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Enabled() || readOnlyExempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		const message = "service in read-only mode"
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			jsonError(w, http.StatusServiceUnavailable, message)
+		} else {
+			http.Error(w, message, http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// SecurityHeaders returns middleware that sets browser security headers on
+// every response. An empty csp omits Content-Security-Policy. HSTS is sent
+// when hsts is set (e.g. behind a TLS-terminating proxy) or the request
+// arrived over TLS.
+func SecurityHeaders(csp string, hsts bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "same-origin")
+			if csp != "" {
+				h.Set("Content-Security-Policy", csp)
+			}
+			if hsts || r.TLS != nil {
+				h.Set("Strict-Transport-Security", "max-age=31536000")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs or bare IP
+// addresses. An empty string yields no trusted proxies.
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(part); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", part)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// TrustedProxies returns middleware that stores the client IP in the request
+// context for ClientIP. If the direct peer is one of the trusted proxies, the
+// client is the right-most X-Forwarded-For address that is not itself a
+// trusted proxy, or X-Real-IP if there is no X-Forwarded-For. Otherwise both
+// headers are ignored, so clients cannot spoof their address.
+func TrustedProxies(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := remoteIP(r.RemoteAddr)
+			if peer, err := netip.ParseAddr(ip); err == nil && isTrusted(peer) {
+				if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+					hops := strings.Split(strings.Join(xff, ","), ",")
+					for i := len(hops) - 1; i >= 0; i-- {
+						hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+						if err != nil {
+							break
+						}
+						ip = hop.Unmap().String()
+						if !isTrusted(hop) {
+							break
+						}
+					}
+				} else if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+					ip = realIP.Unmap().String()
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey, ip)))
+		})
+	}
+}
+
+// ClientIP returns the client IP determined by TrustedProxies, or the host
+// part of r.RemoteAddr if that middleware did not run.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	return remoteIP(r.RemoteAddr)
+}
+
+// remoteIP strips the port from a RemoteAddr.
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
 // statusRecorder wraps http.ResponseWriter to capture the status code.
 type statusRecorder struct {
 	http.ResponseWriter
@@ -116,12 +475,15 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 			"path", r.URL.RequestURI(),
 			"status", rec.status,
 			"duration", duration.Round(time.Millisecond).String(),
-			"remote", r.RemoteAddr,
+			"remote", ClientIP(r),
 		}
 
 		// Add user info if authenticated.
 		if claims := GetClaims(r.Context()); claims != nil {
 			attrs = append(attrs, "user", claims.Username)
+			if claims.ImpersonatedBy != "" {
+				attrs = append(attrs, "impersonated_by", claims.ImpersonatedBy)
+			}
 		}
 
 		if rec.status >= 500 {
@@ -131,3 +493,50 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		}
 	})
 }
+
+// RecoverPanics returns middleware that turns a panicking handler into a 500
+// instead of a dropped connection, logging the panic and its stack. If the
+// handler had already started its response, that response is left as is.
+// http.ErrAbortHandler is re-raised so net/http can abort the response.
+func RecoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &writeRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			slog.Error("panic serving request",
+				"method", r.Method,
+				"path", r.URL.RequestURI(),
+				"remote", ClientIP(r),
+				"panic", v,
+				"stack", string(debug.Stack()),
+			)
+			if !rec.wrote {
+				jsonError(w, http.StatusInternalServerError, "internal server error")
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// writeRecorder wraps http.ResponseWriter to note whether the response has
+// been started.
+type writeRecorder struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (r *writeRecorder) WriteHeader(code int) {
+	r.wrote = true
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *writeRecorder) Write(b []byte) (int, error) {
+	r.wrote = true
+	return r.ResponseWriter.Write(b)
+}
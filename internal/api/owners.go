@@ -1,11 +1,15 @@
 package api
 
 import (
-	"database/sql"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/erazemk/skladisce/internal/model"
 	"github.com/erazemk/skladisce/internal/store"
@@ -13,7 +17,7 @@ import (
 
 // OwnersHandler handles owner CRUD endpoints.
 type OwnersHandler struct {
-	DB *sql.DB
+	Store store.Store
 }
 
 type createOwnerRequest struct {
@@ -25,15 +29,81 @@ type updateOwnerRequest struct {
 	Name string `json:"name"`
 }
 
-// List handles GET /api/owners.
+type linkOwnerUserRequest struct {
+	UserID *int64 `json:"user_id"`
+}
+
+type moveAllRequest struct {
+	To    int64  `json:"to"`
+	Notes string `json:"notes"`
+}
+
+// ownerSearchLimit caps the number of results returned by an owner name search.
+const ownerSearchLimit = 50
+
+// List handles GET /api/owners. ?exclude=<id> (repeatable) and
+// ?exclude_self=true drop owners from the result, so pickers can leave out
+// the other side of a transfer or the caller's own person owner.
 func (h *OwnersHandler) List(w http.ResponseWriter, r *http.Request) {
 	ownerType := r.URL.Query().Get("type")
-	owners, err := store.ListOwners(r.Context(), h.DB, ownerType)
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	holding := r.URL.Query().Get("holding") == "true"
+
+	var itemID int64
+	if s := r.URL.Query().Get("item_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			jsonError(w, http.StatusBadRequest, "invalid item_id")
+			return
+		}
+		if !holding {
+			jsonError(w, http.StatusBadRequest, "item_id requires holding=true")
+			return
+		}
+		itemID = id
+	}
+	if holding && query != "" {
+		jsonError(w, http.StatusBadRequest, "q cannot be combined with holding")
+		return
+	}
+
+	exclude := make(map[int64]bool)
+	for _, s := range r.URL.Query()["exclude"] {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			jsonError(w, http.StatusBadRequest, "invalid exclude")
+			return
+		}
+		exclude[id] = true
+	}
+	if r.URL.Query().Get("exclude_self") == "true" {
+		self, err := h.Store.GetUserOwner(r.Context(), GetClaims(r.Context()).UserID)
+		if err != nil {
+			slog.Error("failed to get user's owner", "error", err)
+			storeError(w, err, "failed to list owners")
+			return
+		}
+		// An account without a linked owner has nothing to exclude.
+		if self != nil {
+			exclude[self.ID] = true
+		}
+	}
+
+	var owners []model.Owner
+	var err error
+	if holding {
+		owners, err = h.Store.ListHoldingOwners(r.Context(), itemID, ownerType)
+	} else if query != "" {
+		owners, err = h.Store.SearchOwners(r.Context(), query, ownerType, ownerSearchLimit)
+	} else {
+		owners, err = h.Store.ListOwners(r.Context(), ownerType)
+	}
 	if err != nil {
 		slog.Error("failed to list owners", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to list owners")
+		storeError(w, err, "failed to list owners")
 		return
 	}
+	owners = slices.DeleteFunc(owners, func(o model.Owner) bool { return exclude[o.ID] })
 	if owners == nil {
 		owners = []model.Owner{}
 	}
@@ -44,7 +114,7 @@ func (h *OwnersHandler) List(w http.ResponseWriter, r *http.Request) {
 func (h *OwnersHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req createOwnerRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
@@ -52,16 +122,25 @@ func (h *OwnersHandler) Create(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, http.StatusBadRequest, "name and type required")
 		return
 	}
+	var err error
+	if req.Name, err = model.NormalizeName(req.Name); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	if req.Type != model.OwnerTypePerson && req.Type != model.OwnerTypeLocation {
 		jsonError(w, http.StatusBadRequest, "type must be 'person' or 'location'")
 		return
 	}
 
-	owner, err := store.CreateOwner(r.Context(), h.DB, req.Name, req.Type)
+	owner, err := h.Store.CreateOwner(r.Context(), req.Name, req.Type)
 	if err != nil {
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			jsonError(w, http.StatusForbidden, err.Error())
+			return
+		}
 		slog.Error("failed to create owner", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to create owner")
+		storeError(w, err, "failed to create owner")
 		return
 	}
 
@@ -78,10 +157,10 @@ func (h *OwnersHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	owner, err := store.GetOwner(r.Context(), h.DB, id)
+	owner, err := h.Store.GetOwner(r.Context(), id)
 	if err != nil {
 		slog.Error("failed to get owner", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to get owner")
+		storeError(w, err, "failed to get owner")
 		return
 	}
 	if owner == nil || owner.DeletedAt != nil {
@@ -102,24 +181,35 @@ func (h *OwnersHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	var req updateOwnerRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
-	if req.Name == "" {
-		jsonError(w, http.StatusBadRequest, "name required")
+	if req.Name, err = model.NormalizeName(req.Name); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err := store.UpdateOwner(r.Context(), h.DB, id, req.Name); err != nil {
+	existing, err := h.Store.GetOwner(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner", "error", err)
+		storeError(w, err, "failed to update owner")
+		return
+	}
+	if existing == nil || existing.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "owner not found")
+		return
+	}
+
+	if err := h.Store.UpdateOwner(r.Context(), id, req.Name); err != nil {
 		slog.Error("failed to update owner", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to update owner")
+		storeError(w, err, "failed to update owner")
 		return
 	}
 
 	claims := GetClaims(r.Context())
 	slog.Info("owner updated", "user", claims.Username, "owner", req.Name)
-	owner, _ := store.GetOwner(r.Context(), h.DB, id)
+	owner, _ := h.Store.GetOwner(r.Context(), id)
 	jsonResponse(w, http.StatusOK, owner)
 }
 
@@ -131,24 +221,180 @@ func (h *OwnersHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	owner, _ := store.GetOwner(r.Context(), h.DB, id)
-	ownerName := fmt.Sprintf("id:%d", id)
-	if owner != nil {
-		ownerName = owner.Name
+	// The body is optional; htmx deletes send none (but a form Content-Type).
+	var req deleteRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil && !errors.Is(err, errEmptyBody) {
+			decodeError(w, err)
+			return
+		}
 	}
 
-	if err := store.DeleteOwner(r.Context(), h.DB, id); err != nil {
-		// Check if it's a business rule error (holding inventory) vs internal error.
-		slog.Warn("failed to delete owner", "owner", ownerName, "error", err)
-		jsonError(w, http.StatusBadRequest, "cannot delete owner: still holds inventory or not found")
+	owner, err := h.Store.GetOwner(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner", "error", err)
+		storeError(w, err, "failed to delete owner")
+		return
+	}
+	if owner == nil || owner.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "owner not found")
 		return
 	}
 
 	claims := GetClaims(r.Context())
-	slog.Info("owner deleted", "user", claims.Username, "owner", ownerName)
+	if err := h.Store.DeleteOwner(r.Context(), id, &claims.UserID, req.Reason); err != nil {
+		slog.Warn("failed to delete owner", "owner", owner.Name, "error", err)
+		jsonError(w, http.StatusBadRequest, "cannot delete owner: still holds inventory")
+		return
+	}
+
+	slog.Info("owner deleted", "user", claims.Username, "owner", owner.Name, "reason", req.Reason)
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "owner deleted"})
 }
 
+// Restore handles POST /api/owners/{id}/restore.
+func (h *OwnersHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid owner id")
+		return
+	}
+
+	if err := h.Store.RestoreOwner(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			jsonError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		slog.Warn("failed to restore owner", "id", id, "error", err)
+		jsonError(w, http.StatusNotFound, "deleted owner not found")
+		return
+	}
+
+	owner, err := h.Store.GetOwner(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner", "error", err)
+		storeError(w, err, "failed to get owner")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("owner restored", "user", claims.Username, "owner", owner.Name)
+	jsonResponse(w, http.StatusOK, owner)
+}
+
+// LinkUser handles PUT /api/owners/{id}/user. It links a person owner to the
+// user account it stands for, or unlinks it when user_id is null.
+func (h *OwnersHandler) LinkUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid owner id")
+		return
+	}
+
+	var req linkOwnerUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	h.linkUser(w, r, id, req.UserID)
+}
+
+// UnlinkUser handles DELETE /api/owners/{id}/user. It is the same as linking
+// the owner to a null user_id.
+func (h *OwnersHandler) UnlinkUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid owner id")
+		return
+	}
+	h.linkUser(w, r, id, nil)
+}
+
+// linkUser links owner id to userID (nil to unlink) and writes the updated
+// owner.
+func (h *OwnersHandler) linkUser(w http.ResponseWriter, r *http.Request, id int64, userID *int64) {
+	existing, err := h.Store.GetOwner(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner", "error", err)
+		storeError(w, err, "failed to link owner")
+		return
+	}
+	if existing == nil || existing.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "owner not found")
+		return
+	}
+
+	owner, err := h.Store.LinkOwnerUser(r.Context(), id, userID)
+	if err != nil {
+		if invalid(err) {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to link owner", "error", err)
+		storeError(w, err, "failed to link owner")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	if owner.UserID != nil {
+		slog.Info("owner linked", "user", claims.Username, "owner", owner.Name, "linked_user", *owner.UserID)
+	} else {
+		slog.Info("owner unlinked", "user", claims.Username, "owner", owner.Name)
+	}
+	jsonResponse(w, http.StatusOK, owner)
+}
+
+// MoveAll handles POST /api/owners/{id}/move-all. It moves everything the
+// owner holds to the owner given as "to" in one transaction, one transfer per
+// item, and returns the transfers. Unlike deleting an owner, both owners
+// stay.
+func (h *OwnersHandler) MoveAll(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid owner id")
+		return
+	}
+	var req moveAllRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	if req.To <= 0 {
+		jsonError(w, http.StatusBadRequest, "to required")
+		return
+	}
+	if err := model.ValidateNotes(req.Notes); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	owner, err := h.Store.GetOwner(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner", "error", err)
+		storeError(w, err, "failed to move inventory")
+		return
+	}
+	if owner == nil || owner.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "owner not found")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	transfers, err := h.Store.MoveAllInventory(r.Context(), id, req.To, req.Notes, &claims.UserID)
+	if err != nil {
+		if invalid(err) {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to move inventory", "error", err)
+		storeError(w, err, "failed to move inventory")
+		return
+	}
+
+	slog.Info("owner inventory moved", "user", claims.Username, "from", owner.Name, "to", req.To, "items", len(transfers))
+	jsonResponse(w, http.StatusOK, transfers)
+}
+
 // GetInventory handles GET /api/owners/{id}/inventory.
 func (h *OwnersHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
@@ -157,10 +403,10 @@ func (h *OwnersHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	inventory, err := store.GetOwnerInventory(r.Context(), h.DB, id)
+	inventory, err := h.Store.GetOwnerInventory(r.Context(), id)
 	if err != nil {
 		slog.Error("failed to get owner inventory", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to get owner inventory")
+		storeError(w, err, "failed to get owner inventory")
 		return
 	}
 	if inventory == nil {
@@ -168,3 +414,163 @@ func (h *OwnersHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
 	}
 	jsonResponse(w, http.StatusOK, inventory)
 }
+
+// GetSummary handles GET /api/owners/{id}/summary.
+func (h *OwnersHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid owner id")
+		return
+	}
+
+	owner, err := h.Store.GetOwner(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner", "error", err)
+		storeError(w, err, "failed to get owner")
+		return
+	}
+	if owner == nil || owner.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "owner not found")
+		return
+	}
+
+	summary, err := h.Store.GetOwnerSummary(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner summary", "error", err)
+		storeError(w, err, "failed to get owner summary")
+		return
+	}
+	jsonResponse(w, http.StatusOK, summary)
+}
+
+// Owner card sizes for the recent transfers section.
+const (
+	ownerCardDefaultTransfers = 10
+	ownerCardMaxTransfers     = 100
+)
+
+type ownerCardResponse struct {
+	Owner           *model.Owner      `json:"owner"`
+	Inventory       []model.Inventory `json:"inventory"`
+	RecentTransfers []model.Transfer  `json:"recent_transfers"`
+	TransferCount   int               `json:"transfer_count"`
+}
+
+// Card handles GET /api/owners/{id}/card. It returns everything an owner
+// detail screen needs in one response: the owner, what it holds and its most
+// recent transfers (?limit=, default 10).
+func (h *OwnersHandler) Card(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid owner id")
+		return
+	}
+	limit, _, err := parsePagination(w, r, ownerCardDefaultTransfers, ownerCardMaxTransfers)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	owner, err := h.Store.GetOwner(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner", "error", err)
+		storeError(w, err, "failed to get owner")
+		return
+	}
+	if owner == nil || owner.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "owner not found")
+		return
+	}
+
+	inventory, err := h.Store.GetOwnerInventory(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner inventory", "error", err)
+		storeError(w, err, "failed to get owner inventory")
+		return
+	}
+	transfers, total, err := h.Store.ListTransfersPage(r.Context(), 0, id, "", limit, 0)
+	if err != nil {
+		slog.Error("failed to list owner transfers", "error", err)
+		storeError(w, err, "failed to list transfers")
+		return
+	}
+	if inventory == nil {
+		inventory = []model.Inventory{}
+	}
+	if transfers == nil {
+		transfers = []model.Transfer{}
+	}
+
+	jsonResponse(w, http.StatusOK, ownerCardResponse{
+		Owner:           owner,
+		Inventory:       inventory,
+		RecentTransfers: transfers,
+		TransferCount:   total,
+	})
+}
+
+// Handover handles GET /api/owners/{id}/handover?format=csv.
+// It lists what the owner currently holds and the incoming transfers for
+// those items, for printing when a person leaves. Only CSV is supported.
+func (h *OwnersHandler) Handover(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid owner id")
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "csv" {
+		jsonError(w, http.StatusBadRequest, "unsupported format: only csv is available")
+		return
+	}
+
+	owner, err := h.Store.GetOwner(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner", "error", err)
+		storeError(w, err, "failed to get owner")
+		return
+	}
+	if owner == nil || owner.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "owner not found")
+		return
+	}
+
+	inventory, err := h.Store.GetOwnerInventory(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get owner inventory", "error", err)
+		storeError(w, err, "failed to get owner inventory")
+		return
+	}
+	transfers, err := h.Store.ListTransfers(r.Context(), 0, id, "")
+	if err != nil {
+		slog.Error("failed to list transfers", "error", err)
+		storeError(w, err, "failed to list transfers")
+		return
+	}
+
+	held := make(map[int64]bool, len(inventory))
+	for _, inv := range inventory {
+		held[inv.ItemID] = true
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="handover-%d.csv"`, id))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"owner", csvCell(owner.Name), owner.Type})
+	cw.Write([]string{"generated_at", time.Now().UTC().Format(time.RFC3339)})
+	cw.Write([]string{"section", "item_id", "item_name", "quantity", "transferred_at", "from_owner", "notes"})
+	for _, inv := range inventory {
+		cw.Write([]string{"holding", strconv.FormatInt(inv.ItemID, 10), csvCell(inv.ItemName), model.FormatQuantity(inv.Quantity, inv.Divisible), "", "", ""})
+	}
+	for _, t := range transfers {
+		if t.ToOwnerID != id || !held[t.ItemID] {
+			continue
+		}
+		cw.Write([]string{"received", strconv.FormatInt(t.ItemID, 10), csvCell(t.ItemName), model.FormatQuantity(t.Quantity, t.Divisible),
+			t.TransferredAt.UTC().Format(time.RFC3339), csvCell(t.FromOwnerName), csvCell(t.Notes)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.Error("failed to write handover sheet", "error", err)
+	}
+}
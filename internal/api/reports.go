@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// ReportsHandler handles analytics reports.
+type ReportsHandler struct {
+	Store store.Store
+}
+
+// maxTrendDays caps ?days on the inventory trend.
+const maxTrendDays = 366
+
+// InventoryTrend handles GET /api/reports/inventory-trend?days=30&by_status=true.
+// It returns one point per day, oldest first, with the units held at the
+// end of that day; today's point is the live total. Per-status figures are
+// included with by_status=true.
+func (h *ReportsHandler) InventoryTrend(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTrendDays {
+			jsonError(w, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", maxTrendDays))
+			return
+		}
+		days = n
+	}
+
+	points, err := h.Store.GetInventoryTrend(r.Context(), days, time.Now())
+	if err != nil {
+		slog.Error("failed to get inventory trend", "error", err)
+		storeError(w, err, "failed to get inventory trend")
+		return
+	}
+	if r.URL.Query().Get("by_status") != "true" {
+		for i := range points {
+			points[i].ByStatus = nil
+		}
+	}
+	jsonResponse(w, http.StatusOK, points)
+}
+
+// Reorder handles GET /api/reports/reorder. It lists the items below their
+// low-stock threshold with how much to order to get back to their reorder
+// target; items without a threshold or a target are left out.
+func (h *ReportsHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	lines, err := h.Store.ListReorder(r.Context())
+	if err != nil {
+		slog.Error("failed to list reorder lines", "error", err)
+		storeError(w, err, "failed to get reorder report")
+		return
+	}
+	if lines == nil {
+		lines = []model.ReorderLine{}
+	}
+	jsonResponse(w, http.StatusOK, lines)
+}
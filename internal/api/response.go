@@ -2,13 +2,30 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
 )
 
 // maxJSONBodySize is the maximum allowed size for JSON request bodies (1 MB).
 const maxJSONBodySize = 1 << 20
 
+// Errors returned by decodeJSON for requests that are not JSON at all.
+var (
+	errEmptyBody          = errors.New("empty request body")
+	errUnsupportedContent = errors.New("content type must be application/json")
+)
+
 // jsonResponse writes a JSON response with the given status code.
 func jsonResponse(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
@@ -25,12 +42,164 @@ func jsonError(w http.ResponseWriter, status int, message string) {
 	jsonResponse(w, status, map[string]string{"error": message})
 }
 
+// storeError writes the response for an unexpected store error. A busy
+// database (store.ErrBusy) is 503 with Retry-After, telling the client to
+// retry; anything else is 500 with message.
+func storeError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, store.ErrBusy) {
+		w.Header().Set("Retry-After", "1")
+		jsonError(w, http.StatusServiceUnavailable, "database busy, try again")
+		return
+	}
+	jsonError(w, http.StatusInternalServerError, message)
+}
+
 // decodeJSON decodes a JSON request body into the given target.
-// Limits the body to maxJSONBodySize and rejects unknown fields.
+// Limits the body to maxJSONBodySize and rejects unknown fields. Returns
+// errUnsupportedContent if a non-JSON Content-Type is set and errEmptyBody
+// if the body is empty.
 func decodeJSON(r *http.Request, target any) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || mediaType != "application/json" {
+			return errUnsupportedContent
+		}
+	}
+
 	r.Body = http.MaxBytesReader(nil, r.Body, maxJSONBodySize)
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	defer r.Body.Close()
-	return dec.Decode(target)
+	if err := dec.Decode(target); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errEmptyBody
+		}
+		return err
+	}
+	return nil
+}
+
+// decodeError writes the error response for a failed decodeJSON call.
+func decodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		jsonError(w, http.StatusRequestEntityTooLarge, "request body too large")
+	case fieldTypeError(err) != "":
+		jsonError(w, http.StatusBadRequest, fieldTypeError(err))
+	case errors.Is(err, errUnsupportedContent):
+		jsonError(w, http.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, errEmptyBody):
+		jsonError(w, http.StatusBadRequest, err.Error())
+	default:
+		jsonError(w, http.StatusBadRequest, "invalid request body")
+	}
+}
+
+// fieldTypeError returns a message naming the field and expected type if err
+// is a JSON type mismatch on a named field, or "" otherwise.
+func fieldTypeError(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field == "" {
+		return ""
+	}
+	return fmt.Sprintf("field '%s' must be %s", typeErr.Field, jsonTypeName(typeErr.Type))
+}
+
+// jsonTypeName describes the JSON value expected for a Go type.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	default:
+		return "an object"
+	}
+}
+
+// parsePagination reads ?limit= and ?offset= from the query string. A missing
+// limit defaults to defaultLimit; larger values are silently capped at
+// maxPageSize (model.DefaultMaxPageSize if it is not positive). The limit actually
+// used is reported in the X-Page-Limit header.
+func parsePagination(w http.ResponseWriter, r *http.Request, defaultLimit, maxPageSize int) (limit, offset int, err error) {
+	if maxPageSize <= 0 {
+		maxPageSize = model.DefaultMaxPageSize
+	}
+	limit = defaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+	}
+	limit = min(limit, maxPageSize)
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	w.Header().Set("X-Page-Limit", strconv.Itoa(limit))
+	return limit, offset, nil
+}
+
+// setPaginationHeaders sets X-Total-Count and an RFC 8288 Link header with
+// first/prev/next/last page URLs for an offset-paginated listing. The links
+// keep the request's other query parameters. Their path comes from the
+// original request URI, so it keeps a base path stripped before routing.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, limit, offset, total int) {
+	path := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		path = u.Path
+	}
+	page := func(off int, rel string) string {
+		q := r.URL.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, path, q.Encode(), rel)
+	}
+
+	last := 0
+	if total > 0 {
+		last = (total - 1) / limit * limit
+	}
+	links := []string{page(0, "first")}
+	if offset > 0 {
+		links = append(links, page(max(min(offset-limit, last), 0), "prev"))
+	}
+	if offset+limit < total {
+		links = append(links, page(offset+limit, "next"))
+	}
+	links = append(links, page(last, "last"))
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// csvCell makes user-entered text safe for a CSV export: a cell starting
+// with =, +, -, @, a tab or a carriage return would run as a formula in
+// spreadsheet programs, so it gets a leading apostrophe, which they show
+// as text. Numbers and dates written by the server do not need it.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// invalid reports whether err is a refusal by the store's own checks (a
+// store.ValidationError), whose message can be shown to the caller, rather
+// than a database failure.
+func invalid(err error) bool {
+	var v *store.ValidationError
+	return errors.As(err, &v)
 }
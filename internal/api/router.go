@@ -3,66 +3,232 @@ package api
 import (
 	"database/sql"
 	"net/http"
+	"time"
 
 	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/reprocess"
+	"github.com/erazemk/skladisce/internal/store"
 )
 
+// Options holds optional runtime settings for the API router. The zero value
+// is ready to use.
+type Options struct {
+	// ReadOnly is the shared read-only toggle. If nil, a disabled toggle is
+	// created; callers that enforce read-only mode must pass their own.
+	ReadOnly *ReadOnlyMode
+
+	// DefaultRole is assigned to users created without a role. If empty, the
+	// role is required.
+	DefaultRole string
+
+	// MaxPageSize caps ?limit on paginated endpoints. If zero,
+	// model.DefaultMaxPageSize is used.
+	MaxPageSize int
+
+	// Config is the effective configuration served by GET /api/admin/config,
+	// with secrets already redacted.
+	Config map[string]string
+
+	// RestrictTransfers limits users below manager to transfers to or from
+	// the person owner linked to their account.
+	RestrictTransfers bool
+
+	// ImageJob runs bulk image reprocessing for /api/admin/reprocess-images.
+	// If nil, a job with reprocess.DefaultDelay is created; callers that need
+	// runs canceled on shutdown must pass their own.
+	ImageJob *reprocess.Job
+
+	// ReportCooldown is how long a user waits between report requests (see
+	// ReportLimiter). If zero, DefaultReportCooldown is used; a negative
+	// value turns the cooldown off.
+	ReportCooldown time.Duration
+
+	// ReadRole is the minimum role for reading items, owners, inventory and
+	// the transfers, reports and searches built on them. If empty, every
+	// role may read them.
+	ReadRole string
+}
+
 // NewRouter creates the API router with all endpoints registered.
-func NewRouter(db *sql.DB, jwtSecret string) http.Handler {
+func NewRouter(db *sql.DB, jwtSecret string, opts Options) http.Handler {
+	if opts.ReadOnly == nil {
+		opts.ReadOnly = NewReadOnlyMode(false)
+	}
+	if opts.MaxPageSize <= 0 {
+		opts.MaxPageSize = model.DefaultMaxPageSize
+	}
+	if opts.ReportCooldown == 0 {
+		opts.ReportCooldown = DefaultReportCooldown
+	}
+	if opts.ReadRole == "" {
+		opts.ReadRole = model.RoleUser
+	}
+	if opts.ImageJob == nil {
+		opts.ImageJob = &reprocess.Job{DB: db, Delay: reprocess.DefaultDelay}
+	}
+
+	st := store.New(db)
 	mux := http.NewServeMux()
 
-	authHandler := &AuthHandler{DB: db, JWTSecret: jwtSecret}
-	usersHandler := &UsersHandler{DB: db}
-	ownersHandler := &OwnersHandler{DB: db}
-	itemsHandler := &ItemsHandler{DB: db}
-	transfersHandler := &TransfersHandler{DB: db}
-	inventoryHandler := &InventoryHandler{DB: db}
+	authHandler := &AuthHandler{Store: st, JWTSecret: jwtSecret, MaxPageSize: opts.MaxPageSize}
+	usersHandler := &UsersHandler{Store: st, DefaultRole: opts.DefaultRole}
+	ownersHandler := &OwnersHandler{Store: st}
+	itemsHandler := &ItemsHandler{Store: st, MaxPageSize: opts.MaxPageSize}
+	transfersHandler := &TransfersHandler{Store: st, MaxPageSize: opts.MaxPageSize, RestrictTransfers: opts.RestrictTransfers}
+	templatesHandler := &TemplatesHandler{Store: st, Transfers: transfersHandler}
+	inventoryHandler := &InventoryHandler{Store: st, MaxPageSize: opts.MaxPageSize}
+	statusesHandler := &StatusesHandler{Store: st}
+	serialsHandler := &SerialsHandler{Store: st, RestrictTransfers: opts.RestrictTransfers}
+	documentsHandler := &DocumentsHandler{Store: st}
+	statsHandler := &StatsHandler{Store: st}
+	reportsHandler := &ReportsHandler{Store: st}
+	activityHandler := &ActivityHandler{Store: st, MaxPageSize: opts.MaxPageSize}
+	auditHandler := &AuditHandler{Store: st}
+	searchHandler := &SearchHandler{Store: st}
+	adminHandler := &AdminHandler{Store: st, JWTSecret: jwtSecret, ReadOnly: opts.ReadOnly, Config: opts.Config, ImageJob: opts.ImageJob}
 
-	authMW := AuthMiddleware(jwtSecret, db)
-	requireAdmin := RequireRole(model.RoleAdmin)
-	requireManager := RequireRole(model.RoleManager)
+	authMW := AuthMiddleware(jwtSecret, st)
+	reports := NewReportLimiter(max(opts.ReportCooldown, 0))
+	read := RequireRole(opts.ReadRole)
 
 	// Public: login.
 	mux.HandleFunc("POST /api/auth/login", authHandler.Login)
 
 	// Authenticated routes.
 	mux.Handle("PUT /api/auth/password", authMW(http.HandlerFunc(authHandler.ChangePassword)))
+	mux.Handle("POST /api/auth/verify-password", authMW(http.HandlerFunc(authHandler.VerifyPassword)))
 	mux.Handle("POST /api/auth/logout", authMW(http.HandlerFunc(authHandler.Logout)))
+	mux.Handle("GET /api/auth/can", authMW(http.HandlerFunc(authHandler.Can)))
+	mux.Handle("GET /api/auth/me/holdings", authMW(http.HandlerFunc(authHandler.Holdings)))
+	mux.Handle("GET /api/auth/me/activity", authMW(http.HandlerFunc(authHandler.Activity)))
+	mux.Handle("POST /api/auth/introspect", authMW(RequireAction(model.ActionIntrospectToken)(http.HandlerFunc(authHandler.Introspect))))
 
 	// Users (admin only).
-	mux.Handle("GET /api/users", authMW(requireAdmin(http.HandlerFunc(usersHandler.List))))
-	mux.Handle("POST /api/users", authMW(requireAdmin(http.HandlerFunc(usersHandler.Create))))
-	mux.Handle("GET /api/users/{id}", authMW(requireAdmin(http.HandlerFunc(usersHandler.Get))))
-	mux.Handle("PUT /api/users/{id}", authMW(requireAdmin(http.HandlerFunc(usersHandler.Update))))
-	mux.Handle("PUT /api/users/{id}/password", authMW(requireAdmin(http.HandlerFunc(usersHandler.ResetPassword))))
-	mux.Handle("DELETE /api/users/{id}", authMW(requireAdmin(http.HandlerFunc(usersHandler.Delete))))
-
-	// Owners: read (all roles), write (manager+).
-	mux.Handle("GET /api/owners", authMW(http.HandlerFunc(ownersHandler.List)))
-	mux.Handle("POST /api/owners", authMW(requireManager(http.HandlerFunc(ownersHandler.Create))))
-	mux.Handle("GET /api/owners/{id}", authMW(http.HandlerFunc(ownersHandler.Get)))
-	mux.Handle("PUT /api/owners/{id}", authMW(requireManager(http.HandlerFunc(ownersHandler.Update))))
-	mux.Handle("DELETE /api/owners/{id}", authMW(requireManager(http.HandlerFunc(ownersHandler.Delete))))
-	mux.Handle("GET /api/owners/{id}/inventory", authMW(http.HandlerFunc(ownersHandler.GetInventory)))
-
-	// Items: read (all roles), write (manager+).
-	mux.Handle("GET /api/items", authMW(http.HandlerFunc(itemsHandler.List)))
-	mux.Handle("POST /api/items", authMW(requireManager(http.HandlerFunc(itemsHandler.Create))))
-	mux.Handle("GET /api/items/{id}", authMW(http.HandlerFunc(itemsHandler.Get)))
-	mux.Handle("PUT /api/items/{id}", authMW(requireManager(http.HandlerFunc(itemsHandler.Update))))
-	mux.Handle("DELETE /api/items/{id}", authMW(requireManager(http.HandlerFunc(itemsHandler.Delete))))
-	mux.Handle("PUT /api/items/{id}/image", authMW(requireManager(http.HandlerFunc(itemsHandler.UploadImage))))
-	mux.Handle("GET /api/items/{id}/image", authMW(http.HandlerFunc(itemsHandler.GetImage)))
-	mux.Handle("GET /api/items/{id}/history", authMW(http.HandlerFunc(itemsHandler.GetHistory)))
-
-	// Transfers (all roles).
-	mux.Handle("POST /api/transfers", authMW(http.HandlerFunc(transfersHandler.Create)))
-	mux.Handle("GET /api/transfers", authMW(http.HandlerFunc(transfersHandler.List)))
-
-	// Inventory: read (all), write (manager+).
-	mux.Handle("GET /api/inventory", authMW(http.HandlerFunc(inventoryHandler.List)))
-	mux.Handle("POST /api/inventory/stock", authMW(requireManager(http.HandlerFunc(inventoryHandler.AddStock))))
-	mux.Handle("POST /api/inventory/adjust", authMW(requireManager(http.HandlerFunc(inventoryHandler.Adjust))))
+	mux.Handle("GET /api/users", authMW(RequireAction(model.ActionManageUsers)(http.HandlerFunc(usersHandler.List))))
+	mux.Handle("POST /api/users", authMW(RequireAction(model.ActionManageUsers)(http.HandlerFunc(usersHandler.Create))))
+	mux.Handle("GET /api/users/{id}", authMW(RequireAction(model.ActionManageUsers)(http.HandlerFunc(usersHandler.Get))))
+	mux.Handle("PUT /api/users/{id}", authMW(RequireAction(model.ActionManageUsers)(http.HandlerFunc(usersHandler.Update))))
+	mux.Handle("PUT /api/users/{id}/password", authMW(RequireAction(model.ActionManageUsers)(http.HandlerFunc(usersHandler.ResetPassword))))
+	mux.Handle("DELETE /api/users/{id}", authMW(RequireAction(model.ActionManageUsers)(http.HandlerFunc(usersHandler.Delete))))
+	mux.Handle("POST /api/users/{id}/reassign", authMW(RequireAction(model.ActionManageUsers)(http.HandlerFunc(usersHandler.Reassign))))
+
+	// Admin: server maintenance.
+	mux.Handle("GET /api/admin/readonly", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.GetReadOnly))))
+	mux.Handle("POST /api/admin/readonly", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.SetReadOnly))))
+	mux.Handle("POST /api/admin/impersonate/{id}", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.Impersonate))))
+	mux.Handle("GET /api/admin/config", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.GetConfig))))
+	mux.Handle("GET /api/admin/db-stats", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.DBStats))))
+	mux.Handle("GET /api/admin/logins", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.Logins))))
+	mux.Handle("GET /api/admin/migrations", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.Migrations))))
+	mux.Handle("POST /api/admin/optimize", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.Optimize))))
+	mux.Handle("GET /api/admin/reprocess-images", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.ReprocessImagesStatus))))
+	mux.Handle("POST /api/admin/reprocess-images", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.StartReprocessImages))))
+	mux.Handle("DELETE /api/admin/reprocess-images", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(adminHandler.CancelReprocessImages))))
+
+	// Owners: read (ReadRole), write (manager+).
+	mux.Handle("GET /api/owners", authMW(read(http.HandlerFunc(ownersHandler.List))))
+	mux.Handle("POST /api/owners", authMW(RequireAction(model.ActionCreateOwner)(http.HandlerFunc(ownersHandler.Create))))
+	mux.Handle("GET /api/owners/{id}", authMW(read(http.HandlerFunc(ownersHandler.Get))))
+	mux.Handle("PUT /api/owners/{id}", authMW(RequireAction(model.ActionEditOwner)(http.HandlerFunc(ownersHandler.Update))))
+	mux.Handle("DELETE /api/owners/{id}", authMW(RequireAction(model.ActionDeleteOwner)(http.HandlerFunc(ownersHandler.Delete))))
+	mux.Handle("POST /api/owners/{id}/restore", authMW(RequireAction(model.ActionDeleteOwner)(http.HandlerFunc(ownersHandler.Restore))))
+	mux.Handle("PUT /api/owners/{id}/user", authMW(RequireAction(model.ActionManageUsers)(http.HandlerFunc(ownersHandler.LinkUser))))
+	mux.Handle("DELETE /api/owners/{id}/user", authMW(RequireAction(model.ActionManageUsers)(http.HandlerFunc(ownersHandler.UnlinkUser))))
+	mux.Handle("GET /api/owners/{id}/inventory", authMW(read(http.HandlerFunc(ownersHandler.GetInventory))))
+	mux.Handle("POST /api/owners/{id}/move-all", authMW(RequireAction(model.ActionManageStock)(http.HandlerFunc(ownersHandler.MoveAll))))
+	mux.Handle("GET /api/owners/{id}/card", authMW(read(http.HandlerFunc(ownersHandler.Card))))
+	mux.Handle("GET /api/owners/{id}/summary", authMW(read(http.HandlerFunc(ownersHandler.GetSummary))))
+	mux.Handle("GET /api/owners/{id}/handover", authMW(read(reports.Middleware(http.HandlerFunc(ownersHandler.Handover)))))
+
+	// Items: read (ReadRole), write (manager+).
+	mux.Handle("GET /api/items", authMW(read(http.HandlerFunc(itemsHandler.List))))
+	mux.Handle("GET /api/items/suggest", authMW(read(http.HandlerFunc(itemsHandler.Suggest))))
+	mux.Handle("POST /api/items/batch-get", authMW(read(http.HandlerFunc(itemsHandler.BatchGet))))
+	mux.Handle("POST /api/items", authMW(RequireAction(model.ActionCreateItem)(http.HandlerFunc(itemsHandler.Create))))
+	mux.Handle("GET /api/items/{id}", authMW(read(http.HandlerFunc(itemsHandler.Get))))
+	mux.Handle("PUT /api/items/{id}", authMW(RequireAction(model.ActionEditItem)(http.HandlerFunc(itemsHandler.Update))))
+	mux.Handle("DELETE /api/items/{id}", authMW(RequireAction(model.ActionDeleteItem)(http.HandlerFunc(itemsHandler.Delete))))
+	mux.Handle("POST /api/items/{id}/restore", authMW(RequireAction(model.ActionDeleteItem)(http.HandlerFunc(itemsHandler.Restore))))
+	mux.Handle("POST /api/items/{id}/decommission", authMW(RequireAction(model.ActionDecommissionItem)(http.HandlerFunc(itemsHandler.Decommission))))
+	mux.Handle("POST /api/items/{id}/clone", authMW(RequireAction(model.ActionCreateItem)(http.HandlerFunc(itemsHandler.Clone))))
+	mux.Handle("PUT /api/items/{id}/min-quantity", authMW(RequireAction(model.ActionEditItem)(http.HandlerFunc(itemsHandler.SetMinQuantity))))
+	mux.Handle("PUT /api/items/{id}/reorder-target", authMW(RequireAction(model.ActionEditItem)(http.HandlerFunc(itemsHandler.SetReorderTarget))))
+	mux.Handle("PUT /api/items/{id}/image", authMW(RequireAction(model.ActionEditItem)(http.HandlerFunc(itemsHandler.UploadImage))))
+	mux.Handle("GET /api/items/{id}/image", authMW(read(http.HandlerFunc(itemsHandler.GetImage))))
+	mux.Handle("POST /api/items/{id}/image/transform", authMW(RequireAction(model.ActionEditItem)(http.HandlerFunc(itemsHandler.TransformImage))))
+	mux.Handle("GET /api/items/{id}/history", authMW(read(http.HandlerFunc(itemsHandler.GetHistory))))
+	mux.Handle("GET /api/items/{id}/history/export", authMW(read(reports.Middleware(http.HandlerFunc(itemsHandler.ExportHistory)))))
+	mux.Handle("GET /api/items/{id}/status-history", authMW(read(http.HandlerFunc(itemsHandler.GetStatusHistory))))
+	mux.Handle("GET /api/items/{id}/changelog", authMW(read(http.HandlerFunc(itemsHandler.GetChangelog))))
+	mux.Handle("GET /api/items/{id}/available", authMW(read(http.HandlerFunc(itemsHandler.GetAvailable))))
+	mux.Handle("PUT /api/items/{id}/serialized", authMW(RequireAction(model.ActionEditItem)(http.HandlerFunc(itemsHandler.SetSerialized))))
+	mux.Handle("PUT /api/items/{id}/divisible", authMW(RequireAction(model.ActionEditItem)(http.HandlerFunc(itemsHandler.SetDivisible))))
+
+	// Item statuses: read (all roles, not ReadRole: the names are
+	// configuration, not inventory), add (admin only).
+	mux.Handle("GET /api/statuses", authMW(http.HandlerFunc(statusesHandler.List)))
+	mux.Handle("POST /api/statuses", authMW(RequireAction(model.ActionAdminister)(http.HandlerFunc(statusesHandler.Create))))
+
+	// Serials of serialized items: read (ReadRole), assign and remove (manager+).
+	mux.Handle("GET /api/items/{id}/serials", authMW(read(http.HandlerFunc(serialsHandler.List))))
+	mux.Handle("POST /api/items/{id}/serials", authMW(RequireAction(model.ActionManageStock)(http.HandlerFunc(serialsHandler.Assign))))
+	mux.Handle("DELETE /api/items/{id}/serials/{serial}", authMW(RequireAction(model.ActionManageStock)(http.HandlerFunc(serialsHandler.Remove))))
+
+	// Item documents: read (ReadRole), upload and delete (manager+).
+	mux.Handle("GET /api/items/{id}/documents", authMW(read(http.HandlerFunc(documentsHandler.List))))
+	mux.Handle("POST /api/items/{id}/documents", authMW(RequireAction(model.ActionEditItem)(http.HandlerFunc(documentsHandler.Upload))))
+	mux.Handle("GET /api/items/{id}/documents/{docID}", authMW(read(http.HandlerFunc(documentsHandler.Download))))
+	mux.Handle("DELETE /api/items/{id}/documents/{docID}", authMW(RequireAction(model.ActionEditItem)(http.HandlerFunc(documentsHandler.Delete))))
+
+	// Favorites (all roles, scoped to the caller; listing needs ReadRole).
+	mux.Handle("POST /api/items/{id}/favorite", authMW(http.HandlerFunc(itemsHandler.AddFavorite)))
+	mux.Handle("DELETE /api/items/{id}/favorite", authMW(http.HandlerFunc(itemsHandler.RemoveFavorite)))
+	mux.Handle("GET /api/favorites", authMW(read(http.HandlerFunc(itemsHandler.ListFavorites))))
+
+	// Transfers: create (all roles), list (ReadRole).
+	mux.Handle("POST /api/transfers", authMW(RequireAction(model.ActionCreateTransfer)(http.HandlerFunc(transfersHandler.Create))))
+	// Validation reports balances, so it needs ReadRole as well.
+	mux.Handle("POST /api/transfers/validate", authMW(RequireAction(model.ActionCreateTransfer)(read(http.HandlerFunc(transfersHandler.Validate)))))
+	mux.Handle("POST /api/transfers/fulfill", authMW(RequireAction(model.ActionCreateTransfer)(http.HandlerFunc(transfersHandler.Fulfill))))
+	mux.Handle("POST /api/transfers/serials", authMW(RequireAction(model.ActionCreateTransfer)(http.HandlerFunc(serialsHandler.Transfer))))
+	mux.Handle("POST /api/transfers/ingest", authMW(RequireAction(model.ActionCreateTransfer)(http.HandlerFunc(transfersHandler.Ingest))))
+	mux.Handle("GET /api/transfers", authMW(read(http.HandlerFunc(transfersHandler.List))))
+
+	// Transfer templates: list (ReadRole), apply (all roles), manage (manager+).
+	mux.Handle("GET /api/transfer-templates", authMW(read(http.HandlerFunc(templatesHandler.List))))
+	mux.Handle("GET /api/transfer-templates/{id}", authMW(read(http.HandlerFunc(templatesHandler.Get))))
+	mux.Handle("POST /api/transfer-templates", authMW(RequireAction(model.ActionManageTemplates)(http.HandlerFunc(templatesHandler.Create))))
+	mux.Handle("PUT /api/transfer-templates/{id}", authMW(RequireAction(model.ActionManageTemplates)(http.HandlerFunc(templatesHandler.Update))))
+	mux.Handle("DELETE /api/transfer-templates/{id}", authMW(RequireAction(model.ActionManageTemplates)(http.HandlerFunc(templatesHandler.Delete))))
+	mux.Handle("POST /api/transfer-templates/{id}/apply", authMW(RequireAction(model.ActionCreateTransfer)(http.HandlerFunc(templatesHandler.Apply))))
+
+	// Inventory: read (ReadRole), write (manager+).
+	mux.Handle("GET /api/inventory", authMW(read(http.HandlerFunc(inventoryHandler.List))))
+	mux.Handle("GET /api/inventory/changes", authMW(read(http.HandlerFunc(inventoryHandler.Changes))))
+	mux.Handle("POST /api/inventory/stock", authMW(RequireAction(model.ActionManageStock)(http.HandlerFunc(inventoryHandler.AddStock))))
+	mux.Handle("PUT /api/inventory/stock", authMW(RequireAction(model.ActionManageStock)(http.HandlerFunc(inventoryHandler.SetStock))))
+	mux.Handle("POST /api/inventory/stock/batch", authMW(RequireAction(model.ActionManageStock)(http.HandlerFunc(inventoryHandler.AddStockBatch))))
+	mux.Handle("POST /api/inventory/import", authMW(RequireAction(model.ActionManageStock)(http.HandlerFunc(inventoryHandler.Import))))
+	mux.Handle("POST /api/inventory/adjust", authMW(RequireAction(model.ActionManageStock)(http.HandlerFunc(inventoryHandler.Adjust))))
+	mux.Handle("GET /api/inventory/snapshots", authMW(read(http.HandlerFunc(inventoryHandler.ListSnapshots))))
+	mux.Handle("POST /api/inventory/snapshots", authMW(RequireAction(model.ActionManageStock)(http.HandlerFunc(inventoryHandler.CreateSnapshot))))
+	mux.Handle("GET /api/inventory/snapshots/diff", authMW(read(http.HandlerFunc(inventoryHandler.DiffSnapshots))))
+
+	// Stats (ReadRole).
+	mux.Handle("GET /api/stats", authMW(read(http.HandlerFunc(statsHandler.Get))))
+
+	// Reports (ReadRole).
+	mux.Handle("GET /api/reports/inventory-trend", authMW(read(reports.Middleware(http.HandlerFunc(reportsHandler.InventoryTrend)))))
+	mux.Handle("GET /api/reports/reorder", authMW(read(reports.Middleware(http.HandlerFunc(reportsHandler.Reorder)))))
+
+	// Activity feed (ReadRole).
+	mux.Handle("GET /api/activity", authMW(read(http.HandlerFunc(activityHandler.List))))
+
+	// Search (ReadRole).
+	mux.Handle("GET /api/search", authMW(read(http.HandlerFunc(searchHandler.Search))))
+
+	// Audit (admin only).
+	mux.Handle("GET /api/audit/export", authMW(RequireAction(model.ActionExportAudit)(reports.Middleware(http.HandlerFunc(auditHandler.Export)))))
 
 	return mux
 }
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// SearchHandler handles the combined search across items, owners and
+// transfers.
+type SearchHandler struct {
+	Store store.Store
+}
+
+// Search result group types.
+const (
+	searchTypeItem     = "item"
+	searchTypeOwner    = "owner"
+	searchTypeTransfer = "transfer"
+)
+
+// searchGroupLimit caps the results returned in each group.
+const searchGroupLimit = 20
+
+// searchGroup is one entity type's matches. Truncated reports that more than
+// searchGroupLimit matched; the per-entity endpoints page through the rest.
+type searchGroup[T any] struct {
+	Type      string `json:"type"`
+	Results   []T    `json:"results"`
+	Truncated bool   `json:"truncated"`
+}
+
+type searchResponse struct {
+	Query     string                      `json:"query"`
+	Items     searchGroup[model.Item]     `json:"items"`
+	Owners    searchGroup[model.Owner]    `json:"owners"`
+	Transfers searchGroup[model.Transfer] `json:"transfers"`
+}
+
+// newSearchGroup caps results at searchGroupLimit, marking the group as
+// truncated when more were found.
+func newSearchGroup[T any](typ string, results []T, more bool) searchGroup[T] {
+	if len(results) > searchGroupLimit {
+		results, more = results[:searchGroupLimit], true
+	}
+	if results == nil {
+		results = []T{}
+	}
+	return searchGroup[T]{Type: typ, Results: results, Truncated: more}
+}
+
+// Search handles GET /api/search?q=. It matches item names, descriptions and
+// conditions, owner names and transfer notes, running the three searches
+// concurrently.
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		jsonError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	var (
+		items          []model.Item
+		owners         []model.Owner
+		transfers      []model.Transfer
+		transfersTotal int
+	)
+	g, ctx := errgroup.WithContext(r.Context())
+	// One extra row per group tells whether it was truncated.
+	g.Go(func() (err error) {
+		items, err = h.Store.SearchItems(ctx, query, model.ItemFilter{}, searchGroupLimit+1)
+		return err
+	})
+	g.Go(func() (err error) {
+		owners, err = h.Store.SearchOwners(ctx, query, "", searchGroupLimit+1)
+		return err
+	})
+	g.Go(func() (err error) {
+		transfers, transfersTotal, err = h.Store.ListTransfersPage(ctx, 0, 0, query, searchGroupLimit, 0)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		slog.Error("failed to search", "error", err)
+		storeError(w, err, "failed to search")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, searchResponse{
+		Query:     query,
+		Items:     newSearchGroup(searchTypeItem, items, false),
+		Owners:    newSearchGroup(searchTypeOwner, owners, false),
+		Transfers: newSearchGroup(searchTypeTransfer, transfers, transfersTotal > len(transfers)),
+	})
+}
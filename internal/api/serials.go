@@ -0,0 +1,236 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// SerialsHandler handles the serial numbers of serialized items.
+type SerialsHandler struct {
+	Store store.Store
+
+	// RestrictTransfers applies the transfer policy of
+	// TransfersHandler.RestrictTransfers to serial transfers.
+	RestrictTransfers bool
+}
+
+type assignSerialsRequest struct {
+	OwnerID int64    `json:"owner_id"`
+	Serials []string `json:"serials"`
+}
+
+type transferSerialsRequest struct {
+	ItemID      int64    `json:"item_id"`
+	FromOwnerID int64    `json:"from_owner_id"`
+	ToOwnerID   int64    `json:"to_owner_id"`
+	Serials     []string `json:"serials"`
+	Notes       string   `json:"notes"`
+}
+
+// maxSerialsPerRequest caps the serials assigned or moved in one request.
+const maxSerialsPerRequest = 500
+
+// getSerializedItem parses the {id} path value and loads the item, writing
+// the error response and returning nil if it is invalid, missing or not
+// serialized.
+func (h *SerialsHandler) getSerializedItem(w http.ResponseWriter, r *http.Request) *model.Item {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid item id")
+		return nil
+	}
+	item, err := h.Store.GetItem(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get item", "error", err)
+		storeError(w, err, "failed to get item")
+		return nil
+	}
+	if item == nil || item.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "item not found")
+		return nil
+	}
+	if !item.Serialized {
+		jsonError(w, http.StatusBadRequest, "item is not serialized")
+		return nil
+	}
+	return item
+}
+
+// List handles GET /api/items/{id}/serials. ?owner_id= limits the list to
+// one holder.
+func (h *SerialsHandler) List(w http.ResponseWriter, r *http.Request) {
+	item := h.getSerializedItem(w, r)
+	if item == nil {
+		return
+	}
+
+	var ownerID int64
+	if v := r.URL.Query().Get("owner_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			jsonError(w, http.StatusBadRequest, "invalid owner_id")
+			return
+		}
+		ownerID = id
+	}
+
+	serials, err := h.Store.ListSerials(r.Context(), item.ID, ownerID)
+	if err != nil {
+		slog.Error("failed to list serials", "error", err)
+		storeError(w, err, "failed to list serials")
+		return
+	}
+	if serials == nil {
+		serials = []model.Serial{}
+	}
+	jsonResponse(w, http.StatusOK, serials)
+}
+
+// Assign handles POST /api/items/{id}/serials. It registers new serials as
+// held by owner_id; a serial the item already has is a 409 and nothing is
+// registered.
+func (h *SerialsHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	item := h.getSerializedItem(w, r)
+	if item == nil {
+		return
+	}
+
+	var req assignSerialsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	if req.OwnerID <= 0 {
+		jsonError(w, http.StatusBadRequest, "owner_id is required")
+		return
+	}
+	if len(req.Serials) > maxSerialsPerRequest {
+		jsonError(w, http.StatusBadRequest, "too many serials in one request")
+		return
+	}
+	serials, err := model.NormalizeSerials(req.Serials)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	assigned, err := h.Store.AssignSerials(r.Context(), item.ID, req.OwnerID, serials)
+	if err != nil {
+		var taken *store.SerialAssignedError
+		switch {
+		case errors.As(err, &taken):
+			jsonResponse(w, http.StatusConflict, map[string]any{
+				"error":    err.Error(),
+				"code":     "serial_assigned",
+				"serial":   taken.Serial,
+				"owner_id": taken.OwnerID,
+			})
+		case invalid(err):
+			jsonError(w, http.StatusBadRequest, err.Error())
+		default:
+			slog.Error("failed to assign serials", "error", err)
+			storeError(w, err, "failed to assign serials")
+		}
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("serials assigned", "user", claims.Username, "item", item.Name, "owner_id", req.OwnerID, "count", len(assigned))
+	jsonResponse(w, http.StatusCreated, assigned)
+}
+
+// Remove handles DELETE /api/items/{id}/serials/{serial}, for a unit that is
+// lost or scrapped.
+func (h *SerialsHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	item := h.getSerializedItem(w, r)
+	if item == nil {
+		return
+	}
+
+	serial := r.PathValue("serial")
+	if err := h.Store.RemoveSerial(r.Context(), item.ID, serial); err != nil {
+		if invalid(err) {
+			jsonError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		slog.Error("failed to remove serial", "error", err)
+		storeError(w, err, "failed to remove serial")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("serial removed", "user", claims.Username, "item", item.Name, "serial", serial)
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "serial removed"})
+}
+
+// Transfer handles POST /api/transfers/serials. It moves the listed serials
+// of a serialized item between owners as one transfer; every serial must be
+// held by the source, or nothing moves.
+func (h *SerialsHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	var req transferSerialsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	if req.ItemID <= 0 || req.FromOwnerID <= 0 || req.ToOwnerID <= 0 {
+		jsonError(w, http.StatusBadRequest, "item_id, from_owner_id, and to_owner_id are required")
+		return
+	}
+	if req.FromOwnerID == req.ToOwnerID {
+		jsonError(w, http.StatusBadRequest, "cannot transfer to same owner")
+		return
+	}
+	if err := model.ValidateNotes(req.Notes); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Serials) > maxSerialsPerRequest {
+		jsonError(w, http.StatusBadRequest, "too many serials in one request")
+		return
+	}
+	serials, err := model.NormalizeSerials(req.Serials)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !checkTransferAllowed(w, r, h.Store, h.RestrictTransfers, req.ToOwnerID, req.FromOwnerID) {
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	var userID *int64
+	if claims != nil {
+		userID = &claims.UserID
+	}
+
+	transfer, err := h.Store.TransferSerials(r.Context(), req.ItemID, req.FromOwnerID, req.ToOwnerID, serials, req.Notes, userID)
+	if err != nil {
+		slog.Warn("serial transfer failed", "error", err)
+		var notHeld *store.SerialNotHeldError
+		switch {
+		case errors.As(err, &notHeld):
+			jsonResponse(w, http.StatusBadRequest, map[string]any{
+				"error":  err.Error(),
+				"code":   "serial_not_held",
+				"serial": notHeld.Serial,
+			})
+		case invalid(err):
+			jsonError(w, http.StatusBadRequest, err.Error())
+		default:
+			slog.Error("failed to transfer serials", "error", err)
+			storeError(w, err, "failed to transfer serials")
+		}
+		return
+	}
+
+	slog.Info("transfer created", "user", claims.Username,
+		"item", transfer.ItemName, "quantity", transfer.Quantity,
+		"from", transfer.FromOwnerName, "to", transfer.ToOwnerName, "serials", len(serials))
+	jsonResponse(w, http.StatusCreated, transfer)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// StatsHandler handles the statistics endpoint.
+type StatsHandler struct {
+	Store store.Store
+}
+
+// defaultStatsRange is the activity window used when no range is given.
+const defaultStatsRange = 30 * 24 * time.Hour
+
+// Get handles GET /api/stats?from=&to=.
+// from and to accept a date (2006-01-02) or an RFC 3339 timestamp. A date in
+// to includes that whole day.
+func (h *StatsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	to, err := parseStatsTime(r.URL.Query().Get("to"), true)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	if to.IsZero() {
+		to = now
+	}
+	from, err := parseStatsTime(r.URL.Query().Get("from"), false)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultStatsRange)
+	}
+	if !from.Before(to) {
+		jsonError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	stats, err := h.Store.GetStats(r.Context(), from, to)
+	if err != nil {
+		slog.Error("failed to get stats", "error", err)
+		storeError(w, err, "failed to get stats")
+		return
+	}
+	jsonResponse(w, http.StatusOK, stats)
+}
+
+// parseStatsTime parses a date or RFC 3339 timestamp. An empty string yields
+// the zero time. With endOfDay, a bare date means the start of the next day.
+func parseStatsTime(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.New("expected YYYY-MM-DD or RFC 3339")
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// StatusesHandler handles the item status endpoints.
+type StatusesHandler struct {
+	Store store.Store
+}
+
+type createStatusRequest struct {
+	Name string `json:"name"`
+}
+
+// List handles GET /api/statuses.
+func (h *StatusesHandler) List(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.Store.ListStatuses(r.Context())
+	if err != nil {
+		slog.Error("failed to list statuses", "error", err)
+		storeError(w, err, "failed to list statuses")
+		return
+	}
+	if statuses == nil {
+		statuses = []model.ItemStatus{}
+	}
+	jsonResponse(w, http.StatusOK, statuses)
+}
+
+// Create handles POST /api/statuses, adding a custom item status.
+func (h *StatusesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req createStatusRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	name, err := model.NormalizeStatusName(req.Name)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status, err := h.Store.CreateStatus(r.Context(), name)
+	if err != nil {
+		if invalid(err) {
+			jsonError(w, http.StatusConflict, err.Error())
+			return
+		}
+		slog.Error("failed to create status", "error", err)
+		storeError(w, err, "failed to create status")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("status created", "user", claims.Username, "status", name)
+	jsonResponse(w, http.StatusCreated, status)
+}
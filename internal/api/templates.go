@@ -0,0 +1,210 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// TemplatesHandler handles transfer template endpoints. Applying a template
+// makes an ordinary transfer through Transfers, with the same checks.
+type TemplatesHandler struct {
+	Store     store.Store
+	Transfers *TransfersHandler
+}
+
+type templateRequest struct {
+	Label       string `json:"label"`
+	FromOwnerID int64  `json:"from_owner_id"`
+	ToOwnerID   int64  `json:"to_owner_id"`
+}
+
+type applyTemplateRequest struct {
+	ItemID   int64   `json:"item_id"`
+	Quantity float64 `json:"quantity"`
+	Notes    string  `json:"notes"`
+}
+
+// List handles GET /api/transfer-templates.
+func (h *TemplatesHandler) List(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.Store.ListTransferTemplates(r.Context())
+	if err != nil {
+		slog.Error("failed to list transfer templates", "error", err)
+		storeError(w, err, "failed to list transfer templates")
+		return
+	}
+	if templates == nil {
+		templates = []model.TransferTemplate{}
+	}
+	jsonResponse(w, http.StatusOK, templates)
+}
+
+// Get handles GET /api/transfer-templates/{id}.
+func (h *TemplatesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.template(w, r)
+	if !ok {
+		return
+	}
+	jsonResponse(w, http.StatusOK, template)
+}
+
+// Create handles POST /api/transfer-templates.
+func (h *TemplatesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	template, err := h.Store.CreateTransferTemplate(r.Context(), req.Label, req.FromOwnerID, req.ToOwnerID, &claims.UserID)
+	if err != nil {
+		templateError(w, err, "failed to create transfer template")
+		return
+	}
+
+	slog.Info("transfer template created", "user", claims.Username, "template", template.Label,
+		"from", template.FromOwnerName, "to", template.ToOwnerName)
+	jsonResponse(w, http.StatusCreated, template)
+}
+
+// Update handles PUT /api/transfer-templates/{id}. It replaces the label and
+// both owners.
+func (h *TemplatesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+	req, ok := decodeTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	template, err := h.Store.UpdateTransferTemplate(r.Context(), id, req.Label, req.FromOwnerID, req.ToOwnerID)
+	if err != nil {
+		templateError(w, err, "failed to update transfer template")
+		return
+	}
+	if template == nil {
+		jsonError(w, http.StatusNotFound, "template not found")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("transfer template updated", "user", claims.Username, "template", template.Label,
+		"from", template.FromOwnerName, "to", template.ToOwnerName)
+	jsonResponse(w, http.StatusOK, template)
+}
+
+// Delete handles DELETE /api/transfer-templates/{id}.
+func (h *TemplatesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	found, err := h.Store.DeleteTransferTemplate(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to delete transfer template", "error", err)
+		storeError(w, err, "failed to delete transfer template")
+		return
+	}
+	if !found {
+		jsonError(w, http.StatusNotFound, "template not found")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	slog.Info("transfer template deleted", "user", claims.Username, "template_id", id)
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "template deleted"})
+}
+
+// Apply handles POST /api/transfer-templates/{id}/apply. It transfers
+// quantity of item_id between the template's owners, exactly like
+// POST /api/transfers; notes default to the template's label.
+func (h *TemplatesHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.template(w, r)
+	if !ok {
+		return
+	}
+
+	var req applyTemplateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	if req.Notes == "" {
+		req.Notes = template.Label
+	}
+
+	h.Transfers.create(w, r, createTransferRequest{
+		ItemID:      req.ItemID,
+		FromOwnerID: template.FromOwnerID,
+		ToOwnerID:   template.ToOwnerID,
+		Quantity:    req.Quantity,
+		Notes:       req.Notes,
+	})
+}
+
+// template looks up the template in the {id} path value, answering 400 or
+// 404 itself if there is none.
+func (h *TemplatesHandler) template(w http.ResponseWriter, r *http.Request) (*model.TransferTemplate, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid template id")
+		return nil, false
+	}
+	template, err := h.Store.GetTransferTemplate(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get transfer template", "error", err)
+		storeError(w, err, "failed to get transfer template")
+		return nil, false
+	}
+	if template == nil {
+		jsonError(w, http.StatusNotFound, "template not found")
+		return nil, false
+	}
+	return template, true
+}
+
+// decodeTemplateRequest reads and validates a template body. The label is
+// normalized like an item name.
+func decodeTemplateRequest(w http.ResponseWriter, r *http.Request) (templateRequest, bool) {
+	var req templateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return req, false
+	}
+	req.Label = strings.Join(strings.Fields(req.Label), " ")
+	if req.Label == "" {
+		jsonError(w, http.StatusBadRequest, "label required")
+		return req, false
+	}
+	if utf8.RuneCountInString(req.Label) > model.MaxNameLength {
+		jsonError(w, http.StatusBadRequest, fmt.Sprintf("label must not exceed %d characters", model.MaxNameLength))
+		return req, false
+	}
+	if req.FromOwnerID <= 0 || req.ToOwnerID <= 0 {
+		jsonError(w, http.StatusBadRequest, "from_owner_id and to_owner_id are required")
+		return req, false
+	}
+	return req, true
+}
+
+// templateError answers a failed template write: 400 for invalid owners, 500
+// otherwise.
+func templateError(w http.ResponseWriter, err error, msg string) {
+	if invalid(err) {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	slog.Error(msg, "error", err)
+	storeError(w, err, msg)
+}
@@ -1,43 +1,129 @@
 package api
 
 import (
-	"database/sql"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
+	"github.com/erazemk/skladisce/internal/auth"
 	"github.com/erazemk/skladisce/internal/model"
 	"github.com/erazemk/skladisce/internal/store"
 )
 
 // TransfersHandler handles transfer endpoints.
 type TransfersHandler struct {
-	DB *sql.DB
+	Store store.Store
+
+	// MaxPageSize caps ?limit on paginated listings.
+	MaxPageSize int
+
+	// RestrictTransfers limits users below manager to transfers to or from
+	// their own person owner (see AllowTransfer).
+	RestrictTransfers bool
+}
+
+// notOwnTransferMessage is the error for transfers forbidden by
+// RestrictTransfers.
+const notOwnTransferMessage = "you may only transfer items to or from yourself"
+
+// AllowTransfer reports whether the caller may move items from each owner in
+// from to the owner to. Managers and admins may make any transfer; other
+// users only ones whose source or destination is the person owner linked to
+// their account, so a user without one may make none. It only applies when
+// transfers are restricted.
+func AllowTransfer(ctx context.Context, st store.Store, claims *auth.Claims, to int64, from ...int64) (bool, error) {
+	if claims == nil || model.RoleAtLeast(claims.Role, model.RoleManager) {
+		return true, nil
+	}
+	own, err := st.GetUserOwner(ctx, claims.UserID)
+	if err != nil || own == nil {
+		return false, err
+	}
+	if to == own.ID {
+		return true, nil
+	}
+	for _, id := range from {
+		if id != own.ID {
+			return false, nil
+		}
+	}
+	return len(from) > 0, nil
 }
 
+// checkTransferAllowed applies AllowTransfer if restrict is set, answering
+// 403 (or 500 on a database error) and returning false if the transfer is
+// not allowed.
+func checkTransferAllowed(w http.ResponseWriter, r *http.Request, st store.Store, restrict bool, to int64, from ...int64) bool {
+	if !restrict {
+		return true
+	}
+	allowed, err := AllowTransfer(r.Context(), st, GetClaims(r.Context()), to, from...)
+	if err != nil {
+		slog.Error("failed to check transfer policy", "error", err)
+		storeError(w, err, "failed to check transfer policy")
+		return false
+	}
+	if !allowed {
+		jsonError(w, http.StatusForbidden, notOwnTransferMessage)
+		return false
+	}
+	return true
+}
+
+// transferPageDefaultLimit is the transfer list page size when ?offset is
+// given without ?limit.
+const transferPageDefaultLimit = 50
+
 type createTransferRequest struct {
-	ItemID      int64  `json:"item_id"`
-	FromOwnerID int64  `json:"from_owner_id"`
-	ToOwnerID   int64  `json:"to_owner_id"`
-	Quantity    int    `json:"quantity"`
-	Notes       string `json:"notes"`
+	ItemID      int64   `json:"item_id"`
+	FromOwnerID int64   `json:"from_owner_id"`
+	ToOwnerID   int64   `json:"to_owner_id"`
+	Quantity    float64 `json:"quantity"`
+	Notes       string  `json:"notes"`
+}
+
+// insufficientQuantityBody is the 400 body for an InsufficientQuantityError.
+func insufficientQuantityBody(e *store.InsufficientQuantityError) map[string]any {
+	return map[string]any{
+		"error":     "insufficient quantity",
+		"code":      "insufficient_quantity",
+		"available": model.QuantityNumber(e.Available, e.Divisible),
+		"requested": model.QuantityNumber(e.Requested, e.Divisible),
+	}
 }
 
 // Create handles POST /api/transfers.
 func (h *TransfersHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req createTransferRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
+	h.create(w, r, req)
+}
 
-	if req.ItemID <= 0 || req.FromOwnerID <= 0 || req.ToOwnerID <= 0 || req.Quantity <= 0 {
-		jsonError(w, http.StatusBadRequest, "item_id, from_owner_id, to_owner_id, and quantity are required and must be positive")
+// create makes the transfer in req and answers with it, for Create and for
+// applying a transfer template.
+func (h *TransfersHandler) create(w http.ResponseWriter, r *http.Request, req createTransferRequest) {
+	quantity, divisible, err := itemQuantity(r, h.Store, req.ItemID, req.Quantity)
+	if err != nil {
+		quantityError(w, err)
 		return
 	}
-
-	if req.FromOwnerID == req.ToOwnerID {
-		jsonError(w, http.StatusBadRequest, "cannot transfer to same owner")
+	if msg := validateTransferRequest(req, quantity); msg != "" {
+		jsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !checkTransferAllowed(w, r, h.Store, h.RestrictTransfers, req.ToOwnerID, req.FromOwnerID) {
 		return
 	}
 
@@ -47,19 +133,378 @@ func (h *TransfersHandler) Create(w http.ResponseWriter, r *http.Request) {
 		userID = &claims.UserID
 	}
 
-	transfer, err := store.CreateTransfer(r.Context(), h.DB, req.ItemID, req.FromOwnerID, req.ToOwnerID, req.Quantity, req.Notes, userID)
+	transfer, err := h.Store.CreateTransfer(r.Context(), req.ItemID, req.FromOwnerID, req.ToOwnerID, quantity, divisible, req.Notes, userID)
 	if err != nil {
-		slog.Warn("transfer failed", "error", err)
-		jsonError(w, http.StatusBadRequest, "transfer failed: insufficient quantity or invalid parameters")
+		var insufficient *store.InsufficientQuantityError
+		if errors.As(err, &insufficient) {
+			slog.Warn("transfer failed", "error", err)
+			jsonResponse(w, http.StatusBadRequest, insufficientQuantityBody(insufficient))
+			return
+		}
+		if invalid(err) {
+			slog.Warn("transfer failed", "error", err)
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to create transfer", "error", err)
+		storeError(w, err, "failed to create transfer")
 		return
 	}
 
 	slog.Info("transfer created", "user", claims.Username,
-		"item", transfer.ItemName, "quantity", transfer.Quantity,
+		"item", transfer.ItemName, "quantity", model.FormatQuantity(transfer.Quantity, transfer.Divisible),
 		"from", transfer.FromOwnerName, "to", transfer.ToOwnerName)
+	transfer.Warnings = h.transferWarnings(r, transfer)
 	jsonResponse(w, http.StatusCreated, transfer)
 }
 
+// warnNoLocationStock warns that a transfer left no units of its item at
+// any location, so everything is now held by people.
+const warnNoLocationStock = "item has no stock left at any location"
+
+// transferWarnings returns the warnings for transfer, which has just been
+// made. Failing to work them out is logged and yields none, since the
+// transfer itself succeeded.
+func (h *TransfersHandler) transferWarnings(r *http.Request, transfer *model.Transfer) []string {
+	var warnings []string
+	if transfer.FromOwnerType == model.OwnerTypeLocation && transfer.ToOwnerType != model.OwnerTypeLocation {
+		inventory, err := h.Store.ListInventory(r.Context(), transfer.ItemID, model.OwnerTypeLocation, "")
+		if err != nil {
+			slog.Error("failed to check transfer warnings", "error", err)
+			return nil
+		}
+		if !slices.ContainsFunc(inventory, func(inv model.Inventory) bool { return inv.Quantity > 0 }) {
+			warnings = append(warnings, warnNoLocationStock)
+		}
+	}
+	return warnings
+}
+
+type fulfillTransferRequest struct {
+	ItemID       int64   `json:"item_id"`
+	ToOwnerID    int64   `json:"to_owner_id"`
+	Quantity     float64 `json:"quantity"`
+	Notes        string  `json:"notes"`
+	FromOwnerIDs []int64 `json:"from_owner_ids"`
+}
+
+// Fulfill handles POST /api/transfers/fulfill. It moves the requested
+// quantity to to_owner_id from as many sources as needed, all or nothing,
+// and returns the transfers created.
+func (h *TransfersHandler) Fulfill(w http.ResponseWriter, r *http.Request) {
+	var req fulfillTransferRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	quantity, divisible, err := itemQuantity(r, h.Store, req.ItemID, req.Quantity)
+	if err != nil {
+		quantityError(w, err)
+		return
+	}
+	if req.ItemID <= 0 || req.ToOwnerID <= 0 || quantity <= 0 {
+		jsonError(w, http.StatusBadRequest, "item_id, to_owner_id, and quantity are required and must be positive")
+		return
+	}
+	if err := model.ValidateNotes(req.Notes); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Without from_owner_ids the sources are picked by the server, so only
+	// the destination can make the transfers the caller's own.
+	if !checkTransferAllowed(w, r, h.Store, h.RestrictTransfers, req.ToOwnerID, req.FromOwnerIDs...) {
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	var userID *int64
+	if claims != nil {
+		userID = &claims.UserID
+	}
+
+	transfers, err := h.Store.FulfillTransfer(r.Context(), req.ItemID, req.ToOwnerID, quantity, divisible, req.FromOwnerIDs, req.Notes, userID)
+	if err != nil {
+		var insufficient *store.InsufficientQuantityError
+		if errors.As(err, &insufficient) {
+			slog.Warn("transfer fulfillment failed", "error", err)
+			jsonResponse(w, http.StatusBadRequest, insufficientQuantityBody(insufficient))
+			return
+		}
+		if invalid(err) {
+			slog.Warn("transfer fulfillment failed", "error", err)
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to fulfill transfer", "error", err)
+		storeError(w, err, "failed to fulfill transfer")
+		return
+	}
+
+	slog.Info("transfer fulfilled", "user", claims.Username,
+		"item", transfers[0].ItemName, "quantity", req.Quantity,
+		"to", transfers[0].ToOwnerName, "sources", len(transfers))
+	jsonResponse(w, http.StatusCreated, transfers)
+}
+
+// validateTransferResponse reports whether a transfer would succeed. On
+// success it carries the resulting balances (a model.TransferPreview); on
+// failure the reason, and for insufficient quantity the amounts involved.
+// Quantities are in whole units.
+type validateTransferResponse struct {
+	Valid        bool        `json:"valid"`
+	ItemID       int64       `json:"item_id,omitempty"`
+	FromOwnerID  int64       `json:"from_owner_id,omitempty"`
+	ToOwnerID    int64       `json:"to_owner_id,omitempty"`
+	Quantity     json.Number `json:"quantity,omitempty"`
+	FromQuantity json.Number `json:"from_quantity,omitempty"`
+	ToQuantity   json.Number `json:"to_quantity,omitempty"`
+	Divisible    bool        `json:"divisible,omitempty"`
+	Error        string      `json:"error,omitempty"`
+	Code         string      `json:"code,omitempty"`
+	Available    json.Number `json:"available,omitempty"`
+	Requested    json.Number `json:"requested,omitempty"`
+}
+
+// Validate handles POST /api/transfers/validate. It takes the same body as
+// Create and reports whether the transfer would succeed without making it.
+// A transfer that would fail is still a 200 with valid=false.
+func (h *TransfersHandler) Validate(w http.ResponseWriter, r *http.Request) {
+	var req createTransferRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+
+	quantity, divisible, err := itemQuantity(r, h.Store, req.ItemID, req.Quantity)
+	if err != nil && !invalid(err) {
+		quantityError(w, err)
+		return
+	}
+	if err != nil {
+		jsonResponse(w, http.StatusOK, validateTransferResponse{Error: err.Error()})
+		return
+	}
+	if msg := validateTransferRequest(req, quantity); msg != "" {
+		jsonResponse(w, http.StatusOK, validateTransferResponse{Error: msg})
+		return
+	}
+	if h.RestrictTransfers {
+		allowed, err := AllowTransfer(r.Context(), h.Store, GetClaims(r.Context()), req.ToOwnerID, req.FromOwnerID)
+		if err != nil {
+			slog.Error("failed to check transfer policy", "error", err)
+			storeError(w, err, "failed to validate transfer")
+			return
+		}
+		if !allowed {
+			jsonResponse(w, http.StatusOK, validateTransferResponse{Error: notOwnTransferMessage, Code: "forbidden"})
+			return
+		}
+	}
+
+	preview, err := h.Store.CheckTransfer(r.Context(), req.ItemID, req.FromOwnerID, req.ToOwnerID, quantity, divisible)
+	var insufficient *store.InsufficientQuantityError
+	switch {
+	case errors.As(err, &insufficient):
+		jsonResponse(w, http.StatusOK, validateTransferResponse{
+			Error:     "insufficient quantity",
+			Code:      "insufficient_quantity",
+			Available: model.QuantityNumber(insufficient.Available, insufficient.Divisible),
+			Requested: model.QuantityNumber(insufficient.Requested, insufficient.Divisible),
+		})
+	case err != nil && !invalid(err):
+		// Anything but a ValidationError comes from the database.
+		slog.Error("failed to validate transfer", "error", err)
+		storeError(w, err, "failed to validate transfer")
+	case err != nil:
+		jsonResponse(w, http.StatusOK, validateTransferResponse{Error: err.Error()})
+	default:
+		jsonResponse(w, http.StatusOK, validateTransferResponse{
+			Valid:        true,
+			ItemID:       preview.ItemID,
+			FromOwnerID:  preview.FromOwnerID,
+			ToOwnerID:    preview.ToOwnerID,
+			Quantity:     model.QuantityNumber(preview.Quantity, preview.Divisible),
+			FromQuantity: model.QuantityNumber(preview.FromQuantity, preview.Divisible),
+			ToQuantity:   model.QuantityNumber(preview.ToQuantity, preview.Divisible),
+			Divisible:    preview.Divisible,
+		})
+	}
+}
+
+// validateTransferRequest returns an error message if req is incomplete,
+// moves items to the owner they come from or has overlong notes, or "" if it
+// is valid. quantity is req.Quantity converted by itemQuantity.
+func validateTransferRequest(req createTransferRequest, quantity int) string {
+	if req.ItemID <= 0 || req.FromOwnerID <= 0 || req.ToOwnerID <= 0 || quantity <= 0 {
+		return "item_id, from_owner_id, to_owner_id, and quantity are required and must be positive"
+	}
+	if req.FromOwnerID == req.ToOwnerID {
+		return "cannot transfer to same owner"
+	}
+	if err := model.ValidateNotes(req.Notes); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// maxIngestLineSize caps a single NDJSON line in a transfer ingest.
+const maxIngestLineSize = 64 << 10
+
+// ingestResult reports the outcome of one NDJSON line. Line is 1-based and
+// counts blank lines, so it matches the uploaded file.
+type ingestResult struct {
+	Line      int             `json:"line"`
+	Transfer  *model.Transfer `json:"transfer,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Code      string          `json:"code,omitempty"`
+	Available json.Number     `json:"available,omitempty"`
+	Requested json.Number     `json:"requested,omitempty"`
+}
+
+// ingestResponse embeds the model.BulkResult summary, whose error indexes
+// are Line minus one. Created duplicates Succeeded and is kept for existing
+// clients.
+type ingestResponse struct {
+	model.BulkResult
+	Created int            `json:"created"`
+	Results []ingestResult `json:"results"`
+}
+
+// Ingest handles POST /api/transfers/ingest. The body is NDJSON with one
+// transfer request per line. Lines are read and applied one at a time, in
+// order, each in its own transaction: a failing line is reported and the
+// rest still run.
+func (h *TransfersHandler) Ingest(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-ndjson" {
+		jsonError(w, http.StatusUnsupportedMediaType, "content type must be application/x-ndjson")
+		return
+	}
+	defer r.Body.Close()
+
+	claims := GetClaims(r.Context())
+	var userID *int64
+	if claims != nil {
+		userID = &claims.UserID
+	}
+
+	resp := ingestResponse{BulkResult: model.NewBulkResult(), Results: []ingestResult{}}
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 4096), maxIngestLineSize)
+	line, stop := 0, false
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		res := ingestResult{Line: line}
+		var req createTransferRequest
+		var quantity int
+		var divisible bool
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&req)
+		if err != nil {
+			res.Error = fieldTypeError(err)
+			if res.Error == "" {
+				res.Error = "invalid JSON"
+			}
+		} else if quantity, divisible, err = itemQuantity(r, h.Store, req.ItemID, req.Quantity); err != nil {
+			res.Error = err.Error()
+			if !invalid(err) {
+				slog.Error("failed to check item", "line", line, "error", err)
+				res.Error = "failed to check item"
+			}
+		} else if msg := validateTransferRequest(req, quantity); msg != "" {
+			res.Error = msg
+		} else if msg, code := h.checkIngestAllowed(r, line, req); msg != "" {
+			res.Error, res.Code = msg, code
+		} else {
+			transfer, err := h.Store.CreateTransfer(r.Context(), req.ItemID, req.FromOwnerID, req.ToOwnerID, quantity, divisible, req.Notes, userID)
+			var insufficient *store.InsufficientQuantityError
+			switch {
+			case errors.As(err, &insufficient):
+				res.Error, res.Code = "insufficient quantity", "insufficient_quantity"
+				res.Available = model.QuantityNumber(insufficient.Available, insufficient.Divisible)
+				res.Requested = model.QuantityNumber(insufficient.Requested, insufficient.Divisible)
+			case invalid(err):
+				res.Error = err.Error()
+			case err != nil:
+				// A database failure would likely fail the remaining lines
+				// too, so reading stops here. If nothing was applied yet,
+				// the whole request fails and can simply be retried.
+				slog.Error("failed to create ingested transfer", "line", line, "error", err)
+				if resp.Succeeded == 0 {
+					storeError(w, err, "failed to create transfer")
+					return
+				}
+				res.Error = "failed to create transfer"
+				if errors.Is(err, store.ErrBusy) {
+					res.Error, res.Code = "database busy, try again", "busy"
+				}
+				stop = true
+			default:
+				res.Transfer = transfer
+			}
+		}
+
+		if res.Error != "" {
+			resp.Fail(line-1, res.Error)
+		} else {
+			resp.Succeed()
+			resp.Created++
+			slog.Info("transfer created", "user", claims.Username,
+				"item", res.Transfer.ItemName, "quantity", model.FormatQuantity(res.Transfer.Quantity, res.Transfer.Divisible),
+				"from", res.Transfer.FromOwnerName, "to", res.Transfer.ToOwnerName, "ingest", true)
+		}
+		resp.Results = append(resp.Results, res)
+		if stop {
+			break
+		}
+	}
+	// Earlier lines are already applied, so a read error is reported as a
+	// final failed line rather than discarding their results.
+	if err := scanner.Err(); err != nil {
+		res := ingestResult{Line: line + 1, Error: "invalid request body"}
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			res.Error = "request body too large"
+		case errors.Is(err, bufio.ErrTooLong):
+			res.Error = fmt.Sprintf("line longer than %d bytes", maxIngestLineSize)
+		}
+		resp.Fail(line, res.Error)
+		resp.Results = append(resp.Results, res)
+	}
+	if len(resp.Results) == 0 {
+		jsonError(w, http.StatusBadRequest, errEmptyBody.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// checkIngestAllowed applies AllowTransfer to an ingested line if transfers
+// are restricted, returning the line's error message and code if it may not
+// be applied.
+func (h *TransfersHandler) checkIngestAllowed(r *http.Request, line int, req createTransferRequest) (string, string) {
+	if !h.RestrictTransfers {
+		return "", ""
+	}
+	allowed, err := AllowTransfer(r.Context(), h.Store, GetClaims(r.Context()), req.ToOwnerID, req.FromOwnerID)
+	if err != nil {
+		slog.Error("failed to check transfer policy", "line", line, "error", err)
+		return "failed to check transfer policy", ""
+	}
+	if !allowed {
+		return notOwnTransferMessage, "forbidden"
+	}
+	return "", ""
+}
+
 // List handles GET /api/transfers.
 func (h *TransfersHandler) List(w http.ResponseWriter, r *http.Request) {
 	var itemID, ownerID int64
@@ -82,10 +527,34 @@ func (h *TransfersHandler) List(w http.ResponseWriter, r *http.Request) {
 		ownerID = id
 	}
 
-	transfers, err := store.ListTransfers(r.Context(), h.DB, itemID, ownerID)
+	notesContains := strings.TrimSpace(r.URL.Query().Get("notes_contains"))
+
+	// Pagination is opt-in; without limit/offset the newest 500 are returned.
+	q := r.URL.Query()
+	if q.Has("limit") || q.Has("offset") {
+		limit, offset, err := parsePagination(w, r, transferPageDefaultLimit, h.MaxPageSize)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		transfers, total, err := h.Store.ListTransfersPage(r.Context(), itemID, ownerID, notesContains, limit, offset)
+		if err != nil {
+			slog.Error("failed to list transfers", "error", err)
+			storeError(w, err, "failed to list transfers")
+			return
+		}
+		if transfers == nil {
+			transfers = []model.Transfer{}
+		}
+		setPaginationHeaders(w, r, limit, offset, total)
+		jsonResponse(w, http.StatusOK, transfers)
+		return
+	}
+
+	transfers, err := h.Store.ListTransfers(r.Context(), itemID, ownerID, notesContains)
 	if err != nil {
 		slog.Error("failed to list transfers", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to list transfers")
+		storeError(w, err, "failed to list transfers")
 		return
 	}
 	if transfers == nil {
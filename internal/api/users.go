@@ -1,7 +1,6 @@
 package api
 
 import (
-	"database/sql"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -15,7 +14,11 @@ import (
 
 // UsersHandler handles user management endpoints (admin only).
 type UsersHandler struct {
-	DB *sql.DB
+	Store store.Store
+
+	// DefaultRole is used when a create request omits the role. If empty,
+	// the role is required.
+	DefaultRole string
 }
 
 type createUserRequest struct {
@@ -28,16 +31,24 @@ type updateUserRequest struct {
 	Role string `json:"role"`
 }
 
+// reassignRequest is the body of POST /api/users/{id}/reassign.
+type reassignRequest struct {
+	To     int64 `json:"to"`
+	Delete bool  `json:"delete"`
+}
+
 type resetPasswordRequest struct {
 	Password string `json:"password"`
+	// MustChangePassword makes the user pick a new password at next login.
+	MustChangePassword bool `json:"must_change_password"`
 }
 
 // List handles GET /api/users.
 func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
-	users, err := store.ListUsers(r.Context(), h.DB)
+	users, err := h.Store.ListUsers(r.Context())
 	if err != nil {
 		slog.Error("failed to list users", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to list users")
+		storeError(w, err, "failed to list users")
 		return
 	}
 	if users == nil {
@@ -50,16 +61,19 @@ func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
 func (h *UsersHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req createUserRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
+	if req.Role == "" {
+		req.Role = h.DefaultRole
+	}
 	if req.Username == "" || req.Password == "" || req.Role == "" {
 		jsonError(w, http.StatusBadRequest, "username, password, and role required")
 		return
 	}
 
-	if req.Role != model.RoleAdmin && req.Role != model.RoleManager && req.Role != model.RoleUser {
+	if !model.ValidRole(req.Role) {
 		jsonError(w, http.StatusBadRequest, "invalid role")
 		return
 	}
@@ -75,7 +89,7 @@ func (h *UsersHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := store.CreateUser(r.Context(), h.DB, req.Username, string(hash), req.Role)
+	user, err := h.Store.CreateUser(r.Context(), req.Username, string(hash), req.Role)
 	if err != nil {
 		jsonError(w, http.StatusConflict, "username already exists")
 		return
@@ -94,10 +108,10 @@ func (h *UsersHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := store.GetUser(r.Context(), h.DB, id)
+	user, err := h.Store.GetUser(r.Context(), id)
 	if err != nil {
 		slog.Error("failed to get user", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to get user")
+		storeError(w, err, "failed to get user")
 		return
 	}
 	if user == nil {
@@ -118,7 +132,7 @@ func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	var req updateUserRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
@@ -127,13 +141,24 @@ func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := store.UpdateUser(r.Context(), h.DB, id, req.Role); err != nil {
+	existing, err := h.Store.GetUser(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get user", "error", err)
+		storeError(w, err, "failed to update user")
+		return
+	}
+	if existing == nil || existing.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	if err := h.Store.UpdateUser(r.Context(), id, req.Role); err != nil {
 		slog.Error("failed to update user", "error", err)
-		jsonError(w, http.StatusInternalServerError, "failed to update user")
+		storeError(w, err, "failed to update user")
 		return
 	}
 
-	user, _ := store.GetUser(r.Context(), h.DB, id)
+	user, _ := h.Store.GetUser(r.Context(), id)
 	claims := GetClaims(r.Context())
 	if user != nil {
 		slog.Info("user role updated", "user", claims.Username, "target_user", user.Username, "new_role", req.Role)
@@ -151,7 +176,7 @@ func (h *UsersHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 
 	var req resetPasswordRequest
 	if err := decodeJSON(r, &req); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid request body")
+		decodeError(w, err)
 		return
 	}
 
@@ -171,19 +196,19 @@ func (h *UsersHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := store.UpdateUserPassword(r.Context(), h.DB, id, string(hash)); err != nil {
+	if err := h.Store.UpdateUserPassword(r.Context(), id, string(hash), req.MustChangePassword); err != nil {
 		slog.Error("failed to reset password", "error", err)
 		jsonError(w, http.StatusNotFound, "user not found")
 		return
 	}
 
 	claims := GetClaims(r.Context())
-	target, _ := store.GetUser(r.Context(), h.DB, id)
+	target, _ := h.Store.GetUser(r.Context(), id)
 	targetName := fmt.Sprintf("id:%d", id)
 	if target != nil {
 		targetName = target.Username
 	}
-	slog.Info("user password reset", "user", claims.Username, "target_user", targetName)
+	slog.Info("user password reset", "user", claims.Username, "target_user", targetName, "must_change", req.MustChangePassword)
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "password reset"})
 }
 
@@ -203,13 +228,13 @@ func (h *UsersHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Look up target name before deleting.
-	target, _ := store.GetUser(r.Context(), h.DB, id)
+	target, _ := h.Store.GetUser(r.Context(), id)
 	targetName := fmt.Sprintf("id:%d", id)
 	if target != nil {
 		targetName = target.Username
 	}
 
-	if err := store.DeleteUser(r.Context(), h.DB, id); err != nil {
+	if err := h.Store.DeleteUser(r.Context(), id); err != nil {
 		slog.Error("failed to delete user", "error", err)
 		jsonError(w, http.StatusNotFound, "user not found")
 		return
@@ -218,3 +243,65 @@ func (h *UsersHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	slog.Info("user deleted", "user", claims.Username, "deleted_user", targetName)
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "user deleted"})
 }
+
+// Reassign handles POST /api/users/{id}/reassign. It repoints the records
+// attributed to the user to another user and optionally deletes the user.
+func (h *UsersHandler) Reassign(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req reassignRequest
+	if err := decodeJSON(r, &req); err != nil {
+		decodeError(w, err)
+		return
+	}
+	if req.To <= 0 {
+		jsonError(w, http.StatusBadRequest, "to (target user id) required")
+		return
+	}
+	if req.To == id {
+		jsonError(w, http.StatusBadRequest, "cannot reassign a user's records to themselves")
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	if req.Delete && claims.UserID == id {
+		jsonError(w, http.StatusBadRequest, "cannot delete yourself")
+		return
+	}
+
+	source, err := h.Store.GetUser(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to get user", "error", err)
+		storeError(w, err, "failed to reassign records")
+		return
+	}
+	if source == nil {
+		jsonError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	target, err := h.Store.GetUser(r.Context(), req.To)
+	if err != nil {
+		slog.Error("failed to get user", "error", err)
+		storeError(w, err, "failed to reassign records")
+		return
+	}
+	if target == nil || target.DeletedAt != nil {
+		jsonError(w, http.StatusNotFound, "target user not found")
+		return
+	}
+
+	result, err := h.Store.ReassignUserRecords(r.Context(), id, req.To, req.Delete)
+	if err != nil {
+		slog.Error("failed to reassign records", "error", err)
+		storeError(w, err, "failed to reassign records")
+		return
+	}
+
+	slog.Info("user records reassigned", "user", claims.Username, "from_user", source.Username, "to_user", target.Username,
+		"transfers", result.Transfers, "status_changes", result.StatusChanges, "deleted", result.Deleted)
+	jsonResponse(w, http.StatusOK, result)
+}
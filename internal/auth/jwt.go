@@ -14,28 +14,60 @@ type Claims struct {
 	UserID   int64  `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// ImpersonatedBy is the username of the admin acting as this user. Empty
+	// for regular tokens.
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+	// MustChangePassword restricts the token to changing the password and
+	// logging out.
+	MustChangePassword bool `json:"must_change_password,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // TokenExpiry is the default token lifetime.
 const TokenExpiry = 7 * 24 * time.Hour
 
+// ImpersonationExpiry is the lifetime of impersonation tokens.
+const ImpersonationExpiry = time.Hour
+
 // GenerateToken creates a new JWT for a user with a unique JTI.
 func GenerateToken(secret string, userID int64, username, role string) (string, error) {
+	return generateToken(secret, Claims{UserID: userID, Username: username, Role: role}, TokenExpiry)
+}
+
+// GeneratePasswordChangeToken creates a JWT for a user who must change their
+// password before doing anything else.
+func GeneratePasswordChangeToken(secret string, userID int64, username, role string) (string, error) {
+	return generateToken(secret, Claims{
+		UserID:             userID,
+		Username:           username,
+		Role:               role,
+		MustChangePassword: true,
+	}, TokenExpiry)
+}
+
+// GenerateImpersonationToken creates a short-lived JWT that lets the admin
+// named impersonatedBy act as the given user.
+func GenerateImpersonationToken(secret string, userID int64, username, role, impersonatedBy string) (string, error) {
+	return generateToken(secret, Claims{
+		UserID:         userID,
+		Username:       username,
+		Role:           role,
+		ImpersonatedBy: impersonatedBy,
+	}, ImpersonationExpiry)
+}
+
+// generateToken fills in the registered claims and signs the token.
+func generateToken(secret string, claims Claims, ttl time.Duration) (string, error) {
 	jti, err := generateJTI()
 	if err != nil {
 		return "", fmt.Errorf("generating JTI: %w", err)
 	}
 
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ID:        jti,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExpiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -65,3 +65,32 @@ func TestTokenExpiry(t *testing.T) {
 		t.Errorf("token expiry too far from expected: diff=%v", diff)
 	}
 }
+
+func TestGenerateImpersonationToken(t *testing.T) {
+	secret := "test"
+	token, err := GenerateImpersonationToken(secret, 2, "alice", model.RoleUser, "admin")
+	if err != nil {
+		t.Fatalf("GenerateImpersonationToken: %v", err)
+	}
+	claims, err := ValidateToken(secret, token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.UserID != 2 || claims.Username != "alice" {
+		t.Errorf("expected claims for alice, got %+v", claims)
+	}
+	if claims.ImpersonatedBy != "admin" {
+		t.Errorf("expected impersonated_by 'admin', got %q", claims.ImpersonatedBy)
+	}
+
+	diff := time.Now().Add(ImpersonationExpiry).Sub(claims.ExpiresAt.Time)
+	if diff < -5*time.Second || diff > 5*time.Second {
+		t.Errorf("impersonation expiry too far from expected: diff=%v", diff)
+	}
+
+	regular, _ := GenerateToken(secret, 1, "admin", model.RoleAdmin)
+	claims, _ = ValidateToken(secret, regular)
+	if claims.ImpersonatedBy != "" {
+		t.Errorf("expected empty impersonated_by on regular token, got %q", claims.ImpersonatedBy)
+	}
+}
@@ -0,0 +1,139 @@
+// Package backup takes scheduled copies of the database into a directory and
+// prunes the old ones.
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// Backup files are named skladisce-<UTC timestamp>.sqlite3, so sorting the
+// names sorts them by age.
+const (
+	filePrefix = "skladisce-"
+	fileSuffix = ".sqlite3"
+	timeLayout = "20060102-150405"
+)
+
+// FileName returns the name of a backup taken at t.
+func FileName(t time.Time) string {
+	return filePrefix + t.UTC().Format(timeLayout) + fileSuffix
+}
+
+// isBackup reports whether name is a file named by FileName.
+func isBackup(name string) bool {
+	stamp, ok := strings.CutPrefix(name, filePrefix)
+	if !ok {
+		return false
+	}
+	stamp, ok = strings.CutSuffix(stamp, fileSuffix)
+	if !ok {
+		return false
+	}
+	_, err := time.Parse(timeLayout, stamp)
+	return err == nil
+}
+
+// Scheduler backs the database up into Dir every Interval, keeping the
+// newest Keep backups.
+type Scheduler struct {
+	DB       *sql.DB
+	Dir      string
+	Interval time.Duration
+
+	// Keep is how many backups to keep; older ones are deleted after each
+	// backup. Zero keeps them all.
+	Keep int
+}
+
+// Run backs up once immediately and then every Interval until ctx is done.
+// A backup in progress when ctx is canceled is aborted and its partial file
+// removed, so Run returns promptly on shutdown.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if path, err := s.Backup(ctx, time.Now()); err != nil {
+			if ctx.Err() == nil {
+				slog.Error("backup failed", "error", err)
+			}
+		} else {
+			slog.Info("backup written", "path", path)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backup writes a backup named for now into Dir, then prunes the old ones.
+// The copy is written under a temporary name and renamed when complete, so a
+// failed or aborted backup never looks like a finished one.
+func (s *Scheduler) Backup(ctx context.Context, now time.Time) (string, error) {
+	path := filepath.Join(s.Dir, FileName(now))
+	tmp := path + ".tmp"
+	// A leftover from a crash would make VACUUM INTO fail.
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("removing stale backup: %w", err)
+	}
+	if err := store.VacuumInto(ctx, s.DB, tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("renaming backup: %w", err)
+	}
+
+	removed, err := Prune(s.Dir, s.Keep)
+	if err != nil {
+		return path, fmt.Errorf("pruning backups: %w", err)
+	}
+	for _, name := range removed {
+		slog.Info("old backup removed", "path", filepath.Join(s.Dir, name))
+	}
+	return path, nil
+}
+
+// Prune deletes all but the newest keep backups in dir and returns the names
+// of those deleted, oldest first. Other files are left alone. keep 0 deletes
+// nothing.
+func Prune(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing backups: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && isBackup(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) <= keep {
+		return nil, nil
+	}
+	slices.Sort(names)
+
+	old := names[:len(names)-keep]
+	for i, name := range old {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return old[:i], fmt.Errorf("removing backup: %w", err)
+		}
+	}
+	return old, nil
+}
@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC)
+	var backups []string
+	for day := range 5 {
+		name := FileName(start.AddDate(0, 0, day))
+		backups = append(backups, name)
+		os.WriteFile(filepath.Join(dir, name), nil, 0600)
+	}
+	// Files that only look like backups are never touched.
+	others := []string{"skladisce.sqlite3", "skladisce-20250101-000000.sqlite3.tmp", "skladisce-latest.sqlite3", "notes.txt"}
+	for _, name := range others {
+		os.WriteFile(filepath.Join(dir, name), nil, 0600)
+	}
+
+	if removed, err := Prune(dir, 0); err != nil || len(removed) != 0 {
+		t.Fatalf("expected keep 0 to remove nothing, got %v %v", removed, err)
+	}
+	if removed, err := Prune(dir, 10); err != nil || len(removed) != 0 {
+		t.Fatalf("expected nothing removed below the limit, got %v %v", removed, err)
+	}
+
+	removed, err := Prune(dir, 2)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if !slices.Equal(removed, backups[:3]) {
+		t.Errorf("expected the 3 oldest removed, got %v", removed)
+	}
+	entries, _ := os.ReadDir(dir)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	want := append(slices.Clone(others), backups[3:]...)
+	slices.Sort(want)
+	if !slices.Equal(left, want) {
+		t.Errorf("expected %v left, got %v", want, left)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewTestDB(t)
+	store.CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+
+	s := &Scheduler{DB: database, Dir: t.TempDir(), Keep: 1}
+	now := time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC)
+	first, err := s.Backup(ctx, now)
+	if err != nil {
+		t.Fatalf("first backup: %v", err)
+	}
+	second, err := s.Backup(ctx, now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("second backup: %v", err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("expected the first backup pruned, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := s.Backup(canceled, now.Add(48*time.Hour)); err == nil {
+		t.Error("expected a canceled backup to fail")
+	}
+	entries, _ := os.ReadDir(s.Dir)
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(second) {
+		t.Errorf("expected only the second backup left, got %v", entries)
+	}
+
+	copied, err := db.Open(second)
+	if err != nil {
+		t.Fatalf("opening backup: %v", err)
+	}
+	defer copied.Close()
+	owners, err := store.ListOwners(ctx, copied, "")
+	if err != nil || len(owners) != 1 || owners[0].Name != "Storage" {
+		t.Errorf("expected the backup to hold Storage, got %v %v", owners, err)
+	}
+}
@@ -0,0 +1,253 @@
+// Package config resolves the server settings from command-line flags,
+// SKLADISCE_* environment variables and an optional JSON config file.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// EnvPrefix is prepended to a setting's upper-cased long flag name (with
+// dashes replaced by underscores) to form its environment variable, e.g.
+// -data-dir is read from SKLADISCE_DATA_DIR.
+const EnvPrefix = "SKLADISCE_"
+
+// Config holds every server setting.
+type Config struct {
+	// ConfigFile is the JSON config file the other settings were read from.
+	ConfigFile string
+
+	DB             string
+	Addr           string
+	AdminUser      string
+	AdminPassword  string
+	Log            string
+	DataDir        string
+	MaxRequests    int
+	MaxBody        int64
+	ReadOnly       bool
+	BasePath       string
+	CSP            string
+	HSTS           bool
+	TLSCert        string
+	TLSKey         string
+	TrustedProxies string
+	MinPassword    int
+	DefaultRole    string
+	ReadRole       string
+	MaxItems       int
+	MaxOwners      int
+	MaxPageSize    int
+
+	RestrictTransfers bool
+
+	LowStockInterval time.Duration
+	LowStockWebhook  string
+
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+}
+
+// Default returns the settings used when nothing else sets them.
+func Default() Config {
+	return Config{
+		DB:          "skladisce.sqlite3",
+		Addr:        ":8080",
+		AdminUser:   "Admin",
+		MaxRequests: 64,
+		MaxBody:     8 << 20,
+		CSP:         model.DefaultCSP,
+		MinPassword: model.DefaultMinPasswordLength,
+		MaxPageSize: model.DefaultMaxPageSize,
+
+		LowStockInterval: 5 * time.Minute,
+
+		BackupInterval: 24 * time.Hour,
+		BackupKeep:     7,
+	}
+}
+
+// Load resolves the settings from args (without the program name), the
+// environment read through lookupEnv, and the config file named by -config or
+// SKLADISCE_CONFIG. Flags take precedence over the environment, which takes
+// precedence over the file, which takes precedence over Default. It returns
+// the arguments remaining after the flags; flag.ErrHelp is returned as is for
+// -h and -help.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, []string, error) {
+	// A first pass finds -config and rejects bad flags before anything is read.
+	scratch := Default()
+	fs := newFlagSet(&scratch)
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	path := scratch.ConfigFile
+	if path == "" {
+		path, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+
+	cfg := Default()
+	fs = newFlagSet(&cfg)
+	if path != "" {
+		if err := applyFile(fs, path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := applyEnv(fs, lookupEnv); err != nil {
+		return nil, nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	cfg.ConfigFile = path
+	return &cfg, fs.Args(), nil
+}
+
+// newFlagSet binds the flags to cfg, keeping its current values as defaults.
+// Errors are returned rather than printed; the caller prints usage.
+func newFlagSet(cfg *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet("skladisce", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+
+	fs.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "")
+	fs.StringVar(&cfg.DB, "db", cfg.DB, "")
+	fs.StringVar(&cfg.DB, "d", cfg.DB, "")
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "")
+	fs.StringVar(&cfg.Addr, "a", cfg.Addr, "")
+	fs.StringVar(&cfg.AdminUser, "user", cfg.AdminUser, "")
+	fs.StringVar(&cfg.AdminUser, "u", cfg.AdminUser, "")
+	fs.StringVar(&cfg.AdminPassword, "admin-password", cfg.AdminPassword, "")
+	fs.StringVar(&cfg.Log, "log", cfg.Log, "")
+	fs.StringVar(&cfg.Log, "l", cfg.Log, "")
+	fs.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "")
+	fs.IntVar(&cfg.MaxRequests, "max-requests", cfg.MaxRequests, "")
+	fs.Int64Var(&cfg.MaxBody, "max-body", cfg.MaxBody, "")
+	fs.BoolVar(&cfg.ReadOnly, "readonly", cfg.ReadOnly, "")
+	fs.StringVar(&cfg.BasePath, "base-path", cfg.BasePath, "")
+	fs.StringVar(&cfg.CSP, "csp", cfg.CSP, "")
+	fs.BoolVar(&cfg.HSTS, "hsts", cfg.HSTS, "")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "")
+	fs.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "")
+	fs.StringVar(&cfg.TrustedProxies, "trusted-proxies", cfg.TrustedProxies, "")
+	fs.IntVar(&cfg.MinPassword, "min-password", cfg.MinPassword, "")
+	fs.StringVar(&cfg.DefaultRole, "default-role", cfg.DefaultRole, "")
+	fs.StringVar(&cfg.ReadRole, "read-role", cfg.ReadRole, "")
+	fs.BoolVar(&cfg.RestrictTransfers, "restrict-transfers", cfg.RestrictTransfers, "")
+	fs.IntVar(&cfg.MaxItems, "max-items", cfg.MaxItems, "")
+	fs.IntVar(&cfg.MaxOwners, "max-owners", cfg.MaxOwners, "")
+	fs.IntVar(&cfg.MaxPageSize, "max-page-size", cfg.MaxPageSize, "")
+	fs.DurationVar(&cfg.LowStockInterval, "low-stock-interval", cfg.LowStockInterval, "")
+	fs.StringVar(&cfg.LowStockWebhook, "low-stock-webhook", cfg.LowStockWebhook, "")
+	fs.StringVar(&cfg.BackupDir, "backup-dir", cfg.BackupDir, "")
+	fs.DurationVar(&cfg.BackupInterval, "backup-interval", cfg.BackupInterval, "")
+	fs.IntVar(&cfg.BackupKeep, "backup-keep", cfg.BackupKeep, "")
+	return fs
+}
+
+// Redacted replaces the value of a secret setting in Effective.
+const Redacted = "[redacted]"
+
+// secretSettings are the settings whose values Effective never reveals. A
+// webhook URL commonly carries its access token in the path or query.
+var secretSettings = map[string]bool{
+	"admin-password":    true,
+	"low-stock-webhook": true,
+}
+
+// Effective returns every setting by its long flag name (the keys a config
+// file uses), formatted as a flag value, with "config" naming the file they
+// were read from. Secret settings that are set read Redacted; an unset one
+// stays empty, so it is still clear whether it is configured.
+func (c *Config) Effective() map[string]string {
+	cfg := *c
+	fs := newFlagSet(&cfg)
+	out := map[string]string{"config": c.ConfigFile}
+	fs.VisitAll(func(f *flag.Flag) {
+		if !setting(f.Name) {
+			return
+		}
+		value := f.Value.String()
+		if secretSettings[f.Name] && value != "" {
+			value = Redacted
+		}
+		out[f.Name] = value
+	})
+	return out
+}
+
+// setting reports whether name is a long flag that the environment and the
+// config file may set. Single-letter aliases and -config itself are excluded.
+func setting(name string) bool {
+	return len(name) > 1 && name != "config"
+}
+
+// envName returns the environment variable for the long flag name.
+func envName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnv sets every flag that has its environment variable set. An empty
+// value counts as set, so e.g. SKLADISCE_CSP= disables the header.
+func applyEnv(fs *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || !setting(f.Name) {
+			return
+		}
+		if v, ok := lookupEnv(envName(f.Name)); ok {
+			if setErr := fs.Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("invalid %s: %w", envName(f.Name), setErr)
+			}
+		}
+	})
+	return err
+}
+
+// applyFile sets the flags named by the keys of the JSON object in path, e.g.
+// {"db": "/data/skladisce.sqlite3", "max-requests": 32, "hsts": true}.
+// Unknown keys are rejected so typos do not go unnoticed.
+func applyFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !setting(name) || fs.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+		raw := bytes.TrimSpace(values[name])
+		value := string(raw)
+		if len(raw) > 0 && raw[0] == '"' {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return fmt.Errorf("config file %s: %s: %w", path, name, err)
+			}
+		} else if len(raw) > 0 && (raw[0] == '{' || raw[0] == '[' || string(raw) == "null") {
+			return fmt.Errorf("config file %s: %s must be a string, number or boolean", path, name)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("config file %s: invalid %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
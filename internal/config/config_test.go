@@ -0,0 +1,188 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// env returns a lookupEnv func backed by vars.
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "skladisce.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, args, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if *cfg != Default() {
+		t.Errorf("expected defaults, got %+v", cfg)
+	}
+	if cfg.CSP != model.DefaultCSP || cfg.MaxBody != 8<<20 {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+	if len(args) != 0 {
+		t.Errorf("expected no remaining args, got %v", args)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `{
+		"db": "file.sqlite3",
+		"addr": ":7000",
+		"user": "FileAdmin",
+		"max-requests": 16,
+		"hsts": true
+	}`)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want func(*Config)
+	}{
+		{
+			name: "file over default",
+			args: []string{"-config", path},
+			want: func(c *Config) {
+				c.ConfigFile = path
+				c.DB, c.Addr, c.AdminUser, c.MaxRequests, c.HSTS = "file.sqlite3", ":7000", "FileAdmin", 16, true
+			},
+		},
+		{
+			name: "env over file",
+			args: []string{"-config", path},
+			env:  map[string]string{"SKLADISCE_DB": "env.sqlite3", "SKLADISCE_MAX_REQUESTS": "32", "SKLADISCE_HSTS": "false"},
+			want: func(c *Config) {
+				c.ConfigFile = path
+				c.DB, c.Addr, c.AdminUser, c.MaxRequests, c.HSTS = "env.sqlite3", ":7000", "FileAdmin", 32, false
+			},
+		},
+		{
+			name: "flags over env",
+			args: []string{"-config", path, "-d", "flag.sqlite3", "-max-requests", "8"},
+			env:  map[string]string{"SKLADISCE_DB": "env.sqlite3", "SKLADISCE_MAX_REQUESTS": "32", "SKLADISCE_ADDR": ":9000"},
+			want: func(c *Config) {
+				c.ConfigFile = path
+				c.DB, c.Addr, c.AdminUser, c.MaxRequests, c.HSTS = "flag.sqlite3", ":9000", "FileAdmin", 8, true
+			},
+		},
+		{
+			name: "config file from env",
+			env:  map[string]string{"SKLADISCE_CONFIG": path},
+			want: func(c *Config) {
+				c.ConfigFile = path
+				c.DB, c.Addr, c.AdminUser, c.MaxRequests, c.HSTS = "file.sqlite3", ":7000", "FileAdmin", 16, true
+			},
+		},
+		{
+			name: "empty env value is set",
+			env:  map[string]string{"SKLADISCE_CSP": "", "SKLADISCE_DATA_DIR": "/var/lib/skladisce"},
+			want: func(c *Config) { c.CSP, c.DataDir = "", "/var/lib/skladisce" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := Load(tt.args, env(tt.env))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			want := Default()
+			tt.want(&want)
+			if *cfg != want {
+				t.Errorf("got  %+v\nwant %+v", *cfg, want)
+			}
+		})
+	}
+}
+
+func TestLoadRemainingArgs(t *testing.T) {
+	_, args, err := Load([]string{"-db", "x.sqlite3", "optimize", "-vacuum"}, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if strings.Join(args, " ") != "optimize -vacuum" {
+		t.Errorf("expected [optimize -vacuum], got %v", args)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, _, err := Load([]string{"-h"}, env(nil)); err != flag.ErrHelp {
+		t.Errorf("expected flag.ErrHelp for -h, got %v", err)
+	}
+	if _, _, err := Load([]string{"-nope"}, env(nil)); err == nil {
+		t.Error("expected error for unknown flag")
+	}
+	if _, _, err := Load(nil, env(map[string]string{"SKLADISCE_MAX_BODY": "lots"})); err == nil || !strings.Contains(err.Error(), "SKLADISCE_MAX_BODY") {
+		t.Errorf("expected error naming SKLADISCE_MAX_BODY, got %v", err)
+	}
+	if _, _, err := Load([]string{"-config", filepath.Join(t.TempDir(), "missing.json")}, env(nil)); err == nil {
+		t.Error("expected error for missing config file")
+	}
+
+	for _, content := range []string{`{"dbb": "x"}`, `{"d": "x"}`, `{"max-items": "many"}`, `{"db": null}`, `[1]`} {
+		path := writeConfig(t, content)
+		if _, _, err := Load([]string{"-config", path}, env(nil)); err == nil {
+			t.Errorf("%s: expected error", content)
+		}
+	}
+}
+
+func TestEffectiveRedactsSecrets(t *testing.T) {
+	path := writeConfig(t, `{"low-stock-webhook": "https://hooks.example.com/T000/secret-token"}`)
+	cfg, _, err := Load([]string{"-db", "/data/inv.sqlite3", "-max-page-size", "50", "-hsts"}, env(map[string]string{
+		"SKLADISCE_CONFIG": path,
+	}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got := cfg.Effective()
+	if got["low-stock-webhook"] != Redacted {
+		t.Errorf("expected the webhook redacted, got %q", got["low-stock-webhook"])
+	}
+	for _, v := range got {
+		if strings.Contains(v, "secret-token") {
+			t.Fatalf("secret leaked in %v", got)
+		}
+	}
+	want := map[string]string{
+		"config":        path,
+		"db":            "/data/inv.sqlite3",
+		"max-page-size": "50",
+		"hsts":          "true",
+		"addr":          ":8080",
+		"csp":           model.DefaultCSP,
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s: got %q, want %q", name, got[name], value)
+		}
+	}
+	if _, ok := got["d"]; ok {
+		t.Error("expected single-letter aliases to be left out")
+	}
+
+	// An unset secret stays empty, so it is clear that it is not configured.
+	if got := Default(); got.Effective()["low-stock-webhook"] != "" {
+		t.Errorf("expected an unset webhook to stay empty, got %q", got.Effective()["low-stock-webhook"])
+	}
+}
@@ -3,13 +3,23 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
 
 // Open opens a SQLite database connection and configures pragmas.
+//
+// CURRENT_TIMESTAMP values carry no zone and are parsed as UTC by the driver.
+// _time_format=sqlite makes bound time.Time values use the same layout (plus
+// offset) instead of Go's String() form, which includes the local zone name
+// and a monotonic clock reading and does not sort or parse reliably.
 func Open(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", path)
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", path+sep+"_time_format=sqlite")
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
@@ -0,0 +1,350 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// migrations are applied in order on top of the base schema. The number of
+// applied migrations is tracked in PRAGMA user_version, so entries must only
+// ever be appended — never reordered or edited once released. Each applied
+// migration is also recorded in schema_migrations with a checksum of its
+// text, which shows when one was edited after the fact.
+var migrations = []string{
+	// 1: track when each inventory row last changed, and keep tombstones for
+	// rows that dropped to zero, so clients can sync incrementally.
+	`ALTER TABLE inventory ADD COLUMN updated_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+	 UPDATE inventory SET updated_at = CURRENT_TIMESTAMP;
+	 CREATE INDEX IF NOT EXISTS idx_inventory_updated_at ON inventory(updated_at);
+	 CREATE TABLE IF NOT EXISTS inventory_tombstones (
+	     item_id    INTEGER NOT NULL REFERENCES items(id),
+	     owner_id   INTEGER NOT NULL REFERENCES owners(id),
+	     deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	     PRIMARY KEY (item_id, owner_id)
+	 );`,
+	// 2: per-user pinned items.
+	`CREATE TABLE IF NOT EXISTS user_favorites (
+	     user_id    INTEGER NOT NULL REFERENCES users(id),
+	     item_id    INTEGER NOT NULL REFERENCES items(id),
+	     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	     PRIMARY KEY (user_id, item_id)
+	 );`,
+	// 3: record who soft-deleted an item or owner, and why.
+	`ALTER TABLE items ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+	 ALTER TABLE items ADD COLUMN delete_reason TEXT;
+	 ALTER TABLE owners ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+	 ALTER TABLE owners ADD COLUMN delete_reason TEXT;`,
+	// 4: tokens issued before a user's last password change are rejected.
+	`ALTER TABLE users ADD COLUMN password_changed_at DATETIME;`,
+	// 5: users who must change their password before doing anything else.
+	`ALTER TABLE users ADD COLUMN must_change_password BOOLEAN NOT NULL DEFAULT 0;`,
+	// 6: short free-text condition note on items, separate from the status.
+	`ALTER TABLE items ADD COLUMN condition TEXT;`,
+	// 7: per-user web UI language.
+	`ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT 'sl';`,
+	// 8: low-stock threshold per item and when the scanner last alerted on it.
+	`ALTER TABLE items ADD COLUMN min_quantity INTEGER;
+	 ALTER TABLE items ADD COLUMN low_stock_alerted_at DATETIME;`,
+	// 9: serialized items, whose units are tracked individually by serial
+	// number, and which serials each of their transfers moved.
+	`ALTER TABLE items ADD COLUMN serialized BOOLEAN NOT NULL DEFAULT 0;
+	 CREATE TABLE IF NOT EXISTS serials (
+	     item_id    INTEGER NOT NULL REFERENCES items(id),
+	     serial     TEXT NOT NULL,
+	     owner_id   INTEGER NOT NULL REFERENCES owners(id),
+	     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	     updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	     PRIMARY KEY (item_id, serial)
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_serials_owner ON serials(item_id, owner_id);
+	 CREATE TABLE IF NOT EXISTS transfer_serials (
+	     transfer_id INTEGER NOT NULL REFERENCES transfers(id),
+	     serial      TEXT NOT NULL,
+	     PRIMARY KEY (transfer_id, serial)
+	 );`,
+	// 10: documents (receipts, manuals) attached to items, stored as is.
+	`CREATE TABLE IF NOT EXISTS item_documents (
+	     id          INTEGER PRIMARY KEY,
+	     item_id     INTEGER NOT NULL REFERENCES items(id),
+	     filename    TEXT NOT NULL,
+	     mime        TEXT NOT NULL,
+	     data        BLOB NOT NULL,
+	     uploaded_by INTEGER REFERENCES users(id),
+	     created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_item_documents_item ON item_documents(item_id);`,
+	// 11: ledger of inventory removed by lifecycle actions such as
+	// decommissioning, which leave no transfer behind.
+	`CREATE TABLE IF NOT EXISTS inventory_adjustments (
+	     id         INTEGER PRIMARY KEY,
+	     item_id    INTEGER NOT NULL REFERENCES items(id),
+	     owner_id   INTEGER NOT NULL REFERENCES owners(id),
+	     delta      INTEGER NOT NULL CHECK (delta != 0),
+	     kind       TEXT NOT NULL CHECK (kind IN ('decommissioned')),
+	     reason     TEXT,
+	     user_id    INTEGER REFERENCES users(id),
+	     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_inventory_adjustments_item ON inventory_adjustments(item_id);`,
+	// 12: item statuses become a lookup table that admins can extend. SQLite
+	// cannot drop a CHECK constraint, so items is rebuilt with status
+	// referencing the table instead.
+	`CREATE TABLE IF NOT EXISTS statuses (
+	     name       TEXT PRIMARY KEY,
+	     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	 );
+	 INSERT OR IGNORE INTO statuses (name) VALUES ('active'), ('damaged'), ('lost'), ('removed');
+	 CREATE TABLE items_new (
+	     id                   INTEGER PRIMARY KEY,
+	     name                 TEXT NOT NULL,
+	     description          TEXT,
+	     image                BLOB,
+	     image_mime           TEXT,
+	     status               TEXT NOT NULL DEFAULT 'active' REFERENCES statuses(name),
+	     created_at           DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	     updated_at           DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	     deleted_at           DATETIME,
+	     deleted_by           INTEGER REFERENCES users(id),
+	     delete_reason        TEXT,
+	     condition            TEXT,
+	     min_quantity         INTEGER,
+	     low_stock_alerted_at DATETIME,
+	     serialized           BOOLEAN NOT NULL DEFAULT 0
+	 );
+	 INSERT INTO items_new (id, name, description, image, image_mime, status, created_at, updated_at,
+	                        deleted_at, deleted_by, delete_reason, condition, min_quantity,
+	                        low_stock_alerted_at, serialized)
+	 SELECT id, name, description, image, image_mime, status, created_at, updated_at,
+	        deleted_at, deleted_by, delete_reason, condition, min_quantity,
+	        low_stock_alerted_at, serialized
+	 FROM items;
+	 DROP TABLE items;
+	 ALTER TABLE items_new RENAME TO items;`,
+	// 13: the person owner that stands for a user account, if any, for the
+	// optional restriction of transfers to the user's own items.
+	`ALTER TABLE owners ADD COLUMN user_id INTEGER REFERENCES users(id);
+	 CREATE UNIQUE INDEX IF NOT EXISTS idx_owners_user ON owners(user_id) WHERE user_id IS NOT NULL;`,
+	// 14: divisible items (measured in kg, liters, ...), whose quantities in
+	// inventory, transfers, adjustments and min_quantity are thousandths.
+	`ALTER TABLE items ADD COLUMN divisible BOOLEAN NOT NULL DEFAULT 0;`,
+	// 15: successful and failed logins, for the admin security overview.
+	`CREATE TABLE IF NOT EXISTS login_events (
+	     id         INTEGER PRIMARY KEY,
+	     user_id    INTEGER REFERENCES users(id),
+	     username   TEXT NOT NULL,
+	     success    BOOLEAN NOT NULL,
+	     ip         TEXT NOT NULL,
+	     user_agent TEXT NOT NULL,
+	     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_login_events_created ON login_events(created_at);
+	 CREATE INDEX IF NOT EXISTS idx_login_events_user ON login_events(user_id, created_at);`,
+	// 16: SHA-256 of the stored item image, so re-uploading the same photo
+	// can skip the write. Images stored before stay NULL until replaced.
+	`ALTER TABLE items ADD COLUMN image_hash TEXT;`,
+	// 17: named from/to owner pairs for recurring transfers.
+	`CREATE TABLE IF NOT EXISTS transfer_templates (
+	     id            INTEGER PRIMARY KEY,
+	     label         TEXT NOT NULL,
+	     from_owner_id INTEGER NOT NULL REFERENCES owners(id),
+	     to_owner_id   INTEGER NOT NULL REFERENCES owners(id),
+	     created_by    INTEGER REFERENCES users(id),
+	     created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	     updated_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	     CHECK (from_owner_id != to_owner_id)
+	 );`,
+	// 18: daily totals of units held per item status, for trend charts.
+	`CREATE TABLE IF NOT EXISTS inventory_snapshots (
+	     day    TEXT NOT NULL,
+	     status TEXT NOT NULL,
+	     units  INTEGER NOT NULL,
+	     PRIMARY KEY (day, status)
+	 );`,
+	// 19: the quantity procurement restocks an item up to once it is low.
+	`ALTER TABLE items ADD COLUMN reorder_target INTEGER;`,
+	// 20: on-demand copies of every inventory balance, for comparing two
+	// points in time.
+	`CREATE TABLE IF NOT EXISTS stock_snapshots (
+	     id       INTEGER PRIMARY KEY,
+	     taken_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	     taken_by INTEGER REFERENCES users(id),
+	     notes    TEXT NOT NULL DEFAULT ''
+	 );
+	 CREATE TABLE IF NOT EXISTS stock_snapshot_lines (
+	     snapshot_id INTEGER NOT NULL REFERENCES stock_snapshots(id),
+	     item_id     INTEGER NOT NULL REFERENCES items(id),
+	     owner_id    INTEGER NOT NULL REFERENCES owners(id),
+	     quantity    INTEGER NOT NULL,
+	     PRIMARY KEY (snapshot_id, item_id, owner_id)
+	 );`,
+	// 21: item status changes, with who made them and why. Databases created
+	// while this table was part of the base schema already have it.
+	`CREATE TABLE IF NOT EXISTS status_changes (
+	     id          INTEGER PRIMARY KEY,
+	     item_id     INTEGER NOT NULL REFERENCES items(id),
+	     from_status TEXT NOT NULL,
+	     to_status   TEXT NOT NULL,
+	     reason      TEXT,
+	     user_id     INTEGER REFERENCES users(id),
+	     created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_status_changes_item ON status_changes(item_id);`,
+	// 22: batch stock additions are recorded in the adjustments ledger too.
+	// SQLite cannot alter a CHECK constraint, so the table is rebuilt.
+	`CREATE TABLE inventory_adjustments_new (
+	     id         INTEGER PRIMARY KEY,
+	     item_id    INTEGER NOT NULL REFERENCES items(id),
+	     owner_id   INTEGER NOT NULL REFERENCES owners(id),
+	     delta      INTEGER NOT NULL CHECK (delta != 0),
+	     kind       TEXT NOT NULL CHECK (kind IN ('decommissioned', 'stock_added')),
+	     reason     TEXT,
+	     user_id    INTEGER REFERENCES users(id),
+	     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	 );
+	 INSERT INTO inventory_adjustments_new (id, item_id, owner_id, delta, kind, reason, user_id, created_at)
+	 SELECT id, item_id, owner_id, delta, kind, reason, user_id, created_at FROM inventory_adjustments;
+	 DROP TABLE inventory_adjustments;
+	 ALTER TABLE inventory_adjustments_new RENAME TO inventory_adjustments;
+	 CREATE INDEX IF NOT EXISTS idx_inventory_adjustments_item ON inventory_adjustments(item_id);`,
+	// 23: set stock records its difference in the adjustments ledger.
+	`CREATE TABLE inventory_adjustments_new (
+	     id         INTEGER PRIMARY KEY,
+	     item_id    INTEGER NOT NULL REFERENCES items(id),
+	     owner_id   INTEGER NOT NULL REFERENCES owners(id),
+	     delta      INTEGER NOT NULL CHECK (delta != 0),
+	     kind       TEXT NOT NULL CHECK (kind IN ('decommissioned', 'stock_added', 'stock_set')),
+	     reason     TEXT,
+	     user_id    INTEGER REFERENCES users(id),
+	     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	 );
+	 INSERT INTO inventory_adjustments_new (id, item_id, owner_id, delta, kind, reason, user_id, created_at)
+	 SELECT id, item_id, owner_id, delta, kind, reason, user_id, created_at FROM inventory_adjustments;
+	 DROP TABLE inventory_adjustments;
+	 ALTER TABLE inventory_adjustments_new RENAME TO inventory_adjustments;
+	 CREATE INDEX IF NOT EXISTS idx_inventory_adjustments_item ON inventory_adjustments(item_id);`,
+}
+
+// MigrationChecksums returns the checksum of each known migration, in order;
+// migration n (user_version n) is at index n-1.
+func MigrationChecksums() []string {
+	sums := make([]string, len(migrations))
+	for i, m := range migrations {
+		sums[i] = checksum(m)
+	}
+	return sums
+}
+
+// checksum returns the hex SHA-256 of a migration's text.
+func checksum(migration string) string {
+	sum := sha256.Sum256([]byte(migration))
+	return hex.EncodeToString(sum[:])
+}
+
+// migrate applies all migrations newer than the database's user_version.
+// Each migration runs in its own transaction together with the version bump
+// and its schema_migrations row.
+//
+// Foreign key enforcement is switched off while migrating, as SQLite requires
+// for rebuilding a table that other tables reference, and every migration
+// must leave no violations behind before it commits.
+func migrate(db *sql.DB) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	var version int
+	if err := conn.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if err := recordApplied(ctx, conn, version); err != nil {
+		return err
+	}
+	if version >= len(migrations) {
+		return nil
+	}
+
+	var foreignKeys bool
+	if err := conn.QueryRowContext(ctx, `PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil {
+		return fmt.Errorf("reading foreign key setting: %w", err)
+	}
+	// PRAGMA foreign_keys is a no-op inside a transaction.
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return fmt.Errorf("disabling foreign keys: %w", err)
+	}
+	if foreignKeys {
+		defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if err := applyMigration(ctx, conn, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordApplied adds schema_migrations rows, without an applied_at, for
+// migrations up to version that were applied before they were recorded.
+// Their checksum is that of the current text, since the original is unknown.
+func recordApplied(ctx context.Context, conn *sql.Conn, version int) error {
+	for i := 0; i < version && i < len(migrations); i++ {
+		_, err := conn.ExecContext(ctx,
+			`INSERT OR IGNORE INTO schema_migrations (version, checksum) VALUES (?, ?)`,
+			i+1, checksum(migrations[i]),
+		)
+		if err != nil {
+			return fmt.Errorf("recording migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs migrations[i] and records version i+1 in one
+// transaction.
+func applyMigration(ctx context.Context, conn *sql.Conn, i int) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning migration %d: %w", i+1, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+		return fmt.Errorf("applying migration %d: %w", i+1, err)
+	}
+	// foreign_key_check returns one row per violation.
+	rows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return fmt.Errorf("checking foreign keys after migration %d: %w", i+1, err)
+	}
+	violation := rows.Next()
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("checking foreign keys after migration %d: %w", i+1, err)
+	}
+	if violation {
+		return fmt.Errorf("migration %d violates foreign key constraints", i+1)
+	}
+	// PRAGMA does not accept bound parameters.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+		return fmt.Errorf("recording migration %d: %w", i+1, err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO schema_migrations (version, checksum, applied_at)
+		 VALUES (?, ?, CURRENT_TIMESTAMP)`,
+		i+1, checksum(migrations[i]),
+	)
+	if err != nil {
+		return fmt.Errorf("recording migration %d: %w", i+1, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing migration %d: %w", i+1, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationsRunOnceAndAreRecorded(t *testing.T) {
+	orig := migrations
+	t.Cleanup(func() { migrations = orig })
+	// A migration that fails if run twice.
+	migrations = append(migrations[:len(migrations):len(migrations)],
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES ('migration-test', CURRENT_TIMESTAMP);`)
+
+	database, err := Open(filepath.Join(t.TempDir(), "migrate.sqlite3"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	for run := 1; run <= 2; run++ {
+		if err := EnsureSchema(database); err != nil {
+			t.Fatalf("EnsureSchema run %d: %v", run, err)
+		}
+	}
+
+	var version, recorded, unstamped int
+	database.QueryRow(`PRAGMA user_version`).Scan(&version)
+	database.QueryRow(`SELECT COUNT(*), COUNT(*) - COUNT(applied_at) FROM schema_migrations`).Scan(&recorded, &unstamped)
+	if version != len(migrations) || recorded != len(migrations) || unstamped != 0 {
+		t.Errorf("expected %d migrations applied and recorded with a time, got version %d, %d rows, %d without time",
+			len(migrations), version, recorded, unstamped)
+	}
+
+	var sum string
+	database.QueryRow(`SELECT checksum FROM schema_migrations WHERE version = ?`, len(migrations)).Scan(&sum)
+	if want := MigrationChecksums()[len(migrations)-1]; sum != want {
+		t.Errorf("expected checksum %s, got %s", want, sum)
+	}
+}
+
+func TestMigrationsAppliedBeforeRecordingAreBackfilled(t *testing.T) {
+	database, err := Open(filepath.Join(t.TempDir(), "backfill.sqlite3"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := EnsureSchema(database); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+
+	// A database migrated before schema_migrations existed.
+	database.Exec(`DELETE FROM schema_migrations`)
+	if err := EnsureSchema(database); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+
+	var recorded, stamped int
+	database.QueryRow(`SELECT COUNT(*), COUNT(applied_at) FROM schema_migrations`).Scan(&recorded, &stamped)
+	if recorded != len(migrations) || stamped != 0 {
+		t.Errorf("expected %d backfilled rows without a time, got %d rows, %d with time", len(migrations), recorded, stamped)
+	}
+}
+
+func TestStatusChangesMigrationKeepsExistingTable(t *testing.T) {
+	database, err := Open(filepath.Join(t.TempDir(), "status.sqlite3"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := EnsureSchema(database); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+
+	// A database created while status_changes was in the base schema, with
+	// history already recorded, before migration 21.
+	database.Exec(`INSERT INTO items (id, name) VALUES (1, 'Drill')`)
+	database.Exec(`INSERT INTO status_changes (item_id, from_status, to_status) VALUES (1, 'active', 'lost')`)
+	database.Exec(`DELETE FROM schema_migrations WHERE version = 21`)
+	database.Exec(`PRAGMA user_version = 20`)
+	if err := EnsureSchema(database); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+
+	var changes int
+	database.QueryRow(`SELECT COUNT(*) FROM status_changes`).Scan(&changes)
+	if changes != 1 {
+		t.Errorf("expected the recorded status change kept, got %d rows", changes)
+	}
+}
@@ -5,7 +5,8 @@ import (
 	"fmt"
 )
 
-// schema is the full database schema.
+// schema is the base database schema. Changes to existing tables are applied
+// on top of it by migrations (see migrations.go).
 const schema = `
 CREATE TABLE IF NOT EXISTS users (
     id            INTEGER PRIMARY KEY,
@@ -66,13 +67,25 @@ CREATE TABLE IF NOT EXISTS revoked_tokens (
     jti        TEXT PRIMARY KEY,
     expires_at DATETIME NOT NULL
 );
+
+-- One row per applied migration. applied_at is NULL for migrations applied
+-- before they were recorded here.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    checksum   TEXT NOT NULL,
+    applied_at DATETIME
+);
 `
 
-// EnsureSchema creates all tables and indexes if they don't already exist.
+// EnsureSchema creates all tables and indexes if they don't already exist,
+// then applies any pending migrations.
 func EnsureSchema(db *sql.DB) error {
 	_, err := db.Exec(schema)
 	if err != nil {
 		return fmt.Errorf("creating schema: %w", err)
 	}
+	if err := migrate(db); err != nil {
+		return fmt.Errorf("migrating schema: %w", err)
+	}
 	return nil
 }
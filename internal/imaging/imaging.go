@@ -2,12 +2,15 @@ package imaging
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"golang.org/x/image/draw"
 )
@@ -15,48 +18,176 @@ import (
 // MaxDimension is the maximum width or height for stored images.
 const MaxDimension = 1024
 
-// JPEGQuality is the compression quality for JPEG output.
+// MaxUploadSize is the maximum size in bytes of an uploaded image file.
+const MaxUploadSize = 5 << 20
+
+// JPEGQuality is the default compression quality for JPEG output.
 const JPEGQuality = 85
 
+// Uploads may ask for a different quality, clamped to this range so blurry
+// low-quality images cannot be stored by accident.
+const (
+	MinJPEGQuality = 50
+	MaxJPEGQuality = 100
+)
+
 // AllowedMIME lists the accepted input MIME types.
 var AllowedMIME = map[string]bool{
 	"image/jpeg": true,
 	"image/png":  true,
 }
 
+// Markup uploads are rejected with a specific error so the user learns why,
+// rather than getting a generic "unsupported format" naming text/xml.
+var (
+	ErrSVG  = errors.New("SVG images are not accepted (only JPEG and PNG)")
+	ErrHTML = errors.New("file is HTML, not an image (only JPEG and PNG accepted)")
+)
+
+// TooLarge reports whether err was caused by an upload body exceeding the
+// limit set with http.MaxBytesReader.
+func TooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// TooLargeMessage is the error shown when an upload exceeds MaxUploadSize.
+var TooLargeMessage = fmt.Sprintf("image exceeds %d MB", MaxUploadSize>>20)
+
 // ProcessResult contains the processed image data.
 type ProcessResult struct {
 	Data []byte
 	MIME string
 }
 
+// ParseQuality parses an upload's quality field. An empty value means
+// JPEGQuality; other values must be integers and are clamped to
+// MinJPEGQuality..MaxJPEGQuality.
+func ParseQuality(s string) (int, error) {
+	if s == "" {
+		return JPEGQuality, nil
+	}
+	q, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("quality must be an integer")
+	}
+	return min(max(q, MinJPEGQuality), MaxJPEGQuality), nil
+}
+
 // Process reads image data, validates the format by sniffing bytes,
 // downscales if larger than MaxDimension, and re-encodes with compression.
 // Always outputs JPEG for consistency and smaller file sizes.
 func Process(r io.Reader) (*ProcessResult, error) {
+	return ProcessQuality(r, JPEGQuality)
+}
+
+// ProcessQuality is Process with the given JPEG quality, clamped to
+// MinJPEGQuality..MaxJPEGQuality.
+func ProcessQuality(r io.Reader, quality int) (*ProcessResult, error) {
+	quality = min(max(quality, MinJPEGQuality), MaxJPEGQuality)
+
 	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, fmt.Errorf("reading image data: %w", err)
 	}
 
+	if err := detectMarkup(data); err != nil {
+		return nil, err
+	}
+
 	// Sniff actual MIME type from bytes (not trusting client headers).
 	detected := http.DetectContentType(data)
 	if !AllowedMIME[detected] {
 		return nil, fmt.Errorf("unsupported image format: %s (only JPEG and PNG accepted)", detected)
 	}
 
-	// Decode the image.
-	img, _, err := image.Decode(bytes.NewReader(data))
+	// Decode the image. Only the registered JPEG/PNG decoders are tried, and
+	// the output is re-encoded from pixels, so nothing else in the file
+	// survives.
+	img, format, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("decoding image: %w", err)
 	}
+	if "image/"+format != detected {
+		return nil, fmt.Errorf("image content (%s) does not match detected format %s", format, detected)
+	}
 
 	// Downscale if needed.
 	img = downscale(img, MaxDimension)
 
 	// Re-encode as JPEG.
 	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("encoding JPEG: %w", err)
+	}
+
+	return &ProcessResult{
+		Data: buf.Bytes(),
+		MIME: "image/jpeg",
+	}, nil
+}
+
+// Conforms reports whether stored image data already matches what Process
+// produces: a JPEG no larger than MaxDimension on either side. Such images
+// gain nothing from being processed again.
+func Conforms(data []byte) bool {
+	if http.DetectContentType(data) != "image/jpeg" {
+		return false
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != "jpeg" {
+		return false
+	}
+	return cfg.Width <= MaxDimension && cfg.Height <= MaxDimension
+}
+
+// Transform decodes a stored image, rotates it clockwise by rotate degrees
+// (0, 90, 180 or 270), then flips it horizontally ("h") or vertically ("v")
+// if flip is set, and re-encodes it as JPEG.
+func Transform(data []byte, rotate int, flip string) (*ProcessResult, error) {
+	if rotate != 0 && rotate != 90 && rotate != 180 && rotate != 270 {
+		return nil, fmt.Errorf("rotate must be 90, 180 or 270")
+	}
+	if flip != "" && flip != "h" && flip != "v" {
+		return nil, fmt.Errorf("flip must be 'h' or 'v'")
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if rotate == 90 || rotate == 270 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x, y
+			switch rotate {
+			case 90:
+				dx, dy = h-1-y, x
+			case 180:
+				dx, dy = w-1-x, h-1-y
+			case 270:
+				dx, dy = y, w-1-x
+			}
+			switch flip {
+			case "h":
+				dx = dw - 1 - dx
+			case "v":
+				dy = dh - 1 - dy
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: JPEGQuality}); err != nil {
 		return nil, fmt.Errorf("encoding JPEG: %w", err)
 	}
 
@@ -66,6 +197,27 @@ func Process(r io.Reader) (*ProcessResult, error) {
 	}, nil
 }
 
+// detectMarkup returns ErrSVG or ErrHTML if data is an SVG or HTML document.
+// Both are text starting with '<' (after an optional BOM and whitespace), so
+// binary images never match.
+func detectMarkup(data []byte) error {
+	text := bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	text = bytes.TrimLeft(text, " \t\r\n\f")
+	if len(text) == 0 || text[0] != '<' {
+		return nil
+	}
+
+	lower := strings.ToLower(string(text))
+	if strings.Contains(lower, "<svg") {
+		return ErrSVG
+	}
+	if strings.HasPrefix(http.DetectContentType(text), "text/html") ||
+		strings.Contains(lower, "<html") || strings.Contains(lower, "<script") {
+		return ErrHTML
+	}
+	return nil
+}
+
 // downscale resizes the image so neither dimension exceeds maxDim.
 // Uses high-quality Catmull-Rom interpolation.
 // Returns the original image if already within bounds.
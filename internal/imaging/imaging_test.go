@@ -2,6 +2,7 @@ package imaging
 
 import (
 	"bytes"
+	"errors"
 	"image"
 	"image/color"
 	"image/jpeg"
@@ -58,6 +59,40 @@ func TestProcessPNG(t *testing.T) {
 	}
 }
 
+func TestProcessQuality(t *testing.T) {
+	// A gradient, so the quality setting has detail to keep or discard.
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 200; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x * y), 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+
+	def, err := Process(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	high, err := ProcessQuality(bytes.NewReader(buf.Bytes()), 98)
+	if err != nil {
+		t.Fatalf("ProcessQuality: %v", err)
+	}
+	if len(high.Data) <= len(def.Data) {
+		t.Errorf("expected quality 98 (%d bytes) to be larger than the default (%d bytes)",
+			len(high.Data), len(def.Data))
+	}
+
+	for in, want := range map[string]int{"": JPEGQuality, "95": 95, "10": MinJPEGQuality, "150": MaxJPEGQuality} {
+		if got, err := ParseQuality(in); err != nil || got != want {
+			t.Errorf("ParseQuality(%q) = %d, %v; expected %d", in, got, err, want)
+		}
+	}
+	if _, err := ParseQuality("high"); err == nil {
+		t.Error("expected error for a non-integer quality")
+	}
+}
+
 func TestProcessDownscale(t *testing.T) {
 	// Create a 2048x2048 image.
 	data := createTestJPEG(2048, 2048)
@@ -108,3 +143,92 @@ func TestProcessGIFRejected(t *testing.T) {
 		t.Error("expected error for GIF")
 	}
 }
+
+func TestProcessSVGRejected(t *testing.T) {
+	payloads := []string{
+		`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`,
+		"\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- logo -->\n<SVG width=\"10\" height=\"10\"></SVG>",
+	}
+	for _, p := range payloads {
+		_, err := Process(bytes.NewReader([]byte(p)))
+		if !errors.Is(err, ErrSVG) {
+			t.Errorf("Process(%q) = %v, want ErrSVG", p, err)
+		}
+	}
+}
+
+func TestProcessHTMLRejected(t *testing.T) {
+	payloads := []string{
+		"<!DOCTYPE html><html><body><img src=x onerror=alert(1)></body></html>",
+		"  <script>alert(1)</script>",
+	}
+	for _, p := range payloads {
+		_, err := Process(bytes.NewReader([]byte(p)))
+		if !errors.Is(err, ErrHTML) {
+			t.Errorf("Process(%q) = %v, want ErrHTML", p, err)
+		}
+	}
+}
+
+func TestTransformRotate90SwapsDimensions(t *testing.T) {
+	// Left half red, right half blue: after a clockwise quarter turn the red
+	// half ends up on top.
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 100; y++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 100 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, src)
+
+	result, err := Transform(buf.Bytes(), 90, "")
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("decoding result: %v", err)
+	}
+	if img.Bounds().Dx() != 100 || img.Bounds().Dy() != 200 {
+		t.Errorf("expected 100x200 after rotating 200x100, got %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	if r, _, b, _ := img.At(50, 20).RGBA(); r < b {
+		t.Errorf("expected red on top after rotation, got r=%d b=%d", r>>8, b>>8)
+	}
+
+	result, _ = Transform(createTestJPEG(200, 100), 180, "h")
+	img, _, _ = image.Decode(bytes.NewReader(result.Data))
+	if img.Bounds().Dx() != 200 || img.Bounds().Dy() != 100 {
+		t.Errorf("expected 200x100 after rotating 180, got %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+}
+
+func TestTransformInvalid(t *testing.T) {
+	data := createTestJPEG(10, 10)
+	if _, err := Transform(data, 45, ""); err == nil {
+		t.Error("expected error for rotate 45")
+	}
+	if _, err := Transform(data, 0, "x"); err == nil {
+		t.Error("expected error for flip 'x'")
+	}
+}
+
+func TestConforms(t *testing.T) {
+	if !Conforms(createTestJPEG(100, 50)) {
+		t.Error("expected small JPEG to conform")
+	}
+	if Conforms(createTestJPEG(MaxDimension+1, 10)) {
+		t.Error("expected oversized JPEG not to conform")
+	}
+	if Conforms(createTestPNG(100, 50)) {
+		t.Error("expected PNG not to conform")
+	}
+	if Conforms([]byte("not an image")) {
+		t.Error("expected garbage not to conform")
+	}
+}
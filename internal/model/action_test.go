@@ -0,0 +1,42 @@
+package model
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role, action string
+		want         bool
+	}{
+		{RoleUser, ActionCreateTransfer, true},
+		{RoleUser, ActionCreateItem, false},
+		{RoleUser, ActionManageStock, false},
+		{RoleUser, ActionManageTemplates, false},
+		{RoleUser, ActionManageUsers, false},
+		{RoleManager, ActionCreateTransfer, true},
+		{RoleManager, ActionEditItem, true},
+		{RoleManager, ActionDeleteOwner, true},
+		{RoleManager, ActionManageStock, true},
+		{RoleManager, ActionManageTemplates, true},
+		{RoleManager, ActionManageUsers, false},
+		{RoleManager, ActionExportAudit, false},
+		{RoleAdmin, ActionCreateItem, true},
+		{RoleAdmin, ActionManageUsers, true},
+		{RoleAdmin, ActionAdminister, true},
+		{RoleAdmin, "launch_rocket", false},
+		{"superuser", ActionCreateTransfer, false},
+	}
+
+	for _, tt := range tests {
+		if got := Can(tt.role, tt.action); got != tt.want {
+			t.Errorf("Can(%q, %q) = %v, want %v", tt.role, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestActionRolesAreKnown(t *testing.T) {
+	for action, role := range actionRoles {
+		if !ValidRole(role) {
+			t.Errorf("action %q requires unknown role %q", action, role)
+		}
+	}
+}
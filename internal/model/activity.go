@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Activity event types.
+const (
+	ActivityItemCreated  = "item_created"
+	ActivityItemUpdated  = "item_updated"
+	ActivityOwnerCreated = "owner_created"
+	ActivityTransfer     = "transfer"
+)
+
+// ActivityEvent is one entry in the global recent-activity feed.
+type ActivityEvent struct {
+	Type string    `json:"type"`
+	At   time.Time `json:"at"`
+
+	// ID and Name identify the item or owner (item_* and owner_* events).
+	ID   int64  `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+
+	// Transfer is set for transfer events.
+	Transfer *Transfer `json:"transfer,omitempty"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Inventory adjustment kinds.
+const (
+	AdjustmentDecommissioned = "decommissioned"
+	AdjustmentStockAdded     = "stock_added"
+	AdjustmentStockSet       = "stock_set"
+)
+
+// Adjustment is a ledger entry for inventory added to (positive Delta) or
+// removed from (negative Delta) an owner outside of a transfer.
+type Adjustment struct {
+	ID        int64     `json:"id"`
+	ItemID    int64     `json:"item_id"`
+	OwnerID   int64     `json:"owner_id"`
+	OwnerName string    `json:"owner_name,omitempty"`
+	Delta     int       `json:"delta"`
+	Kind      string    `json:"kind"`
+	Reason    string    `json:"reason,omitempty"`
+	UserID    *int64    `json:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Divisible is set for divisible items, whose Delta is in thousandths.
+	Divisible bool `json:"divisible,omitempty"`
+}
+
+// MarshalJSON renders Delta in whole units.
+func (a Adjustment) MarshalJSON() ([]byte, error) {
+	type plain Adjustment
+	return json.Marshal(struct {
+		plain
+		Delta json.Number `json:"delta"`
+	}{plain(a), QuantityNumber(a.Delta, a.Divisible)})
+}
+
+// DecommissionResult is the outcome of decommissioning an item: the item,
+// now removed, and one adjustment per owner whose stock was zeroed.
+type DecommissionResult struct {
+	Item        *Item        `json:"item"`
+	Adjustments []Adjustment `json:"adjustments"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Audit actions.
+const (
+	AuditTransfer       = "transfer"
+	AuditStatusChanged  = "status_changed"
+	AuditItemDeleted    = "item_deleted"
+	AuditOwnerDeleted   = "owner_deleted"
+	AuditDecommissioned = "decommissioned"
+	AuditStockAdded     = "stock_added"
+	AuditStockSet       = "stock_set"
+)
+
+// AuditEntry is one attributable change: a transfer, an item status change,
+// stock removed by decommissioning, added in a batch or set to a count, or a
+// soft deletion, with the user who made it.
+type AuditEntry struct {
+	At       time.Time `json:"at"`
+	Action   string    `json:"action"`
+	RefID    int64     `json:"ref_id"` // transfer, status change, adjustment, item or owner ID
+	UserID   *int64    `json:"user_id,omitempty"`
+	Username string    `json:"username,omitempty"`
+
+	// Subject is the item or owner the action applies to.
+	SubjectType string `json:"subject_type"`
+	SubjectID   int64  `json:"subject_id"`
+	SubjectName string `json:"subject_name"`
+
+	// Details summarises the change (e.g. "3 from Storage to Bob",
+	// "active -> lost", "3 removed from Storage"); Reason is the notes or
+	// reason given with it.
+	Details string `json:"details,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// AuditFilter narrows an audit listing. Zero values mean no bound.
+type AuditFilter struct {
+	From   time.Time // inclusive
+	To     time.Time // exclusive
+	UserID int64
+}
@@ -0,0 +1,38 @@
+package model
+
+// BulkResult summarises a batch request. Every batch endpoint embeds it in
+// its response, so clients can check the counts without walking the
+// per-entry results. Failed is always Requested minus Succeeded; in an
+// all-or-nothing batch one bad entry rejects every entry, so Failed can
+// exceed len(Errors).
+type BulkResult struct {
+	Requested int         `json:"requested"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+	Errors    []BulkError `json:"errors"`
+}
+
+// BulkError is one rejected entry of a batch. Index is zero-based into the
+// submitted entries.
+type BulkError struct {
+	Index   int    `json:"index"`
+	Message string `json:"message"`
+}
+
+// NewBulkResult returns an empty summary with a non-nil Errors slice.
+func NewBulkResult() BulkResult {
+	return BulkResult{Errors: []BulkError{}}
+}
+
+// Succeed counts one applied entry.
+func (r *BulkResult) Succeed() {
+	r.Requested++
+	r.Succeeded++
+}
+
+// Fail counts one rejected entry and records why.
+func (r *BulkResult) Fail(index int, message string) {
+	r.Requested++
+	r.Failed++
+	r.Errors = append(r.Errors, BulkError{Index: index, Message: message})
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxDocumentSize is the maximum size in bytes of an uploaded document.
+const MaxDocumentSize = 5 << 20
+
+// MaxFilenameLength is the maximum length of a document's filename, in
+// characters.
+const MaxFilenameLength = 255
+
+// Document is a file, such as a receipt or a manual, attached to an item.
+// Data is only loaded for downloads.
+type Document struct {
+	ID         int64     `json:"id"`
+	ItemID     int64     `json:"item_id"`
+	Filename   string    `json:"filename"`
+	MIME       string    `json:"mime"`
+	Size       int64     `json:"size"`
+	UploadedBy *int64    `json:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	Data       []byte    `json:"-"`
+}
+
+// zipDocumentMIME maps the extensions of the accepted ZIP-based office
+// formats to their MIME types. The bytes only tell that a file is a ZIP
+// archive, so the extension decides which format it is.
+var zipDocumentMIME = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+}
+
+// DetectDocumentMIME sniffs data and returns its MIME type if it is an
+// accepted document type: PDF, JPEG or PNG, plain text that is not markup,
+// or one of the ZIP-based office formats named by filename's extension.
+// Client-supplied content types are never trusted.
+func DetectDocumentMIME(filename string, data []byte) (string, error) {
+	detected := http.DetectContentType(data)
+	switch {
+	case detected == "application/pdf", detected == "image/jpeg", detected == "image/png":
+		return detected, nil
+	case strings.HasPrefix(detected, "text/plain"):
+		// SVG and other markup can sniff as plain text, so reject any text
+		// that opens with a tag.
+		text := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n\f")
+		if len(text) > 0 && text[0] == '<' {
+			return "", fmt.Errorf("markup files are not accepted")
+		}
+		return "text/plain; charset=utf-8", nil
+	case detected == "application/zip":
+		if mime, ok := zipDocumentMIME[strings.ToLower(filepath.Ext(filename))]; ok {
+			return mime, nil
+		}
+		return "", fmt.Errorf("unsupported archive: only .docx, .xlsx, .pptx, .odt and .ods are accepted")
+	}
+	return "", fmt.Errorf("unsupported document type: %s (accepted: PDF, JPEG, PNG, plain text, office documents)", detected)
+}
+
+// NormalizeFilename reduces an uploaded filename to its base name without
+// control characters, trimmed and capped at MaxFilenameLength characters.
+// An empty result becomes "document".
+func NormalizeFilename(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = strings.TrimSpace(filepath.Base(name))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if utf8.RuneCountInString(name) > MaxFilenameLength {
+		name = string([]rune(name)[:MaxFilenameLength])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "document"
+	}
+	return name
+}
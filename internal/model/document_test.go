@@ -0,0 +1,48 @@
+package model
+
+import "testing"
+
+func TestDetectDocumentMIME(t *testing.T) {
+	accepted := []struct {
+		filename string
+		data     string
+		want     string
+	}{
+		{"receipt.pdf", "%PDF-1.7\n", "application/pdf"},
+		{"scan.jpg", "\xff\xd8\xff\xe0\x00\x10JFIF\x00", "image/jpeg"},
+		{"notes.txt", "plain notes\n", "text/plain; charset=utf-8"},
+		{"Manual.DOCX", "PK\x03\x04\x14\x00", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	}
+	for _, c := range accepted {
+		got, err := DetectDocumentMIME(c.filename, []byte(c.data))
+		if err != nil || got != c.want {
+			t.Errorf("%s: got %q, %v; want %q", c.filename, got, err, c.want)
+		}
+	}
+
+	for name, data := range map[string]string{
+		"page.html":   "<html><body>hi</body></html>",
+		"archive.zip": "PK\x03\x04\x14\x00",
+		"image.svg":   `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+		"tool.bin":    "\x00\x01\x02\x03",
+	} {
+		if got, err := DetectDocumentMIME(name, []byte(data)); err == nil {
+			t.Errorf("%s: expected rejection, got %q", name, got)
+		}
+	}
+}
+
+func TestNormalizeFilename(t *testing.T) {
+	for in, want := range map[string]string{
+		"receipt.pdf":           "receipt.pdf",
+		"../../etc/passwd":      "passwd",
+		`C:\Users\ana\scan.pdf`: "scan.pdf",
+		"  bad\x00name\n.txt ":  "badname.txt",
+		"":                      "document",
+		"/":                     "document",
+	} {
+		if got := NormalizeFilename(in); got != want {
+			t.Errorf("NormalizeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
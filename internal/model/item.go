@@ -1,17 +1,113 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
-// Item represents an item type (quantity-based, not individual tracking).
+// Item represents an item type. Items are quantity-based unless Serialized,
+// in which case each unit is tracked as a Serial. Quantities of a Divisible
+// item may be fractional and are stored in thousandths (see QuantityScale).
 type Item struct {
-	ID          int64      `json:"id"`
-	Name        string     `json:"name"`
-	Description string     `json:"description,omitempty"`
-	ImageMime   string     `json:"image_mime,omitempty"`
-	Status      string     `json:"status"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Condition   string `json:"condition,omitempty"`
+	MinQuantity *int   `json:"min_quantity,omitempty"`
+	// ReorderTarget is the total to restock up to once the item is below
+	// MinQuantity (see ReorderLine).
+	ReorderTarget *int       `json:"reorder_target,omitempty"`
+	Serialized    bool       `json:"serialized"`
+	Divisible     bool       `json:"divisible"`
+	ImageMime     string     `json:"image_mime,omitempty"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+
+	// DeletedBy and DeleteReason are set together with DeletedAt.
+	DeletedBy    *int64 `json:"deleted_by,omitempty"`
+	DeleteReason string `json:"delete_reason,omitempty"`
+}
+
+// ItemSuggestion is the compact form of an item offered by pickers.
+type ItemSuggestion struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	HasImage bool   `json:"has_image"`
+}
+
+// ItemFilter narrows an item listing. Zero values mean no filter.
+type ItemFilter struct {
+	Status   string
+	HasImage *bool // only items with (true) or without (false) an image
+}
+
+// MarshalJSON renders MinQuantity and ReorderTarget in whole units.
+func (i Item) MarshalJSON() ([]byte, error) {
+	type plain Item
+	out := struct {
+		plain
+		MinQuantity   *json.Number `json:"min_quantity,omitempty"`
+		ReorderTarget *json.Number `json:"reorder_target,omitempty"`
+	}{plain: plain(i)}
+	if i.MinQuantity != nil {
+		n := QuantityNumber(*i.MinQuantity, i.Divisible)
+		out.MinQuantity = &n
+	}
+	if i.ReorderTarget != nil {
+		n := QuantityNumber(*i.ReorderTarget, i.Divisible)
+		out.ReorderTarget = &n
+	}
+	return json.Marshal(out)
+}
+
+// StockLevel is an item's total quantity across all owners compared with its
+// low-stock threshold, as seen by the low-stock scanner.
+type StockLevel struct {
+	ItemID      int64  `json:"item_id"`
+	ItemName    string `json:"item_name"`
+	Quantity    int    `json:"quantity"`
+	MinQuantity int    `json:"min_quantity"`
+	Divisible   bool   `json:"divisible,omitempty"` // quantities in thousandths (see QuantityScale)
+
+	// Alerted is set while an alert for the current low-stock spell has been
+	// sent.
+	Alerted bool `json:"-"`
+}
+
+// Low reports whether the total quantity is below the threshold. Items
+// without a threshold (MinQuantity 0) are never low.
+func (s StockLevel) Low() bool {
+	return s.Quantity < s.MinQuantity
+}
+
+// ReorderLine is an item below its low-stock threshold with the quantity to
+// order to bring its total back up to its reorder target.
+type ReorderLine struct {
+	ItemID        int64  `json:"item_id"`
+	ItemName      string `json:"item_name"`
+	Quantity      int    `json:"quantity"`
+	MinQuantity   int    `json:"min_quantity"`
+	ReorderTarget int    `json:"reorder_target"`
+	Suggested     int    `json:"suggested"`
+	Divisible     bool   `json:"divisible,omitempty"` // quantities in thousandths (see QuantityScale)
+}
+
+// MarshalJSON renders the quantities in whole units.
+func (l ReorderLine) MarshalJSON() ([]byte, error) {
+	type plain ReorderLine
+	return json.Marshal(struct {
+		plain
+		Quantity      json.Number `json:"quantity"`
+		MinQuantity   json.Number `json:"min_quantity"`
+		ReorderTarget json.Number `json:"reorder_target"`
+		Suggested     json.Number `json:"suggested"`
+	}{plain(l),
+		QuantityNumber(l.Quantity, l.Divisible),
+		QuantityNumber(l.MinQuantity, l.Divisible),
+		QuantityNumber(l.ReorderTarget, l.Divisible),
+		QuantityNumber(l.Suggested, l.Divisible)})
 }
 
 // Item statuses.
@@ -21,3 +117,51 @@ const (
 	ItemStatusLost    = "lost"
 	ItemStatusRemoved = "removed"
 )
+
+// StatusChange records a change of an item's status.
+type StatusChange struct {
+	ID         int64     `json:"id"`
+	ItemID     int64     `json:"item_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason,omitempty"`
+	UserID     *int64    `json:"user_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	// Joined fields (not always populated).
+	Username string `json:"username,omitempty"`
+}
+
+// Item changelog entry types.
+const (
+	ChangelogCreated        = "created"
+	ChangelogStatusChanged  = "status_changed"
+	ChangelogTransfer       = "transfer"
+	ChangelogDeleted        = "deleted"
+	ChangelogDecommissioned = "decommissioned"
+)
+
+// FieldChange is the old and new value of a changed field.
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ItemChangelogEntry is one event in an item's merged audit timeline.
+type ItemChangelogEntry struct {
+	Type     string    `json:"type"`
+	At       time.Time `json:"at"`
+	UserID   *int64    `json:"user_id,omitempty"`
+	Username string    `json:"username,omitempty"`
+
+	// Changes maps field names to their old and new values (status_changed).
+	Changes map[string]FieldChange `json:"changes,omitempty"`
+	Reason  string                 `json:"reason,omitempty"`
+
+	// Transfer is set for transfer entries.
+	Transfer *Transfer `json:"transfer,omitempty"`
+
+	// Adjustment is set for decommissioned entries, one per owner whose
+	// stock was removed.
+	Adjustment *Adjustment `json:"adjustment,omitempty"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// LoginEvent is one login attempt through the API or the web UI.
+type LoginEvent struct {
+	ID int64 `json:"id"`
+	// UserID is nil when the username did not match an account.
+	UserID    *int64    `json:"user_id,omitempty"`
+	Username  string    `json:"username"`
+	Success   bool      `json:"success"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginHotspot counts the failed logins from one IP address.
+type LoginHotspot struct {
+	IP        string    `json:"ip"`
+	Failures  int       `json:"failures"`
+	Usernames int       `json:"usernames"` // distinct usernames tried
+	LastAt    time.Time `json:"last_at"`
+}
+
+// LoginActivity is the body of GET /api/admin/logins: recent login events,
+// newest first, and the addresses with the most failures in the same range.
+type LoginActivity struct {
+	Events         []LoginEvent   `json:"events"`
+	FailedHotspots []LoginHotspot `json:"failed_hotspots"`
+}
+
+// LoginFilter narrows a login event listing. Zero values mean no bound.
+type LoginFilter struct {
+	From   time.Time // inclusive
+	To     time.Time // exclusive
+	UserID int64
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxNameLength is the maximum length of item and owner names, in characters.
+const MaxNameLength = 100
+
+// NormalizeName trims a name and collapses runs of internal whitespace into a
+// single space. It fails if nothing is left or the result is longer than
+// MaxNameLength.
+func NormalizeName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return "", fmt.Errorf("name required")
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return "", fmt.Errorf("name must not exceed %d characters", MaxNameLength)
+	}
+	return name, nil
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"Laptop", "Laptop", false},
+		{"  Laptop  ", "Laptop", false},
+		{"Dell \t Latitude\n 5420", "Dell Latitude 5420", false},
+		{"Škatla", "Škatla", false},
+		{"", "", true},
+		{" \t\n ", "", true},
+		{strings.Repeat("č", MaxNameLength), strings.Repeat("č", MaxNameLength), false},
+		{strings.Repeat("a", MaxNameLength+1), "", true},
+		{"  " + strings.Repeat("a", MaxNameLength) + "  ", strings.Repeat("a", MaxNameLength), false},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeName(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
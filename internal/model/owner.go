@@ -9,6 +9,13 @@ type Owner struct {
 	Type      string     `json:"type"`
 	CreatedAt time.Time  `json:"created_at"`
 	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// DeletedBy and DeleteReason are set together with DeletedAt.
+	DeletedBy    *int64 `json:"deleted_by,omitempty"`
+	DeleteReason string `json:"delete_reason,omitempty"`
+
+	// UserID is the user account a person owner stands for, if linked.
+	UserID *int64 `json:"user_id,omitempty"`
 }
 
 // Owner types.
@@ -16,3 +23,13 @@ const (
 	OwnerTypePerson   = "person"
 	OwnerTypeLocation = "location"
 )
+
+// OwnerSummary aggregates what an owner holds.
+type OwnerSummary struct {
+	OwnerID int64 `json:"owner_id"`
+	Items   int   `json:"items"` // distinct items held
+	Units   int   `json:"units"` // total quantity held, divisible items in whole units
+
+	// UnitsByStatus maps every item status to the units held of items in it.
+	UnitsByStatus map[string]int `json:"units_by_status"`
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QuantityScale is the number of stored units per whole unit of a divisible
+// item. Quantities of divisible items (e.g. measured in kg or liters) are
+// kept as integer thousandths, so 1.5 kg is stored as 1500 and the same
+// integer arithmetic keeps them exact; other items store whole units.
+const QuantityScale = 1000
+
+// quantityDecimals is the number of decimal places QuantityScale allows.
+const quantityDecimals = 3
+
+// maxQuantityDigits bounds the integer part of a parsed quantity, well
+// below where scaling could overflow.
+const maxQuantityDigits = 12
+
+// ErrWholeQuantity is returned for a fractional quantity of an item that is
+// not divisible.
+var ErrWholeQuantity = errors.New("quantity must be a whole number (item is not divisible)")
+
+// ParseQuantity converts a quantity as entered, such as "2", "-3" or "1.25",
+// to stored units. Items that are not divisible take whole numbers only
+// ("2.0" is accepted); divisible items take at most three decimal places.
+// An empty string is 0, leaving range checks to the caller.
+func ParseQuantity(s string, divisible bool) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	neg := strings.HasPrefix(s, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	if whole == "" || len(whole) > maxQuantityDigits || !digits(whole) || !digits(frac) {
+		return 0, fmt.Errorf("invalid quantity: %q", s)
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > 0 && !divisible {
+		return 0, ErrWholeQuantity
+	}
+	if len(frac) > quantityDecimals {
+		return 0, fmt.Errorf("quantity must have at most %d decimal places", quantityDecimals)
+	}
+
+	q, _ := strconv.Atoi(whole)
+	if divisible {
+		f, _ := strconv.Atoi(frac + strings.Repeat("0", quantityDecimals-len(frac)))
+		q = q*QuantityScale + f
+	}
+	if neg {
+		q = -q
+	}
+	return q, nil
+}
+
+// FormatQuantity renders a stored quantity in whole units: unchanged for
+// items that are not divisible, and as a decimal without trailing zeros
+// ("1.5", "2") for divisible ones.
+func FormatQuantity(q int, divisible bool) string {
+	if !divisible {
+		return strconv.Itoa(q)
+	}
+	sign := ""
+	if q < 0 {
+		sign, q = "-", -q
+	}
+	s := sign + strconv.Itoa(q/QuantityScale)
+	if frac := q % QuantityScale; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%03d", frac), "0")
+	}
+	return s
+}
+
+// QuantityNumber is FormatQuantity as a JSON number.
+func QuantityNumber(q int, divisible bool) json.Number {
+	return json.Number(FormatQuantity(q, divisible))
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
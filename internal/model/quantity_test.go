@@ -0,0 +1,61 @@
+package model
+
+import "testing"
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		s         string
+		divisible bool
+		want      int
+		wantErr   bool
+	}{
+		{"", false, 0, false},
+		{"3", false, 3, false},
+		{"-3", false, -3, false},
+		{"2.0", false, 2, false},
+		{"2.5", false, 0, true},
+		{"3", true, 3000, false},
+		{"1.25", true, 1250, false},
+		{"-0.5", true, -500, false},
+		{"0.001", true, 1, false},
+		{"0.0001", true, 0, true},
+		{"1.2500", true, 1250, false},
+		{"1e3", true, 0, true},
+		{".5", true, 0, true},
+		{"1.", true, 1000, false},
+		{"abc", false, 0, true},
+		{"1234567890123", false, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseQuantity(tt.s, tt.divisible)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseQuantity(%q, %v) error = %v, wantErr %v", tt.s, tt.divisible, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseQuantity(%q, %v) = %d, want %d", tt.s, tt.divisible, got, tt.want)
+		}
+	}
+}
+
+func TestFormatQuantity(t *testing.T) {
+	tests := []struct {
+		q         int
+		divisible bool
+		want      string
+	}{
+		{1500, false, "1500"},
+		{1500, true, "1.5"},
+		{2000, true, "2"},
+		{1, true, "0.001"},
+		{-250, true, "-0.25"},
+		{0, true, "0"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatQuantity(tt.q, tt.divisible); got != tt.want {
+			t.Errorf("FormatQuantity(%d, %v) = %q, want %q", tt.q, tt.divisible, got, tt.want)
+		}
+	}
+}
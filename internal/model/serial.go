@@ -0,0 +1,47 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxSerialLength is the maximum length of a serial number, in characters.
+const MaxSerialLength = 100
+
+// Serial is one individually tracked unit of a serialized item and the owner
+// currently holding it.
+type Serial struct {
+	ItemID    int64     `json:"item_id"`
+	Serial    string    `json:"serial"`
+	OwnerID   int64     `json:"owner_id"`
+	OwnerName string    `json:"owner_name,omitempty"`
+	OwnerType string    `json:"owner_type,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NormalizeSerials trims each serial number and checks that none is blank,
+// longer than MaxSerialLength or repeated. It fails if serials is empty.
+func NormalizeSerials(serials []string) ([]string, error) {
+	if len(serials) == 0 {
+		return nil, fmt.Errorf("at least one serial required")
+	}
+	out := make([]string, 0, len(serials))
+	seen := make(map[string]bool, len(serials))
+	for _, s := range serials {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil, fmt.Errorf("serial must not be blank")
+		}
+		if utf8.RuneCountInString(s) > MaxSerialLength {
+			return nil, fmt.Errorf("serial must not exceed %d characters", MaxSerialLength)
+		}
+		if seen[s] {
+			return nil, fmt.Errorf("serial %q listed twice", s)
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,11 @@
+package model
+
+// DefaultCSP is the Content-Security-Policy used unless overridden. The web UI
+// relies on inline scripts/styles and htmx hx-on handlers (compiled with
+// Function), hence 'unsafe-inline' and 'unsafe-eval'.
+const DefaultCSP = "default-src 'self'; script-src 'self' 'unsafe-inline' 'unsafe-eval'; " +
+	"style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'"
+
+// DefaultMaxPageSize is the largest page a paginated endpoint returns unless
+// a smaller or larger cap is configured.
+const DefaultMaxPageSize = 200
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StockSnapshot is a copy of every inventory balance at one moment, taken on
+// request so later balances can be compared with it.
+type StockSnapshot struct {
+	ID      int64     `json:"id"`
+	TakenAt time.Time `json:"taken_at"`
+	TakenBy *int64    `json:"taken_by,omitempty"`
+	Notes   string    `json:"notes,omitempty"`
+	Lines   int       `json:"lines"` // item/owner balances captured
+}
+
+// Kinds of SnapshotChange.
+const (
+	SnapshotAdded   = "added"   // held only at the later point
+	SnapshotRemoved = "removed" // held only at the earlier point
+	SnapshotChanged = "changed" // held at both, in a different quantity
+)
+
+// SnapshotChange is an item/owner balance that differs between two points.
+type SnapshotChange struct {
+	ItemID       int64  `json:"item_id"`
+	OwnerID      int64  `json:"owner_id"`
+	ItemName     string `json:"item_name"`
+	OwnerName    string `json:"owner_name"`
+	OwnerType    string `json:"owner_type"`
+	Change       string `json:"change"`
+	FromQuantity int    `json:"from_quantity"`
+	ToQuantity   int    `json:"to_quantity"`
+	Delta        int    `json:"delta"`
+
+	// Divisible is set for divisible items; see Transfer.
+	Divisible bool `json:"divisible,omitempty"`
+}
+
+// MarshalJSON renders the quantities in whole units.
+func (c SnapshotChange) MarshalJSON() ([]byte, error) {
+	type plain SnapshotChange
+	return json.Marshal(struct {
+		plain
+		FromQuantity json.Number `json:"from_quantity"`
+		ToQuantity   json.Number `json:"to_quantity"`
+		Delta        json.Number `json:"delta"`
+	}{plain(c), QuantityNumber(c.FromQuantity, c.Divisible), QuantityNumber(c.ToQuantity, c.Divisible),
+		QuantityNumber(c.Delta, c.Divisible)})
+}
+
+// SnapshotDiff lists the balances that differ between a snapshot and a later
+// snapshot, or the current inventory if To is nil.
+type SnapshotDiff struct {
+	From    StockSnapshot    `json:"from"`
+	To      *StockSnapshot   `json:"to"`
+	Changes []SnapshotChange `json:"changes"`
+}
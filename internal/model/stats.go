@@ -0,0 +1,96 @@
+package model
+
+import "time"
+
+// Stats summarizes the current inventory and transfer activity in a period.
+type Stats struct {
+	// Current state, as of the request.
+	Items         int `json:"items"`
+	People        int `json:"people"`
+	Locations     int `json:"locations"`
+	TotalQuantity int `json:"total_quantity"` // divisible items in whole units, rounded down
+
+	Activity StatsActivity `json:"activity"`
+}
+
+// StatsActivity covers transfers and status changes in [From, To).
+type StatsActivity struct {
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Transfers     int       `json:"transfers"`
+	QuantityMoved int       `json:"quantity_moved"` // like Stats.TotalQuantity
+	ItemsMoved    int       `json:"items_moved"`    // distinct item types transferred
+	StatusChanges int       `json:"status_changes"`
+}
+
+// Sources of an InventoryTrendPoint value.
+const (
+	TrendSourceLive     = "live"     // today, as of the request
+	TrendSourceSnapshot = "snapshot" // recorded that day
+	TrendSourceCarried  = "carried"  // no snapshot that day: the previous day's value
+	TrendSourceMissing  = "missing"  // before the first snapshot: unknown
+)
+
+// InventoryTrendPoint is the total inventory at the end of one day, in whole
+// units like Stats.TotalQuantity. Units is nil for a missing day.
+type InventoryTrendPoint struct {
+	Date     string         `json:"date"` // YYYY-MM-DD, UTC
+	Units    *int           `json:"units"`
+	ByStatus map[string]int `json:"by_status,omitempty"`
+	Source   string         `json:"source"`
+}
+
+// DBStats reports SQLite storage figures for capacity planning.
+type DBStats struct {
+	PageCount     int64  `json:"page_count"`
+	PageSize      int64  `json:"page_size"`
+	FreelistCount int64  `json:"freelist_count"`
+	JournalMode   string `json:"journal_mode"`
+	Path          string `json:"path,omitempty"`
+	FileSize      int64  `json:"file_size"` // bytes on disk, 0 for in-memory databases
+	WALSize       int64  `json:"wal_size"`  // bytes in the -wal file, 0 if absent
+
+	// Result of a passive PRAGMA wal_checkpoint (-1 frames outside WAL mode).
+	WALCheckpoint WALCheckpoint `json:"wal_checkpoint"`
+}
+
+// WALCheckpoint is the result row of PRAGMA wal_checkpoint.
+type WALCheckpoint struct {
+	Busy         bool  `json:"busy"`
+	LogFrames    int64 `json:"log_frames"`
+	Checkpointed int64 `json:"checkpointed_frames"`
+}
+
+// ReprocessStatus reports the progress of a bulk image reprocessing run.
+type ReprocessStatus struct {
+	Running    bool       `json:"running"`
+	Total      int        `json:"total"`     // images to look at
+	Processed  int        `json:"processed"` // looked at so far: updated + skipped + failed
+	Updated    int        `json:"updated"`
+	Skipped    int        `json:"skipped"` // already conforming, or changed meanwhile
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Canceled   bool       `json:"canceled,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// MigrationStatus reports which schema migrations the database has applied.
+type MigrationStatus struct {
+	Version    int         `json:"version"` // PRAGMA user_version
+	Latest     int         `json:"latest"`  // newest migration this binary knows
+	Pending    []int       `json:"pending"`
+	Migrations []Migration `json:"migrations"`
+}
+
+// Migration is one schema migration, known to this binary, recorded in the
+// database, or both.
+type Migration struct {
+	Version   int        `json:"version"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"` // unset if applied before it was recorded
+	Checksum  string     `json:"checksum"`             // of the migration as this binary has it
+	// Modified is set when the recorded checksum differs from Checksum,
+	// meaning the migration was edited after this database applied it.
+	Modified bool `json:"modified,omitempty"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxStatusLength is the maximum length of an item status name.
+const MaxStatusLength = 32
+
+// ItemStatus is a status items can be set to. The four built-in statuses are
+// always present; admins may add custom ones such as "in_repair".
+type ItemStatus struct {
+	Name      string    `json:"name"`
+	Builtin   bool      `json:"builtin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BuiltinStatus reports whether name is one of the built-in item statuses.
+func BuiltinStatus(name string) bool {
+	switch name {
+	case ItemStatusActive, ItemStatusDamaged, ItemStatusLost, ItemStatusRemoved:
+		return true
+	}
+	return false
+}
+
+// NormalizeStatusName trims and lower-cases a new status name. Names are used
+// as identifiers in URLs, filters and message IDs, so only lower-case ASCII
+// letters, digits and underscores are allowed, starting with a letter.
+func NormalizeStatusName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("name required")
+	}
+	if len(name) > MaxStatusLength {
+		return "", fmt.Errorf("name must not exceed %d characters", MaxStatusLength)
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '_'):
+		default:
+			return "", fmt.Errorf("name must start with a letter and contain only letters, digits and underscores")
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeStatusName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"in_repair", "in_repair", false},
+		{"  In_Repair ", "in_repair", false},
+		{"loaned2", "loaned2", false},
+		{"", "", true},
+		{"2nd_hand", "", true},
+		{"_hidden", "", true},
+		{"in repair", "", true},
+		{"v-popravilu", "", true},
+		{"popravljën", "", true},
+		{strings.Repeat("a", MaxStatusLength), strings.Repeat("a", MaxStatusLength), false},
+		{strings.Repeat("a", MaxStatusLength+1), "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeStatusName(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeStatusName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeStatusName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Maximum lengths of free-text fields, in characters.
+const (
+	MaxNotesLength       = 1000
+	MaxDescriptionLength = 10000
+	MaxConditionLength   = 200
+)
+
+// ValidateNotes checks that transfer or adjustment notes are at most
+// MaxNotesLength characters long.
+func ValidateNotes(notes string) error {
+	return checkLength("notes", notes, MaxNotesLength)
+}
+
+// ValidateDescription checks that an item description is at most
+// MaxDescriptionLength characters long.
+func ValidateDescription(description string) error {
+	return checkLength("description", description, MaxDescriptionLength)
+}
+
+// ValidateCondition checks that an item condition note is at most
+// MaxConditionLength characters long.
+func ValidateCondition(condition string) error {
+	return checkLength("condition", condition, MaxConditionLength)
+}
+
+func checkLength(field, s string, limit int) error {
+	if utf8.RuneCountInString(s) > limit {
+		return fmt.Errorf("%s must not exceed %d characters", field, limit)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTextLengths(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate func(string) error
+		limit    int
+	}{
+		{"notes", ValidateNotes, MaxNotesLength},
+		{"description", ValidateDescription, MaxDescriptionLength},
+		{"condition", ValidateCondition, MaxConditionLength},
+	}
+
+	for _, tt := range tests {
+		if err := tt.validate(""); err != nil {
+			t.Errorf("%s: empty value rejected: %v", tt.name, err)
+		}
+		if err := tt.validate(strings.Repeat("č", tt.limit)); err != nil {
+			t.Errorf("%s: value at limit rejected: %v", tt.name, err)
+		}
+		if err := tt.validate(strings.Repeat("a", tt.limit+1)); err == nil {
+			t.Errorf("%s: value over limit accepted", tt.name)
+		}
+	}
+}
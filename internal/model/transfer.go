@@ -1,32 +1,198 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Transfer represents an item movement between owners.
 type Transfer struct {
-	ID             int64     `json:"id"`
-	ItemID         int64     `json:"item_id"`
-	FromOwnerID    int64     `json:"from_owner_id"`
-	ToOwnerID      int64     `json:"to_owner_id"`
-	Quantity       int       `json:"quantity"`
-	Notes          string    `json:"notes,omitempty"`
-	TransferredAt  time.Time `json:"transferred_at"`
-	TransferredBy  *int64    `json:"transferred_by,omitempty"`
+	ID            int64     `json:"id"`
+	ItemID        int64     `json:"item_id"`
+	FromOwnerID   int64     `json:"from_owner_id"`
+	ToOwnerID     int64     `json:"to_owner_id"`
+	Quantity      int       `json:"quantity"`
+	Notes         string    `json:"notes,omitempty"`
+	TransferredAt time.Time `json:"transferred_at"`
+	TransferredBy *int64    `json:"transferred_by,omitempty"`
 
 	// Joined fields (not always populated).
 	ItemName      string `json:"item_name,omitempty"`
 	FromOwnerName string `json:"from_owner_name,omitempty"`
+	FromOwnerType string `json:"from_owner_type,omitempty"`
 	ToOwnerName   string `json:"to_owner_name,omitempty"`
+	ToOwnerType   string `json:"to_owner_type,omitempty"`
+
+	// Serials lists the units moved, for transfers of a serialized item
+	// (only populated when the transfer is created).
+	Serials []string `json:"serials,omitempty"`
+
+	// Warnings are non-blocking notes about a transfer that succeeded (only
+	// populated when the transfer is created).
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Divisible is set for transfers of a divisible item, whose Quantity is
+	// in thousandths (see QuantityScale) and is rendered as a decimal.
+	Divisible bool `json:"divisible,omitempty"`
+}
+
+// MarshalJSON renders Quantity in whole units.
+func (t Transfer) MarshalJSON() ([]byte, error) {
+	type plain Transfer
+	return json.Marshal(struct {
+		plain
+		Quantity json.Number `json:"quantity"`
+	}{plain(t), QuantityNumber(t.Quantity, t.Divisible)})
 }
 
 // Inventory represents the current quantity of an item held by an owner.
 type Inventory struct {
-	ItemID    int64  `json:"item_id"`
-	OwnerID   int64  `json:"owner_id"`
-	Quantity  int    `json:"quantity"`
+	ItemID   int64 `json:"item_id"`
+	OwnerID  int64 `json:"owner_id"`
+	Quantity int   `json:"quantity"`
 
 	// Joined fields (not always populated).
 	ItemName  string `json:"item_name,omitempty"`
 	OwnerName string `json:"owner_name,omitempty"`
 	OwnerType string `json:"owner_type,omitempty"`
+
+	// Divisible is set for divisible items; see Transfer.
+	Divisible bool `json:"divisible,omitempty"`
+}
+
+// MarshalJSON renders Quantity in whole units.
+func (i Inventory) MarshalJSON() ([]byte, error) {
+	type plain Inventory
+	return json.Marshal(struct {
+		plain
+		Quantity json.Number `json:"quantity"`
+	}{plain(i), QuantityNumber(i.Quantity, i.Divisible)})
+}
+
+// InventoryChange is an inventory row changed since a point in time, used for
+// incremental sync. Deleted rows are reported as tombstones with Quantity 0.
+type InventoryChange struct {
+	ItemID    int64     `json:"item_id"`
+	OwnerID   int64     `json:"owner_id"`
+	Quantity  int       `json:"quantity"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Deleted   bool      `json:"deleted"`
+	Divisible bool      `json:"divisible,omitempty"` // see Transfer
+}
+
+// MarshalJSON renders Quantity in whole units.
+func (c InventoryChange) MarshalJSON() ([]byte, error) {
+	type plain InventoryChange
+	return json.Marshal(struct {
+		plain
+		Quantity json.Number `json:"quantity"`
+	}{plain(c), QuantityNumber(c.Quantity, c.Divisible)})
+}
+
+// StockLine is one line of a batch stock addition. Divisible is the item's
+// divisible flag that Quantity was converted under (see ParseQuantity).
+type StockLine struct {
+	ItemID    int64 `json:"item_id"`
+	Quantity  int   `json:"quantity"`
+	Divisible bool  `json:"divisible,omitempty"`
+}
+
+// StockLineResult is the outcome of one applied StockLine.
+type StockLineResult struct {
+	ItemID    int64 `json:"item_id"`
+	Added     int   `json:"added"`
+	Quantity  int   `json:"quantity"`            // owner's quantity after the addition
+	Divisible bool  `json:"divisible,omitempty"` // see Transfer
+}
+
+// MarshalJSON renders Added and Quantity in whole units.
+func (r StockLineResult) MarshalJSON() ([]byte, error) {
+	type plain StockLineResult
+	return json.Marshal(struct {
+		plain
+		Added    json.Number `json:"added"`
+		Quantity json.Number `json:"quantity"`
+	}{plain(r), QuantityNumber(r.Added, r.Divisible), QuantityNumber(r.Quantity, r.Divisible)})
+}
+
+// StockImportRow is one data row of a CSV stock import. Item and Owner are
+// references as written in the file: an id, or otherwise an exact name.
+// Quantity is also as written, since whether it may have decimals depends
+// on the item.
+type StockImportRow struct {
+	Line     int // 1-based line in the file
+	Item     string
+	Owner    string
+	Quantity string
+}
+
+// StockImportResult is the outcome of one StockImportRow. ItemID and OwnerID
+// are set for every reference that resolved, and Quantity is the owner's
+// quantity after the row (as it would be, in a dry run) if the row is valid.
+type StockImportResult struct {
+	Line      int    `json:"line"`
+	ItemID    int64  `json:"item_id,omitempty"`
+	OwnerID   int64  `json:"owner_id,omitempty"`
+	Added     int    `json:"added,omitempty"`
+	Quantity  int    `json:"quantity,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Divisible bool   `json:"divisible,omitempty"` // see Transfer
+}
+
+// MarshalJSON renders Added and Quantity in whole units.
+func (r StockImportResult) MarshalJSON() ([]byte, error) {
+	type plain StockImportResult
+	out := struct {
+		plain
+		Added    json.Number `json:"added,omitempty"`
+		Quantity json.Number `json:"quantity,omitempty"`
+	}{plain: plain(r)}
+	if r.Added != 0 {
+		out.Added = QuantityNumber(r.Added, r.Divisible)
+	}
+	if r.Quantity != 0 {
+		out.Quantity = QuantityNumber(r.Quantity, r.Divisible)
+	}
+	return json.Marshal(out)
+}
+
+// TransferPreview is the outcome a transfer would have, as reported by a dry
+// run: the source and destination quantities after the move.
+type TransferPreview struct {
+	ItemID       int64 `json:"item_id"`
+	FromOwnerID  int64 `json:"from_owner_id"`
+	ToOwnerID    int64 `json:"to_owner_id"`
+	Quantity     int   `json:"quantity"`
+	FromQuantity int   `json:"from_quantity"`
+	ToQuantity   int   `json:"to_quantity"`
+	Divisible    bool  `json:"divisible,omitempty"` // see Transfer
+}
+
+// MarshalJSON renders the quantities in whole units.
+func (p TransferPreview) MarshalJSON() ([]byte, error) {
+	type plain TransferPreview
+	return json.Marshal(struct {
+		plain
+		Quantity     json.Number `json:"quantity"`
+		FromQuantity json.Number `json:"from_quantity"`
+		ToQuantity   json.Number `json:"to_quantity"`
+	}{plain(p), QuantityNumber(p.Quantity, p.Divisible),
+		QuantityNumber(p.FromQuantity, p.Divisible), QuantityNumber(p.ToQuantity, p.Divisible)})
+}
+
+// TransferTemplate is a named from/to owner pair for a recurring transfer,
+// such as returns from the front desk to main storage. It names no item;
+// the item and quantity are given when it is applied.
+type TransferTemplate struct {
+	ID          int64     `json:"id"`
+	Label       string    `json:"label"`
+	FromOwnerID int64     `json:"from_owner_id"`
+	ToOwnerID   int64     `json:"to_owner_id"`
+	CreatedBy   *int64    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Joined fields.
+	FromOwnerName string `json:"from_owner_name"`
+	ToOwnerName   string `json:"to_owner_name"`
 }
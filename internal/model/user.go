@@ -13,6 +13,29 @@ type User struct {
 	Role         string     `json:"role"`
 	CreatedAt    time.Time  `json:"created_at"`
 	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+
+	// MustChangePassword blocks everything but a password change until the
+	// user sets a new password themselves.
+	MustChangePassword bool `json:"must_change_password"`
+
+	// Locale is the language of the web UI for this user.
+	Locale string `json:"locale"`
+}
+
+// ReassignResult reports how many records a user reassignment repointed from
+// one user to another, per column.
+type ReassignResult struct {
+	FromUserID     int64 `json:"from_user_id"`
+	ToUserID       int64 `json:"to_user_id"`
+	Transfers      int64 `json:"transfers"`
+	StatusChanges  int64 `json:"status_changes"`
+	ItemDeletions  int64 `json:"item_deletions"`
+	OwnerDeletions int64 `json:"owner_deletions"`
+	Documents      int64 `json:"documents"`
+	Adjustments    int64 `json:"adjustments"`
+
+	// Deleted is set if the source user was soft-deleted afterwards.
+	Deleted bool `json:"deleted"`
 }
 
 // Roles.
@@ -40,13 +63,53 @@ func RoleAtLeast(role, minimum string) bool {
 	return roleLevel >= minLevel
 }
 
-// MinPasswordLength is the minimum allowed password length.
-const MinPasswordLength = 8
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// Web UI locales. Slovenian is the default.
+const (
+	LocaleSlovenian = "sl"
+	LocaleEnglish   = "en"
+	DefaultLocale   = LocaleSlovenian
+)
+
+// ValidLocale reports whether locale is one of the supported UI locales.
+func ValidLocale(locale string) bool {
+	return locale == LocaleSlovenian || locale == LocaleEnglish
+}
+
+// Bounds for the configurable minimum password length.
+const (
+	DefaultMinPasswordLength = 8
+	MaxMinPasswordLength     = 64
+)
+
+// minPasswordLength is the minimum enforced by ValidatePassword.
+var minPasswordLength = DefaultMinPasswordLength
+
+// MinPasswordLength returns the minimum allowed password length.
+func MinPasswordLength() int {
+	return minPasswordLength
+}
+
+// SetMinPasswordLength changes the minimum allowed password length. It must be
+// between DefaultMinPasswordLength and MaxMinPasswordLength. Call it once at
+// startup, before serving requests.
+func SetMinPasswordLength(n int) error {
+	if n < DefaultMinPasswordLength || n > MaxMinPasswordLength {
+		return fmt.Errorf("minimum password length must be between %d and %d", DefaultMinPasswordLength, MaxMinPasswordLength)
+	}
+	minPasswordLength = n
+	return nil
+}
 
 // ValidatePassword checks that a password meets minimum requirements.
 func ValidatePassword(password string) error {
-	if len(password) < MinPasswordLength {
-		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
 	}
 	// bcrypt silently truncates at 72 bytes.
 	if len([]byte(password)) > 72 {
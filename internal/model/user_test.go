@@ -32,6 +32,19 @@ func TestRoleAtLeast(t *testing.T) {
 	}
 }
 
+func TestValidRole(t *testing.T) {
+	for _, role := range []string{RoleAdmin, RoleManager, RoleUser} {
+		if !ValidRole(role) {
+			t.Errorf("ValidRole(%q) = false, want true", role)
+		}
+	}
+	for _, role := range []string{"", "superuser", "Admin"} {
+		if ValidRole(role) {
+			t.Errorf("ValidRole(%q) = true, want false", role)
+		}
+	}
+}
+
 func TestValidatePassword(t *testing.T) {
 	tests := []struct {
 		password string
@@ -51,3 +64,26 @@ func TestValidatePassword(t *testing.T) {
 		}
 	}
 }
+
+func TestValidatePasswordCustomMinimum(t *testing.T) {
+	t.Cleanup(func() { SetMinPasswordLength(DefaultMinPasswordLength) })
+
+	if err := SetMinPasswordLength(12); err != nil {
+		t.Fatalf("SetMinPasswordLength(12): %v", err)
+	}
+	if err := ValidatePassword("elevenchars"); err == nil {
+		t.Error("expected 11-character password to be rejected")
+	}
+	if err := ValidatePassword("twelve-chars"); err != nil {
+		t.Errorf("expected 12-character password to pass, got %v", err)
+	}
+
+	for _, n := range []int{7, 65} {
+		if err := SetMinPasswordLength(n); err == nil {
+			t.Errorf("SetMinPasswordLength(%d) should fail", n)
+		}
+	}
+	if MinPasswordLength() != 12 {
+		t.Errorf("rejected values must not change the minimum, got %d", MinPasswordLength())
+	}
+}
@@ -0,0 +1,196 @@
+// Package reprocess re-runs image processing over stored item images, so a
+// change to imaging.MaxDimension or the output format reaches existing
+// images too.
+package reprocess
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/imaging"
+	"github.com/erazemk/skladisce/internal/model"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+// DefaultDelay is the pause between two images, which keeps a run from
+// pinning a CPU while the server is serving requests.
+const DefaultDelay = 100 * time.Millisecond
+
+// Job reprocesses every stored item image that does not already conform to
+// what imaging.Process produces. At most one run is active at a time.
+type Job struct {
+	DB *sql.DB
+
+	// Delay is the pause between two images. Zero means no pause.
+	Delay time.Duration
+
+	// Context bounds runs started with Start; cancel it on shutdown. If nil,
+	// context.Background is used.
+	Context context.Context
+
+	mu     sync.Mutex
+	status model.ReprocessStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start begins a run in the background. It returns false if one is already
+// running.
+func (j *Job) Start() bool {
+	parent := j.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.Running {
+		return false
+	}
+	ctx, cancel := context.WithCancel(parent)
+	j.begin(cancel)
+	go func() {
+		defer cancel()
+		j.run(ctx)
+	}()
+	return true
+}
+
+// Run reprocesses images until done or ctx is canceled and returns the final
+// status. It returns false if a run is already active.
+func (j *Job) Run(ctx context.Context) (model.ReprocessStatus, bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	j.mu.Lock()
+	if j.status.Running {
+		j.mu.Unlock()
+		return j.Status(), false
+	}
+	j.begin(cancel)
+	j.mu.Unlock()
+
+	j.run(ctx)
+	return j.Status(), true
+}
+
+// Cancel stops the active run after the image being processed. It returns
+// false if no run is active.
+func (j *Job) Cancel() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.status.Running {
+		return false
+	}
+	j.cancel()
+	return true
+}
+
+// Status returns the progress of the active run, or the result of the last
+// one.
+func (j *Job) Status() model.ReprocessStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// Wait blocks until the active run, if any, has finished.
+func (j *Job) Wait() {
+	j.mu.Lock()
+	done := j.done
+	j.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// begin resets the status for a new run. j.mu must be held.
+func (j *Job) begin(cancel context.CancelFunc) {
+	now := time.Now().UTC()
+	j.status = model.ReprocessStatus{Running: true, StartedAt: &now}
+	j.cancel = cancel
+	j.done = make(chan struct{})
+}
+
+func (j *Job) run(ctx context.Context) {
+	defer j.finish(ctx)
+
+	ids, err := store.ListItemImageIDs(ctx, j.DB)
+	if err != nil {
+		if ctx.Err() == nil {
+			j.update(func(s *model.ReprocessStatus) { s.Error = err.Error() })
+		}
+		return
+	}
+	j.update(func(s *model.ReprocessStatus) { s.Total = len(ids) })
+	slog.Info("image reprocessing started", "images", len(ids))
+
+	for i, id := range ids {
+		if i > 0 && j.Delay > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(j.Delay):
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		updated, err := j.reprocess(ctx, id)
+		j.update(func(s *model.ReprocessStatus) {
+			s.Processed++
+			switch {
+			case err != nil:
+				s.Failed++
+			case updated:
+				s.Updated++
+			default:
+				s.Skipped++
+			}
+		})
+		if err != nil {
+			slog.Warn("failed to reprocess item image", "item_id", id, "error", err)
+		}
+	}
+}
+
+// reprocess processes one item's image and stores the result. It reports
+// false if the image already conformed or was replaced meanwhile.
+func (j *Job) reprocess(ctx context.Context, id int64) (bool, error) {
+	data, _, err := store.GetItemImage(ctx, j.DB, id, true)
+	if err != nil {
+		return false, err
+	}
+	if data == nil || imaging.Conforms(data) {
+		return false, nil
+	}
+
+	result, err := imaging.Process(bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	return store.ReplaceItemImage(ctx, j.DB, id, data, result.Data, result.MIME)
+}
+
+func (j *Job) update(f func(*model.ReprocessStatus)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f(&j.status)
+}
+
+func (j *Job) finish(ctx context.Context) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now().UTC()
+	j.status.Running = false
+	j.status.FinishedAt = &now
+	j.status.Canceled = ctx.Err() != nil && j.status.Error == ""
+	close(j.done)
+
+	slog.Info("image reprocessing finished", "updated", j.status.Updated, "skipped", j.status.Skipped,
+		"failed", j.status.Failed, "total", j.status.Total, "canceled", j.status.Canceled)
+}
@@ -0,0 +1,142 @@
+package reprocess
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/imaging"
+	"github.com/erazemk/skladisce/internal/store"
+)
+
+func encode(t *testing.T, w, h int, asPNG bool) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{0, 128, 255, 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if asPNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		t.Fatalf("encoding test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func dimensions(t *testing.T, data []byte) (int, int) {
+	t.Helper()
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding stored image: %v", err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestRunReprocessesImages(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	large, _ := store.CreateItem(ctx, database, "Large", "", "")
+	small, _ := store.CreateItem(ctx, database, "Small", "", "")
+	pngItem, _ := store.CreateItem(ctx, database, "PNG", "", "")
+	broken, _ := store.CreateItem(ctx, database, "Broken", "", "")
+	store.CreateItem(ctx, database, "No image", "", "")
+
+	smallData := encode(t, 100, 50, false)
+	store.SetItemImage(ctx, database, large.ID, encode(t, 2000, 1000, false), "image/jpeg")
+	store.SetItemImage(ctx, database, small.ID, smallData, "image/jpeg")
+	store.SetItemImage(ctx, database, pngItem.ID, encode(t, 40, 30, true), "image/png")
+	store.SetItemImage(ctx, database, broken.ID, []byte("not an image"), "image/jpeg")
+
+	job := &Job{DB: database}
+	status, ok := job.Run(ctx)
+	if !ok {
+		t.Fatal("expected run to start")
+	}
+	if status.Running || status.Total != 4 || status.Processed != 4 ||
+		status.Updated != 2 || status.Skipped != 1 || status.Failed != 1 || status.Canceled {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	data, mime, _ := store.GetItemImage(ctx, database, large.ID, false)
+	if w, h := dimensions(t, data); w != imaging.MaxDimension || h != imaging.MaxDimension/2 {
+		t.Errorf("expected %dx%d, got %dx%d", imaging.MaxDimension, imaging.MaxDimension/2, w, h)
+	}
+	if mime != "image/jpeg" {
+		t.Errorf("expected image/jpeg, got %q", mime)
+	}
+
+	data, mime, _ = store.GetItemImage(ctx, database, pngItem.ID, false)
+	if w, h := dimensions(t, data); w != 40 || h != 30 || mime != "image/jpeg" {
+		t.Errorf("expected 40x30 JPEG, got %dx%d %s", w, h, mime)
+	}
+
+	if data, _, _ := store.GetItemImage(ctx, database, small.ID, false); !bytes.Equal(data, smallData) {
+		t.Error("expected conforming image to be left untouched")
+	}
+
+	// A second run finds nothing left to do.
+	status, _ = job.Run(ctx)
+	if status.Updated != 0 || status.Skipped != 3 || status.Failed != 1 {
+		t.Errorf("unexpected status on second run: %+v", status)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := store.CreateItem(ctx, database, "Large", "", "")
+	store.SetItemImage(ctx, database, item.ID, encode(t, 1500, 1500, false), "image/jpeg")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	status, _ := (&Job{DB: database}).Run(canceled)
+	if !status.Canceled || status.Processed != 0 {
+		t.Errorf("expected canceled run with nothing processed, got %+v", status)
+	}
+}
+
+func TestStartAndCancel(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"A", "B", "C"} {
+		item, _ := store.CreateItem(ctx, database, name, "", "")
+		store.SetItemImage(ctx, database, item.ID, encode(t, 10, 10, true), "image/png")
+	}
+
+	// A long delay keeps the run waiting after the first image.
+	job := &Job{DB: database, Delay: time.Hour}
+	if !job.Start() {
+		t.Fatal("expected run to start")
+	}
+	if job.Start() {
+		t.Error("expected second start to be refused while running")
+	}
+	if !job.Cancel() {
+		t.Error("expected cancel to stop the active run")
+	}
+	job.Wait()
+
+	status := job.Status()
+	if status.Running || !status.Canceled || status.Processed > 1 {
+		t.Errorf("expected an unfinished, canceled run, got %+v", status)
+	}
+	if job.Cancel() {
+		t.Error("expected cancel to report no active run")
+	}
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// ListActivity returns recent item, owner and transfer events older than
+// before, newest first. At most limit timestamps' worth of events are taken,
+// but events sharing the oldest returned timestamp are always returned
+// together, so paging with before = last event's At never skips any.
+func ListActivity(ctx context.Context, db *sql.DB, before time.Time, limit int) ([]model.ActivityEvent, error) {
+	beforeStr := before.UTC().Format(sqliteTimeFormat)
+	rows, err := db.QueryContext(ctx,
+		`WITH events AS (
+		   SELECT 'item_created' AS type, i.created_at AS at, i.id AS ref_id, i.name AS name,
+		          NULL AS item_id, NULL AS from_owner_id, NULL AS from_owner_name,
+		          NULL AS to_owner_id, NULL AS to_owner_name, NULL AS quantity, NULL AS notes, NULL AS user_id, 0 AS divisible
+		   FROM items i
+		   UNION ALL
+		   SELECT 'item_updated', i.updated_at, i.id, i.name,
+		          NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, 0
+		   FROM items i WHERE i.updated_at > i.created_at
+		   UNION ALL
+		   SELECT 'owner_created', o.created_at, o.id, o.name,
+		          NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, 0
+		   FROM owners o
+		   UNION ALL
+		   SELECT 'transfer', t.transferred_at, t.id, i.name,
+		          t.item_id, t.from_owner_id, fo.name, t.to_owner_id, too.name, t.quantity, t.notes, t.transferred_by, i.divisible
+		   FROM transfers t
+		   JOIN items i ON i.id = t.item_id
+		   JOIN owners fo ON fo.id = t.from_owner_id
+		   JOIN owners too ON too.id = t.to_owner_id
+		 ),
+		 page AS (SELECT at FROM events WHERE at < ? ORDER BY at DESC LIMIT ?)
+		 SELECT type, at, ref_id, name, item_id, from_owner_id, from_owner_name,
+		        to_owner_id, to_owner_name, quantity, notes, user_id, divisible
+		 FROM events
+		 WHERE at < ? AND at >= (SELECT MIN(at) FROM page)
+		 ORDER BY at DESC, type, ref_id DESC`,
+		beforeStr, limit, beforeStr,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing activity: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.ActivityEvent
+	for rows.Next() {
+		var e model.ActivityEvent
+		var refID int64
+		var itemID, fromOwnerID, toOwnerID, quantity sql.NullInt64
+		var fromOwnerName, toOwnerName, notes sql.NullString
+		var userID *int64
+		var divisible bool
+		if err := rows.Scan(&e.Type, &e.At, &refID, &e.Name, &itemID, &fromOwnerID, &fromOwnerName,
+			&toOwnerID, &toOwnerName, &quantity, &notes, &userID, &divisible); err != nil {
+			return nil, fmt.Errorf("scanning activity event: %w", err)
+		}
+
+		if e.Type == model.ActivityTransfer {
+			e.Transfer = &model.Transfer{
+				ID:            refID,
+				ItemID:        itemID.Int64,
+				FromOwnerID:   fromOwnerID.Int64,
+				ToOwnerID:     toOwnerID.Int64,
+				Quantity:      int(quantity.Int64),
+				Notes:         notes.String,
+				TransferredAt: e.At,
+				TransferredBy: userID,
+				ItemName:      e.Name,
+				FromOwnerName: fromOwnerName.String,
+				ToOwnerName:   toOwnerName.String,
+				Divisible:     divisible,
+			}
+			e.Name = ""
+		} else {
+			e.ID = refID
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
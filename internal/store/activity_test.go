@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestListActivityMergesAndPages(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	office, _ := CreateOwner(ctx, database, "Office", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, storage.ID, 5, false, nil)
+	CreateTransfer(ctx, database, item.ID, storage.ID, office.ID, 2, false, "", nil)
+
+	database.ExecContext(ctx, `UPDATE items SET created_at = '2024-01-01 10:00:00', updated_at = '2024-01-05 10:00:00'`)
+	database.ExecContext(ctx, `UPDATE owners SET created_at = '2024-01-02 10:00:00' WHERE id = ?`, storage.ID)
+	database.ExecContext(ctx, `UPDATE owners SET created_at = '2024-01-03 10:00:00' WHERE id = ?`, office.ID)
+	database.ExecContext(ctx, `UPDATE transfers SET transferred_at = '2024-01-04 10:00:00'`)
+
+	now := time.Now()
+	events, err := ListActivity(ctx, database, now, 10)
+	if err != nil {
+		t.Fatalf("ListActivity: %v", err)
+	}
+	want := []string{
+		model.ActivityItemUpdated, model.ActivityTransfer, model.ActivityOwnerCreated,
+		model.ActivityOwnerCreated, model.ActivityItemCreated,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+	for i, typ := range want {
+		if events[i].Type != typ {
+			t.Errorf("event %d: expected %q, got %q", i, typ, events[i].Type)
+		}
+	}
+	if tr := events[1].Transfer; tr == nil || tr.ItemName != "Widget" || tr.ToOwnerName != "Office" {
+		t.Errorf("unexpected transfer event: %+v", events[1].Transfer)
+	}
+	if events[2].ID != office.ID || events[2].Name != "Office" {
+		t.Errorf("expected office creation, got %+v", events[2])
+	}
+
+	// Page through two at a time using the last event's timestamp.
+	page1, _ := ListActivity(ctx, database, now, 2)
+	page2, _ := ListActivity(ctx, database, page1[len(page1)-1].At, 2)
+	page3, _ := ListActivity(ctx, database, page2[len(page2)-1].At, 2)
+	if len(page1) != 2 || len(page2) != 2 || len(page3) != 1 {
+		t.Fatalf("expected pages of 2, 2, 1, got %d, %d, %d", len(page1), len(page2), len(page3))
+	}
+	if page3[0].Type != model.ActivityItemCreated {
+		t.Errorf("expected item creation last, got %q", page3[0].Type)
+	}
+}
+
+func TestListActivityKeepsSameSecondEventsTogether(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	CreateOwner(ctx, database, "A", model.OwnerTypeLocation)
+	CreateOwner(ctx, database, "B", model.OwnerTypeLocation)
+	CreateOwner(ctx, database, "C", model.OwnerTypeLocation)
+	database.ExecContext(ctx, `UPDATE owners SET created_at = '2024-01-01 10:00:00'`)
+
+	events, _ := ListActivity(ctx, database, time.Now(), 2)
+	if len(events) != 3 {
+		t.Errorf("expected all 3 same-second events on one page, got %d", len(events))
+	}
+}
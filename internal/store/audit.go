@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// auditEntries is every attributable change, one row per event, keyed by
+// (at, action, ref_id).
+var auditEntries = `
+	SELECT t.transferred_at AS at, 'transfer' AS action, t.id AS ref_id, t.transferred_by AS user_id,
+	       'item' AS subject_type, t.item_id AS subject_id, i.name AS subject_name,
+	       ` + unitsText("t.quantity") + ` || ' from ' || fo.name || ' to ' || too.name AS details, t.notes AS reason
+	FROM transfers t
+	JOIN items i ON i.id = t.item_id
+	JOIN owners fo ON fo.id = t.from_owner_id
+	JOIN owners too ON too.id = t.to_owner_id
+	UNION ALL
+	SELECT sc.created_at, 'status_changed', sc.id, sc.user_id,
+	       'item', sc.item_id, i.name, sc.from_status || ' -> ' || sc.to_status, sc.reason
+	FROM status_changes sc
+	JOIN items i ON i.id = sc.item_id
+	UNION ALL
+	SELECT a.created_at, a.kind, a.id, a.user_id,
+	       'item', a.item_id, i.name,
+	       CASE WHEN a.delta < 0 THEN ` + unitsText("(-a.delta)") + ` || ' removed from '
+	            ELSE ` + unitsText("a.delta") + ` || ' added to ' END || o.name,
+	       a.reason
+	FROM inventory_adjustments a
+	JOIN items i ON i.id = a.item_id
+	JOIN owners o ON o.id = a.owner_id
+	UNION ALL
+	SELECT deleted_at, 'item_deleted', id, deleted_by, 'item', id, name, NULL, delete_reason
+	FROM items WHERE deleted_at IS NOT NULL
+	UNION ALL
+	SELECT deleted_at, 'owner_deleted', id, deleted_by, 'owner', id, name, NULL, delete_reason
+	FROM owners WHERE deleted_at IS NOT NULL`
+
+// unitsText is an SQL expression for a quantity column as text in whole
+// units: divisible items (the items table aliased as i must be joined) show
+// their thousandths as a decimal without trailing zeros, e.g. 1500 as 1.5.
+func unitsText(column string) string {
+	return fmt.Sprintf("CASE WHEN i.divisible THEN rtrim(rtrim(printf('%%.3f', %s / %d.0), '0'), '.') ELSE %s END",
+		column, model.QuantityScale, column)
+}
+
+// ListAuditPage returns up to limit audit entries matching filter, oldest
+// first, that come after the entry identified by after (nil for the first
+// page). Pass the last entry of one page as after to get the next, so large
+// logs can be walked without loading them whole.
+func ListAuditPage(ctx context.Context, db *sql.DB, filter model.AuditFilter, after *model.AuditEntry, limit int) ([]model.AuditEntry, error) {
+	var afterAt, afterAction string
+	var afterRef int64
+	if after != nil {
+		afterAt, afterAction, afterRef = after.At.UTC().Format(sqliteTimeFormat), after.Action, after.RefID
+	}
+	var from, to string
+	if !filter.From.IsZero() {
+		from = filter.From.UTC().Format(sqliteTimeFormat)
+	}
+	if !filter.To.IsZero() {
+		to = filter.To.UTC().Format(sqliteTimeFormat)
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`WITH audit AS (`+auditEntries+`)
+		 SELECT a.at, a.action, a.ref_id, a.user_id, u.username,
+		        a.subject_type, a.subject_id, a.subject_name, a.details, a.reason
+		 FROM audit a
+		 LEFT JOIN users u ON u.id = a.user_id
+		 WHERE (a.at, a.action, a.ref_id) > (?, ?, ?)
+		   AND (? = '' OR a.at >= ?)
+		   AND (? = '' OR a.at < ?)
+		   AND (? = 0 OR a.user_id = ?)
+		 ORDER BY a.at, a.action, a.ref_id
+		 LIMIT ?`,
+		afterAt, afterAction, afterRef, from, from, to, to, filter.UserID, filter.UserID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.AuditEntry
+	for rows.Next() {
+		var e model.AuditEntry
+		var username, details, reason sql.NullString
+		if err := rows.Scan(&e.At, &e.Action, &e.RefID, &e.UserID, &username,
+			&e.SubjectType, &e.SubjectID, &e.SubjectName, &details, &reason); err != nil {
+			return nil, fmt.Errorf("scanning audit entry: %w", err)
+		}
+		e.Username, e.Details, e.Reason = username.String, details.String, reason.String
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// ListUserActions returns the audit entries made by userID, newest first,
+// skipping offset and returning at most limit.
+func ListUserActions(ctx context.Context, db *sql.DB, userID int64, limit, offset int) ([]model.AuditEntry, error) {
+	rows, err := db.QueryContext(ctx,
+		`WITH audit AS (`+auditEntries+`)
+		 SELECT a.at, a.action, a.ref_id, a.user_id, u.username,
+		        a.subject_type, a.subject_id, a.subject_name, a.details, a.reason
+		 FROM audit a
+		 JOIN users u ON u.id = a.user_id
+		 WHERE a.user_id = ?
+		 ORDER BY a.at DESC, a.action DESC, a.ref_id DESC
+		 LIMIT ? OFFSET ?`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing user actions: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.AuditEntry
+	for rows.Next() {
+		var e model.AuditEntry
+		var details, reason sql.NullString
+		if err := rows.Scan(&e.At, &e.Action, &e.RefID, &e.UserID, &e.Username,
+			&e.SubjectType, &e.SubjectID, &e.SubjectName, &details, &reason); err != nil {
+			return nil, fmt.Errorf("scanning audit entry: %w", err)
+		}
+		e.Details, e.Reason = details.String, reason.String
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
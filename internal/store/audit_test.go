@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestListAuditPage(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	alice, _ := CreateUser(ctx, database, "alice", "hash", model.RoleManager)
+	bob, _ := CreateUser(ctx, database, "bob", "hash", model.RoleManager)
+	item, _ := CreateItem(ctx, database, "Drill", "", "")
+	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := CreateOwner(ctx, database, "Site", model.OwnerTypeLocation)
+	shelf, _ := CreateOwner(ctx, database, "Old shelf", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, from.ID, 5, false, nil)
+
+	CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 2, false, "for the site", &alice.ID)
+	UpdateItem(ctx, database, item.ID, "Drill", "", "", model.ItemStatusDamaged, "dropped", &bob.ID)
+	if err := DeleteOwner(ctx, database, shelf.ID, &alice.ID, "closed"); err != nil {
+		t.Fatalf("DeleteOwner: %v", err)
+	}
+
+	all, err := ListAuditPage(ctx, database, model.AuditFilter{}, nil, 10)
+	if err != nil {
+		t.Fatalf("ListAuditPage: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 entries, got %d: %+v", len(all), all)
+	}
+
+	byAlice, _ := ListAuditPage(ctx, database, model.AuditFilter{UserID: alice.ID}, nil, 10)
+	if len(byAlice) != 2 {
+		t.Fatalf("expected 2 entries by alice, got %+v", byAlice)
+	}
+	for _, e := range byAlice {
+		if e.Username != "alice" {
+			t.Errorf("expected alice's entries only, got %+v", e)
+		}
+	}
+	var transfer *model.AuditEntry
+	for i := range byAlice {
+		if byAlice[i].Action == model.AuditTransfer {
+			transfer = &byAlice[i]
+		}
+	}
+	if transfer == nil || transfer.Details != "2 from Storage to Site" || transfer.Reason != "for the site" {
+		t.Errorf("unexpected transfer entry: %+v", transfer)
+	}
+
+	// Keyset paging visits every entry exactly once.
+	var paged []model.AuditEntry
+	var after *model.AuditEntry
+	for {
+		page, err := ListAuditPage(ctx, database, model.AuditFilter{}, after, 1)
+		if err != nil {
+			t.Fatalf("ListAuditPage: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page...)
+		after = &page[len(page)-1]
+	}
+	if len(paged) != len(all) {
+		t.Errorf("expected %d entries when paging, got %d", len(all), len(paged))
+	}
+
+	future, _ := ListAuditPage(ctx, database, model.AuditFilter{From: time.Now().Add(time.Hour)}, nil, 10)
+	if len(future) != 0 {
+		t.Errorf("expected no entries after from, got %d", len(future))
+	}
+}
+
+func TestListAuditPageIncludesDecommissioning(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	manager, _ := CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	item, _ := CreateItem(ctx, database, "Drill", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, storage.ID, 3, false, nil)
+	DecommissionItem(ctx, database, item.ID, "worn out", &manager.ID)
+
+	entries, _ := ListAuditPage(ctx, database, model.AuditFilter{}, nil, 10)
+	var found bool
+	for _, e := range entries {
+		if e.Action == model.AuditDecommissioned {
+			found = e.Details == "3 removed from Storage" && e.Reason == "worn out" && e.Username == "manager"
+		}
+	}
+	if !found || len(entries) != 2 {
+		t.Errorf("expected a status change and a decommissioned entry, got %+v", entries)
+	}
+}
+
+func TestListAuditPageIncludesBatchStock(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	manager, _ := CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	item, _ := CreateItem(ctx, database, "Drill", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	if _, err := AddStockBatch(ctx, database, storage.ID, []model.StockLine{{ItemID: item.ID, Quantity: 5}}, &manager.ID); err != nil {
+		t.Fatalf("AddStockBatch: %v", err)
+	}
+
+	entries, _ := ListAuditPage(ctx, database, model.AuditFilter{UserID: manager.ID}, nil, 10)
+	if len(entries) != 1 || entries[0].Action != model.AuditStockAdded ||
+		entries[0].Details != "5 added to Storage" || entries[0].SubjectName != "Drill" {
+		t.Errorf("expected one stock_added entry, got %+v", entries)
+	}
+}
+
+func TestListAuditPageIncludesSetStock(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	manager, _ := CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	item, _ := CreateItem(ctx, database, "Drill", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, storage.ID, 5, false, nil)
+	if _, err := SetStock(ctx, database, item.ID, storage.ID, 2, false, &manager.ID); err != nil {
+		t.Fatalf("SetStock: %v", err)
+	}
+
+	entries, _ := ListAuditPage(ctx, database, model.AuditFilter{UserID: manager.ID}, nil, 10)
+	if len(entries) != 1 || entries[0].Action != model.AuditStockSet || entries[0].Details != "3 removed from Storage" {
+		t.Errorf("expected one stock_set entry, got %+v", entries)
+	}
+}
+
+func TestListAuditPageDivisibleQuantities(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Cable", "", "")
+	if err := SetItemDivisible(ctx, database, item.ID, true); err != nil {
+		t.Fatalf("SetItemDivisible: %v", err)
+	}
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	site, _ := CreateOwner(ctx, database, "Site", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, storage.ID, 3250, true, nil)
+	if _, err := CreateTransfer(ctx, database, item.ID, storage.ID, site.ID, 1500, true, "", nil); err != nil {
+		t.Fatalf("CreateTransfer: %v", err)
+	}
+	if _, err := SetStock(ctx, database, item.ID, storage.ID, 1000, true, nil); err != nil {
+		t.Fatalf("SetStock: %v", err)
+	}
+
+	entries, _ := ListAuditPage(ctx, database, model.AuditFilter{}, nil, 10)
+	details := map[string]string{}
+	for _, e := range entries {
+		details[e.Action] = e.Details
+	}
+	if details[model.AuditTransfer] != "1.5 from Storage to Site" {
+		t.Errorf("expected the transfer in whole units, got %q", details[model.AuditTransfer])
+	}
+	if details[model.AuditStockSet] != "0.75 removed from Storage" {
+		t.Errorf("expected the set stock difference in whole units, got %q", details[model.AuditStockSet])
+	}
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ErrBusy is returned by writes that could not get the database lock within
+// the busy timeout because other connections held it. Nothing was written,
+// so the caller may retry.
+var ErrBusy = errors.New("database is busy")
+
+// checkBusy wraps err with ErrBusy if SQLite reported it as busy or locked
+// (including extended codes such as SQLITE_BUSY_SNAPSHOT), and returns any
+// other error, or nil, unchanged.
+func checkBusy(err error) error {
+	var e *sqlite.Error
+	if errors.As(err, &e) {
+		switch e.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return fmt.Errorf("%w: %v", ErrBusy, err)
+		}
+	}
+	return err
+}
+
+// exec runs a single write statement outside a transaction, reporting lock
+// contention as ErrBusy.
+func exec(ctx context.Context, db *sql.DB, query string, args ...any) (sql.Result, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	return result, checkBusy(err)
+}
+
+// commit commits tx, reporting lock contention as ErrBusy.
+func commit(tx *sql.Tx) error {
+	return checkBusy(tx.Commit())
+}
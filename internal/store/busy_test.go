@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestWritesReportBusy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "busy.sqlite3")
+	holder, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { holder.Close() })
+	if err := db.EnsureSchema(holder); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+
+	// A second handle that gives up on the lock at once, on a single
+	// connection so the pragma sticks.
+	waiter, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { waiter.Close() })
+	waiter.SetMaxOpenConns(1)
+	if _, err := waiter.Exec(`PRAGMA busy_timeout = 0`); err != nil {
+		t.Fatalf("setting busy_timeout: %v", err)
+	}
+
+	ctx := context.Background()
+	tx, err := beginImmediate(ctx, holder)
+	if err != nil {
+		t.Fatalf("beginImmediate: %v", err)
+	}
+
+	// Transactional and single-statement writes both report ErrBusy.
+	if _, err := CreateOwner(ctx, waiter, "Shed", model.OwnerTypeLocation); !errors.Is(err, ErrBusy) {
+		t.Errorf("expected ErrBusy from CreateOwner, got %v", err)
+	}
+	if err := RevokeToken(ctx, waiter, "jti", time.Now().Add(time.Hour)); !errors.Is(err, ErrBusy) {
+		t.Errorf("expected ErrBusy from RevokeToken, got %v", err)
+	}
+
+	tx.Rollback()
+	if _, err := CreateOwner(ctx, waiter, "Shed", model.OwnerTypeLocation); err != nil {
+		t.Errorf("expected the retry to succeed, got %v", err)
+	}
+}
+
+func TestCheckBusy(t *testing.T) {
+	if err := checkBusy(nil); err != nil {
+		t.Errorf("expected nil to stay nil, got %v", err)
+	}
+	other := errors.New("no such table")
+	if err := checkBusy(other); err != other {
+		t.Errorf("expected other errors unchanged, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// AddDocument attaches a document to a non-deleted item. The filename and
+// MIME type must already be normalized and validated.
+func AddDocument(ctx context.Context, db *sql.DB, itemID int64, filename, mime string, data []byte, uploadedBy *int64) (*model.Document, error) {
+	result, err := exec(ctx, db,
+		`INSERT INTO item_documents (item_id, filename, mime, data, uploaded_by)
+		 SELECT id, ?, ?, ?, ? FROM items WHERE id = ? AND deleted_at IS NULL`,
+		filename, mime, data, uploadedBy, itemID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("adding document: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("checking rows affected: %w", err)
+	}
+	if n == 0 {
+		return nil, invalidf("item not found")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("getting document id: %w", err)
+	}
+	return GetDocument(ctx, db, itemID, id, false)
+}
+
+// ListDocuments returns the metadata of an item's documents, oldest first.
+func ListDocuments(ctx context.Context, db *sql.DB, itemID int64) ([]model.Document, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, item_id, filename, mime, length(data), uploaded_by, created_at
+		 FROM item_documents WHERE item_id = ? ORDER BY created_at, id`, itemID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing documents: %w", err)
+	}
+	defer rows.Close()
+
+	var documents []model.Document
+	for rows.Next() {
+		var d model.Document
+		if err := rows.Scan(&d.ID, &d.ItemID, &d.Filename, &d.MIME, &d.Size, &d.UploadedBy, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning document: %w", err)
+		}
+		documents = append(documents, d)
+	}
+	return documents, rows.Err()
+}
+
+// GetDocument returns one of an item's documents, with its data if withData
+// is set. It returns nil if the item has no such document.
+func GetDocument(ctx context.Context, db *sql.DB, itemID, id int64, withData bool) (*model.Document, error) {
+	data := `NULL`
+	if withData {
+		data = `data`
+	}
+	var d model.Document
+	err := db.QueryRowContext(ctx,
+		`SELECT id, item_id, filename, mime, length(data), uploaded_by, created_at, `+data+`
+		 FROM item_documents WHERE id = ? AND item_id = ?`, id, itemID,
+	).Scan(&d.ID, &d.ItemID, &d.Filename, &d.MIME, &d.Size, &d.UploadedBy, &d.CreatedAt, &d.Data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting document: %w", err)
+	}
+	return &d, nil
+}
+
+// DeleteDocument removes one of an item's documents.
+func DeleteDocument(ctx context.Context, db *sql.DB, itemID, id int64) error {
+	result, err := exec(ctx, db,
+		`DELETE FROM item_documents WHERE id = ? AND item_id = ?`, id, itemID,
+	)
+	if err != nil {
+		return fmt.Errorf("deleting document: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if n == 0 {
+		return invalidf("document not found")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+)
+
+func TestItemDocuments(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Drill", "", "")
+	other, _ := CreateItem(ctx, database, "Saw", "", "")
+	user, _ := CreateUser(ctx, database, "ana", "hash", "manager")
+
+	receipt, err := AddDocument(ctx, database, item.ID, "receipt.pdf", "application/pdf", []byte("%PDF-1.4 receipt"), &user.ID)
+	if err != nil {
+		t.Fatalf("AddDocument: %v", err)
+	}
+	if receipt.Size != 16 || receipt.Data != nil || receipt.UploadedBy == nil || *receipt.UploadedBy != user.ID {
+		t.Errorf("unexpected document: %+v", receipt)
+	}
+	AddDocument(ctx, database, item.ID, "manual.txt", "text/plain; charset=utf-8", []byte("read me"), nil)
+
+	docs, err := ListDocuments(ctx, database, item.ID)
+	if err != nil {
+		t.Fatalf("ListDocuments: %v", err)
+	}
+	if len(docs) != 2 || docs[0].Filename != "receipt.pdf" || docs[1].Filename != "manual.txt" || docs[0].Data != nil {
+		t.Errorf("unexpected documents: %+v", docs)
+	}
+
+	got, err := GetDocument(ctx, database, item.ID, receipt.ID, true)
+	if err != nil || got == nil || !bytes.Equal(got.Data, []byte("%PDF-1.4 receipt")) {
+		t.Fatalf("expected the receipt's data, got %+v, %v", got, err)
+	}
+	if got, _ := GetDocument(ctx, database, other.ID, receipt.ID, true); got != nil {
+		t.Error("expected another item's document to be missing")
+	}
+
+	if err := DeleteDocument(ctx, database, other.ID, receipt.ID); err == nil {
+		t.Error("expected error deleting through another item")
+	}
+	if err := DeleteDocument(ctx, database, item.ID, receipt.ID); err != nil {
+		t.Fatalf("DeleteDocument: %v", err)
+	}
+	if docs, _ := ListDocuments(ctx, database, item.ID); len(docs) != 1 {
+		t.Errorf("expected one document left, got %d", len(docs))
+	}
+
+	DeleteItem(ctx, database, other.ID, nil, "")
+	if _, err := AddDocument(ctx, database, other.ID, "x.pdf", "application/pdf", []byte("%PDF-"), nil); err == nil {
+		t.Error("expected error attaching to a deleted item")
+	}
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// AddFavorite pins an item for a user. Pinning an already pinned item is a
+// no-op.
+func AddFavorite(ctx context.Context, db *sql.DB, userID, itemID int64) error {
+	_, err := exec(ctx, db,
+		`INSERT INTO user_favorites (user_id, item_id) VALUES (?, ?)
+		 ON CONFLICT (user_id, item_id) DO NOTHING`,
+		userID, itemID,
+	)
+	if err != nil {
+		return fmt.Errorf("adding favorite: %w", err)
+	}
+	return nil
+}
+
+// RemoveFavorite unpins an item for a user. Unpinning an item that is not
+// pinned is a no-op.
+func RemoveFavorite(ctx context.Context, db *sql.DB, userID, itemID int64) error {
+	_, err := exec(ctx, db,
+		`DELETE FROM user_favorites WHERE user_id = ? AND item_id = ?`,
+		userID, itemID,
+	)
+	if err != nil {
+		return fmt.Errorf("removing favorite: %w", err)
+	}
+	return nil
+}
+
+// ListFavorites returns a user's pinned, non-deleted items ordered by name.
+func ListFavorites(ctx context.Context, db *sql.DB, userID int64) ([]model.Item, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT i.id, i.name, i.description, i.condition, i.min_quantity, i.reorder_target, i.serialized, i.divisible, i.image_mime, i.status, i.created_at, i.updated_at, i.deleted_at
+		 FROM user_favorites f
+		 JOIN items i ON i.id = f.item_id
+		 WHERE f.user_id = ? AND i.deleted_at IS NULL
+		 ORDER BY i.name`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing favorites: %w", err)
+	}
+	defer rows.Close()
+
+	return scanItems(rows)
+}
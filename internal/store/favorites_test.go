@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestFavorites(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	ana, _ := CreateUser(ctx, database, "ana", "hash", model.RoleUser)
+	bor, _ := CreateUser(ctx, database, "bor", "hash", model.RoleUser)
+	laptop, _ := CreateItem(ctx, database, "Laptop", "", "")
+	cable, _ := CreateItem(ctx, database, "Cable", "", "")
+	drill, _ := CreateItem(ctx, database, "Drill", "", "")
+
+	for _, id := range []int64{laptop.ID, cable.ID, drill.ID, laptop.ID} {
+		if err := AddFavorite(ctx, database, ana.ID, id); err != nil {
+			t.Fatalf("AddFavorite: %v", err)
+		}
+	}
+	AddFavorite(ctx, database, bor.ID, drill.ID)
+
+	if err := RemoveFavorite(ctx, database, ana.ID, cable.ID); err != nil {
+		t.Fatalf("RemoveFavorite: %v", err)
+	}
+	if err := RemoveFavorite(ctx, database, ana.ID, cable.ID); err != nil {
+		t.Fatalf("RemoveFavorite twice: %v", err)
+	}
+	DeleteItem(ctx, database, drill.ID, nil, "")
+
+	favs, err := ListFavorites(ctx, database, ana.ID)
+	if err != nil {
+		t.Fatalf("ListFavorites: %v", err)
+	}
+	if len(favs) != 1 || favs[0].ID != laptop.ID {
+		t.Errorf("expected only Laptop pinned for ana, got %+v", favs)
+	}
+
+	favs, _ = ListFavorites(ctx, database, bor.ID)
+	if len(favs) != 0 {
+		t.Errorf("expected deleted item hidden for bor, got %+v", favs)
+	}
+}
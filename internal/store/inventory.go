@@ -3,31 +3,87 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/erazemk/skladisce/internal/model"
 )
 
-// ListInventory returns the full inventory overview.
-func ListInventory(ctx context.Context, db *sql.DB) ([]model.Inventory, error) {
-	rows, err := db.QueryContext(ctx,
-		`SELECT inv.item_id, inv.owner_id, inv.quantity,
-		        i.name AS item_name, o.name AS owner_name, o.type AS owner_type
-		 FROM inventory inv
-		 JOIN items i ON i.id = inv.item_id
-		 JOIN owners o ON o.id = inv.owner_id
-		 ORDER BY i.name, o.name
-		 LIMIT 1000`,
-	)
+// ListInventory returns the inventory overview, optionally limited to one
+// item, to owners of one type, or to rows whose item or owner name contains
+// query. At most 1000 rows are returned.
+func ListInventory(ctx context.Context, db *sql.DB, itemID int64, ownerType, query string) ([]model.Inventory, error) {
+	where, args := inventoryFilter(itemID, ownerType, query)
+	rows, err := db.QueryContext(ctx, inventorySelect+where+` ORDER BY i.name, o.name LIMIT 1000`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("listing inventory: %w", err)
 	}
 	defer rows.Close()
 
+	return scanInventory(rows)
+}
+
+// ListInventoryPage returns one page of the inventory overview with the same
+// filters as ListInventory, plus the total number of matching rows.
+func ListInventoryPage(ctx context.Context, db *sql.DB, itemID int64, ownerType, query string, limit, offset int) ([]model.Inventory, int, error) {
+	where, args := inventoryFilter(itemID, ownerType, query)
+
+	var total int
+	if err := db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM inventory inv
+		 JOIN items i ON i.id = inv.item_id
+		 JOIN owners o ON o.id = inv.owner_id`+where, args...,
+	).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting inventory: %w", err)
+	}
+
+	// The item and owner IDs break ties between rows with equal names, so
+	// pages neither repeat nor skip rows.
+	rows, err := db.QueryContext(ctx, inventorySelect+where+` ORDER BY i.name, o.name, inv.item_id, inv.owner_id LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("listing inventory: %w", err)
+	}
+	defer rows.Close()
+
+	inventory, err := scanInventory(rows)
+	return inventory, total, err
+}
+
+const inventorySelect = `SELECT inv.item_id, inv.owner_id, inv.quantity,
+	       i.name AS item_name, o.name AS owner_name, o.type AS owner_type, i.divisible
+	FROM inventory inv
+	JOIN items i ON i.id = inv.item_id
+	JOIN owners o ON o.id = inv.owner_id`
+
+// inventoryFilter builds the WHERE clause for the inventory list filters.
+func inventoryFilter(itemID int64, ownerType, query string) (string, []any) {
+	where := ` WHERE 1=1`
+	var args []any
+	if itemID > 0 {
+		where += ` AND inv.item_id = ?`
+		args = append(args, itemID)
+	}
+	if ownerType != "" {
+		where += ` AND o.type = ?`
+		args = append(args, ownerType)
+	}
+	if query != "" {
+		pattern := "%" + escapeLike(query) + "%"
+		where += ` AND (i.name LIKE ? ESCAPE '\' OR o.name LIKE ? ESCAPE '\')`
+		args = append(args, pattern, pattern)
+	}
+	return where, args
+}
+
+func scanInventory(rows *sql.Rows) ([]model.Inventory, error) {
 	var items []model.Inventory
 	for rows.Next() {
 		var inv model.Inventory
-		if err := rows.Scan(&inv.ItemID, &inv.OwnerID, &inv.Quantity, &inv.ItemName, &inv.OwnerName, &inv.OwnerType); err != nil {
+		if err := rows.Scan(&inv.ItemID, &inv.OwnerID, &inv.Quantity, &inv.ItemName, &inv.OwnerName, &inv.OwnerType, &inv.Divisible); err != nil {
 			return nil, fmt.Errorf("scanning inventory: %w", err)
 		}
 		items = append(items, inv)
@@ -36,9 +92,9 @@ func ListInventory(ctx context.Context, db *sql.DB) ([]model.Inventory, error) {
 }
 
 // AddStock adds initial stock of an item to an owner (any type).
-func AddStock(ctx context.Context, db *sql.DB, itemID, ownerID int64, quantity int, userID *int64) error {
+func AddStock(ctx context.Context, db *sql.DB, itemID, ownerID int64, quantity int, divisible bool, userID *int64) error {
 	if quantity <= 0 {
-		return fmt.Errorf("quantity must be positive")
+		return invalidf("quantity must be positive")
 	}
 
 	tx, err := beginImmediate(ctx, db)
@@ -53,33 +109,263 @@ func AddStock(ctx context.Context, db *sql.DB, itemID, ownerID int64, quantity i
 		`SELECT type FROM owners WHERE id = ? AND deleted_at IS NULL`, ownerID,
 	).Scan(&ownerType)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("owner not found")
+		return invalidf("owner not found")
 	}
 	if err != nil {
 		return fmt.Errorf("checking owner: %w", err)
 	}
 
+	if err := rejectSerialized(ctx, tx, itemID); err != nil {
+		return err
+	}
+	if err := checkDivisible(ctx, tx, itemID, divisible); err != nil {
+		return err
+	}
+
 	// Upsert inventory.
 	_, err = tx.ExecContext(ctx,
-		`INSERT INTO inventory (item_id, owner_id, quantity) VALUES (?, ?, ?)
-		 ON CONFLICT (item_id, owner_id) DO UPDATE SET quantity = quantity + ?`,
+		`INSERT INTO inventory (item_id, owner_id, quantity, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT (item_id, owner_id) DO UPDATE SET quantity = quantity + ?, updated_at = CURRENT_TIMESTAMP`,
 		itemID, ownerID, quantity, quantity,
 	)
 	if err != nil {
 		return fmt.Errorf("adding stock: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := commit(tx); err != nil {
 		return fmt.Errorf("committing stock addition: %w", err)
 	}
 	return nil
 }
 
+// StockLineError reports which line of a batch stock addition failed.
+type StockLineError struct {
+	Line   int // zero-based index into the batch
+	ItemID int64
+	Err    error
+}
+
+func (e *StockLineError) Error() string {
+	return fmt.Sprintf("line %d (item %d): %v", e.Line, e.ItemID, e.Err)
+}
+
+func (e *StockLineError) Unwrap() error { return e.Err }
+
+// AddStockBatch adds stock for several items to one owner in a single
+// transaction, with the same checks as AddStock, and records each line as a
+// stock_added adjustment by userID. Either every line is applied or none is;
+// a line refused by the checks is reported as a *StockLineError, and a
+// missing owner as a ValidationError.
+func AddStockBatch(ctx context.Context, db *sql.DB, ownerID int64, lines []model.StockLine, userID *int64) ([]model.StockLineResult, error) {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Verify the owner exists.
+	var ownerType string
+	err = tx.QueryRowContext(ctx,
+		`SELECT type FROM owners WHERE id = ? AND deleted_at IS NULL`, ownerID,
+	).Scan(&ownerType)
+	if err == sql.ErrNoRows {
+		return nil, invalidf("owner not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking owner: %w", err)
+	}
+
+	results := make([]model.StockLineResult, 0, len(lines))
+	for i, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, &StockLineError{Line: i, ItemID: line.ItemID, Err: invalidf("quantity must be positive")}
+		}
+
+		var divisible bool
+		err := tx.QueryRowContext(ctx,
+			`SELECT divisible FROM items WHERE id = ? AND deleted_at IS NULL`, line.ItemID,
+		).Scan(&divisible)
+		if err == sql.ErrNoRows {
+			return nil, &StockLineError{Line: i, ItemID: line.ItemID, Err: invalidf("item not found")}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checking item: %w", err)
+		}
+		if err := rejectSerialized(ctx, tx, line.ItemID); err != nil {
+			if !errors.Is(err, ErrSerializedItem) {
+				return nil, err
+			}
+			return nil, &StockLineError{Line: i, ItemID: line.ItemID, Err: err}
+		}
+		if divisible != line.Divisible {
+			return nil, &StockLineError{Line: i, ItemID: line.ItemID, Err: &ValidationError{Err: ErrDivisibleChanged}}
+		}
+
+		var quantity int
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO inventory (item_id, owner_id, quantity, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+			 ON CONFLICT (item_id, owner_id) DO UPDATE SET quantity = quantity + ?, updated_at = CURRENT_TIMESTAMP
+			 RETURNING quantity`,
+			line.ItemID, ownerID, line.Quantity, line.Quantity,
+		).Scan(&quantity)
+		if err != nil {
+			return nil, fmt.Errorf("adding stock: %w", checkBusy(err))
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO inventory_adjustments (item_id, owner_id, delta, kind, user_id) VALUES (?, ?, ?, ?, ?)`,
+			line.ItemID, ownerID, line.Quantity, model.AdjustmentStockAdded, userID,
+		); err != nil {
+			return nil, fmt.Errorf("recording adjustment: %w", checkBusy(err))
+		}
+		results = append(results, model.StockLineResult{ItemID: line.ItemID, Added: line.Quantity, Quantity: quantity, Divisible: divisible})
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing batch stock addition: %w", err)
+	}
+	return results, nil
+}
+
+// ImportStock adds stock for the rows of a CSV import in a single
+// transaction, with the same checks as AddStock. Each row's item and owner
+// references are resolved first (see resolveImportRef), and every row gets a
+// result, so a dry run reports all unresolved references at once. If any row
+// fails, or dryRun is set, nothing is applied. It reports whether the rows
+// were applied.
+func ImportStock(ctx context.Context, db *sql.DB, rows []model.StockImportRow, dryRun bool) ([]model.StockImportResult, bool, error) {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	results := make([]model.StockImportResult, 0, len(rows))
+	failed := false
+	for _, row := range rows {
+		res := model.StockImportResult{Line: row.Line}
+		var problems []string
+
+		itemID, problem, err := resolveImportRef(ctx, tx, "items", row.Item)
+		if err != nil {
+			return nil, false, fmt.Errorf("resolving item on line %d: %w", row.Line, err)
+		}
+		if problem != "" {
+			problems = append(problems, "item "+problem)
+		}
+		ownerID, problem, err := resolveImportRef(ctx, tx, "owners", row.Owner)
+		if err != nil {
+			return nil, false, fmt.Errorf("resolving owner on line %d: %w", row.Line, err)
+		}
+		if problem != "" {
+			problems = append(problems, "owner "+problem)
+		}
+		res.ItemID, res.OwnerID = itemID, ownerID
+
+		// Until the item resolves, the quantity is only checked as a decimal.
+		divisible := itemID == 0
+		if itemID != 0 {
+			if err := rejectSerialized(ctx, tx, itemID); err != nil {
+				if !errors.Is(err, ErrSerializedItem) {
+					return nil, false, err
+				}
+				problems = append(problems, err.Error())
+			}
+			if divisible, err = itemDivisible(ctx, tx, itemID); err != nil {
+				return nil, false, err
+			}
+			res.Divisible = divisible
+		}
+		quantity, err := model.ParseQuantity(row.Quantity, divisible)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else if quantity <= 0 {
+			problems = append(problems, "quantity must be positive")
+		}
+
+		// Valid rows are added even in a dry run, so later rows for the same
+		// item and owner report the running quantity; the transaction is
+		// rolled back unless everything is applied.
+		if len(problems) == 0 {
+			err := tx.QueryRowContext(ctx,
+				`INSERT INTO inventory (item_id, owner_id, quantity, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+				 ON CONFLICT (item_id, owner_id) DO UPDATE SET quantity = quantity + ?, updated_at = CURRENT_TIMESTAMP
+				 RETURNING quantity`,
+				itemID, ownerID, quantity, quantity,
+			).Scan(&res.Quantity)
+			if err != nil {
+				return nil, false, fmt.Errorf("adding stock on line %d: %w", row.Line, err)
+			}
+			res.Added = quantity
+		} else {
+			res.Error = strings.Join(problems, "; ")
+			failed = true
+		}
+		results = append(results, res)
+	}
+
+	if dryRun || failed {
+		return results, false, nil
+	}
+	if err := commit(tx); err != nil {
+		return nil, false, fmt.Errorf("committing stock import: %w", err)
+	}
+	return results, true, nil
+}
+
+// resolveImportRef resolves a reference to a non-deleted row of table (items
+// or owners): an id if ref is one that exists, otherwise a case-insensitive
+// exact name. It returns the id, or a problem such as "not found" if the
+// reference does not resolve to exactly one row.
+func resolveImportRef(ctx context.Context, tx *sql.Tx, table, ref string) (int64, string, error) {
+	if ref == "" {
+		return 0, "missing", nil
+	}
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		var exists bool
+		err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = ? AND deleted_at IS NULL)`, id,
+		).Scan(&exists)
+		if err != nil {
+			return 0, "", err
+		}
+		if exists {
+			return id, "", nil
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id FROM `+table+` WHERE name = ? COLLATE NOCASE AND deleted_at IS NULL LIMIT 2`, ref,
+	)
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, "", err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+	switch len(ids) {
+	case 0:
+		return 0, fmt.Sprintf("not found: %q", ref), nil
+	case 1:
+		return ids[0], "", nil
+	default:
+		return 0, fmt.Sprintf("name is ambiguous, use its id: %q", ref), nil
+	}
+}
+
 // AdjustInventory adjusts inventory quantity (for corrections/losses).
 // Delta can be negative. If resulting quantity is 0, the row is deleted.
-func AdjustInventory(ctx context.Context, db *sql.DB, itemID, ownerID int64, delta int, notes string, userID *int64) error {
+func AdjustInventory(ctx context.Context, db *sql.DB, itemID, ownerID int64, delta int, divisible bool, notes string, userID *int64) error {
 	if delta == 0 {
-		return fmt.Errorf("delta must be non-zero")
+		return invalidf("delta must be non-zero")
 	}
 
 	tx, err := beginImmediate(ctx, db)
@@ -88,6 +374,13 @@ func AdjustInventory(ctx context.Context, db *sql.DB, itemID, ownerID int64, del
 	}
 	defer tx.Rollback()
 
+	if err := rejectSerialized(ctx, tx, itemID); err != nil {
+		return err
+	}
+	if err := checkDivisible(ctx, tx, itemID, divisible); err != nil {
+		return err
+	}
+
 	// Get current quantity.
 	var current int
 	err = tx.QueryRowContext(ctx,
@@ -102,22 +395,19 @@ func AdjustInventory(ctx context.Context, db *sql.DB, itemID, ownerID int64, del
 
 	newQty := current + delta
 	if newQty < 0 {
-		return fmt.Errorf("adjustment would result in negative quantity: %d + %d = %d", current, delta, newQty)
+		return invalidf("adjustment would result in negative quantity: %d + %d = %d", current, delta, newQty)
 	}
 
 	if newQty == 0 {
-		_, err = tx.ExecContext(ctx,
-			`DELETE FROM inventory WHERE item_id = ? AND owner_id = ?`,
-			itemID, ownerID,
-		)
+		err = removeInventoryRow(ctx, tx, itemID, ownerID)
 	} else if current == 0 {
 		_, err = tx.ExecContext(ctx,
-			`INSERT INTO inventory (item_id, owner_id, quantity) VALUES (?, ?, ?)`,
+			`INSERT INTO inventory (item_id, owner_id, quantity, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)`,
 			itemID, ownerID, newQty,
 		)
 	} else {
 		_, err = tx.ExecContext(ctx,
-			`UPDATE inventory SET quantity = ? WHERE item_id = ? AND owner_id = ?`,
+			`UPDATE inventory SET quantity = ?, updated_at = CURRENT_TIMESTAMP WHERE item_id = ? AND owner_id = ?`,
 			newQty, itemID, ownerID,
 		)
 	}
@@ -125,17 +415,113 @@ func AdjustInventory(ctx context.Context, db *sql.DB, itemID, ownerID int64, del
 		return fmt.Errorf("adjusting inventory: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := commit(tx); err != nil {
 		return fmt.Errorf("committing adjustment: %w", err)
 	}
 	return nil
 }
 
+// SetStock sets how many of an item an owner holds to exactly quantity,
+// applying the difference in one transaction, and returns the previous
+// quantity. A non-zero difference is recorded as a stock_set adjustment by
+// userID. Setting it to 0 deletes the row; setting the current value is a
+// no-op, so repeating a call is safe.
+func SetStock(ctx context.Context, db *sql.DB, itemID, ownerID int64, quantity int, divisible bool, userID *int64) (int, error) {
+	if quantity < 0 {
+		return 0, invalidf("quantity must not be negative")
+	}
+
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Verify the owner and item exist.
+	var ownerType string
+	err = tx.QueryRowContext(ctx,
+		`SELECT type FROM owners WHERE id = ? AND deleted_at IS NULL`, ownerID,
+	).Scan(&ownerType)
+	if err == sql.ErrNoRows {
+		return 0, invalidf("owner not found")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("checking owner: %w", err)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM items WHERE id = ? AND deleted_at IS NULL)`, itemID,
+	).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("checking item: %w", err)
+	}
+	if !exists {
+		return 0, invalidf("item not found")
+	}
+	if err := rejectSerialized(ctx, tx, itemID); err != nil {
+		return 0, err
+	}
+	if err := checkDivisible(ctx, tx, itemID, divisible); err != nil {
+		return 0, err
+	}
+
+	var current int
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(quantity), 0) FROM inventory WHERE item_id = ? AND owner_id = ?`,
+		itemID, ownerID,
+	).Scan(&current)
+	if err != nil {
+		return 0, fmt.Errorf("checking current quantity: %w", err)
+	}
+	if current == quantity {
+		return current, nil
+	}
+
+	if quantity == 0 {
+		err = removeInventoryRow(ctx, tx, itemID, ownerID)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO inventory (item_id, owner_id, quantity, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+			 ON CONFLICT (item_id, owner_id) DO UPDATE SET quantity = excluded.quantity, updated_at = CURRENT_TIMESTAMP`,
+			itemID, ownerID, quantity,
+		)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("setting stock: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO inventory_adjustments (item_id, owner_id, delta, kind, user_id) VALUES (?, ?, ?, ?, ?)`,
+		itemID, ownerID, quantity-current, model.AdjustmentStockSet, userID,
+	); err != nil {
+		return 0, fmt.Errorf("recording adjustment: %w", err)
+	}
+
+	if err := commit(tx); err != nil {
+		return 0, fmt.Errorf("committing stock change: %w", err)
+	}
+	return current, nil
+}
+
+// GetHeldQuantity returns how many of an item an owner currently holds (0 if
+// none).
+func GetHeldQuantity(ctx context.Context, db *sql.DB, itemID, ownerID int64) (int, error) {
+	var quantity int
+	err := db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(quantity), 0) FROM inventory WHERE item_id = ? AND owner_id = ?`,
+		itemID, ownerID,
+	).Scan(&quantity)
+	if err != nil {
+		return 0, fmt.Errorf("getting held quantity: %w", err)
+	}
+	return quantity, nil
+}
+
 // GetItemDistribution returns inventory entries for a specific item.
 func GetItemDistribution(ctx context.Context, db *sql.DB, itemID int64) ([]model.Inventory, error) {
 	rows, err := db.QueryContext(ctx,
 		`SELECT inv.item_id, inv.owner_id, inv.quantity,
-		        i.name AS item_name, o.name AS owner_name, o.type AS owner_type
+		        i.name AS item_name, o.name AS owner_name, o.type AS owner_type, i.divisible
 		 FROM inventory inv
 		 JOIN items i ON i.id = inv.item_id
 		 JOIN owners o ON o.id = inv.owner_id
@@ -150,10 +536,64 @@ func GetItemDistribution(ctx context.Context, db *sql.DB, itemID int64) ([]model
 	var items []model.Inventory
 	for rows.Next() {
 		var inv model.Inventory
-		if err := rows.Scan(&inv.ItemID, &inv.OwnerID, &inv.Quantity, &inv.ItemName, &inv.OwnerName, &inv.OwnerType); err != nil {
+		if err := rows.Scan(&inv.ItemID, &inv.OwnerID, &inv.Quantity, &inv.ItemName, &inv.OwnerName, &inv.OwnerType, &inv.Divisible); err != nil {
 			return nil, fmt.Errorf("scanning inventory: %w", err)
 		}
 		items = append(items, inv)
 	}
 	return items, rows.Err()
 }
+
+// removeInventoryRow deletes an inventory row that dropped to zero and records
+// a tombstone so incremental sync clients learn about the removal.
+func removeInventoryRow(ctx context.Context, tx *sql.Tx, itemID, ownerID int64) error {
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM inventory WHERE item_id = ? AND owner_id = ?`,
+		itemID, ownerID,
+	); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO inventory_tombstones (item_id, owner_id) VALUES (?, ?)
+		 ON CONFLICT (item_id, owner_id) DO UPDATE SET deleted_at = CURRENT_TIMESTAMP`,
+		itemID, ownerID,
+	)
+	return err
+}
+
+// sqliteTimeFormat matches the text format of CURRENT_TIMESTAMP. Times bound
+// as query parameters are converted to UTC and formatted with it so they
+// compare correctly against columns it populated.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// ListInventoryChanges returns inventory rows changed at or after since, plus
+// tombstones (Deleted = true, Quantity = 0) for rows removed since then that
+// have not been re-created. Ordered by change time.
+func ListInventoryChanges(ctx context.Context, db *sql.DB, since time.Time) ([]model.InventoryChange, error) {
+	sinceStr := since.UTC().Format(sqliteTimeFormat)
+	rows, err := db.QueryContext(ctx,
+		`SELECT item_id, owner_id, quantity, updated_at, 0 AS deleted
+		 FROM inventory WHERE updated_at >= ?
+		 UNION ALL
+		 SELECT t.item_id, t.owner_id, 0, t.deleted_at, 1
+		 FROM inventory_tombstones t
+		 WHERE t.deleted_at >= ?
+		   AND NOT EXISTS (SELECT 1 FROM inventory inv WHERE inv.item_id = t.item_id AND inv.owner_id = t.owner_id)
+		 ORDER BY 4, 1, 2`,
+		sinceStr, sinceStr,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing inventory changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []model.InventoryChange
+	for rows.Next() {
+		var c model.InventoryChange
+		if err := rows.Scan(&c.ItemID, &c.OwnerID, &c.Quantity, &c.UpdatedAt, &c.Deleted); err != nil {
+			return nil, fmt.Errorf("scanning inventory change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -2,7 +2,12 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/erazemk/skladisce/internal/db"
 	"github.com/erazemk/skladisce/internal/model"
@@ -12,12 +17,12 @@ func TestAddStockAndListInventory(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	location, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 
-	AddStock(ctx, database, item.ID, location.ID, 10, nil)
+	AddStock(ctx, database, item.ID, location.ID, 10, false, nil)
 
-	inv, _ := ListInventory(ctx, database)
+	inv, _ := ListInventory(ctx, database, 0, "", "")
 	if len(inv) != 1 {
 		t.Fatalf("expected 1 inventory entry, got %d", len(inv))
 	}
@@ -30,10 +35,10 @@ func TestAddStockToPersonWorks(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	person, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
 
-	err := AddStock(ctx, database, item.ID, person.ID, 10, nil)
+	err := AddStock(ctx, database, item.ID, person.ID, 10, false, nil)
 	if err != nil {
 		t.Errorf("expected stock addition to person to succeed, got: %v", err)
 	}
@@ -48,13 +53,13 @@ func TestAddStockUpserts(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	location, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 
-	AddStock(ctx, database, item.ID, location.ID, 5, nil)
-	AddStock(ctx, database, item.ID, location.ID, 3, nil)
+	AddStock(ctx, database, item.ID, location.ID, 5, false, nil)
+	AddStock(ctx, database, item.ID, location.ID, 3, false, nil)
 
-	inv, _ := ListInventory(ctx, database)
+	inv, _ := ListInventory(ctx, database, 0, "", "")
 	if len(inv) != 1 {
 		t.Fatalf("expected 1 inventory entry, got %d", len(inv))
 	}
@@ -67,13 +72,13 @@ func TestAdjustInventory(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	location, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 
-	AddStock(ctx, database, item.ID, location.ID, 10, nil)
+	AddStock(ctx, database, item.ID, location.ID, 10, false, nil)
 
 	// Decrease by 3.
-	err := AdjustInventory(ctx, database, item.ID, location.ID, -3, "lost items", nil)
+	err := AdjustInventory(ctx, database, item.ID, location.ID, -3, false, "lost items", nil)
 	if err != nil {
 		t.Fatalf("AdjustInventory: %v", err)
 	}
@@ -88,17 +93,17 @@ func TestAdjustInventoryToZeroRemovesRow(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	location, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 
-	AddStock(ctx, database, item.ID, location.ID, 5, nil)
+	AddStock(ctx, database, item.ID, location.ID, 5, false, nil)
 
-	err := AdjustInventory(ctx, database, item.ID, location.ID, -5, "all lost", nil)
+	err := AdjustInventory(ctx, database, item.ID, location.ID, -5, false, "all lost", nil)
 	if err != nil {
 		t.Fatalf("AdjustInventory: %v", err)
 	}
 
-	inv, _ := ListInventory(ctx, database)
+	inv, _ := ListInventory(ctx, database, 0, "", "")
 	if len(inv) != 0 {
 		t.Errorf("expected 0 inventory entries, got %d", len(inv))
 	}
@@ -108,27 +113,76 @@ func TestAdjustInventoryNegativeResultFails(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	location, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 
-	AddStock(ctx, database, item.ID, location.ID, 3, nil)
+	AddStock(ctx, database, item.ID, location.ID, 3, false, nil)
 
-	err := AdjustInventory(ctx, database, item.ID, location.ID, -5, "too much", nil)
+	err := AdjustInventory(ctx, database, item.ID, location.ID, -5, false, "too much", nil)
 	if err == nil {
 		t.Error("expected error for negative result")
 	}
 }
 
+func TestSetStock(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
+	location, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+
+	// Set up from nothing.
+	prev, err := SetStock(ctx, database, item.ID, location.ID, 8, false, nil)
+	if err != nil {
+		t.Fatalf("SetStock: %v", err)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, location.ID); prev != 0 || got != 8 {
+		t.Errorf("expected 0 -> 8, got %d -> %d", prev, got)
+	}
+
+	// Set down, then repeat the same call.
+	for i := 0; i < 2; i++ {
+		if _, err := SetStock(ctx, database, item.ID, location.ID, 3, false, nil); err != nil {
+			t.Fatalf("SetStock: %v", err)
+		}
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, location.ID); got != 3 {
+		t.Errorf("expected 3 after setting down, got %d", got)
+	}
+
+	// Set to zero removes the row.
+	prev, _ = SetStock(ctx, database, item.ID, location.ID, 0, false, nil)
+	inv, _ := ListInventory(ctx, database, 0, "", "")
+	if prev != 3 || len(inv) != 0 {
+		t.Errorf("expected row removed (previous 3), got previous %d and %d rows", prev, len(inv))
+	}
+
+	// 0 -> 8, 8 -> 3 and 3 -> 0 are recorded; the repeated call is not.
+	if n := countAdjustments(t, database, model.AdjustmentStockSet); n != 3 {
+		t.Errorf("expected 3 stock_set adjustments, got %d", n)
+	}
+
+	if _, err := SetStock(ctx, database, item.ID, location.ID, -1, false, nil); err == nil {
+		t.Error("expected error for negative quantity")
+	}
+	if _, err := SetStock(ctx, database, item.ID, 999, 1, false, nil); err == nil {
+		t.Error("expected error for missing owner")
+	}
+	if _, err := SetStock(ctx, database, 999, location.ID, 1, false, nil); err == nil {
+		t.Error("expected error for missing item")
+	}
+}
+
 func TestGetItemDistribution(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	loc1, _ := CreateOwner(ctx, database, "Room A", model.OwnerTypeLocation)
 	loc2, _ := CreateOwner(ctx, database, "Room B", model.OwnerTypeLocation)
 
-	AddStock(ctx, database, item.ID, loc1.ID, 5, nil)
-	AddStock(ctx, database, item.ID, loc2.ID, 3, nil)
+	AddStock(ctx, database, item.ID, loc1.ID, 5, false, nil)
+	AddStock(ctx, database, item.ID, loc2.ID, 3, false, nil)
 
 	dist, _ := GetItemDistribution(ctx, database, item.ID)
 	if len(dist) != 2 {
@@ -143,3 +197,289 @@ func TestGetItemDistribution(t *testing.T) {
 		t.Errorf("expected total 8, got %d", total)
 	}
 }
+
+func TestListInventoryChanges(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	office, _ := CreateOwner(ctx, database, "Office", model.OwnerTypeLocation)
+	shelf, _ := CreateOwner(ctx, database, "Shelf", model.OwnerTypeLocation)
+
+	AddStock(ctx, database, item.ID, storage.ID, 5, false, nil)
+	AddStock(ctx, database, item.ID, shelf.ID, 2, false, nil)
+
+	// Backdate existing rows so they fall before the sync point.
+	database.ExecContext(ctx, `UPDATE inventory SET updated_at = '2020-01-01 00:00:00'`)
+	since := time.Now().Add(-time.Minute)
+
+	changes, err := ListInventoryChanges(ctx, database, since)
+	if err != nil {
+		t.Fatalf("ListInventoryChanges: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected 0 changes before any writes, got %d", len(changes))
+	}
+
+	// Move everything from storage to office: storage row is removed,
+	// office row is created. Shelf is untouched.
+	if _, err := CreateTransfer(ctx, database, item.ID, storage.ID, office.ID, 5, false, "", nil); err != nil {
+		t.Fatalf("CreateTransfer: %v", err)
+	}
+
+	changes, err = ListInventoryChanges(ctx, database, since)
+	if err != nil {
+		t.Fatalf("ListInventoryChanges: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+	byOwner := map[int64]model.InventoryChange{}
+	for _, c := range changes {
+		byOwner[c.OwnerID] = c
+	}
+	if c := byOwner[storage.ID]; !c.Deleted || c.Quantity != 0 {
+		t.Errorf("expected storage tombstone, got %+v", c)
+	}
+	if c := byOwner[office.ID]; c.Deleted || c.Quantity != 5 {
+		t.Errorf("expected office quantity 5, got %+v", c)
+	}
+	if _, ok := byOwner[shelf.ID]; ok {
+		t.Error("expected unchanged shelf row to be omitted")
+	}
+
+	// Re-creating a removed row replaces its tombstone.
+	AddStock(ctx, database, item.ID, storage.ID, 1, false, nil)
+	changes, _ = ListInventoryChanges(ctx, database, since)
+	for _, c := range changes {
+		if c.OwnerID == storage.ID && c.Deleted {
+			t.Error("expected tombstone to be hidden once the row exists again")
+		}
+	}
+}
+
+func TestAddStockBatch(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	widget, _ := CreateItem(ctx, database, "Widget", "", "")
+	gadget, _ := CreateItem(ctx, database, "Gadget", "", "")
+	warehouse, _ := CreateOwner(ctx, database, "Warehouse", model.OwnerTypeLocation)
+	AddStock(ctx, database, widget.ID, warehouse.ID, 3, false, nil)
+
+	results, err := AddStockBatch(ctx, database, warehouse.ID, []model.StockLine{
+		{ItemID: widget.ID, Quantity: 2},
+		{ItemID: gadget.ID, Quantity: 5},
+	}, nil)
+	if err != nil {
+		t.Fatalf("AddStockBatch: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Added != 2 || results[0].Quantity != 5 {
+		t.Errorf("expected widget +2 to 5, got %+v", results[0])
+	}
+	if results[1].Added != 5 || results[1].Quantity != 5 {
+		t.Errorf("expected gadget +5 to 5, got %+v", results[1])
+	}
+	if n := countAdjustments(t, database, model.AdjustmentStockAdded); n != 2 {
+		t.Errorf("expected 2 stock_added adjustments, got %d", n)
+	}
+}
+
+func countAdjustments(t *testing.T, database *sql.DB, kind string) int {
+	t.Helper()
+	var n int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM inventory_adjustments WHERE kind = ?`, kind).Scan(&n); err != nil {
+		t.Fatalf("counting adjustments: %v", err)
+	}
+	return n
+}
+
+func TestAddStockBatchRollsBackOnFailure(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	widget, _ := CreateItem(ctx, database, "Widget", "", "")
+	gadget, _ := CreateItem(ctx, database, "Gadget", "", "")
+	warehouse, _ := CreateOwner(ctx, database, "Warehouse", model.OwnerTypeLocation)
+
+	_, err := AddStockBatch(ctx, database, warehouse.ID, []model.StockLine{
+		{ItemID: widget.ID, Quantity: 2},
+		{ItemID: 9999, Quantity: 1},
+		{ItemID: gadget.ID, Quantity: 5},
+	}, nil)
+	var lineErr *StockLineError
+	if !errors.As(err, &lineErr) {
+		t.Fatalf("expected StockLineError, got %v", err)
+	}
+	if lineErr.Line != 1 || lineErr.ItemID != 9999 {
+		t.Errorf("expected failure on line 1 (item 9999), got line %d (item %d)", lineErr.Line, lineErr.ItemID)
+	}
+
+	inv, _ := ListInventory(ctx, database, 0, "", "")
+	if len(inv) != 0 {
+		t.Errorf("expected no inventory after rollback, got %v", inv)
+	}
+	if n := countAdjustments(t, database, model.AdjustmentStockAdded); n != 0 {
+		t.Errorf("expected no adjustments after rollback, got %d", n)
+	}
+
+	_, err = AddStockBatch(ctx, database, warehouse.ID, []model.StockLine{
+		{ItemID: widget.ID, Quantity: 0},
+	}, nil)
+	if !errors.As(err, &lineErr) || lineErr.Line != 0 {
+		t.Errorf("expected line 0 failure for zero quantity, got %v", err)
+	}
+
+	if _, err := AddStockBatch(ctx, database, 9999, []model.StockLine{{ItemID: widget.ID, Quantity: 1}}, nil); err == nil {
+		t.Error("expected error for missing owner")
+	}
+}
+
+func TestGetHeldQuantity(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	office, _ := CreateOwner(ctx, database, "Office", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, storage.ID, 7, false, nil)
+
+	if got, err := GetHeldQuantity(ctx, database, item.ID, storage.ID); err != nil || got != 7 {
+		t.Errorf("expected 7 held by storage, got %d (%v)", got, err)
+	}
+	if got, err := GetHeldQuantity(ctx, database, item.ID, office.ID); err != nil || got != 0 {
+		t.Errorf("expected 0 held by office, got %d (%v)", got, err)
+	}
+}
+
+func TestListInventoryPage(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	widget, _ := CreateItem(ctx, database, "Widget", "", "")
+	gadget, _ := CreateItem(ctx, database, "Gadget", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	alice, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	for _, item := range []int64{widget.ID, gadget.ID} {
+		AddStock(ctx, database, item, storage.ID, 3, false, nil)
+		AddStock(ctx, database, item, alice.ID, 1, false, nil)
+	}
+
+	page, total, err := ListInventoryPage(ctx, database, 0, "", "", 3, 0)
+	if err != nil {
+		t.Fatalf("ListInventoryPage: %v", err)
+	}
+	if total != 4 || len(page) != 3 {
+		t.Fatalf("expected 3 of 4 rows, got %d of %d", len(page), total)
+	}
+	if page[0].ItemName != "Gadget" || page[0].OwnerName != "Alice" {
+		t.Errorf("expected Gadget/Alice first, got %s/%s", page[0].ItemName, page[0].OwnerName)
+	}
+	last, _, _ := ListInventoryPage(ctx, database, 0, "", "", 3, 3)
+	if len(last) != 1 || last[0].ItemName != "Widget" || last[0].OwnerName != "Storage" {
+		t.Errorf("expected only Widget/Storage on the last page, got %+v", last)
+	}
+	if beyond, total, _ := ListInventoryPage(ctx, database, 0, "", "", 3, 4); len(beyond) != 0 || total != 4 {
+		t.Errorf("expected an empty page past the end with total 4, got %d of %d", len(beyond), total)
+	}
+
+	tests := []struct {
+		name      string
+		itemID    int64
+		ownerType string
+		query     string
+		want      int
+	}{
+		{"item name", 0, "", "widg", 2},
+		{"owner name", 0, "", "ali", 2},
+		{"no match", 0, "", "100%", 0},
+		{"query and owner type", 0, model.OwnerTypeLocation, "widg", 1},
+		{"query and item", gadget.ID, "", "stor", 1},
+		{"owner type", 0, model.OwnerTypePerson, "", 2},
+	}
+	for _, tt := range tests {
+		rows, total, err := ListInventoryPage(ctx, database, tt.itemID, tt.ownerType, tt.query, 10, 0)
+		if err != nil || total != tt.want || len(rows) != tt.want {
+			t.Errorf("%s: expected %d rows, got %d of %d (%v)", tt.name, tt.want, len(rows), total, err)
+		}
+	}
+	if rows, _ := ListInventory(ctx, database, 0, model.OwnerTypeLocation, "gad"); len(rows) != 1 || rows[0].ItemID != gadget.ID {
+		t.Errorf("expected ListInventory to apply the same filters, got %+v", rows)
+	}
+}
+
+func TestImportStock(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	widget, _ := CreateItem(ctx, database, "Widget", "", "")
+	gadget, _ := CreateItem(ctx, database, "Gadget", "", "")
+	CreateItem(ctx, database, "Cable", "", "")
+	CreateItem(ctx, database, "cable", "", "")
+	warehouse, _ := CreateOwner(ctx, database, "Warehouse", model.OwnerTypeLocation)
+	ana, _ := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	AddStock(ctx, database, widget.ID, warehouse.ID, 3, false, nil)
+
+	valid := []model.StockImportRow{
+		{Line: 2, Item: "widget", Owner: "Warehouse", Quantity: "2"},
+		{Line: 3, Item: fmt.Sprint(gadget.ID), Owner: fmt.Sprint(ana.ID), Quantity: "4"},
+		{Line: 4, Item: "Widget", Owner: "warehouse", Quantity: "1"},
+	}
+
+	results, applied, err := ImportStock(ctx, database, valid, true)
+	if err != nil {
+		t.Fatalf("ImportStock dry run: %v", err)
+	}
+	if applied {
+		t.Error("expected a dry run not to apply")
+	}
+	if len(results) != 3 || results[0].ItemID != widget.ID || results[0].Quantity != 5 || results[2].Quantity != 6 {
+		t.Errorf("expected resolved rows with running quantities, got %+v", results)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, widget.ID, warehouse.ID); got != 3 {
+		t.Errorf("expected a dry run to leave 3 widgets, got %d", got)
+	}
+
+	invalid := []model.StockImportRow{
+		{Line: 2, Item: "Widget", Owner: "Warehouse", Quantity: "2"},
+		{Line: 3, Item: "Sprocket", Owner: "Nobody", Quantity: "1"},
+		{Line: 4, Item: "cable", Owner: "Ana", Quantity: "1"},
+		{Line: 5, Item: "Gadget", Owner: "Ana", Quantity: "0"},
+	}
+	results, applied, err = ImportStock(ctx, database, invalid, false)
+	if err != nil {
+		t.Fatalf("ImportStock: %v", err)
+	}
+	if applied {
+		t.Error("expected an import with unresolved rows not to apply")
+	}
+	if results[0].Error != "" {
+		t.Errorf("expected line 2 to resolve, got %q", results[0].Error)
+	}
+	if !strings.Contains(results[1].Error, "item not found") || !strings.Contains(results[1].Error, "owner not found") {
+		t.Errorf("expected both references unresolved on line 3, got %q", results[1].Error)
+	}
+	if !strings.Contains(results[2].Error, "ambiguous") {
+		t.Errorf("expected an ambiguous item on line 4, got %q", results[2].Error)
+	}
+	if !strings.Contains(results[3].Error, "quantity") {
+		t.Errorf("expected a quantity error on line 5, got %q", results[3].Error)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, widget.ID, warehouse.ID); got != 3 {
+		t.Errorf("expected a failed import to leave 3 widgets, got %d", got)
+	}
+
+	if _, applied, err := ImportStock(ctx, database, valid, false); err != nil || !applied {
+		t.Fatalf("expected the import to apply, got %v %v", applied, err)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, widget.ID, warehouse.ID); got != 6 {
+		t.Errorf("expected 6 widgets, got %d", got)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, gadget.ID, ana.ID); got != 4 {
+		t.Errorf("expected Ana to hold 4 gadgets, got %d", got)
+	}
+}
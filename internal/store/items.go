@@ -2,17 +2,31 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/erazemk/skladisce/internal/model"
 )
 
 // CreateItem creates a new item.
-func CreateItem(ctx context.Context, db *sql.DB, name, description string) (*model.Item, error) {
-	result, err := db.ExecContext(ctx,
-		`INSERT INTO items (name, description) VALUES (?, ?)`,
-		name, description,
+func CreateItem(ctx context.Context, db *sql.DB, name, description, condition string) (*model.Item, error) {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkQuota(ctx, tx, "items", maxItems); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO items (name, description, condition) VALUES (?, ?, ?)`,
+		name, description, condition,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("creating item: %w", err)
@@ -23,17 +37,21 @@ func CreateItem(ctx context.Context, db *sql.DB, name, description string) (*mod
 		return nil, fmt.Errorf("getting item id: %w", err)
 	}
 
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing item creation: %w", err)
+	}
 	return GetItem(ctx, db, id)
 }
 
 // GetItem returns an item by ID.
 func GetItem(ctx context.Context, db *sql.DB, id int64) (*model.Item, error) {
 	item := &model.Item{}
-	var description, imageMime sql.NullString
+	var description, condition, imageMime, deleteReason sql.NullString
 	err := db.QueryRowContext(ctx,
-		`SELECT id, name, description, image_mime, status, created_at, updated_at, deleted_at
+		`SELECT id, name, description, condition, min_quantity, reorder_target, serialized, divisible, image_mime, status, created_at, updated_at, deleted_at, deleted_by, delete_reason
 		 FROM items WHERE id = ?`, id,
-	).Scan(&item.ID, &item.Name, &description, &imageMime, &item.Status, &item.CreatedAt, &item.UpdatedAt, &item.DeletedAt)
+	).Scan(&item.ID, &item.Name, &description, &condition, &item.MinQuantity, &item.ReorderTarget, &item.Serialized, &item.Divisible, &imageMime, &item.Status, &item.CreatedAt, &item.UpdatedAt, &item.DeletedAt,
+		&item.DeletedBy, &deleteReason)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -41,64 +59,423 @@ func GetItem(ctx context.Context, db *sql.DB, id int64) (*model.Item, error) {
 		return nil, fmt.Errorf("getting item: %w", err)
 	}
 	item.Description = description.String
+	item.Condition = condition.String
 	item.ImageMime = imageMime.String
+	item.DeleteReason = deleteReason.String
 	return item, nil
 }
 
-// ListItems returns all non-deleted items, optionally filtered by status.
-func ListItems(ctx context.Context, db *sql.DB, status string) ([]model.Item, error) {
-	var rows *sql.Rows
-	var err error
+// ListItems returns all non-deleted items matching filter, ordered by name.
+func ListItems(ctx context.Context, db *sql.DB, filter model.ItemFilter) ([]model.Item, error) {
+	q := `SELECT id, name, description, condition, min_quantity, reorder_target, serialized, divisible, image_mime, status, created_at, updated_at, deleted_at
+	      FROM items WHERE deleted_at IS NULL`
+	q, args := itemFilterClause(q, nil, filter)
+	q += ` ORDER BY name`
 
-	if status != "" {
-		rows, err = db.QueryContext(ctx,
-			`SELECT id, name, description, image_mime, status, created_at, updated_at, deleted_at
-			 FROM items WHERE deleted_at IS NULL AND status = ? ORDER BY name`, status,
-		)
-	} else {
-		rows, err = db.QueryContext(ctx,
-			`SELECT id, name, description, image_mime, status, created_at, updated_at, deleted_at
-			 FROM items WHERE deleted_at IS NULL ORDER BY name`,
-		)
-	}
+	rows, err := db.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("listing items: %w", err)
 	}
 	defer rows.Close()
 
+	return scanItems(rows)
+}
+
+// SearchItems returns non-deleted items whose name, description or condition
+// contains query (case-insensitive) and that match filter, ordered by name.
+func SearchItems(ctx context.Context, db *sql.DB, query string, filter model.ItemFilter, limit int) ([]model.Item, error) {
+	q := `SELECT id, name, description, condition, min_quantity, reorder_target, serialized, divisible, image_mime, status, created_at, updated_at, deleted_at
+	      FROM items
+	      WHERE deleted_at IS NULL
+	        AND (name LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\' OR condition LIKE ? ESCAPE '\')`
+	pattern := "%" + escapeLike(query) + "%"
+	q, args := itemFilterClause(q, []any{pattern, pattern, pattern}, filter)
+	q += ` ORDER BY name LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching items: %w", err)
+	}
+	defer rows.Close()
+
+	return scanItems(rows)
+}
+
+// GetItems returns the non-deleted items among ids, in one query, ordered by
+// ID. IDs that match nothing are left out.
+func GetItems(ctx context.Context, db *sql.DB, ids []int64) ([]model.Item, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, name, description, condition, min_quantity, reorder_target, serialized, divisible, image_mime, status, created_at, updated_at, deleted_at
+		 FROM items WHERE deleted_at IS NULL AND id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) ORDER BY id`,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting items: %w", err)
+	}
+	defer rows.Close()
+
+	return scanItems(rows)
+}
+
+// SuggestItems returns up to limit non-deleted items whose name contains
+// query (case-insensitive), ordered by name, in the compact form used by
+// pickers.
+func SuggestItems(ctx context.Context, db *sql.DB, query string, limit int) ([]model.ItemSuggestion, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, name, image IS NOT NULL FROM items
+		 WHERE deleted_at IS NULL AND name LIKE ? ESCAPE '\'
+		 ORDER BY name LIMIT ?`,
+		"%"+escapeLike(query)+"%", limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("suggesting items: %w", err)
+	}
+	defer rows.Close()
+
+	var suggestions []model.ItemSuggestion
+	for rows.Next() {
+		var s model.ItemSuggestion
+		if err := rows.Scan(&s.ID, &s.Name, &s.HasImage); err != nil {
+			return nil, fmt.Errorf("scanning item suggestion: %w", err)
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
+
+// itemFilterClause appends the conditions of filter to q and args.
+func itemFilterClause(q string, args []any, filter model.ItemFilter) (string, []any) {
+	if filter.Status != "" {
+		q += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.HasImage != nil {
+		if *filter.HasImage {
+			q += ` AND image IS NOT NULL`
+		} else {
+			q += ` AND image IS NULL`
+		}
+	}
+	return q, args
+}
+
+// scanItems scans rows of id, name, description, condition, min_quantity,
+// reorder_target, serialized, divisible, image_mime, status, created_at, updated_at and
+// deleted_at.
+func scanItems(rows *sql.Rows) ([]model.Item, error) {
 	var items []model.Item
 	for rows.Next() {
 		var item model.Item
-		var description, imageMime sql.NullString
-		if err := rows.Scan(&item.ID, &item.Name, &description, &imageMime, &item.Status, &item.CreatedAt, &item.UpdatedAt, &item.DeletedAt); err != nil {
+		var description, condition, imageMime sql.NullString
+		if err := rows.Scan(&item.ID, &item.Name, &description, &condition, &item.MinQuantity, &item.ReorderTarget, &item.Serialized, &item.Divisible, &imageMime, &item.Status, &item.CreatedAt, &item.UpdatedAt, &item.DeletedAt); err != nil {
 			return nil, fmt.Errorf("scanning item: %w", err)
 		}
 		item.Description = description.String
+		item.Condition = condition.String
 		item.ImageMime = imageMime.String
 		items = append(items, item)
 	}
 	return items, rows.Err()
 }
 
-// UpdateItem updates an item's metadata.
-func UpdateItem(ctx context.Context, db *sql.DB, id int64, name, description, status string) error {
-	_, err := db.ExecContext(ctx,
-		`UPDATE items SET name = ?, description = ?, status = ?, updated_at = CURRENT_TIMESTAMP
+// UpdateItem updates an item's metadata. The status must be one of
+// ListStatuses. If it changes, a row is recorded in status_changes with the
+// given reason and user in the same transaction.
+func UpdateItem(ctx context.Context, db *sql.DB, id int64, name, description, condition, status, reason string, userID *int64) error {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldStatus string
+	var divisible bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, divisible FROM items WHERE id = ? AND deleted_at IS NULL`, id,
+	).Scan(&oldStatus, &divisible)
+	if err == sql.ErrNoRows {
+		return invalidf("updating item: item not found")
+	}
+	if err != nil {
+		return fmt.Errorf("checking item status: %w", err)
+	}
+	if err := requireStatus(ctx, tx, status); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE items SET name = ?, description = ?, condition = ?, status = ?, updated_at = CURRENT_TIMESTAMP
 		 WHERE id = ? AND deleted_at IS NULL`,
-		name, description, status, id,
+		name, description, condition, status, id,
 	)
 	if err != nil {
 		return fmt.Errorf("updating item: %w", err)
 	}
+
+	if oldStatus != status {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO status_changes (item_id, from_status, to_status, reason, user_id)
+			 VALUES (?, ?, ?, ?, ?)`,
+			id, oldStatus, status, reason, userID,
+		)
+		if err != nil {
+			return fmt.Errorf("recording status change: %w", err)
+		}
+	}
+
+	if err := commit(tx); err != nil {
+		return fmt.Errorf("committing item update: %w", err)
+	}
 	return nil
 }
 
-// DeleteItem soft-deletes an item.
-// Returns an error if the item does not exist or is already deleted.
-func DeleteItem(ctx context.Context, db *sql.DB, id int64) error {
-	result, err := db.ExecContext(ctx,
-		`UPDATE items SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`,
-		id,
+// DecommissionItem retires an item in one transaction: every owner's stock is
+// removed and recorded as a decommissioned adjustment, the serials of a
+// serialized item are dropped, and the status is set to removed (recording
+// the status change if it was different). Returns an error if the item does
+// not exist or is deleted.
+func DecommissionItem(ctx context.Context, db *sql.DB, id int64, reason string, userID *int64) (*model.DecommissionResult, error) {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldStatus string
+	var divisible bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, divisible FROM items WHERE id = ? AND deleted_at IS NULL`, id,
+	).Scan(&oldStatus, &divisible)
+	if err == sql.ErrNoRows {
+		return nil, invalidf("item not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking item status: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT inv.owner_id, o.name, inv.quantity
+		 FROM inventory inv JOIN owners o ON o.id = inv.owner_id
+		 WHERE inv.item_id = ? ORDER BY o.name, inv.owner_id`, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing item inventory: %w", err)
+	}
+	adjustments := []model.Adjustment{}
+	for rows.Next() {
+		a := model.Adjustment{ItemID: id, Kind: model.AdjustmentDecommissioned, Reason: reason, UserID: userID, Divisible: divisible}
+		var quantity int
+		if err := rows.Scan(&a.OwnerID, &a.OwnerName, &quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning inventory: %w", err)
+		}
+		a.Delta = -quantity
+		adjustments = append(adjustments, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing item inventory: %w", err)
+	}
+
+	for i := range adjustments {
+		a := &adjustments[i]
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO inventory_adjustments (item_id, owner_id, delta, kind, reason, user_id)
+			 VALUES (?, ?, ?, ?, NULLIF(?, ''), ?) RETURNING id, created_at`,
+			id, a.OwnerID, a.Delta, a.Kind, reason, userID,
+		).Scan(&a.ID, &a.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("recording adjustment: %w", err)
+		}
+		if err := removeInventoryRow(ctx, tx, id, a.OwnerID); err != nil {
+			return nil, fmt.Errorf("removing inventory: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM serials WHERE item_id = ?`, id); err != nil {
+		return nil, fmt.Errorf("removing serials: %w", err)
+	}
+
+	if oldStatus != model.ItemStatusRemoved {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE items SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			model.ItemStatusRemoved, id,
+		); err != nil {
+			return nil, fmt.Errorf("updating item status: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO status_changes (item_id, from_status, to_status, reason, user_id)
+			 VALUES (?, ?, ?, ?, ?)`,
+			id, oldStatus, model.ItemStatusRemoved, reason, userID,
+		); err != nil {
+			return nil, fmt.Errorf("recording status change: %w", err)
+		}
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing decommission: %w", err)
+	}
+
+	item, err := GetItem(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	return &model.DecommissionResult{Item: item, Adjustments: adjustments}, nil
+}
+
+// GetItemStatusHistory returns status changes for an item, newest first.
+func GetItemStatusHistory(ctx context.Context, db *sql.DB, itemID int64) ([]model.StatusChange, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT sc.id, sc.item_id, sc.from_status, sc.to_status, sc.reason, sc.user_id, sc.created_at,
+		        COALESCE(u.username, '') AS username
+		 FROM status_changes sc
+		 LEFT JOIN users u ON u.id = sc.user_id
+		 WHERE sc.item_id = ?
+		 ORDER BY sc.created_at DESC, sc.id DESC`, itemID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting item status history: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []model.StatusChange
+	for rows.Next() {
+		var sc model.StatusChange
+		var reason sql.NullString
+		if err := rows.Scan(&sc.ID, &sc.ItemID, &sc.FromStatus, &sc.ToStatus, &reason, &sc.UserID, &sc.CreatedAt, &sc.Username); err != nil {
+			return nil, fmt.Errorf("scanning status change: %w", err)
+		}
+		sc.Reason = reason.String
+		changes = append(changes, sc)
+	}
+	return changes, rows.Err()
+}
+
+// GetItemChangelog returns an item's creation, status changes, transfers and
+// deletion merged into one timeline, newest first. Events with the same
+// timestamp are ordered so that creation sorts last and deletion first. A
+// negative limit returns every entry.
+func GetItemChangelog(ctx context.Context, db *sql.DB, itemID int64, limit, offset int) ([]model.ItemChangelogEntry, error) {
+	var divisible bool
+	err := db.QueryRowContext(ctx, `SELECT divisible FROM items WHERE id = ?`, itemID).Scan(&divisible)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("checking item: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT 'created' AS type, i.created_at AS at, 0 AS rank, i.id AS ref_id,
+		        NULL AS user_id, '' AS username, NULL AS from_status, NULL AS to_status, NULL AS reason,
+		        NULL AS from_owner_id, NULL AS from_owner_name, NULL AS to_owner_id, NULL AS to_owner_name,
+		        NULL AS quantity, NULL AS notes
+		 FROM items i WHERE i.id = ?
+		 UNION ALL
+		 SELECT 'status_changed', sc.created_at, 1, sc.id,
+		        sc.user_id, COALESCE(u.username, ''), sc.from_status, sc.to_status, sc.reason,
+		        NULL, NULL, NULL, NULL, NULL, NULL
+		 FROM status_changes sc
+		 LEFT JOIN users u ON u.id = sc.user_id
+		 WHERE sc.item_id = ?
+		 UNION ALL
+		 SELECT 'transfer', t.transferred_at, 1, t.id,
+		        t.transferred_by, COALESCE(u.username, ''), NULL, NULL, NULL,
+		        t.from_owner_id, fo.name, t.to_owner_id, too.name, t.quantity, t.notes
+		 FROM transfers t
+		 JOIN owners fo ON fo.id = t.from_owner_id
+		 JOIN owners too ON too.id = t.to_owner_id
+		 LEFT JOIN users u ON u.id = t.transferred_by
+		 WHERE t.item_id = ?
+		 UNION ALL
+		 SELECT 'decommissioned', a.created_at, 1, a.id,
+		        a.user_id, COALESCE(u.username, ''), NULL, NULL, a.reason,
+		        a.owner_id, o.name, NULL, NULL, a.delta, NULL
+		 FROM inventory_adjustments a
+		 JOIN owners o ON o.id = a.owner_id
+		 LEFT JOIN users u ON u.id = a.user_id
+		 WHERE a.item_id = ? AND a.kind = 'decommissioned'
+		 UNION ALL
+		 SELECT 'deleted', i.deleted_at, 2, i.id,
+		        i.deleted_by, COALESCE(u.username, ''), NULL, NULL, i.delete_reason,
+		        NULL, NULL, NULL, NULL, NULL, NULL
+		 FROM items i
+		 LEFT JOIN users u ON u.id = i.deleted_by
+		 WHERE i.id = ? AND i.deleted_at IS NOT NULL
+		 ORDER BY at DESC, rank DESC, ref_id DESC
+		 LIMIT ? OFFSET ?`,
+		itemID, itemID, itemID, itemID, itemID, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting item changelog: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.ItemChangelogEntry
+	for rows.Next() {
+		var e model.ItemChangelogEntry
+		var rank int
+		var refID int64
+		var fromStatus, toStatus, reason, fromOwnerName, toOwnerName, notes sql.NullString
+		var fromOwnerID, toOwnerID, quantity sql.NullInt64
+		if err := rows.Scan(&e.Type, &e.At, &rank, &refID,
+			&e.UserID, &e.Username, &fromStatus, &toStatus, &reason,
+			&fromOwnerID, &fromOwnerName, &toOwnerID, &toOwnerName, &quantity, &notes); err != nil {
+			return nil, fmt.Errorf("scanning changelog entry: %w", err)
+		}
+
+		switch e.Type {
+		case model.ChangelogStatusChanged:
+			e.Changes = map[string]model.FieldChange{
+				"status": {From: fromStatus.String, To: toStatus.String},
+			}
+			e.Reason = reason.String
+		case model.ChangelogDeleted:
+			e.Reason = reason.String
+		case model.ChangelogTransfer:
+			e.Transfer = &model.Transfer{
+				ID:            refID,
+				ItemID:        itemID,
+				FromOwnerID:   fromOwnerID.Int64,
+				ToOwnerID:     toOwnerID.Int64,
+				Quantity:      int(quantity.Int64),
+				Notes:         notes.String,
+				TransferredAt: e.At,
+				TransferredBy: e.UserID,
+				FromOwnerName: fromOwnerName.String,
+				ToOwnerName:   toOwnerName.String,
+				Divisible:     divisible,
+			}
+		case model.ChangelogDecommissioned:
+			e.Reason = reason.String
+			e.Adjustment = &model.Adjustment{
+				ID:        refID,
+				ItemID:    itemID,
+				OwnerID:   fromOwnerID.Int64,
+				OwnerName: fromOwnerName.String,
+				Delta:     int(quantity.Int64),
+				Kind:      model.AdjustmentDecommissioned,
+				Reason:    reason.String,
+				UserID:    e.UserID,
+				CreatedAt: e.At,
+				Divisible: divisible,
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteItem soft-deletes an item, recording who deleted it and an optional
+// reason. Returns an error if the item does not exist or is already deleted.
+func DeleteItem(ctx context.Context, db *sql.DB, id int64, userID *int64, reason string) error {
+	result, err := exec(ctx, db,
+		`UPDATE items SET deleted_at = CURRENT_TIMESTAMP, deleted_by = ?, delete_reason = NULLIF(?, '')
+		 WHERE id = ? AND deleted_at IS NULL`,
+		userID, reason, id,
 	)
 	if err != nil {
 		return fmt.Errorf("deleting item: %w", err)
@@ -108,31 +485,150 @@ func DeleteItem(ctx context.Context, db *sql.DB, id int64) error {
 		return fmt.Errorf("checking rows affected: %w", err)
 	}
 	if n == 0 {
-		return fmt.Errorf("deleting item: item not found")
+		return invalidf("deleting item: item not found")
 	}
 	return nil
 }
 
-// SetItemImage sets an item's image data.
-func SetItemImage(ctx context.Context, db *sql.DB, id int64, image []byte, mime string) error {
-	_, err := db.ExecContext(ctx,
-		`UPDATE items SET image = ?, image_mime = ?, updated_at = CURRENT_TIMESTAMP
-		 WHERE id = ? AND deleted_at IS NULL`,
-		image, mime, id,
+// RestoreItem undoes a soft delete, clearing the deletion fields. Returns an
+// error if the item does not exist or is not deleted.
+func RestoreItem(ctx context.Context, db *sql.DB, id int64) error {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkQuota(ctx, tx, "items", maxItems); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE items SET deleted_at = NULL, deleted_by = NULL, delete_reason = NULL
+		 WHERE id = ? AND deleted_at IS NOT NULL`,
+		id,
 	)
 	if err != nil {
-		return fmt.Errorf("setting item image: %w", err)
+		return fmt.Errorf("restoring item: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if n == 0 {
+		return invalidf("restoring item: deleted item not found")
+	}
+
+	if err := commit(tx); err != nil {
+		return fmt.Errorf("committing item restore: %w", err)
 	}
 	return nil
 }
 
-// GetItemImage returns an item's image data and MIME type.
-func GetItemImage(ctx context.Context, db *sql.DB, id int64) ([]byte, string, error) {
+// copySuffix is appended to the name of a cloned item.
+const copySuffix = " (copy)"
+
+// CloneItem creates a new active item with the source item's description,
+// condition, serialized and divisible flags, low-stock threshold and reorder
+// target and, if withImage is set, its image. The name gets copySuffix, shortening the original if needed to stay
+// within model.MaxNameLength. Inventory, serials and history are not copied.
+// Returns nil if the source does not exist or is deleted.
+func CloneItem(ctx context.Context, db *sql.DB, id int64, withImage bool) (*model.Item, error) {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var name string
+	var description, condition, imageMime sql.NullString
+	var image []byte
+	var serialized, divisible bool
+	var minQuantity, reorderTarget sql.NullInt64
+	err = tx.QueryRowContext(ctx,
+		`SELECT name, description, condition, serialized, divisible, min_quantity, reorder_target, image, image_mime
+		 FROM items WHERE id = ? AND deleted_at IS NULL`, id,
+	).Scan(&name, &description, &condition, &serialized, &divisible, &minQuantity, &reorderTarget, &image, &imageMime)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting item to clone: %w", err)
+	}
+	if !withImage {
+		image, imageMime = nil, sql.NullString{}
+	}
+
+	if err := checkQuota(ctx, tx, "items", maxItems); err != nil {
+		return nil, err
+	}
+
+	if runes := []rune(name); len(runes)+len(copySuffix) > model.MaxNameLength {
+		name = strings.TrimSpace(string(runes[:model.MaxNameLength-len(copySuffix)]))
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO items (name, description, condition, serialized, divisible, min_quantity, reorder_target,
+		                    image, image_mime, image_hash)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		name+copySuffix, description, condition, serialized, divisible, minQuantity, reorderTarget,
+		image, imageMime, imageHash(image),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cloning item: %w", err)
+	}
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("getting item id: %w", err)
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing item clone: %w", err)
+	}
+	return GetItem(ctx, db, newID)
+}
+
+// imageHash returns the hex SHA-256 of an image, stored in items.image_hash,
+// or NULL for no image.
+func imageHash(image []byte) sql.NullString {
+	if image == nil {
+		return sql.NullString{}
+	}
+	sum := sha256.Sum256(image)
+	return sql.NullString{String: hex.EncodeToString(sum[:]), Valid: true}
+}
+
+// SetItemImage sets an item's image data. If the item already has exactly
+// this image, nothing is written and updated_at is left alone. It reports
+// whether the image was written, which is also false for a missing or
+// deleted item.
+func SetItemImage(ctx context.Context, db *sql.DB, id int64, image []byte, mime string) (bool, error) {
+	hash := imageHash(image)
+	result, err := exec(ctx, db,
+		`UPDATE items SET image = ?, image_mime = ?, image_hash = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND deleted_at IS NULL AND image_hash IS NOT ?`,
+		image, mime, hash, id, hash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("setting item image: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("setting item image: %w", err)
+	}
+	return n > 0, nil
+}
+
+// GetItemImage returns an item's image data and MIME type. Soft-deleted items
+// are treated as missing unless includeDeleted is set.
+func GetItemImage(ctx context.Context, db *sql.DB, id int64, includeDeleted bool) ([]byte, string, error) {
+	query := `SELECT image, image_mime FROM items WHERE id = ? AND deleted_at IS NULL`
+	if includeDeleted {
+		query = `SELECT image, image_mime FROM items WHERE id = ?`
+	}
+
 	var image []byte
 	var mime sql.NullString
-	err := db.QueryRowContext(ctx,
-		`SELECT image, image_mime FROM items WHERE id = ?`, id,
-	).Scan(&image, &mime)
+	err := db.QueryRowContext(ctx, query, id).Scan(&image, &mime)
 	if err == sql.ErrNoRows {
 		return nil, "", nil
 	}
@@ -142,12 +638,52 @@ func GetItemImage(ctx context.Context, db *sql.DB, id int64) ([]byte, string, er
 	return image, mime.String, nil
 }
 
+// ListItemImageIDs returns the IDs of all items with an image, including
+// soft-deleted ones, in ID order.
+func ListItemImageIDs(ctx context.Context, db *sql.DB) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id FROM items WHERE image IS NOT NULL ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("listing item images: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning item image id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ReplaceItemImage swaps an item's image for a reprocessed copy, but only if
+// the stored image is still old, so an upload made meanwhile is not
+// overwritten. It reports whether the image was replaced. The item's
+// updated_at is left alone, since its content has not changed.
+func ReplaceItemImage(ctx context.Context, db *sql.DB, id int64, old, image []byte, mime string) (bool, error) {
+	result, err := exec(ctx, db,
+		`UPDATE items SET image = ?, image_mime = ?, image_hash = ? WHERE id = ? AND image = ?`,
+		image, mime, imageHash(image), id, old,
+	)
+	if err != nil {
+		return false, fmt.Errorf("replacing item image: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("replacing item image: %w", err)
+	}
+	return n > 0, nil
+}
+
 // GetItemHistory returns transfer history for an item.
 func GetItemHistory(ctx context.Context, db *sql.DB, itemID int64) ([]model.Transfer, error) {
 	rows, err := db.QueryContext(ctx,
 		`SELECT t.id, t.item_id, t.from_owner_id, t.to_owner_id, t.quantity, t.notes,
 		        t.transferred_at, t.transferred_by,
-		        i.name AS item_name, fo.name AS from_owner_name, too.name AS to_owner_name
+		        i.name AS item_name, fo.name AS from_owner_name, fo.type AS from_owner_type,
+		        too.name AS to_owner_name, too.type AS to_owner_type, i.divisible
 		 FROM transfers t
 		 JOIN items i ON i.id = t.item_id
 		 JOIN owners fo ON fo.id = t.from_owner_id
@@ -162,3 +698,117 @@ func GetItemHistory(ctx context.Context, db *sql.DB, itemID int64) ([]model.Tran
 
 	return scanTransfers(rows)
 }
+
+// ItemDivisible reports whether an item is divisible, i.e. whether its
+// quantities are stored in thousandths (see model.QuantityScale). A missing
+// item is reported as not divisible and left to the caller's own checks.
+func ItemDivisible(ctx context.Context, db *sql.DB, id int64) (bool, error) {
+	var divisible bool
+	err := db.QueryRowContext(ctx, `SELECT divisible FROM items WHERE id = ?`, id).Scan(&divisible)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("checking item: %w", err)
+	}
+	return divisible, nil
+}
+
+// itemDivisible is ItemDivisible inside a transaction.
+func itemDivisible(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	var divisible bool
+	err := tx.QueryRowContext(ctx, `SELECT divisible FROM items WHERE id = ?`, id).Scan(&divisible)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("checking item: %w", err)
+	}
+	return divisible, nil
+}
+
+// ErrDivisibleChanged is returned when an item's divisible flag no longer
+// matches the one a quantity was converted under, because it was switched in
+// between. The quantity would be off by a factor of model.QuantityScale, so
+// the caller has to convert it again.
+var ErrDivisibleChanged = errors.New("item's divisible setting changed: resend the quantity")
+
+// checkDivisible returns a ValidationError wrapping ErrDivisibleChanged if the
+// item's divisible flag is not divisible. Writes that take a quantity call it
+// inside their transaction, so a concurrent SetItemDivisible cannot rescale
+// the item between the check and the write.
+func checkDivisible(ctx context.Context, tx *sql.Tx, itemID int64, divisible bool) error {
+	current, err := itemDivisible(ctx, tx, itemID)
+	if err != nil {
+		return err
+	}
+	if current != divisible {
+		return &ValidationError{Err: ErrDivisibleChanged}
+	}
+	return nil
+}
+
+// divisibleColumns are the columns holding an item's quantities, rescaled
+// when its divisible flag changes.
+var divisibleColumns = []struct{ table, column, key string }{
+	{"inventory", "quantity", "item_id"},
+	{"transfers", "quantity", "item_id"},
+	{"inventory_adjustments", "delta", "item_id"},
+	{"stock_snapshot_lines", "quantity", "item_id"},
+	{"items", "min_quantity", "id"},
+	{"items", "reorder_target", "id"},
+}
+
+// SetItemDivisible switches decimal quantities on or off for an item.
+// Switching on converts its stock, history, stock snapshots and minimum
+// quantity to thousandths; switching off converts them back and is refused while any of
+// them is fractional. Serialized items cannot be divisible.
+func SetItemDivisible(ctx context.Context, db *sql.DB, id int64, divisible bool) error {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current, serialized bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT divisible, serialized FROM items WHERE id = ? AND deleted_at IS NULL`, id,
+	).Scan(&current, &serialized)
+	if err == sql.ErrNoRows {
+		return invalidf("item not found")
+	}
+	if err != nil {
+		return fmt.Errorf("checking item: %w", err)
+	}
+	if current == divisible {
+		return nil
+	}
+	if serialized {
+		return invalidf("serialized items cannot be divisible")
+	}
+
+	for _, c := range divisibleColumns {
+		update := `UPDATE ` + c.table + ` SET ` + c.column + ` = ` + c.column + ` * ? WHERE ` + c.key + ` = ?`
+		if !divisible {
+			var fractional bool
+			err := tx.QueryRowContext(ctx,
+				`SELECT EXISTS (SELECT 1 FROM `+c.table+` WHERE `+c.key+` = ? AND `+c.column+` % ? != 0)`,
+				id, model.QuantityScale,
+			).Scan(&fractional)
+			if err != nil {
+				return fmt.Errorf("checking %s: %w", c.table, err)
+			}
+			if fractional {
+				return invalidf("item has fractional quantities in %s", c.table)
+			}
+			update = `UPDATE ` + c.table + ` SET ` + c.column + ` = ` + c.column + ` / ? WHERE ` + c.key + ` = ?`
+		}
+		if _, err := tx.ExecContext(ctx, update, model.QuantityScale, id); err != nil {
+			return fmt.Errorf("rescaling %s: %w", c.table, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE items SET divisible = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, divisible, id,
+	); err != nil {
+		return fmt.Errorf("setting item divisible: %w", err)
+	}
+	if err := commit(tx); err != nil {
+		return fmt.Errorf("committing divisible change: %w", err)
+	}
+	return nil
+}
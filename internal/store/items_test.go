@@ -2,7 +2,11 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/erazemk/skladisce/internal/db"
 	"github.com/erazemk/skladisce/internal/model"
@@ -12,7 +16,7 @@ func TestCreateAndGetItem(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, err := CreateItem(ctx, database, "Laptop", "Dell XPS 15")
+	item, err := CreateItem(ctx, database, "Laptop", "Dell XPS 15", "")
 	if err != nil {
 		t.Fatalf("CreateItem: %v", err)
 	}
@@ -24,33 +28,114 @@ func TestCreateAndGetItem(t *testing.T) {
 	}
 }
 
+func TestGetItems(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	tent, _ := CreateItem(ctx, database, "Tent", "", "")
+	stove, _ := CreateItem(ctx, database, "Stove", "", "")
+	gone, _ := CreateItem(ctx, database, "Lantern", "", "")
+	DeleteItem(ctx, database, gone.ID, nil, "")
+
+	items, err := GetItems(ctx, database, []int64{stove.ID, 9999, gone.ID, tent.ID})
+	if err != nil {
+		t.Fatalf("GetItems: %v", err)
+	}
+	if len(items) != 2 || items[0].ID != tent.ID || items[1].ID != stove.ID {
+		t.Errorf("expected Tent and Stove by ID, got %+v", items)
+	}
+	if items, err := GetItems(ctx, database, nil); err != nil || len(items) != 0 {
+		t.Errorf("expected nothing for no IDs, got %v, %v", items, err)
+	}
+}
+
 func TestListItemsByStatus(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	CreateItem(ctx, database, "Active Item", "")
-	item2, _ := CreateItem(ctx, database, "Damaged Item", "")
-	UpdateItem(ctx, database, item2.ID, "Damaged Item", "", model.ItemStatusDamaged)
+	CreateItem(ctx, database, "Active Item", "", "")
+	item2, _ := CreateItem(ctx, database, "Damaged Item", "", "")
+	UpdateItem(ctx, database, item2.ID, "Damaged Item", "", "", model.ItemStatusDamaged, "", nil)
 
-	all, _ := ListItems(ctx, database, "")
+	all, _ := ListItems(ctx, database, model.ItemFilter{})
 	if len(all) != 2 {
 		t.Errorf("expected 2 items, got %d", len(all))
 	}
 
-	active, _ := ListItems(ctx, database, model.ItemStatusActive)
+	active, _ := ListItems(ctx, database, model.ItemFilter{Status: model.ItemStatusActive})
 	if len(active) != 1 {
 		t.Errorf("expected 1 active item, got %d", len(active))
 	}
 }
 
+func TestItemCondition(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, err := CreateItem(ctx, database, "Cooler", "Blue 20 l cooler", "scratched lid, works fine")
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	if item.Condition != "scratched lid, works fine" {
+		t.Errorf("expected condition to persist on create, got %q", item.Condition)
+	}
+
+	if err := UpdateItem(ctx, database, item.ID, "Cooler", "Blue 20 l cooler", "handle missing", item.Status, "", nil); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	got, _ := GetItem(ctx, database, item.ID)
+	if got.Condition != "handle missing" {
+		t.Errorf("expected updated condition, got %q", got.Condition)
+	}
+	if history, _ := GetItemStatusHistory(ctx, database, item.ID); len(history) != 0 {
+		t.Errorf("expected a condition change not to record a status change, got %d", len(history))
+	}
+
+	clone, _ := CloneItem(ctx, database, item.ID, false)
+	if clone.Condition != "handle missing" {
+		t.Errorf("expected clone to copy condition, got %q", clone.Condition)
+	}
+}
+
+func TestSearchItems(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	CreateItem(ctx, database, "Tent", "Four-person tent", "")
+	CreateItem(ctx, database, "Cooler", "", "Scratched lid")
+	lamp, _ := CreateItem(ctx, database, "Lamp", "", "")
+	UpdateItem(ctx, database, lamp.ID, "Lamp", "", "scratched shade", model.ItemStatusDamaged, "", nil)
+
+	got, err := SearchItems(ctx, database, "SCRATCHED", model.ItemFilter{}, 50)
+	if err != nil {
+		t.Fatalf("SearchItems: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "Cooler" || got[1].Name != "Lamp" {
+		t.Fatalf("expected Cooler and Lamp matching condition, got %v", got)
+	}
+	if got[0].Condition != "Scratched lid" {
+		t.Errorf("expected condition in search results, got %q", got[0].Condition)
+	}
+
+	if byDesc, _ := SearchItems(ctx, database, "person", model.ItemFilter{}, 50); len(byDesc) != 1 || byDesc[0].Name != "Tent" {
+		t.Errorf("expected Tent matching description, got %v", byDesc)
+	}
+	if damaged, _ := SearchItems(ctx, database, "scratched", model.ItemFilter{Status: model.ItemStatusDamaged}, 50); len(damaged) != 1 || damaged[0].Name != "Lamp" {
+		t.Errorf("expected only Lamp for damaged search, got %v", damaged)
+	}
+	if none, _ := SearchItems(ctx, database, "_", model.ItemFilter{}, 50); len(none) != 0 {
+		t.Errorf("expected no items matching literal '_', got %d", len(none))
+	}
+}
+
 func TestSoftDeleteItem(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Delete Me", "")
-	DeleteItem(ctx, database, item.ID)
+	item, _ := CreateItem(ctx, database, "Delete Me", "", "")
+	DeleteItem(ctx, database, item.ID, nil, "")
 
-	items, _ := ListItems(ctx, database, "")
+	items, _ := ListItems(ctx, database, model.ItemFilter{})
 	if len(items) != 0 {
 		t.Errorf("expected 0 items after soft delete, got %d", len(items))
 	}
@@ -62,15 +147,46 @@ func TestSoftDeleteItem(t *testing.T) {
 	}
 }
 
+func TestDeleteAndRestoreItemRecordsAudit(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	user, _ := CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	item, _ := CreateItem(ctx, database, "Drill", "", "")
+
+	if err := DeleteItem(ctx, database, item.ID, &user.ID, "broken beyond repair"); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	got, _ := GetItem(ctx, database, item.ID)
+	if got.DeletedAt == nil || got.DeletedBy == nil || *got.DeletedBy != user.ID || got.DeleteReason != "broken beyond repair" {
+		t.Errorf("expected deletion audit fields, got %+v", got)
+	}
+	entries, _ := GetItemChangelog(ctx, database, item.ID, 10, 0)
+	if len(entries) == 0 || entries[0].Type != model.ChangelogDeleted || entries[0].Username != "manager" || entries[0].Reason != "broken beyond repair" {
+		t.Errorf("expected attributed deletion in changelog, got %+v", entries)
+	}
+
+	if err := RestoreItem(ctx, database, item.ID); err != nil {
+		t.Fatalf("RestoreItem: %v", err)
+	}
+	got, _ = GetItem(ctx, database, item.ID)
+	if got.DeletedAt != nil || got.DeletedBy != nil || got.DeleteReason != "" {
+		t.Errorf("expected deletion fields cleared, got %+v", got)
+	}
+	if items, _ := ListItems(ctx, database, model.ItemFilter{}); len(items) != 1 {
+		t.Errorf("expected restored item to be listed, got %d items", len(items))
+	}
+}
+
 func TestItemImage(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Photo Item", "")
+	item, _ := CreateItem(ctx, database, "Photo Item", "", "")
 	imageData := []byte("fake image data")
 	SetItemImage(ctx, database, item.ID, imageData, "image/png")
 
-	data, mime, err := GetItemImage(ctx, database, item.ID)
+	data, mime, err := GetItemImage(ctx, database, item.ID, false)
 	if err != nil {
 		t.Fatalf("GetItemImage: %v", err)
 	}
@@ -80,4 +196,443 @@ func TestItemImage(t *testing.T) {
 	if mime != "image/png" {
 		t.Errorf("expected mime 'image/png', got %q", mime)
 	}
+
+	// The same image again is not written.
+	if changed, err := SetItemImage(ctx, database, item.ID, imageData, "image/png"); err != nil || changed {
+		t.Errorf("expected the same image to be skipped, got %v, %v", changed, err)
+	}
+	if changed, _ := SetItemImage(ctx, database, item.ID, []byte("other image data"), "image/png"); !changed {
+		t.Error("expected a different image to be written")
+	}
+}
+
+func TestCloneItem(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Drill", "Cordless, 18V", "")
+	SetItemImage(ctx, database, item.ID, []byte("fake image data"), "image/jpeg")
+	owner, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, owner.ID, 4, false, nil)
+	minQuantity, target := 2, 10
+	SetItemMinQuantity(ctx, database, item.ID, &minQuantity)
+	SetItemReorderTarget(ctx, database, item.ID, &target)
+
+	clone, err := CloneItem(ctx, database, item.ID, true)
+	if err != nil {
+		t.Fatalf("CloneItem: %v", err)
+	}
+	if clone.ID == item.ID || clone.Name != "Drill (copy)" || clone.Description != "Cordless, 18V" {
+		t.Errorf("unexpected clone: %+v", clone)
+	}
+	if clone.MinQuantity == nil || *clone.MinQuantity != 2 || clone.ReorderTarget == nil || *clone.ReorderTarget != 10 {
+		t.Errorf("expected threshold 2 and target 10 to be copied, got %v and %v", clone.MinQuantity, clone.ReorderTarget)
+	}
+	if data, mime, _ := GetItemImage(ctx, database, clone.ID, false); string(data) != "fake image data" || mime != "image/jpeg" {
+		t.Errorf("expected image to be copied, got %q %q", data, mime)
+	}
+	if dist, _ := GetItemDistribution(ctx, database, clone.ID); len(dist) != 0 {
+		t.Errorf("expected clone to start with no inventory, got %+v", dist)
+	}
+	if history, _ := GetItemHistory(ctx, database, clone.ID); len(history) != 0 {
+		t.Errorf("expected clone to have no history, got %+v", history)
+	}
+
+	noImage, _ := CloneItem(ctx, database, item.ID, false)
+	if noImage.ImageMime != "" {
+		t.Errorf("expected no image, got %q", noImage.ImageMime)
+	}
+
+	long, _ := CreateItem(ctx, database, strings.Repeat("a", model.MaxNameLength), "", "")
+	longClone, _ := CloneItem(ctx, database, long.ID, false)
+	if n := len([]rune(longClone.Name)); n != model.MaxNameLength || !strings.HasSuffix(longClone.Name, " (copy)") {
+		t.Errorf("expected a %d-character name ending in (copy), got %d: %q", model.MaxNameLength, n, longClone.Name)
+	}
+
+	DeleteItem(ctx, database, item.ID, nil, "")
+	if got, err := CloneItem(ctx, database, item.ID, false); err != nil || got != nil {
+		t.Errorf("expected nil for deleted item, got %+v, %v", got, err)
+	}
+}
+
+func TestItemImageHiddenForDeletedItem(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Photo Item", "", "")
+	SetItemImage(ctx, database, item.ID, []byte("fake image data"), "image/png")
+	DeleteItem(ctx, database, item.ID, nil, "")
+
+	data, _, err := GetItemImage(ctx, database, item.ID, false)
+	if err != nil {
+		t.Fatalf("GetItemImage: %v", err)
+	}
+	if data != nil {
+		t.Error("expected no image for deleted item")
+	}
+
+	data, _, _ = GetItemImage(ctx, database, item.ID, true)
+	if string(data) != "fake image data" {
+		t.Errorf("expected image with includeDeleted, got %q", string(data))
+	}
+}
+
+func TestReplaceItemImage(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	a, _ := CreateItem(ctx, database, "A", "", "")
+	CreateItem(ctx, database, "No image", "", "")
+	c, _ := CreateItem(ctx, database, "C", "", "")
+	SetItemImage(ctx, database, a.ID, []byte("old a"), "image/png")
+	SetItemImage(ctx, database, c.ID, []byte("old c"), "image/png")
+	DeleteItem(ctx, database, c.ID, nil, "")
+
+	ids, err := ListItemImageIDs(ctx, database)
+	if err != nil {
+		t.Fatalf("ListItemImageIDs: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != a.ID || ids[1] != c.ID {
+		t.Errorf("expected [%d %d] including the deleted item, got %v", a.ID, c.ID, ids)
+	}
+
+	if ok, err := ReplaceItemImage(ctx, database, a.ID, []byte("old a"), []byte("new a"), "image/jpeg"); err != nil || !ok {
+		t.Fatalf("ReplaceItemImage: %v, %v", ok, err)
+	}
+	if data, mime, _ := GetItemImage(ctx, database, a.ID, false); string(data) != "new a" || mime != "image/jpeg" {
+		t.Errorf("expected replaced image, got %q %q", data, mime)
+	}
+
+	// The image changed since it was read, so the stale copy is discarded.
+	if ok, _ := ReplaceItemImage(ctx, database, a.ID, []byte("old a"), []byte("stale"), "image/jpeg"); ok {
+		t.Error("expected no replacement when the image changed meanwhile")
+	}
+	if data, _, _ := GetItemImage(ctx, database, a.ID, false); string(data) != "new a" {
+		t.Errorf("expected image to be kept, got %q", data)
+	}
+}
+
+func TestUpdateItemRecordsStatusChange(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	user, _ := CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	item, _ := CreateItem(ctx, database, "Laptop", "", "")
+
+	// Changing only the name does not record a status change.
+	if err := UpdateItem(ctx, database, item.ID, "Laptop 2", "", "", model.ItemStatusActive, "", &user.ID); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	history, _ := GetItemStatusHistory(ctx, database, item.ID)
+	if len(history) != 0 {
+		t.Fatalf("expected no status changes, got %d", len(history))
+	}
+
+	if err := UpdateItem(ctx, database, item.ID, "Laptop 2", "", "", model.ItemStatusLost, "left on train", &user.ID); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	UpdateItem(ctx, database, item.ID, "Laptop 2", "", "", model.ItemStatusActive, "found", &user.ID)
+
+	history, err := GetItemStatusHistory(ctx, database, item.ID)
+	if err != nil {
+		t.Fatalf("GetItemStatusHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 status changes, got %d", len(history))
+	}
+	lost := history[1]
+	if lost.FromStatus != model.ItemStatusActive || lost.ToStatus != model.ItemStatusLost {
+		t.Errorf("expected active -> lost, got %s -> %s", lost.FromStatus, lost.ToStatus)
+	}
+	if lost.Reason != "left on train" {
+		t.Errorf("expected reason 'left on train', got %q", lost.Reason)
+	}
+	if lost.UserID == nil || *lost.UserID != user.ID || lost.Username != "manager" {
+		t.Errorf("expected change attributed to manager, got %v %q", lost.UserID, lost.Username)
+	}
+}
+
+func TestUpdateItemNotFound(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	if err := UpdateItem(ctx, database, 999, "Ghost", "", "", model.ItemStatusActive, "", nil); err == nil {
+		t.Error("expected error updating missing item")
+	}
+}
+
+func TestGetItemChangelogMergesHistory(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	user, _ := CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	item, _ := CreateItem(ctx, database, "Laptop", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	office, _ := CreateOwner(ctx, database, "Office", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, storage.ID, 2, false, nil)
+
+	CreateTransfer(ctx, database, item.ID, storage.ID, office.ID, 1, false, "desk", &user.ID)
+	UpdateItem(ctx, database, item.ID, "Laptop", "", "", model.ItemStatusDamaged, "dropped", &user.ID)
+
+	// Pin timestamps so the status change precedes the transfer even though
+	// it was recorded later.
+	database.ExecContext(ctx, `UPDATE items SET created_at = '2024-01-01 10:00:00' WHERE id = ?`, item.ID)
+	database.ExecContext(ctx, `UPDATE status_changes SET created_at = '2024-01-02 10:00:00'`)
+	database.ExecContext(ctx, `UPDATE transfers SET transferred_at = '2024-01-03 10:00:00'`)
+	DeleteItem(ctx, database, item.ID, nil, "")
+
+	entries, err := GetItemChangelog(ctx, database, item.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetItemChangelog: %v", err)
+	}
+	want := []string{model.ChangelogDeleted, model.ChangelogTransfer, model.ChangelogStatusChanged, model.ChangelogCreated}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(entries))
+	}
+	for i, typ := range want {
+		if entries[i].Type != typ {
+			t.Errorf("entry %d: expected %q, got %q", i, typ, entries[i].Type)
+		}
+	}
+
+	tr := entries[1].Transfer
+	if tr == nil || tr.FromOwnerName != "Storage" || tr.ToOwnerName != "Office" || tr.Quantity != 1 || tr.Notes != "desk" {
+		t.Errorf("unexpected transfer entry: %+v", tr)
+	}
+	if entries[1].Username != "manager" {
+		t.Errorf("expected transfer by manager, got %q", entries[1].Username)
+	}
+
+	sc := entries[2]
+	if sc.Changes["status"] != (model.FieldChange{From: model.ItemStatusActive, To: model.ItemStatusDamaged}) || sc.Reason != "dropped" {
+		t.Errorf("unexpected status entry: %+v", sc)
+	}
+
+	page, _ := GetItemChangelog(ctx, database, item.ID, 2, 1)
+	if len(page) != 2 || page[0].Type != model.ChangelogTransfer || page[1].Type != model.ChangelogStatusChanged {
+		t.Errorf("unexpected page: %+v", page)
+	}
+}
+
+func TestGetItemChangelogSameTimestampOrder(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Laptop", "", "")
+	UpdateItem(ctx, database, item.ID, "Laptop", "", "", model.ItemStatusLost, "", nil)
+	database.ExecContext(ctx, `UPDATE items SET created_at = '2024-01-01 10:00:00' WHERE id = ?`, item.ID)
+	database.ExecContext(ctx, `UPDATE status_changes SET created_at = '2024-01-01 10:00:00'`)
+
+	entries, _ := GetItemChangelog(ctx, database, item.ID, 10, 0)
+	if len(entries) != 2 || entries[0].Type != model.ChangelogStatusChanged || entries[1].Type != model.ChangelogCreated {
+		t.Errorf("expected status change before creation, got %+v", entries)
+	}
+}
+
+func TestCreatedAtIsUTCRegardlessOfLocalZone(t *testing.T) {
+	orig := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	t.Cleanup(func() { time.Local = orig })
+
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, err := CreateItem(ctx, database, "Widget", "", "")
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	if item.CreatedAt.Location() != time.UTC {
+		t.Errorf("expected created_at in UTC, got %v", item.CreatedAt.Location())
+	}
+	if d := time.Since(item.CreatedAt); d < -time.Minute || d > time.Minute {
+		t.Errorf("created_at shifted by local zone: %v (now %v)", item.CreatedAt, time.Now().UTC())
+	}
+
+	data, _ := json.Marshal(item)
+	var raw map[string]any
+	json.Unmarshal(data, &raw)
+	if s, _ := raw["created_at"].(string); !strings.HasSuffix(s, "Z") {
+		t.Errorf("expected RFC 3339 UTC created_at, got %q", raw["created_at"])
+	}
+}
+
+func TestDecommissionItem(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	manager, _ := CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	item, _ := CreateItem(ctx, database, "Projector", "", "")
+	other, _ := CreateItem(ctx, database, "Screen", "", "")
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	ana, _ := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	AddStock(ctx, database, item.ID, room.ID, 5, false, nil)
+	CreateTransfer(ctx, database, item.ID, room.ID, ana.ID, 2, false, "", nil)
+	AddStock(ctx, database, other.ID, room.ID, 1, false, nil)
+
+	result, err := DecommissionItem(ctx, database, item.ID, "end of life", &manager.ID)
+	if err != nil {
+		t.Fatalf("DecommissionItem: %v", err)
+	}
+	if result.Item.Status != model.ItemStatusRemoved {
+		t.Errorf("expected status removed, got %q", result.Item.Status)
+	}
+	if len(result.Adjustments) != 2 ||
+		result.Adjustments[0].OwnerName != "Ana" || result.Adjustments[0].Delta != -2 ||
+		result.Adjustments[1].OwnerName != "Room" || result.Adjustments[1].Delta != -3 ||
+		result.Adjustments[0].Kind != model.AdjustmentDecommissioned || result.Adjustments[0].Reason != "end of life" {
+		t.Errorf("unexpected adjustments: %+v", result.Adjustments)
+	}
+	if dist, _ := GetItemDistribution(ctx, database, item.ID); len(dist) != 0 {
+		t.Errorf("expected no stock left, got %+v", dist)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, other.ID, room.ID); got != 1 {
+		t.Errorf("expected other items untouched, got %d", got)
+	}
+
+	history, _ := GetItemStatusHistory(ctx, database, item.ID)
+	if len(history) != 1 || history[0].ToStatus != model.ItemStatusRemoved || history[0].Reason != "end of life" {
+		t.Errorf("expected one status change to removed, got %+v", history)
+	}
+	changelog, _ := GetItemChangelog(ctx, database, item.ID, 50, 0)
+	decommissioned := 0
+	for _, e := range changelog {
+		if e.Type == model.ChangelogDecommissioned {
+			decommissioned++
+			if e.Adjustment == nil || e.Adjustment.Delta >= 0 || e.Username != "manager" {
+				t.Errorf("unexpected decommissioned entry: %+v", e)
+			}
+		}
+	}
+	if decommissioned != 2 {
+		t.Errorf("expected 2 decommissioned changelog entries, got %d", decommissioned)
+	}
+
+	// A second call has nothing left to remove and keeps the single status change.
+	again, err := DecommissionItem(ctx, database, item.ID, "", &manager.ID)
+	if err != nil || len(again.Adjustments) != 0 {
+		t.Errorf("expected an empty repeat, got %+v, %v", again, err)
+	}
+	if history, _ := GetItemStatusHistory(ctx, database, item.ID); len(history) != 1 {
+		t.Errorf("expected no new status change, got %d", len(history))
+	}
+
+	if _, err := DecommissionItem(ctx, database, 999, "", nil); err == nil {
+		t.Error("expected error for missing item")
+	}
+}
+
+func TestDecommissionSerializedItem(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Laptop", "", "")
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	SetItemSerialized(ctx, database, item.ID, true)
+	AssignSerials(ctx, database, item.ID, room.ID, []string{"SN1", "SN2"})
+
+	result, err := DecommissionItem(ctx, database, item.ID, "", nil)
+	if err != nil {
+		t.Fatalf("DecommissionItem: %v", err)
+	}
+	if len(result.Adjustments) != 1 || result.Adjustments[0].Delta != -2 {
+		t.Errorf("unexpected adjustments: %+v", result.Adjustments)
+	}
+	if serials, _ := ListSerials(ctx, database, item.ID, 0); len(serials) != 0 {
+		t.Errorf("expected serials removed, got %+v", serials)
+	}
+}
+
+func TestSetItemDivisible(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Flour", "", "")
+	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, item.ID, from.ID, 5, false, nil)
+	CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 2, false, "", nil)
+	threshold := 3
+	SetItemMinQuantity(ctx, database, item.ID, &threshold)
+
+	if err := SetItemDivisible(ctx, database, item.ID, true); err != nil {
+		t.Fatalf("SetItemDivisible: %v", err)
+	}
+	got, _ := GetItem(ctx, database, item.ID)
+	if !got.Divisible || got.MinQuantity == nil || *got.MinQuantity != 3000 {
+		t.Errorf("expected a divisible item with min 3000, got %+v", got)
+	}
+	if held, _ := GetHeldQuantity(ctx, database, item.ID, from.ID); held != 3000 {
+		t.Errorf("expected 3 kept as 3000, got %d", held)
+	}
+	if history, _ := GetItemHistory(ctx, database, item.ID); len(history) != 1 || history[0].Quantity != 2000 || !history[0].Divisible {
+		t.Errorf("expected the transfer rescaled to 2000, got %+v", history)
+	}
+
+	// A fractional amount keeps it divisible.
+	AdjustInventory(ctx, database, item.ID, from.ID, -500, true, "", nil)
+	if err := SetItemDivisible(ctx, database, item.ID, false); err == nil {
+		t.Error("expected fractional stock to block switching back")
+	}
+	AdjustInventory(ctx, database, item.ID, from.ID, 500, true, "", nil)
+	if err := SetItemDivisible(ctx, database, item.ID, false); err != nil {
+		t.Fatalf("SetItemDivisible off: %v", err)
+	}
+	if held, _ := GetHeldQuantity(ctx, database, item.ID, from.ID); held != 3 {
+		t.Errorf("expected 3 whole units back, got %d", held)
+	}
+
+	laptop, _ := CreateItem(ctx, database, "Laptop", "", "")
+	SetItemSerialized(ctx, database, laptop.ID, true)
+	if err := SetItemDivisible(ctx, database, laptop.ID, true); err == nil {
+		t.Error("expected a serialized item not to become divisible")
+	}
+}
+
+func TestQuantityAfterDivisibleChange(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Flour", "", "")
+	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, item.ID, from.ID, 5, false, nil)
+
+	// Quantities converted as whole units before the switch are refused
+	// rather than read as thousandths.
+	if err := SetItemDivisible(ctx, database, item.ID, true); err != nil {
+		t.Fatalf("SetItemDivisible: %v", err)
+	}
+	var v *ValidationError
+	checks := []struct {
+		name string
+		run  func() error
+	}{
+		{"AddStock", func() error { return AddStock(ctx, database, item.ID, from.ID, 2, false, nil) }},
+		{"AdjustInventory", func() error { return AdjustInventory(ctx, database, item.ID, from.ID, -2, false, "", nil) }},
+		{"SetStock", func() error { _, err := SetStock(ctx, database, item.ID, from.ID, 2, false, nil); return err }},
+		{"CreateTransfer", func() error {
+			_, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 2, false, "", nil)
+			return err
+		}},
+		{"FulfillTransfer", func() error {
+			_, err := FulfillTransfer(ctx, database, item.ID, to.ID, 2, false, nil, "", nil)
+			return err
+		}},
+		{"AddStockBatch", func() error {
+			_, err := AddStockBatch(ctx, database, from.ID, []model.StockLine{{ItemID: item.ID, Quantity: 2}}, nil)
+			return err
+		}},
+	}
+	for _, c := range checks {
+		if err := c.run(); !errors.Is(err, ErrDivisibleChanged) || !errors.As(err, &v) {
+			t.Errorf("%s: expected a ValidationError wrapping ErrDivisibleChanged, got %v", c.name, err)
+		}
+	}
+	if held, _ := GetHeldQuantity(ctx, database, item.ID, from.ID); held != 5000 {
+		t.Errorf("expected the stock left at 5000, got %d", held)
+	}
+
+	if err := AddStock(ctx, database, item.ID, from.ID, 2000, true, nil); err != nil {
+		t.Fatalf("AddStock with the current flag: %v", err)
+	}
 }
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// LoginEventRetention is how long login events are kept.
+const LoginEventRetention = 90 * 24 * time.Hour
+
+// loginHotspotLimit is how many addresses GetLoginActivity reports.
+const loginHotspotLimit = 10
+
+// RecordLogin stores a login attempt. userID is 0 when the username did not
+// match an account.
+func RecordLogin(ctx context.Context, db *sql.DB, userID int64, username string, success bool, ip, userAgent string) error {
+	var uid sql.NullInt64
+	if userID != 0 {
+		uid = sql.NullInt64{Int64: userID, Valid: true}
+	}
+	_, err := exec(ctx, db,
+		`INSERT INTO login_events (user_id, username, success, ip, user_agent) VALUES (?, ?, ?, ?, ?)`,
+		uid, username, success, ip, userAgent,
+	)
+	if err != nil {
+		return fmt.Errorf("recording login: %w", err)
+	}
+
+	// Opportunistically clean up old events.
+	_, _ = exec(ctx, db,
+		`DELETE FROM login_events WHERE created_at < ?`,
+		time.Now().UTC().Add(-LoginEventRetention).Format(sqliteTimeFormat),
+	)
+
+	return nil
+}
+
+// GetLoginActivity returns up to limit login events matching filter, newest
+// first, and the addresses with the most failed logins in the same range.
+func GetLoginActivity(ctx context.Context, db *sql.DB, filter model.LoginFilter, limit int) (*model.LoginActivity, error) {
+	var from, to string
+	if !filter.From.IsZero() {
+		from = filter.From.UTC().Format(sqliteTimeFormat)
+	}
+	if !filter.To.IsZero() {
+		to = filter.To.UTC().Format(sqliteTimeFormat)
+	}
+	const where = `(? = '' OR created_at >= ?)
+		   AND (? = '' OR created_at < ?)
+		   AND (? = 0 OR user_id = ?)`
+	args := []any{from, from, to, to, filter.UserID, filter.UserID}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, user_id, username, success, ip, user_agent, created_at
+		 FROM login_events
+		 WHERE `+where+`
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT ?`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing login events: %w", err)
+	}
+	defer rows.Close()
+
+	activity := &model.LoginActivity{Events: []model.LoginEvent{}, FailedHotspots: []model.LoginHotspot{}}
+	for rows.Next() {
+		var e model.LoginEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Username, &e.Success, &e.IP, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning login event: %w", err)
+		}
+		activity.Events = append(activity.Events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing login events: %w", err)
+	}
+
+	// Hotspots ignore the user filter: an address guessing at many accounts
+	// is the interesting case.
+	rows, err = db.QueryContext(ctx,
+		`SELECT ip, COUNT(*), COUNT(DISTINCT username), MAX(created_at)
+		 FROM login_events
+		 WHERE NOT success
+		   AND (? = '' OR created_at >= ?)
+		   AND (? = '' OR created_at < ?)
+		 GROUP BY ip
+		 ORDER BY COUNT(*) DESC, MAX(created_at) DESC
+		 LIMIT ?`,
+		from, from, to, to, loginHotspotLimit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing failed login hotspots: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var h model.LoginHotspot
+		var lastAt string
+		if err := rows.Scan(&h.IP, &h.Failures, &h.Usernames, &lastAt); err != nil {
+			return nil, fmt.Errorf("scanning failed login hotspot: %w", err)
+		}
+		if h.LastAt, err = time.Parse(sqliteTimeFormat, lastAt); err != nil {
+			return nil, fmt.Errorf("parsing failed login time: %w", err)
+		}
+		activity.FailedHotspots = append(activity.FailedHotspots, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing failed login hotspots: %w", err)
+	}
+	return activity, nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestLoginActivity(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	user, err := CreateUser(ctx, database, "alice", "hash", model.RoleUser)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	for _, e := range []struct {
+		userID   int64
+		username string
+		success  bool
+		ip       string
+	}{
+		{user.ID, "alice", true, "10.0.0.1"},
+		{user.ID, "alice", false, "10.0.0.2"},
+		{0, "root", false, "10.0.0.2"},
+		{0, "bob", false, "10.0.0.3"},
+	} {
+		if err := RecordLogin(ctx, database, e.userID, e.username, e.success, e.ip, "agent"); err != nil {
+			t.Fatalf("RecordLogin: %v", err)
+		}
+	}
+
+	activity, err := GetLoginActivity(ctx, database, model.LoginFilter{}, 10)
+	if err != nil {
+		t.Fatalf("GetLoginActivity: %v", err)
+	}
+	if len(activity.Events) != 4 || activity.Events[0].Username != "bob" || activity.Events[0].UserID != nil {
+		t.Errorf("expected 4 events, newest first, got %+v", activity.Events)
+	}
+	if len(activity.FailedHotspots) != 2 {
+		t.Fatalf("expected 2 hotspots, got %+v", activity.FailedHotspots)
+	}
+	if h := activity.FailedHotspots[0]; h.IP != "10.0.0.2" || h.Failures != 2 || h.Usernames != 2 || h.LastAt.IsZero() {
+		t.Errorf("unexpected top hotspot: %+v", h)
+	}
+
+	activity, err = GetLoginActivity(ctx, database, model.LoginFilter{UserID: user.ID}, 1)
+	if err != nil {
+		t.Fatalf("GetLoginActivity: %v", err)
+	}
+	if len(activity.Events) != 1 || activity.Events[0].Success || activity.Events[0].IP != "10.0.0.2" {
+		t.Errorf("expected alice's latest, failed login, got %+v", activity.Events)
+	}
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// SetItemMinQuantity sets an item's low-stock threshold. A nil minQuantity
+// removes it. Returns an error if the item does not exist or is soft-deleted.
+func SetItemMinQuantity(ctx context.Context, db *sql.DB, id int64, minQuantity *int) error {
+	result, err := exec(ctx, db,
+		`UPDATE items SET min_quantity = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND deleted_at IS NULL`,
+		minQuantity, id,
+	)
+	if err != nil {
+		return fmt.Errorf("setting item min quantity: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if n == 0 {
+		return invalidf("setting item min quantity: item not found")
+	}
+	return nil
+}
+
+// SetItemReorderTarget sets the total an item is restocked up to once it is
+// low. A nil target removes it. Returns an error if the item does not exist
+// or is soft-deleted.
+func SetItemReorderTarget(ctx context.Context, db *sql.DB, id int64, target *int) error {
+	result, err := exec(ctx, db,
+		`UPDATE items SET reorder_target = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND deleted_at IS NULL`,
+		target, id,
+	)
+	if err != nil {
+		return fmt.Errorf("setting item reorder target: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if n == 0 {
+		return invalidf("setting item reorder target: item not found")
+	}
+	return nil
+}
+
+// ListReorder returns the non-deleted items whose total quantity is below
+// their low-stock threshold and that have a reorder target above that total,
+// with the quantity to order to reach the target, ordered by item name.
+// Items without a threshold or a target are left out.
+func ListReorder(ctx context.Context, db *sql.DB) ([]model.ReorderLine, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT i.id, i.name, COALESCE(SUM(inv.quantity), 0) AS total, i.min_quantity, i.reorder_target, i.divisible
+		 FROM items i
+		 LEFT JOIN inventory inv ON inv.item_id = i.id
+		 WHERE i.deleted_at IS NULL
+		   AND i.min_quantity IS NOT NULL AND i.reorder_target IS NOT NULL
+		 GROUP BY i.id
+		 HAVING total < i.min_quantity AND total < i.reorder_target
+		 ORDER BY i.name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing reorder lines: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []model.ReorderLine
+	for rows.Next() {
+		var l model.ReorderLine
+		if err := rows.Scan(&l.ItemID, &l.ItemName, &l.Quantity, &l.MinQuantity, &l.ReorderTarget, &l.Divisible); err != nil {
+			return nil, fmt.Errorf("scanning reorder line: %w", err)
+		}
+		l.Suggested = l.ReorderTarget - l.Quantity
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// ListStockLevels returns the total quantity of every non-deleted item that
+// has a low-stock threshold or an outstanding low-stock alert, ordered by
+// item name. Items whose threshold was removed have MinQuantity 0.
+func ListStockLevels(ctx context.Context, db *sql.DB) ([]model.StockLevel, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT i.id, i.name, COALESCE(SUM(inv.quantity), 0), COALESCE(i.min_quantity, 0),
+		        i.low_stock_alerted_at IS NOT NULL, i.divisible
+		 FROM items i
+		 LEFT JOIN inventory inv ON inv.item_id = i.id
+		 WHERE i.deleted_at IS NULL
+		   AND (i.min_quantity IS NOT NULL OR i.low_stock_alerted_at IS NOT NULL)
+		 GROUP BY i.id
+		 ORDER BY i.name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing stock levels: %w", err)
+	}
+	defer rows.Close()
+
+	var levels []model.StockLevel
+	for rows.Next() {
+		var l model.StockLevel
+		if err := rows.Scan(&l.ItemID, &l.ItemName, &l.Quantity, &l.MinQuantity, &l.Alerted, &l.Divisible); err != nil {
+			return nil, fmt.Errorf("scanning stock level: %w", err)
+		}
+		levels = append(levels, l)
+	}
+	return levels, rows.Err()
+}
+
+// SetLowStockAlerted records that a low-stock alert was sent for an item, or
+// clears the record once the item is no longer low so the next drop alerts
+// again.
+func SetLowStockAlerted(ctx context.Context, db *sql.DB, itemID int64, alerted bool) error {
+	query := `UPDATE items SET low_stock_alerted_at = NULL WHERE id = ?`
+	if alerted {
+		query = `UPDATE items SET low_stock_alerted_at = CURRENT_TIMESTAMP WHERE id = ?`
+	}
+	if _, err := exec(ctx, db, query, itemID); err != nil {
+		return fmt.Errorf("setting low-stock alert state: %w", err)
+	}
+	return nil
+}
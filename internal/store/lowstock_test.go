@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestListReorder(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	van, _ := CreateOwner(ctx, database, "Van", model.OwnerTypeLocation)
+	level := func(name string, held, min, target int) *model.Item {
+		item, _ := CreateItem(ctx, database, name, "", "")
+		if held > 0 {
+			AddStock(ctx, database, item.ID, storage.ID, held, false, nil)
+		}
+		if min > 0 {
+			SetItemMinQuantity(ctx, database, item.ID, &min)
+		}
+		if target > 0 {
+			SetItemReorderTarget(ctx, database, item.ID, &target)
+		}
+		return item
+	}
+	gloves := level("Gloves", 2, 5, 20)
+	level("Helmets", 6, 5, 20) // not low
+	level("Masks", 1, 5, 0)    // no target
+	level("Tape", 0, 0, 10)    // no threshold
+	level("Vests", 3, 5, 3)    // target already reached
+	batteries := level("Batteries", 0, 4, 12)
+
+	// Stock held by any owner counts towards the total.
+	AddStock(ctx, database, gloves.ID, van.ID, 1, false, nil)
+
+	lines, err := ListReorder(ctx, database)
+	if err != nil {
+		t.Fatalf("ListReorder: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected Batteries and Gloves, got %+v", lines)
+	}
+	if l := lines[0]; l.ItemID != batteries.ID || l.Quantity != 0 || l.Suggested != 12 {
+		t.Errorf("expected 12 batteries to order, got %+v", l)
+	}
+	if l := lines[1]; l.ItemID != gloves.ID || l.Quantity != 3 || l.MinQuantity != 5 || l.ReorderTarget != 20 || l.Suggested != 17 {
+		t.Errorf("expected 17 gloves to order, got %+v", l)
+	}
+
+	// Removing the target drops the item from the report.
+	SetItemReorderTarget(ctx, database, gloves.ID, nil)
+	if lines, _ := ListReorder(ctx, database); len(lines) != 1 || lines[0].ItemID != batteries.ID {
+		t.Errorf("expected only Batteries, got %+v", lines)
+	}
+	if err := SetItemReorderTarget(ctx, database, 999, nil); err == nil {
+		t.Error("expected error for a missing item")
+	}
+}
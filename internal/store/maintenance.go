@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	// Aliased because store functions name their *sql.DB parameter db.
+	dbschema "github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// GetDBStats reports page and file sizes of the main database. It runs a
+// passive WAL checkpoint, which never blocks readers or writers.
+func GetDBStats(ctx context.Context, db *sql.DB) (*model.DBStats, error) {
+	// Pin a connection so every pragma sees the same database.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting connection: %w", err)
+	}
+	defer conn.Close()
+
+	s := &model.DBStats{}
+	pragmas := []struct {
+		name string
+		dest any
+	}{
+		{"page_count", &s.PageCount},
+		{"page_size", &s.PageSize},
+		{"freelist_count", &s.FreelistCount},
+		{"journal_mode", &s.JournalMode},
+	}
+	for _, p := range pragmas {
+		if err := conn.QueryRowContext(ctx, "PRAGMA "+p.name).Scan(p.dest); err != nil {
+			return nil, fmt.Errorf("reading pragma %s: %w", p.name, err)
+		}
+	}
+
+	var busy int
+	err = conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint").
+		Scan(&busy, &s.WALCheckpoint.LogFrames, &s.WALCheckpoint.Checkpointed)
+	if err != nil {
+		return nil, fmt.Errorf("running wal checkpoint: %w", err)
+	}
+	s.WALCheckpoint.Busy = busy != 0
+
+	// The file path is empty for in-memory databases.
+	err = conn.QueryRowContext(ctx, "SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading database path: %w", err)
+	}
+	if s.Path != "" {
+		if s.FileSize, err = fileSize(s.Path); err != nil {
+			return nil, err
+		}
+		if s.WALSize, err = fileSize(s.Path + "-wal"); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// fileSize returns the size of the file at path, or 0 if it does not exist.
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("stat %s: %w", path, err)
+	}
+	return info.Size(), nil
+}
+
+// Optimize runs PRAGMA optimize and truncates the WAL. With vacuum it also
+// rebuilds the database file to reclaim free pages; VACUUM takes an exclusive
+// lock for its whole duration, blocking all other readers and writers.
+func Optimize(ctx context.Context, db *sql.DB, vacuum bool) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("getting connection: %w", err)
+	}
+	defer conn.Close()
+
+	stmts := []string{"PRAGMA optimize"}
+	if vacuum {
+		stmts = append(stmts, "VACUUM")
+	}
+	// Checkpoint last so pages written by VACUUM are moved out of the WAL.
+	stmts = append(stmts, "PRAGMA wal_checkpoint(TRUNCATE)")
+
+	for _, stmt := range stmts {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("running %s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// VacuumInto writes a compacted, consistent copy of the database to path,
+// which must not exist. It reads a snapshot, so writers are not blocked while
+// it runs; canceling ctx interrupts it.
+func VacuumInto(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("vacuuming into %s: %w", path, err)
+	}
+	return nil
+}
+
+// GetMigrationStatus compares the migrations this binary knows with those the
+// database has applied and recorded.
+func GetMigrationStatus(ctx context.Context, db *sql.DB) (*model.MigrationStatus, error) {
+	sums := dbschema.MigrationChecksums()
+	s := &model.MigrationStatus{Latest: len(sums), Pending: []int{}, Migrations: []model.Migration{}}
+
+	if err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&s.Version); err != nil {
+		return nil, fmt.Errorf("reading schema version: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT version, checksum, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("listing applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	recorded := map[int]model.Migration{}
+	for rows.Next() {
+		var m model.Migration
+		var appliedAt sql.NullTime
+		if err := rows.Scan(&m.Version, &m.Checksum, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scanning applied migration: %w", err)
+		}
+		if appliedAt.Valid {
+			m.AppliedAt = &appliedAt.Time
+		}
+		recorded[m.Version] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing applied migrations: %w", err)
+	}
+
+	for i, sum := range sums {
+		version := i + 1
+		m := model.Migration{Version: version, Applied: version <= s.Version, Checksum: sum}
+		if r, ok := recorded[version]; ok {
+			m.AppliedAt = r.AppliedAt
+			m.Modified = r.Checksum != sum
+		}
+		if !m.Applied {
+			s.Pending = append(s.Pending, version)
+		}
+		s.Migrations = append(s.Migrations, m)
+	}
+	// Migrations from a newer binary that this one does not know.
+	for version := len(sums) + 1; version <= s.Version; version++ {
+		m := recorded[version]
+		m.Version, m.Applied = version, true
+		s.Migrations = append(s.Migrations, m)
+	}
+	return s, nil
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+)
+
+func TestGetDBStats(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "stats.sqlite3"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := db.EnsureSchema(database); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+	ctx := context.Background()
+	CreateItem(ctx, database, "Widget", "", "")
+
+	stats, err := GetDBStats(ctx, database)
+	if err != nil {
+		t.Fatalf("GetDBStats: %v", err)
+	}
+	if stats.PageCount <= 0 || stats.PageSize <= 0 {
+		t.Errorf("expected positive page count and size, got %d and %d", stats.PageCount, stats.PageSize)
+	}
+	if stats.JournalMode != "wal" {
+		t.Errorf("expected journal mode wal, got %q", stats.JournalMode)
+	}
+	if stats.Path == "" || stats.FileSize <= 0 {
+		t.Errorf("expected on-disk path and size, got %q (%d bytes)", stats.Path, stats.FileSize)
+	}
+	if stats.WALCheckpoint.LogFrames < 0 {
+		t.Errorf("expected WAL frame counts in WAL mode, got %+v", stats.WALCheckpoint)
+	}
+}
+
+func TestOptimizeVacuumReclaimsPages(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "optimize.sqlite3"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := db.EnsureSchema(database); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+	ctx := context.Background()
+
+	image := make([]byte, 64<<10)
+	for i := range 20 {
+		item, _ := CreateItem(ctx, database, fmt.Sprintf("Item %d", i), "", "")
+		SetItemImage(ctx, database, item.ID, image, "image/png")
+	}
+
+	// A plain optimize pass must work on a populated database.
+	if err := Optimize(ctx, database, false); err != nil {
+		t.Fatalf("Optimize: %v", err)
+	}
+	database.ExecContext(ctx, `UPDATE items SET image = NULL, image_mime = NULL`)
+
+	before, _ := GetDBStats(ctx, database)
+	if before.FreelistCount == 0 {
+		t.Fatalf("expected free pages after clearing images, got %+v", before)
+	}
+
+	if err := Optimize(ctx, database, true); err != nil {
+		t.Fatalf("Optimize with vacuum: %v", err)
+	}
+	after, _ := GetDBStats(ctx, database)
+	if after.PageCount >= before.PageCount {
+		t.Errorf("expected page count to shrink, got %d -> %d", before.PageCount, after.PageCount)
+	}
+	if after.FreelistCount != 0 {
+		t.Errorf("expected no free pages after vacuum, got %d", after.FreelistCount)
+	}
+	if after.WALSize != 0 {
+		t.Errorf("expected WAL truncated, got %d bytes", after.WALSize)
+	}
+}
+
+func TestGetMigrationStatus(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	status, err := GetMigrationStatus(ctx, database)
+	if err != nil {
+		t.Fatalf("GetMigrationStatus: %v", err)
+	}
+	latest := len(db.MigrationChecksums())
+	if status.Version != latest || status.Latest != latest || len(status.Pending) != 0 || len(status.Migrations) != latest {
+		t.Fatalf("expected all %d migrations applied, got %+v", latest, status)
+	}
+	if m := status.Migrations[0]; !m.Applied || m.AppliedAt == nil || m.Modified {
+		t.Errorf("expected first migration applied and unmodified, got %+v", m)
+	}
+
+	// Pretend the last migration has not run, and the first was edited.
+	database.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, latest-1))
+	database.ExecContext(ctx, `UPDATE schema_migrations SET checksum = 'old' WHERE version = 1`)
+
+	status, _ = GetMigrationStatus(ctx, database)
+	if len(status.Pending) != 1 || status.Pending[0] != latest || status.Migrations[latest-1].Applied {
+		t.Errorf("expected migration %d pending, got %+v", latest, status.Pending)
+	}
+	if !status.Migrations[0].Modified {
+		t.Error("expected edited migration to be flagged")
+	}
+}
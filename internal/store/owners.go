@@ -4,13 +4,24 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/erazemk/skladisce/internal/model"
 )
 
 // CreateOwner creates a new owner (person or location).
 func CreateOwner(ctx context.Context, db *sql.DB, name, ownerType string) (*model.Owner, error) {
-	result, err := db.ExecContext(ctx,
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkQuota(ctx, tx, "owners", maxOwners); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx,
 		`INSERT INTO owners (name, type) VALUES (?, ?)`,
 		name, ownerType,
 	)
@@ -23,22 +34,27 @@ func CreateOwner(ctx context.Context, db *sql.DB, name, ownerType string) (*mode
 		return nil, fmt.Errorf("getting owner id: %w", err)
 	}
 
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing owner creation: %w", err)
+	}
 	return GetOwner(ctx, db, id)
 }
 
 // GetOwner returns an owner by ID.
 func GetOwner(ctx context.Context, db *sql.DB, id int64) (*model.Owner, error) {
 	o := &model.Owner{}
+	var deleteReason sql.NullString
 	err := db.QueryRowContext(ctx,
-		`SELECT id, name, type, created_at, deleted_at
+		`SELECT id, name, type, created_at, deleted_at, deleted_by, delete_reason, user_id
 		 FROM owners WHERE id = ?`, id,
-	).Scan(&o.ID, &o.Name, &o.Type, &o.CreatedAt, &o.DeletedAt)
+	).Scan(&o.ID, &o.Name, &o.Type, &o.CreatedAt, &o.DeletedAt, &o.DeletedBy, &deleteReason, &o.UserID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("getting owner: %w", err)
 	}
+	o.DeleteReason = deleteReason.String
 	return o, nil
 }
 
@@ -49,12 +65,12 @@ func ListOwners(ctx context.Context, db *sql.DB, ownerType string) ([]model.Owne
 
 	if ownerType != "" {
 		rows, err = db.QueryContext(ctx,
-			`SELECT id, name, type, created_at, deleted_at
+			`SELECT id, name, type, created_at, deleted_at, user_id
 			 FROM owners WHERE deleted_at IS NULL AND type = ? ORDER BY name`, ownerType,
 		)
 	} else {
 		rows, err = db.QueryContext(ctx,
-			`SELECT id, name, type, created_at, deleted_at
+			`SELECT id, name, type, created_at, deleted_at, user_id
 			 FROM owners WHERE deleted_at IS NULL ORDER BY name`,
 		)
 	}
@@ -63,6 +79,69 @@ func ListOwners(ctx context.Context, db *sql.DB, ownerType string) ([]model.Owne
 	}
 	defer rows.Close()
 
+	var owners []model.Owner
+	for rows.Next() {
+		var o model.Owner
+		if err := rows.Scan(&o.ID, &o.Name, &o.Type, &o.CreatedAt, &o.DeletedAt, &o.UserID); err != nil {
+			return nil, fmt.Errorf("scanning owner: %w", err)
+		}
+		owners = append(owners, o)
+	}
+	return owners, rows.Err()
+}
+
+// SearchOwners returns non-deleted owners whose name contains query
+// (case-insensitive), optionally filtered by type, ordered by name.
+func SearchOwners(ctx context.Context, db *sql.DB, query, ownerType string, limit int) ([]model.Owner, error) {
+	q := `SELECT id, name, type, created_at, deleted_at, user_id
+	      FROM owners WHERE deleted_at IS NULL AND name LIKE ? ESCAPE '\'`
+	args := []any{"%" + escapeLike(query) + "%"}
+	if ownerType != "" {
+		q += ` AND type = ?`
+		args = append(args, ownerType)
+	}
+	q += ` ORDER BY name LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching owners: %w", err)
+	}
+	defer rows.Close()
+
+	var owners []model.Owner
+	for rows.Next() {
+		var o model.Owner
+		if err := rows.Scan(&o.ID, &o.Name, &o.Type, &o.CreatedAt, &o.DeletedAt, &o.UserID); err != nil {
+			return nil, fmt.Errorf("scanning owner: %w", err)
+		}
+		owners = append(owners, o)
+	}
+	return owners, rows.Err()
+}
+
+// ListHoldingOwners returns non-deleted owners that currently hold any
+// inventory, optionally only of itemID (0 = any item) and of ownerType,
+// ordered by name.
+func ListHoldingOwners(ctx context.Context, db *sql.DB, itemID int64, ownerType string) ([]model.Owner, error) {
+	q := `SELECT o.id, o.name, o.type, o.created_at, o.deleted_at
+	      FROM owners o
+	      WHERE o.deleted_at IS NULL
+	        AND EXISTS (SELECT 1 FROM inventory inv
+	                    WHERE inv.owner_id = o.id AND (? = 0 OR inv.item_id = ?))`
+	args := []any{itemID, itemID}
+	if ownerType != "" {
+		q += ` AND o.type = ?`
+		args = append(args, ownerType)
+	}
+	q += ` ORDER BY o.name`
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing holding owners: %w", err)
+	}
+	defer rows.Close()
+
 	var owners []model.Owner
 	for rows.Next() {
 		var o model.Owner
@@ -74,9 +153,88 @@ func ListOwners(ctx context.Context, db *sql.DB, ownerType string) ([]model.Owne
 	return owners, rows.Err()
 }
 
+// LinkOwnerUser links a person owner to the user account it stands for, or
+// unlinks it if userID is nil. A user can be linked to at most one owner.
+// Returns an error if the owner does not exist, is deleted or is a location,
+// or if the user does not exist or is linked to another owner.
+func LinkOwnerUser(ctx context.Context, db *sql.DB, ownerID int64, userID *int64) (*model.Owner, error) {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var ownerType string
+	err = tx.QueryRowContext(ctx,
+		`SELECT type FROM owners WHERE id = ? AND deleted_at IS NULL`, ownerID,
+	).Scan(&ownerType)
+	if err == sql.ErrNoRows {
+		return nil, invalidf("owner not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking owner: %w", err)
+	}
+	if ownerType != model.OwnerTypePerson {
+		return nil, invalidf("only person owners can be linked to a user")
+	}
+
+	if userID != nil {
+		var exists bool
+		err = tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL)`, *userID,
+		).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("checking user: %w", err)
+		}
+		if !exists {
+			return nil, invalidf("user not found")
+		}
+
+		var linked int64
+		err = tx.QueryRowContext(ctx,
+			`SELECT id FROM owners WHERE user_id = ? AND id != ?`, *userID, ownerID,
+		).Scan(&linked)
+		if err == nil {
+			return nil, invalidf("user is already linked to owner %d", linked)
+		}
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("checking linked owner: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE owners SET user_id = ? WHERE id = ?`, userID, ownerID); err != nil {
+		return nil, fmt.Errorf("linking owner: %w", err)
+	}
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing owner link: %w", err)
+	}
+	return GetOwner(ctx, db, ownerID)
+}
+
+// GetUserOwner returns the person owner linked to a user, or nil if there is
+// none.
+func GetUserOwner(ctx context.Context, db *sql.DB, userID int64) (*model.Owner, error) {
+	var id int64
+	err := db.QueryRowContext(ctx,
+		`SELECT id FROM owners WHERE user_id = ? AND deleted_at IS NULL`, userID,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting user's owner: %w", err)
+	}
+	return GetOwner(ctx, db, id)
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 // UpdateOwner updates an owner's name.
 func UpdateOwner(ctx context.Context, db *sql.DB, id int64, name string) error {
-	_, err := db.ExecContext(ctx,
+	_, err := exec(ctx, db,
 		`UPDATE owners SET name = ? WHERE id = ? AND deleted_at IS NULL`,
 		name, id,
 	)
@@ -86,8 +244,9 @@ func UpdateOwner(ctx context.Context, db *sql.DB, id int64, name string) error {
 	return nil
 }
 
-// DeleteOwner soft-deletes an owner. Fails if the owner holds any inventory.
-func DeleteOwner(ctx context.Context, db *sql.DB, id int64) error {
+// DeleteOwner soft-deletes an owner, recording who deleted it and an optional
+// reason, and unlinks its user. Fails if the owner holds any inventory.
+func DeleteOwner(ctx context.Context, db *sql.DB, id int64, userID *int64, reason string) error {
 	// Check if owner holds inventory.
 	var count int
 	err := db.QueryRowContext(ctx,
@@ -97,12 +256,13 @@ func DeleteOwner(ctx context.Context, db *sql.DB, id int64) error {
 		return fmt.Errorf("checking owner inventory: %w", err)
 	}
 	if count > 0 {
-		return fmt.Errorf("cannot delete owner: still holds %d inventory entries", count)
+		return invalidf("cannot delete owner: still holds %d inventory entries", count)
 	}
 
-	_, err = db.ExecContext(ctx,
-		`UPDATE owners SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`,
-		id,
+	_, err = exec(ctx, db,
+		`UPDATE owners SET deleted_at = CURRENT_TIMESTAMP, deleted_by = ?, delete_reason = NULLIF(?, ''), user_id = NULL
+		 WHERE id = ? AND deleted_at IS NULL`,
+		userID, reason, id,
 	)
 	if err != nil {
 		return fmt.Errorf("deleting owner: %w", err)
@@ -110,12 +270,49 @@ func DeleteOwner(ctx context.Context, db *sql.DB, id int64) error {
 	return nil
 }
 
+// RestoreOwner undoes a soft delete, clearing the deletion fields. Returns an
+// error if the owner does not exist or is not deleted.
+func RestoreOwner(ctx context.Context, db *sql.DB, id int64) error {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkQuota(ctx, tx, "owners", maxOwners); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE owners SET deleted_at = NULL, deleted_by = NULL, delete_reason = NULL
+		 WHERE id = ? AND deleted_at IS NOT NULL`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("restoring owner: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if n == 0 {
+		return invalidf("restoring owner: deleted owner not found")
+	}
+
+	if err := commit(tx); err != nil {
+		return fmt.Errorf("committing owner restore: %w", err)
+	}
+	return nil
+}
+
 // GetOwnerInventory returns all inventory entries for an owner.
 func GetOwnerInventory(ctx context.Context, db *sql.DB, ownerID int64) ([]model.Inventory, error) {
 	rows, err := db.QueryContext(ctx,
-		`SELECT inv.item_id, inv.owner_id, inv.quantity, i.name AS item_name
+		`SELECT inv.item_id, inv.owner_id, inv.quantity, i.name AS item_name,
+		        o.name AS owner_name, o.type AS owner_type, i.divisible
 		 FROM inventory inv
 		 JOIN items i ON i.id = inv.item_id
+		 JOIN owners o ON o.id = inv.owner_id
 		 WHERE inv.owner_id = ?
 		 ORDER BY i.name`, ownerID,
 	)
@@ -127,10 +324,50 @@ func GetOwnerInventory(ctx context.Context, db *sql.DB, ownerID int64) ([]model.
 	var items []model.Inventory
 	for rows.Next() {
 		var inv model.Inventory
-		if err := rows.Scan(&inv.ItemID, &inv.OwnerID, &inv.Quantity, &inv.ItemName); err != nil {
+		if err := rows.Scan(&inv.ItemID, &inv.OwnerID, &inv.Quantity, &inv.ItemName, &inv.OwnerName, &inv.OwnerType, &inv.Divisible); err != nil {
 			return nil, fmt.Errorf("scanning inventory: %w", err)
 		}
 		items = append(items, inv)
 	}
 	return items, rows.Err()
 }
+
+// GetOwnerSummary returns distinct item and unit counts for what an owner
+// holds, with units broken down by item status.
+func GetOwnerSummary(ctx context.Context, db *sql.DB, ownerID int64) (*model.OwnerSummary, error) {
+	statuses, err := ListStatuses(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	summary := &model.OwnerSummary{
+		OwnerID:       ownerID,
+		UnitsByStatus: make(map[string]int, len(statuses)),
+	}
+	for _, s := range statuses {
+		summary.UnitsByStatus[s.Name] = 0
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT i.status, COUNT(*), SUM(`+wholeUnits("inv.quantity")+`)
+		 FROM inventory inv
+		 JOIN items i ON i.id = inv.item_id
+		 WHERE inv.owner_id = ?
+		 GROUP BY i.status`, ownerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting owner summary: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var items, units int
+		if err := rows.Scan(&status, &items, &units); err != nil {
+			return nil, fmt.Errorf("scanning owner summary: %w", err)
+		}
+		summary.Items += items
+		summary.Units += units
+		summary.UnitsByStatus[status] = units
+	}
+	return summary, rows.Err()
+}
@@ -58,10 +58,10 @@ func TestDeleteOwnerWithInventoryFails(t *testing.T) {
 	ctx := context.Background()
 
 	location, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
-	item, _ := CreateItem(ctx, database, "Widget", "")
-	AddStock(ctx, database, item.ID, location.ID, 5, nil)
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
+	AddStock(ctx, database, item.ID, location.ID, 5, false, nil)
 
-	err := DeleteOwner(ctx, database, location.ID)
+	err := DeleteOwner(ctx, database, location.ID, nil, "")
 	if err == nil {
 		t.Error("expected error deleting owner with inventory")
 	}
@@ -72,8 +72,208 @@ func TestDeleteOwnerWithoutInventory(t *testing.T) {
 	ctx := context.Background()
 
 	owner, _ := CreateOwner(ctx, database, "Empty Room", model.OwnerTypeLocation)
-	err := DeleteOwner(ctx, database, owner.ID)
+	err := DeleteOwner(ctx, database, owner.ID, nil, "")
 	if err != nil {
 		t.Errorf("expected no error, got: %v", err)
 	}
 }
+
+func TestDeleteAndRestoreOwnerRecordsAudit(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	user, _ := CreateUser(ctx, database, "manager", "hash", model.RoleManager)
+	owner, _ := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+
+	if err := DeleteOwner(ctx, database, owner.ID, &user.ID, "left the company"); err != nil {
+		t.Fatalf("DeleteOwner: %v", err)
+	}
+	got, _ := GetOwner(ctx, database, owner.ID)
+	if got.DeletedAt == nil || got.DeletedBy == nil || *got.DeletedBy != user.ID || got.DeleteReason != "left the company" {
+		t.Errorf("expected deletion audit fields, got %+v", got)
+	}
+
+	if err := RestoreOwner(ctx, database, owner.ID); err != nil {
+		t.Fatalf("RestoreOwner: %v", err)
+	}
+	got, _ = GetOwner(ctx, database, owner.ID)
+	if got.DeletedAt != nil || got.DeletedBy != nil || got.DeleteReason != "" {
+		t.Errorf("expected deletion fields cleared, got %+v", got)
+	}
+
+	if err := RestoreOwner(ctx, database, owner.ID); err == nil {
+		t.Error("expected error restoring an owner that isn't deleted")
+	}
+}
+
+func TestListHoldingOwners(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	closet, _ := CreateOwner(ctx, database, "Closet", model.OwnerTypeLocation)
+	ana, _ := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	CreateOwner(ctx, database, "Empty", model.OwnerTypeLocation)
+	chair, _ := CreateItem(ctx, database, "Chair", "", "")
+	lamp, _ := CreateItem(ctx, database, "Lamp", "", "")
+	AddStock(ctx, database, chair.ID, room.ID, 5, false, nil)
+	AddStock(ctx, database, lamp.ID, closet.ID, 1, false, nil)
+	AddStock(ctx, database, chair.ID, ana.ID, 1, false, nil)
+
+	all, err := ListHoldingOwners(ctx, database, 0, "")
+	if err != nil {
+		t.Fatalf("ListHoldingOwners: %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("expected 3 holding owners, got %d", len(all))
+	}
+
+	chairs, _ := ListHoldingOwners(ctx, database, chair.ID, "")
+	if len(chairs) != 2 || chairs[0].Name != "Ana" || chairs[1].Name != "Room" {
+		t.Errorf("expected Ana and Room holding chairs, got %v", chairs)
+	}
+
+	locations, _ := ListHoldingOwners(ctx, database, chair.ID, model.OwnerTypeLocation)
+	if len(locations) != 1 || locations[0].ID != room.ID {
+		t.Errorf("expected only Room as location holding chairs, got %v", locations)
+	}
+}
+
+func TestSearchOwners(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	CreateOwner(ctx, database, "Storage Room", model.OwnerTypeLocation)
+	CreateOwner(ctx, database, "Back Room", model.OwnerTypeLocation)
+	CreateOwner(ctx, database, "Roman", model.OwnerTypePerson)
+	CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+
+	got, err := SearchOwners(ctx, database, "ROOM", "", 50)
+	if err != nil {
+		t.Fatalf("SearchOwners: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 owners matching 'ROOM', got %d", len(got))
+	}
+	if got[0].Name != "Back Room" {
+		t.Errorf("expected results ordered by name, got %q first", got[0].Name)
+	}
+
+	people, _ := SearchOwners(ctx, database, "rom", model.OwnerTypePerson, 50)
+	if len(people) != 1 || people[0].Name != "Roman" {
+		t.Errorf("expected only 'Roman' for person search, got %v", people)
+	}
+
+	limited, _ := SearchOwners(ctx, database, "o", "", 1)
+	if len(limited) != 1 {
+		t.Errorf("expected limit of 1 result, got %d", len(limited))
+	}
+
+	// LIKE wildcards in the query are matched literally.
+	none, _ := SearchOwners(ctx, database, "%", "", 50)
+	if len(none) != 0 {
+		t.Errorf("expected no owners matching literal '%%', got %d", len(none))
+	}
+}
+
+func TestGetOwnerSummary(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	other, _ := CreateOwner(ctx, database, "Other", model.OwnerTypeLocation)
+	for _, s := range []struct {
+		name, status string
+		qty          int
+	}{
+		{"Chair", model.ItemStatusActive, 10},
+		{"Desk", model.ItemStatusActive, 2},
+		{"Lamp", model.ItemStatusDamaged, 3},
+		{"Cable", model.ItemStatusLost, 1},
+	} {
+		item, _ := CreateItem(ctx, database, s.name, "", "")
+		if s.status != model.ItemStatusActive {
+			UpdateItem(ctx, database, item.ID, s.name, "", "", s.status, "", nil)
+		}
+		AddStock(ctx, database, item.ID, room.ID, s.qty, false, nil)
+		AddStock(ctx, database, item.ID, other.ID, 100, false, nil)
+	}
+
+	summary, err := GetOwnerSummary(ctx, database, room.ID)
+	if err != nil {
+		t.Fatalf("GetOwnerSummary: %v", err)
+	}
+	if summary.Items != 4 || summary.Units != 16 {
+		t.Errorf("expected 4 items / 16 units, got %d / %d", summary.Items, summary.Units)
+	}
+	want := map[string]int{
+		model.ItemStatusActive:  12,
+		model.ItemStatusDamaged: 3,
+		model.ItemStatusLost:    1,
+		model.ItemStatusRemoved: 0,
+	}
+	for status, units := range want {
+		if got := summary.UnitsByStatus[status]; got != units {
+			t.Errorf("%s: expected %d units, got %d", status, units, got)
+		}
+	}
+
+	empty, _ := CreateOwner(ctx, database, "Empty", model.OwnerTypePerson)
+	summary, _ = GetOwnerSummary(ctx, database, empty.ID)
+	if summary.Items != 0 || summary.Units != 0 || len(summary.UnitsByStatus) != 4 {
+		t.Errorf("expected zeroed summary, got %+v", summary)
+	}
+}
+
+func TestLinkOwnerUser(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	ana, _ := CreateUser(ctx, database, "ana", "hash", model.RoleUser)
+	anaOwner, _ := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	bor, _ := CreateOwner(ctx, database, "Bor", model.OwnerTypePerson)
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+
+	if own, _ := GetUserOwner(ctx, database, ana.ID); own != nil {
+		t.Fatalf("expected no linked owner, got %+v", own)
+	}
+	owner, err := LinkOwnerUser(ctx, database, anaOwner.ID, &ana.ID)
+	if err != nil {
+		t.Fatalf("LinkOwnerUser: %v", err)
+	}
+	if owner.UserID == nil || *owner.UserID != ana.ID {
+		t.Errorf("expected owner linked to user %d, got %v", ana.ID, owner.UserID)
+	}
+	if own, _ := GetUserOwner(ctx, database, ana.ID); own == nil || own.ID != anaOwner.ID {
+		t.Errorf("expected Ana's owner, got %+v", own)
+	}
+
+	missing := int64(999)
+	checks := []struct {
+		name    string
+		ownerID int64
+		userID  *int64
+	}{
+		{"location", room.ID, &ana.ID},
+		{"already linked", bor.ID, &ana.ID},
+		{"missing user", bor.ID, &missing},
+		{"missing owner", 999, &ana.ID},
+	}
+	for _, c := range checks {
+		if _, err := LinkOwnerUser(ctx, database, c.ownerID, c.userID); err == nil {
+			t.Errorf("%s: expected error", c.name)
+		}
+	}
+
+	// Deleting the owner unlinks the user, who can then be linked again.
+	DeleteOwner(ctx, database, anaOwner.ID, nil, "")
+	if own, _ := GetUserOwner(ctx, database, ana.ID); own != nil {
+		t.Errorf("expected no linked owner after deletion, got %+v", own)
+	}
+	if _, err := LinkOwnerUser(ctx, database, bor.ID, &ana.ID); err != nil {
+		t.Errorf("expected relinking to succeed, got %v", err)
+	}
+	if owner, err := LinkOwnerUser(ctx, database, bor.ID, nil); err != nil || owner.UserID != nil {
+		t.Errorf("expected unlinking to succeed, got %+v, %v", owner, err)
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrQuotaExceeded is returned when an operation would make the number of
+// active items or owners exceed the configured quota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quotas on active (non-deleted) items and owners. 0 means unlimited.
+var (
+	maxItems  int
+	maxOwners int
+)
+
+// SetQuotas sets the maximum number of active items and owners; 0 means
+// unlimited. Call it once at startup, before serving requests.
+func SetQuotas(items, owners int) error {
+	if items < 0 || owners < 0 {
+		return invalidf("quotas must not be negative")
+	}
+	maxItems, maxOwners = items, owners
+	return nil
+}
+
+// checkQuota returns ErrQuotaExceeded if table already holds limit active
+// rows. It must run inside the write transaction that adds the row, so
+// concurrent creations cannot both pass the check.
+func checkQuota(ctx context.Context, tx *sql.Tx, table string, limit int) error {
+	if limit == 0 {
+		return nil
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM `+table+` WHERE deleted_at IS NULL`,
+	).Scan(&count); err != nil {
+		return fmt.Errorf("counting %s: %w", table, err)
+	}
+	if count >= limit {
+		return fmt.Errorf("%s %w (max %d)", table, ErrQuotaExceeded, limit)
+	}
+	return nil
+}
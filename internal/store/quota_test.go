@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestQuotas(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	if err := SetQuotas(2, 1); err != nil {
+		t.Fatalf("SetQuotas: %v", err)
+	}
+	t.Cleanup(func() { SetQuotas(0, 0) })
+
+	first, _ := CreateItem(ctx, database, "Chair", "", "")
+	if _, err := CreateItem(ctx, database, "Desk", "", ""); err != nil {
+		t.Fatalf("expected second item within quota, got %v", err)
+	}
+	if _, err := CreateItem(ctx, database, "Lamp", "", ""); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded for third item, got %v", err)
+	}
+	if _, err := CloneItem(ctx, database, first.ID, false); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded for clone, got %v", err)
+	}
+
+	// Deleted items free up room; restoring one counts again.
+	DeleteItem(ctx, database, first.ID, nil, "")
+	if _, err := CreateItem(ctx, database, "Lamp", "", ""); err != nil {
+		t.Errorf("expected room after delete, got %v", err)
+	}
+	if err := RestoreItem(ctx, database, first.ID); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded for restore, got %v", err)
+	}
+
+	if _, err := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation); err != nil {
+		t.Fatalf("expected first owner within quota, got %v", err)
+	}
+	if _, err := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded for second owner, got %v", err)
+	}
+
+	if err := SetQuotas(-1, 0); err == nil {
+		t.Error("expected error for negative quota")
+	}
+}
@@ -0,0 +1,350 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// ErrSerializedItem is returned when a quantity-based stock or transfer
+// operation targets a serialized item, whose units move by serial instead.
+var ErrSerializedItem = errors.New("item is serialized: use its serials instead of a quantity")
+
+// SerialAssignedError is returned when registering a serial the item already
+// has, so that one unit can never be held by two owners.
+type SerialAssignedError struct {
+	Serial  string
+	OwnerID int64
+}
+
+func (e *SerialAssignedError) Error() string {
+	return fmt.Sprintf("serial %q is already assigned", e.Serial)
+}
+
+// SerialNotHeldError is returned when a serial transfer names a serial that
+// the source owner does not hold.
+type SerialNotHeldError struct {
+	Serial string
+}
+
+func (e *SerialNotHeldError) Error() string {
+	return fmt.Sprintf("serial %q is not held by the source owner", e.Serial)
+}
+
+// rejectSerialized returns a ValidationError wrapping ErrSerializedItem if
+// the item is serialized. A missing item is left to the caller's own checks.
+func rejectSerialized(ctx context.Context, tx *sql.Tx, itemID int64) error {
+	var serialized bool
+	err := tx.QueryRowContext(ctx, `SELECT serialized FROM items WHERE id = ?`, itemID).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("checking item: %w", err)
+	}
+	if serialized {
+		return &ValidationError{Err: ErrSerializedItem}
+	}
+	return nil
+}
+
+// requireSerialized checks that the item exists, is not deleted and is
+// serialized.
+func requireSerialized(ctx context.Context, tx *sql.Tx, itemID int64) error {
+	var serialized bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT serialized FROM items WHERE id = ? AND deleted_at IS NULL`, itemID,
+	).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return invalidf("item not found")
+	}
+	if err != nil {
+		return fmt.Errorf("checking item: %w", err)
+	}
+	if !serialized {
+		return invalidf("item is not serialized")
+	}
+	return nil
+}
+
+// SetItemSerialized switches serial tracking on or off for an item. It can
+// only be switched on while nobody holds the item, and off while it has no
+// serials, so its quantities always match its serials. Divisible items cannot
+// be serialized.
+func SetItemSerialized(ctx context.Context, db *sql.DB, id int64, serialized bool) error {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current, divisible bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT serialized, divisible FROM items WHERE id = ? AND deleted_at IS NULL`, id,
+	).Scan(&current, &divisible)
+	if err == sql.ErrNoRows {
+		return invalidf("item not found")
+	}
+	if err != nil {
+		return fmt.Errorf("checking item: %w", err)
+	}
+	if current == serialized {
+		return nil
+	}
+	if divisible {
+		return invalidf("divisible items cannot be serialized")
+	}
+
+	check, blocked := `SELECT EXISTS (SELECT 1 FROM inventory WHERE item_id = ?)`, "item is in stock; remove its stock first"
+	if !serialized {
+		check, blocked = `SELECT EXISTS (SELECT 1 FROM serials WHERE item_id = ?)`, "item has serials; remove them first"
+	}
+	var exists bool
+	if err := tx.QueryRowContext(ctx, check, id).Scan(&exists); err != nil {
+		return fmt.Errorf("checking item stock: %w", err)
+	}
+	if exists {
+		return invalidf("%s", blocked)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE items SET serialized = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, serialized, id,
+	); err != nil {
+		return fmt.Errorf("setting item serialized: %w", err)
+	}
+	if err := commit(tx); err != nil {
+		return fmt.Errorf("committing serialized change: %w", err)
+	}
+	return nil
+}
+
+// AssignSerials registers new serials of a serialized item as held by
+// ownerID, adding one to the owner's quantity per serial, all in one
+// transaction. A serial the item already has is rejected with a
+// *SerialAssignedError and nothing is registered. serials should come from
+// model.NormalizeSerials.
+func AssignSerials(ctx context.Context, db *sql.DB, itemID, ownerID int64, serials []string) ([]model.Serial, error) {
+	if len(serials) == 0 {
+		return nil, invalidf("at least one serial required")
+	}
+
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := requireSerialized(ctx, tx, itemID); err != nil {
+		return nil, err
+	}
+	var ownerExists bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM owners WHERE id = ? AND deleted_at IS NULL)`, ownerID,
+	).Scan(&ownerExists)
+	if err != nil {
+		return nil, fmt.Errorf("checking owner: %w", err)
+	}
+	if !ownerExists {
+		return nil, invalidf("owner not found")
+	}
+
+	for _, serial := range serials {
+		var holder int64
+		err := tx.QueryRowContext(ctx,
+			`SELECT owner_id FROM serials WHERE item_id = ? AND serial = ?`, itemID, serial,
+		).Scan(&holder)
+		if err == nil {
+			return nil, &SerialAssignedError{Serial: serial, OwnerID: holder}
+		}
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("checking serial: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO serials (item_id, serial, owner_id) VALUES (?, ?, ?)`, itemID, serial, ownerID,
+		); err != nil {
+			return nil, fmt.Errorf("assigning serial: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO inventory (item_id, owner_id, quantity, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT (item_id, owner_id) DO UPDATE SET quantity = quantity + ?, updated_at = CURRENT_TIMESTAMP`,
+		itemID, ownerID, len(serials), len(serials),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("adding stock: %w", err)
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing serial assignment: %w", err)
+	}
+	return listSerials(ctx, db, itemID, 0, serials)
+}
+
+// ListSerials returns an item's serials with their holders, ordered by
+// serial. An ownerID above zero limits them to that owner.
+func ListSerials(ctx context.Context, db *sql.DB, itemID, ownerID int64) ([]model.Serial, error) {
+	return listSerials(ctx, db, itemID, ownerID, nil)
+}
+
+// listSerials is ListSerials, further limited to the given serials when
+// there are any.
+func listSerials(ctx context.Context, db *sql.DB, itemID, ownerID int64, only []string) ([]model.Serial, error) {
+	q := `SELECT s.item_id, s.serial, s.owner_id, o.name, o.type, s.updated_at
+	      FROM serials s
+	      JOIN owners o ON o.id = s.owner_id
+	      WHERE s.item_id = ?`
+	args := []any{itemID}
+	if ownerID > 0 {
+		q += ` AND s.owner_id = ?`
+		args = append(args, ownerID)
+	}
+	if len(only) > 0 {
+		q += ` AND s.serial IN (?` + strings.Repeat(", ?", len(only)-1) + `)`
+		for _, s := range only {
+			args = append(args, s)
+		}
+	}
+	q += ` ORDER BY s.serial`
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing serials: %w", err)
+	}
+	defer rows.Close()
+
+	var serials []model.Serial
+	for rows.Next() {
+		var s model.Serial
+		if err := rows.Scan(&s.ItemID, &s.Serial, &s.OwnerID, &s.OwnerName, &s.OwnerType, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning serial: %w", err)
+		}
+		serials = append(serials, s)
+	}
+	return serials, rows.Err()
+}
+
+// TransferSerials moves specific serials of a serialized item from one owner
+// to another in one transaction, updating both quantities and recording a
+// single transfer of len(serials) units. Every serial must be held by
+// fromOwnerID; otherwise a *SerialNotHeldError is returned and nothing moves.
+// serials should come from model.NormalizeSerials.
+func TransferSerials(ctx context.Context, db *sql.DB, itemID, fromOwnerID, toOwnerID int64, serials []string, notes string, transferredBy *int64) (*model.Transfer, error) {
+	if fromOwnerID == toOwnerID {
+		return nil, invalidf("cannot transfer to same owner")
+	}
+	if len(serials) == 0 {
+		return nil, invalidf("at least one serial required")
+	}
+
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := requireSerialized(ctx, tx, itemID); err != nil {
+		return nil, err
+	}
+	var destinationExists bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM owners WHERE id = ? AND deleted_at IS NULL)`, toOwnerID,
+	).Scan(&destinationExists)
+	if err != nil {
+		return nil, fmt.Errorf("checking destination owner: %w", err)
+	}
+	if !destinationExists {
+		return nil, invalidf("destination owner not found")
+	}
+
+	for _, serial := range serials {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE serials SET owner_id = ?, updated_at = CURRENT_TIMESTAMP
+			 WHERE item_id = ? AND serial = ? AND owner_id = ?`,
+			toOwnerID, itemID, serial, fromOwnerID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("moving serial: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("checking rows affected: %w", err)
+		}
+		if n == 0 {
+			return nil, &SerialNotHeldError{Serial: serial}
+		}
+	}
+
+	available, err := availableForTransfer(ctx, tx, itemID, fromOwnerID, len(serials))
+	if err != nil {
+		return nil, err
+	}
+	transferID, err := moveStock(ctx, tx, itemID, fromOwnerID, toOwnerID, len(serials), available, notes, transferredBy)
+	if err != nil {
+		return nil, err
+	}
+	for _, serial := range serials {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO transfer_serials (transfer_id, serial) VALUES (?, ?)`, transferID, serial,
+		); err != nil {
+			return nil, fmt.Errorf("recording transfer serial: %w", err)
+		}
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing serial transfer: %w", err)
+	}
+
+	t, err := GetTransfer(ctx, db, transferID)
+	if err != nil || t == nil {
+		return t, err
+	}
+	t.Serials = serials
+	return t, nil
+}
+
+// RemoveSerial deletes a serial of an item, e.g. a unit that was lost or
+// scrapped, and takes one from its holder's quantity.
+func RemoveSerial(ctx context.Context, db *sql.DB, itemID int64, serial string) error {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var ownerID int64
+	err = tx.QueryRowContext(ctx,
+		`DELETE FROM serials WHERE item_id = ? AND serial = ? RETURNING owner_id`, itemID, serial,
+	).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return invalidf("serial not found")
+	}
+	if err != nil {
+		return fmt.Errorf("removing serial: %w", err)
+	}
+
+	available, err := availableForTransfer(ctx, tx, itemID, ownerID, 1)
+	if err != nil {
+		return err
+	}
+	if available == 1 {
+		err = removeInventoryRow(ctx, tx, itemID, ownerID)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE inventory SET quantity = quantity - 1, updated_at = CURRENT_TIMESTAMP WHERE item_id = ? AND owner_id = ?`,
+			itemID, ownerID,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("updating inventory: %w", err)
+	}
+
+	if err := commit(tx); err != nil {
+		return fmt.Errorf("committing serial removal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestSetItemSerialized(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Laptop", "", "")
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, room.ID, 2, false, nil)
+
+	if err := SetItemSerialized(ctx, database, item.ID, true); err == nil {
+		t.Error("expected an item in stock to refuse serial tracking")
+	}
+	AdjustInventory(ctx, database, item.ID, room.ID, -2, false, "", nil)
+	if err := SetItemSerialized(ctx, database, item.ID, true); err != nil {
+		t.Fatalf("SetItemSerialized: %v", err)
+	}
+	if got, _ := GetItem(ctx, database, item.ID); !got.Serialized {
+		t.Error("expected item to be serialized")
+	}
+
+	AssignSerials(ctx, database, item.ID, room.ID, []string{"SN1"})
+	if err := SetItemSerialized(ctx, database, item.ID, false); err == nil {
+		t.Error("expected an item with serials to refuse quantity tracking")
+	}
+	if err := SetItemSerialized(ctx, database, 999, true); err == nil {
+		t.Error("expected error for missing item")
+	}
+}
+
+func TestSerializedItemRejectsQuantities(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Laptop", "", "")
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	ana, _ := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	SetItemSerialized(ctx, database, item.ID, true)
+	AssignSerials(ctx, database, item.ID, room.ID, []string{"SN1", "SN2"})
+
+	checks := []struct {
+		name string
+		run  func() error
+	}{
+		{"AddStock", func() error { return AddStock(ctx, database, item.ID, room.ID, 1, false, nil) }},
+		{"AddStockBatch", func() error {
+			_, err := AddStockBatch(ctx, database, room.ID, []model.StockLine{{ItemID: item.ID, Quantity: 1}}, nil)
+			return err
+		}},
+		{"AdjustInventory", func() error { return AdjustInventory(ctx, database, item.ID, room.ID, -1, false, "", nil) }},
+		{"SetStock", func() error { _, err := SetStock(ctx, database, item.ID, room.ID, 5, false, nil); return err }},
+		{"CreateTransfer", func() error {
+			_, err := CreateTransfer(ctx, database, item.ID, room.ID, ana.ID, 1, false, "", nil)
+			return err
+		}},
+		{"CheckTransfer", func() error { _, err := CheckTransfer(ctx, database, item.ID, room.ID, ana.ID, 1, false); return err }},
+		{"FulfillTransfer", func() error {
+			_, err := FulfillTransfer(ctx, database, item.ID, ana.ID, 1, false, nil, "", nil)
+			return err
+		}},
+	}
+	for _, c := range checks {
+		if err := c.run(); !errors.Is(err, ErrSerializedItem) {
+			t.Errorf("%s: expected ErrSerializedItem, got %v", c.name, err)
+		}
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, room.ID); got != 2 {
+		t.Errorf("expected room to still hold 2, got %d", got)
+	}
+}
+
+func TestAssignSerialsPreventsDoubleAssignment(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Laptop", "", "")
+	plain, _ := CreateItem(ctx, database, "Cable", "", "")
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	ana, _ := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	SetItemSerialized(ctx, database, item.ID, true)
+
+	assigned, err := AssignSerials(ctx, database, item.ID, room.ID, []string{"SN2", "SN1"})
+	if err != nil {
+		t.Fatalf("AssignSerials: %v", err)
+	}
+	if len(assigned) != 2 || assigned[0].Serial != "SN1" || assigned[0].OwnerName != "Room" {
+		t.Errorf("unexpected assigned serials: %+v", assigned)
+	}
+
+	// SN1 is already held by Room, so the whole request is rejected.
+	_, err = AssignSerials(ctx, database, item.ID, ana.ID, []string{"SN3", "SN1"})
+	var taken *SerialAssignedError
+	if !errors.As(err, &taken) || taken.Serial != "SN1" || taken.OwnerID != room.ID {
+		t.Fatalf("expected SerialAssignedError for SN1, got %v", err)
+	}
+	if serials, _ := ListSerials(ctx, database, item.ID, ana.ID); len(serials) != 0 {
+		t.Errorf("expected nothing assigned to Ana, got %+v", serials)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, room.ID); got != 2 {
+		t.Errorf("expected room quantity 2, got %d", got)
+	}
+
+	if _, err := AssignSerials(ctx, database, plain.ID, room.ID, []string{"X"}); err == nil {
+		t.Error("expected error assigning serials to a quantity item")
+	}
+}
+
+func TestTransferSerials(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Laptop", "", "")
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	ana, _ := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	SetItemSerialized(ctx, database, item.ID, true)
+	AssignSerials(ctx, database, item.ID, room.ID, []string{"SN1", "SN2", "SN3"})
+
+	transfer, err := TransferSerials(ctx, database, item.ID, room.ID, ana.ID, []string{"SN1", "SN3"}, "loan", nil)
+	if err != nil {
+		t.Fatalf("TransferSerials: %v", err)
+	}
+	if transfer.Quantity != 2 || len(transfer.Serials) != 2 {
+		t.Errorf("expected a transfer of 2 serials, got %+v", transfer)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, ana.ID); got != 2 {
+		t.Errorf("expected Ana to hold 2, got %d", got)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, room.ID); got != 1 {
+		t.Errorf("expected room to hold 1, got %d", got)
+	}
+	if serials, _ := ListSerials(ctx, database, item.ID, ana.ID); len(serials) != 2 || serials[0].Serial != "SN1" || serials[1].Serial != "SN3" {
+		t.Errorf("expected Ana to hold SN1 and SN3, got %+v", serials)
+	}
+
+	// SN1 is now Ana's, so the room cannot send it; SN2 must not move either.
+	_, err = TransferSerials(ctx, database, item.ID, room.ID, ana.ID, []string{"SN2", "SN1"}, "", nil)
+	var notHeld *SerialNotHeldError
+	if !errors.As(err, &notHeld) || notHeld.Serial != "SN1" {
+		t.Fatalf("expected SerialNotHeldError for SN1, got %v", err)
+	}
+	if serials, _ := ListSerials(ctx, database, item.ID, room.ID); len(serials) != 1 || serials[0].Serial != "SN2" {
+		t.Errorf("expected room to still hold SN2, got %+v", serials)
+	}
+
+	if _, err := TransferSerials(ctx, database, item.ID, ana.ID, room.ID, []string{"SN9"}, "", nil); !errors.As(err, &notHeld) {
+		t.Errorf("expected SerialNotHeldError for unknown serial, got %v", err)
+	}
+}
+
+func TestRemoveSerial(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Laptop", "", "")
+	room, _ := CreateOwner(ctx, database, "Room", model.OwnerTypeLocation)
+	SetItemSerialized(ctx, database, item.ID, true)
+	AssignSerials(ctx, database, item.ID, room.ID, []string{"SN1"})
+
+	if err := RemoveSerial(ctx, database, item.ID, "SN1"); err != nil {
+		t.Fatalf("RemoveSerial: %v", err)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, room.ID); got != 0 {
+		t.Errorf("expected room to hold 0, got %d", got)
+	}
+	if err := RemoveSerial(ctx, database, item.ID, "SN1"); err == nil {
+		t.Error("expected error removing a missing serial")
+	}
+	if err := SetItemSerialized(ctx, database, item.ID, false); err != nil {
+		t.Errorf("expected item without serials to switch back, got %v", err)
+	}
+}
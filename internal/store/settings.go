@@ -19,7 +19,7 @@ func GetJWTSecret(ctx context.Context, db *sql.DB) (string, error) {
 	}
 	candidate := hex.EncodeToString(buf)
 
-	_, err := db.ExecContext(ctx,
+	_, err := exec(ctx, db,
 		`INSERT OR IGNORE INTO settings (key, value) VALUES ('jwt_secret', ?)`,
 		candidate,
 	)
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+const stockSnapshotSelect = `
+	SELECT s.id, s.taken_at, s.taken_by, s.notes,
+	       (SELECT COUNT(*) FROM stock_snapshot_lines l WHERE l.snapshot_id = s.id)
+	FROM stock_snapshots s`
+
+// CreateStockSnapshot copies every non-zero inventory balance into a new
+// snapshot.
+func CreateStockSnapshot(ctx context.Context, db *sql.DB, notes string, takenBy *int64) (*model.StockSnapshot, error) {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO stock_snapshots (taken_by, notes) VALUES (?, ?)`, takenBy, notes,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating stock snapshot: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("getting stock snapshot id: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO stock_snapshot_lines (snapshot_id, item_id, owner_id, quantity)
+		 SELECT ?, item_id, owner_id, quantity FROM inventory WHERE quantity != 0`, id,
+	); err != nil {
+		return nil, fmt.Errorf("copying inventory into stock snapshot: %w", err)
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing stock snapshot: %w", err)
+	}
+	return GetStockSnapshot(ctx, db, id)
+}
+
+// ListStockSnapshots returns all stock snapshots, newest first.
+func ListStockSnapshots(ctx context.Context, db *sql.DB) ([]model.StockSnapshot, error) {
+	rows, err := db.QueryContext(ctx, stockSnapshotSelect+` ORDER BY s.id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("listing stock snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []model.StockSnapshot
+	for rows.Next() {
+		var s model.StockSnapshot
+		if err := rows.Scan(&s.ID, &s.TakenAt, &s.TakenBy, &s.Notes, &s.Lines); err != nil {
+			return nil, fmt.Errorf("scanning stock snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// GetStockSnapshot returns a stock snapshot by ID, or nil if it does not
+// exist.
+func GetStockSnapshot(ctx context.Context, db *sql.DB, id int64) (*model.StockSnapshot, error) {
+	s := &model.StockSnapshot{}
+	err := db.QueryRowContext(ctx, stockSnapshotSelect+` WHERE s.id = ?`, id).Scan(
+		&s.ID, &s.TakenAt, &s.TakenBy, &s.Notes, &s.Lines)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting stock snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// DiffStockSnapshots returns the item/owner balances that differ between
+// snapshot fromID and snapshot toID, or the current inventory if toID is 0,
+// ordered by item and owner name. Returns nil if either snapshot does not
+// exist.
+func DiffStockSnapshots(ctx context.Context, db *sql.DB, fromID, toID int64) (*model.SnapshotDiff, error) {
+	// Snapshots never change once taken and the current inventory is read
+	// in one query, so no transaction is needed.
+	diff := &model.SnapshotDiff{Changes: []model.SnapshotChange{}}
+	from, err := GetStockSnapshot(ctx, db, fromID)
+	if err != nil || from == nil {
+		return nil, err
+	}
+	diff.From = *from
+	if toID != 0 {
+		if diff.To, err = GetStockSnapshot(ctx, db, toID); err != nil || diff.To == nil {
+			return nil, err
+		}
+	}
+
+	before, err := snapshotBalances(ctx, db, fromID)
+	if err != nil {
+		return nil, err
+	}
+	after, err := snapshotBalances(ctx, db, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ item, owner int64 }
+	balances := make(map[key][2]*model.Inventory)
+	for i := range before {
+		k := key{before[i].ItemID, before[i].OwnerID}
+		balances[k] = [2]*model.Inventory{&before[i], nil}
+	}
+	for i := range after {
+		k := key{after[i].ItemID, after[i].OwnerID}
+		b := balances[k]
+		b[1] = &after[i]
+		balances[k] = b
+	}
+
+	for _, b := range balances {
+		var c model.SnapshotChange
+		switch {
+		case b[0] == nil:
+			c.Change = model.SnapshotAdded
+		case b[1] == nil:
+			c.Change = model.SnapshotRemoved
+		case b[0].Quantity != b[1].Quantity:
+			c.Change = model.SnapshotChanged
+		default:
+			continue
+		}
+		inv := cmp.Or(b[1], b[0])
+		c.ItemID, c.OwnerID = inv.ItemID, inv.OwnerID
+		c.ItemName, c.OwnerName, c.OwnerType = inv.ItemName, inv.OwnerName, inv.OwnerType
+		c.Divisible = inv.Divisible
+		if b[0] != nil {
+			c.FromQuantity = b[0].Quantity
+		}
+		if b[1] != nil {
+			c.ToQuantity = b[1].Quantity
+		}
+		c.Delta = c.ToQuantity - c.FromQuantity
+		diff.Changes = append(diff.Changes, c)
+	}
+	slices.SortFunc(diff.Changes, func(a, b model.SnapshotChange) int {
+		return cmp.Or(
+			cmp.Compare(a.ItemName, b.ItemName),
+			cmp.Compare(a.OwnerName, b.OwnerName),
+			cmp.Compare(a.ItemID, b.ItemID),
+			cmp.Compare(a.OwnerID, b.OwnerID),
+		)
+	})
+	return diff, nil
+}
+
+// snapshotBalances returns the non-zero balances of snapshot id, or of the
+// current inventory if id is 0.
+func snapshotBalances(ctx context.Context, db *sql.DB, id int64) ([]model.Inventory, error) {
+	var rows *sql.Rows
+	var err error
+	if id == 0 {
+		rows, err = db.QueryContext(ctx, inventorySelect+` WHERE inv.quantity != 0`)
+	} else {
+		rows, err = db.QueryContext(ctx,
+			`SELECT l.item_id, l.owner_id, l.quantity,
+			        i.name, o.name, o.type, i.divisible
+			 FROM stock_snapshot_lines l
+			 JOIN items i ON i.id = l.item_id
+			 JOIN owners o ON o.id = l.owner_id
+			 WHERE l.snapshot_id = ?`, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot balances: %w", err)
+	}
+	defer rows.Close()
+
+	return scanInventory(rows)
+}
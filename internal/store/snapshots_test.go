@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestStockSnapshotDiff(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	ana, _ := CreateOwner(ctx, database, "Ana", model.OwnerTypePerson)
+	laptop, _ := CreateItem(ctx, database, "Laptop", "", "")
+	cable, _ := CreateItem(ctx, database, "Cable", "", "")
+	chair, _ := CreateItem(ctx, database, "Chair", "", "")
+	AddStock(ctx, database, laptop.ID, storage.ID, 3, false, nil)
+	AddStock(ctx, database, cable.ID, storage.ID, 10, false, nil)
+
+	first, err := CreateStockSnapshot(ctx, database, "start of quarter", nil)
+	if err != nil {
+		t.Fatalf("CreateStockSnapshot: %v", err)
+	}
+	if first.Lines != 2 || first.Notes != "start of quarter" {
+		t.Errorf("unexpected snapshot: %+v", first)
+	}
+
+	if _, err := CreateTransfer(ctx, database, laptop.ID, storage.ID, ana.ID, 1, false, "", nil); err != nil {
+		t.Fatalf("CreateTransfer: %v", err)
+	}
+	if err := AdjustInventory(ctx, database, cable.ID, storage.ID, -4, false, "lost", nil); err != nil {
+		t.Fatalf("AdjustInventory: %v", err)
+	}
+	AddStock(ctx, database, chair.ID, storage.ID, 2, false, nil)
+
+	second, err := CreateStockSnapshot(ctx, database, "", nil)
+	if err != nil {
+		t.Fatalf("CreateStockSnapshot: %v", err)
+	}
+
+	if _, err := CreateTransfer(ctx, database, laptop.ID, storage.ID, ana.ID, 2, false, "", nil); err != nil {
+		t.Fatalf("CreateTransfer: %v", err)
+	}
+
+	type change struct {
+		item, owner, kind string
+		from, to, delta   int
+	}
+	check := func(name string, diff *model.SnapshotDiff, want []change) {
+		t.Helper()
+		var got []change
+		for _, c := range diff.Changes {
+			got = append(got, change{c.ItemName, c.OwnerName, c.Change, c.FromQuantity, c.ToQuantity, c.Delta})
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %v, got %v", name, want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: change %d: expected %v, got %v", name, i, want[i], got[i])
+			}
+		}
+	}
+
+	diff, err := DiffStockSnapshots(ctx, database, first.ID, second.ID)
+	if err != nil {
+		t.Fatalf("DiffStockSnapshots: %v", err)
+	}
+	if diff.From.ID != first.ID || diff.To == nil || diff.To.ID != second.ID {
+		t.Errorf("expected the diff to name both snapshots, got %+v and %+v", diff.From, diff.To)
+	}
+	check("first to second", diff, []change{
+		{"Cable", "Storage", model.SnapshotChanged, 10, 6, -4},
+		{"Chair", "Storage", model.SnapshotAdded, 0, 2, 2},
+		{"Laptop", "Ana", model.SnapshotAdded, 0, 1, 1},
+		{"Laptop", "Storage", model.SnapshotChanged, 3, 2, -1},
+	})
+
+	diff, err = DiffStockSnapshots(ctx, database, second.ID, 0)
+	if err != nil {
+		t.Fatalf("DiffStockSnapshots: %v", err)
+	}
+	if diff.To != nil {
+		t.Errorf("expected no to snapshot for the current inventory, got %+v", diff.To)
+	}
+	check("second to current", diff, []change{
+		{"Laptop", "Ana", model.SnapshotChanged, 1, 3, 2},
+		{"Laptop", "Storage", model.SnapshotRemoved, 2, 0, -2},
+	})
+
+	diff, err = DiffStockSnapshots(ctx, database, second.ID, second.ID)
+	if err != nil || len(diff.Changes) != 0 {
+		t.Errorf("expected no changes against itself, got %v, %v", diff, err)
+	}
+
+	for _, ids := range [][2]int64{{9999, second.ID}, {first.ID, 9999}} {
+		if diff, err := DiffStockSnapshots(ctx, database, ids[0], ids[1]); diff != nil || err != nil {
+			t.Errorf("expected nil for a missing snapshot %v, got %v, %v", ids, diff, err)
+		}
+	}
+
+	snapshots, err := ListStockSnapshots(ctx, database)
+	if err != nil || len(snapshots) != 2 || snapshots[0].ID != second.ID || snapshots[0].Lines != 4 {
+		t.Errorf("expected both snapshots newest first, got %+v, %v", snapshots, err)
+	}
+}
+
+func TestStockSnapshotDiffAcrossDivisibleToggle(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	flour, _ := CreateItem(ctx, database, "Flour", "", "")
+	AddStock(ctx, database, flour.ID, storage.ID, 5, false, nil)
+
+	snapshot, err := CreateStockSnapshot(ctx, database, "", nil)
+	if err != nil {
+		t.Fatalf("CreateStockSnapshot: %v", err)
+	}
+	if err := SetItemDivisible(ctx, database, flour.ID, true); err != nil {
+		t.Fatalf("SetItemDivisible: %v", err)
+	}
+
+	diff, err := DiffStockSnapshots(ctx, database, snapshot.ID, 0)
+	if err != nil {
+		t.Fatalf("DiffStockSnapshots: %v", err)
+	}
+	if len(diff.Changes) != 0 {
+		t.Errorf("expected no changes after making the item divisible, got %+v", diff.Changes)
+	}
+
+	// A fractional snapshot line blocks switching back, like fractional stock.
+	if err := AdjustInventory(ctx, database, flour.ID, storage.ID, -500, true, "spilled", nil); err != nil {
+		t.Fatalf("AdjustInventory: %v", err)
+	}
+	fractional, _ := CreateStockSnapshot(ctx, database, "", nil)
+	AddStock(ctx, database, flour.ID, storage.ID, 500, true, nil)
+	if err := SetItemDivisible(ctx, database, flour.ID, false); err == nil {
+		t.Error("expected a fractional snapshot line to block switching off divisible")
+	}
+
+	database.Exec(`DELETE FROM stock_snapshot_lines WHERE snapshot_id = ?`, fractional.ID)
+	if err := SetItemDivisible(ctx, database, flour.ID, false); err != nil {
+		t.Fatalf("SetItemDivisible off: %v", err)
+	}
+	diff, err = DiffStockSnapshots(ctx, database, snapshot.ID, 0)
+	if err != nil || len(diff.Changes) != 0 {
+		t.Errorf("expected no changes after switching back, got %+v, %v", diff, err)
+	}
+}
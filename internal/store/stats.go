@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// GetStats returns current item/owner counts and transfer activity between
+// from (inclusive) and to (exclusive). Divisible items count in whole units
+// (see wholeUnits).
+func GetStats(ctx context.Context, db *sql.DB, from, to time.Time) (*model.Stats, error) {
+	s := &model.Stats{Activity: model.StatsActivity{From: from, To: to}}
+
+	err := db.QueryRowContext(ctx,
+		`SELECT
+		   (SELECT COUNT(*) FROM items WHERE deleted_at IS NULL),
+		   (SELECT COUNT(*) FROM owners WHERE deleted_at IS NULL AND type = 'person'),
+		   (SELECT COUNT(*) FROM owners WHERE deleted_at IS NULL AND type = 'location'),
+		   (SELECT COALESCE(SUM(`+wholeUnits("inv.quantity")+`), 0)
+		    FROM inventory inv JOIN items i ON i.id = inv.item_id)`,
+	).Scan(&s.Items, &s.People, &s.Locations, &s.TotalQuantity)
+	if err != nil {
+		return nil, fmt.Errorf("counting current state: %w", err)
+	}
+
+	fromStr := from.UTC().Format(sqliteTimeFormat)
+	toStr := to.UTC().Format(sqliteTimeFormat)
+	err = db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(`+wholeUnits("t.quantity")+`), 0), COUNT(DISTINCT t.item_id)
+		 FROM transfers t JOIN items i ON i.id = t.item_id
+		 WHERE t.transferred_at >= ? AND t.transferred_at < ?`,
+		fromStr, toStr,
+	).Scan(&s.Activity.Transfers, &s.Activity.QuantityMoved, &s.Activity.ItemsMoved)
+	if err != nil {
+		return nil, fmt.Errorf("counting transfer activity: %w", err)
+	}
+
+	err = db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM status_changes WHERE created_at >= ? AND created_at < ?`,
+		fromStr, toStr,
+	).Scan(&s.Activity.StatusChanges)
+	if err != nil {
+		return nil, fmt.Errorf("counting status changes: %w", err)
+	}
+
+	return s, nil
+}
+
+// wholeUnits is an SQL expression for a quantity column in whole units, for
+// totals across items: divisible items (the items table aliased as i must be
+// joined) count their thousandths rounded down.
+func wholeUnits(column string) string {
+	return fmt.Sprintf("CASE WHEN i.divisible THEN %s / %d ELSE %s END", column, model.QuantityScale, column)
+}
+
+// RecordInventorySnapshot stores the units currently held per item status as
+// the snapshot for now's day (UTC). A later call on the same day replaces it,
+// so the last snapshot of a day stands for that day's end.
+func RecordInventorySnapshot(ctx context.Context, db *sql.DB, now time.Time) error {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	day := now.UTC().Format(time.DateOnly)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM inventory_snapshots WHERE day = ?`, day); err != nil {
+		return fmt.Errorf("replacing inventory snapshot: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO inventory_snapshots (day, status, units) `+unitsByStatus("?"),
+		day,
+	)
+	if err != nil {
+		return fmt.Errorf("recording inventory snapshot: %w", err)
+	}
+
+	if err := commit(tx); err != nil {
+		return fmt.Errorf("committing inventory snapshot: %w", err)
+	}
+	return nil
+}
+
+// GetInventoryTrend returns the total units held at the end of each of the
+// last days days, oldest first, ending with today's live totals. Days with a
+// snapshot use it and days between snapshots carry the previous value
+// forward. Days before the first snapshot are missing: no ledger records
+// every stock change, so they cannot be worked out.
+func GetInventoryTrend(ctx context.Context, db *sql.DB, days int, now time.Time) ([]model.InventoryTrendPoint, error) {
+	if days < 1 {
+		return nil, invalidf("days must be at least 1")
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	start := today.AddDate(0, 0, 1-days)
+	todayStr, startStr := today.Format(time.DateOnly), start.Format(time.DateOnly)
+
+	// Snapshots in the window, plus the last one before it to carry forward.
+	var before sql.NullString
+	err := db.QueryRowContext(ctx,
+		`SELECT MAX(day) FROM inventory_snapshots WHERE day < ?`, startStr,
+	).Scan(&before)
+	if err != nil {
+		return nil, fmt.Errorf("finding earlier snapshot: %w", err)
+	}
+	from := startStr
+	if before.Valid {
+		from = before.String
+	}
+	snapshots, err := scanUnitsByStatus(db.QueryContext(ctx,
+		`SELECT day, status, units FROM inventory_snapshots WHERE day >= ? AND day < ?`,
+		from, todayStr,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("listing inventory snapshots: %w", err)
+	}
+	live, err := scanUnitsByStatus(db.QueryContext(ctx, unitsByStatus("''")))
+	if err != nil {
+		return nil, fmt.Errorf("summing inventory: %w", err)
+	}
+
+	points := make([]model.InventoryTrendPoint, 0, days)
+	var previous map[string]int
+	if before.Valid {
+		previous = snapshots[before.String]
+	}
+	for d := start; !d.After(today); d = d.AddDate(0, 0, 1) {
+		day := d.Format(time.DateOnly)
+		values, source := snapshots[day], model.TrendSourceSnapshot
+		switch {
+		case day == todayStr:
+			values, source = live[""], model.TrendSourceLive
+		case values == nil && previous == nil:
+			source = model.TrendSourceMissing
+		case values == nil:
+			values, source = previous, model.TrendSourceCarried
+		}
+		point := model.InventoryTrendPoint{Date: day, Source: source}
+		if source != model.TrendSourceMissing {
+			units := 0
+			for _, u := range values {
+				units += u
+			}
+			point.Units = &units
+			point.ByStatus = maps.Clone(values)
+			if point.ByStatus == nil {
+				point.ByStatus = map[string]int{}
+			}
+		}
+		points = append(points, point)
+		previous = values
+	}
+	return points, nil
+}
+
+// unitsByStatus is a query for the units currently held per item status, in
+// whole units like GetStats, with key as the first column. Every status is
+// listed, with 0 if nothing is held.
+func unitsByStatus(key string) string {
+	return `SELECT ` + key + `, s.name, COALESCE(SUM(` + wholeUnits("inv.quantity") + `), 0)
+		FROM statuses s
+		LEFT JOIN items i ON i.status = s.name
+		LEFT JOIN inventory inv ON inv.item_id = i.id
+		GROUP BY s.name`
+}
+
+// scanUnitsByStatus reads (key, status, units) rows into a map by key, then
+// status.
+func scanUnitsByStatus(rows *sql.Rows, err error) (map[string]map[string]int, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]map[string]int)
+	for rows.Next() {
+		var key, status string
+		var units int
+		if err := rows.Scan(&key, &status, &units); err != nil {
+			return nil, err
+		}
+		if result[key] == nil {
+			result[key] = make(map[string]int)
+		}
+		result[key][status] = units
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestGetStatsScopesActivityToRange(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	widget, _ := CreateItem(ctx, database, "Widget", "", "")
+	gadget, _ := CreateItem(ctx, database, "Gadget", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	alice, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, widget.ID, storage.ID, 10, false, nil)
+	AddStock(ctx, database, gadget.ID, storage.ID, 5, false, nil)
+
+	CreateTransfer(ctx, database, widget.ID, storage.ID, alice.ID, 2, false, "", nil)
+	CreateTransfer(ctx, database, widget.ID, storage.ID, alice.ID, 3, false, "", nil)
+	CreateTransfer(ctx, database, gadget.ID, storage.ID, alice.ID, 1, false, "", nil)
+	database.ExecContext(ctx, `UPDATE transfers SET transferred_at = '2024-02-10 12:00:00' WHERE id = 1`)
+	database.ExecContext(ctx, `UPDATE transfers SET transferred_at = '2024-05-01 12:00:00' WHERE id = 2`)
+	database.ExecContext(ctx, `UPDATE transfers SET transferred_at = '2024-03-31 23:59:59' WHERE id = 3`)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	stats, err := GetStats(ctx, database, from, to)
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+
+	if stats.Items != 2 || stats.People != 1 || stats.Locations != 1 || stats.TotalQuantity != 15 {
+		t.Errorf("unexpected current-state counts: %+v", stats)
+	}
+	a := stats.Activity
+	if a.Transfers != 2 || a.QuantityMoved != 3 || a.ItemsMoved != 2 {
+		t.Errorf("expected 2 transfers moving 3 of 2 items in Q1, got %+v", a)
+	}
+	if !a.From.Equal(from) || !a.To.Equal(to) {
+		t.Errorf("expected range to be echoed, got %v – %v", a.From, a.To)
+	}
+}
+
+func TestInventoryTrend(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	widget, _ := CreateItem(ctx, database, "Widget", "", "")
+	gadget, _ := CreateItem(ctx, database, "Gadget", "", "")
+	storage, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	AddStock(ctx, database, widget.ID, storage.ID, 10, false, nil)
+	AddStock(ctx, database, gadget.ID, storage.ID, 5, false, nil)
+	UpdateItem(ctx, database, gadget.ID, "Gadget", "", "", model.ItemStatusDamaged, "", nil)
+	// Removals before today are not used: without a snapshot the day is
+	// missing rather than guessed.
+	database.ExecContext(ctx,
+		`INSERT INTO inventory_adjustments (item_id, owner_id, delta, kind, created_at) VALUES (?, ?, -3, 'decommissioned', '2024-06-06 10:00:00')`,
+		widget.ID, storage.ID)
+	now := time.Date(2024, 6, 10, 15, 0, 0, 0, time.UTC)
+
+	// units -1 marks a missing day.
+	type want struct {
+		units, active int
+		source        string
+	}
+	check := func(days int, expected []want) {
+		t.Helper()
+		points, err := GetInventoryTrend(ctx, database, days, now)
+		if err != nil {
+			t.Fatalf("GetInventoryTrend: %v", err)
+		}
+		if len(points) != len(expected) {
+			t.Fatalf("expected %d points, got %d", len(expected), len(points))
+		}
+		for i, p := range points {
+			w := expected[i]
+			if day := now.AddDate(0, 0, i+1-days).Format(time.DateOnly); p.Date != day {
+				t.Errorf("point %d: expected date %s, got %s", i, day, p.Date)
+			}
+			units := -1
+			if p.Units != nil {
+				units = *p.Units
+			}
+			if units != w.units || p.ByStatus[model.ItemStatusActive] != w.active || p.Source != w.source {
+				t.Errorf("%s: expected %d units (%d active) from %s, got %d (%d) from %s",
+					p.Date, w.units, w.active, w.source, units, p.ByStatus[model.ItemStatusActive], p.Source)
+			}
+			if w.source == model.TrendSourceMissing && p.ByStatus != nil {
+				t.Errorf("%s: expected no per-status figures for a missing day, got %v", p.Date, p.ByStatus)
+			}
+		}
+	}
+
+	// Without snapshots only today is known.
+	check(7, []want{
+		{-1, 0, model.TrendSourceMissing}, // June 4th
+		{-1, 0, model.TrendSourceMissing},
+		{-1, 0, model.TrendSourceMissing},
+		{-1, 0, model.TrendSourceMissing},
+		{-1, 0, model.TrendSourceMissing},
+		{-1, 0, model.TrendSourceMissing},
+		{15, 10, model.TrendSourceLive}, // today
+	})
+
+	// A snapshot on June 7th is used as is and carried forward over the days
+	// without one; the days before it stay missing.
+	if err := RecordInventorySnapshot(ctx, database, time.Date(2024, 6, 7, 23, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("RecordInventorySnapshot: %v", err)
+	}
+	database.ExecContext(ctx, `UPDATE inventory_snapshots SET units = 30 WHERE day = '2024-06-07' AND status = 'active'`)
+	check(7, []want{
+		{-1, 0, model.TrendSourceMissing},
+		{-1, 0, model.TrendSourceMissing},
+		{-1, 0, model.TrendSourceMissing},
+		{35, 30, model.TrendSourceSnapshot},
+		{35, 30, model.TrendSourceCarried},
+		{35, 30, model.TrendSourceCarried},
+		{15, 10, model.TrendSourceLive},
+	})
+
+	// A snapshot before the window is carried into it.
+	check(2, []want{
+		{35, 30, model.TrendSourceCarried},
+		{15, 10, model.TrendSourceLive},
+	})
+
+	if _, err := GetInventoryTrend(ctx, database, 0, now); err == nil {
+		t.Error("expected error for 0 days")
+	}
+}
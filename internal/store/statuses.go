@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// ListStatuses returns every item status, built-in ones first, then custom
+// ones in the order they were added.
+func ListStatuses(ctx context.Context, db *sql.DB) ([]model.ItemStatus, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT name, created_at FROM statuses
+		 ORDER BY CASE name WHEN 'active' THEN 0 WHEN 'damaged' THEN 1 WHEN 'lost' THEN 2 WHEN 'removed' THEN 3 ELSE 4 END,
+		          created_at, name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing statuses: %w", err)
+	}
+	defer rows.Close()
+
+	var statuses []model.ItemStatus
+	for rows.Next() {
+		var s model.ItemStatus
+		if err := rows.Scan(&s.Name, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning status: %w", err)
+		}
+		s.Builtin = model.BuiltinStatus(s.Name)
+		statuses = append(statuses, s)
+	}
+	return statuses, rows.Err()
+}
+
+// CreateStatus adds a custom item status. The name must already be
+// normalized. Returns an error if the status already exists.
+func CreateStatus(ctx context.Context, db *sql.DB, name string) (*model.ItemStatus, error) {
+	result, err := exec(ctx, db, `INSERT OR IGNORE INTO statuses (name) VALUES (?)`, name)
+	if err != nil {
+		return nil, fmt.Errorf("creating status: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("checking rows affected: %w", err)
+	}
+	if n == 0 {
+		return nil, invalidf("status already exists")
+	}
+
+	s := &model.ItemStatus{Name: name}
+	err = db.QueryRowContext(ctx, `SELECT created_at FROM statuses WHERE name = ?`, name).Scan(&s.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("getting status: %w", err)
+	}
+	return s, nil
+}
+
+// requireStatus checks that status is a known item status.
+func requireStatus(ctx context.Context, tx *sql.Tx, status string) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM statuses WHERE name = ?)`, status,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("checking status: %w", err)
+	}
+	if !exists {
+		return invalidf("invalid status")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestCustomStatus(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	statuses, err := ListStatuses(ctx, database)
+	if err != nil {
+		t.Fatalf("ListStatuses: %v", err)
+	}
+	if len(statuses) != 4 || statuses[0].Name != model.ItemStatusActive || !statuses[3].Builtin {
+		t.Fatalf("expected the four built-in statuses, got %+v", statuses)
+	}
+
+	item, _ := CreateItem(ctx, database, "Drill", "", "")
+	if err := UpdateItem(ctx, database, item.ID, item.Name, "", "", "in_repair", "", nil); err == nil || err.Error() != "invalid status" {
+		t.Fatalf("expected an unknown status to be rejected, got %v", err)
+	}
+
+	status, err := CreateStatus(ctx, database, "in_repair")
+	if err != nil {
+		t.Fatalf("CreateStatus: %v", err)
+	}
+	if status.Name != "in_repair" || status.Builtin || status.CreatedAt.IsZero() {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if _, err := CreateStatus(ctx, database, "in_repair"); err == nil {
+		t.Error("expected error creating a duplicate status")
+	}
+	if statuses, _ := ListStatuses(ctx, database); len(statuses) != 5 || statuses[4].Name != "in_repair" {
+		t.Errorf("expected in_repair after the built-ins, got %+v", statuses)
+	}
+
+	if err := UpdateItem(ctx, database, item.ID, item.Name, "", "", "in_repair", "sent to service", nil); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	if got, _ := GetItem(ctx, database, item.ID); got.Status != "in_repair" {
+		t.Errorf("expected status in_repair, got %s", got.Status)
+	}
+	if items, _ := ListItems(ctx, database, model.ItemFilter{Status: "in_repair"}); len(items) != 1 {
+		t.Errorf("expected the item when filtering by in_repair, got %d", len(items))
+	}
+
+	room, _ := CreateOwner(ctx, database, "Workshop", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, room.ID, 2, false, nil)
+	summary, _ := GetOwnerSummary(ctx, database, room.ID)
+	if summary.UnitsByStatus["in_repair"] != 2 || summary.UnitsByStatus[model.ItemStatusActive] != 0 || len(summary.UnitsByStatus) != 5 {
+		t.Errorf("expected 2 units in_repair among 5 statuses, got %v", summary.UnitsByStatus)
+	}
+}
+
+func TestItemStatusForeignKey(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Drill", "", "")
+	if _, err := database.ExecContext(ctx, `UPDATE items SET status = 'bogus' WHERE id = ?`, item.ID); err == nil {
+		t.Error("expected the schema to reject an unknown status")
+	}
+	if _, err := database.ExecContext(ctx, `DELETE FROM statuses WHERE name = 'active'`); err == nil {
+		t.Error("expected the schema to keep a status that items use")
+	}
+}
@@ -0,0 +1,520 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// Store is the persistence the API handlers use. SQLite implements it with
+// the package functions; handler tests can substitute a fake that embeds
+// Store and overrides only the methods a test needs.
+type Store interface {
+	// Items.
+	CreateItem(ctx context.Context, name, description, condition string) (*model.Item, error)
+	GetItem(ctx context.Context, id int64) (*model.Item, error)
+	GetItems(ctx context.Context, ids []int64) ([]model.Item, error)
+	ListItems(ctx context.Context, filter model.ItemFilter) ([]model.Item, error)
+	SearchItems(ctx context.Context, query string, filter model.ItemFilter, limit int) ([]model.Item, error)
+	SuggestItems(ctx context.Context, query string, limit int) ([]model.ItemSuggestion, error)
+	UpdateItem(ctx context.Context, id int64, name, description, condition, status, reason string, userID *int64) error
+	DecommissionItem(ctx context.Context, id int64, reason string, userID *int64) (*model.DecommissionResult, error)
+	GetItemStatusHistory(ctx context.Context, itemID int64) ([]model.StatusChange, error)
+	GetItemChangelog(ctx context.Context, itemID int64, limit, offset int) ([]model.ItemChangelogEntry, error)
+	DeleteItem(ctx context.Context, id int64, userID *int64, reason string) error
+	RestoreItem(ctx context.Context, id int64) error
+	CloneItem(ctx context.Context, id int64, withImage bool) (*model.Item, error)
+	SetItemImage(ctx context.Context, id int64, image []byte, mime string) (bool, error)
+	GetItemImage(ctx context.Context, id int64, includeDeleted bool) ([]byte, string, error)
+	GetItemHistory(ctx context.Context, itemID int64) ([]model.Transfer, error)
+	ItemDivisible(ctx context.Context, id int64) (bool, error)
+	SetItemDivisible(ctx context.Context, id int64, divisible bool) error
+
+	// Low stock.
+	SetItemMinQuantity(ctx context.Context, id int64, minQuantity *int) error
+	SetItemReorderTarget(ctx context.Context, id int64, target *int) error
+	ListReorder(ctx context.Context) ([]model.ReorderLine, error)
+
+	// Serials.
+	SetItemSerialized(ctx context.Context, id int64, serialized bool) error
+	AssignSerials(ctx context.Context, itemID, ownerID int64, serials []string) ([]model.Serial, error)
+	ListSerials(ctx context.Context, itemID, ownerID int64) ([]model.Serial, error)
+	TransferSerials(ctx context.Context, itemID, fromOwnerID, toOwnerID int64, serials []string, notes string, transferredBy *int64) (*model.Transfer, error)
+	RemoveSerial(ctx context.Context, itemID int64, serial string) error
+
+	// Documents.
+	AddDocument(ctx context.Context, itemID int64, filename, mime string, data []byte, uploadedBy *int64) (*model.Document, error)
+	ListDocuments(ctx context.Context, itemID int64) ([]model.Document, error)
+	GetDocument(ctx context.Context, itemID, id int64, withData bool) (*model.Document, error)
+	DeleteDocument(ctx context.Context, itemID, id int64) error
+
+	// Favorites.
+	AddFavorite(ctx context.Context, userID, itemID int64) error
+	RemoveFavorite(ctx context.Context, userID, itemID int64) error
+	ListFavorites(ctx context.Context, userID int64) ([]model.Item, error)
+
+	// Statuses.
+	ListStatuses(ctx context.Context) ([]model.ItemStatus, error)
+	CreateStatus(ctx context.Context, name string) (*model.ItemStatus, error)
+
+	// Owners.
+	CreateOwner(ctx context.Context, name, ownerType string) (*model.Owner, error)
+	GetOwner(ctx context.Context, id int64) (*model.Owner, error)
+	ListOwners(ctx context.Context, ownerType string) ([]model.Owner, error)
+	SearchOwners(ctx context.Context, query, ownerType string, limit int) ([]model.Owner, error)
+	ListHoldingOwners(ctx context.Context, itemID int64, ownerType string) ([]model.Owner, error)
+	LinkOwnerUser(ctx context.Context, ownerID int64, userID *int64) (*model.Owner, error)
+	GetUserOwner(ctx context.Context, userID int64) (*model.Owner, error)
+	UpdateOwner(ctx context.Context, id int64, name string) error
+	DeleteOwner(ctx context.Context, id int64, userID *int64, reason string) error
+	RestoreOwner(ctx context.Context, id int64) error
+	GetOwnerInventory(ctx context.Context, ownerID int64) ([]model.Inventory, error)
+	GetOwnerSummary(ctx context.Context, ownerID int64) (*model.OwnerSummary, error)
+
+	// Inventory.
+	ListInventory(ctx context.Context, itemID int64, ownerType, query string) ([]model.Inventory, error)
+	ListInventoryPage(ctx context.Context, itemID int64, ownerType, query string, limit, offset int) ([]model.Inventory, int, error)
+	AddStock(ctx context.Context, itemID, ownerID int64, quantity int, divisible bool, userID *int64) error
+	AddStockBatch(ctx context.Context, ownerID int64, lines []model.StockLine, userID *int64) ([]model.StockLineResult, error)
+	ImportStock(ctx context.Context, rows []model.StockImportRow, dryRun bool) ([]model.StockImportResult, bool, error)
+	AdjustInventory(ctx context.Context, itemID, ownerID int64, delta int, divisible bool, notes string, userID *int64) error
+	SetStock(ctx context.Context, itemID, ownerID int64, quantity int, divisible bool, userID *int64) (int, error)
+	GetHeldQuantity(ctx context.Context, itemID, ownerID int64) (int, error)
+	GetItemDistribution(ctx context.Context, itemID int64) ([]model.Inventory, error)
+	ListInventoryChanges(ctx context.Context, since time.Time) ([]model.InventoryChange, error)
+
+	// Stock snapshots.
+	CreateStockSnapshot(ctx context.Context, notes string, takenBy *int64) (*model.StockSnapshot, error)
+	ListStockSnapshots(ctx context.Context) ([]model.StockSnapshot, error)
+	DiffStockSnapshots(ctx context.Context, fromID, toID int64) (*model.SnapshotDiff, error)
+
+	// Transfers.
+	CreateTransfer(ctx context.Context, itemID, fromOwnerID, toOwnerID int64, quantity int, divisible bool, notes string, transferredBy *int64) (*model.Transfer, error)
+	FulfillTransfer(ctx context.Context, itemID, toOwnerID int64, quantity int, divisible bool, fromOwnerIDs []int64, notes string, transferredBy *int64) ([]model.Transfer, error)
+	MoveAllInventory(ctx context.Context, fromOwnerID, toOwnerID int64, notes string, transferredBy *int64) ([]model.Transfer, error)
+	CheckTransfer(ctx context.Context, itemID, fromOwnerID, toOwnerID int64, quantity int, divisible bool) (*model.TransferPreview, error)
+	ListTransfers(ctx context.Context, itemID, ownerID int64, notesContains string) ([]model.Transfer, error)
+	ListTransfersPage(ctx context.Context, itemID, ownerID int64, notesContains string, limit, offset int) ([]model.Transfer, int, error)
+
+	// Transfer templates.
+	ListTransferTemplates(ctx context.Context) ([]model.TransferTemplate, error)
+	GetTransferTemplate(ctx context.Context, id int64) (*model.TransferTemplate, error)
+	CreateTransferTemplate(ctx context.Context, label string, fromOwnerID, toOwnerID int64, createdBy *int64) (*model.TransferTemplate, error)
+	UpdateTransferTemplate(ctx context.Context, id int64, label string, fromOwnerID, toOwnerID int64) (*model.TransferTemplate, error)
+	DeleteTransferTemplate(ctx context.Context, id int64) (bool, error)
+
+	// Users.
+	CreateUser(ctx context.Context, username, passwordHash, role string) (*model.User, error)
+	GetUser(ctx context.Context, id int64) (*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	ListUsers(ctx context.Context) ([]model.User, error)
+	UpdateUser(ctx context.Context, id int64, role string) error
+	UpdateUserPassword(ctx context.Context, id int64, passwordHash string, mustChange bool) error
+	DeleteUser(ctx context.Context, id int64) error
+	ReassignUserRecords(ctx context.Context, fromID, toID int64, deleteSource bool) (*model.ReassignResult, error)
+
+	// Tokens.
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	IssuedBeforePasswordChange(ctx context.Context, userID int64, issuedAt time.Time) (bool, error)
+
+	// Logins.
+	RecordLogin(ctx context.Context, userID int64, username string, success bool, ip, userAgent string) error
+	GetLoginActivity(ctx context.Context, filter model.LoginFilter, limit int) (*model.LoginActivity, error)
+
+	// Statistics.
+	GetStats(ctx context.Context, from, to time.Time) (*model.Stats, error)
+	GetInventoryTrend(ctx context.Context, days int, now time.Time) ([]model.InventoryTrendPoint, error)
+
+	// Activity.
+	ListActivity(ctx context.Context, before time.Time, limit int) ([]model.ActivityEvent, error)
+
+	// Audit.
+	ListAuditPage(ctx context.Context, filter model.AuditFilter, after *model.AuditEntry, limit int) ([]model.AuditEntry, error)
+	ListUserActions(ctx context.Context, userID int64, limit, offset int) ([]model.AuditEntry, error)
+
+	// Maintenance.
+	GetDBStats(ctx context.Context) (*model.DBStats, error)
+	Optimize(ctx context.Context, vacuum bool) error
+	GetMigrationStatus(ctx context.Context) (*model.MigrationStatus, error)
+}
+
+// SQLite is the Store backed by a database opened with db.Open.
+type SQLite struct {
+	db *sql.DB
+}
+
+// New returns a Store on database.
+func New(database *sql.DB) *SQLite {
+	return &SQLite{db: database}
+}
+
+func (s *SQLite) CreateItem(ctx context.Context, name, description, condition string) (*model.Item, error) {
+	return CreateItem(ctx, s.db, name, description, condition)
+}
+
+func (s *SQLite) GetItem(ctx context.Context, id int64) (*model.Item, error) {
+	return GetItem(ctx, s.db, id)
+}
+
+func (s *SQLite) GetItems(ctx context.Context, ids []int64) ([]model.Item, error) {
+	return GetItems(ctx, s.db, ids)
+}
+
+func (s *SQLite) ListItems(ctx context.Context, filter model.ItemFilter) ([]model.Item, error) {
+	return ListItems(ctx, s.db, filter)
+}
+
+func (s *SQLite) SearchItems(ctx context.Context, query string, filter model.ItemFilter, limit int) ([]model.Item, error) {
+	return SearchItems(ctx, s.db, query, filter, limit)
+}
+
+func (s *SQLite) SuggestItems(ctx context.Context, query string, limit int) ([]model.ItemSuggestion, error) {
+	return SuggestItems(ctx, s.db, query, limit)
+}
+
+func (s *SQLite) UpdateItem(ctx context.Context, id int64, name, description, condition, status, reason string, userID *int64) error {
+	return UpdateItem(ctx, s.db, id, name, description, condition, status, reason, userID)
+}
+
+func (s *SQLite) DecommissionItem(ctx context.Context, id int64, reason string, userID *int64) (*model.DecommissionResult, error) {
+	return DecommissionItem(ctx, s.db, id, reason, userID)
+}
+
+func (s *SQLite) GetItemStatusHistory(ctx context.Context, itemID int64) ([]model.StatusChange, error) {
+	return GetItemStatusHistory(ctx, s.db, itemID)
+}
+
+func (s *SQLite) GetItemChangelog(ctx context.Context, itemID int64, limit, offset int) ([]model.ItemChangelogEntry, error) {
+	return GetItemChangelog(ctx, s.db, itemID, limit, offset)
+}
+
+func (s *SQLite) DeleteItem(ctx context.Context, id int64, userID *int64, reason string) error {
+	return DeleteItem(ctx, s.db, id, userID, reason)
+}
+
+func (s *SQLite) RestoreItem(ctx context.Context, id int64) error {
+	return RestoreItem(ctx, s.db, id)
+}
+
+func (s *SQLite) CloneItem(ctx context.Context, id int64, withImage bool) (*model.Item, error) {
+	return CloneItem(ctx, s.db, id, withImage)
+}
+
+func (s *SQLite) SetItemImage(ctx context.Context, id int64, image []byte, mime string) (bool, error) {
+	return SetItemImage(ctx, s.db, id, image, mime)
+}
+
+func (s *SQLite) GetItemImage(ctx context.Context, id int64, includeDeleted bool) ([]byte, string, error) {
+	return GetItemImage(ctx, s.db, id, includeDeleted)
+}
+
+func (s *SQLite) GetItemHistory(ctx context.Context, itemID int64) ([]model.Transfer, error) {
+	return GetItemHistory(ctx, s.db, itemID)
+}
+
+func (s *SQLite) ItemDivisible(ctx context.Context, id int64) (bool, error) {
+	return ItemDivisible(ctx, s.db, id)
+}
+
+func (s *SQLite) SetItemDivisible(ctx context.Context, id int64, divisible bool) error {
+	return SetItemDivisible(ctx, s.db, id, divisible)
+}
+
+func (s *SQLite) SetItemMinQuantity(ctx context.Context, id int64, minQuantity *int) error {
+	return SetItemMinQuantity(ctx, s.db, id, minQuantity)
+}
+
+func (s *SQLite) SetItemReorderTarget(ctx context.Context, id int64, target *int) error {
+	return SetItemReorderTarget(ctx, s.db, id, target)
+}
+
+func (s *SQLite) ListReorder(ctx context.Context) ([]model.ReorderLine, error) {
+	return ListReorder(ctx, s.db)
+}
+
+func (s *SQLite) SetItemSerialized(ctx context.Context, id int64, serialized bool) error {
+	return SetItemSerialized(ctx, s.db, id, serialized)
+}
+
+func (s *SQLite) AssignSerials(ctx context.Context, itemID, ownerID int64, serials []string) ([]model.Serial, error) {
+	return AssignSerials(ctx, s.db, itemID, ownerID, serials)
+}
+
+func (s *SQLite) ListSerials(ctx context.Context, itemID, ownerID int64) ([]model.Serial, error) {
+	return ListSerials(ctx, s.db, itemID, ownerID)
+}
+
+func (s *SQLite) TransferSerials(ctx context.Context, itemID, fromOwnerID, toOwnerID int64, serials []string, notes string, transferredBy *int64) (*model.Transfer, error) {
+	return TransferSerials(ctx, s.db, itemID, fromOwnerID, toOwnerID, serials, notes, transferredBy)
+}
+
+func (s *SQLite) RemoveSerial(ctx context.Context, itemID int64, serial string) error {
+	return RemoveSerial(ctx, s.db, itemID, serial)
+}
+
+func (s *SQLite) AddDocument(ctx context.Context, itemID int64, filename, mime string, data []byte, uploadedBy *int64) (*model.Document, error) {
+	return AddDocument(ctx, s.db, itemID, filename, mime, data, uploadedBy)
+}
+
+func (s *SQLite) ListDocuments(ctx context.Context, itemID int64) ([]model.Document, error) {
+	return ListDocuments(ctx, s.db, itemID)
+}
+
+func (s *SQLite) GetDocument(ctx context.Context, itemID, id int64, withData bool) (*model.Document, error) {
+	return GetDocument(ctx, s.db, itemID, id, withData)
+}
+
+func (s *SQLite) DeleteDocument(ctx context.Context, itemID, id int64) error {
+	return DeleteDocument(ctx, s.db, itemID, id)
+}
+
+func (s *SQLite) AddFavorite(ctx context.Context, userID, itemID int64) error {
+	return AddFavorite(ctx, s.db, userID, itemID)
+}
+
+func (s *SQLite) RemoveFavorite(ctx context.Context, userID, itemID int64) error {
+	return RemoveFavorite(ctx, s.db, userID, itemID)
+}
+
+func (s *SQLite) ListFavorites(ctx context.Context, userID int64) ([]model.Item, error) {
+	return ListFavorites(ctx, s.db, userID)
+}
+
+func (s *SQLite) ListStatuses(ctx context.Context) ([]model.ItemStatus, error) {
+	return ListStatuses(ctx, s.db)
+}
+
+func (s *SQLite) CreateStatus(ctx context.Context, name string) (*model.ItemStatus, error) {
+	return CreateStatus(ctx, s.db, name)
+}
+
+func (s *SQLite) CreateOwner(ctx context.Context, name, ownerType string) (*model.Owner, error) {
+	return CreateOwner(ctx, s.db, name, ownerType)
+}
+
+func (s *SQLite) GetOwner(ctx context.Context, id int64) (*model.Owner, error) {
+	return GetOwner(ctx, s.db, id)
+}
+
+func (s *SQLite) ListOwners(ctx context.Context, ownerType string) ([]model.Owner, error) {
+	return ListOwners(ctx, s.db, ownerType)
+}
+
+func (s *SQLite) SearchOwners(ctx context.Context, query, ownerType string, limit int) ([]model.Owner, error) {
+	return SearchOwners(ctx, s.db, query, ownerType, limit)
+}
+
+func (s *SQLite) ListHoldingOwners(ctx context.Context, itemID int64, ownerType string) ([]model.Owner, error) {
+	return ListHoldingOwners(ctx, s.db, itemID, ownerType)
+}
+
+func (s *SQLite) LinkOwnerUser(ctx context.Context, ownerID int64, userID *int64) (*model.Owner, error) {
+	return LinkOwnerUser(ctx, s.db, ownerID, userID)
+}
+
+func (s *SQLite) GetUserOwner(ctx context.Context, userID int64) (*model.Owner, error) {
+	return GetUserOwner(ctx, s.db, userID)
+}
+
+func (s *SQLite) UpdateOwner(ctx context.Context, id int64, name string) error {
+	return UpdateOwner(ctx, s.db, id, name)
+}
+
+func (s *SQLite) DeleteOwner(ctx context.Context, id int64, userID *int64, reason string) error {
+	return DeleteOwner(ctx, s.db, id, userID, reason)
+}
+
+func (s *SQLite) RestoreOwner(ctx context.Context, id int64) error {
+	return RestoreOwner(ctx, s.db, id)
+}
+
+func (s *SQLite) GetOwnerInventory(ctx context.Context, ownerID int64) ([]model.Inventory, error) {
+	return GetOwnerInventory(ctx, s.db, ownerID)
+}
+
+func (s *SQLite) GetOwnerSummary(ctx context.Context, ownerID int64) (*model.OwnerSummary, error) {
+	return GetOwnerSummary(ctx, s.db, ownerID)
+}
+
+func (s *SQLite) ListInventory(ctx context.Context, itemID int64, ownerType, query string) ([]model.Inventory, error) {
+	return ListInventory(ctx, s.db, itemID, ownerType, query)
+}
+
+func (s *SQLite) ListInventoryPage(ctx context.Context, itemID int64, ownerType, query string, limit, offset int) ([]model.Inventory, int, error) {
+	return ListInventoryPage(ctx, s.db, itemID, ownerType, query, limit, offset)
+}
+
+func (s *SQLite) AddStock(ctx context.Context, itemID, ownerID int64, quantity int, divisible bool, userID *int64) error {
+	return AddStock(ctx, s.db, itemID, ownerID, quantity, divisible, userID)
+}
+
+func (s *SQLite) AddStockBatch(ctx context.Context, ownerID int64, lines []model.StockLine, userID *int64) ([]model.StockLineResult, error) {
+	return AddStockBatch(ctx, s.db, ownerID, lines, userID)
+}
+
+func (s *SQLite) ImportStock(ctx context.Context, rows []model.StockImportRow, dryRun bool) ([]model.StockImportResult, bool, error) {
+	return ImportStock(ctx, s.db, rows, dryRun)
+}
+
+func (s *SQLite) AdjustInventory(ctx context.Context, itemID, ownerID int64, delta int, divisible bool, notes string, userID *int64) error {
+	return AdjustInventory(ctx, s.db, itemID, ownerID, delta, divisible, notes, userID)
+}
+
+func (s *SQLite) SetStock(ctx context.Context, itemID, ownerID int64, quantity int, divisible bool, userID *int64) (int, error) {
+	return SetStock(ctx, s.db, itemID, ownerID, quantity, divisible, userID)
+}
+
+func (s *SQLite) GetHeldQuantity(ctx context.Context, itemID, ownerID int64) (int, error) {
+	return GetHeldQuantity(ctx, s.db, itemID, ownerID)
+}
+
+func (s *SQLite) GetItemDistribution(ctx context.Context, itemID int64) ([]model.Inventory, error) {
+	return GetItemDistribution(ctx, s.db, itemID)
+}
+
+func (s *SQLite) ListInventoryChanges(ctx context.Context, since time.Time) ([]model.InventoryChange, error) {
+	return ListInventoryChanges(ctx, s.db, since)
+}
+
+func (s *SQLite) CreateStockSnapshot(ctx context.Context, notes string, takenBy *int64) (*model.StockSnapshot, error) {
+	return CreateStockSnapshot(ctx, s.db, notes, takenBy)
+}
+
+func (s *SQLite) ListStockSnapshots(ctx context.Context) ([]model.StockSnapshot, error) {
+	return ListStockSnapshots(ctx, s.db)
+}
+
+func (s *SQLite) DiffStockSnapshots(ctx context.Context, fromID, toID int64) (*model.SnapshotDiff, error) {
+	return DiffStockSnapshots(ctx, s.db, fromID, toID)
+}
+
+func (s *SQLite) CreateTransfer(ctx context.Context, itemID, fromOwnerID, toOwnerID int64, quantity int, divisible bool, notes string, transferredBy *int64) (*model.Transfer, error) {
+	return CreateTransfer(ctx, s.db, itemID, fromOwnerID, toOwnerID, quantity, divisible, notes, transferredBy)
+}
+
+func (s *SQLite) FulfillTransfer(ctx context.Context, itemID, toOwnerID int64, quantity int, divisible bool, fromOwnerIDs []int64, notes string, transferredBy *int64) ([]model.Transfer, error) {
+	return FulfillTransfer(ctx, s.db, itemID, toOwnerID, quantity, divisible, fromOwnerIDs, notes, transferredBy)
+}
+
+func (s *SQLite) MoveAllInventory(ctx context.Context, fromOwnerID, toOwnerID int64, notes string, transferredBy *int64) ([]model.Transfer, error) {
+	return MoveAllInventory(ctx, s.db, fromOwnerID, toOwnerID, notes, transferredBy)
+}
+
+func (s *SQLite) CheckTransfer(ctx context.Context, itemID, fromOwnerID, toOwnerID int64, quantity int, divisible bool) (*model.TransferPreview, error) {
+	return CheckTransfer(ctx, s.db, itemID, fromOwnerID, toOwnerID, quantity, divisible)
+}
+
+func (s *SQLite) ListTransfers(ctx context.Context, itemID, ownerID int64, notesContains string) ([]model.Transfer, error) {
+	return ListTransfers(ctx, s.db, itemID, ownerID, notesContains)
+}
+
+func (s *SQLite) ListTransfersPage(ctx context.Context, itemID, ownerID int64, notesContains string, limit, offset int) ([]model.Transfer, int, error) {
+	return ListTransfersPage(ctx, s.db, itemID, ownerID, notesContains, limit, offset)
+}
+
+func (s *SQLite) ListTransferTemplates(ctx context.Context) ([]model.TransferTemplate, error) {
+	return ListTransferTemplates(ctx, s.db)
+}
+
+func (s *SQLite) GetTransferTemplate(ctx context.Context, id int64) (*model.TransferTemplate, error) {
+	return GetTransferTemplate(ctx, s.db, id)
+}
+
+func (s *SQLite) CreateTransferTemplate(ctx context.Context, label string, fromOwnerID, toOwnerID int64, createdBy *int64) (*model.TransferTemplate, error) {
+	return CreateTransferTemplate(ctx, s.db, label, fromOwnerID, toOwnerID, createdBy)
+}
+
+func (s *SQLite) UpdateTransferTemplate(ctx context.Context, id int64, label string, fromOwnerID, toOwnerID int64) (*model.TransferTemplate, error) {
+	return UpdateTransferTemplate(ctx, s.db, id, label, fromOwnerID, toOwnerID)
+}
+
+func (s *SQLite) DeleteTransferTemplate(ctx context.Context, id int64) (bool, error) {
+	return DeleteTransferTemplate(ctx, s.db, id)
+}
+
+func (s *SQLite) CreateUser(ctx context.Context, username, passwordHash, role string) (*model.User, error) {
+	return CreateUser(ctx, s.db, username, passwordHash, role)
+}
+
+func (s *SQLite) GetUser(ctx context.Context, id int64) (*model.User, error) {
+	return GetUser(ctx, s.db, id)
+}
+
+func (s *SQLite) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	return GetUserByUsername(ctx, s.db, username)
+}
+
+func (s *SQLite) ListUsers(ctx context.Context) ([]model.User, error) {
+	return ListUsers(ctx, s.db)
+}
+
+func (s *SQLite) UpdateUser(ctx context.Context, id int64, role string) error {
+	return UpdateUser(ctx, s.db, id, role)
+}
+
+func (s *SQLite) UpdateUserPassword(ctx context.Context, id int64, passwordHash string, mustChange bool) error {
+	return UpdateUserPassword(ctx, s.db, id, passwordHash, mustChange)
+}
+
+func (s *SQLite) DeleteUser(ctx context.Context, id int64) error {
+	return DeleteUser(ctx, s.db, id)
+}
+
+func (s *SQLite) ReassignUserRecords(ctx context.Context, fromID, toID int64, deleteSource bool) (*model.ReassignResult, error) {
+	return ReassignUserRecords(ctx, s.db, fromID, toID, deleteSource)
+}
+
+func (s *SQLite) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return RevokeToken(ctx, s.db, jti, expiresAt)
+}
+
+func (s *SQLite) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return IsTokenRevoked(ctx, s.db, jti)
+}
+
+func (s *SQLite) IssuedBeforePasswordChange(ctx context.Context, userID int64, issuedAt time.Time) (bool, error) {
+	return IssuedBeforePasswordChange(ctx, s.db, userID, issuedAt)
+}
+
+func (s *SQLite) RecordLogin(ctx context.Context, userID int64, username string, success bool, ip, userAgent string) error {
+	return RecordLogin(ctx, s.db, userID, username, success, ip, userAgent)
+}
+
+func (s *SQLite) GetLoginActivity(ctx context.Context, filter model.LoginFilter, limit int) (*model.LoginActivity, error) {
+	return GetLoginActivity(ctx, s.db, filter, limit)
+}
+
+func (s *SQLite) GetStats(ctx context.Context, from, to time.Time) (*model.Stats, error) {
+	return GetStats(ctx, s.db, from, to)
+}
+
+func (s *SQLite) GetInventoryTrend(ctx context.Context, days int, now time.Time) ([]model.InventoryTrendPoint, error) {
+	return GetInventoryTrend(ctx, s.db, days, now)
+}
+
+func (s *SQLite) ListActivity(ctx context.Context, before time.Time, limit int) ([]model.ActivityEvent, error) {
+	return ListActivity(ctx, s.db, before, limit)
+}
+
+func (s *SQLite) ListAuditPage(ctx context.Context, filter model.AuditFilter, after *model.AuditEntry, limit int) ([]model.AuditEntry, error) {
+	return ListAuditPage(ctx, s.db, filter, after, limit)
+}
+
+func (s *SQLite) ListUserActions(ctx context.Context, userID int64, limit, offset int) ([]model.AuditEntry, error) {
+	return ListUserActions(ctx, s.db, userID, limit, offset)
+}
+
+func (s *SQLite) GetDBStats(ctx context.Context) (*model.DBStats, error) {
+	return GetDBStats(ctx, s.db)
+}
+
+func (s *SQLite) Optimize(ctx context.Context, vacuum bool) error {
+	return Optimize(ctx, s.db, vacuum)
+}
+
+func (s *SQLite) GetMigrationStatus(ctx context.Context) (*model.MigrationStatus, error) {
+	return GetMigrationStatus(ctx, s.db)
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+const templateSelect = `
+	SELECT t.id, t.label, t.from_owner_id, t.to_owner_id, t.created_by, t.created_at, t.updated_at,
+	       fo.name, too.name
+	FROM transfer_templates t
+	JOIN owners fo ON fo.id = t.from_owner_id
+	JOIN owners too ON too.id = t.to_owner_id`
+
+// ListTransferTemplates returns all transfer templates ordered by label.
+// Templates whose owners were deleted are included; applying them fails.
+func ListTransferTemplates(ctx context.Context, db *sql.DB) ([]model.TransferTemplate, error) {
+	rows, err := db.QueryContext(ctx, templateSelect+` ORDER BY t.label, t.id`)
+	if err != nil {
+		return nil, fmt.Errorf("listing transfer templates: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTemplates(rows)
+}
+
+// GetTransferTemplate returns a transfer template by ID, or nil if it does
+// not exist.
+func GetTransferTemplate(ctx context.Context, db *sql.DB, id int64) (*model.TransferTemplate, error) {
+	t := &model.TransferTemplate{}
+	err := db.QueryRowContext(ctx, templateSelect+` WHERE t.id = ?`, id).Scan(
+		&t.ID, &t.Label, &t.FromOwnerID, &t.ToOwnerID, &t.CreatedBy, &t.CreatedAt, &t.UpdatedAt,
+		&t.FromOwnerName, &t.ToOwnerName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting transfer template: %w", err)
+	}
+	return t, nil
+}
+
+func scanTemplates(rows *sql.Rows) ([]model.TransferTemplate, error) {
+	var templates []model.TransferTemplate
+	for rows.Next() {
+		var t model.TransferTemplate
+		if err := rows.Scan(&t.ID, &t.Label, &t.FromOwnerID, &t.ToOwnerID, &t.CreatedBy, &t.CreatedAt, &t.UpdatedAt,
+			&t.FromOwnerName, &t.ToOwnerName); err != nil {
+			return nil, fmt.Errorf("scanning transfer template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// CreateTransferTemplate adds a transfer template. The label must already be
+// normalized. Both owners must exist and differ.
+func CreateTransferTemplate(ctx context.Context, db *sql.DB, label string, fromOwnerID, toOwnerID int64, createdBy *int64) (*model.TransferTemplate, error) {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkTemplateOwners(ctx, tx, fromOwnerID, toOwnerID); err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO transfer_templates (label, from_owner_id, to_owner_id, created_by) VALUES (?, ?, ?, ?)`,
+		label, fromOwnerID, toOwnerID, createdBy,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating transfer template: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("getting transfer template id: %w", err)
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing transfer template: %w", err)
+	}
+	return GetTransferTemplate(ctx, db, id)
+}
+
+// UpdateTransferTemplate replaces a template's label and owners. Returns nil
+// if the template does not exist.
+func UpdateTransferTemplate(ctx context.Context, db *sql.DB, id int64, label string, fromOwnerID, toOwnerID int64) (*model.TransferTemplate, error) {
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkTemplateOwners(ctx, tx, fromOwnerID, toOwnerID); err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx,
+		`UPDATE transfer_templates SET label = ?, from_owner_id = ?, to_owner_id = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		label, fromOwnerID, toOwnerID, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("updating transfer template: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("checking rows affected: %w", err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing transfer template: %w", err)
+	}
+	return GetTransferTemplate(ctx, db, id)
+}
+
+// DeleteTransferTemplate removes a template. Transfers made with it are not
+// affected. It reports whether the template existed.
+func DeleteTransferTemplate(ctx context.Context, db *sql.DB, id int64) (bool, error) {
+	result, err := exec(ctx, db, `DELETE FROM transfer_templates WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("deleting transfer template: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("checking rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// checkTemplateOwners checks that a template's owners differ and both exist
+// and are not deleted.
+func checkTemplateOwners(ctx context.Context, tx *sql.Tx, fromOwnerID, toOwnerID int64) error {
+	if fromOwnerID == toOwnerID {
+		return invalidf("from and to owner must differ")
+	}
+	var n int
+	err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM owners WHERE id IN (?, ?) AND deleted_at IS NULL`,
+		fromOwnerID, toOwnerID,
+	).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("checking owners: %w", err)
+	}
+	if n != 2 {
+		return invalidf("owner not found")
+	}
+	return nil
+}
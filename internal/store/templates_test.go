@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/erazemk/skladisce/internal/db"
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+func TestTransferTemplates(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	desk, _ := CreateOwner(ctx, database, "Front desk", model.OwnerTypeLocation)
+	storage, _ := CreateOwner(ctx, database, "Main storage", model.OwnerTypeLocation)
+	attic, _ := CreateOwner(ctx, database, "Attic", model.OwnerTypeLocation)
+
+	if _, err := CreateTransferTemplate(ctx, database, "Loop", desk.ID, desk.ID, nil); err == nil {
+		t.Error("expected an error for the same owner twice")
+	}
+	if _, err := CreateTransferTemplate(ctx, database, "Nowhere", desk.ID, 9999, nil); err == nil || err.Error() != "owner not found" {
+		t.Errorf("expected 'owner not found', got %v", err)
+	}
+
+	tmpl, err := CreateTransferTemplate(ctx, database, "Returns", desk.ID, storage.ID, nil)
+	if err != nil {
+		t.Fatalf("CreateTransferTemplate: %v", err)
+	}
+	if tmpl.FromOwnerName != "Front desk" || tmpl.ToOwnerName != "Main storage" {
+		t.Errorf("expected joined owner names, got %+v", tmpl)
+	}
+
+	updated, err := UpdateTransferTemplate(ctx, database, tmpl.ID, "Archive", storage.ID, attic.ID)
+	if err != nil {
+		t.Fatalf("UpdateTransferTemplate: %v", err)
+	}
+	if updated.Label != "Archive" || updated.FromOwnerID != storage.ID || updated.ToOwnerName != "Attic" {
+		t.Errorf("unexpected updated template: %+v", updated)
+	}
+	if missing, err := UpdateTransferTemplate(ctx, database, 9999, "X", desk.ID, attic.ID); err != nil || missing != nil {
+		t.Errorf("expected nil for a missing template, got %+v, %v", missing, err)
+	}
+
+	if found, err := DeleteTransferTemplate(ctx, database, tmpl.ID); err != nil || !found {
+		t.Fatalf("DeleteTransferTemplate: %v, %v", found, err)
+	}
+	templates, _ := ListTransferTemplates(ctx, database)
+	if len(templates) != 0 {
+		t.Errorf("expected no templates, got %+v", templates)
+	}
+}
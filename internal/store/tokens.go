@@ -9,17 +9,17 @@ import (
 
 // RevokeToken adds a token's JTI to the revocation list.
 func RevokeToken(ctx context.Context, db *sql.DB, jti string, expiresAt time.Time) error {
-	_, err := db.ExecContext(ctx,
+	_, err := exec(ctx, db,
 		`INSERT OR IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)`,
-		jti, expiresAt,
+		jti, expiresAt.UTC().Format(sqliteTimeFormat),
 	)
 	if err != nil {
 		return fmt.Errorf("revoking token: %w", err)
 	}
 
 	// Opportunistically clean up expired revocations.
-	_, _ = db.ExecContext(ctx,
-		`DELETE FROM revoked_tokens WHERE expires_at < ?`, time.Now(),
+	_, _ = exec(ctx, db,
+		`DELETE FROM revoked_tokens WHERE expires_at < ?`, time.Now().UTC().Format(sqliteTimeFormat),
 	)
 
 	return nil
@@ -36,3 +36,18 @@ func IsTokenRevoked(ctx context.Context, db *sql.DB, jti string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// IssuedBeforePasswordChange reports whether a token issued at issuedAt
+// predates the user's last password change. Both have second precision, so a
+// token issued in the same second as the change is still accepted.
+func IssuedBeforePasswordChange(ctx context.Context, db *sql.DB, userID int64, issuedAt time.Time) (bool, error) {
+	var stale bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users WHERE id = ? AND password_changed_at > ?)`,
+		userID, issuedAt.UTC().Format(sqliteTimeFormat),
+	).Scan(&stale)
+	if err != nil {
+		return false, fmt.Errorf("checking password change: %w", err)
+	}
+	return stale, nil
+}
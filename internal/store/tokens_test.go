@@ -61,3 +61,59 @@ func TestRevokeTokenIdempotent(t *testing.T) {
 		t.Fatalf("second RevokeToken: %v", err)
 	}
 }
+
+func TestRevokeTokenStoresUTC(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	zone := time.FixedZone("UTC-7", -7*60*60)
+	expires := time.Date(2030, 1, 1, 5, 0, 0, 0, zone)
+	if err := RevokeToken(ctx, database, "jti-utc", expires); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+
+	var stored string
+	database.QueryRowContext(ctx, `SELECT CAST(expires_at AS TEXT) FROM revoked_tokens WHERE jti = ?`, "jti-utc").Scan(&stored)
+	if stored != "2030-01-01 12:00:00" {
+		t.Errorf("expected expiry stored as UTC, got %q", stored)
+	}
+}
+
+func TestIssuedBeforePasswordChange(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	user, err := CreateUser(ctx, database, "pwchange", "hash", "user")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// A user who never changed their password has no stale tokens.
+	stale, err := IssuedBeforePasswordChange(ctx, database, user.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("IssuedBeforePasswordChange: %v", err)
+	}
+	if stale {
+		t.Error("expected token to be valid before any password change")
+	}
+
+	if err := UpdateUserPassword(ctx, database, user.ID, "newhash", false); err != nil {
+		t.Fatalf("UpdateUserPassword: %v", err)
+	}
+
+	stale, err = IssuedBeforePasswordChange(ctx, database, user.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("IssuedBeforePasswordChange: %v", err)
+	}
+	if !stale {
+		t.Error("expected token issued before the change to be stale")
+	}
+
+	stale, err = IssuedBeforePasswordChange(ctx, database, user.ID, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("IssuedBeforePasswordChange: %v", err)
+	}
+	if stale {
+		t.Error("expected token issued after the change to be valid")
+	}
+}
@@ -4,16 +4,32 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 
 	"github.com/erazemk/skladisce/internal/model"
 )
 
+// InsufficientQuantityError is returned when a transfer requests more than the
+// source owner holds. It carries both amounts, in stored units, so callers
+// can report them; Divisible tells how to format them.
+type InsufficientQuantityError struct {
+	Available int
+	Requested int
+	Divisible bool
+}
+
+func (e *InsufficientQuantityError) Error() string {
+	return fmt.Sprintf("insufficient quantity: have %s, need %s",
+		model.FormatQuantity(e.Available, e.Divisible), model.FormatQuantity(e.Requested, e.Divisible))
+}
+
 // beginImmediate starts a transaction with BEGIN IMMEDIATE semantics.
-// This prevents SQLITE_BUSY errors by acquiring a write lock immediately.
+// This prevents SQLITE_BUSY errors by acquiring a write lock immediately;
+// if the lock is not free within the busy timeout, the error is ErrBusy.
 func beginImmediate(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
 	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("beginning transaction: %w", err)
+		return nil, fmt.Errorf("beginning transaction: %w", checkBusy(err))
 	}
 	// Upgrade to immediate by executing a write statement.
 	// The INSERT OR IGNORE into a non-existent key is a no-op write that
@@ -21,19 +37,19 @@ func beginImmediate(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
 	if _, err := tx.ExecContext(ctx,
 		`INSERT OR IGNORE INTO settings (key, value) VALUES ('_lock', '')`); err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("acquiring write lock: %w", err)
+		return nil, fmt.Errorf("acquiring write lock: %w", checkBusy(err))
 	}
 	return tx, nil
 }
 
 // CreateTransfer creates a transfer, updating inventory in a single transaction.
 // Uses BEGIN IMMEDIATE to prevent concurrent modification issues.
-func CreateTransfer(ctx context.Context, db *sql.DB, itemID, fromOwnerID, toOwnerID int64, quantity int, notes string, transferredBy *int64) (*model.Transfer, error) {
+func CreateTransfer(ctx context.Context, db *sql.DB, itemID, fromOwnerID, toOwnerID int64, quantity int, divisible bool, notes string, transferredBy *int64) (*model.Transfer, error) {
 	if fromOwnerID == toOwnerID {
-		return nil, fmt.Errorf("cannot transfer to same owner")
+		return nil, invalidf("cannot transfer to same owner")
 	}
 	if quantity <= 0 {
-		return nil, fmt.Errorf("quantity must be positive")
+		return nil, invalidf("quantity must be positive")
 	}
 
 	tx, err := beginImmediate(ctx, db)
@@ -42,47 +58,55 @@ func CreateTransfer(ctx context.Context, db *sql.DB, itemID, fromOwnerID, toOwne
 	}
 	defer tx.Rollback()
 
-	// Check available quantity.
-	var available int
-	err = tx.QueryRowContext(ctx,
-		`SELECT COALESCE(quantity, 0) FROM inventory WHERE item_id = ? AND owner_id = ?`,
-		itemID, fromOwnerID,
-	).Scan(&available)
-	if err == sql.ErrNoRows {
-		available = 0
-	} else if err != nil {
-		return nil, fmt.Errorf("checking available quantity: %w", err)
+	if err := rejectSerialized(ctx, tx, itemID); err != nil {
+		return nil, err
+	}
+	if err := checkDivisible(ctx, tx, itemID, divisible); err != nil {
+		return nil, err
+	}
+	available, err := availableForTransfer(ctx, tx, itemID, fromOwnerID, quantity)
+	if err != nil {
+		return nil, err
 	}
 
-	if available < quantity {
-		return nil, fmt.Errorf("insufficient quantity: have %d, need %d", available, quantity)
+	transferID, err := moveStock(ctx, tx, itemID, fromOwnerID, toOwnerID, quantity, available, notes, transferredBy)
+	if err != nil {
+		return nil, err
 	}
 
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing transfer: %w", err)
+	}
+
+	return GetTransfer(ctx, db, transferID)
+}
+
+// moveStock moves quantity of an item from one owner, who holds available,
+// to another and records the transfer. It returns the new transfer's ID.
+func moveStock(ctx context.Context, tx *sql.Tx, itemID, fromOwnerID, toOwnerID int64, quantity, available int, notes string, transferredBy *int64) (int64, error) {
 	// Decrease from source.
+	var err error
 	newQty := available - quantity
 	if newQty == 0 {
-		_, err = tx.ExecContext(ctx,
-			`DELETE FROM inventory WHERE item_id = ? AND owner_id = ?`,
-			itemID, fromOwnerID,
-		)
+		err = removeInventoryRow(ctx, tx, itemID, fromOwnerID)
 	} else {
 		_, err = tx.ExecContext(ctx,
-			`UPDATE inventory SET quantity = ? WHERE item_id = ? AND owner_id = ?`,
+			`UPDATE inventory SET quantity = ?, updated_at = CURRENT_TIMESTAMP WHERE item_id = ? AND owner_id = ?`,
 			newQty, itemID, fromOwnerID,
 		)
 	}
 	if err != nil {
-		return nil, fmt.Errorf("updating source inventory: %w", err)
+		return 0, fmt.Errorf("updating source inventory: %w", err)
 	}
 
 	// Increase at destination.
 	_, err = tx.ExecContext(ctx,
-		`INSERT INTO inventory (item_id, owner_id, quantity) VALUES (?, ?, ?)
-		 ON CONFLICT (item_id, owner_id) DO UPDATE SET quantity = quantity + ?`,
+		`INSERT INTO inventory (item_id, owner_id, quantity, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT (item_id, owner_id) DO UPDATE SET quantity = quantity + ?, updated_at = CURRENT_TIMESTAMP`,
 		itemID, toOwnerID, quantity, quantity,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("updating destination inventory: %w", err)
+		return 0, fmt.Errorf("updating destination inventory: %w", err)
 	}
 
 	// Record the transfer.
@@ -92,19 +116,358 @@ func CreateTransfer(ctx context.Context, db *sql.DB, itemID, fromOwnerID, toOwne
 		itemID, fromOwnerID, toOwnerID, quantity, notes, transferredBy,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("recording transfer: %w", err)
+		return 0, fmt.Errorf("recording transfer: %w", err)
 	}
 
 	transferID, err := result.LastInsertId()
 	if err != nil {
-		return nil, fmt.Errorf("getting transfer id: %w", err)
+		return 0, fmt.Errorf("getting transfer id: %w", err)
 	}
+	return transferID, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("committing transfer: %w", err)
+// FulfillTransfer moves quantity of an item to toOwnerID, taking it from as
+// many sources as needed in one transaction. By default the sources are the
+// non-deleted locations holding the item, largest stock first (ties by name);
+// a non-empty fromOwnerIDs instead gives the sources to use, in order, and
+// owners in it that hold none of the item are skipped. Each source gives as
+// much as it holds until the quantity is met, and each contribution is its
+// own transfer record. If the sources hold less than quantity in total,
+// nothing is moved and an InsufficientQuantityError with the total is
+// returned.
+func FulfillTransfer(ctx context.Context, db *sql.DB, itemID, toOwnerID int64, quantity int, divisible bool, fromOwnerIDs []int64, notes string, transferredBy *int64) ([]model.Transfer, error) {
+	if quantity <= 0 {
+		return nil, invalidf("quantity must be positive")
+	}
+	seen := make(map[int64]bool, len(fromOwnerIDs))
+	for _, id := range fromOwnerIDs {
+		if id == toOwnerID {
+			return nil, invalidf("cannot transfer to same owner")
+		}
+		if seen[id] {
+			return nil, invalidf("duplicate source owner %d", id)
+		}
+		seen[id] = true
 	}
 
-	return GetTransfer(ctx, db, transferID)
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := rejectSerialized(ctx, tx, itemID); err != nil {
+		return nil, err
+	}
+	if err := checkDivisible(ctx, tx, itemID, divisible); err != nil {
+		return nil, err
+	}
+	sources, err := fulfillmentSources(ctx, tx, itemID, toOwnerID, fromOwnerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, src := range sources {
+		total += src.Quantity
+	}
+	if total < quantity {
+		divisible, err := itemDivisible(ctx, tx, itemID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &InsufficientQuantityError{Available: total, Requested: quantity, Divisible: divisible}
+	}
+
+	var ids []int64
+	remaining := quantity
+	for _, src := range sources {
+		if remaining == 0 {
+			break
+		}
+		take := min(remaining, src.Quantity)
+		id, err := moveStock(ctx, tx, itemID, src.OwnerID, toOwnerID, take, src.Quantity, notes, transferredBy)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		remaining -= take
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing transfers: %w", err)
+	}
+
+	transfers := make([]model.Transfer, 0, len(ids))
+	for _, id := range ids {
+		t, err := GetTransfer(ctx, db, id)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *t)
+	}
+	return transfers, nil
+}
+
+// fulfillmentSources returns the owners FulfillTransfer takes stock from, in
+// order, with how much of the item each holds.
+func fulfillmentSources(ctx context.Context, tx *sql.Tx, itemID, toOwnerID int64, fromOwnerIDs []int64) ([]model.Inventory, error) {
+	if len(fromOwnerIDs) == 0 {
+		rows, err := tx.QueryContext(ctx,
+			`SELECT inv.owner_id, inv.quantity
+			 FROM inventory inv
+			 JOIN owners o ON o.id = inv.owner_id
+			 WHERE inv.item_id = ? AND inv.owner_id != ? AND o.type = ? AND o.deleted_at IS NULL
+			 ORDER BY inv.quantity DESC, o.name, o.id`,
+			itemID, toOwnerID, model.OwnerTypeLocation,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("listing fulfillment sources: %w", err)
+		}
+		defer rows.Close()
+
+		var sources []model.Inventory
+		for rows.Next() {
+			inv := model.Inventory{ItemID: itemID}
+			if err := rows.Scan(&inv.OwnerID, &inv.Quantity); err != nil {
+				return nil, fmt.Errorf("scanning fulfillment source: %w", err)
+			}
+			sources = append(sources, inv)
+		}
+		return sources, rows.Err()
+	}
+
+	var sources []model.Inventory
+	for _, ownerID := range fromOwnerIDs {
+		var quantity int
+		err := tx.QueryRowContext(ctx,
+			`SELECT quantity FROM inventory WHERE item_id = ? AND owner_id = ?`,
+			itemID, ownerID,
+		).Scan(&quantity)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checking available quantity: %w", err)
+		}
+		sources = append(sources, model.Inventory{ItemID: itemID, OwnerID: ownerID, Quantity: quantity})
+	}
+	return sources, nil
+}
+
+// MoveAllInventory moves everything fromOwnerID holds to toOwnerID in one
+// transaction, recording one transfer per item, and returns the transfers in
+// item name order. Serialized items move with all their serials. Both owners
+// are left in place; either everything moves or, on error, nothing does.
+func MoveAllInventory(ctx context.Context, db *sql.DB, fromOwnerID, toOwnerID int64, notes string, transferredBy *int64) ([]model.Transfer, error) {
+	if fromOwnerID == toOwnerID {
+		return nil, invalidf("cannot transfer to same owner")
+	}
+
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var destinationExists bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM owners WHERE id = ? AND deleted_at IS NULL)`, toOwnerID,
+	).Scan(&destinationExists)
+	if err != nil {
+		return nil, fmt.Errorf("checking destination owner: %w", err)
+	}
+	if !destinationExists {
+		return nil, invalidf("destination owner not found")
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT inv.item_id, inv.quantity, i.serialized
+		 FROM inventory inv
+		 JOIN items i ON i.id = inv.item_id
+		 WHERE inv.owner_id = ?
+		 ORDER BY i.name, i.id`, fromOwnerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing owner inventory: %w", err)
+	}
+	type holding struct {
+		itemID     int64
+		quantity   int
+		serialized bool
+	}
+	var holdings []holding
+	for rows.Next() {
+		var h holding
+		if err := rows.Scan(&h.itemID, &h.quantity, &h.serialized); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning inventory: %w", err)
+		}
+		holdings = append(holdings, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing owner inventory: %w", err)
+	}
+
+	ids := make([]int64, 0, len(holdings))
+	serials := make(map[int64][]string)
+	for _, h := range holdings {
+		id, err := moveStock(ctx, tx, h.itemID, fromOwnerID, toOwnerID, h.quantity, h.quantity, notes, transferredBy)
+		if err != nil {
+			return nil, err
+		}
+		if h.serialized {
+			if serials[id], err = moveAllSerials(ctx, tx, h.itemID, fromOwnerID, toOwnerID, id); err != nil {
+				return nil, err
+			}
+		}
+		ids = append(ids, id)
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing transfers: %w", err)
+	}
+
+	transfers := make([]model.Transfer, 0, len(ids))
+	for _, id := range ids {
+		t, err := GetTransfer(ctx, db, id)
+		if err != nil {
+			return nil, err
+		}
+		t.Serials = serials[id]
+		transfers = append(transfers, *t)
+	}
+	return transfers, nil
+}
+
+// moveAllSerials moves every serial of an item held by fromOwnerID to
+// toOwnerID, records them on transfer transferID and returns them.
+func moveAllSerials(ctx context.Context, tx *sql.Tx, itemID, fromOwnerID, toOwnerID, transferID int64) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`UPDATE serials SET owner_id = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE item_id = ? AND owner_id = ?
+		 RETURNING serial`,
+		toOwnerID, itemID, fromOwnerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("moving serials: %w", err)
+	}
+	var serials []string
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning serial: %w", err)
+		}
+		serials = append(serials, serial)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("moving serials: %w", err)
+	}
+
+	slices.Sort(serials)
+	for _, serial := range serials {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO transfer_serials (transfer_id, serial) VALUES (?, ?)`, transferID, serial,
+		); err != nil {
+			return nil, fmt.Errorf("recording transfer serial: %w", err)
+		}
+	}
+	return serials, nil
+}
+
+// availableForTransfer returns the quantity of an item the source owner holds,
+// or an InsufficientQuantityError if it is less than quantity.
+func availableForTransfer(ctx context.Context, tx *sql.Tx, itemID, fromOwnerID int64, quantity int) (int, error) {
+	var available int
+	err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(quantity, 0) FROM inventory WHERE item_id = ? AND owner_id = ?`,
+		itemID, fromOwnerID,
+	).Scan(&available)
+	if err == sql.ErrNoRows {
+		available = 0
+	} else if err != nil {
+		return 0, fmt.Errorf("checking available quantity: %w", err)
+	}
+
+	if available < quantity {
+		divisible, err := itemDivisible(ctx, tx, itemID)
+		if err != nil {
+			return 0, err
+		}
+		return available, &InsufficientQuantityError{Available: available, Requested: quantity, Divisible: divisible}
+	}
+	return available, nil
+}
+
+// CheckTransfer runs the checks CreateTransfer would, inside a read-only
+// transaction, and returns the balances the transfer would leave without
+// changing anything. The item and both owners must exist and divisible must
+// match the item's flag; failures are returned as errors, like
+// CreateTransfer's.
+func CheckTransfer(ctx context.Context, db *sql.DB, itemID, fromOwnerID, toOwnerID int64, quantity int, divisible bool) (*model.TransferPreview, error) {
+	if fromOwnerID == toOwnerID {
+		return nil, invalidf("cannot transfer to same owner")
+	}
+	if quantity <= 0 {
+		return nil, invalidf("quantity must be positive")
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	checks := []struct {
+		query, missing string
+		id             int64
+	}{
+		{`SELECT EXISTS (SELECT 1 FROM items WHERE id = ?)`, "item not found", itemID},
+		{`SELECT EXISTS (SELECT 1 FROM owners WHERE id = ?)`, "source owner not found", fromOwnerID},
+		{`SELECT EXISTS (SELECT 1 FROM owners WHERE id = ?)`, "destination owner not found", toOwnerID},
+	}
+	for _, c := range checks {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, c.query, c.id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("checking transfer parties: %w", err)
+		}
+		if !exists {
+			return nil, invalidf("%s", c.missing)
+		}
+	}
+
+	if err := rejectSerialized(ctx, tx, itemID); err != nil {
+		return nil, err
+	}
+	if err := checkDivisible(ctx, tx, itemID, divisible); err != nil {
+		return nil, err
+	}
+	available, err := availableForTransfer(ctx, tx, itemID, fromOwnerID, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	var destination int
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(quantity), 0) FROM inventory WHERE item_id = ? AND owner_id = ?`,
+		itemID, toOwnerID,
+	).Scan(&destination)
+	if err != nil {
+		return nil, fmt.Errorf("checking destination quantity: %w", err)
+	}
+
+	return &model.TransferPreview{
+		Divisible:    divisible,
+		ItemID:       itemID,
+		FromOwnerID:  fromOwnerID,
+		ToOwnerID:    toOwnerID,
+		Quantity:     quantity,
+		FromQuantity: available - quantity,
+		ToQuantity:   destination + quantity,
+	}, nil
 }
 
 // GetTransfer returns a transfer by ID.
@@ -114,7 +477,8 @@ func GetTransfer(ctx context.Context, db *sql.DB, id int64) (*model.Transfer, er
 	err := db.QueryRowContext(ctx,
 		`SELECT t.id, t.item_id, t.from_owner_id, t.to_owner_id, t.quantity, t.notes,
 		        t.transferred_at, t.transferred_by,
-		        i.name AS item_name, fo.name AS from_owner_name, too.name AS to_owner_name
+		        i.name AS item_name, fo.name AS from_owner_name, fo.type AS from_owner_type,
+		        too.name AS to_owner_name, too.type AS to_owner_type, i.divisible
 		 FROM transfers t
 		 JOIN items i ON i.id = t.item_id
 		 JOIN owners fo ON fo.id = t.from_owner_id
@@ -122,7 +486,7 @@ func GetTransfer(ctx context.Context, db *sql.DB, id int64) (*model.Transfer, er
 		 WHERE t.id = ?`, id,
 	).Scan(&t.ID, &t.ItemID, &t.FromOwnerID, &t.ToOwnerID, &t.Quantity, &notes,
 		&t.TransferredAt, &t.TransferredBy,
-		&t.ItemName, &t.FromOwnerName, &t.ToOwnerName)
+		&t.ItemName, &t.FromOwnerName, &t.FromOwnerType, &t.ToOwnerName, &t.ToOwnerType, &t.Divisible)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -133,36 +497,66 @@ func GetTransfer(ctx context.Context, db *sql.DB, id int64) (*model.Transfer, er
 	return t, nil
 }
 
-// ListTransfers returns transfers, optionally filtered by item or owner.
-func ListTransfers(ctx context.Context, db *sql.DB, itemID, ownerID int64) ([]model.Transfer, error) {
-	query := `SELECT t.id, t.item_id, t.from_owner_id, t.to_owner_id, t.quantity, t.notes,
-	                 t.transferred_at, t.transferred_by,
-	                 i.name AS item_name, fo.name AS from_owner_name, too.name AS to_owner_name
-	          FROM transfers t
-	          JOIN items i ON i.id = t.item_id
-	          JOIN owners fo ON fo.id = t.from_owner_id
-	          JOIN owners too ON too.id = t.to_owner_id
-	          WHERE 1=1`
-	var args []any
+// ListTransfers returns transfers, optionally filtered by item or owner and by
+// notesContains, a case-insensitive substring of the notes.
+func ListTransfers(ctx context.Context, db *sql.DB, itemID, ownerID int64, notesContains string) ([]model.Transfer, error) {
+	where, args := transferFilter(itemID, ownerID, notesContains)
+	rows, err := db.QueryContext(ctx, transferSelect+where+` ORDER BY t.transferred_at DESC, t.id DESC LIMIT 500`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing transfers: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTransfers(rows)
+}
+
+// ListTransfersPage returns one page of transfers, newest first, with the
+// same filters as ListTransfers, plus the total number of matching transfers.
+func ListTransfersPage(ctx context.Context, db *sql.DB, itemID, ownerID int64, notesContains string, limit, offset int) ([]model.Transfer, int, error) {
+	where, args := transferFilter(itemID, ownerID, notesContains)
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM transfers t`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting transfers: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, transferSelect+where+` ORDER BY t.transferred_at DESC, t.id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("listing transfers: %w", err)
+	}
+	defer rows.Close()
 
+	transfers, err := scanTransfers(rows)
+	return transfers, total, err
+}
+
+const transferSelect = `SELECT t.id, t.item_id, t.from_owner_id, t.to_owner_id, t.quantity, t.notes,
+	       t.transferred_at, t.transferred_by,
+	       i.name AS item_name, fo.name AS from_owner_name, fo.type AS from_owner_type,
+	       too.name AS to_owner_name, too.type AS to_owner_type, i.divisible
+	FROM transfers t
+	JOIN items i ON i.id = t.item_id
+	JOIN owners fo ON fo.id = t.from_owner_id
+	JOIN owners too ON too.id = t.to_owner_id`
+
+// transferFilter builds the WHERE clause for the transfer list filters.
+func transferFilter(itemID, ownerID int64, notesContains string) (string, []any) {
+	where := ` WHERE 1=1`
+	var args []any
 	if itemID > 0 {
-		query += ` AND t.item_id = ?`
+		where += ` AND t.item_id = ?`
 		args = append(args, itemID)
 	}
 	if ownerID > 0 {
-		query += ` AND (t.from_owner_id = ? OR t.to_owner_id = ?)`
+		where += ` AND (t.from_owner_id = ? OR t.to_owner_id = ?)`
 		args = append(args, ownerID, ownerID)
 	}
-
-	query += ` ORDER BY t.transferred_at DESC LIMIT 500`
-
-	rows, err := db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("listing transfers: %w", err)
+	if notesContains != "" {
+		where += ` AND t.notes LIKE ? ESCAPE '\'`
+		args = append(args, "%"+escapeLike(notesContains)+"%")
 	}
-	defer rows.Close()
-
-	return scanTransfers(rows)
+	return where, args
 }
 
 func scanTransfers(rows *sql.Rows) ([]model.Transfer, error) {
@@ -172,7 +566,7 @@ func scanTransfers(rows *sql.Rows) ([]model.Transfer, error) {
 		var notes sql.NullString
 		if err := rows.Scan(&t.ID, &t.ItemID, &t.FromOwnerID, &t.ToOwnerID, &t.Quantity, &notes,
 			&t.TransferredAt, &t.TransferredBy,
-			&t.ItemName, &t.FromOwnerName, &t.ToOwnerName); err != nil {
+			&t.ItemName, &t.FromOwnerName, &t.FromOwnerType, &t.ToOwnerName, &t.ToOwnerType, &t.Divisible); err != nil {
 			return nil, fmt.Errorf("scanning transfer: %w", err)
 		}
 		t.Notes = notes.String
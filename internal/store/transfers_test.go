@@ -2,6 +2,9 @@ package store
 
 import (
 	"context"
+	"errors"
+	"maps"
+	"slices"
 	"testing"
 
 	"github.com/erazemk/skladisce/internal/db"
@@ -12,15 +15,15 @@ func TestTransferBasic(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
 
 	// Add stock first.
-	AddStock(ctx, database, item.ID, from.ID, 10, nil)
+	AddStock(ctx, database, item.ID, from.ID, 10, false, nil)
 
 	// Transfer 3 from Storage to Alice.
-	transfer, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 3, "test transfer", nil)
+	transfer, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 3, false, "test transfer", nil)
 	if err != nil {
 		t.Fatalf("CreateTransfer: %v", err)
 	}
@@ -40,32 +43,238 @@ func TestTransferBasic(t *testing.T) {
 	}
 }
 
+func TestTransferOwnerTypes(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
+	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, item.ID, from.ID, 10, false, nil)
+	created, _ := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 3, false, "", nil)
+
+	got, err := GetTransfer(ctx, database, created.ID)
+	if err != nil {
+		t.Fatalf("GetTransfer: %v", err)
+	}
+	listed, _ := ListTransfers(ctx, database, 0, 0, "")
+	history, _ := GetItemHistory(ctx, database, item.ID)
+	if len(listed) != 1 || len(history) != 1 {
+		t.Fatalf("expected one transfer listed and in history, got %v and %v", listed, history)
+	}
+	for _, tr := range []model.Transfer{*got, listed[0], history[0]} {
+		if tr.FromOwnerName != "Storage" || tr.FromOwnerType != model.OwnerTypeLocation ||
+			tr.ToOwnerName != "Alice" || tr.ToOwnerType != model.OwnerTypePerson {
+			t.Errorf("expected Storage (location) to Alice (person), got %+v", tr)
+		}
+	}
+
+	inv, _ := GetOwnerInventory(ctx, database, to.ID)
+	if len(inv) != 1 || inv[0].OwnerName != "Alice" || inv[0].OwnerType != model.OwnerTypePerson || inv[0].ItemName != "Widget" {
+		t.Errorf("expected Alice's inventory with owner name and type, got %+v", inv)
+	}
+}
+
 func TestTransferInsufficientQuantity(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
 
-	AddStock(ctx, database, item.ID, from.ID, 5, nil)
+	AddStock(ctx, database, item.ID, from.ID, 5, false, nil)
 
-	_, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 10, "", nil)
+	_, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 10, false, "", nil)
 	if err == nil {
 		t.Error("expected error for insufficient quantity")
 	}
 }
 
+func TestTransferInsufficientQuantityError(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
+	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+
+	AddStock(ctx, database, item.ID, from.ID, 7, false, nil)
+
+	_, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 10, false, "", nil)
+	var insufficient *InsufficientQuantityError
+	if !errors.As(err, &insufficient) {
+		t.Fatalf("expected InsufficientQuantityError, got %v", err)
+	}
+	if insufficient.Available != 7 || insufficient.Requested != 10 {
+		t.Errorf("expected available 7 and requested 10, got %d and %d", insufficient.Available, insufficient.Requested)
+	}
+}
+
+func TestTransferDivisible(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Flour", "", "")
+	if err := SetItemDivisible(ctx, database, item.ID, true); err != nil {
+		t.Fatalf("SetItemDivisible: %v", err)
+	}
+	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+
+	qty := func(s string) int {
+		q, err := model.ParseQuantity(s, true)
+		if err != nil {
+			t.Fatalf("ParseQuantity(%q): %v", s, err)
+		}
+		return q
+	}
+	AddStock(ctx, database, item.ID, from.ID, qty("2.5"), true, nil)
+
+	transfer, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, qty("1.25"), true, "", nil)
+	if err != nil {
+		t.Fatalf("CreateTransfer: %v", err)
+	}
+	if !transfer.Divisible || model.FormatQuantity(transfer.Quantity, true) != "1.25" {
+		t.Errorf("expected a divisible transfer of 1.25, got %+v", transfer)
+	}
+
+	// 1.25 is left; 1.5 would take the source below zero.
+	_, err = CreateTransfer(ctx, database, item.ID, from.ID, to.ID, qty("1.5"), true, "", nil)
+	var insufficient *InsufficientQuantityError
+	if !errors.As(err, &insufficient) {
+		t.Fatalf("expected InsufficientQuantityError, got %v", err)
+	}
+	if insufficient.Error() != "insufficient quantity: have 1.25, need 1.5" {
+		t.Errorf("unexpected error message %q", insufficient.Error())
+	}
+
+	if _, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, qty("1.25"), true, "", nil); err != nil {
+		t.Fatalf("CreateTransfer of the rest: %v", err)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, from.ID); got != 0 {
+		t.Errorf("expected Storage to hold nothing, got %d", got)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, to.ID); got != qty("2.5") {
+		t.Errorf("expected Alice to hold 2.5, got %s", model.FormatQuantity(got, true))
+	}
+}
+
+func TestFulfillTransferSplitsAcrossLocations(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Chair", "", "")
+	hall, _ := CreateOwner(ctx, database, "Hall", model.OwnerTypeLocation)
+	attic, _ := CreateOwner(ctx, database, "Attic", model.OwnerTypeLocation)
+	garage, _ := CreateOwner(ctx, database, "Garage", model.OwnerTypeLocation)
+	bob, _ := CreateOwner(ctx, database, "Bob", model.OwnerTypePerson)
+	alice, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, item.ID, hall.ID, 5, false, nil)
+	AddStock(ctx, database, item.ID, attic.ID, 3, false, nil)
+	AddStock(ctx, database, item.ID, garage.ID, 3, false, nil)
+	AddStock(ctx, database, item.ID, bob.ID, 20, false, nil) // people are not default sources
+
+	transfers, err := FulfillTransfer(ctx, database, item.ID, alice.ID, 9, false, nil, "event", nil)
+	if err != nil {
+		t.Fatalf("FulfillTransfer: %v", err)
+	}
+	// Largest stock first, ties by name: Hall 5, Attic 3, Garage 1.
+	want := []struct {
+		from int64
+		qty  int
+	}{{hall.ID, 5}, {attic.ID, 3}, {garage.ID, 1}}
+	if len(transfers) != len(want) {
+		t.Fatalf("expected %d transfers, got %d", len(want), len(transfers))
+	}
+	for i, w := range want {
+		tr := transfers[i]
+		if tr.FromOwnerID != w.from || tr.Quantity != w.qty || tr.ToOwnerID != alice.ID || tr.Notes != "event" {
+			t.Errorf("transfer %d: expected %d from owner %d, got %+v", i, w.qty, w.from, tr)
+		}
+	}
+
+	for _, c := range []struct {
+		owner int64
+		want  int
+	}{{hall.ID, 0}, {attic.ID, 0}, {garage.ID, 2}, {bob.ID, 20}, {alice.ID, 9}} {
+		if got, _ := GetHeldQuantity(ctx, database, item.ID, c.owner); got != c.want {
+			t.Errorf("owner %d: expected %d, got %d", c.owner, c.want, got)
+		}
+	}
+}
+
+func TestFulfillTransferExplicitOrder(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Chair", "", "")
+	hall, _ := CreateOwner(ctx, database, "Hall", model.OwnerTypeLocation)
+	empty, _ := CreateOwner(ctx, database, "Empty", model.OwnerTypeLocation)
+	bob, _ := CreateOwner(ctx, database, "Bob", model.OwnerTypePerson)
+	alice, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, item.ID, hall.ID, 5, false, nil)
+	AddStock(ctx, database, item.ID, bob.ID, 2, false, nil)
+
+	transfers, err := FulfillTransfer(ctx, database, item.ID, alice.ID, 4, false, []int64{empty.ID, bob.ID, hall.ID}, "", nil)
+	if err != nil {
+		t.Fatalf("FulfillTransfer: %v", err)
+	}
+	if len(transfers) != 2 || transfers[0].FromOwnerID != bob.ID || transfers[0].Quantity != 2 ||
+		transfers[1].FromOwnerID != hall.ID || transfers[1].Quantity != 2 {
+		t.Errorf("expected 2 from Bob then 2 from Hall, got %+v", transfers)
+	}
+
+	if _, err := FulfillTransfer(ctx, database, item.ID, alice.ID, 1, false, []int64{hall.ID, hall.ID}, "", nil); err == nil {
+		t.Error("expected duplicate sources to be rejected")
+	}
+	if _, err := FulfillTransfer(ctx, database, item.ID, alice.ID, 1, false, []int64{alice.ID}, "", nil); err == nil {
+		t.Error("expected the destination as a source to be rejected")
+	}
+}
+
+func TestFulfillTransferInsufficientTotal(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Chair", "", "")
+	hall, _ := CreateOwner(ctx, database, "Hall", model.OwnerTypeLocation)
+	attic, _ := CreateOwner(ctx, database, "Attic", model.OwnerTypeLocation)
+	alice, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, item.ID, hall.ID, 5, false, nil)
+	AddStock(ctx, database, item.ID, attic.ID, 3, false, nil)
+
+	_, err := FulfillTransfer(ctx, database, item.ID, alice.ID, 9, false, nil, "", nil)
+	var insufficient *InsufficientQuantityError
+	if !errors.As(err, &insufficient) {
+		t.Fatalf("expected InsufficientQuantityError, got %v", err)
+	}
+	if insufficient.Available != 8 || insufficient.Requested != 9 {
+		t.Errorf("expected available=8 requested=9, got %+v", insufficient)
+	}
+
+	// Nothing moved.
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, hall.ID); got != 5 {
+		t.Errorf("expected Hall to keep 5, got %d", got)
+	}
+	if got, _ := GetHeldQuantity(ctx, database, item.ID, alice.ID); got != 0 {
+		t.Errorf("expected Alice to get nothing, got %d", got)
+	}
+	if transfers, _ := ListTransfers(ctx, database, item.ID, 0, ""); len(transfers) != 0 {
+		t.Errorf("expected no transfers recorded, got %d", len(transfers))
+	}
+}
+
 func TestTransferToSelfRejected(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	owner, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 
-	AddStock(ctx, database, item.ID, owner.ID, 5, nil)
+	AddStock(ctx, database, item.ID, owner.ID, 5, false, nil)
 
-	_, err := CreateTransfer(ctx, database, item.ID, owner.ID, owner.ID, 1, "", nil)
+	_, err := CreateTransfer(ctx, database, item.ID, owner.ID, owner.ID, 1, false, "", nil)
 	if err == nil {
 		t.Error("expected error for transfer to self")
 	}
@@ -75,14 +284,14 @@ func TestTransferRemovesZeroInventory(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item, _ := CreateItem(ctx, database, "Widget", "")
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
 	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
 
-	AddStock(ctx, database, item.ID, from.ID, 5, nil)
+	AddStock(ctx, database, item.ID, from.ID, 5, false, nil)
 
 	// Transfer all 5.
-	_, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 5, "", nil)
+	_, err := CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 5, false, "", nil)
 	if err != nil {
 		t.Fatalf("CreateTransfer: %v", err)
 	}
@@ -98,29 +307,217 @@ func TestListTransfersFiltered(t *testing.T) {
 	database := db.NewTestDB(t)
 	ctx := context.Background()
 
-	item1, _ := CreateItem(ctx, database, "Widget", "")
-	item2, _ := CreateItem(ctx, database, "Gadget", "")
+	item1, _ := CreateItem(ctx, database, "Widget", "", "")
+	item2, _ := CreateItem(ctx, database, "Gadget", "", "")
 	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
 	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
 
-	AddStock(ctx, database, item1.ID, from.ID, 10, nil)
-	AddStock(ctx, database, item2.ID, from.ID, 10, nil)
+	AddStock(ctx, database, item1.ID, from.ID, 10, false, nil)
+	AddStock(ctx, database, item2.ID, from.ID, 10, false, nil)
 
-	CreateTransfer(ctx, database, item1.ID, from.ID, to.ID, 2, "", nil)
-	CreateTransfer(ctx, database, item2.ID, from.ID, to.ID, 3, "", nil)
+	CreateTransfer(ctx, database, item1.ID, from.ID, to.ID, 2, false, "", nil)
+	CreateTransfer(ctx, database, item2.ID, from.ID, to.ID, 3, false, "", nil)
 
-	all, _ := ListTransfers(ctx, database, 0, 0)
+	all, _ := ListTransfers(ctx, database, 0, 0, "")
 	if len(all) != 2 {
 		t.Errorf("expected 2 transfers, got %d", len(all))
 	}
 
-	byItem, _ := ListTransfers(ctx, database, item1.ID, 0)
+	byItem, _ := ListTransfers(ctx, database, item1.ID, 0, "")
 	if len(byItem) != 1 {
 		t.Errorf("expected 1 transfer for item1, got %d", len(byItem))
 	}
 
-	byOwner, _ := ListTransfers(ctx, database, 0, to.ID)
+	byOwner, _ := ListTransfers(ctx, database, 0, to.ID, "")
 	if len(byOwner) != 2 {
 		t.Errorf("expected 2 transfers for Alice, got %d", len(byOwner))
 	}
 }
+
+func TestListTransfersNotesContains(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item1, _ := CreateItem(ctx, database, "Widget", "", "")
+	item2, _ := CreateItem(ctx, database, "Gadget", "", "")
+	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, item1.ID, from.ID, 10, false, nil)
+	AddStock(ctx, database, item2.ID, from.ID, 10, false, nil)
+
+	CreateTransfer(ctx, database, item1.ID, from.ID, to.ID, 1, false, "Event 2024 setup", nil)
+	CreateTransfer(ctx, database, item2.ID, from.ID, to.ID, 1, false, "for event 2024", nil)
+	CreateTransfer(ctx, database, item1.ID, from.ID, to.ID, 1, false, "ticket #42", nil)
+	CreateTransfer(ctx, database, item1.ID, from.ID, to.ID, 1, false, "100% done", nil)
+	CreateTransfer(ctx, database, item1.ID, from.ID, to.ID, 1, false, "", nil)
+
+	tests := []struct {
+		itemID int64
+		notes  string
+		want   int
+	}{
+		{0, "EVENT 2024", 2},
+		{item1.ID, "event 2024", 1},
+		{0, "#42", 1},
+		{0, "%", 1},
+		{0, "nothing", 0},
+	}
+	for _, tt := range tests {
+		transfers, err := ListTransfers(ctx, database, tt.itemID, 0, tt.notes)
+		if err != nil {
+			t.Fatalf("ListTransfers(%q): %v", tt.notes, err)
+		}
+		if len(transfers) != tt.want {
+			t.Errorf("ListTransfers(item %d, %q): expected %d, got %d", tt.itemID, tt.notes, tt.want, len(transfers))
+		}
+	}
+
+	_, total, _ := ListTransfersPage(ctx, database, 0, to.ID, "event", 1, 0)
+	if total != 2 {
+		t.Errorf("expected page total 2 for notes filter, got %d", total)
+	}
+}
+
+func TestListTransfersPage(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
+	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, item.ID, from.ID, 10, false, nil)
+	for i := 0; i < 5; i++ {
+		CreateTransfer(ctx, database, item.ID, from.ID, to.ID, 1, false, "", nil)
+	}
+
+	page, total, err := ListTransfersPage(ctx, database, 0, 0, "", 2, 0)
+	if err != nil {
+		t.Fatalf("ListTransfersPage: %v", err)
+	}
+	if total != 5 || len(page) != 2 {
+		t.Fatalf("expected 2 of 5 transfers, got %d of %d", len(page), total)
+	}
+	if page[0].ID < page[1].ID {
+		t.Errorf("expected newest first, got ids %d, %d", page[0].ID, page[1].ID)
+	}
+
+	last, _, _ := ListTransfersPage(ctx, database, 0, 0, "", 2, 4)
+	if len(last) != 1 {
+		t.Errorf("expected 1 transfer on the last page, got %d", len(last))
+	}
+
+	_, total, _ = ListTransfersPage(ctx, database, 0, from.ID+to.ID+1, "", 2, 0)
+	if total != 0 {
+		t.Errorf("expected no transfers for unknown owner, got %d", total)
+	}
+}
+
+func TestCheckTransfer(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	item, _ := CreateItem(ctx, database, "Widget", "", "")
+	from, _ := CreateOwner(ctx, database, "Storage", model.OwnerTypeLocation)
+	to, _ := CreateOwner(ctx, database, "Alice", model.OwnerTypePerson)
+	AddStock(ctx, database, item.ID, from.ID, 10, false, nil)
+	AddStock(ctx, database, item.ID, to.ID, 2, false, nil)
+
+	preview, err := CheckTransfer(ctx, database, item.ID, from.ID, to.ID, 4, false)
+	if err != nil {
+		t.Fatalf("CheckTransfer: %v", err)
+	}
+	if preview.FromQuantity != 6 || preview.ToQuantity != 6 {
+		t.Errorf("expected 6/6 after the move, got %d/%d", preview.FromQuantity, preview.ToQuantity)
+	}
+
+	_, err = CheckTransfer(ctx, database, item.ID, from.ID, to.ID, 11, false)
+	var insufficient *InsufficientQuantityError
+	if !errors.As(err, &insufficient) || insufficient.Available != 10 {
+		t.Errorf("expected InsufficientQuantityError with 10 available, got %v", err)
+	}
+
+	if _, err := CheckTransfer(ctx, database, item.ID, from.ID, 9999, 1, false); err == nil {
+		t.Error("expected error for missing destination owner")
+	}
+	if _, err := CheckTransfer(ctx, database, item.ID, from.ID, to.ID, 4000, true); !errors.Is(err, ErrDivisibleChanged) {
+		t.Errorf("expected ErrDivisibleChanged for a stale divisible flag, got %v", err)
+	}
+
+	// Nothing was moved or recorded.
+	fromInv, _ := GetOwnerInventory(ctx, database, from.ID)
+	if len(fromInv) != 1 || fromInv[0].Quantity != 10 {
+		t.Errorf("expected Storage to still have 10, got %v", fromInv)
+	}
+	transfers, _ := ListTransfers(ctx, database, 0, 0, "")
+	if len(transfers) != 0 {
+		t.Errorf("expected no transfers, got %d", len(transfers))
+	}
+}
+
+func TestMoveAllInventory(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	rope, _ := CreateItem(ctx, database, "Rope", "", "")
+	laptop, _ := CreateItem(ctx, database, "Laptop", "", "")
+	tape, _ := CreateItem(ctx, database, "Tape", "", "")
+	roomA, _ := CreateOwner(ctx, database, "Room A", model.OwnerTypeLocation)
+	roomB, _ := CreateOwner(ctx, database, "Room B", model.OwnerTypeLocation)
+	AddStock(ctx, database, rope.ID, roomA.ID, 4, false, nil)
+	AddStock(ctx, database, rope.ID, roomB.ID, 1, false, nil)
+	AddStock(ctx, database, tape.ID, roomB.ID, 2, false, nil)
+	SetItemSerialized(ctx, database, laptop.ID, true)
+	AssignSerials(ctx, database, laptop.ID, roomA.ID, []string{"SN2", "SN1"})
+
+	transfers, err := MoveAllInventory(ctx, database, roomA.ID, roomB.ID, "renovation", nil)
+	if err != nil {
+		t.Fatalf("MoveAllInventory: %v", err)
+	}
+	if len(transfers) != 2 || transfers[0].ItemName != "Laptop" || transfers[1].ItemName != "Rope" {
+		t.Fatalf("expected transfers of Laptop and Rope, got %+v", transfers)
+	}
+	if transfers[0].Quantity != 2 || !slices.Equal(transfers[0].Serials, []string{"SN1", "SN2"}) {
+		t.Errorf("expected both laptop serials moved, got %+v", transfers[0])
+	}
+	if transfers[1].Quantity != 4 || transfers[1].Notes != "renovation" {
+		t.Errorf("expected 4 rope with notes, got %+v", transfers[1])
+	}
+
+	if inv, _ := GetOwnerInventory(ctx, database, roomA.ID); len(inv) != 0 {
+		t.Errorf("expected Room A empty, got %+v", inv)
+	}
+	inv, _ := GetOwnerInventory(ctx, database, roomB.ID)
+	got := map[string]int{}
+	for _, i := range inv {
+		got[i.ItemName] = i.Quantity
+	}
+	if want := map[string]int{"Laptop": 2, "Rope": 5, "Tape": 2}; !maps.Equal(got, want) {
+		t.Errorf("expected Room B to hold %v, got %v", want, got)
+	}
+	if serials, _ := ListSerials(ctx, database, laptop.ID, roomB.ID); len(serials) != 2 {
+		t.Errorf("expected Room B to hold both serials, got %+v", serials)
+	}
+	if history, _ := ListTransfers(ctx, database, 0, roomA.ID, ""); len(history) != 2 {
+		t.Errorf("expected both moves in the history, got %+v", history)
+	}
+	for _, o := range []int64{roomA.ID, roomB.ID} {
+		if owner, _ := GetOwner(ctx, database, o); owner == nil || owner.DeletedAt != nil {
+			t.Errorf("expected owner %d to remain, got %+v", o, owner)
+		}
+	}
+
+	if moved, err := MoveAllInventory(ctx, database, roomA.ID, roomB.ID, "", nil); err != nil || len(moved) != 0 {
+		t.Errorf("expected nothing to move from an empty owner, got %v %v", moved, err)
+	}
+	var invalid *ValidationError
+	if _, err := MoveAllInventory(ctx, database, roomB.ID, roomB.ID, "", nil); !errors.As(err, &invalid) {
+		t.Errorf("expected a ValidationError moving to the same owner, got %v", err)
+	}
+	DeleteOwner(ctx, database, roomA.ID, nil, "")
+	if _, err := MoveAllInventory(ctx, database, roomB.ID, roomA.ID, "", nil); !errors.As(err, &invalid) {
+		t.Errorf("expected a ValidationError moving to a deleted owner, got %v", err)
+	}
+	if inv, _ := GetOwnerInventory(ctx, database, roomB.ID); len(inv) != 3 {
+		t.Errorf("expected a failed move to leave Room B as it was, got %+v", inv)
+	}
+}
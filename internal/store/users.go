@@ -10,7 +10,7 @@ import (
 
 // CreateUser creates a new user.
 func CreateUser(ctx context.Context, db *sql.DB, username, passwordHash, role string) (*model.User, error) {
-	result, err := db.ExecContext(ctx,
+	result, err := exec(ctx, db,
 		`INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)`,
 		username, passwordHash, role,
 	)
@@ -30,9 +30,9 @@ func CreateUser(ctx context.Context, db *sql.DB, username, passwordHash, role st
 func GetUser(ctx context.Context, db *sql.DB, id int64) (*model.User, error) {
 	u := &model.User{}
 	err := db.QueryRowContext(ctx,
-		`SELECT id, username, password_hash, role, created_at, deleted_at
+		`SELECT id, username, password_hash, role, created_at, deleted_at, must_change_password, locale
 		 FROM users WHERE id = ?`, id,
-	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DeletedAt)
+	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DeletedAt, &u.MustChangePassword, &u.Locale)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -46,9 +46,9 @@ func GetUser(ctx context.Context, db *sql.DB, id int64) (*model.User, error) {
 func GetUserByUsername(ctx context.Context, db *sql.DB, username string) (*model.User, error) {
 	u := &model.User{}
 	err := db.QueryRowContext(ctx,
-		`SELECT id, username, password_hash, role, created_at, deleted_at
+		`SELECT id, username, password_hash, role, created_at, deleted_at, must_change_password, locale
 		 FROM users WHERE username = ? AND deleted_at IS NULL`, username,
-	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DeletedAt)
+	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DeletedAt, &u.MustChangePassword, &u.Locale)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -61,7 +61,7 @@ func GetUserByUsername(ctx context.Context, db *sql.DB, username string) (*model
 // ListUsers returns all non-deleted users.
 func ListUsers(ctx context.Context, db *sql.DB) ([]model.User, error) {
 	rows, err := db.QueryContext(ctx,
-		`SELECT id, username, password_hash, role, created_at, deleted_at
+		`SELECT id, username, password_hash, role, created_at, deleted_at, must_change_password, locale
 		 FROM users WHERE deleted_at IS NULL ORDER BY id`,
 	)
 	if err != nil {
@@ -72,7 +72,7 @@ func ListUsers(ctx context.Context, db *sql.DB) ([]model.User, error) {
 	var users []model.User
 	for rows.Next() {
 		var u model.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DeletedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DeletedAt, &u.MustChangePassword, &u.Locale); err != nil {
 			return nil, fmt.Errorf("scanning user: %w", err)
 		}
 		users = append(users, u)
@@ -83,7 +83,7 @@ func ListUsers(ctx context.Context, db *sql.DB) ([]model.User, error) {
 // UpdateUser updates a user's role. Returns an error if the user does not exist
 // or is soft-deleted.
 func UpdateUser(ctx context.Context, db *sql.DB, id int64, role string) error {
-	result, err := db.ExecContext(ctx,
+	result, err := exec(ctx, db,
 		`UPDATE users SET role = ? WHERE id = ? AND deleted_at IS NULL`,
 		role, id,
 	)
@@ -95,17 +95,20 @@ func UpdateUser(ctx context.Context, db *sql.DB, id int64, role string) error {
 		return fmt.Errorf("updating user rows affected: %w", err)
 	}
 	if n == 0 {
-		return fmt.Errorf("updating user: user not found")
+		return invalidf("updating user: user not found")
 	}
 	return nil
 }
 
-// UpdateUserPassword updates a user's password hash.
+// UpdateUserPassword updates a user's password hash and records the time of
+// the change, which invalidates tokens issued before it. mustChange sets or
+// clears the forced password change flag.
 // Returns an error if the user does not exist or is soft-deleted.
-func UpdateUserPassword(ctx context.Context, db *sql.DB, id int64, passwordHash string) error {
-	result, err := db.ExecContext(ctx,
-		`UPDATE users SET password_hash = ? WHERE id = ? AND deleted_at IS NULL`,
-		passwordHash, id,
+func UpdateUserPassword(ctx context.Context, db *sql.DB, id int64, passwordHash string, mustChange bool) error {
+	result, err := exec(ctx, db,
+		`UPDATE users SET password_hash = ?, password_changed_at = CURRENT_TIMESTAMP, must_change_password = ?
+		 WHERE id = ? AND deleted_at IS NULL`,
+		passwordHash, mustChange, id,
 	)
 	if err != nil {
 		return fmt.Errorf("updating user password: %w", err)
@@ -115,15 +118,59 @@ func UpdateUserPassword(ctx context.Context, db *sql.DB, id int64, passwordHash
 		return fmt.Errorf("checking rows affected: %w", err)
 	}
 	if n == 0 {
-		return fmt.Errorf("updating user password: user not found")
+		return invalidf("updating user password: user not found")
 	}
 	return nil
 }
 
+// SetMustChangePassword sets or clears a user's forced password change flag
+// without touching the password.
+func SetMustChangePassword(ctx context.Context, db *sql.DB, id int64, mustChange bool) error {
+	if _, err := exec(ctx, db,
+		`UPDATE users SET must_change_password = ? WHERE id = ?`, mustChange, id,
+	); err != nil {
+		return fmt.Errorf("setting must_change_password: %w", err)
+	}
+	return nil
+}
+
+// SetUserLocale sets a user's web UI locale. Returns an error if the user
+// does not exist or is soft-deleted.
+func SetUserLocale(ctx context.Context, db *sql.DB, id int64, locale string) error {
+	result, err := exec(ctx, db,
+		`UPDATE users SET locale = ? WHERE id = ? AND deleted_at IS NULL`, locale, id,
+	)
+	if err != nil {
+		return fmt.Errorf("setting user locale: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if n == 0 {
+		return invalidf("setting user locale: user not found")
+	}
+	return nil
+}
+
+// GetUserLocale returns a user's web UI locale, or "" if the user does not
+// exist.
+func GetUserLocale(ctx context.Context, db *sql.DB, id int64) (string, error) {
+	var locale string
+	err := db.QueryRowContext(ctx, `SELECT locale FROM users WHERE id = ?`, id).Scan(&locale)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("getting user locale: %w", err)
+	}
+	return locale, nil
+}
+
 // DeleteUser soft-deletes a user.
 // Returns an error if the user does not exist or is already deleted.
 func DeleteUser(ctx context.Context, db *sql.DB, id int64) error {
-	result, err := db.ExecContext(ctx,
+	result, err := exec(ctx, db,
 		`UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`,
 		id,
 	)
@@ -135,7 +182,77 @@ func DeleteUser(ctx context.Context, db *sql.DB, id int64) error {
 		return fmt.Errorf("checking rows affected: %w", err)
 	}
 	if n == 0 {
-		return fmt.Errorf("deleting user: user not found")
+		return invalidf("deleting user: user not found")
 	}
 	return nil
 }
+
+// ReassignUserRecords repoints everything attributed to user fromID to user
+// toID in one transaction: transfers.transferred_by, status_changes.user_id,
+// the deleted_by of items and owners, item_documents.uploaded_by and
+// inventory_adjustments.user_id. The source may already be deleted; the
+// target must be an active user. If deleteSource is set, the source is also
+// soft-deleted (if it isn't already) in the same transaction.
+func ReassignUserRecords(ctx context.Context, db *sql.DB, fromID, toID int64, deleteSource bool) (*model.ReassignResult, error) {
+	if fromID == toID {
+		return nil, invalidf("cannot reassign a user's records to themselves")
+	}
+
+	tx, err := beginImmediate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var sourceDeleted bool
+	err = tx.QueryRowContext(ctx, `SELECT deleted_at IS NOT NULL FROM users WHERE id = ?`, fromID).Scan(&sourceDeleted)
+	if err == sql.ErrNoRows {
+		return nil, invalidf("source user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking source user: %w", err)
+	}
+	var exists int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL`, toID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, invalidf("target user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking target user: %w", err)
+	}
+
+	result := &model.ReassignResult{FromUserID: fromID, ToUserID: toID}
+	for _, u := range []struct {
+		query string
+		count *int64
+	}{
+		{`UPDATE transfers SET transferred_by = ? WHERE transferred_by = ?`, &result.Transfers},
+		{`UPDATE status_changes SET user_id = ? WHERE user_id = ?`, &result.StatusChanges},
+		{`UPDATE items SET deleted_by = ? WHERE deleted_by = ?`, &result.ItemDeletions},
+		{`UPDATE owners SET deleted_by = ? WHERE deleted_by = ?`, &result.OwnerDeletions},
+		{`UPDATE item_documents SET uploaded_by = ? WHERE uploaded_by = ?`, &result.Documents},
+		{`UPDATE inventory_adjustments SET user_id = ? WHERE user_id = ?`, &result.Adjustments},
+	} {
+		res, err := tx.ExecContext(ctx, u.query, toID, fromID)
+		if err != nil {
+			return nil, fmt.Errorf("reassigning records: %w", err)
+		}
+		if *u.count, err = res.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("checking rows affected: %w", err)
+		}
+	}
+
+	if deleteSource && !sourceDeleted {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?`, fromID,
+		); err != nil {
+			return nil, fmt.Errorf("deleting source user: %w", err)
+		}
+		result.Deleted = true
+	}
+
+	if err := commit(tx); err != nil {
+		return nil, fmt.Errorf("committing reassignment: %w", err)
+	}
+	return result, nil
+}
@@ -126,7 +126,7 @@ func TestUpdateUserPassword(t *testing.T) {
 	ctx := context.Background()
 
 	user, _ := CreateUser(ctx, database, "pwuser", "oldhash", model.RoleUser)
-	UpdateUserPassword(ctx, database, user.ID, "newhash")
+	UpdateUserPassword(ctx, database, user.ID, "newhash", false)
 
 	got, _ := GetUser(ctx, database, user.ID)
 	if got.PasswordHash != "newhash" {
@@ -181,3 +181,131 @@ func TestUpdateUserRoleDeletedUser(t *testing.T) {
 		t.Error("expected error for deleted user, got nil")
 	}
 }
+
+func TestMustChangePassword(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	user, _ := CreateUser(ctx, database, "forced", "hash", model.RoleUser)
+	if user.MustChangePassword {
+		t.Fatal("expected new users not to be flagged")
+	}
+
+	if err := SetMustChangePassword(ctx, database, user.ID, true); err != nil {
+		t.Fatalf("SetMustChangePassword: %v", err)
+	}
+	got, _ := GetUserByUsername(ctx, database, "forced")
+	if !got.MustChangePassword {
+		t.Error("expected flag to be set")
+	}
+
+	// A password update sets or clears the flag as requested.
+	UpdateUserPassword(ctx, database, user.ID, "newhash", false)
+	got, _ = GetUser(ctx, database, user.ID)
+	if got.MustChangePassword {
+		t.Error("expected flag to be cleared by a self-change")
+	}
+	UpdateUserPassword(ctx, database, user.ID, "resethash", true)
+	got, _ = GetUser(ctx, database, user.ID)
+	if !got.MustChangePassword {
+		t.Error("expected flag to be set by a flagged reset")
+	}
+}
+
+func TestUserLocale(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	user, _ := CreateUser(ctx, database, "ana", "hash", model.RoleUser)
+	if user.Locale != model.DefaultLocale {
+		t.Errorf("expected default locale %q, got %q", model.DefaultLocale, user.Locale)
+	}
+
+	if err := SetUserLocale(ctx, database, user.ID, model.LocaleEnglish); err != nil {
+		t.Fatalf("SetUserLocale: %v", err)
+	}
+	if got, _ := GetUserLocale(ctx, database, user.ID); got != model.LocaleEnglish {
+		t.Errorf("expected locale %q, got %q", model.LocaleEnglish, got)
+	}
+
+	if err := SetUserLocale(ctx, database, 9999, model.LocaleEnglish); err == nil {
+		t.Error("expected error for missing user")
+	}
+	if got, err := GetUserLocale(ctx, database, 9999); err != nil || got != "" {
+		t.Errorf("expected empty locale for missing user, got %q, %v", got, err)
+	}
+}
+
+func TestReassignUserRecords(t *testing.T) {
+	database := db.NewTestDB(t)
+	ctx := context.Background()
+
+	leaver, _ := CreateUser(ctx, database, "leaver", "hash", model.RoleManager)
+	other, _ := CreateUser(ctx, database, "other", "hash", model.RoleUser)
+	system, _ := CreateUser(ctx, database, "system", "hash", model.RoleAdmin)
+
+	item, _ := CreateItem(ctx, database, "Drill", "", "")
+	spare, _ := CreateItem(ctx, database, "Spare", "", "")
+	shelf, _ := CreateOwner(ctx, database, "Shelf", model.OwnerTypeLocation)
+	bob, _ := CreateOwner(ctx, database, "Bob", model.OwnerTypePerson)
+	gone, _ := CreateOwner(ctx, database, "Gone", model.OwnerTypeLocation)
+	AddStock(ctx, database, item.ID, shelf.ID, 10, false, nil)
+
+	CreateTransfer(ctx, database, item.ID, shelf.ID, bob.ID, 1, false, "", &leaver.ID)
+	CreateTransfer(ctx, database, item.ID, shelf.ID, bob.ID, 1, false, "", &leaver.ID)
+	CreateTransfer(ctx, database, item.ID, shelf.ID, bob.ID, 1, false, "", &other.ID)
+	UpdateItem(ctx, database, item.ID, "Drill", "", "", model.ItemStatusDamaged, "dropped", &leaver.ID)
+	DeleteItem(ctx, database, spare.ID, &leaver.ID, "")
+	DeleteOwner(ctx, database, gone.ID, &leaver.ID, "")
+	AddDocument(ctx, database, item.ID, "receipt.pdf", "application/pdf", []byte("%PDF-1.4"), &leaver.ID)
+
+	result, err := ReassignUserRecords(ctx, database, leaver.ID, system.ID, true)
+	if err != nil {
+		t.Fatalf("ReassignUserRecords: %v", err)
+	}
+	if result.Transfers != 2 || result.StatusChanges != 1 || result.ItemDeletions != 1 || result.OwnerDeletions != 1 || result.Documents != 1 || !result.Deleted {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	transfers, _ := ListTransfers(ctx, database, item.ID, 0, "")
+	bySystem := 0
+	for _, tr := range transfers {
+		if tr.TransferredBy == nil {
+			t.Fatalf("transfer %d lost its author", tr.ID)
+		}
+		switch *tr.TransferredBy {
+		case leaver.ID:
+			t.Errorf("transfer %d still attributed to the source user", tr.ID)
+		case system.ID:
+			bySystem++
+		}
+	}
+	if bySystem != 2 {
+		t.Errorf("expected 2 transfers by system, got %d", bySystem)
+	}
+
+	history, _ := GetItemStatusHistory(ctx, database, item.ID)
+	if len(history) != 1 || history[0].UserID == nil || *history[0].UserID != system.ID {
+		t.Errorf("expected status change attributed to system, got %+v", history)
+	}
+	if deleted, _ := GetItem(ctx, database, spare.ID); deleted.DeletedBy == nil || *deleted.DeletedBy != system.ID {
+		t.Errorf("expected item deletion attributed to system, got %v", deleted.DeletedBy)
+	}
+	if u, _ := GetUser(ctx, database, leaver.ID); u.DeletedAt == nil {
+		t.Error("expected source user to be deleted")
+	}
+
+	// A deleted source can be reassigned again; a deleted target cannot.
+	if _, err := ReassignUserRecords(ctx, database, leaver.ID, other.ID, true); err != nil {
+		t.Errorf("reassigning from a deleted user: %v", err)
+	}
+	if _, err := ReassignUserRecords(ctx, database, other.ID, leaver.ID, false); err == nil {
+		t.Error("expected error for a deleted target")
+	}
+	if _, err := ReassignUserRecords(ctx, database, other.ID, other.ID, false); err == nil {
+		t.Error("expected error for reassigning to the same user")
+	}
+	if _, err := ReassignUserRecords(ctx, database, 9999, system.ID, false); err == nil {
+		t.Error("expected error for a missing source")
+	}
+}
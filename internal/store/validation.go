@@ -0,0 +1,20 @@
+package store
+
+import "fmt"
+
+// ValidationError is returned when a write is refused by the store's own
+// checks, such as a missing owner or a non-positive quantity, rather than by
+// the database. Its message is meant for the caller; any other error from
+// the store is internal.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// invalidf returns a ValidationError with a formatted message.
+func invalidf(format string, args ...any) error {
+	return &ValidationError{Err: fmt.Errorf(format, args...)}
+}
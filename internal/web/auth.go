@@ -6,13 +6,14 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/erazemk/skladisce/internal/api"
 	"github.com/erazemk/skladisce/internal/auth"
 	"github.com/erazemk/skladisce/internal/store"
 )
 
 // LoginPage handles GET /login.
 func (s *Server) LoginPage(w http.ResponseWriter, r *http.Request) {
-	s.Templates.Render(w, "login.html", &PageData{Title: "Prijava"})
+	s.render(w, r, "login.html", &PageData{Title: s.t(r, "login.title")})
 }
 
 // LoginSubmit handles POST /login.
@@ -21,52 +22,57 @@ func (s *Server) LoginSubmit(w http.ResponseWriter, r *http.Request) {
 	password := r.FormValue("password")
 
 	if username == "" || password == "" {
-		s.Templates.Render(w, "login.html", &PageData{
-			Title: "Prijava",
-			Error: "Vnesite uporabniško ime in geslo.",
+		s.render(w, r, "login.html", &PageData{
+			Title: s.t(r, "login.title"),
+			Error: s.t(r, "login.missingFields"),
 		})
 		return
 	}
 
 	user, err := store.GetUserByUsername(r.Context(), s.DB, username)
+	if err == nil && (user == nil || user.DeletedAt != nil) {
+		api.RecordLogin(r, store.New(s.DB), user, username, false)
+	}
 	if err != nil || user == nil || user.DeletedAt != nil {
-		s.Templates.Render(w, "login.html", &PageData{
-			Title: "Prijava",
-			Error: "Napačno uporabniško ime ali geslo.",
+		s.render(w, r, "login.html", &PageData{
+			Title: s.t(r, "login.title"),
+			Error: s.t(r, "login.invalid"),
 		})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		slog.Warn("login failed", "username", username, "remote", r.RemoteAddr)
-		s.Templates.Render(w, "login.html", &PageData{
-			Title: "Prijava",
-			Error: "Napačno uporabniško ime ali geslo.",
+		slog.Warn("login failed", "username", username, "remote", api.ClientIP(r))
+		api.RecordLogin(r, store.New(s.DB), user, username, false)
+		s.render(w, r, "login.html", &PageData{
+			Title: s.t(r, "login.title"),
+			Error: s.t(r, "login.invalid"),
 		})
 		return
 	}
 
-	token, err := auth.GenerateToken(s.JWTSecret, user.ID, user.Username, user.Role)
+	generate := auth.GenerateToken
+	if user.MustChangePassword {
+		generate = auth.GeneratePasswordChangeToken
+	}
+	token, err := generate(s.JWTSecret, user.ID, user.Username, user.Role)
 	if err != nil {
-		s.Templates.Render(w, "login.html", &PageData{
-			Title: "Prijava",
-			Error: "Napaka pri prijavi.",
+		s.render(w, r, "login.html", &PageData{
+			Title: s.t(r, "login.title"),
+			Error: s.t(r, "login.failed"),
 		})
 		return
 	}
 
-	// Cookie MaxAge matches JWT TokenExpiry (7 days).
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   int(auth.TokenExpiry.Seconds()),
-	})
+	setAuthCookie(w, token, s.BasePath)
 
-	slog.Info("user logged in", "user", user.Username, "role", user.Role)
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	api.RecordLogin(r, store.New(s.DB), user, username, true)
+	slog.Info("user logged in", "user", user.Username, "role", user.Role, "must_change_password", user.MustChangePassword)
+	if user.MustChangePassword {
+		s.redirect(w, r, "/settings")
+		return
+	}
+	s.redirect(w, r, "/")
 }
 
 // Logout handles POST /logout.
@@ -85,6 +91,6 @@ func (s *Server) Logout(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	clearAuthCookie(w)
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
+	clearAuthCookie(w, s.BasePath)
+	s.redirect(w, r, "/login")
 }
@@ -11,11 +11,11 @@ import (
 func (s *Server) Dashboard(w http.ResponseWriter, r *http.Request) {
 	claims := GetWebClaims(r.Context())
 
-	inventory, err := store.ListInventory(r.Context(), s.DB)
+	inventory, err := store.ListInventory(r.Context(), s.DB, 0, "", "")
 	if err != nil {
 		slog.Error("failed to list inventory for dashboard", "error", err)
 	}
-	transfers, err := store.ListTransfers(r.Context(), s.DB, 0, 0)
+	transfers, err := store.ListTransfers(r.Context(), s.DB, 0, 0, "")
 	if err != nil {
 		slog.Error("failed to list transfers for dashboard", "error", err)
 	}
@@ -25,12 +25,12 @@ func (s *Server) Dashboard(w http.ResponseWriter, r *http.Request) {
 		transfers = transfers[:10]
 	}
 
-	s.Templates.Render(w, "dashboard.html", &struct {
+	s.render(w, r, "dashboard.html", &struct {
 		PageData
 		Inventory       any
 		RecentTransfers any
 	}{
-		PageData:        PageData{Title: "Nadzorna plošča", User: claims, Token: GetWebToken(r.Context())},
+		PageData:        PageData{Title: s.t(r, "dashboard.title"), User: claims, Token: GetWebToken(r.Context())},
 		Inventory:       inventory,
 		RecentTransfers: transfers,
 	})
@@ -1,30 +1,45 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/erazemk/skladisce/internal/imaging"
 	"github.com/erazemk/skladisce/internal/model"
 	"github.com/erazemk/skladisce/internal/store"
 )
 
-// ItemsPage handles GET /items.
+// itemSearchLimit caps the number of results of an item search.
+const itemSearchLimit = 50
+
+// ItemsPage handles GET /items, searching with ?q= if it is set.
 func (s *Server) ItemsPage(w http.ResponseWriter, r *http.Request) {
 	claims := GetWebClaims(r.Context())
-	items, err := store.ListItems(r.Context(), s.DB, "")
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var items []model.Item
+	var err error
+	if query != "" {
+		items, err = store.SearchItems(r.Context(), s.DB, query, model.ItemFilter{}, itemSearchLimit)
+	} else {
+		items, err = store.ListItems(r.Context(), s.DB, model.ItemFilter{})
+	}
 	if err != nil {
 		slog.Error("failed to list items", "error", err)
 	}
 
-	s.Templates.Render(w, "items.html", &struct {
+	s.render(w, r, "items.html", &struct {
 		PageData
 		Items []model.Item
+		Query string
 	}{
-		PageData: PageData{Title: "Predmeti", User: claims, Token: GetWebToken(r.Context())},
+		PageData: PageData{Title: s.t(r, "items.title"), User: claims, Token: GetWebToken(r.Context())},
 		Items:    items,
+		Query:    query,
 	})
 }
 
@@ -56,25 +71,43 @@ func (s *Server) ItemDetailPage(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		slog.Error("failed to get item history", "error", err)
 	}
+	statusHistory, err := store.GetItemStatusHistory(r.Context(), s.DB, id)
+	if err != nil {
+		slog.Error("failed to get item status history", "error", err)
+	}
 	owners, err := store.ListOwners(r.Context(), s.DB, "")
 	if err != nil {
 		slog.Error("failed to list owners", "error", err)
 	}
+	documents, err := store.ListDocuments(r.Context(), s.DB, id)
+	if err != nil {
+		slog.Error("failed to list documents", "error", err)
+	}
+	statuses, err := store.ListStatuses(r.Context(), s.DB)
+	if err != nil {
+		slog.Error("failed to list statuses", "error", err)
+	}
 
-	s.Templates.Render(w, "item_detail.html", &struct {
+	s.render(w, r, "item_detail.html", &struct {
 		PageData
-		Item         *model.Item
-		Distribution []model.Inventory
-		History      []model.Transfer
-		Owners       []model.Owner
-		CreatedAt    any
+		Item          *model.Item
+		Distribution  []model.Inventory
+		History       []model.Transfer
+		StatusHistory []model.StatusChange
+		Owners        []model.Owner
+		Documents     []model.Document
+		Statuses      []model.ItemStatus
+		CreatedAt     any
 	}{
-		PageData:     PageData{Title: item.Name, User: claims, Token: GetWebToken(r.Context())},
-		Item:         item,
-		Distribution: dist,
-		History:      history,
-		Owners:       owners,
-		CreatedAt:    item.CreatedAt,
+		PageData:      PageData{Title: item.Name, User: claims, Token: GetWebToken(r.Context())},
+		Item:          item,
+		Distribution:  dist,
+		History:       history,
+		StatusHistory: statusHistory,
+		Owners:        owners,
+		Documents:     documents,
+		Statuses:      statuses,
+		CreatedAt:     item.CreatedAt,
 	})
 }
 
@@ -86,20 +119,30 @@ func (s *Server) ItemCreateSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	name := r.FormValue("name")
+	name, err := model.NormalizeName(r.FormValue("name"))
 	description := r.FormValue("description")
+	condition := r.FormValue("condition")
 
-	if name == "" {
-		http.Redirect(w, r, "/items", http.StatusSeeOther)
+	if err != nil {
+		s.redirect(w, r, "/items")
+		return
+	}
+	if err := model.ValidateDescription(description); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := model.ValidateCondition(condition); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if _, err := store.CreateItem(r.Context(), s.DB, name, description); err != nil {
+	if _, err := store.CreateItem(r.Context(), s.DB, name, description, condition); err != nil {
 		slog.Error("failed to create item", "error", err)
 	} else {
 		slog.Info("item created", "user", claims.Username, "item", name)
+		s.flash(w, "flash.itemCreated")
 	}
-	http.Redirect(w, r, "/items", http.StatusSeeOther)
+	s.redirect(w, r, "/items")
 }
 
 // ItemUpdateSubmit handles POST /items/{id}.
@@ -116,18 +159,38 @@ func (s *Server) ItemUpdateSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	name := r.FormValue("name")
+	name, err := model.NormalizeName(r.FormValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	description := r.FormValue("description")
+	if err := model.ValidateDescription(description); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	condition := r.FormValue("condition")
+	if err := model.ValidateCondition(condition); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	status := r.FormValue("status")
+	reason := r.FormValue("reason")
 
-	if err := store.UpdateItem(r.Context(), s.DB, id, name, description, status); err != nil {
+	userID := claims.UserID
+	if err := store.UpdateItem(r.Context(), s.DB, id, name, description, condition, status, reason, &userID); err != nil {
+		var invalid *store.ValidationError
+		if errors.As(err, &invalid) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		slog.Error("failed to update item", "error", err)
 		http.Error(w, "failed to update", http.StatusInternalServerError)
 		return
 	}
 
 	slog.Info("item updated", "user", claims.Username, "item", name, "status", status)
-	http.Redirect(w, r, fmt.Sprintf("/items/%d", id), http.StatusSeeOther)
+	s.redirect(w, r, fmt.Sprintf("/items/%d", id))
 }
 
 // ItemStockSubmit handles POST /items/{id}/stock.
@@ -145,10 +208,20 @@ func (s *Server) ItemStockSubmit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ownerID, _ := strconv.ParseInt(r.FormValue("owner_id"), 10, 64)
-	quantity, _ := strconv.Atoi(r.FormValue("quantity"))
+	divisible, err := store.ItemDivisible(r.Context(), s.DB, id)
+	if err != nil {
+		slog.Error("failed to check item", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	quantity, err := model.ParseQuantity(r.FormValue("quantity"), divisible)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	userID := claims.UserID
-	if err := store.AddStock(r.Context(), s.DB, id, ownerID, quantity, &userID); err != nil {
+	if err := store.AddStock(r.Context(), s.DB, id, ownerID, quantity, divisible, &userID); err != nil {
 		slog.Warn("failed to add stock", "error", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -164,8 +237,8 @@ func (s *Server) ItemStockSubmit(w http.ResponseWriter, r *http.Request) {
 	if owner != nil {
 		ownerName = owner.Name
 	}
-	slog.Info("stock added", "user", claims.Username, "item", itemName, "owner", ownerName, "quantity", quantity)
-	http.Redirect(w, r, fmt.Sprintf("/items/%d", id), http.StatusSeeOther)
+	slog.Info("stock added", "user", claims.Username, "item", itemName, "owner", ownerName, "quantity", model.FormatQuantity(quantity, divisible))
+	s.redirect(w, r, fmt.Sprintf("/items/%d", id))
 }
 
 // ItemImageSubmit handles POST /items/{id}/image.
@@ -182,9 +255,13 @@ func (s *Server) ItemImageSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 5<<20)
-	if err := r.ParseMultipartForm(5 << 20); err != nil {
-		http.Error(w, "file too large", http.StatusBadRequest)
+	r.Body = http.MaxBytesReader(w, r.Body, imaging.MaxUploadSize)
+	if err := r.ParseMultipartForm(imaging.MaxUploadSize); err != nil {
+		if imaging.TooLarge(err) {
+			http.Error(w, imaging.TooLargeMessage, http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid multipart form", http.StatusBadRequest)
 		return
 	}
 
@@ -195,18 +272,33 @@ func (s *Server) ItemImageSubmit(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	quality, err := imaging.ParseQuality(r.FormValue("quality"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Process the image: validate format by sniffing bytes, downscale, compress.
-	result, err := imaging.Process(file)
+	result, err := imaging.ProcessQuality(file, quality)
 	if err != nil {
+		if imaging.TooLarge(err) {
+			http.Error(w, imaging.TooLargeMessage, http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := store.SetItemImage(r.Context(), s.DB, id, result.Data, result.MIME); err != nil {
+	changed, err := store.SetItemImage(r.Context(), s.DB, id, result.Data, result.MIME)
+	if err != nil {
 		slog.Error("failed to save image", "error", err)
 		http.Error(w, "failed to save image", http.StatusInternalServerError)
 		return
 	}
+	if !changed {
+		s.redirect(w, r, fmt.Sprintf("/items/%d", id))
+		return
+	}
 
 	item, _ := store.GetItem(r.Context(), s.DB, id)
 	itemName := fmt.Sprintf("id:%d", id)
@@ -214,5 +306,5 @@ func (s *Server) ItemImageSubmit(w http.ResponseWriter, r *http.Request) {
 		itemName = item.Name
 	}
 	slog.Info("item image uploaded", "user", claims.Username, "item", itemName)
-	http.Redirect(w, r, fmt.Sprintf("/items/%d", id), http.StatusSeeOther)
+	s.redirect(w, r, fmt.Sprintf("/items/%d", id))
 }
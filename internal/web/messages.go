@@ -0,0 +1,339 @@
+package web
+
+import (
+	"fmt"
+
+	"github.com/erazemk/skladisce/internal/model"
+)
+
+// messages holds the UI strings for each locale, keyed by message ID.
+// Messages with arguments are fmt format strings. Every key must exist in
+// the Slovenian catalog, which is the fallback for missing translations.
+var messages = map[string]map[string]string{
+	model.LocaleSlovenian: {
+		"role.admin":   "Administrator",
+		"role.manager": "Skladiščar",
+		"role.user":    "Uporabnik",
+
+		"status.active":  "Aktiven",
+		"status.damaged": "Poškodovan",
+		"status.lost":    "Izgubljen",
+		"status.removed": "Odpisan",
+
+		"ownerType.person":   "Oseba",
+		"ownerType.location": "Lokacija",
+
+		"locale.sl": "Slovenščina",
+		"locale.en": "English",
+
+		"nav.items":       "Predmeti",
+		"nav.owners":      "Lastniki",
+		"nav.transfers":   "Prenosi",
+		"nav.newTransfer": "Nov prenos",
+		"nav.users":       "Uporabniki",
+		"nav.settings":    "Nastavitve",
+		"nav.logout":      "Odjava",
+
+		"common.save":        "Shrani",
+		"common.cancel":      "Prekliči",
+		"common.edit":        "Uredi",
+		"common.delete":      "Izbriši",
+		"common.details":     "Podrobnosti",
+		"common.confirm":     "Ali ste prepričani?",
+		"common.optional":    "Neobvezno",
+		"common.name":        "Ime",
+		"common.type":        "Tip",
+		"common.created":     "Ustvarjeno",
+		"common.date":        "Datum",
+		"common.item":        "Predmet",
+		"common.owner":       "Lastnik",
+		"common.quantity":    "Količina",
+		"common.qtyShort":    "Kol.",
+		"common.from":        "Od",
+		"common.to":          "Do",
+		"common.notes":       "Opombe",
+		"common.inventory":   "Inventar",
+		"common.username":    "Uporabniško ime",
+		"common.password":    "Geslo",
+		"common.role":        "Vloga",
+		"common.user":        "Uporabnik",
+		"common.noTransfers": "Ni prenosov.",
+
+		"login.title":         "Prijava",
+		"login.submit":        "Prijava",
+		"login.missingFields": "Vnesite uporabniško ime in geslo.",
+		"login.invalid":       "Napačno uporabniško ime ali geslo.",
+		"login.failed":        "Napaka pri prijavi.",
+
+		"dashboard.title":           "Nadzorna plošča",
+		"dashboard.noInventory":     "Ni inventarja.",
+		"dashboard.recentTransfers": "Zadnji prenosi",
+
+		"items.title":                "Predmeti",
+		"items.add":                  "Dodaj predmet",
+		"items.new":                  "Nov predmet",
+		"items.description":          "Opis",
+		"items.condition":            "Ohranjenost",
+		"items.conditionPlaceholder": "Npr. opraskan pokrov, deluje",
+		"items.status":               "Stanje",
+		"items.searchPlaceholder":    "Išči po imenu, opisu ali ohranjenosti",
+		"items.search":               "Išči",
+		"items.noResults":            "Ni zadetkov.",
+		"items.none":                 "Ni predmetov.",
+
+		"item.edit":             "Uredi predmet",
+		"item.statusReason":     "Razlog spremembe stanja",
+		"item.descriptionLabel": "Opis:",
+		"item.noDescription":    "Ni opisa.",
+		"item.conditionLabel":   "Ohranjenost:",
+		"item.statusLabel":      "Stanje:",
+		"item.createdLabel":     "Ustvarjeno:",
+		"item.image":            "Slika",
+		"item.uploadImage":      "Naloži sliko",
+		"item.documents":        "Dokumenti",
+		"item.distribution":     "Razporeditev",
+		"item.noStock":          "Ni zalog.",
+		"item.addStock":         "Dodaj zalogo",
+		"item.chooseOwner":      "Izberi lastnika",
+		"item.statusHistory":    "Zgodovina stanja",
+		"item.statusFrom":       "Iz",
+		"item.statusTo":         "V",
+		"item.reason":           "Razlog",
+		"item.transferHistory":  "Zgodovina prenosov",
+
+		"owners.title": "Lastniki",
+		"owners.add":   "Dodaj lastnika",
+		"owners.new":   "Nov lastnik",
+		"owners.none":  "Ni lastnikov.",
+
+		"owner.edit":        "Uredi lastnika",
+		"owner.noInventory": "Ta lastnik nima inventarja.",
+
+		"transfers.title": "Prenosi",
+
+		"transfer.title":        "Nov prenos",
+		"transfer.chooseItem":   "Izberi predmet",
+		"transfer.fromOwner":    "Od (lastnik)",
+		"transfer.chooseSource": "Izberi izvor",
+		"transfer.toOwner":      "Do (lastnik)",
+		"transfer.chooseTarget": "Izberi cilj",
+		"transfer.submit":       "Izvedi prenos",
+		"transfer.available":    "Na voljo: ",
+		"transfer.failed":       "Prenos ni uspel. Preverite količino in lastnika.",
+		"transfer.insufficient": "Prenos ni uspel. Na voljo: %s, zahtevano: %s.",
+		"transfer.notesTooLong": "Opomba ne sme biti daljša od %d znakov.",
+		"transfer.notOwn":       "Prenašate lahko le predmete k sebi ali od sebe.",
+
+		"users.title":           "Uporabniki",
+		"users.add":             "Dodaj uporabnika",
+		"users.new":             "Nov uporabnik",
+		"users.changeRole":      "Spremeni vlogo",
+		"users.resetPassword":   "Ponastavi geslo",
+		"users.confirmDelete":   "Ali ste prepričani, da želite izbrisati uporabnika %s?",
+		"users.current":         "Trenutni uporabnik",
+		"users.none":            "Ni uporabnikov.",
+		"users.resetFor":        "Novo geslo za uporabnika",
+		"users.newPassword":     "Novo geslo",
+		"users.requireChange":   "Zahtevaj spremembo gesla ob prijavi",
+		"users.reset":           "Ponastavi",
+		"users.roleFor":         "Nova vloga za uporabnika",
+		"users.invalidPassword": "Geslo: %s",
+
+		"settings.title":            "Nastavitve",
+		"settings.changePassword":   "Spremeni geslo",
+		"settings.currentPassword":  "Trenutno geslo",
+		"settings.newPassword":      "Novo geslo",
+		"settings.language":         "Jezik",
+		"settings.mustChange":       "Pred nadaljevanjem morate spremeniti geslo.",
+		"settings.missingPasswords": "Vnesite trenutno in novo geslo.",
+		"settings.passwordTooShort": "Novo geslo mora imeti vsaj %d znakov.",
+		"settings.userLookupFailed": "Napaka pri pridobivanju uporabnika.",
+		"settings.wrongPassword":    "Trenutno geslo ni pravilno.",
+		"settings.hashFailed":       "Napaka pri shranjevanju gesla.",
+		"settings.updateFailed":     "Napaka pri posodabljanju gesla.",
+		"settings.passwordChanged":  "Geslo uspešno spremenjeno.",
+		"settings.languageChanged":  "Jezik spremenjen.",
+		"settings.languageFailed":   "Napaka pri shranjevanju jezika.",
+
+		"flash.itemCreated":     "Predmet dodan.",
+		"flash.ownerCreated":    "Lastnik dodan.",
+		"flash.transferCreated": "Prenos izveden.",
+		"flash.userCreated":     "Uporabnik dodan.",
+	},
+	model.LocaleEnglish: {
+		"role.admin":   "Administrator",
+		"role.manager": "Warehouse manager",
+		"role.user":    "User",
+
+		"status.active":  "Active",
+		"status.damaged": "Damaged",
+		"status.lost":    "Lost",
+		"status.removed": "Written off",
+
+		"ownerType.person":   "Person",
+		"ownerType.location": "Location",
+
+		"locale.sl": "Slovenščina",
+		"locale.en": "English",
+
+		"nav.items":       "Items",
+		"nav.owners":      "Owners",
+		"nav.transfers":   "Transfers",
+		"nav.newTransfer": "New transfer",
+		"nav.users":       "Users",
+		"nav.settings":    "Settings",
+		"nav.logout":      "Log out",
+
+		"common.save":        "Save",
+		"common.cancel":      "Cancel",
+		"common.edit":        "Edit",
+		"common.delete":      "Delete",
+		"common.details":     "Details",
+		"common.confirm":     "Are you sure?",
+		"common.optional":    "Optional",
+		"common.name":        "Name",
+		"common.type":        "Type",
+		"common.created":     "Created",
+		"common.date":        "Date",
+		"common.item":        "Item",
+		"common.owner":       "Owner",
+		"common.quantity":    "Quantity",
+		"common.qtyShort":    "Qty",
+		"common.from":        "From",
+		"common.to":          "To",
+		"common.notes":       "Notes",
+		"common.inventory":   "Inventory",
+		"common.username":    "Username",
+		"common.password":    "Password",
+		"common.role":        "Role",
+		"common.user":        "User",
+		"common.noTransfers": "No transfers.",
+
+		"login.title":         "Log in",
+		"login.submit":        "Log in",
+		"login.missingFields": "Enter your username and password.",
+		"login.invalid":       "Wrong username or password.",
+		"login.failed":        "Login failed.",
+
+		"dashboard.title":           "Dashboard",
+		"dashboard.noInventory":     "No inventory.",
+		"dashboard.recentTransfers": "Recent transfers",
+
+		"items.title":                "Items",
+		"items.add":                  "Add item",
+		"items.new":                  "New item",
+		"items.description":          "Description",
+		"items.condition":            "Condition",
+		"items.conditionPlaceholder": "E.g. scratched lid, works fine",
+		"items.status":               "Status",
+		"items.searchPlaceholder":    "Search by name, description or condition",
+		"items.search":               "Search",
+		"items.noResults":            "No matches.",
+		"items.none":                 "No items.",
+
+		"item.edit":             "Edit item",
+		"item.statusReason":     "Reason for status change",
+		"item.descriptionLabel": "Description:",
+		"item.noDescription":    "No description.",
+		"item.conditionLabel":   "Condition:",
+		"item.statusLabel":      "Status:",
+		"item.createdLabel":     "Created:",
+		"item.image":            "Image",
+		"item.uploadImage":      "Upload image",
+		"item.documents":        "Documents",
+		"item.distribution":     "Distribution",
+		"item.noStock":          "No stock.",
+		"item.addStock":         "Add stock",
+		"item.chooseOwner":      "Choose an owner",
+		"item.statusHistory":    "Status history",
+		"item.statusFrom":       "From",
+		"item.statusTo":         "To",
+		"item.reason":           "Reason",
+		"item.transferHistory":  "Transfer history",
+
+		"owners.title": "Owners",
+		"owners.add":   "Add owner",
+		"owners.new":   "New owner",
+		"owners.none":  "No owners.",
+
+		"owner.edit":        "Edit owner",
+		"owner.noInventory": "This owner holds no inventory.",
+
+		"transfers.title": "Transfers",
+
+		"transfer.title":        "New transfer",
+		"transfer.chooseItem":   "Choose an item",
+		"transfer.fromOwner":    "From (owner)",
+		"transfer.chooseSource": "Choose a source",
+		"transfer.toOwner":      "To (owner)",
+		"transfer.chooseTarget": "Choose a destination",
+		"transfer.submit":       "Transfer",
+		"transfer.available":    "Available: ",
+		"transfer.failed":       "Transfer failed. Check the quantity and owner.",
+		"transfer.insufficient": "Transfer failed. Available: %s, requested: %s.",
+		"transfer.notesTooLong": "Notes must not exceed %d characters.",
+		"transfer.notOwn":       "You may only transfer items to or from yourself.",
+
+		"users.title":           "Users",
+		"users.add":             "Add user",
+		"users.new":             "New user",
+		"users.changeRole":      "Change role",
+		"users.resetPassword":   "Reset password",
+		"users.confirmDelete":   "Are you sure you want to delete user %s?",
+		"users.current":         "Current user",
+		"users.none":            "No users.",
+		"users.resetFor":        "New password for user",
+		"users.newPassword":     "New password",
+		"users.requireChange":   "Require a password change on login",
+		"users.reset":           "Reset",
+		"users.roleFor":         "New role for user",
+		"users.invalidPassword": "Password: %s",
+
+		"settings.title":            "Settings",
+		"settings.changePassword":   "Change password",
+		"settings.currentPassword":  "Current password",
+		"settings.newPassword":      "New password",
+		"settings.language":         "Language",
+		"settings.mustChange":       "You must change your password before continuing.",
+		"settings.missingPasswords": "Enter your current and new password.",
+		"settings.passwordTooShort": "The new password must be at least %d characters long.",
+		"settings.userLookupFailed": "Failed to load your account.",
+		"settings.wrongPassword":    "The current password is wrong.",
+		"settings.hashFailed":       "Failed to save the password.",
+		"settings.updateFailed":     "Failed to update the password.",
+		"settings.passwordChanged":  "Password changed.",
+		"settings.languageChanged":  "Language changed.",
+		"settings.languageFailed":   "Failed to save the language.",
+
+		"flash.itemCreated":     "Item added.",
+		"flash.ownerCreated":    "Owner added.",
+		"flash.transferCreated": "Transfer made.",
+		"flash.userCreated":     "User added.",
+	},
+}
+
+// translate returns the message for key in locale, formatted with args if
+// any. Unknown locales and missing keys fall back to Slovenian, and a key
+// missing there too is returned as is.
+func translate(locale, key string, args ...any) string {
+	msg, ok := messages[locale][key]
+	if !ok {
+		msg, ok = messages[model.DefaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// translateOr returns the message for key in locale, or fallback if no
+// catalog has it.
+func translateOr(locale, key, fallback string) string {
+	if msg := translate(locale, key); msg != key {
+		return msg
+	}
+	return fallback
+}
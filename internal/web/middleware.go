@@ -5,8 +5,11 @@ import (
 	"database/sql"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/erazemk/skladisce/internal/auth"
+	"github.com/erazemk/skladisce/internal/model"
 	"github.com/erazemk/skladisce/internal/store"
 )
 
@@ -14,22 +17,27 @@ type webContextKey string
 
 const webClaimsKey webContextKey = "webclaims"
 const webTokenKey webContextKey = "webtoken"
+const webLocaleKey webContextKey = "weblocale"
+const webFlashKey webContextKey = "webflash"
 
 // CookieAuthMiddleware validates JWT from cookie, checks token revocation,
-// and adds claims to context.
-func CookieAuthMiddleware(secret string, db *sql.DB) func(http.Handler) http.Handler {
+// and adds claims and the user's UI locale to context. Unauthenticated requests are redirected to the
+// login page under basePath, and users who must change their password to the
+// settings page.
+func CookieAuthMiddleware(secret string, db *sql.DB, basePath string) func(http.Handler) http.Handler {
+	login := basePath + "/login"
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			cookie, err := r.Cookie("token")
 			if err != nil || cookie.Value == "" {
-				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				http.Redirect(w, r, login, http.StatusSeeOther)
 				return
 			}
 
 			claims, err := auth.ValidateToken(secret, cookie.Value)
 			if err != nil {
-				clearAuthCookie(w)
-				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				clearAuthCookie(w, basePath)
+				http.Redirect(w, r, login, http.StatusSeeOther)
 				return
 			}
 
@@ -38,30 +46,135 @@ func CookieAuthMiddleware(secret string, db *sql.DB) func(http.Handler) http.Han
 				revoked, err := store.IsTokenRevoked(r.Context(), db, claims.ID)
 				if err != nil {
 					slog.Error("failed to check token revocation", "error", err)
-					clearAuthCookie(w)
-					http.Redirect(w, r, "/login", http.StatusSeeOther)
+					clearAuthCookie(w, basePath)
+					http.Redirect(w, r, login, http.StatusSeeOther)
 					return
 				}
 				if revoked {
-					clearAuthCookie(w)
-					http.Redirect(w, r, "/login", http.StatusSeeOther)
+					clearAuthCookie(w, basePath)
+					http.Redirect(w, r, login, http.StatusSeeOther)
 					return
 				}
 			}
 
+			// Tokens issued before the last password change are no longer valid.
+			if claims.IssuedAt != nil {
+				stale, err := store.IssuedBeforePasswordChange(r.Context(), db, claims.UserID, claims.IssuedAt.Time)
+				if err != nil {
+					slog.Error("failed to check password change", "error", err)
+				}
+				if err != nil || stale {
+					clearAuthCookie(w, basePath)
+					http.Redirect(w, r, login, http.StatusSeeOther)
+					return
+				}
+			}
+
+			// Until the password is changed, only the settings pages are usable.
+			if claims.MustChangePassword && r.URL.Path != "/settings" && !strings.HasPrefix(r.URL.Path, "/settings/") {
+				http.Redirect(w, r, basePath+"/settings", http.StatusSeeOther)
+				return
+			}
+
+			locale, err := store.GetUserLocale(r.Context(), db, claims.UserID)
+			if err != nil {
+				slog.Error("failed to get user locale", "error", err)
+			}
+
 			ctx := context.WithValue(r.Context(), webClaimsKey, claims)
 			ctx = context.WithValue(ctx, webTokenKey, cookie.Value)
+			ctx = context.WithValue(ctx, webLocaleKey, locale)
+			if flash, err := r.Cookie(flashCookie); err == nil && validFlash(flash.Value) {
+				ctx = context.WithValue(ctx, webFlashKey, flash.Value)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// requireRole refuses pages to users below minimum. An empty minimum lets
+// every role through.
+func requireRole(minimum string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if minimum == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := GetWebClaims(r.Context())
+			if claims == nil || !model.RoleAtLeast(claims.Role, minimum) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// cookiePath scopes the session cookie to the base path.
+func cookiePath(basePath string) string {
+	if basePath == "" {
+		return "/"
+	}
+	return basePath
+}
+
+// setAuthCookie stores the session token. Cookie MaxAge matches JWT
+// TokenExpiry.
+func setAuthCookie(w http.ResponseWriter, token, basePath string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    token,
+		Path:     cookiePath(basePath),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(auth.TokenExpiry.Seconds()),
+	})
+}
+
 // clearAuthCookie clears the authentication cookie with consistent attributes.
-func clearAuthCookie(w http.ResponseWriter) {
+func clearAuthCookie(w http.ResponseWriter, basePath string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
 		Value:    "",
-		Path:     "/",
+		Path:     cookiePath(basePath),
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// flashCookie carries a success message across a redirect to the next page
+// rendered. Its value is a "flash." message key rather than text, so a
+// forged cookie can only show one of the catalog's messages.
+const flashCookie = "flash"
+
+// flashMaxAge bounds how long an unshown flash waits for the next page.
+const flashMaxAge = time.Minute
+
+// validFlash reports whether key is a flash message in the catalog.
+func validFlash(key string) bool {
+	_, ok := messages[model.DefaultLocale][key]
+	return ok && strings.HasPrefix(key, "flash.")
+}
+
+// setFlashCookie stores the flash message key for the next page render.
+func setFlashCookie(w http.ResponseWriter, key, basePath string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookie,
+		Value:    key,
+		Path:     cookiePath(basePath),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(flashMaxAge.Seconds()),
+	})
+}
+
+// clearFlashCookie removes the flash once it has been shown.
+func clearFlashCookie(w http.ResponseWriter, basePath string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookie,
+		Value:    "",
+		Path:     cookiePath(basePath),
 		MaxAge:   -1,
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
@@ -79,3 +192,13 @@ func GetWebToken(ctx context.Context) string {
 	token, _ := ctx.Value(webTokenKey).(string)
 	return token
 }
+
+// GetWebLocale returns the UI locale of the web user, or the default locale
+// if it is unknown (e.g. on the login page).
+func GetWebLocale(ctx context.Context) string {
+	locale, _ := ctx.Value(webLocaleKey).(string)
+	if !model.ValidLocale(locale) {
+		return model.DefaultLocale
+	}
+	return locale
+}
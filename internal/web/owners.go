@@ -18,11 +18,11 @@ func (s *Server) OwnersPage(w http.ResponseWriter, r *http.Request) {
 		slog.Error("failed to list owners", "error", err)
 	}
 
-	s.Templates.Render(w, "owners.html", &struct {
+	s.render(w, r, "owners.html", &struct {
 		PageData
 		Owners []model.Owner
 	}{
-		PageData: PageData{Title: "Lastniki", User: claims, Token: GetWebToken(r.Context())},
+		PageData: PageData{Title: s.t(r, "owners.title"), User: claims, Token: GetWebToken(r.Context())},
 		Owners:   owners,
 	})
 }
@@ -52,7 +52,7 @@ func (s *Server) OwnerDetailPage(w http.ResponseWriter, r *http.Request) {
 		slog.Error("failed to get owner inventory", "error", err)
 	}
 
-	s.Templates.Render(w, "owner_detail.html", &struct {
+	s.render(w, r, "owner_detail.html", &struct {
 		PageData
 		Owner     *model.Owner
 		Inventory []model.Inventory
@@ -71,11 +71,11 @@ func (s *Server) OwnerCreateSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	name := r.FormValue("name")
+	name, err := model.NormalizeName(r.FormValue("name"))
 	ownerType := r.FormValue("type")
 
-	if name == "" || ownerType == "" {
-		http.Redirect(w, r, "/owners", http.StatusSeeOther)
+	if err != nil || ownerType == "" {
+		s.redirect(w, r, "/owners")
 		return
 	}
 
@@ -83,8 +83,9 @@ func (s *Server) OwnerCreateSubmit(w http.ResponseWriter, r *http.Request) {
 		slog.Error("failed to create owner", "error", err)
 	} else {
 		slog.Info("owner created", "user", claims.Username, "owner", name, "type", ownerType)
+		s.flash(w, "flash.ownerCreated")
 	}
-	http.Redirect(w, r, "/owners", http.StatusSeeOther)
+	s.redirect(w, r, "/owners")
 }
 
 // OwnerUpdateSubmit handles POST /owners/{id}.
@@ -101,9 +102,9 @@ func (s *Server) OwnerUpdateSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	name := r.FormValue("name")
-	if name == "" {
-		http.Redirect(w, r, fmt.Sprintf("/owners/%d", id), http.StatusSeeOther)
+	name, err := model.NormalizeName(r.FormValue("name"))
+	if err != nil {
+		s.redirect(w, r, fmt.Sprintf("/owners/%d", id))
 		return
 	}
 
@@ -112,5 +113,5 @@ func (s *Server) OwnerUpdateSubmit(w http.ResponseWriter, r *http.Request) {
 	} else {
 		slog.Info("owner updated", "user", claims.Username, "owner", name)
 	}
-	http.Redirect(w, r, fmt.Sprintf("/owners/%d", id), http.StatusSeeOther)
+	s.redirect(w, r, fmt.Sprintf("/owners/%d", id))
 }
@@ -6,13 +6,29 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/erazemk/skladisce/internal/api"
 	"github.com/erazemk/skladisce/internal/store"
 	webembed "github.com/erazemk/skladisce/web"
 )
 
+// Options holds optional runtime settings for the web router. The zero value
+// is ready to use.
+type Options struct {
+	// RestrictTransfers applies the API's transfer restriction (see
+	// api.AllowTransfer) to the new transfer form.
+	RestrictTransfers bool
+
+	// ReadRole applies the API's minimum role for reads (see
+	// api.Options.ReadRole) to the pages listing items, owners and
+	// transfers. If empty, every role may see them.
+	ReadRole string
+}
+
 // NewRouter creates the web page router with all page routes registered.
-func NewRouter(db *sql.DB, jwtSecret string) (http.Handler, error) {
-	templates, err := LoadTemplates()
+// Routes are registered without basePath, which the caller strips before
+// routing; it is used for redirects, the session cookie and template links.
+func NewRouter(db *sql.DB, jwtSecret, basePath string, opts Options) (http.Handler, error) {
+	templates, err := LoadTemplates(basePath)
 	if err != nil {
 		return nil, err
 	}
@@ -21,13 +37,22 @@ func NewRouter(db *sql.DB, jwtSecret string) (http.Handler, error) {
 		DB:        db,
 		Templates: templates,
 		JWTSecret: jwtSecret,
+		BasePath:  basePath,
+
+		RestrictTransfers: opts.RestrictTransfers,
+	}
+
+	static, err := newStaticHandler(webembed.StaticFS())
+	if err != nil {
+		return nil, err
 	}
 
 	mux := http.NewServeMux()
-	cookieAuth := CookieAuthMiddleware(jwtSecret, db)
+	cookieAuth := CookieAuthMiddleware(jwtSecret, db, basePath)
+	read := requireRole(opts.ReadRole)
 
-	// Static assets.
-	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(webembed.StaticFS()))))
+	// Static assets (gzip negotiated via Accept-Encoding).
+	mux.Handle("GET /static/", http.StripPrefix("/static/", static))
 
 	// Public routes.
 	mux.HandleFunc("GET /login", s.LoginPage)
@@ -35,23 +60,24 @@ func NewRouter(db *sql.DB, jwtSecret string) (http.Handler, error) {
 	mux.HandleFunc("POST /logout", s.Logout)
 
 	// Authenticated routes.
-	mux.Handle("GET /{$}", cookieAuth(http.HandlerFunc(s.Dashboard)))
+	mux.Handle("GET /{$}", cookieAuth(read(http.HandlerFunc(s.Dashboard))))
 
-	mux.Handle("GET /items", cookieAuth(http.HandlerFunc(s.ItemsPage)))
+	mux.Handle("GET /items", cookieAuth(read(http.HandlerFunc(s.ItemsPage))))
 	mux.Handle("POST /items", cookieAuth(http.HandlerFunc(s.ItemCreateSubmit)))
-	mux.Handle("GET /items/{id}", cookieAuth(http.HandlerFunc(s.ItemDetailPage)))
+	mux.Handle("GET /items/{id}", cookieAuth(read(http.HandlerFunc(s.ItemDetailPage))))
 	mux.Handle("POST /items/{id}", cookieAuth(http.HandlerFunc(s.ItemUpdateSubmit)))
 	mux.Handle("POST /items/{id}/stock", cookieAuth(http.HandlerFunc(s.ItemStockSubmit)))
 	mux.Handle("POST /items/{id}/image", cookieAuth(http.HandlerFunc(s.ItemImageSubmit)))
-	mux.Handle("GET /items/{id}/image", cookieAuth(http.HandlerFunc(s.ItemImageGet)))
+	mux.Handle("GET /items/{id}/image", cookieAuth(read(http.HandlerFunc(s.ItemImageGet))))
+	mux.Handle("GET /items/{id}/documents/{docID}", cookieAuth(read(http.HandlerFunc(s.ItemDocumentGet))))
 
-	mux.Handle("GET /owners", cookieAuth(http.HandlerFunc(s.OwnersPage)))
+	mux.Handle("GET /owners", cookieAuth(read(http.HandlerFunc(s.OwnersPage))))
 	mux.Handle("POST /owners", cookieAuth(http.HandlerFunc(s.OwnerCreateSubmit)))
-	mux.Handle("GET /owners/{id}", cookieAuth(http.HandlerFunc(s.OwnerDetailPage)))
+	mux.Handle("GET /owners/{id}", cookieAuth(read(http.HandlerFunc(s.OwnerDetailPage))))
 	mux.Handle("POST /owners/{id}", cookieAuth(http.HandlerFunc(s.OwnerUpdateSubmit)))
 
-	mux.Handle("GET /transfers", cookieAuth(http.HandlerFunc(s.TransfersPage)))
-	mux.Handle("GET /transfers/new", cookieAuth(http.HandlerFunc(s.TransferNewPage)))
+	mux.Handle("GET /transfers", cookieAuth(read(http.HandlerFunc(s.TransfersPage))))
+	mux.Handle("GET /transfers/new", cookieAuth(read(http.HandlerFunc(s.TransferNewPage))))
 	mux.Handle("POST /transfers/new", cookieAuth(http.HandlerFunc(s.TransferCreateSubmit)))
 
 	mux.Handle("GET /users", cookieAuth(http.HandlerFunc(s.UsersPage)))
@@ -61,6 +87,7 @@ func NewRouter(db *sql.DB, jwtSecret string) (http.Handler, error) {
 
 	mux.Handle("GET /settings", cookieAuth(http.HandlerFunc(s.SettingsPage)))
 	mux.Handle("POST /settings", cookieAuth(http.HandlerFunc(s.SettingsSubmit)))
+	mux.Handle("POST /settings/language", cookieAuth(http.HandlerFunc(s.SettingsLanguageSubmit)))
 
 	return mux, nil
 }
@@ -73,7 +100,7 @@ func (s *Server) ItemImageGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, mime, err := store.GetItemImage(r.Context(), s.DB, id)
+	data, mime, err := store.GetItemImage(r.Context(), s.DB, id, false)
 	if err != nil {
 		slog.Error("failed to get image", "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -92,3 +119,30 @@ func (s *Server) ItemImageGet(w http.ResponseWriter, r *http.Request) {
 		slog.Error("failed to write image response", "error", err)
 	}
 }
+
+// ItemDocumentGet handles GET /items/{id}/documents/{docID} (web route,
+// cookie-authenticated).
+func (s *Server) ItemDocumentGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	docID, err := strconv.ParseInt(r.PathValue("docID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := store.GetDocument(r.Context(), s.DB, id, docID, true)
+	if err != nil {
+		slog.Error("failed to get document", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if doc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	api.ServeDocument(w, doc)
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compressibleExts lists static file extensions that are gzipped at startup.
+var compressibleExts = map[string]bool{
+	".css":  true,
+	".js":   true,
+	".html": true,
+	".svg":  true,
+}
+
+// staticFile holds an embedded asset and its gzipped variant.
+type staticFile struct {
+	contentType string
+	raw         []byte
+	gzip        []byte // nil if gzip does not make it smaller
+}
+
+// staticHandler serves embedded static assets, choosing the gzipped
+// variant based on the client's Accept-Encoding header.
+type staticHandler struct {
+	files    map[string]*staticFile
+	fallback http.Handler
+	modTime  time.Time
+}
+
+// newStaticHandler loads all files from fsys and gzips text assets. Other
+// files are left to an http.FileServer.
+func newStaticHandler(fsys fs.FS) (http.Handler, error) {
+	h := &staticHandler{
+		files:    make(map[string]*staticFile),
+		fallback: http.FileServer(http.FS(fsys)),
+		modTime:  time.Now(),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := path.Ext(name)
+		if d.IsDir() || !compressibleExts[ext] {
+			return nil
+		}
+
+		raw, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("reading static file %s: %w", name, err)
+		}
+		f := &staticFile{contentType: mime.TypeByExtension(ext), raw: raw}
+
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, err := zw.Write(raw); err != nil {
+			return fmt.Errorf("compressing static file %s: %w", name, err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("compressing static file %s: %w", name, err)
+		}
+		if buf.Len() < len(raw) {
+			f.gzip = buf.Bytes()
+		}
+
+		h.files[name] = f
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading static files: %w", err)
+	}
+
+	return h, nil
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	f, ok := h.files[name]
+	if !ok {
+		h.fallback.ServeHTTP(w, r)
+		return
+	}
+
+	data := f.raw
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Set("Content-Type", f.contentType)
+	if f.gzip != nil && acceptedEncodings(r.Header.Get("Accept-Encoding"))["gzip"] {
+		data = f.gzip
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	http.ServeContent(w, r, name, h.modTime, bytes.NewReader(data))
+}
+
+// acceptedEncodings parses an Accept-Encoding header into the set of
+// encodings the client accepts (q > 0).
+func acceptedEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		accepted[coding] = true
+	}
+	return accepted
+}
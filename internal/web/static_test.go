@@ -0,0 +1,74 @@
+package web
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticHandlerNegotiation(t *testing.T) {
+	css := strings.Repeat("body { margin: 0; }\n", 100)
+	h, err := newStaticHandler(fstest.MapFS{
+		"style.css": {Data: []byte(css)},
+		"tiny.js":   {Data: []byte("x")},
+		"logo.png":  {Data: []byte("\x89PNG")},
+	})
+	if err != nil {
+		t.Fatalf("newStaticHandler: %v", err)
+	}
+	get := func(name, acceptEncoding string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/"+name, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("style.css", "br, gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/css") {
+		t.Errorf("expected the CSS content type, got %q", got)
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("expected Vary: Accept-Encoding, got %q", rec.Header().Get("Vary"))
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != css {
+		t.Error("expected the gzipped body to decompress to the file")
+	}
+
+	for _, accept := range []string{"", "identity", "br", "gzip;q=0", "deflate, gzip; q=0.0"} {
+		rec := get("style.css", accept)
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != css {
+			t.Errorf("Accept-Encoding %q: expected the raw file, got encoding %q", accept, rec.Header().Get("Content-Encoding"))
+		}
+	}
+	if rec := get("style.css", "GZIP;q=0.5"); rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("expected gzip for an upper-case coding with q > 0, got %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	// Gzip would make a tiny file larger, so it is always sent raw.
+	if rec := get("tiny.js", "gzip"); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "x" {
+		t.Errorf("expected tiny.js raw, got encoding %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	// Other files go to the file server, uncompressed.
+	if rec := get("logo.png", "gzip"); rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "\x89PNG" {
+		t.Errorf("expected logo.png from the file server, got %d %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if rec := get("missing.css", "gzip"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing file, got %d", rec.Code)
+	}
+}
@@ -7,52 +7,49 @@ import (
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/erazemk/skladisce/internal/auth"
 	"github.com/erazemk/skladisce/internal/model"
 	webembed "github.com/erazemk/skladisce/web"
 )
 
-// Templates holds parsed HTML templates.
+// Templates holds parsed HTML templates, one set per UI locale.
 type Templates struct {
-	templates map[string]*template.Template
+	templates map[string]map[string]*template.Template
 }
 
-// FuncMap returns the template function map.
-func FuncMap() template.FuncMap {
+// FuncMap returns the template function map for locale. base returns
+// basePath, the prefix for every link, form action and asset URL; t looks up
+// a UI string in the message catalog; quantity renders a stored quantity
+// (see model.FormatQuantity).
+func FuncMap(basePath, locale string) template.FuncMap {
 	return template.FuncMap{
-		"roleAtLeast": model.RoleAtLeast,
+		"base":              func() string { return basePath },
+		"lang":              func() string { return locale },
+		"roleAtLeast":       model.RoleAtLeast,
+		"minPasswordLength": model.MinPasswordLength,
+		"lower":             strings.ToLower,
+		"quantity":          model.FormatQuantity,
+		"t": func(key string, args ...any) string {
+			return translate(locale, key, args...)
+		},
 		"roleName": func(role string) string {
-			switch role {
-			case "admin":
-				return "Administrator"
-			case "manager":
-				return "Skladiščar"
-			case "user":
-				return "Uporabnik"
-			default:
-				return role
-			}
+			return translateOr(locale, "role."+role, role)
 		},
 		"statusName": func(status string) string {
-			switch status {
-			case "active":
-				return "Aktiven"
-			case "damaged":
-				return "Poškodovan"
-			case "lost":
-				return "Izgubljen"
-			case "removed":
-				return "Odpisan"
-			default:
-				return status
-			}
+			return translateOr(locale, "status."+status, status)
+		},
+		"ownerTypeName": func(ownerType string) string {
+			return translateOr(locale, "ownerType."+ownerType, ownerType)
 		},
 	}
 }
 
-// LoadTemplates parses all page templates with the layout.
-func LoadTemplates() (*Templates, error) {
+// LoadTemplates parses all page templates with the layout, once for every
+// locale in the message catalog. Links in the templates are prefixed with
+// basePath.
+func LoadTemplates(basePath string) (*Templates, error) {
 	tfs := webembed.TemplatesFS()
 
 	// Read layout.
@@ -74,33 +71,41 @@ func LoadTemplates() (*Templates, error) {
 		"settings.html",
 	}
 
-	ts := &Templates{templates: make(map[string]*template.Template)}
+	ts := &Templates{templates: make(map[string]map[string]*template.Template)}
 
-	for _, page := range pages {
-		pageBytes, err := fs.ReadFile(tfs, page)
-		if err != nil {
-			return nil, fmt.Errorf("reading template %s: %w", page, err)
-		}
+	for locale := range messages {
+		ts.templates[locale] = make(map[string]*template.Template)
+		for _, page := range pages {
+			pageBytes, err := fs.ReadFile(tfs, page)
+			if err != nil {
+				return nil, fmt.Errorf("reading template %s: %w", page, err)
+			}
 
-		tmpl := template.New(page).Funcs(FuncMap())
-		tmpl, err = tmpl.Parse(string(layoutBytes))
-		if err != nil {
-			return nil, fmt.Errorf("parsing layout for %s: %w", page, err)
-		}
-		tmpl, err = tmpl.Parse(string(pageBytes))
-		if err != nil {
-			return nil, fmt.Errorf("parsing template %s: %w", page, err)
-		}
+			tmpl := template.New(page).Funcs(FuncMap(basePath, locale))
+			tmpl, err = tmpl.Parse(string(layoutBytes))
+			if err != nil {
+				return nil, fmt.Errorf("parsing layout for %s: %w", page, err)
+			}
+			tmpl, err = tmpl.Parse(string(pageBytes))
+			if err != nil {
+				return nil, fmt.Errorf("parsing template %s: %w", page, err)
+			}
 
-		ts.templates[page] = tmpl
+			ts.templates[locale][page] = tmpl
+		}
 	}
 
 	return ts, nil
 }
 
-// Render renders a template with the given data.
-func (ts *Templates) Render(w http.ResponseWriter, name string, data any) {
-	tmpl, ok := ts.templates[name]
+// Render renders a template in locale (falling back to the default locale)
+// with the given data.
+func (ts *Templates) Render(w http.ResponseWriter, name, locale string, data any) {
+	set, ok := ts.templates[locale]
+	if !ok {
+		set = ts.templates[model.DefaultLocale]
+	}
+	tmpl, ok := set[name]
 	if !ok {
 		http.Error(w, "template not found", http.StatusInternalServerError)
 		return
@@ -121,9 +126,50 @@ type PageData struct {
 	Success string
 }
 
+// page gives render access to the PageData embedded in a page's data.
+func (p *PageData) page() *PageData {
+	return p
+}
+
 // Server holds all dependencies for page handlers.
 type Server struct {
 	DB        *sql.DB
 	Templates *Templates
 	JWTSecret string
+	// BasePath is the path prefix the UI is served under ("" for the root).
+	BasePath string
+	// RestrictTransfers limits users below manager to transfers to or from
+	// their own person owner.
+	RestrictTransfers bool
+}
+
+// t returns the UI string for key in the locale of the request's user.
+func (s *Server) t(r *http.Request, key string, args ...any) string {
+	return translate(GetWebLocale(r.Context()), key, args...)
+}
+
+// render renders a page in the locale of the request's user. A flash set
+// before the redirect that led here becomes the page's success message,
+// unless the page has its own, and is then cleared.
+func (s *Server) render(w http.ResponseWriter, r *http.Request, name string, data any) {
+	if key, _ := r.Context().Value(webFlashKey).(string); key != "" {
+		if p, ok := data.(interface{ page() *PageData }); ok {
+			if page := p.page(); page.Success == "" {
+				page.Success = s.t(r, key)
+			}
+			clearFlashCookie(w, s.BasePath)
+		}
+	}
+	s.Templates.Render(w, name, GetWebLocale(r.Context()), data)
+}
+
+// redirect sends a 303 See Other to path under the base path.
+func (s *Server) redirect(w http.ResponseWriter, r *http.Request, path string) {
+	http.Redirect(w, r, s.BasePath+path, http.StatusSeeOther)
+}
+
+// flash shows the "flash." message key as a success message on the next page
+// rendered, for handlers that redirect after a change.
+func (s *Server) flash(w http.ResponseWriter, key string) {
+	setFlashCookie(w, key, s.BasePath)
 }
@@ -1,10 +1,12 @@
 package web
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
 
+	"github.com/erazemk/skladisce/internal/api"
 	"github.com/erazemk/skladisce/internal/model"
 	"github.com/erazemk/skladisce/internal/store"
 )
@@ -12,16 +14,16 @@ import (
 // TransfersPage handles GET /transfers.
 func (s *Server) TransfersPage(w http.ResponseWriter, r *http.Request) {
 	claims := GetWebClaims(r.Context())
-	transfers, err := store.ListTransfers(r.Context(), s.DB, 0, 0)
+	transfers, err := store.ListTransfers(r.Context(), s.DB, 0, 0, "")
 	if err != nil {
 		slog.Error("failed to list transfers", "error", err)
 	}
 
-	s.Templates.Render(w, "transfers.html", &struct {
+	s.render(w, r, "transfers.html", &struct {
 		PageData
 		Transfers []model.Transfer
 	}{
-		PageData:  PageData{Title: "Prenosi", User: claims, Token: GetWebToken(r.Context())},
+		PageData:  PageData{Title: s.t(r, "transfers.title"), User: claims, Token: GetWebToken(r.Context())},
 		Transfers: transfers,
 	})
 }
@@ -29,7 +31,7 @@ func (s *Server) TransfersPage(w http.ResponseWriter, r *http.Request) {
 // TransferNewPage handles GET /transfers/new.
 func (s *Server) TransferNewPage(w http.ResponseWriter, r *http.Request) {
 	claims := GetWebClaims(r.Context())
-	items, err := store.ListItems(r.Context(), s.DB, "")
+	items, err := store.ListItems(r.Context(), s.DB, model.ItemFilter{})
 	if err != nil {
 		slog.Error("failed to list items for transfer form", "error", err)
 	}
@@ -38,12 +40,12 @@ func (s *Server) TransferNewPage(w http.ResponseWriter, r *http.Request) {
 		slog.Error("failed to list owners for transfer form", "error", err)
 	}
 
-	s.Templates.Render(w, "transfer_new.html", &struct {
+	s.render(w, r, "transfer_new.html", &struct {
 		PageData
 		Items  []model.Item
 		Owners []model.Owner
 	}{
-		PageData: PageData{Title: "Nov prenos", User: claims, Token: GetWebToken(r.Context())},
+		PageData: PageData{Title: s.t(r, "transfer.title"), User: claims, Token: GetWebToken(r.Context())},
 		Items:    items,
 		Owners:   owners,
 	})
@@ -56,37 +58,73 @@ func (s *Server) TransferCreateSubmit(w http.ResponseWriter, r *http.Request) {
 	itemID, _ := strconv.ParseInt(r.FormValue("item_id"), 10, 64)
 	fromOwnerID, _ := strconv.ParseInt(r.FormValue("from_owner_id"), 10, 64)
 	toOwnerID, _ := strconv.ParseInt(r.FormValue("to_owner_id"), 10, 64)
-	quantity, _ := strconv.Atoi(r.FormValue("quantity"))
+	divisible, err := store.ItemDivisible(r.Context(), s.DB, itemID)
+	if err != nil {
+		slog.Error("failed to check item", "error", err)
+	}
+	quantity, err := model.ParseQuantity(r.FormValue("quantity"), divisible)
+	if err != nil {
+		s.renderTransferForm(w, r, s.t(r, "transfer.failed"))
+		return
+	}
 	notes := r.FormValue("notes")
+	if err := model.ValidateNotes(notes); err != nil {
+		s.renderTransferForm(w, r, s.t(r, "transfer.notesTooLong", model.MaxNotesLength))
+		return
+	}
+
+	if s.RestrictTransfers {
+		allowed, err := api.AllowTransfer(r.Context(), store.New(s.DB), claims, toOwnerID, fromOwnerID)
+		if err != nil {
+			slog.Error("failed to check transfer policy", "error", err)
+		}
+		if !allowed {
+			s.renderTransferForm(w, r, s.t(r, "transfer.notOwn"))
+			return
+		}
+	}
 
 	userID := claims.UserID
-	transfer, err := store.CreateTransfer(r.Context(), s.DB, itemID, fromOwnerID, toOwnerID, quantity, notes, &userID)
+	transfer, err := store.CreateTransfer(r.Context(), s.DB, itemID, fromOwnerID, toOwnerID, quantity, divisible, notes, &userID)
 
 	if err != nil {
 		slog.Warn("transfer creation failed", "error", err, "user", claims.Username)
-		items, err2 := store.ListItems(r.Context(), s.DB, "")
-		if err2 != nil {
-			slog.Error("failed to list items for transfer error page", "error", err2)
+		errMsg := s.t(r, "transfer.failed")
+		var insufficient *store.InsufficientQuantityError
+		if errors.As(err, &insufficient) {
+			errMsg = s.t(r, "transfer.insufficient",
+				model.FormatQuantity(insufficient.Available, insufficient.Divisible),
+				model.FormatQuantity(insufficient.Requested, insufficient.Divisible))
 		}
-		owners, err2 := store.ListOwners(r.Context(), s.DB, "")
-		if err2 != nil {
-			slog.Error("failed to list owners for transfer error page", "error", err2)
-		}
-
-		s.Templates.Render(w, "transfer_new.html", &struct {
-			PageData
-			Items  []model.Item
-			Owners []model.Owner
-		}{
-			PageData: PageData{Title: "Nov prenos", User: claims, Token: GetWebToken(r.Context()), Error: "Prenos ni uspel. Preverite količino in lastnika."},
-			Items:    items,
-			Owners:   owners,
-		})
+		s.renderTransferForm(w, r, errMsg)
 		return
 	}
 
 	slog.Info("transfer created", "user", claims.Username,
-		"item", transfer.ItemName, "quantity", transfer.Quantity,
+		"item", transfer.ItemName, "quantity", model.FormatQuantity(transfer.Quantity, transfer.Divisible),
 		"from", transfer.FromOwnerName, "to", transfer.ToOwnerName)
-	http.Redirect(w, r, "/transfers", http.StatusSeeOther)
+	s.flash(w, "flash.transferCreated")
+	s.redirect(w, r, "/transfers")
+}
+
+// renderTransferForm re-renders the new transfer form with an error message.
+func (s *Server) renderTransferForm(w http.ResponseWriter, r *http.Request, errMsg string) {
+	items, err := store.ListItems(r.Context(), s.DB, model.ItemFilter{})
+	if err != nil {
+		slog.Error("failed to list items for transfer error page", "error", err)
+	}
+	owners, err := store.ListOwners(r.Context(), s.DB, "")
+	if err != nil {
+		slog.Error("failed to list owners for transfer error page", "error", err)
+	}
+
+	s.render(w, r, "transfer_new.html", &struct {
+		PageData
+		Items  []model.Item
+		Owners []model.Owner
+	}{
+		PageData: PageData{Title: s.t(r, "transfer.title"), User: GetWebClaims(r.Context()), Token: GetWebToken(r.Context()), Error: errMsg},
+		Items:    items,
+		Owners:   owners,
+	})
 }
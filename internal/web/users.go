@@ -8,6 +8,7 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/erazemk/skladisce/internal/auth"
 	"github.com/erazemk/skladisce/internal/model"
 	"github.com/erazemk/skladisce/internal/store"
 )
@@ -25,11 +26,11 @@ func (s *Server) UsersPage(w http.ResponseWriter, r *http.Request) {
 		slog.Error("failed to list users", "error", err)
 	}
 
-	s.Templates.Render(w, "users.html", &struct {
+	s.render(w, r, "users.html", &struct {
 		PageData
 		Users []model.User
 	}{
-		PageData: PageData{Title: "Uporabniki", User: claims, Token: GetWebToken(r.Context())},
+		PageData: PageData{Title: s.t(r, "users.title"), User: claims, Token: GetWebToken(r.Context())},
 		Users:    users,
 	})
 }
@@ -47,17 +48,17 @@ func (s *Server) UserCreateSubmit(w http.ResponseWriter, r *http.Request) {
 	role := r.FormValue("role")
 
 	if username == "" || password == "" || role == "" {
-		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		s.redirect(w, r, "/users")
 		return
 	}
 
 	if err := model.ValidatePassword(password); err != nil {
 		users, _ := store.ListUsers(r.Context(), s.DB)
-		s.Templates.Render(w, "users.html", &struct {
+		s.render(w, r, "users.html", &struct {
 			PageData
 			Users []model.User
 		}{
-			PageData: PageData{Title: "Uporabniki", User: claims, Token: GetWebToken(r.Context()), Error: fmt.Sprintf("Geslo: %s", err.Error())},
+			PageData: PageData{Title: s.t(r, "users.title"), User: claims, Token: GetWebToken(r.Context()), Error: s.t(r, "users.invalidPassword", err.Error())},
 			Users:    users,
 		})
 		return
@@ -74,8 +75,9 @@ func (s *Server) UserCreateSubmit(w http.ResponseWriter, r *http.Request) {
 		slog.Error("failed to create user", "error", err)
 	} else {
 		slog.Info("user created", "user", claims.Username, "new_user", username, "role", role)
+		s.flash(w, "flash.userCreated")
 	}
-	http.Redirect(w, r, "/users", http.StatusSeeOther)
+	s.redirect(w, r, "/users")
 }
 
 // UserResetPasswordSubmit handles POST /users/{id}/password (admin only).
@@ -88,19 +90,19 @@ func (s *Server) UserResetPasswordSubmit(w http.ResponseWriter, r *http.Request)
 
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		s.redirect(w, r, "/users")
 		return
 	}
 
 	newPassword := r.FormValue("new_password")
 	if newPassword == "" {
-		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		s.redirect(w, r, "/users")
 		return
 	}
 
 	if err := model.ValidatePassword(newPassword); err != nil {
 		// Redirect back; password too short.
-		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		s.redirect(w, r, "/users")
 		return
 	}
 
@@ -111,7 +113,8 @@ func (s *Server) UserResetPasswordSubmit(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := store.UpdateUserPassword(r.Context(), s.DB, id, string(hash)); err != nil {
+	mustChange := r.FormValue("must_change_password") != ""
+	if err := store.UpdateUserPassword(r.Context(), s.DB, id, string(hash), mustChange); err != nil {
 		slog.Error("failed to reset password", "error", err)
 	}
 
@@ -120,8 +123,8 @@ func (s *Server) UserResetPasswordSubmit(w http.ResponseWriter, r *http.Request)
 	if target != nil {
 		targetName = target.Username
 	}
-	slog.Info("user password reset", "user", claims.Username, "target_user", targetName)
-	http.Redirect(w, r, "/users", http.StatusSeeOther)
+	slog.Info("user password reset", "user", claims.Username, "target_user", targetName, "must_change", mustChange)
+	s.redirect(w, r, "/users")
 }
 
 // UserUpdateRoleSubmit handles POST /users/{id}/role (admin only).
@@ -134,24 +137,24 @@ func (s *Server) UserUpdateRoleSubmit(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		s.redirect(w, r, "/users")
 		return
 	}
 
 	role := r.FormValue("role")
 	if role != model.RoleAdmin && role != model.RoleManager && role != model.RoleUser {
-		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		s.redirect(w, r, "/users")
 		return
 	}
 
 	if claims.UserID == id {
-		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		s.redirect(w, r, "/users")
 		return
 	}
 
 	if err := store.UpdateUser(r.Context(), s.DB, id, role); err != nil {
 		slog.Error("failed to update user role", "user", claims.Username, "target_id", id, "error", err)
-		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		s.redirect(w, r, "/users")
 		return
 	}
 
@@ -161,17 +164,21 @@ func (s *Server) UserUpdateRoleSubmit(w http.ResponseWriter, r *http.Request) {
 		targetName = target.Username
 	}
 	slog.Info("user role updated", "user", claims.Username, "target_user", targetName, "new_role", role)
-	http.Redirect(w, r, "/users", http.StatusSeeOther)
+	s.redirect(w, r, "/users")
 }
 
 // SettingsPage handles GET /settings.
 func (s *Server) SettingsPage(w http.ResponseWriter, r *http.Request) {
 	claims := GetWebClaims(r.Context())
-	s.Templates.Render(w, "settings.html", &PageData{
-		Title: "Nastavitve",
+	data := &PageData{
+		Title: s.t(r, "settings.title"),
 		User:  claims,
 		Token: GetWebToken(r.Context()),
-	})
+	}
+	if claims.MustChangePassword {
+		data.Error = s.t(r, "settings.mustChange")
+	}
+	s.render(w, r, "settings.html", data)
 }
 
 // SettingsSubmit handles POST /settings (change own password).
@@ -182,21 +189,21 @@ func (s *Server) SettingsSubmit(w http.ResponseWriter, r *http.Request) {
 	newPassword := r.FormValue("new_password")
 
 	if currentPassword == "" || newPassword == "" {
-		s.Templates.Render(w, "settings.html", &PageData{
-			Title: "Nastavitve",
+		s.render(w, r, "settings.html", &PageData{
+			Title: s.t(r, "settings.title"),
 			User:  claims,
 			Token: GetWebToken(r.Context()),
-			Error: "Vnesite trenutno in novo geslo.",
+			Error: s.t(r, "settings.missingPasswords"),
 		})
 		return
 	}
 
 	if err := model.ValidatePassword(newPassword); err != nil {
-		s.Templates.Render(w, "settings.html", &PageData{
-			Title: "Nastavitve",
+		s.render(w, r, "settings.html", &PageData{
+			Title: s.t(r, "settings.title"),
 			User:  claims,
 			Token: GetWebToken(r.Context()),
-			Error: "Novo geslo mora imeti vsaj 8 znakov.",
+			Error: s.t(r, "settings.passwordTooShort", model.MinPasswordLength()),
 		})
 		return
 	}
@@ -204,21 +211,21 @@ func (s *Server) SettingsSubmit(w http.ResponseWriter, r *http.Request) {
 	user, err := store.GetUser(r.Context(), s.DB, claims.UserID)
 	if err != nil || user == nil {
 		slog.Error("failed to get user for password change", "error", err)
-		s.Templates.Render(w, "settings.html", &PageData{
-			Title: "Nastavitve",
+		s.render(w, r, "settings.html", &PageData{
+			Title: s.t(r, "settings.title"),
 			User:  claims,
 			Token: GetWebToken(r.Context()),
-			Error: "Napaka pri pridobivanju uporabnika.",
+			Error: s.t(r, "settings.userLookupFailed"),
 		})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
-		s.Templates.Render(w, "settings.html", &PageData{
-			Title: "Nastavitve",
+		s.render(w, r, "settings.html", &PageData{
+			Title: s.t(r, "settings.title"),
 			User:  claims,
 			Token: GetWebToken(r.Context()),
-			Error: "Trenutno geslo ni pravilno.",
+			Error: s.t(r, "settings.wrongPassword"),
 		})
 		return
 	}
@@ -226,31 +233,72 @@ func (s *Server) SettingsSubmit(w http.ResponseWriter, r *http.Request) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
 		slog.Error("failed to hash new password", "error", err)
-		s.Templates.Render(w, "settings.html", &PageData{
-			Title: "Nastavitve",
+		s.render(w, r, "settings.html", &PageData{
+			Title: s.t(r, "settings.title"),
 			User:  claims,
 			Token: GetWebToken(r.Context()),
-			Error: "Napaka pri shranjevanju gesla.",
+			Error: s.t(r, "settings.hashFailed"),
 		})
 		return
 	}
 
-	if err := store.UpdateUserPassword(r.Context(), s.DB, claims.UserID, string(hash)); err != nil {
+	if err := store.UpdateUserPassword(r.Context(), s.DB, claims.UserID, string(hash), false); err != nil {
 		slog.Error("failed to update password", "error", err)
-		s.Templates.Render(w, "settings.html", &PageData{
-			Title: "Nastavitve",
+		s.render(w, r, "settings.html", &PageData{
+			Title: s.t(r, "settings.title"),
 			User:  claims,
 			Token: GetWebToken(r.Context()),
-			Error: "Napaka pri posodabljanju gesla.",
+			Error: s.t(r, "settings.updateFailed"),
 		})
 		return
 	}
 
+	// The change invalidates every existing session, including this one, so
+	// replace the cookie with a fresh token.
+	token, err := auth.GenerateToken(s.JWTSecret, claims.UserID, claims.Username, claims.Role)
+	if err != nil {
+		slog.Error("failed to generate token", "error", err)
+		s.redirect(w, r, "/login")
+		return
+	}
+	setAuthCookie(w, token, s.BasePath)
+
 	slog.Info("user changed own password", "user", claims.Username)
-	s.Templates.Render(w, "settings.html", &PageData{
-		Title:   "Nastavitve",
+	s.render(w, r, "settings.html", &PageData{
+		Title:   s.t(r, "settings.title"),
+		User:    claims,
+		Token:   token,
+		Success: s.t(r, "settings.passwordChanged"),
+	})
+}
+
+// SettingsLanguageSubmit handles POST /settings/language (change own UI
+// language).
+func (s *Server) SettingsLanguageSubmit(w http.ResponseWriter, r *http.Request) {
+	claims := GetWebClaims(r.Context())
+
+	locale := r.FormValue("locale")
+	if !model.ValidLocale(locale) {
+		s.redirect(w, r, "/settings")
+		return
+	}
+
+	if err := store.SetUserLocale(r.Context(), s.DB, claims.UserID, locale); err != nil {
+		slog.Error("failed to set user locale", "error", err)
+		s.render(w, r, "settings.html", &PageData{
+			Title: s.t(r, "settings.title"),
+			User:  claims,
+			Token: GetWebToken(r.Context()),
+			Error: s.t(r, "settings.languageFailed"),
+		})
+		return
+	}
+
+	slog.Info("user changed language", "user", claims.Username, "locale", locale)
+	s.Templates.Render(w, "settings.html", locale, &PageData{
+		Title:   translate(locale, "settings.title"),
 		User:    claims,
 		Token:   GetWebToken(r.Context()),
-		Success: "Geslo uspešno spremenjeno.",
+		Success: translate(locale, "settings.languageChanged"),
 	})
 }